@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Name of the file in the config directory holding the aliases
+const aliasesFileName = "aliases.json"
+
+// Subcommands of the alias command
+var aliasSubcommands = []string{"add", "list", "remove"}
+
+// AliasEntry is one alias in the JSON output of alias list
+type AliasEntry struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+}
+
+// aliasCommand manages aliases, canned prompts run as their own command
+func aliasCommand(config *Config, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("alias subcommand required\nUsage: chatgpt-cli alias <%s>", strings.Join(aliasSubcommands, "|"))
+	}
+
+	switch args[0] {
+	case "add":
+		return aliasAddCommand(config, args[1:])
+	case "list":
+		return aliasListCommand(config, args[1:])
+	case "remove":
+		return aliasRemoveCommand(config, args[1:])
+	default:
+		return usageErrorf("unknown alias subcommand: %s\nValid subcommands: %s", args[0], strings.Join(aliasSubcommands, ", "))
+	}
+}
+
+// aliasAddCommand saves an alias, replacing one of the same name
+func aliasAddCommand(config *Config, args []string) error {
+	if len(args) < 2 {
+		return usageErrorf("alias name and prompt required\nUsage: chatgpt-cli alias add <name> \"prompt text\"")
+	}
+
+	name := args[0]
+	if err := validateAliasName(name); err != nil {
+		return err
+	}
+
+	prompt := strings.Join(args[1:], " ")
+	if strings.TrimSpace(prompt) == "" {
+		return usageErrorf("alias prompt cannot be empty")
+	}
+
+	aliases, err := loadAliases(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	_, replaced := aliases[name]
+	aliases[name] = prompt
+
+	if err := saveAliases(config.ConfigDir, aliases); err != nil {
+		return err
+	}
+
+	if replaced {
+		fmt.Printf("Updated alias %s\n", name)
+	} else {
+		fmt.Printf("Added alias %s; run it with: chatgpt-cli %s <text>\n", name, name)
+	}
+	return nil
+}
+
+// aliasListCommand prints the aliases sorted by name
+func aliasListCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli alias list", args[0])
+	}
+
+	aliases, err := loadAliases(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+
+	entries := []AliasEntry{}
+	for _, name := range aliasNames(aliases) {
+		entries = append(entries, AliasEntry{Name: name, Prompt: aliases[name]})
+	}
+
+	if config.Output == outputJSON {
+		return printJSON(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No aliases defined.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPROMPT")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\n", entry.Name, truncate(oneLine(entry.Prompt), 60))
+	}
+	return w.Flush()
+}
+
+// aliasRemoveCommand deletes an alias
+func aliasRemoveCommand(config *Config, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("alias name required\nUsage: chatgpt-cli alias remove <name>")
+	}
+	name := args[0]
+
+	aliases, err := loadAliases(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	if _, exists := aliases[name]; !exists {
+		return usageErrorf("no alias named %s", name)
+	}
+	delete(aliases, name)
+
+	if err := saveAliases(config.ConfigDir, aliases); err != nil {
+		return err
+	}
+	fmt.Printf("Removed alias %s\n", name)
+	return nil
+}
+
+// validateAliasName rejects names that can't be typed as a command or that
+// a built-in command already uses
+func validateAliasName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") {
+		return usageErrorf("invalid alias name %q: it must not be empty or start with -", name)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return usageErrorf("invalid alias name %q: use only letters, digits, - and _", name)
+		}
+	}
+	if _, exists := getCommands()[name]; exists {
+		return usageErrorf("%s is a built-in command and cannot be used as an alias name", name)
+	}
+	return nil
+}
+
+// aliasCommandFor returns the command running the alias name, if defined.
+// An alias sends its prompt followed by the arguments, and is logged under
+// its own name.
+func aliasCommandFor(config *Config, name string) (Command, bool) {
+	aliases, err := loadAliases(config.ConfigDir)
+	if err != nil {
+		return Command{}, false
+	}
+	prompt, exists := aliases[name]
+	if !exists {
+		return Command{}, false
+	}
+
+	return Command{
+		Name:        name,
+		Description: "Alias for: " + prompt,
+		Handler: func(config *Config, args []string) error {
+			return runPrompt(config, name, prompt, args)
+		},
+	}, true
+}
+
+// aliasNames returns the names of the aliases, sorted
+func aliasNames(aliases map[string]string) []string {
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadAliases reads the aliases file, returning no aliases if it is missing
+func loadAliases(configDir string) (map[string]string, error) {
+	aliases := make(map[string]string)
+
+	data, err := os.ReadFile(filepath.Join(configDir, aliasesFileName))
+	if os.IsNotExist(err) {
+		return aliases, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read aliases: %w", err)
+	}
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", aliasesFileName, err)
+	}
+	return aliases, nil
+}
+
+// saveAliases writes the aliases file, creating the config directory if needed
+func saveAliases(configDir string, aliases map[string]string) error {
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode aliases: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, aliasesFileName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write aliases: %w", err)
+	}
+	return nil
+}
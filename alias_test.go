@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAliasCommands tests adding, listing and removing aliases
+func TestAliasCommands(t *testing.T) {
+	config := &Config{ConfigDir: t.TempDir()}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := aliasCommand(config, []string{"add", "explain", "Explain the following code in plain English:"}); err != nil {
+			t.Errorf("alias add error = %v", err)
+		}
+		if err := aliasCommand(config, []string{"add", "fr", "Translate", "to", "French:"}); err != nil {
+			t.Errorf("alias add with several words error = %v", err)
+		}
+		if err := aliasCommand(config, []string{"add", "fr", "Translate to French, formally:"}); err != nil {
+			t.Errorf("alias add replacing error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Added alias explain") || !strings.Contains(out, "Updated alias fr") {
+		t.Errorf("alias add output = %q", out)
+	}
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := aliasCommand(config, []string{"list"}); err != nil {
+			t.Errorf("alias list error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "explain  Explain the following code") || strings.Index(out, "explain") > strings.Index(out, "fr ") {
+		t.Errorf("alias list output = %q, want the aliases sorted by name", out)
+	}
+
+	config.Output = outputJSON
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := aliasCommand(config, []string{"list"}); err != nil {
+			t.Errorf("alias list error = %v", err)
+		}
+	})
+	var entries []AliasEntry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("alias list output is not valid JSON: %v\n%s", err, out)
+	}
+	if len(entries) != 2 || entries[1] != (AliasEntry{Name: "fr", Prompt: "Translate to French, formally:"}) {
+		t.Errorf("alias list entries = %+v", entries)
+	}
+	config.Output = outputPlain
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := aliasCommand(config, []string{"remove", "fr"}); err != nil {
+			t.Errorf("alias remove error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Removed alias fr") {
+		t.Errorf("alias remove output = %q", out)
+	}
+	aliases, err := loadAliases(config.ConfigDir)
+	if err != nil || len(aliases) != 1 {
+		t.Errorf("loadAliases() = %v, %v, want only explain", aliases, err)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"rename"},
+		{"add", "explain"},
+		{"add", "prompt", "Shadow the prompt command"},
+		{"add", "--flag", "text"},
+		{"add", "two words", "text"},
+		{"add", "blank", "  "},
+		{"remove", "missing"},
+		{"list", "extra"},
+	} {
+		if err := aliasCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("aliasCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
+
+// TestAliasInvocation tests that running an alias sends its prompt before the
+// arguments and logs the alias name
+func TestAliasInvocation(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err == nil && len(request.Messages) > 0 {
+			received = request.Messages[0].Content
+		}
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"It adds numbers"}}]}`)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	if _, exists := aliasCommandFor(config, "explain"); exists {
+		t.Fatal("aliasCommandFor() found an alias before any was added")
+	}
+	if err := saveAliases(tmpDir, map[string]string{"explain": "Explain this code:"}); err != nil {
+		t.Fatalf("saveAliases() error = %v", err)
+	}
+
+	command, exists := aliasCommandFor(config, "explain")
+	if !exists {
+		t.Fatal("aliasCommandFor() did not find the alias")
+	}
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := command.Handler(config, []string{"--no-stream", "func add(a, b int) int"}); err != nil {
+			t.Errorf("alias handler error = %v", err)
+		}
+	})
+	if received != "Explain this code:\n\nfunc add(a, b int) int" {
+		t.Errorf("sent prompt = %q, want the alias prompt before the arguments", received)
+	}
+	if !strings.Contains(out, "It adds numbers") {
+		t.Errorf("alias output = %q, want the reply", out)
+	}
+
+	// Without arguments the alias prompt is sent alone
+	captureOutput(t, &os.Stdout, func() {
+		if err := command.Handler(config, []string{"--no-stream"}); err != nil {
+			t.Errorf("alias handler without arguments error = %v", err)
+		}
+	})
+	if received != "Explain this code:" {
+		t.Errorf("sent prompt = %q, want only the alias prompt", received)
+	}
+
+	entries, _, err := readLogEntries([]string{filepath.Join(tmpDir, logFileName)}, logFilter{Command: "explain"}, 0)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("entries logged as explain = %+v, %v, want both invocations", entries, err)
+	}
+
+	candidates := completionCandidates(config, []string{"expl"})
+	if strings.Join(candidates, ",") != "explain,explain-code" {
+		t.Errorf("completionCandidates(expl) = %v, want the alias and explain-code", candidates)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Anthropic messages API defaults
+const (
+	defaultAnthropicAPIURL = "https://api.anthropic.com/v1/messages"
+	defaultAnthropicModel  = "claude-3-5-sonnet-latest"
+	anthropicVersion       = "2023-06-01"
+)
+
+// AnthropicRequest is the body of a messages API request. System prompts go
+// in a top-level field rather than in the messages.
+type AnthropicRequest struct {
+	Model         string    `json:"model"`
+	System        string    `json:"system,omitempty"`
+	Messages      []Message `json:"messages"`
+	MaxTokens     int       `json:"max_tokens"`
+	Temperature   float64   `json:"temperature,omitempty"`
+	TopP          float64   `json:"top_p,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+	Stream        bool      `json:"stream,omitempty"`
+}
+
+// AnthropicResponse is a messages API reply, or an error when Type is "error"
+type AnthropicResponse struct {
+	ID         string                  `json:"id"`
+	Type       string                  `json:"type"`
+	Model      string                  `json:"model"`
+	Content    []AnthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      AnthropicUsage          `json:"usage"`
+	Error      *APIError               `json:"error,omitempty"`
+}
+
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicStreamEvent is a single server-sent event of a streamed reply. Only
+// the fields of the event types used here are declared.
+type AnthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Message *AnthropicResponse `json:"message,omitempty"`
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *AnthropicUsage `json:"usage,omitempty"`
+	Error *APIError       `json:"error,omitempty"`
+}
+
+// anthropicProvider speaks Anthropic's messages API
+type anthropicProvider struct{}
+
+func (anthropicProvider) NewRequest(ctx context.Context, config *Config, messages []Message, stream bool) (*http.Request, error) {
+	requestBody := AnthropicRequest{
+		Model:         config.Model,
+		MaxTokens:     config.MaxTokens,
+		Temperature:   config.Temperature,
+		TopP:          config.TopP,
+		StopSequences: config.Stop,
+		Stream:        stream,
+	}
+	// max_tokens is required by the messages API
+	if requestBody.MaxTokens <= 0 {
+		requestBody.MaxTokens = defaultMaxTokens
+	}
+
+	var system []string
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		requestBody.Messages = append(requestBody.Messages, m)
+	}
+	requestBody.System = strings.Join(system, "\n\n")
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.APIURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeader(req, config)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return req, nil
+}
+
+func (anthropicProvider) ReadResponse(resp *http.Response) (*ChatResponse, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp.StatusCode, body)
+	}
+
+	var message AnthropicResponse
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if message.Error != nil {
+		return nil, newAPIError(resp.StatusCode, message.Error)
+	}
+
+	var content strings.Builder
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+
+	response := anthropicChatResponse(&message)
+	response.Choices = []Choice{{
+		Message:      Message{Role: "assistant", Content: content.String()},
+		FinishReason: anthropicFinishReason(message.StopReason),
+	}}
+	return response, nil
+}
+
+// ReadStream parses the message_start, content_block_delta and message_delta
+// events of a streamed reply until message_stop or end of input
+func (anthropicProvider) ReadStream(r io.Reader, w io.Writer) (*ChatResponse, error) {
+	response := &ChatResponse{Object: "chat.completion"}
+	choice := Choice{Message: Message{Role: "assistant"}}
+
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	// The event name is repeated in the data, so "event:" lines are skipped
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return nil, fmt.Errorf("failed to parse stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "error":
+			if event.Error != nil {
+				return nil, newAPIError(0, event.Error)
+			}
+			return nil, fmt.Errorf("stream error without details")
+		case "message_start":
+			if event.Message != nil {
+				start := anthropicChatResponse(event.Message)
+				start.Choices = nil
+				response = start
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				content.WriteString(event.Delta.Text)
+				fmt.Fprint(w, event.Delta.Text)
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				choice.FinishReason = anthropicFinishReason(event.Delta.StopReason)
+			}
+			if event.Usage != nil {
+				response.Usage.CompletionTokens = event.Usage.OutputTokens
+				response.Usage.TotalTokens = response.Usage.PromptTokens + event.Usage.OutputTokens
+			}
+		}
+
+		if event.Type == "message_stop" {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	// Terminate the streamed output with a newline
+	fmt.Fprintln(w)
+
+	choice.Message.Content = content.String()
+	response.Choices = []Choice{choice}
+
+	return response, nil
+}
+
+// anthropicChatResponse copies the fields shared with chat completions
+func anthropicChatResponse(message *AnthropicResponse) *ChatResponse {
+	return &ChatResponse{
+		ID:     message.ID,
+		Object: "chat.completion",
+		Model:  message.Model,
+		Usage: Usage{
+			PromptTokens:     message.Usage.InputTokens,
+			CompletionTokens: message.Usage.OutputTokens,
+			TotalTokens:      message.Usage.InputTokens + message.Usage.OutputTokens,
+		},
+	}
+}
+
+// anthropicFinishReason maps a stop_reason to the finish_reason values of
+// chat completions
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	default:
+		return stopReason
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAnthropicTestConfig returns a config sending to an Anthropic test server
+func newAnthropicTestConfig(serverURL, configDir string) *Config {
+	return &Config{
+		APIKey:      "sk-ant-test",
+		APIURL:      serverURL + "/v1/messages",
+		Model:       "claude-3-5-sonnet-20241022",
+		Timeout:     10 * time.Second,
+		MaxTokens:   500,
+		Temperature: 0.5,
+		Stop:        []string{"END"},
+		Provider:    providerAnthropic,
+		ConfigDir:   configDir,
+	}
+}
+
+// TestAnthropicRequest tests the translation of a prompt to a messages request
+func TestAnthropicRequest(t *testing.T) {
+	var request AnthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path = %q, want /v1/messages", r.URL.Path)
+		}
+		if got := r.Header.Get("x-api-key"); got != "sk-ant-test" {
+			t.Errorf("x-api-key header = %q, want %q", got, "sk-ant-test")
+		}
+		if got := r.Header.Get("anthropic-version"); got != anthropicVersion {
+			t.Errorf("anthropic-version header = %q, want %q", got, anthropicVersion)
+		}
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization header = %q, want none", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		_, _ = fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022",
+			"content":[{"type":"text","text":"Hello"},{"type":"text","text":", world"}],
+			"stop_reason":"max_tokens","usage":{"input_tokens":12,"output_tokens":30}}`)
+	}))
+	defer server.Close()
+
+	config := newAnthropicTestConfig(server.URL, t.TempDir())
+	response, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt"))
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if request.Model != config.Model || request.MaxTokens != 500 || request.Temperature != 0.5 {
+		t.Errorf("request = %+v, want the configured model, max_tokens and temperature", request)
+	}
+	if len(request.Messages) != 1 || request.Messages[0].Role != "user" || request.Messages[0].Content != "test prompt" {
+		t.Errorf("request messages = %+v, want the prompt as a user message", request.Messages)
+	}
+	if strings.Join(request.StopSequences, ",") != "END" || request.System != "" || request.Stream {
+		t.Errorf("request = %+v, want stop_sequences and no system prompt or streaming", request)
+	}
+
+	if got := formatResponse(response); got != "Hello, world" {
+		t.Errorf("formatResponse() = %q, want the joined text blocks", got)
+	}
+	if response.Model != config.Model || response.Choices[0].FinishReason != "length" {
+		t.Errorf("response = %+v, want the model and finish reason length", response)
+	}
+	if response.Usage != (Usage{PromptTokens: 12, CompletionTokens: 30, TotalTokens: 42}) {
+		t.Errorf("response usage = %+v, want input and output tokens mapped", response.Usage)
+	}
+}
+
+// TestAnthropicSystemMessages tests that system messages move to the system field
+func TestAnthropicSystemMessages(t *testing.T) {
+	config := newAnthropicTestConfig("https://api.anthropic.com", "")
+	config.MaxTokens = 0
+
+	messages := []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "hello"},
+		{Role: "system", Content: "Answer in French."},
+	}
+	req, err := anthropicProvider{}.NewRequest(context.Background(), config, messages, true)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+
+	var request AnthropicRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		t.Fatalf("failed to decode request: %v", err)
+	}
+	if request.System != "Be brief.\n\nAnswer in French." {
+		t.Errorf("system = %q, want both system messages", request.System)
+	}
+	if len(request.Messages) != 1 || request.Messages[0].Content != "hello" {
+		t.Errorf("messages = %+v, want only the user message", request.Messages)
+	}
+	if request.MaxTokens != defaultMaxTokens || !request.Stream {
+		t.Errorf("request = %+v, want the default max_tokens and streaming", request)
+	}
+}
+
+// TestAnthropicErrors tests that error replies keep their exit status
+func TestAnthropicErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		kind   error
+	}{
+		{"invalid key", http.StatusUnauthorized, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, ErrAuth},
+		{"overloaded", 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, ErrServer},
+		{"error with OK status", http.StatusOK, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, ErrRateLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			config := newAnthropicTestConfig(server.URL, "")
+			_, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt"))
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("Chat() error = %v, want %v", err, tt.kind)
+			}
+		})
+	}
+}
+
+// TestAnthropicStream tests parsing of a streamed messages reply
+func TestAnthropicStream(t *testing.T) {
+	events := []string{
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"type\":\"message\",\"model\":\"claude-3-5-haiku-20241022\",\"content\":[],\"usage\":{\"input_tokens\":8,\"output_tokens\":1}}}",
+		"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}",
+		"event: ping\ndata: {\"type\":\"ping\"}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}",
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\", world\"}}",
+		"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":5}}",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request AnthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Stream {
+			t.Errorf("request stream = %t, %v, want a streaming request", request.Stream, err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	config := newAnthropicTestConfig(server.URL, "")
+	response, err := newAPIClient(config).ChatStream(context.Background(), promptMessages(config, "test prompt"), &out)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	if out.String() != "Hello, world\n" {
+		t.Errorf("streamed output = %q, want %q", out.String(), "Hello, world\n")
+	}
+	if formatResponse(response) != "Hello, world" || response.Choices[0].FinishReason != "stop" {
+		t.Errorf("response = %+v, want the assembled text and finish reason stop", response)
+	}
+	if response.Model != "claude-3-5-haiku-20241022" || response.Usage != (Usage{PromptTokens: 8, CompletionTokens: 5, TotalTokens: 13}) {
+		t.Errorf("response model %q and usage %+v, want them from message_start and message_delta", response.Model, response.Usage)
+	}
+
+	// An error event ends the stream with an error
+	errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	}))
+	defer errorServer.Close()
+
+	config = newAnthropicTestConfig(errorServer.URL, "")
+	if _, err := newAPIClient(config).ChatStream(context.Background(), promptMessages(config, "test prompt"), &out); !errors.Is(err, ErrServer) {
+		t.Errorf("ChatStream() error = %v, want %v", err, ErrServer)
+	}
+}
+
+// TestPromptCommandAnthropic tests that prompting and logging work unchanged
+// with the anthropic provider
+func TestPromptCommandAnthropic(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"id":"msg_1","type":"message","model":"claude-3-5-sonnet-20241022",
+			"content":[{"type":"text","text":"Bonjour"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":20}}`)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	setTestEnv(envProvider, providerAnthropic)
+	setTestEnv(envAPIURL, server.URL+"/v1/messages")
+	setTestEnv(envAnthropicAPIKey, "sk-ant-test")
+	setTestEnv(envAPIKey, "sk-openai-unused")
+
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.APIKey != "sk-ant-test" || config.Model != defaultAnthropicModel {
+		t.Errorf("loadConfig() APIKey %q, model %q, want the Anthropic key and default model", config.APIKey, config.Model)
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "--usage", "Say hello in French"}); err != nil {
+			t.Errorf("promptCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Bonjour") {
+		t.Errorf("prompt output = %q, want the reply", out)
+	}
+
+	entries, _, err := readLogEntries([]string{filepath.Join(tmpDir, logFileName)}, logFilter{}, 0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("readLogEntries() = %v, %v, want one entry", entries, err)
+	}
+	entry := entries[0]
+	if entry.Response != "Bonjour" || entry.Model != "claude-3-5-sonnet-20241022" || entry.Usage == nil || entry.Usage.TotalTokens != 30 {
+		t.Errorf("logged entry = %+v, want the reply, model and usage", entry)
+	}
+}
+
+// TestLoadConfigAnthropicDefaults tests the endpoint and model defaults
+func TestLoadConfigAnthropicDefaults(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	setTestEnv(envConfigDir, t.TempDir())
+	setTestEnv(envProvider, providerAnthropic)
+
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.APIURL != defaultAnthropicAPIURL || config.Model != defaultAnthropicModel {
+		t.Errorf("loadConfig() APIURL %q, model %q, want the Anthropic defaults", config.APIURL, config.Model)
+	}
+	if err := requireAPIKey(config); err == nil || !strings.Contains(err.Error(), envAnthropicAPIKey) {
+		t.Errorf("requireAPIKey() error = %v, want it to name %s", err, envAnthropicAPIKey)
+	}
+	if getModelsURL(config) != "https://api.anthropic.com/v1/models" {
+		t.Errorf("getModelsURL() = %q, want the Anthropic models endpoint", getModelsURL(config))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// APIClient sends requests to the configured provider. It is built once
+// per command and keeps one HTTP client for its whole life, so the requests
+// of a batch, a workflow, a refine or a round of tool calls reuse their
+// connections instead of each paying for a new TLS handshake. Retries, the
+// OPENAI_REQUESTS_PER_MINUTE budget and the debug output of -v all hook in
+// here.
+type APIClient struct {
+	config *Config
+	http   *http.Client
+}
+
+// newAPIClient returns a client sending requests with config, which is read
+// at each request so later changes such as a system prompt apply
+func newAPIClient(config *Config) *APIClient {
+	return &APIClient{config: config, http: newHTTPClient(config)}
+}
+
+// withConfig returns a client sending requests with config over the same
+// connections, for requests with their own model or sampling values. The
+// timeout may be set per model, so it is taken from config.
+func (c *APIClient) withConfig(config *Config) *APIClient {
+	client := *c.http
+	client.Timeout = config.Timeout
+	return &APIClient{config: config, http: &client}
+}
+
+// Chat sends a whole conversation and reads the reply
+func (c *APIClient) Chat(ctx context.Context, messages []Message) (response *ChatResponse, err error) {
+	ctx, span := c.startRequestSpan(ctx)
+	defer func() { endRequestSpan(span, response, err) }()
+
+	resp, err := c.send(ctx, messages, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	response, err = chatProviderFor(c.config).ReadResponse(resp)
+	return withRequestID(resp, response, err)
+}
+
+// startRequestSpan starts the trace span of a chat request, named as
+// OpenTelemetry names the spans of generative AI clients
+func (c *APIClient) startRequestSpan(ctx context.Context) (context.Context, *traceSpan) {
+	ctx, span := startSpan(ctx, "chat "+c.config.Model, spanKindClient)
+	span.SetString("gen_ai.operation.name", "chat")
+	span.SetString("gen_ai.system", c.config.Provider)
+	span.SetString("gen_ai.request.model", c.config.Model)
+	return ctx, span
+}
+
+// endRequestSpan ends the trace span of a chat request with what the
+// response tells about it
+func endRequestSpan(span *traceSpan, response *ChatResponse, err error) {
+	if response != nil {
+		span.SetString("gen_ai.response.model", response.Model)
+		span.SetString("gen_ai.response.id", response.ID)
+		span.SetUsage(response.Usage)
+	}
+	span.End(err)
+}
+
+// send builds the chat completion request and sends it, returning the raw
+// HTTP response. The caller is responsible for closing its body.
+func (c *APIClient) send(ctx context.Context, messages []Message, stream bool) (*http.Response, error) {
+	return c.doLimited(ctx, func() (*http.Request, error) {
+		return newProviderRequest(ctx, c.config, messages, stream)
+	})
+}
+
+// do sends the request made by build, rebuilding it if it has to be retried.
+// The caller is responsible for closing the body of the response.
+func (c *APIClient) do(ctx context.Context, build func() (*http.Request, error)) (*http.Response, error) {
+	resp, err := sendRequest(ctx, c.http, build)
+	if err != nil {
+		return nil, ollamaNotRunning(c.config, err)
+	}
+	return resp, nil
+}
+
+// doLimited is do for the requests that count against the
+// OPENAI_REQUESTS_PER_MINUTE budget, taking one from it first
+func (c *APIClient) doLimited(ctx context.Context, build func() (*http.Request, error)) (*http.Response, error) {
+	if err := waitForRateLimit(ctx, c.config); err != nil {
+		return nil, err
+	}
+	return c.do(ctx, build)
+}
+
+// decodeResponse reads the JSON reply of an endpoint other than chat into v.
+// A structured API error in the body is preferred to the bare status code,
+// which is checked before the body is parsed.
+func decodeResponse(resp *http.Response, v any) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var failure struct {
+		Error *APIError `json:"error"`
+	}
+	if json.Unmarshal(body, &failure) == nil && failure.Error != nil {
+		return newAPIError(resp.StatusCode, failure.Error)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
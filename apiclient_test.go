@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newConnCountingServer returns a started server answering chat requests,
+// streaming when asked to, and a function returning how many connections
+// were opened to it
+func newConnCountingServer(t *testing.T) (*httptest.Server, func() int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	conns := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body bytes.Buffer
+		body.ReadFrom(r.Body)
+		if strings.Contains(body.String(), `"stream":true`) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return server, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return conns
+	}
+}
+
+// TestAPIClientReusesConnections tests that the requests of one client,
+// streamed or not, go over a single connection
+func TestAPIClientReusesConnections(t *testing.T) {
+	server, conns := newConnCountingServer(t)
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConnectTimeout: 7 * time.Second}
+
+	client := newAPIClient(config)
+	for i := 0; i < 3; i++ {
+		if _, err := client.Chat(context.Background(), promptMessages(config, "hi")); err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+	}
+	var out bytes.Buffer
+	if _, err := client.ChatStream(context.Background(), promptMessages(config, "hi"), &out); err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if _, err := client.Chat(context.Background(), promptMessages(config, "hi")); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	// A client for a step with its own model and timeout keeps the connections
+	step := *config
+	step.Model, step.Timeout = "gpt-4o-mini", 5*time.Second
+	stepClient := client.withConfig(&step)
+	if _, err := stepClient.Chat(context.Background(), promptMessages(&step, "hi")); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if stepClient.http.Timeout != 5*time.Second || client.http.Timeout != 10*time.Second {
+		t.Errorf("timeouts = %s, %s; want the step's own", stepClient.http.Timeout, client.http.Timeout)
+	}
+
+	if n := conns(); n != 1 {
+		t.Errorf("%d connections opened for 6 requests, want 1", n)
+	}
+}
+
+// TestRunBatchReusesConnections tests that the workers of a batch keep
+// their connections from one prompt to the next
+func TestRunBatchReusesConnections(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server, conns := newConnCountingServer(t)
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConnectTimeout: 8 * time.Second, ConfigDir: t.TempDir()}
+
+	prompts := []string{"one", "two", "three", "four", "five", "six"}
+	var out bytes.Buffer
+	summary, err := runBatch(config, prompts, batchOptions{concurrency: 2}, &out)
+	if err != nil || summary.failed != 0 {
+		t.Fatalf("runBatch() = %+v, %v", summary, err)
+	}
+	if n := conns(); n > 2 {
+		t.Errorf("%d connections opened by 2 workers, want at most 2", n)
+	}
+}
+
+// TestDecodeResponse tests that a structured API error is preferred to the
+// status code, which is checked before the body is parsed
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "ok", status: http.StatusOK, body: `{"object":"list","data":[{"id":"gpt-4o"}]}`},
+		{name: "structured error", status: http.StatusUnauthorized, body: `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error"}}`, wantErr: "Incorrect API key provided"},
+		{name: "structured error with status ok", status: http.StatusOK, body: `{"error":{"message":"model overloaded"}}`, wantErr: "model overloaded"},
+		{name: "status without error", status: http.StatusBadGateway, body: `<html>Bad Gateway</html>`, wantErr: "502"},
+		{name: "unparsable", status: http.StatusOK, body: `{"data":`, wantErr: "failed to parse response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(tt.body))}
+			var models ModelsResponse
+			err := decodeResponse(resp, &models)
+			if tt.wantErr == "" {
+				if err != nil || len(models.Data) != 1 || models.Data[0].ID != "gpt-4o" {
+					t.Errorf("decodeResponse() = %v, %+v", err, models)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("decodeResponse() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Page where OpenAI credits are bought and usage limits raised
+const openAIBillingURL = "https://platform.openai.com/account/billing"
+
+// Hints shared by several codes, types or statuses
+const (
+	quotaHint     = "the account has no credits left; add credits or raise its usage limit at " + openAIBillingURL
+	rateLimitHint = "too many requests; wait a moment and retry, or set " + envRequestsPerMinute + " to stay under the limit"
+	apiKeyHint    = "the API key was rejected; check which key is used with: chatgpt-cli auth status"
+)
+
+// APIFailure is an error response of the API, with a hint on fixing it when
+// its code, type or status is a known one
+type APIFailure struct {
+	StatusCode int
+	// The error object of the response, nil if it had none
+	API *APIError
+	// What the API said, as received
+	Message string
+	// How to fix it, or "" for failures without advice
+	Hint string
+	// The ID the provider gave the request, or "" if it sent none
+	RequestID string
+}
+
+// Error returns the message, with the hint and the request ID on lines of
+// their own
+func (e *APIFailure) Error() string {
+	message := e.Message
+	if e.Hint != "" {
+		message += "\nHint: " + e.Hint
+	}
+	if e.RequestID != "" {
+		message += "\nrequest id: " + e.RequestID + " — include this when contacting support"
+	}
+	return message
+}
+
+// classifyAPIError returns the error for a failed API response: its error
+// object if it has one, otherwise its raw body. The error is an *APIFailure,
+// tagged with the kind of failure for the exit status.
+func classifyAPIError(statusCode int, apiErr *APIError, body []byte) error {
+	failure := &APIFailure{StatusCode: statusCode, API: apiErr, Hint: apiErrorHint(statusCode, apiErr)}
+	if body == nil && apiErr != nil {
+		failure.Message = fmt.Sprintf("API error: %s (type: %s)", apiErr.Message, apiErr.Type)
+	} else {
+		failure.Message = fmt.Sprintf("unexpected status code: %d, response: %s", statusCode, string(body))
+	}
+
+	if kind := apiErrorKind(statusCode, apiErr); kind != nil {
+		return withKind(kind, failure)
+	}
+	return failure
+}
+
+// apiErrorHint returns advice on fixing an API error, looked up by its code,
+// then its type, then the status code, or "" if there is none
+func apiErrorHint(statusCode int, apiErr *APIError) string {
+	if apiErr != nil {
+		switch apiErr.Code {
+		case "invalid_api_key":
+			return apiKeyHint + ", or set another with: chatgpt-cli config set " + envAPIKey + " <key>"
+		case "insufficient_quota":
+			return quotaHint
+		case "context_length_exceeded":
+			return "the prompt and " + envMaxTokens + " don't fit in the model's context; lower " + envMaxTokens + " or --max-tokens, shorten the prompt and attached files, or condense long input first with: chatgpt-cli summarize"
+		case "model_not_found":
+			return "the model doesn't exist or the API key can't use it; list the available models with: chatgpt-cli models, then choose one with: chatgpt-cli config set " + envModel + " <model>"
+		case "mismatched_organization":
+			return fmt.Sprintf("check %s and %s; the API key must belong to that organization and project (chatgpt-cli config list)", envOrgID, envProjectID)
+		case "rate_limit_exceeded":
+			return rateLimitHint
+		case "content_policy_violation":
+			return "the prompt was rejected by the content policy; rephrase it without the content the policy forbids"
+		}
+
+		switch apiErr.Type {
+		case "insufficient_quota":
+			return quotaHint
+		case "authentication_error":
+			return apiKeyHint + ", or set another with: chatgpt-cli config set " + envAnthropicAPIKey + " <key>"
+		case "not_found_error":
+			return "the model doesn't exist or the API key can't use it; list the available models with: chatgpt-cli models"
+		case "rate_limit_error":
+			return rateLimitHint
+		case "overloaded_error":
+			return "the service is overloaded; retry in a moment"
+		}
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return apiKeyHint
+	case statusCode == http.StatusNotFound:
+		return "nothing was found at the API URL, or the model doesn't exist; check " + envAPIURL + " and " + envModel + " (chatgpt-cli config list)"
+	case statusCode == http.StatusTooManyRequests:
+		return rateLimitHint
+	case statusCode >= 500:
+		return "the service failed to handle the request; retry later"
+	}
+	return ""
+}
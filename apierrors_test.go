@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestClassifyAPIError tests the hint of every known code, type and status,
+// and that other failures keep the raw message alone
+func TestClassifyAPIError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		apiErr  *APIError
+		body    []byte
+		message string
+		hint    string
+		kind    error
+	}{
+		{
+			name:    "invalid API key",
+			status:  http.StatusUnauthorized,
+			apiErr:  &APIError{Message: "Incorrect API key provided", Type: "invalid_request_error", Code: "invalid_api_key"},
+			message: "API error: Incorrect API key provided (type: invalid_request_error)",
+			hint:    "chatgpt-cli config set OPENAI_API_KEY <key>",
+			kind:    ErrAuth,
+		},
+		{
+			name:    "insufficient quota",
+			status:  http.StatusTooManyRequests,
+			apiErr:  &APIError{Message: "You exceeded your current quota", Type: "insufficient_quota", Code: "insufficient_quota"},
+			message: "API error: You exceeded your current quota (type: insufficient_quota)",
+			hint:    openAIBillingURL,
+			kind:    ErrRateLimit,
+		},
+		{
+			name:    "insufficient quota type only",
+			status:  http.StatusTooManyRequests,
+			apiErr:  &APIError{Message: "quota", Type: "insufficient_quota"},
+			message: "API error: quota (type: insufficient_quota)",
+			hint:    openAIBillingURL,
+			kind:    ErrRateLimit,
+		},
+		{
+			name:    "context length exceeded",
+			status:  http.StatusBadRequest,
+			apiErr:  &APIError{Message: "This model's maximum context length is 4097 tokens", Type: "invalid_request_error", Code: "context_length_exceeded"},
+			message: "API error: This model's maximum context length is 4097 tokens (type: invalid_request_error)",
+			hint:    "lower OPENAI_MAX_TOKENS or --max-tokens",
+		},
+		{
+			name:    "model not found",
+			status:  http.StatusNotFound,
+			apiErr:  &APIError{Message: "The model `gpt-5o` does not exist", Type: "invalid_request_error", Code: "model_not_found"},
+			message: "API error: The model `gpt-5o` does not exist (type: invalid_request_error)",
+			hint:    "chatgpt-cli models",
+		},
+		{
+			name:    "mismatched organization",
+			status:  http.StatusUnauthorized,
+			apiErr:  &APIError{Message: "OpenAI-Organization header should match", Type: "invalid_request_error", Code: "mismatched_organization"},
+			message: "API error: OpenAI-Organization header should match (type: invalid_request_error)",
+			hint:    "check OPENAI_ORG_ID and OPENAI_PROJECT_ID",
+			kind:    ErrAuth,
+		},
+		{
+			name:    "rate limit exceeded",
+			status:  http.StatusTooManyRequests,
+			apiErr:  &APIError{Message: "Rate limit reached", Type: "requests", Code: "rate_limit_exceeded"},
+			message: "API error: Rate limit reached (type: requests)",
+			hint:    "OPENAI_REQUESTS_PER_MINUTE",
+			kind:    ErrRateLimit,
+		},
+		{
+			name:    "anthropic authentication",
+			status:  http.StatusUnauthorized,
+			apiErr:  &APIError{Message: "invalid x-api-key", Type: "authentication_error"},
+			message: "API error: invalid x-api-key (type: authentication_error)",
+			hint:    "chatgpt-cli config set ANTHROPIC_API_KEY <key>",
+			kind:    ErrAuth,
+		},
+		{
+			name:    "anthropic model not found",
+			status:  http.StatusNotFound,
+			apiErr:  &APIError{Message: "model: claude-9", Type: "not_found_error"},
+			message: "API error: model: claude-9 (type: not_found_error)",
+			hint:    "chatgpt-cli models",
+		},
+		{
+			name:    "rate limit type in a stream",
+			apiErr:  &APIError{Message: "Rate limit reached", Type: "rate_limit_error"},
+			message: "API error: Rate limit reached (type: rate_limit_error)",
+			hint:    "wait a moment and retry",
+			kind:    ErrRateLimit,
+		},
+		{
+			name:    "overloaded",
+			status:  529,
+			apiErr:  &APIError{Message: "Overloaded", Type: "overloaded_error"},
+			message: "API error: Overloaded (type: overloaded_error)",
+			hint:    "the service is overloaded",
+			kind:    ErrServer,
+		},
+		{
+			name:    "unauthorized without error object",
+			status:  http.StatusUnauthorized,
+			body:    []byte("Unauthorized"),
+			message: "unexpected status code: 401, response: Unauthorized",
+			hint:    "chatgpt-cli auth status",
+			kind:    ErrAuth,
+		},
+		{
+			name:    "not found without error object",
+			status:  http.StatusNotFound,
+			body:    []byte("404 page not found"),
+			message: "unexpected status code: 404, response: 404 page not found",
+			hint:    "check OPENAI_API_URL and OPENAI_MODEL",
+		},
+		{
+			name:    "too many requests without error object",
+			status:  http.StatusTooManyRequests,
+			body:    []byte{},
+			message: "unexpected status code: 429, response: ",
+			hint:    "OPENAI_REQUESTS_PER_MINUTE",
+			kind:    ErrRateLimit,
+		},
+		{
+			name:    "server error without error object",
+			status:  http.StatusBadGateway,
+			body:    []byte("bad gateway"),
+			message: "unexpected status code: 502, response: bad gateway",
+			hint:    "retry later",
+			kind:    ErrServer,
+		},
+		{
+			name:    "unknown code",
+			status:  http.StatusBadRequest,
+			apiErr:  &APIError{Message: "Something new went wrong", Type: "invalid_request_error", Code: "something_new"},
+			message: "API error: Something new went wrong (type: invalid_request_error)",
+		},
+		{
+			name:    "unknown status with body",
+			status:  http.StatusBadRequest,
+			apiErr:  &APIError{Message: "bad", Type: "invalid_request_error"},
+			body:    []byte(`{"error":{"message":"bad","type":"invalid_request_error"}}`),
+			message: `unexpected status code: 400, response: {"error":{"message":"bad","type":"invalid_request_error"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyAPIError(tt.status, tt.apiErr, tt.body)
+
+			var failure *APIFailure
+			if !errors.As(err, &failure) {
+				t.Fatalf("classifyAPIError() = %T, want an *APIFailure", err)
+			}
+			if failure.Message != tt.message {
+				t.Errorf("Message = %q, want %q", failure.Message, tt.message)
+			}
+			if (tt.hint == "") != (failure.Hint == "") || !strings.Contains(failure.Hint, tt.hint) {
+				t.Errorf("Hint = %q, want it to contain %q", failure.Hint, tt.hint)
+			}
+
+			// The hint is printed on a line of its own
+			want := tt.message
+			if tt.hint != "" {
+				want += "\nHint: " + failure.Hint
+			}
+			if err.Error() != want {
+				t.Errorf("Error() = %q, want %q", err.Error(), want)
+			}
+
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Errorf("error is not %v", tt.kind)
+			}
+			if tt.kind == nil && exitCode(err) != exitFailure {
+				t.Errorf("exit code = %d, want %d", exitCode(err), exitFailure)
+			}
+		})
+	}
+}
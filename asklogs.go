@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default number of tokens of log entries sent by ask-logs
+const defaultAskLogsBudget = 4000
+
+// Characters of a prompt, response or error kept for each entry sent by
+// ask-logs, so that one long exchange doesn't crowd out the others
+const askLogsTextChars = 300
+
+// askLogsInstructions is the system prompt of ask-logs, followed by the
+// selected log entries
+const askLogsInstructions = "You help the user understand how they use chatgpt-cli, a command-line client for chat APIs, from its log. " +
+	"Each entry below is one request: when it was made, the command, the model, how long it took and the tokens used, then the prompt and the response or the error, cut to their start. " +
+	"Entries are oldest first. Some may have been left out to fit, keeping errors and recent entries first. " +
+	"Answer the user's question from these entries, citing times, models and errors where useful, and say so when they don't hold the answer. " +
+	"Secrets in the entries were replaced with [REDACTED:<type>]."
+
+// askLogsCommand asks the model a question about the log, sending the
+// entries that fit the token budget as context. The entries are redacted
+// before they are sent, and only the question is logged.
+func askLogsCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli ask-logs [--since 7d] [--budget N] [--raw] [--dry-run] \"your question\""
+
+	fs := flag.NewFlagSet("ask-logs", flag.ContinueOnError)
+	var since durationFlag
+	fs.Var(&since, "since", "only send entries newer than this duration (e.g. 24h or 7d)")
+	budget := fs.Int("budget", defaultAskLogsBudget, "estimated tokens of log entries to send at most")
+	raw := fs.Bool("raw", false, "print the response as-is instead of rendering markdown")
+	dryRun := fs.Bool("dry-run", false, "print the request instead of sending it")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if since < 0 {
+		return usageErrorf("--since must be a positive duration")
+	}
+	if *budget < 1 {
+		return usageErrorf("--budget must be a positive integer")
+	}
+	question := strings.TrimSpace(strings.Join(args, " "))
+	if question == "" {
+		return usageErrorf("question is required\n%s", usage)
+	}
+
+	var filter logFilter
+	if since > 0 {
+		filter.Since = time.Now().Add(-time.Duration(since))
+	}
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	entries, _, err := readLogEntries(logFiles, filter, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	// Earlier questions about the logs say nothing about them
+	candidates := entries[:0]
+	for _, entry := range entries {
+		if entry.Command != "ask-logs" {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) == 0 {
+		fmt.Println("No logs found.")
+		return nil
+	}
+
+	// Redaction always applies here, since old entries may have been
+	// logged with it off
+	r, err := loadRedactor(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	for i := range candidates {
+		r.redactEntry(&candidates[i])
+	}
+
+	selected := selectLogEntries(candidates, *budget)
+	if len(selected) == 0 {
+		return usageErrorf("no log entry fits in --budget %d; raise it", *budget)
+	}
+	config.SystemPrompt = askLogsInstructions + " The time now is " + time.Now().Format("2006-01-02 15:04:05") + ".\n\nLog entries:\n\n" + formatLogContext(selected)
+
+	if *dryRun {
+		req, err := newChatRequest(config.requestContext(), config, question, false)
+		if err != nil {
+			return err
+		}
+		return printDryRun(config, req)
+	}
+
+	// Checked before the notice, so a missing key fails without it
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+	errOut := newUI(config, os.Stderr)
+	errOut.Println(errOut.dim(fmt.Sprintf("Asking about %d of %d log entries", len(selected), len(candidates))))
+
+	return sendAndPrint(config, chatPrompt{Command: "ask-logs", Text: question, Raw: *raw})
+}
+
+// selectLogEntries picks the entries sent by ask-logs: those with an error
+// first, then the others, newest first in both, as long as they fit in
+// budget estimated tokens once formatted. An entry too large to fit is
+// skipped for smaller ones. The selected entries are returned in the order
+// of the log, oldest first.
+func selectLogEntries(entries []LogEntry, budget int) []LogEntry {
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ea, eb := entries[order[a]], entries[order[b]]
+		if (ea.Error != "") != (eb.Error != "") {
+			return ea.Error != ""
+		}
+		return ea.Timestamp.After(eb.Timestamp)
+	})
+
+	var picked []int
+	for _, i := range order {
+		tokens := estimateTokens(formatLogContextEntry(entries[i]))
+		if tokens > budget {
+			continue
+		}
+		budget -= tokens
+		picked = append(picked, i)
+	}
+
+	sort.Ints(picked)
+	selected := make([]LogEntry, len(picked))
+	for n, i := range picked {
+		selected[n] = entries[i]
+	}
+	return selected
+}
+
+// formatLogContext formats the entries sent by ask-logs, one after the other
+func formatLogContext(entries []LogEntry) string {
+	parts := make([]string, len(entries))
+	for i, entry := range entries {
+		parts[i] = formatLogContextEntry(entry)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// formatLogContextEntry formats one entry sent by ask-logs: a header line,
+// then the start of its prompt, error and response on a line each
+func formatLogContextEntry(entry LogEntry) string {
+	var details []string
+	if entry.Model != "" {
+		details = append(details, entry.Model)
+	}
+	if entry.LatencyMs > 0 {
+		details = append(details, formatLatency(entry.LatencyMs))
+	}
+	if entry.Usage != nil && entry.Usage.TotalTokens > 0 {
+		details = append(details, fmt.Sprintf("%d tokens", entry.Usage.TotalTokens))
+	}
+
+	var b strings.Builder
+	b.WriteString(entry.Timestamp.Local().Format("2006-01-02 15:04:05") + " " + entry.Command)
+	if len(details) > 0 {
+		b.WriteString(" (" + strings.Join(details, ", ") + ")")
+	}
+	b.WriteString("\n")
+	for _, field := range []struct{ name, text string }{
+		{"Prompt", entry.Prompt},
+		{"Error", entry.Error},
+		{"Response", entry.Response},
+	} {
+		if field.text != "" {
+			fmt.Fprintf(&b, "  %s: %s\n", field.name, truncate(oneLine(strings.TrimSpace(field.text)), askLogsTextChars))
+		}
+	}
+	return b.String()
+}
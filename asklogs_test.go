@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSelectLogEntries tests that errors and recent entries are kept first
+// within the budget, and returned in the order of the log
+func TestSelectLogEntries(t *testing.T) {
+	now := time.Now()
+	entries := []LogEntry{
+		{Timestamp: now.Add(-5 * time.Hour), Command: "prompt", Prompt: "oldest", Response: "ok"},
+		{Timestamp: now.Add(-4 * time.Hour), Command: "prompt", Prompt: "old failure", Error: "timeout"},
+		{Timestamp: now.Add(-3 * time.Hour), Command: "prompt", Prompt: "older", Response: "ok"},
+		{Timestamp: now.Add(-2 * time.Hour), Command: "prompt", Prompt: strings.Repeat("long ", 200), Response: strings.Repeat("reply ", 200)},
+		{Timestamp: now.Add(-time.Hour), Command: "prompt", Prompt: "newest", Response: "ok"},
+	}
+	size := func(i int) int { return estimateTokens(formatLogContextEntry(entries[i])) }
+
+	prompts := func(selected []LogEntry) string {
+		var names []string
+		for _, entry := range selected {
+			names = append(names, strings.Fields(entry.Prompt)[0])
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		name   string
+		budget int
+		want   string
+	}{
+		{"everything", 100000, "oldest,old,older,long,newest"},
+		{"error first", size(1), "old"},
+		{"then newest", size(1) + size(4), "old,newest"},
+		// The long entry doesn't fit, so the older ones after it are taken
+		{"skips what doesn't fit", size(1) + size(4) + size(2) + size(0), "oldest,old,older,newest"},
+		{"nothing fits", 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prompts(selectLogEntries(entries, tt.budget)); got != tt.want {
+				t.Errorf("selectLogEntries(budget %d) = %q, want %q", tt.budget, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFormatLogContextEntry tests the text an entry is sent as
+func TestFormatLogContextEntry(t *testing.T) {
+	entry := LogEntry{
+		Timestamp: time.Date(2026, 3, 2, 10, 4, 5, 0, time.Local),
+		Command:   "prompt",
+		Model:     "gpt-4o",
+		LatencyMs: 1500,
+		Usage:     &Usage{TotalTokens: 42},
+		Prompt:    "line one\nline two",
+		Error:     "unexpected status code: 429",
+	}
+	want := "2026-03-02 10:04:05 prompt (gpt-4o, 1.5s, 42 tokens)\n  Prompt: line one⏎line two\n  Error: unexpected status code: 429\n"
+	if got := formatLogContextEntry(entry); got != want {
+		t.Errorf("formatLogContextEntry() = %q, want %q", got, want)
+	}
+
+	entry = LogEntry{Timestamp: entry.Timestamp, Command: "batch", Response: strings.Repeat("x", askLogsTextChars+50)}
+	if got := formatLogContextEntry(entry); !strings.HasPrefix(got, "2026-03-02 10:04:05 batch\n  Response: xxx") || !strings.HasSuffix(got, "...\n") {
+		t.Errorf("formatLogContextEntry() = %q, want the response cut", got)
+	}
+}
+
+// TestAskLogsCommand tests that the selected entries are sent redacted in
+// the system prompt, and that only the question is logged
+func TestAskLogsCommand(t *testing.T) {
+	var sent ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"The API rate-limited you."}}]}`)
+	}))
+	defer server.Close()
+
+	configDir := t.TempDir()
+	now := time.Now()
+	writeTestLogs(t, filepath.Join(configDir, "logs.jsonl"), []LogEntry{
+		{Timestamp: now.Add(-72 * time.Hour), Command: "prompt", Prompt: "too old", Response: "ok"},
+		{Timestamp: now.Add(-time.Hour), Command: "prompt", Prompt: "use key sk-abcdefghijklmnopqrstuvwxyz123456", Error: "unexpected status code: 429"},
+		{Timestamp: now.Add(-30 * time.Minute), Command: "ask-logs", Prompt: "earlier question", Response: "earlier answer"},
+		{Timestamp: now.Add(-10 * time.Minute), Command: "summarize", Prompt: "notes", Response: "summary"},
+	})
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: configDir, NoColor: true}
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		captureOutput(t, &os.Stderr, func() {
+			err = askLogsCommand(config, []string{"--since", "1d", "why", "did", "it", "fail?"})
+		})
+	})
+	if err != nil {
+		t.Fatalf("askLogsCommand() error = %v", err)
+	}
+	if out != "The API rate-limited you.\n" {
+		t.Errorf("output = %q", out)
+	}
+
+	if len(sent.Messages) != 2 || sent.Messages[0].Role != "system" || sent.Messages[1].Content != "why did it fail?" {
+		t.Fatalf("messages = %+v", sent.Messages)
+	}
+	context := sent.Messages[0].Content
+	for _, want := range []string{askLogsInstructions, "Prompt: use key [REDACTED:api_key]", "Error: unexpected status code: 429", "Response: summary"} {
+		if !strings.Contains(context, want) {
+			t.Errorf("context = %q, want %q", context, want)
+		}
+	}
+	for _, unwanted := range []string{"sk-abcdefghijklmnopqrstuvwxyz123456", "too old", "earlier question"} {
+		if strings.Contains(context, unwanted) {
+			t.Errorf("context = %q, want no %q", context, unwanted)
+		}
+	}
+
+	entries := readTestLogEntries(t, configDir)
+	last := entries[len(entries)-1]
+	if last.Command != "ask-logs" || last.Prompt != "why did it fail?" || last.Response != "The API rate-limited you." {
+		t.Errorf("log entry = %+v, want the question only", last)
+	}
+}
+
+// TestAskLogsCommandUsage tests the arguments rejected before the logs are read
+func TestAskLogsCommandUsage(t *testing.T) {
+	config := &Config{ConfigDir: t.TempDir()}
+	for _, args := range [][]string{
+		nil,
+		{"--budget", "0", "why?"},
+		{"--since", "-1h", "why?"},
+	} {
+		if err := askLogsCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("askLogsCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := askLogsCommand(config, []string{"why?"}); err != nil {
+			t.Errorf("askLogsCommand() without logs error = %v", err)
+		}
+	})
+	if out != "No logs found.\n" {
+		t.Errorf("output = %q", out)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// attachment is a file whose contents are appended to a prompt
+type attachment struct {
+	Name    string
+	Content string
+}
+
+// fileListFlag is a repeatable flag collecting file paths
+type fileListFlag []string
+
+func (f *fileListFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *fileListFlag) Set(value string) error {
+	if value == "" {
+		return fmt.Errorf("file path cannot be empty")
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// readAttachments reads every file, where "-" means stdin. Files larger than
+// maxSize bytes or that look binary are rejected; a maxSize of zero means no
+// limit.
+func readAttachments(paths []string, maxSize int64) ([]attachment, error) {
+	var attachments []attachment
+	readStdin := false
+
+	for _, path := range paths {
+		if path == "-" {
+			if readStdin {
+				return nil, fmt.Errorf("stdin can only be attached once")
+			}
+			readStdin = true
+		}
+
+		a, err := readAttachment(path, maxSize)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, nil
+}
+
+// readAttachment reads a single file, or stdin for "-"
+func readAttachment(path string, maxSize int64) (attachment, error) {
+	name := path
+	var r io.Reader
+
+	if path == "-" {
+		name = "stdin"
+		r = stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return attachment{}, fmt.Errorf("failed to open file: %w", err)
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return attachment{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		if info.IsDir() {
+			return attachment{}, fmt.Errorf("%s is a directory", path)
+		}
+		if maxSize > 0 && info.Size() > maxSize {
+			return attachment{}, fmt.Errorf("%s is %s, larger than the %s limit (%s)",
+				path, formatSize(info.Size()), formatSize(maxSize), envMaxFileSize)
+		}
+		r = f
+	}
+
+	// Read one byte past the limit to detect oversized input without a size
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return attachment{}, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return attachment{}, fmt.Errorf("%s is larger than the %s limit (%s)", name, formatSize(maxSize), envMaxFileSize)
+	}
+
+	// Same heuristic as git: text files don't contain NUL bytes
+	if bytes.IndexByte(data, 0) >= 0 {
+		return attachment{}, fmt.Errorf("%s appears to be a binary file", name)
+	}
+
+	return attachment{Name: name, Content: string(data)}, nil
+}
+
+// buildPrompt appends each attachment to the prompt text as a fenced code
+// block annotated with its filename
+func buildPrompt(text string, attachments []attachment) string {
+	var b strings.Builder
+	b.WriteString(text)
+
+	for _, a := range attachments {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+
+		fence := codeFence(a.Content)
+		content := strings.TrimSuffix(a.Content, "\n")
+		fmt.Fprintf(&b, "%s %s\n%s\n%s", fence, a.Name, content, fence)
+	}
+
+	return b.String()
+}
+
+// wrapPrompt puts the prefix before a prompt and the suffix after it, each
+// separated by a blank line. Empty parts are left out.
+func wrapPrompt(prefix, prompt, suffix string) string {
+	var parts []string
+	for _, part := range []string{prefix, prompt, suffix} {
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// codeFence returns a markdown code fence for content. The fence must be longer
+// than any backtick run in the content.
+func codeFence(content string) string {
+	fence := strings.Repeat("`", maxBacktickRun(content)+1)
+	if len(fence) < 3 {
+		fence = "```"
+	}
+	return fence
+}
+
+// maxBacktickRun returns the length of the longest run of backticks in s
+func maxBacktickRun(s string) int {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+// promptForLog returns the prompt as recorded in the log: the full prompt if
+// full is true, otherwise the text followed by the attached filenames
+func promptForLog(text string, attachments []attachment, full bool) string {
+	if full || len(attachments) == 0 {
+		return buildPrompt(text, attachments)
+	}
+
+	names := make([]string, len(attachments))
+	for i, a := range attachments {
+		names[i] = a.Name
+	}
+
+	attached := "[attached: " + strings.Join(names, ", ") + "]"
+	if text == "" {
+		return attached
+	}
+	return text + "\n" + attached
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadAttachments tests reading files and rejecting unsuitable ones
+func TestReadAttachments(t *testing.T) {
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	tmpDir := t.TempDir()
+	textFile := filepath.Join(tmpDir, "main.go")
+	binaryFile := filepath.Join(tmpDir, "image.png")
+	largeFile := filepath.Join(tmpDir, "large.txt")
+
+	if err := os.WriteFile(textFile, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(binaryFile, []byte("\x89PNG\x00\x00"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(largeFile, []byte(strings.Repeat("a", 2048)), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		paths         []string
+		stdin         string
+		maxSize       int64
+		expectedNames []string
+		wantErr       bool
+		errContains   string
+	}{
+		{
+			name:          "text file and stdin",
+			paths:         []string{textFile, "-"},
+			stdin:         "from stdin",
+			maxSize:       1024,
+			expectedNames: []string{textFile, "stdin"},
+		},
+		{
+			name:          "no limit",
+			paths:         []string{largeFile},
+			maxSize:       0,
+			expectedNames: []string{largeFile},
+		},
+		{
+			name:        "binary file",
+			paths:       []string{binaryFile},
+			maxSize:     1024,
+			wantErr:     true,
+			errContains: "appears to be a binary file",
+		},
+		{
+			name:        "file above limit",
+			paths:       []string{largeFile},
+			maxSize:     1024,
+			wantErr:     true,
+			errContains: "larger than the 1KB limit",
+		},
+		{
+			name:        "stdin above limit",
+			paths:       []string{"-"},
+			stdin:       strings.Repeat("a", 2048),
+			maxSize:     1024,
+			wantErr:     true,
+			errContains: "stdin is larger than the 1KB limit",
+		},
+		{
+			name:        "stdin twice",
+			paths:       []string{"-", "-"},
+			maxSize:     1024,
+			wantErr:     true,
+			errContains: "stdin can only be attached once",
+		},
+		{
+			name:        "missing file",
+			paths:       []string{filepath.Join(tmpDir, "missing.txt")},
+			maxSize:     1024,
+			wantErr:     true,
+			errContains: "failed to open file",
+		},
+		{
+			name:        "directory",
+			paths:       []string{tmpDir},
+			maxSize:     1024,
+			wantErr:     true,
+			errContains: "is a directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdin = strings.NewReader(tt.stdin)
+
+			attachments, err := readAttachments(tt.paths, tt.maxSize)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("readAttachments() expected error, got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readAttachments() unexpected error: %v", err)
+			}
+
+			var names []string
+			for _, a := range attachments {
+				names = append(names, a.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expectedNames, ",") {
+				t.Errorf("names = %v, want %v", names, tt.expectedNames)
+			}
+		})
+	}
+}
+
+// TestBuildPrompt tests wrapping attachments in fenced code blocks
+func TestBuildPrompt(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		attachments []attachment
+		expected    string
+	}{
+		{
+			name:     "no attachments",
+			text:     "Hello",
+			expected: "Hello",
+		},
+		{
+			name:        "one file",
+			text:        "Review this",
+			attachments: []attachment{{Name: "main.go", Content: "package main\n"}},
+			expected:    "Review this\n\n``` main.go\npackage main\n```",
+		},
+		{
+			name: "file only",
+			attachments: []attachment{
+				{Name: "a.txt", Content: "a"},
+				{Name: "b.txt", Content: "b"},
+			},
+			expected: "``` a.txt\na\n```\n\n``` b.txt\nb\n```",
+		},
+		{
+			name:        "content with a fence",
+			text:        "Fix the docs",
+			attachments: []attachment{{Name: "README.md", Content: "```go\nx\n```"}},
+			expected:    "Fix the docs\n\n```` README.md\n```go\nx\n```\n````",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := buildPrompt(tt.text, tt.attachments); result != tt.expected {
+				t.Errorf("buildPrompt() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPromptForLog tests that logs name attachments instead of including them
+func TestPromptForLog(t *testing.T) {
+	attachments := []attachment{
+		{Name: "main.go", Content: "package main"},
+		{Name: "stdin", Content: "input"},
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		attachments []attachment
+		full        bool
+		expected    string
+	}{
+		{"no attachments", "Hello", nil, false, "Hello"},
+		{"filenames only", "Review", attachments, false, "Review\n[attached: main.go, stdin]"},
+		{"no text", "", attachments, false, "[attached: main.go, stdin]"},
+		{"full prompt", "Review", attachments[:1], true, "Review\n\n``` main.go\npackage main\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := promptForLog(tt.text, tt.attachments, tt.full); result != tt.expected {
+				t.Errorf("promptForLog() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPromptCommandFile tests sending and logging a prompt with an attachment
+func TestPromptCommandFile(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		received = request.Messages[0].Content
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			Choices: []Choice{{Message: Message{Content: "Looks good"}}},
+		})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	config := &Config{
+		APIKey:      "test-key",
+		APIURL:      server.URL,
+		Model:       "gpt-4o",
+		Timeout:     10 * time.Second,
+		MaxFileSize: defaultMaxFileSize,
+		ConfigDir:   tmpDir,
+	}
+
+	if err := promptCommand(config, []string{"--no-stream", "--file", file, "Review this"}); err != nil {
+		t.Fatalf("promptCommand() error = %v", err)
+	}
+
+	if !strings.Contains(received, "Review this") || !strings.Contains(received, "package main") {
+		t.Errorf("request content = %q, want the text and the file contents", received)
+	}
+
+	entries, _, err := readLogEntries([]string{filepath.Join(tmpDir, "logs.jsonl")}, logFilter{}, 0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("readLogEntries() = %v, %v, want one entry", entries, err)
+	}
+	if strings.Contains(entries[0].Prompt, "package main") {
+		t.Errorf("logged prompt = %q, should not contain the file contents", entries[0].Prompt)
+	}
+	if !strings.Contains(entries[0].Prompt, "[attached: "+file+"]") {
+		t.Errorf("logged prompt = %q, want the attached filename", entries[0].Prompt)
+	}
+}
+
+// TestWrapPrompt tests putting the prefix and suffix around a prompt
+func TestWrapPrompt(t *testing.T) {
+	tests := []struct {
+		name           string
+		prefix, suffix string
+		expected       string
+	}{
+		{name: "neither", expected: "Question"},
+		{name: "prefix", prefix: "Answer in Italian.", expected: "Answer in Italian.\n\nQuestion"},
+		{name: "suffix", suffix: "Only code.", expected: "Question\n\nOnly code."},
+		{name: "both", prefix: "P", suffix: "S", expected: "P\n\nQuestion\n\nS"},
+		{name: "blank prefix", prefix: "  ", expected: "Question"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapPrompt(tt.prefix, "Question", tt.suffix); got != tt.expected {
+				t.Errorf("wrapPrompt() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPromptCommandPrefixSuffix tests the order of the prefix, the prompt
+// from stdin, the attached files and the suffix, as sent and as logged
+func TestPromptCommandPrefixSuffix(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		received = request.Messages[0].Content
+		_ = json.NewEncoder(w).Encode(ChatResponse{Choices: []Choice{{Message: Message{Content: "ok"}}}})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+	stdin = strings.NewReader("Review this")
+
+	config := &Config{
+		APIKey:       "test-key",
+		APIURL:       server.URL,
+		Model:        "gpt-4o",
+		Timeout:      10 * time.Second,
+		MaxFileSize:  defaultMaxFileSize,
+		ConfigDir:    tmpDir,
+		PromptPrefix: "Answer in Italian.",
+		PromptSuffix: "Be brief.",
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "--suffix", "Only code.", "--file", file, "--stdin"}); err != nil {
+			t.Fatalf("promptCommand() error = %v", err)
+		}
+	})
+
+	if want := "Answer in Italian.\n\nReview this\n\n``` " + file + "\npackage main\n```\n\nOnly code."; received != want {
+		t.Errorf("request content = %q, want the prefix, stdin, the file, then the --suffix: %q", received, want)
+	}
+
+	entries, _, err := readLogEntries([]string{filepath.Join(tmpDir, "logs.jsonl")}, logFilter{}, 0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("readLogEntries() = %v, %v, want one entry", entries, err)
+	}
+	if want := "Answer in Italian.\n\nReview this\n[attached: " + file + "]\n\nOnly code."; entries[0].Prompt != want {
+		t.Errorf("logged prompt = %q, want %q", entries[0].Prompt, want)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// Sources of the API key besides the credential store, which reports its own
+// name
+const (
+	apiKeySourceEnv  = "environment"
+	apiKeySourceFile = "config file"
+)
+
+// AuthStatus describes where the API key of a profile can come from
+type AuthStatus struct {
+	Profile     string `json:"profile"`
+	Source      string `json:"source"`
+	Environment bool   `json:"environment"`
+	Store       string `json:"store"`
+	StoreKey    bool   `json:"store_key"`
+	StoreError  string `json:"store_error,omitempty"`
+	ConfigFile  bool   `json:"config_file"`
+	Encrypted   bool   `json:"encrypted,omitempty"`
+}
+
+// resolveAPIKey picks the provider's API key from the environment, then the
+// credential store, then the config file, and reports where it came from. An
+// encrypted key in the config file is returned empty; see unlockAPIKey.
+func resolveAPIKey(configDir, profile, provider string, fileConfig map[string]string) (string, string) {
+	name := apiKeyName(provider)
+	if apiKey := os.Getenv(name); apiKey != "" {
+		return apiKey, apiKeySourceEnv
+	}
+
+	// A store that cannot be read is skipped here; auth status reports why
+	store := newCredentialStore(configDir)
+	if apiKey, err := lookupStoredAPIKey(store, profile); err == nil {
+		return apiKey, store.Name()
+	}
+
+	if fileConfig[name] != "" || hasEncryptedAPIKey(provider, fileConfig) {
+		return fileConfig[name], apiKeySourceFile
+	}
+	return "", ""
+}
+
+// hasEncryptedAPIKey reports whether the config file holds an encrypted key
+// for the provider. Only OPENAI_API_KEY can be stored encrypted.
+func hasEncryptedAPIKey(provider string, fileConfig map[string]string) bool {
+	return apiKeyName(provider) == envAPIKey && fileConfig[encryptedAPIKeyKey] != ""
+}
+
+// lookupStoredAPIKey returns the key stored for the profile, or the one stored
+// for the default profile when the profile has none
+func lookupStoredAPIKey(store credentialStore, profile string) (string, error) {
+	apiKey, err := store.Get(profileDisplayName(profile))
+	if errors.Is(err, errCredentialNotFound) && profile != "" {
+		apiKey, err = store.Get(defaultProfileName)
+	}
+	if err == nil && apiKey == "" {
+		err = errCredentialNotFound
+	}
+	return apiKey, err
+}
+
+// apiKeyWithSource returns the API key as shown by config list, followed by
+// where it was found
+func apiKeyWithSource(config *Config) string {
+	if config.apiKeySource == "" {
+		return displayAPIKey(config)
+	}
+	return fmt.Sprintf("%s (from %s)", displayAPIKey(config), config.apiKeySource)
+}
+
+// authCommand manages the API key kept in the OS credential store
+func authCommand(config *Config, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("auth subcommand required\nUsage: chatgpt-cli auth <login|logout|status>")
+	}
+
+	switch args[0] {
+	case "login":
+		return authLoginCommand(config, args[1:])
+	case "logout":
+		return authLogoutCommand(config, args[1:])
+	case "status":
+		return authStatusCommand(config, args[1:])
+	default:
+		return usageErrorf("unknown auth subcommand: %s\nUsage: chatgpt-cli auth <login|logout|status>", args[0])
+	}
+}
+
+// authLoginCommand reads an API key without echoing it and saves it to the
+// credential store for the current profile
+func authLoginCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli auth login", args[0])
+	}
+
+	apiKey, err := readAPIKey()
+	if err != nil {
+		return err
+	}
+
+	store := newCredentialStore(config.ConfigDir)
+	account := profileDisplayName(config.Profile)
+	if err := store.Set(account, apiKey); err != nil {
+		return fmt.Errorf("failed to save the API key to the %s: %w", store.Name(), err)
+	}
+
+	fmt.Printf("Saved the API key for profile %s to the %s\n", account, store.Name())
+	name := apiKeyName(config.Provider)
+	if os.Getenv(name) != "" {
+		fmt.Printf("Note: %s is set in the environment and takes precedence\n", name)
+	} else if config.apiKeySource == apiKeySourceFile {
+		fmt.Printf("The API key in the config file is no longer used; remove it with: chatgpt-cli config unset %s\n", name)
+	}
+	return nil
+}
+
+// readAPIKey asks for the API key on the terminal without echoing it, or reads
+// a line from stdin when it is not a terminal
+func readAPIKey() (string, error) {
+	var apiKey string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		secret, err := readHidden("OpenAI API key: ")
+		if err != nil {
+			return "", fmt.Errorf("failed to read the API key: %w", err)
+		}
+		apiKey = secret
+	} else {
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read the API key: %w", err)
+		}
+		apiKey = line
+	}
+
+	apiKey = normalizeAPIKey(apiKey)
+	if apiKey == "" {
+		return "", usageErrorf("API key cannot be empty")
+	}
+	return apiKey, nil
+}
+
+// authLogoutCommand removes the current profile's API key from the credential
+// store
+func authLogoutCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli auth logout", args[0])
+	}
+
+	store := newCredentialStore(config.ConfigDir)
+	account := profileDisplayName(config.Profile)
+	err := store.Delete(account)
+	if errors.Is(err, errCredentialNotFound) {
+		fmt.Printf("No API key stored in the %s for profile %s\n", store.Name(), account)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove the API key from the %s: %w", store.Name(), err)
+	}
+
+	fmt.Printf("Removed the API key for profile %s from the %s\n", account, store.Name())
+	return nil
+}
+
+// authStatusCommand shows every place an API key was found for the current
+// profile and which one is used
+func authStatusCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli auth status", args[0])
+	}
+
+	fileConfig, err := loadProfileConfig(config.ConfigDir, config.Profile)
+	if err != nil {
+		return err
+	}
+
+	name := apiKeyName(config.Provider)
+	store := newCredentialStore(config.ConfigDir)
+	status := AuthStatus{
+		Profile:     profileDisplayName(config.Profile),
+		Source:      config.apiKeySource,
+		Environment: os.Getenv(name) != "",
+		Store:       store.Name(),
+		ConfigFile:  fileConfig[name] != "" || hasEncryptedAPIKey(config.Provider, fileConfig),
+		Encrypted:   hasEncryptedAPIKey(config.Provider, fileConfig),
+	}
+	_, err = lookupStoredAPIKey(store, config.Profile)
+	status.StoreKey = err == nil
+	if err != nil && !errors.Is(err, errCredentialNotFound) {
+		status.StoreError = err.Error()
+	}
+
+	if config.Output == outputJSON {
+		return printJSON(status)
+	}
+
+	fmt.Printf("Profile:      %s\n", status.Profile)
+	fmt.Printf("Environment:  %s\n", setOrNot(status.Environment))
+	storeState := setOrNot(status.StoreKey)
+	if status.StoreError != "" {
+		storeState = "unavailable: " + status.StoreError
+	}
+	fmt.Printf("Keychain:     %s (%s)\n", storeState, status.Store)
+	fileState := setOrNot(status.ConfigFile)
+	if status.Encrypted {
+		fileState += " (encrypted)"
+	}
+	fmt.Printf("Config file:  %s\n", fileState)
+
+	if status.Source == "" {
+		fmt.Println("No API key found; run: chatgpt-cli auth login")
+	} else {
+		fmt.Printf("Using:        %s\n", status.Source)
+	}
+	return nil
+}
+
+// normalizeAPIKey strips the whitespace and surrounding quotes that copying a
+// key from a password manager or quoting it twice in a shell leaves around it
+func normalizeAPIKey(apiKey string) string {
+	apiKey = strings.TrimSpace(apiKey)
+	for len(apiKey) >= 2 && (apiKey[0] == '"' || apiKey[0] == '\'') && apiKey[len(apiKey)-1] == apiKey[0] {
+		apiKey = strings.TrimSpace(apiKey[1 : len(apiKey)-1])
+	}
+	return apiKey
+}
+
+// apiKeyFormatProblem describes what looks wrong with the shape of the API
+// key, or returns "" when nothing does. Keys of other services behind a
+// custom OPENAI_API_URL can look like anything, so only the official
+// endpoints' prefixes are checked.
+func apiKeyFormatProblem(config *Config) string {
+	apiKey := config.APIKey
+	switch {
+	case apiKey == "":
+		return ""
+	case strings.IndexFunc(apiKey, unicode.IsSpace) >= 0:
+		return "contains whitespace"
+	case strings.HasPrefix(apiKey, `"`) || strings.HasPrefix(apiKey, "'") || strings.HasSuffix(apiKey, `"`) || strings.HasSuffix(apiKey, "'"):
+		return "is wrapped in quotes"
+	}
+
+	if config.APIURL != defaultAPIURLFor(config.Provider) {
+		return ""
+	}
+	switch config.Provider {
+	case providerOpenAI:
+		if !strings.HasPrefix(apiKey, "sk-") {
+			return "does not start with sk- (or sk-proj-) like OpenAI keys"
+		}
+	case providerAnthropic:
+		if !strings.HasPrefix(apiKey, "sk-ant-") {
+			return "does not start with sk-ant- like Anthropic keys"
+		}
+	}
+	return ""
+}
+
+// warnAPIKeyFormat tells on stderr when the API key looks malformed, and how
+// to set it again, since the API only answers such a key with a 401
+func warnAPIKeyFormat(config *Config) {
+	problem := apiKeyFormatProblem(config)
+	if problem == "" {
+		return
+	}
+
+	name := apiKeyName(config.Provider)
+	var fix string
+	switch config.apiKeySource {
+	case apiKeySourceEnv:
+		fix = fmt.Sprintf("check the value of %s in your environment", name)
+	case apiKeySourceFile:
+		fix = fmt.Sprintf("set it again with: chatgpt-cli config set %s <key>", name)
+	default:
+		fix = "save it again with: chatgpt-cli auth login"
+	}
+	errOut := newUI(config, os.Stderr)
+	errOut.Printf("%s the API key from the %s %s; %s\n", errOut.yellow("Warning:"), config.apiKeySource, problem, fix)
+}
+
+// setOrNot describes whether an API key was found in a place
+func setOrNot(found bool) string {
+	if found {
+		return "set"
+	}
+	return "not set"
+}
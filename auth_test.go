@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestResolveAPIKey tests the order the API key sources are tried in
+func TestResolveAPIKey(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	store := newCredentialStore(tmpDir)
+	fileConfig := map[string]string{envAPIKey: "sk-file"}
+
+	tests := []struct {
+		name       string
+		env        string
+		stored     map[string]string
+		profile    string
+		fileConfig map[string]string
+		wantKey    string
+		wantSource string
+	}{
+		{"nothing", "", nil, "", nil, "", ""},
+		{"config file", "", nil, "", fileConfig, "sk-file", apiKeySourceFile},
+		{"encrypted config file", "", nil, "", map[string]string{encryptedAPIKeyKey: "abc"}, "", apiKeySourceFile},
+		{"keychain over config file", "", map[string]string{"default": "sk-stored"}, "", fileConfig, "sk-stored", store.Name()},
+		{"environment over keychain", "sk-env", map[string]string{"default": "sk-stored"}, "", fileConfig, "sk-env", apiKeySourceEnv},
+		{"profile key", "", map[string]string{"default": "sk-stored", "work": "sk-work"}, "work", nil, "sk-work", store.Name()},
+		{"profile falls back to default key", "", map[string]string{"default": "sk-stored"}, "work", nil, "sk-stored", store.Name()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(store.(fileCredentialStore).path)
+			for account, apiKey := range tt.stored {
+				if err := store.Set(account, apiKey); err != nil {
+					t.Fatalf("Set() error = %v", err)
+				}
+			}
+			if tt.env != "" {
+				setTestEnv(envAPIKey, tt.env)
+				defer os.Unsetenv(envAPIKey)
+			}
+
+			apiKey, source := resolveAPIKey(tmpDir, tt.profile, providerOpenAI, tt.fileConfig)
+			if apiKey != tt.wantKey || source != tt.wantSource {
+				t.Errorf("resolveAPIKey() = %q, %q, want %q, %q", apiKey, source, tt.wantKey, tt.wantSource)
+			}
+		})
+	}
+
+	// The anthropic provider reads ANTHROPIC_API_KEY and ignores the OpenAI keys
+	os.Remove(store.(fileCredentialStore).path)
+	setTestEnv(envAPIKey, "sk-env")
+	defer os.Unsetenv(envAPIKey)
+	fileConfig = map[string]string{envAPIKey: "sk-file", encryptedAPIKeyKey: "abc", envAnthropicAPIKey: "sk-ant-file"}
+	if apiKey, source := resolveAPIKey(tmpDir, "", providerAnthropic, fileConfig); apiKey != "sk-ant-file" || source != apiKeySourceFile {
+		t.Errorf("resolveAPIKey(anthropic) = %q, %q, want the ANTHROPIC_API_KEY from the config file", apiKey, source)
+	}
+	setTestEnv(envAnthropicAPIKey, "sk-ant-env")
+	defer os.Unsetenv(envAnthropicAPIKey)
+	if apiKey, source := resolveAPIKey(tmpDir, "", providerAnthropic, fileConfig); apiKey != "sk-ant-env" || source != apiKeySourceEnv {
+		t.Errorf("resolveAPIKey(anthropic) = %q, %q, want the ANTHROPIC_API_KEY from the environment", apiKey, source)
+	}
+}
+
+// TestAuthCommands tests logging in, checking the status and logging out
+func TestAuthCommands(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	writeTestConfigFile(t, tmpDir, "api_key = \"sk-file-1234567890\"\n")
+
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	// An empty key is refused
+	config, _ := loadConfig("")
+	stdin = strings.NewReader("\n")
+	if err := authCommand(config, []string{"login"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("auth login with an empty key error = %v, want a usage error", err)
+	}
+
+	stdin = strings.NewReader("sk-stored-1234567890\n")
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := authCommand(config, []string{"login"}); err != nil {
+			t.Errorf("auth login error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Saved the API key for profile default to the credentials file") {
+		t.Errorf("auth login output = %q", out)
+	}
+	if !strings.Contains(out, "config unset OPENAI_API_KEY") {
+		t.Errorf("auth login should point out the unused key in the config file: %q", out)
+	}
+
+	// The stored key now takes precedence over the config file
+	config, _ = loadConfig("")
+	if config.APIKey != "sk-stored-1234567890" {
+		t.Errorf("loadConfig() APIKey = %q, want the stored key", config.APIKey)
+	}
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := configListCommand(config, nil); err != nil {
+			t.Errorf("configListCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "sk-s...7890 (from credentials file)") {
+		t.Errorf("config list should show where the key comes from:\n%s", out)
+	}
+
+	config.Output = outputJSON
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := authCommand(config, []string{"status"}); err != nil {
+			t.Errorf("auth status error = %v", err)
+		}
+	})
+	var status AuthStatus
+	if err := json.Unmarshal([]byte(out), &status); err != nil {
+		t.Fatalf("auth status output is not valid JSON: %v\n%s", err, out)
+	}
+	expected := AuthStatus{Profile: "default", Source: "credentials file", Store: "credentials file", StoreKey: true, ConfigFile: true}
+	if status != expected {
+		t.Errorf("auth status = %+v, want %+v", status, expected)
+	}
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := authCommand(config, []string{"logout"}); err != nil {
+			t.Errorf("auth logout error = %v", err)
+		}
+		if err := authCommand(config, []string{"logout"}); err != nil {
+			t.Errorf("auth logout twice error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Removed the API key") || !strings.Contains(out, "No API key stored") {
+		t.Errorf("auth logout output = %q", out)
+	}
+
+	config, _ = loadConfig("")
+	if config.APIKey != "sk-file-1234567890" || config.apiKeySource != apiKeySourceFile {
+		t.Errorf("after logout APIKey = %q from %q, want the config file key", config.APIKey, config.apiKeySource)
+	}
+
+	for _, args := range [][]string{nil, {"signin"}, {"status", "extra"}} {
+		if err := authCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("authCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
+
+// TestNormalizeAPIKey tests that pasted keys lose their whitespace and quotes
+func TestNormalizeAPIKey(t *testing.T) {
+	tests := []struct {
+		apiKey string
+		want   string
+	}{
+		{"sk-abc", "sk-abc"},
+		{"sk-abc\n", "sk-abc"},
+		{"  sk-abc\r\n", "sk-abc"},
+		{`"sk-abc"`, "sk-abc"},
+		{`'sk-abc'` + "\n", "sk-abc"},
+		{`"'sk-abc'"`, "sk-abc"},
+		{`"sk-abc`, `"sk-abc`},
+		{`""`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeAPIKey(tt.apiKey); got != tt.want {
+			t.Errorf("normalizeAPIKey(%q) = %q, want %q", tt.apiKey, got, tt.want)
+		}
+	}
+}
+
+// TestAPIKeyFormatProblem tests the checks of the API key's shape
+func TestAPIKeyFormatProblem(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiKey   string
+		provider string
+		apiURL   string
+		want     string
+	}{
+		{"openai key", "sk-abc", providerOpenAI, "", ""},
+		{"project key", "sk-proj-abc", providerOpenAI, "", ""},
+		{"whitespace", "sk-abc def", providerOpenAI, "", "contains whitespace"},
+		{"quotes", `"sk-abc`, providerOpenAI, "", "is wrapped in quotes"},
+		{"unknown prefix", "abc123", providerOpenAI, "", "does not start with sk-"},
+		{"custom endpoint", "gsk_abc", providerOpenAI, "https://api.example.com/v1/chat/completions", ""},
+		{"anthropic key", "sk-ant-abc", providerAnthropic, "", ""},
+		{"openai key for anthropic", "sk-abc", providerAnthropic, "", "does not start with sk-ant-"},
+		{"azure key", "0123abcd", providerAzure, "https://example.openai.azure.com", ""},
+		{"no key", "", providerOpenAI, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiURL := tt.apiURL
+			if apiURL == "" {
+				apiURL = defaultAPIURLFor(tt.provider)
+			}
+			config := &Config{APIKey: tt.apiKey, Provider: tt.provider, APIURL: apiURL}
+			got := apiKeyFormatProblem(config)
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("apiKeyFormatProblem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMalformedAPIKeys tests that loadConfig trims the key, config set strips
+// what was pasted around it and prompt warns about what is left
+func TestMalformedAPIKeys(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	setTestEnv(envNoColor, "true")
+	setTestEnv(envAPIKey, "sk-env\n")
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.APIKey != "sk-env" {
+		t.Errorf("loadConfig() API key = %q, want the trailing newline trimmed", config.APIKey)
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := configSetCommand(config, []string{envAPIKey, "\"sk-file\"\n"}); err != nil {
+			t.Fatalf("config set error = %v", err)
+		}
+	})
+	fileConfig, err := loadConfigFile(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fileConfig[envAPIKey] != "sk-file" {
+		t.Errorf("saved API key = %q, want sk-file", fileConfig[envAPIKey])
+	}
+	if err := configSetCommand(config, []string{envAPIKey, `''`}); err == nil || !strings.Contains(err.Error(), "API key cannot be empty") {
+		t.Errorf("config set of a quoted empty key error = %v, want API key cannot be empty", err)
+	}
+
+	// The prompt is still sent after the warning
+	sent := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+	setTestEnv(envAPIURL, server.URL)
+	setTestEnv(envAPIKey, `'sk-env'`)
+	if config, err = loadConfig(""); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	stderr := captureOutput(t, &os.Stderr, func() {
+		captureOutput(t, &os.Stdout, func() {
+			if err := promptCommand(config, []string{"--no-stream", "hello"}); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+	if !sent {
+		t.Error("promptCommand() did not send the prompt")
+	}
+	want := "Warning: the API key from the environment is wrapped in quotes; check the value of OPENAI_API_KEY in your environment"
+	if !strings.Contains(stderr, want) {
+		t.Errorf("stderr = %q, want it to contain %q", stderr, want)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Characters of an attached file kept on each side of the part cut out
+const minTrimKeep = 200
+
+// Parts of the context_length_exceeded message of the OpenAI API, as in
+// "This model's maximum context length is 4097 tokens. However, you
+// requested 5000 tokens (1000 in the messages, 4000 in the completion)."
+var (
+	contextLimitPattern     = regexp.MustCompile(`maximum context length is (\d+) tokens`)
+	contextRequestedPattern = regexp.MustCompile(`(\d+) in the messages, (\d+) in the completion`)
+)
+
+// promptParts are the parts a prompt is built from, kept apart so that the
+// attached files can be trimmed and the prompt built again
+type promptParts struct {
+	text           string
+	attachments    []attachment
+	prefix, suffix string
+}
+
+// build returns the prompt, or "" if there is no text and no file, as after
+// a --messages conversation sent on its own
+func (p promptParts) build() string {
+	if strings.TrimSpace(p.text) == "" && len(p.attachments) == 0 {
+		return ""
+	}
+	return wrapPrompt(p.prefix, buildPrompt(p.text, p.attachments), p.suffix)
+}
+
+// promptForLogParts returns the prompt built from parts as the log records
+// it, with the attached files only named unless config.LogFullPrompt
+func promptForLogParts(config *Config, parts promptParts) string {
+	if parts.build() == "" {
+		return ""
+	}
+	return wrapPrompt(parts.prefix, promptForLog(parts.text, parts.attachments, config.LogFullPrompt), parts.suffix)
+}
+
+// contextLengthExceeded returns the failure of a request rejected for not
+// fitting in the model's context
+func contextLengthExceeded(err error) (*APIFailure, bool) {
+	var failure *APIFailure
+	if errors.As(err, &failure) && failure.API != nil && failure.API.Code == "context_length_exceeded" {
+		return failure, true
+	}
+	return nil, false
+}
+
+// contextLimits reads the context length of the model and the tokens of the
+// request from the message of the failure, when the API gives them
+func contextLimits(message string) (limit, messages, completion int, ok bool) {
+	limitMatch := contextLimitPattern.FindStringSubmatch(message)
+	requestedMatch := contextRequestedPattern.FindStringSubmatch(message)
+	if limitMatch == nil || requestedMatch == nil {
+		return 0, 0, 0, false
+	}
+	limit, _ = strconv.Atoi(limitMatch[1])
+	messages, _ = strconv.Atoi(requestedMatch[1])
+	completion, _ = strconv.Atoi(requestedMatch[2])
+	return limit, messages, completion, true
+}
+
+// trimRequest shrinks a prompt the API rejected with failure for not fitting
+// in the model's context, changing config and parts, and returns what was
+// trimmed, or "" if nothing could be. Depending on what the request holds,
+// it lowers max_tokens when the messages fit on their own, or else drops the
+// oldest messages of the conversation before the prompt, or else cuts the
+// middle out of the attached files.
+func trimRequest(config *Config, parts *promptParts, failure *APIFailure) string {
+	limit, messageTokens, completion, ok := contextLimits(failure.Message)
+	if ok && messageTokens < limit && config.MaxTokens > limit-messageTokens {
+		before := config.MaxTokens
+		config.MaxTokens = limit - messageTokens
+		return fmt.Sprintf("lowered max_tokens from %d to %d", before, config.MaxTokens)
+	}
+
+	// Without the counts, half of what can be trimmed goes; with them, what
+	// is over by the estimate scaled to the API's count, and a tenth more
+	prompt := parts.build()
+	messages := promptMessages(config, prompt)
+	estimated := estimatePromptTokens(messages)
+	excess := estimated / 2
+	if ok && estimated > 0 && messageTokens > 0 {
+		excess = (messageTokens + completion - limit) * estimated / messageTokens
+		excess += excess / 10
+	}
+
+	if note := trimConversation(config, prompt != "", excess); note != "" {
+		return note
+	}
+	return trimAttachments(parts, excess)
+}
+
+// trimConversation drops the oldest messages before the prompt, other than
+// system messages, until about excess tokens are gone. The results of a tool
+// call go with the call. The last message of a --messages conversation sent
+// without a prompt is kept.
+func trimConversation(config *Config, hasPrompt bool, excess int) string {
+	conversation := &config.History
+	if config.Messages != nil {
+		conversation = &config.Messages
+	}
+	kept := append([]Message(nil), (*conversation)...)
+	last := len(kept)
+	if config.Messages != nil && !hasPrompt {
+		last--
+	}
+
+	dropped, tokens := 0, 0
+	for i := 0; i < last && tokens < excess; {
+		if kept[i].Role == "system" {
+			i++
+			continue
+		}
+		n := 1
+		for i+n < last && kept[i+n].Role == "tool" {
+			n++
+		}
+		for _, m := range kept[i : i+n] {
+			tokens += tokensPerMessage + estimateTokens(m.Content)
+		}
+		kept = append(kept[:i], kept[i+n:]...)
+		last -= n
+		dropped += n
+	}
+	if dropped == 0 {
+		return ""
+	}
+	*conversation = kept
+	return fmt.Sprintf("dropped the %d oldest %s of the conversation, about %d tokens", dropped, plural(dropped, "message", "messages"), tokens)
+}
+
+// trimAttachments cuts the middle out of the largest attached files until
+// about excess tokens are gone, marking where text was cut
+func trimAttachments(parts *promptParts, excess int) string {
+	order := make([]int, len(parts.attachments))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(parts.attachments[order[a]].Content) > len(parts.attachments[order[b]].Content)
+	})
+
+	var notes []string
+	for _, i := range order {
+		if excess <= 0 {
+			break
+		}
+		a := &parts.attachments[i]
+		content := []rune(a.Content)
+		tokens := estimateTokens(a.Content)
+		if len(content) <= 2*minTrimKeep || tokens == 0 {
+			continue
+		}
+		// Characters per token of this file, to turn tokens into characters
+		cut := excess * len(content) / tokens
+		if cut > len(content)-2*minTrimKeep {
+			cut = len(content) - 2*minTrimKeep
+		}
+		head := (len(content) - cut) / 2
+		tail := len(content) - cut - head
+		a.Content = string(content[:head]) + fmt.Sprintf("\n[... %d characters trimmed ...]\n", cut) + string(content[len(content)-tail:])
+		excess -= cut * tokens / len(content)
+		notes = append(notes, fmt.Sprintf("cut %d characters from the middle of %s", cut, a.Name))
+	}
+	return strings.Join(notes, ", ")
+}
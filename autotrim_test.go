@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contextLengthBody is the error of the OpenAI API for a request that
+// doesn't fit in the model's context
+func contextLengthBody(limit, messages, completion int) string {
+	message := fmt.Sprintf("This model's maximum context length is %d tokens. However, you requested %d tokens (%d in the messages, %d in the completion). Please reduce the length of the messages or completion.",
+		limit, messages+completion, messages, completion)
+	return `{"error":{"message":"` + message + `","type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`
+}
+
+// TestContextLimits tests reading the counts from the error message
+func TestContextLimits(t *testing.T) {
+	var body struct{ Error APIError }
+	if err := json.Unmarshal([]byte(contextLengthBody(4097, 1200, 4000)), &body); err != nil {
+		t.Fatal(err)
+	}
+	limit, messages, completion, ok := contextLimits(body.Error.Message)
+	if !ok || limit != 4097 || messages != 1200 || completion != 4000 {
+		t.Errorf("contextLimits() = %d, %d, %d, %v", limit, messages, completion, ok)
+	}
+	if _, _, _, ok := contextLimits("prompt is too long"); ok {
+		t.Error("contextLimits() of a message without counts is ok")
+	}
+}
+
+// TestPromptAutoTrim tests that a prompt rejected with
+// context_length_exceeded is sent once more, shrunk according to what it
+// holds, and only when CHATGPT_CLI_AUTO_TRIM is set without --no-trim
+func TestPromptAutoTrim(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	var conversation []Message
+	conversation = append(conversation, Message{Role: "system", Content: "You are terse."})
+	for i := 1; i <= 10; i++ {
+		conversation = append(conversation,
+			Message{Role: "user", Content: fmt.Sprintf("question %d %s", i, strings.Repeat("word ", 100))},
+			Message{Role: "assistant", Content: fmt.Sprintf("answer %d %s", i, strings.Repeat("word ", 100))})
+	}
+	data, err := json.Marshal(conversation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messagesPath := filepath.Join(dir, "messages.json")
+	if err := os.WriteFile(messagesPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	bigFile := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(bigFile, []byte(strings.Repeat("line of text\n", 2000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		autoTrim bool
+		args     []string
+		// The counts of the rejection of the first request
+		messages, completion int
+		requests             int
+		trimmed              string
+		check                func(t *testing.T, first, second ChatRequest)
+		// logged checks the log entry of the request sent after trimming
+		logged func(t *testing.T, entry LogEntry)
+	}{
+		{
+			name:       "max tokens lowered",
+			autoTrim:   true,
+			args:       []string{"--max-tokens", "4000", "hello"},
+			messages:   500,
+			completion: 4000,
+			requests:   2,
+			trimmed:    "lowered max_tokens from 4000 to 3597",
+			check: func(t *testing.T, first, second ChatRequest) {
+				if second.MaxTokens != 3597 || len(second.Messages) != len(first.Messages) {
+					t.Errorf("second request max_tokens = %d with %d messages", second.MaxTokens, len(second.Messages))
+				}
+			},
+		},
+		{
+			name:       "oldest messages dropped",
+			autoTrim:   true,
+			args:       []string{"--messages", messagesPath, "and now?"},
+			messages:   6000,
+			completion: 1000,
+			requests:   2,
+			trimmed:    "oldest messages of the conversation",
+			check: func(t *testing.T, first, second ChatRequest) {
+				if len(second.Messages) >= len(first.Messages) || len(second.Messages) < 3 {
+					t.Fatalf("second request has %d messages, first %d", len(second.Messages), len(first.Messages))
+				}
+				if second.Messages[0].Content != first.Messages[0].Content {
+					t.Errorf("system message = %+v, want it kept", second.Messages[0])
+				}
+				if strings.HasPrefix(second.Messages[1].Content, "question 1 ") {
+					t.Errorf("oldest question kept: %q", second.Messages[1].Content[:20])
+				}
+				if last := second.Messages[len(second.Messages)-1]; last.Content != "and now?" {
+					t.Errorf("last message = %q, want the prompt", last.Content)
+				}
+				// Only newest messages remain after the system message
+				dropped := len(first.Messages) - len(second.Messages)
+				for i, m := range second.Messages[1:] {
+					if m.Content != first.Messages[1+dropped+i].Content {
+						t.Errorf("message %d = %q, want %q", 1+i, m.Content, first.Messages[1+dropped+i].Content)
+					}
+				}
+			},
+			logged: func(t *testing.T, entry LogEntry) {
+				if strings.HasPrefix(entry.Messages, "21 messages") {
+					t.Errorf("logged messages = %q, want the trimmed conversation", entry.Messages)
+				}
+			},
+		},
+		{
+			name:       "attached file cut in the middle",
+			autoTrim:   true,
+			args:       []string{"--file", bigFile, "summarize"},
+			messages:   8000,
+			completion: 1000,
+			requests:   2,
+			trimmed:    "characters from the middle of " + bigFile,
+			check: func(t *testing.T, first, second ChatRequest) {
+				before, after := first.Messages[0].Content, second.Messages[0].Content
+				if len(after) >= len(before)/2 || !strings.Contains(after, "characters trimmed ...]") {
+					t.Errorf("second prompt is %d characters of %d:\n%s", len(after), len(before), after)
+				}
+				if !strings.HasPrefix(after, "summarize\n\n```") || !strings.HasSuffix(after, "line of text\n```") {
+					t.Errorf("second prompt lost its head or tail:\n%s", after)
+				}
+			},
+			logged: func(t *testing.T, entry LogEntry) {
+				if !strings.Contains(entry.Prompt, "characters trimmed ...]") {
+					t.Errorf("logged prompt is %d characters, want the trimmed file", len(entry.Prompt))
+				}
+			},
+		},
+		{
+			name:       "no trim flag",
+			autoTrim:   true,
+			args:       []string{"--no-trim", "--max-tokens", "4000", "hello"},
+			messages:   500,
+			completion: 4000,
+			requests:   1,
+		},
+		{
+			name:       "disabled",
+			args:       []string{"--max-tokens", "4000", "hello"},
+			messages:   500,
+			completion: 4000,
+			requests:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []ChatRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request ChatRequest
+				_ = json.NewDecoder(r.Body).Decode(&request)
+				requests = append(requests, request)
+				w.Header().Set("Content-Type", "application/json")
+				if len(requests) == 1 {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(contextLengthBody(4097, tt.messages, tt.completion)))
+					return
+				}
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"done"}}]}`))
+			}))
+			defer server.Close()
+
+			config := &Config{
+				APIKey:    "sk-test",
+				APIURL:    server.URL,
+				Model:     "gpt-4o",
+				MaxTokens: 1000,
+				Timeout:   5 * time.Second,
+				ConfigDir: t.TempDir(),
+				AutoTrim:  tt.autoTrim,
+				// The log then has the attached files whole, as sent
+				LogFullPrompt: true,
+			}
+			var err error
+			stderr := captureOutput(t, &os.Stderr, func() {
+				captureOutput(t, &os.Stdout, func() {
+					err = promptCommand(config, append([]string{"--no-stream"}, tt.args...))
+				})
+			})
+
+			if len(requests) != tt.requests {
+				t.Fatalf("%d requests sent, want %d", len(requests), tt.requests)
+			}
+			if tt.requests == 1 {
+				if _, ok := contextLengthExceeded(err); !ok {
+					t.Errorf("error = %v, want context_length_exceeded", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("promptCommand() error = %v", err)
+			}
+			if !strings.Contains(stderr, tt.trimmed) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, tt.trimmed)
+			}
+			tt.check(t, requests[0], requests[1])
+
+			entries, _, err := readLogEntries([]string{filepath.Join(config.ConfigDir, logFileName)}, logFilter{}, 0)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("readLogEntries() = %v, %v, want one entry", entries, err)
+			}
+			if !strings.Contains(entries[0].Trimmed, tt.trimmed) {
+				t.Errorf("logged trimmed = %q, want it to mention %q", entries[0].Trimmed, tt.trimmed)
+			}
+			if tt.logged != nil {
+				tt.logged(t, entries[0])
+			}
+		})
+	}
+}
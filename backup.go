@@ -0,0 +1,498 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Name of the manifest in a backup archive, always its first entry
+const backupManifestName = "manifest.json"
+
+// Version of the archive layout. Import refuses archives of a newer format,
+// made by a newer CLI.
+const backupFormat = 1
+
+// Largest file import reads from an archive
+const maxBackupFileSize = 10 << 20
+
+// Config file keys holding secrets, left out of exports unless
+// --include-secrets is given
+var backupSecretKeys = []string{envAPIKey, envAnthropicAPIKey, encryptedAPIKeyKey}
+
+// BackupManifest describes a backup archive: the CLI and format that made
+// it, whether it holds secrets, and the files it holds
+type BackupManifest struct {
+	Format     int       `json:"format"`
+	CLIVersion string    `json:"cli_version"`
+	Created    time.Time `json:"created"`
+	Secrets    bool      `json:"secrets"`
+	Files      []string  `json:"files"`
+}
+
+// backupFile is a file of a backup archive, named by its slash-separated
+// path in the config directory
+type backupFile struct {
+	Name string
+	Data []byte
+}
+
+// exportCommand bundles the config file, aliases, styles and redaction rules
+// into a tar.gz archive
+func exportCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli export --out backup.tar.gz [--include-secrets]"
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("out", "", "archive to write")
+	includeSecrets := fs.Bool("include-secrets", false, "include the API keys of the config file and the credentials file")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\n%s", args[0], usage)
+	}
+	if *out == "" {
+		return usageErrorf("--out is required\n%s", usage)
+	}
+
+	files, err := collectBackupFiles(config.ConfigDir, *includeSecrets)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("nothing to export: %s has no config file, aliases, styles or redaction rules", config.ConfigDir)
+	}
+
+	manifest := BackupManifest{Format: backupFormat, CLIVersion: version, Created: time.Now().UTC(), Secrets: *includeSecrets}
+	for _, file := range files {
+		manifest.Files = append(manifest.Files, file.Name)
+	}
+	data, err := writeBackupArchive(manifest, files)
+	if err != nil {
+		return err
+	}
+
+	// The archive may hold API keys, so only the user may read it
+	if err := writeFileAtomic(*out, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *out, err)
+	}
+
+	fmt.Printf("Exported %d %s to %s: %s\n", len(files), plural(len(files), "file", "files"), *out, strings.Join(manifest.Files, ", "))
+	if !*includeSecrets {
+		fmt.Println("API keys were left out; use --include-secrets to include them.")
+	}
+	return nil
+}
+
+// collectBackupFiles reads the files export bundles, in the order they are
+// archived. The config file is taken from config.toml, or the legacy config
+// file converted to TOML, without its secrets unless includeSecrets is set.
+func collectBackupFiles(configDir string, includeSecrets bool) ([]backupFile, error) {
+	var files []backupFile
+
+	if configFileExists(configDir) {
+		doc, err := loadConfigDocument(configDir)
+		if err != nil {
+			return nil, err
+		}
+		if !includeSecrets {
+			doc.removeKeys(backupSecretKeys)
+		}
+		files = append(files, backupFile{Name: configFileName, Data: []byte(doc.String())})
+	}
+
+	names := []string{aliasesFileName, redactRulesFile}
+	if includeSecrets {
+		names = append(names, credentialsFileName)
+	}
+	styles, err := filepath.Glob(filepath.Join(configDir, stylesDirName, "*"+styleFileExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(styles)
+	for _, style := range styles {
+		names = append(names, stylesDirName+"/"+filepath.Base(style))
+	}
+
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(configDir, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files = append(files, backupFile{Name: name, Data: data})
+	}
+	return files, nil
+}
+
+// removeKeys removes keys from every table of the document
+func (d *configDocument) removeKeys(keys []string) {
+	names := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		names[tomlKey(key)] = true
+	}
+
+	var kept []tomlLine
+	for _, line := range d.lines {
+		if !line.header && names[line.key] {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	d.lines = kept
+}
+
+// writeBackupArchive returns the tar.gz archive of the manifest followed by
+// the files
+func writeBackupArchive(manifest BackupManifest, files []backupFile) ([]byte, error) {
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range append([]backupFile{{Name: backupManifestName, Data: append(manifestData, '\n')}}, files...) {
+		header := &tar.Header{Name: file.Name, Mode: 0600, Size: int64(len(file.Data)), ModTime: manifest.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(file.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// importCommand restores the files of an archive made by export. Without
+// --merge or --overwrite, it refuses to replace any existing file.
+func importCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli import <backup.tar.gz> [--merge | --overwrite]"
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	merge := fs.Bool("merge", false, "keep existing files and values, adding only what they lack")
+	overwrite := fs.Bool("overwrite", false, "replace existing files with those of the archive")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) != 1 {
+		return usageErrorf("give exactly one archive to import\n%s", usage)
+	}
+	if *merge && *overwrite {
+		return usageErrorf("--merge and --overwrite cannot be combined")
+	}
+
+	manifest, files, err := readBackupArchive(config.ConfigDir, args[0])
+	if err != nil {
+		return err
+	}
+
+	var existing []string
+	for _, file := range files {
+		if backupFileExists(config.ConfigDir, file.Name) {
+			existing = append(existing, file.Name)
+		}
+	}
+	if len(existing) > 0 && !*merge && !*overwrite {
+		return fmt.Errorf("importing would replace %s\nUse --merge to keep them and add only what they lack, or --overwrite to replace them", strings.Join(existing, ", "))
+	}
+
+	if err := os.MkdirAll(config.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	for _, file := range files {
+		status := "restored"
+		if containsString(existing, file.Name) {
+			status = "replaced"
+			if *merge {
+				status, err = mergeBackupFile(config.ConfigDir, file)
+			} else {
+				err = restoreBackupFile(config.ConfigDir, file, manifest.Secrets)
+				// An archive without secrets leaves the API keys in place
+				if file.Name == configFileName && !manifest.Secrets {
+					status = "replaced, API keys kept"
+				}
+			}
+		} else {
+			err = restoreBackupFile(config.ConfigDir, file, manifest.Secrets)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", file.Name, err)
+		}
+		fmt.Printf("  %-24s %s\n", file.Name, status)
+	}
+
+	fmt.Printf("Imported %s, made by chatgpt-cli %s on %s\n", args[0], manifest.CLIVersion, manifest.Created.Local().Format("2006-01-02 15:04"))
+	return nil
+}
+
+// readBackupArchive reads and checks an archive made by export. Every file is
+// checked before anything is restored: its path must be one export writes,
+// inside the config directory, and listed in the manifest.
+func readBackupArchive(configDir, archive string) (*BackupManifest, []backupFile, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, nil, withKind(ErrUsage, fmt.Errorf("failed to open archive: %w", err))
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not a backup made by export: %w", archive, err)
+	}
+	tr := tar.NewReader(gz)
+
+	var manifest *BackupManifest
+	var files []backupFile
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", archive, err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("%s: %s is not a regular file", archive, header.Name)
+		}
+		if header.Size > maxBackupFileSize {
+			return nil, nil, fmt.Errorf("%s: %s is larger than %d MB", archive, header.Name, maxBackupFileSize>>20)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxBackupFileSize))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", archive, err)
+		}
+
+		if manifest == nil {
+			if header.Name != backupManifestName {
+				return nil, nil, fmt.Errorf("%s has no manifest; it was not made by export", archive)
+			}
+			manifest = &BackupManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("%s: invalid manifest: %w", archive, err)
+			}
+			if manifest.Format > backupFormat {
+				return nil, nil, fmt.Errorf("%s was made by chatgpt-cli %s in a newer archive format (%d, this version reads %d); upgrade to import it", archive, manifest.CLIVersion, manifest.Format, backupFormat)
+			}
+			continue
+		}
+
+		if _, err := backupTarget(configDir, header.Name); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", archive, err)
+		}
+		if !containsString(manifest.Files, header.Name) {
+			return nil, nil, fmt.Errorf("%s: %s is not listed in the manifest", archive, header.Name)
+		}
+		files = append(files, backupFile{Name: header.Name, Data: data})
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("%s has no manifest; it was not made by export", archive)
+	}
+	return manifest, files, nil
+}
+
+// backupTarget returns where a file of an archive is restored. Names that
+// could land outside configDir, such as ../x or /etc/x, and files export
+// never writes are refused.
+func backupTarget(configDir, name string) (string, error) {
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" ||
+		strings.Contains(name, `\`) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("unsafe path %q", name)
+	}
+
+	dir, base := path.Split(name)
+	switch {
+	case dir == "" && (base == configFileName || base == aliasesFileName || base == redactRulesFile || base == credentialsFileName):
+	case dir == stylesDirName+"/" && path.Ext(base) == styleFileExt && base != styleFileExt:
+	default:
+		return "", fmt.Errorf("unexpected file %q", name)
+	}
+
+	target := filepath.Join(configDir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(configDir, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("unsafe path %q", name)
+	}
+	return target, nil
+}
+
+// backupFileExists reports whether importing name would replace a file
+func backupFileExists(configDir, name string) bool {
+	if name == configFileName {
+		return configFileExists(configDir)
+	}
+	target, err := backupTarget(configDir, name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Lstat(target)
+	return err == nil
+}
+
+// restoreBackupFile writes a file of an archive, replacing any existing one.
+// The config file is parsed first so that a broken one is never restored.
+// Unless the archive holds the secrets, the API keys of the existing config
+// are kept, since the archive's config file was stripped of them.
+func restoreBackupFile(configDir string, file backupFile, secrets bool) error {
+	target, err := backupTarget(configDir, file.Name)
+	if err != nil {
+		return err
+	}
+
+	switch file.Name {
+	case configFileName:
+		doc, err := parseConfigDocument(file.Name, string(file.Data))
+		if err != nil {
+			return err
+		}
+		return updateConfigDocument(configDir, func(existing *configDocument) {
+			var kept []tomlLine
+			if !secrets {
+				for _, line := range existing.lines {
+					if !line.header && isSecretConfigKey(line.key) {
+						kept = append(kept, line)
+					}
+				}
+			}
+			existing.lines = doc.lines
+			for _, line := range kept {
+				key, _ := configKeyForTOML(line.key)
+				existing.setIn(line.table, key, line.value)
+			}
+		})
+	case aliasesFileName:
+		var aliases map[string]string
+		if err := json.Unmarshal(file.Data, &aliases); err != nil {
+			return fmt.Errorf("invalid aliases: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(target, file.Data, 0600)
+}
+
+// mergeBackupFile adds what an existing file lacks: the config values and
+// aliases not set yet. Other files are kept as they are. It returns what was
+// done, for the report.
+func mergeBackupFile(configDir string, file backupFile) (string, error) {
+	switch file.Name {
+	case configFileName:
+		doc, err := parseConfigDocument(file.Name, string(file.Data))
+		if err != nil {
+			return "", err
+		}
+		added := 0
+		err = updateConfigDocument(configDir, func(existing *configDocument) {
+			added = existing.mergeMissing(doc)
+		})
+		return fmt.Sprintf("merged, %d %s added", added, plural(added, "value", "values")), err
+
+	case aliasesFileName:
+		var imported map[string]string
+		if err := json.Unmarshal(file.Data, &imported); err != nil {
+			return "", fmt.Errorf("invalid aliases: %w", err)
+		}
+		aliases, err := loadAliases(configDir)
+		if err != nil {
+			return "", err
+		}
+		added := 0
+		for name, prompt := range imported {
+			if _, ok := aliases[name]; !ok {
+				aliases[name] = prompt
+				added++
+			}
+		}
+		if added > 0 {
+			if err := saveAliases(configDir, aliases); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("merged, %d %s added", added, plural(added, "alias", "aliases")), nil
+	}
+	return "kept, already exists", nil
+}
+
+// mergeMissing sets the values of the default section, the profiles, the
+// models and the default flags of imported that d doesn't have, and returns
+// how many were set
+func (d *configDocument) mergeMissing(imported *configDocument) int {
+	added := 0
+
+	sections := d.sections()
+	importedSections := imported.sections()
+	profiles := make([]string, 0, len(importedSections))
+	for profile := range importedSections {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+	for _, profile := range profiles {
+		d.ensureTable(profileTable(profile))
+		for _, key := range configFileKeys {
+			value := importedSections[profile][key]
+			if value != "" && sections[profile][key] == "" {
+				d.set(profile, key, value)
+				added++
+			}
+		}
+	}
+
+	models := d.modelSections()
+	importedModels := imported.modelSections()
+	names := make([]string, 0, len(importedModels))
+	for model := range importedModels {
+		names = append(names, model)
+	}
+	sort.Strings(names)
+	for _, model := range names {
+		for _, key := range modelScopedKeys {
+			value := importedModels[model][key]
+			if value != "" && models[model][key] == "" {
+				d.setIn(modelTable(model), key, value)
+				added++
+			}
+		}
+	}
+
+	defaults := d.defaultFlags()
+	importedDefaults := imported.defaultFlags()
+	commands := make([]string, 0, len(importedDefaults))
+	for command := range importedDefaults {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		if _, exists := defaults[command]; !exists {
+			d.setIn(defaultsTable, command, importedDefaults[command])
+			added++
+		}
+	}
+	return added
+}
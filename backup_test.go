@@ -0,0 +1,328 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBackupConfig = `# ChatGPT CLI Configuration
+api_key = "sk-personal"
+model = "gpt-4o-mini"
+
+[profile.work]
+api_key = "sk-work"
+model = "gpt-4"
+
+[models.gpt-4]
+max_tokens = 4000
+
+[defaults]
+prompt = "--usage"
+refine = "--passes 3"
+`
+
+// writeTestBackupDir fills configDir with a config file, aliases, a style
+// and the credentials file
+func writeTestBackupDir(t *testing.T, configDir string) {
+	t.Helper()
+
+	writeTestConfigFile(t, configDir, testBackupConfig)
+	if err := saveAliases(configDir, map[string]string{"fix": "Fix this code"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(configDir, stylesDirName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, stylesDirName, "pirate"+styleFileExt), []byte("Answer like a pirate."), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, credentialsFileName), []byte(`{"api_key":"sk-stored"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runBackupCommand runs export or import and returns its output
+func runBackupCommand(t *testing.T, handler func(*Config, []string) error, config *Config, args ...string) (string, error) {
+	t.Helper()
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = handler(config, args)
+	})
+	return out, err
+}
+
+// TestExportImport tests that an export restores into an empty config
+// directory, without the API keys unless --include-secrets is given
+func TestExportImport(t *testing.T) {
+	source := &Config{ConfigDir: t.TempDir()}
+	writeTestBackupDir(t, source.ConfigDir)
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+
+	out, err := runBackupCommand(t, exportCommand, source, "--out", archive)
+	if err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+	if !strings.Contains(out, "Exported 3 files") || !strings.Contains(out, "--include-secrets") {
+		t.Errorf("export output = %q", out)
+	}
+	if info, err := os.Stat(archive); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("archive mode = %v, %v; want 0600", info, err)
+	}
+
+	target := &Config{ConfigDir: filepath.Join(t.TempDir(), "new")}
+	out, err = runBackupCommand(t, importCommand, target, archive)
+	if err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	if !strings.Contains(out, "made by chatgpt-cli "+version) {
+		t.Errorf("import output = %q", out)
+	}
+
+	sections := loadTestConfigSections(t, target.ConfigDir)
+	if sections[""]["OPENAI_MODEL"] != "gpt-4o-mini" || sections["work"]["OPENAI_MODEL"] != "gpt-4" {
+		t.Errorf("imported sections = %v", sections)
+	}
+	if sections[""]["OPENAI_API_KEY"] != "" || sections["work"]["OPENAI_API_KEY"] != "" {
+		t.Errorf("imported sections = %v, want the API keys left out", sections)
+	}
+	if data, err := os.ReadFile(filepath.Join(target.ConfigDir, configFileName)); err != nil || !strings.Contains(string(data), "[models.gpt-4]\nmax_tokens = 4000") {
+		t.Errorf("imported config = %s, %v", data, err)
+	}
+	if aliases, err := loadAliases(target.ConfigDir); err != nil || aliases["fix"] != "Fix this code" {
+		t.Errorf("imported aliases = %v, %v", aliases, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(target.ConfigDir, stylesDirName, "pirate"+styleFileExt)); err != nil || string(data) != "Answer like a pirate." {
+		t.Errorf("imported style = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(target.ConfigDir, credentialsFileName)); !os.IsNotExist(err) {
+		t.Errorf("credentials file imported without --include-secrets: %v", err)
+	}
+
+	// With --include-secrets the keys and the credentials file come along
+	if _, err := runBackupCommand(t, exportCommand, source, "--out", archive, "--include-secrets"); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+	target.ConfigDir = t.TempDir()
+	if _, err := runBackupCommand(t, importCommand, target, archive); err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	sections = loadTestConfigSections(t, target.ConfigDir)
+	if sections[""]["OPENAI_API_KEY"] != "sk-personal" || sections["work"]["OPENAI_API_KEY"] != "sk-work" {
+		t.Errorf("imported sections = %v, want the API keys", sections)
+	}
+	if data, err := os.ReadFile(filepath.Join(target.ConfigDir, credentialsFileName)); err != nil || !strings.Contains(string(data), "sk-stored") {
+		t.Errorf("imported credentials = %q, %v", data, err)
+	}
+}
+
+// TestImportExistingFiles tests that import refuses to replace files unless
+// told to merge or overwrite them
+func TestImportExistingFiles(t *testing.T) {
+	source := &Config{ConfigDir: t.TempDir()}
+	writeTestBackupDir(t, source.ConfigDir)
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := runBackupCommand(t, exportCommand, source, "--out", archive); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+
+	const existingConfig = "model = \"gpt-4o\"\nmax_tokens = 100\n\n[defaults]\nprompt = \"--timing\"\n"
+	target := &Config{ConfigDir: t.TempDir()}
+	writeTestConfigFile(t, target.ConfigDir, existingConfig)
+	if err := saveAliases(target.ConfigDir, map[string]string{"fix": "Mine", "tldr": "Summarize"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := runBackupCommand(t, importCommand, target, archive)
+	if err == nil || !strings.Contains(err.Error(), "importing would replace config.toml, aliases.json") {
+		t.Fatalf("import error = %v, want a refusal naming the existing files", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target.ConfigDir, configFileName)); string(data) != existingConfig {
+		t.Errorf("config changed by a refused import: %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(target.ConfigDir, stylesDirName)); !os.IsNotExist(err) {
+		t.Errorf("styles restored by a refused import: %v", err)
+	}
+
+	if _, err := runBackupCommand(t, importCommand, target, "--merge", "--overwrite", archive); exitCode(err) != exitUsage {
+		t.Errorf("import --merge --overwrite error = %v, want a usage error", err)
+	}
+
+	// --merge keeps what exists and adds what is missing
+	out, err := runBackupCommand(t, importCommand, target, "--merge", archive)
+	if err != nil {
+		t.Fatalf("import --merge error = %v", err)
+	}
+	for _, want := range []string{"config.toml", "merged, 3 values added", "merged, 0 aliases added", "styles/pirate.txt", "restored"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("import --merge output = %q, want %q", out, want)
+		}
+	}
+	sections := loadTestConfigSections(t, target.ConfigDir)
+	if sections[""]["OPENAI_MODEL"] != "gpt-4o" || sections[""]["OPENAI_MAX_TOKENS"] != "100" || sections["work"]["OPENAI_MODEL"] != "gpt-4" {
+		t.Errorf("merged sections = %v", sections)
+	}
+	if defaults, _ := loadFlagDefaults(target.ConfigDir); defaults["prompt"] != "--timing" || defaults["refine"] != "--passes 3" {
+		t.Errorf("merged default flags = %v", defaults)
+	}
+	if aliases, _ := loadAliases(target.ConfigDir); aliases["fix"] != "Mine" || aliases["tldr"] != "Summarize" {
+		t.Errorf("merged aliases = %v", aliases)
+	}
+
+	// --overwrite replaces them with the archive's
+	if _, err := runBackupCommand(t, importCommand, target, "--overwrite", archive); err != nil {
+		t.Fatalf("import --overwrite error = %v", err)
+	}
+	sections = loadTestConfigSections(t, target.ConfigDir)
+	if sections[""]["OPENAI_MODEL"] != "gpt-4o-mini" || sections[""]["OPENAI_MAX_TOKENS"] != "" {
+		t.Errorf("overwritten sections = %v", sections)
+	}
+	if aliases, _ := loadAliases(target.ConfigDir); len(aliases) != 1 || aliases["fix"] != "Fix this code" {
+		t.Errorf("overwritten aliases = %v", aliases)
+	}
+}
+
+// TestImportOverwriteKeepsSecrets tests that overwriting the config with an
+// archive made without --include-secrets keeps the existing API keys
+func TestImportOverwriteKeepsSecrets(t *testing.T) {
+	source := &Config{ConfigDir: t.TempDir()}
+	writeTestBackupDir(t, source.ConfigDir)
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := runBackupCommand(t, exportCommand, source, "--out", archive); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+
+	target := &Config{ConfigDir: t.TempDir()}
+	err := updateConfigDocument(target.ConfigDir, func(doc *configDocument) {
+		doc.set("", envModel, "gpt-4o")
+		doc.set("", envAPIKey, "sk-mine")
+		doc.ensureTable(profileTable("work"))
+		doc.set("work", envAPIKey, "sk-mine-work")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runBackupCommand(t, importCommand, target, "--overwrite", archive)
+	if err != nil {
+		t.Fatalf("import --overwrite error = %v", err)
+	}
+	if !strings.Contains(out, "replaced, API keys kept") {
+		t.Errorf("import --overwrite output = %q", out)
+	}
+	sections := loadTestConfigSections(t, target.ConfigDir)
+	if sections[""]["OPENAI_MODEL"] != "gpt-4o-mini" {
+		t.Errorf("overwritten sections = %v, want the archive's model", sections)
+	}
+	if sections[""]["OPENAI_API_KEY"] != "sk-mine" || sections["work"]["OPENAI_API_KEY"] != "sk-mine-work" {
+		t.Errorf("overwritten sections = %v, want the existing API keys", sections)
+	}
+	if data, err := os.ReadFile(secretsFilePath(target.ConfigDir)); err != nil || !strings.Contains(string(data), "sk-mine") {
+		t.Errorf("credentials file = %q, %v; want the existing API keys", data, err)
+	}
+}
+
+// writeTestArchive writes an archive of the given entries after a manifest
+// listing files, bypassing the checks of export
+func writeTestArchive(t *testing.T, manifest BackupManifest, entries []*tar.Header) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := append([]*tar.Header{{Name: backupManifestName, Mode: 0600, Size: int64(len(data)), Typeflag: tar.TypeReg}}, entries...)
+	for i, header := range headers {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			tw.Write(data)
+		} else if header.Typeflag == tar.TypeReg {
+			tw.Write(bytes.Repeat([]byte("x"), int(header.Size)))
+		}
+	}
+	tw.Close()
+	gz.Close()
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := os.WriteFile(archive, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+// TestImportRejectsUnsafeArchives tests that entries that would land outside
+// the config directory, links, unlisted files and newer formats are refused
+// with nothing written
+func TestImportRejectsUnsafeArchives(t *testing.T) {
+	file := func(name string) *tar.Header {
+		return &tar.Header{Name: name, Mode: 0600, Size: 4, Typeflag: tar.TypeReg}
+	}
+	manifest := func(files ...string) BackupManifest {
+		return BackupManifest{Format: backupFormat, CLIVersion: "v1.0.0", Created: time.Now(), Files: files}
+	}
+
+	tests := []struct {
+		name     string
+		manifest BackupManifest
+		entries  []*tar.Header
+		want     string
+	}{
+		{"parent directory", manifest("../evil.txt"), []*tar.Header{file("../evil.txt")}, "unsafe path"},
+		{"absolute path", manifest("/tmp/evil.txt"), []*tar.Header{file("/tmp/evil.txt")}, "unsafe path"},
+		{"escaping styles", manifest("styles/../../evil.txt"), []*tar.Header{file("styles/../../evil.txt")}, "unsafe path"},
+		{"backslashes", manifest(`styles\..\..\evil.txt`), []*tar.Header{file(`styles\..\..\evil.txt`)}, "unsafe path"},
+		{"unexpected file", manifest("logs.jsonl"), []*tar.Header{file("logs.jsonl")}, "unexpected file"},
+		{"nested style", manifest("styles/a/b.txt"), []*tar.Header{file("styles/a/b.txt")}, "unexpected file"},
+		{"symlink", manifest("aliases.json"), []*tar.Header{{Name: "aliases.json", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}}, "not a regular file"},
+		{"unlisted file", manifest(), []*tar.Header{file("styles/x.txt")}, "not listed in the manifest"},
+		{"after a valid file", manifest("styles/ok.txt", "../evil.txt"), []*tar.Header{file("styles/ok.txt"), file("../evil.txt")}, "unsafe path"},
+		{"newer format", BackupManifest{Format: backupFormat + 1, CLIVersion: "v9.0.0"}, nil, "newer archive format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			config := &Config{ConfigDir: filepath.Join(root, "config")}
+			archive := writeTestArchive(t, tt.manifest, tt.entries)
+
+			_, err := runBackupCommand(t, importCommand, config, archive)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("import error = %v, want %q", err, tt.want)
+			}
+			entries, _ := os.ReadDir(root)
+			if len(entries) != 0 {
+				t.Errorf("import wrote %v, want nothing written", entries)
+			}
+		})
+	}
+}
+
+// TestBackupTarget tests where the files of an archive are restored
+func TestBackupTarget(t *testing.T) {
+	dir := t.TempDir()
+	for name, want := range map[string]string{
+		"config.toml":       filepath.Join(dir, "config.toml"),
+		"styles/pirate.txt": filepath.Join(dir, "styles", "pirate.txt"),
+	} {
+		if got, err := backupTarget(dir, name); err != nil || got != want {
+			t.Errorf("backupTarget(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"", ".", "..", "./config.toml", "styles/.txt", "styles//x.txt", "C:/config.toml", "styles"} {
+		if got, err := backupTarget(dir, name); err == nil {
+			t.Errorf("backupTarget(%q) = %q, want an error", name, got)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default number of prompts sent in parallel by the batch command
+const defaultBatchConcurrency = 4
+
+// BatchResult is the outcome of a single batch prompt, written as one JSON line
+type BatchResult struct {
+	Prompt   string `json:"prompt"`
+	Response string `json:"response,omitempty"`
+	Usage    *Usage `json:"usage,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// batchCommand sends every prompt in a file and writes the results as JSONL
+func batchCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	concurrency := fs.Int("concurrency", defaultBatchConcurrency, "number of prompts sent in parallel")
+	outFile := fs.String("out", "", "write results to this file instead of stdout")
+	noWait := fs.Bool("no-wait", false, "fail prompts instead of waiting when OPENAI_REQUESTS_PER_MINUTE is reached")
+	failFast := fs.Bool("fail-fast", false, "stop sending prompts after the first one fails")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli batch [--concurrency N] [--out results.jsonl] [--no-wait] [--fail-fast] <file>", err)
+	}
+
+	if len(args) != 1 {
+		return usageErrorf("prompt file is required\nUsage: chatgpt-cli batch [--concurrency N] [--out results.jsonl] [--no-wait] [--fail-fast] <file>")
+	}
+	if *concurrency < 1 {
+		return usageErrorf("--concurrency must be a positive integer")
+	}
+	config.NoWait = *noWait
+
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	prompts, err := readPrompts(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read prompts: %w", err)
+	}
+	if len(prompts) == 0 {
+		return fmt.Errorf("no prompts found in %s", args[0])
+	}
+
+	var out io.Writer = os.Stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	summary, err := runBatch(config, prompts, batchOptions{
+		concurrency: *concurrency,
+		failFast:    *failFast,
+		progress:    newBatchProgress(config, len(prompts)),
+	}, out)
+	if err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+
+	if *outFile != "" {
+		fmt.Printf("Wrote %d results to %s\n", summary.sent, *outFile)
+	}
+	if config.requestContext().Err() != nil {
+		fmt.Fprintf(os.Stderr, "Interrupted after sending %d of %d prompts\n", summary.sent, len(prompts))
+		return errCancelled
+	}
+	if summary.sent < len(prompts) {
+		return fmt.Errorf("%d of %d prompts failed; --fail-fast left %d unsent", summary.failed, len(prompts), len(prompts)-summary.sent)
+	}
+	if summary.failed > 0 {
+		return fmt.Errorf("%d of %d prompts failed", summary.failed, len(prompts))
+	}
+
+	return nil
+}
+
+// readPrompts reads one prompt per line, skipping blank lines and # comments
+func readPrompts(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var prompts []string
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prompts = append(prompts, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return prompts, nil
+}
+
+// batchOptions are the settings of a batch run
+type batchOptions struct {
+	// concurrency is the number of prompts sent in parallel
+	concurrency int
+	// failFast stops sending prompts after the first one fails
+	failFast bool
+	// progress counts the prompts as they complete; nil for none
+	progress *batchProgress
+}
+
+// batchSummary counts the prompts of a batch run
+type batchSummary struct {
+	sent   int
+	failed int
+}
+
+// runBatch sends prompts using a pool of workers and writes one JSON line per
+// prompt to out, in input order. Prompts are sent until they are all done,
+// the user interrupts the batch or, with failFast, one fails; the prompts in
+// flight then finish and their results are written, the others are not sent.
+func runBatch(config *Config, prompts []string, options batchOptions, out io.Writer) (batchSummary, error) {
+	type indexedResult struct {
+		index  int
+		result BatchResult
+	}
+
+	// Sending stops when the user interrupts the batch, but the requests in
+	// flight go on
+	schedule, stop := context.WithCancel(config.requestContext())
+	defer stop()
+	ctx := withoutCancel(config.requestContext())
+
+	// The workers share one client, so prompts reuse connections
+	client := newAPIClient(config)
+	jobs := make(chan int)
+	results := make(chan indexedResult)
+
+	var wg sync.WaitGroup
+	for w := 0; w < options.concurrency && w < len(prompts); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// A job taken as sending stops is not sent
+				if schedule.Err() != nil {
+					continue
+				}
+				result := runBatchPrompt(ctx, client, prompts[i])
+				if result.Error != "" && options.failFast {
+					stop()
+				}
+				results <- indexedResult{index: i, result: result}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range prompts {
+			if schedule.Err() != nil {
+				break
+			}
+			select {
+			case jobs <- i:
+			case <-schedule.Done():
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	progress := options.progress
+	if progress == nil {
+		progress = &batchProgress{total: len(prompts), start: time.Now(), now: time.Now}
+	}
+	progress.begin()
+	defer progress.finish()
+
+	// Buffer out-of-order results and flush them as soon as the next one is ready
+	enc := json.NewEncoder(out)
+	pending := make(map[int]BatchResult)
+	next := 0
+
+	var writeErr error
+	for r := range results {
+		progress.add(r.result)
+		pending[r.index] = r.result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if writeErr == nil {
+				writeErr = enc.Encode(result)
+			}
+		}
+	}
+
+	// Once sending stopped, results may follow a prompt that was not sent
+	for ; next < len(prompts); next++ {
+		if result, ok := pending[next]; ok && writeErr == nil {
+			writeErr = enc.Encode(result)
+		}
+	}
+
+	return batchSummary{sent: progress.done, failed: progress.failed}, writeErr
+}
+
+// runBatchPrompt sends a single prompt and logs the interaction. Failures
+// have been retried by the client, as for any request, before they are
+// returned.
+func runBatchPrompt(ctx context.Context, client *APIClient, prompt string) BatchResult {
+	config := client.config
+	result := BatchResult{Prompt: prompt}
+
+	start := time.Now()
+	response, err := client.Chat(ctx, promptMessages(config, prompt))
+	if err != nil {
+		result.Error = err.Error()
+		if isCancelled(err) {
+			result.Error = cancelledLogMessage
+		}
+		warnLogError(config, writeLogEntry(config, LogEntry{
+			Timestamp: time.Now(),
+			Command:   "batch",
+			Prompt:    prompt,
+			Error:     result.Error,
+			RequestID: errorRequestID(err),
+		}))
+		return result
+	}
+
+	result.Response = formatResponse(response)
+	result.Usage = usageOrNil(response.Usage)
+
+	warnLogError(config, writeLogEntry(config, LogEntry{
+		Timestamp: time.Now(),
+		Command:   "batch",
+		Prompt:    prompt,
+		Response:  result.Response,
+		Usage:     result.Usage,
+		Model:     responseModel(config, response),
+		LatencyMs: time.Since(start).Milliseconds(),
+		RequestID: response.RequestID,
+	}))
+
+	return result
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadPrompts tests reading prompts while skipping blanks and comments
+func TestReadPrompts(t *testing.T) {
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompts.txt")
+
+	content := "# classification prompts\nfirst prompt\n\n   \n  second prompt  \n# ignored\nthird prompt"
+	if err := os.WriteFile(promptFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write prompt file: %v", err)
+	}
+
+	prompts, err := readPrompts(promptFile)
+	if err != nil {
+		t.Fatalf("readPrompts() error = %v", err)
+	}
+
+	expected := []string{"first prompt", "second prompt", "third prompt"}
+	if strings.Join(prompts, "|") != strings.Join(expected, "|") {
+		t.Errorf("readPrompts() = %q, want %q", prompts, expected)
+	}
+
+	if _, err := readPrompts(filepath.Join(tmpDir, "missing.txt")); err == nil {
+		t.Errorf("readPrompts() with missing file expected error, got nil")
+	}
+}
+
+// newBatchServer echoes prompts back, answering later prompts faster so
+// results complete out of order, and fails any prompt containing "fail"
+func newBatchServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		prompt := request.Messages[0].Content
+
+		if strings.Contains(prompt, "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if strings.HasPrefix(prompt, "slow") {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			Choices: []Choice{{Message: Message{Content: "echo: " + prompt}}},
+			Usage:   Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2},
+		})
+	}))
+}
+
+// newDelayedServer echoes prompts back after delay, failing any prompt
+// containing "fail" at once
+func newDelayedServer(t *testing.T, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		prompt := request.Messages[0].Content
+
+		if strings.Contains(prompt, "fail") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		time.Sleep(delay)
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			Choices: []Choice{{Message: Message{Content: "echo: " + prompt}}},
+			Usage:   Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2},
+		})
+	}))
+}
+
+// TestRunBatch tests ordering, error isolation and logging of batch prompts
+func TestRunBatch(t *testing.T) {
+	server := newBatchServer(t)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{
+		APIKey:    "test-key",
+		APIURL:    server.URL,
+		Model:     "gpt-3.5-turbo",
+		Timeout:   10 * time.Second,
+		ConfigDir: tmpDir,
+	}
+
+	prompts := []string{"slow one", "slow two", "please fail", "four", "five"}
+
+	var out strings.Builder
+	summary, err := runBatch(config, prompts, batchOptions{concurrency: 3}, &out)
+	if err != nil {
+		t.Fatalf("runBatch() error = %v", err)
+	}
+	if summary.failed != 1 || summary.sent != len(prompts) {
+		t.Errorf("summary = %+v, want 1 failed of %d sent", summary, len(prompts))
+	}
+
+	var results []BatchResult
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		var result BatchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("invalid result line %q: %v", scanner.Text(), err)
+		}
+		results = append(results, result)
+	}
+
+	if len(results) != len(prompts) {
+		t.Fatalf("got %d results, want %d", len(results), len(prompts))
+	}
+
+	for i, result := range results {
+		if result.Prompt != prompts[i] {
+			t.Errorf("results[%d].Prompt = %q, want %q", i, result.Prompt, prompts[i])
+		}
+	}
+
+	if results[2].Error == "" {
+		t.Errorf("results[2].Error is empty, want failure")
+	}
+	if results[4].Response != "echo: five" {
+		t.Errorf("results[4].Response = %q, want %q", results[4].Response, "echo: five")
+	}
+	if results[4].Usage == nil || results[4].Usage.TotalTokens != 2 {
+		t.Errorf("results[4].Usage = %v, want TotalTokens 2", results[4].Usage)
+	}
+
+	// Every prompt is logged, including the failure
+	data, err := os.ReadFile(filepath.Join(tmpDir, "logs.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != len(prompts) {
+		t.Errorf("expected %d log entries, got %d", len(prompts), len(lines))
+	}
+}
+
+// TestBatchCommand tests argument handling of the batch command
+func TestBatchCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := newBatchServer(t)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{
+		APIKey:    "test-key",
+		APIURL:    server.URL,
+		Model:     "gpt-3.5-turbo",
+		Timeout:   10 * time.Second,
+		ConfigDir: tmpDir,
+	}
+
+	okFile := filepath.Join(tmpDir, "ok.txt")
+	if err := os.WriteFile(okFile, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatalf("failed to write prompt file: %v", err)
+	}
+	failFile := filepath.Join(tmpDir, "fail.txt")
+	if err := os.WriteFile(failFile, []byte("one\nfail\n"), 0644); err != nil {
+		t.Fatalf("failed to write prompt file: %v", err)
+	}
+	emptyFile := filepath.Join(tmpDir, "empty.txt")
+	if err := os.WriteFile(emptyFile, []byte("# nothing here\n"), 0644); err != nil {
+		t.Fatalf("failed to write prompt file: %v", err)
+	}
+	outFile := filepath.Join(tmpDir, "results.jsonl")
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:    "write results to file",
+			args:    []string{okFile, "--out", outFile, "--concurrency", "2"},
+			wantErr: false,
+		},
+		{
+			name:        "failed prompts are reported",
+			args:        []string{failFile, "--out", outFile},
+			wantErr:     true,
+			errContains: "1 of 2 prompts failed",
+		},
+		{
+			name:        "no file",
+			args:        []string{},
+			wantErr:     true,
+			errContains: "prompt file is required",
+		},
+		{
+			name:        "invalid concurrency",
+			args:        []string{okFile, "--concurrency", "0"},
+			wantErr:     true,
+			errContains: "--concurrency must be a positive integer",
+		},
+		{
+			name:        "missing file",
+			args:        []string{filepath.Join(tmpDir, "missing.txt")},
+			wantErr:     true,
+			errContains: "failed to read prompts",
+		},
+		{
+			name:        "no prompts",
+			args:        []string{emptyFile},
+			wantErr:     true,
+			errContains: "no prompts found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := batchCommand(config, tt.args)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("batchCommand() expected error, got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("batchCommand() unexpected error: %v", err)
+				}
+			}
+		})
+	}
+
+	// The failing run overwrote the output file with both results
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("failed to read output file: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 {
+		t.Errorf("output file has %d lines, want 2", len(lines))
+	}
+}
+
+// TestRunBatchFailFast tests that --fail-fast stops sending prompts after
+// the first failure, while the prompt in flight finishes
+func TestRunBatchFailFast(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := newDelayedServer(t, 100*time.Millisecond)
+	defer server.Close()
+
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: t.TempDir()}
+	prompts := []string{"please fail", "in flight", "three", "four", "five"}
+
+	var out strings.Builder
+	summary, err := runBatch(config, prompts, batchOptions{concurrency: 2, failFast: true}, &out)
+	if err != nil {
+		t.Fatalf("runBatch() error = %v", err)
+	}
+	if summary.sent != 2 || summary.failed != 1 {
+		t.Errorf("summary = %+v, want 2 sent and 1 failed", summary)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"error"`) || !strings.Contains(lines[1], "echo: in flight") {
+		t.Errorf("results = %q, want the failure and the prompt in flight", lines)
+	}
+
+	promptFile := filepath.Join(t.TempDir(), "prompts.txt")
+	os.WriteFile(promptFile, []byte(strings.Join(prompts, "\n")), 0644)
+	captureOutput(t, &os.Stdout, func() {
+		err = batchCommand(config, []string{"--concurrency", "1", "--fail-fast", promptFile})
+	})
+	if err == nil || !strings.Contains(err.Error(), "1 of 5 prompts failed; --fail-fast left 4 unsent") {
+		t.Errorf("batchCommand(--fail-fast) error = %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// How often batch writes a progress line when stderr is not a terminal
+const batchProgressInterval = 10 * time.Second
+
+// batchProgress counts the prompts of a batch as they complete and reports
+// them on stderr, as "37/200 done, 2 failed, 1.2k tokens, ETA 02:10". On a
+// terminal the line is redrawn in place after each prompt; otherwise a line
+// is written every batchProgressInterval, whether or not prompts complete.
+// Either way a last line is written when the batch ends, even when
+// interrupted.
+type batchProgress struct {
+	// mu guards the counts, updated as results arrive while the periodic
+	// line reads them
+	mu     sync.Mutex
+	total  int
+	done   int
+	failed int
+	tokens int
+	start  time.Time
+	// now is replaced by tests with a fake clock
+	now func() time.Time
+	// out receives the progress, nil for none
+	out *ui
+	// ticks triggers the periodic line when out is not a terminal; tests
+	// set it, otherwise begin ticks every batchProgressInterval
+	ticks <-chan time.Time
+	// stop ends the goroutine writing the periodic line, which closes
+	// stopped once it is done
+	stop, stopped chan struct{}
+}
+
+// newBatchProgress returns the progress of a batch of total prompts, shown
+// on stderr. The line is not redrawn in place while debug output is printed
+// there.
+func newBatchProgress(config *Config, total int) *batchProgress {
+	out := newUI(config, os.Stderr)
+	out.tty = out.tty && config.Debug == 0
+	return &batchProgress{total: total, start: time.Now(), now: time.Now, out: out}
+}
+
+// begin starts writing the periodic line when out is not a terminal
+func (p *batchProgress) begin() {
+	if p.out == nil || p.out.tty {
+		return
+	}
+	ticks := p.ticks
+	var ticker *time.Ticker
+	if ticks == nil {
+		ticker = time.NewTicker(batchProgressInterval)
+		ticks = ticker.C
+	}
+
+	p.stop, p.stopped = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(p.stopped)
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		for {
+			select {
+			case <-ticks:
+				p.mu.Lock()
+				p.out.Println(p.line())
+				p.mu.Unlock()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// add counts a completed prompt and redraws the line on a terminal
+func (p *batchProgress) add(result BatchResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	if result.Error != "" {
+		p.failed++
+	}
+	if result.Usage != nil {
+		p.tokens += result.Usage.TotalTokens
+	}
+	if p.out != nil && p.out.tty {
+		p.out.Printf("\r\033[K%s", p.out.dim(p.line()))
+	}
+}
+
+// finish stops the periodic line and writes the last one, in place of the
+// redrawn line on a terminal
+func (p *batchProgress) finish() {
+	if p.stop != nil {
+		close(p.stop)
+		<-p.stopped
+		p.stop = nil
+	}
+	if p.out == nil {
+		return
+	}
+	if p.out.tty {
+		p.out.Printf("\r\033[K")
+	}
+	p.out.Println(p.line())
+}
+
+// line describes the progress
+func (p *batchProgress) line() string {
+	return fmt.Sprintf("%d/%d done, %d failed, %s tokens, ETA %s",
+		p.done, p.total, p.failed, formatThousands(p.tokens), p.eta())
+}
+
+// eta estimates the time left from the average time per prompt so far
+func (p *batchProgress) eta() string {
+	if p.done == 0 {
+		return "--:--"
+	}
+	elapsed := p.now().Sub(p.start)
+	left := elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)
+	return formatClock(left)
+}
+
+// formatClock formats a duration as mm:ss, or h:mm:ss from an hour
+func formatClock(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
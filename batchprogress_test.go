@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBatchProgressTerminal tests the line redrawn on a terminal after each
+// prompt, with the ETA from the average time per prompt so far
+func TestBatchProgressTerminal(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	var out strings.Builder
+	progress := &batchProgress{total: 200, start: clock.now, now: clock.Now, out: &ui{w: &out, tty: true}}
+
+	if got := progress.line(); got != "0/200 done, 0 failed, 0 tokens, ETA --:--" {
+		t.Errorf("line() = %q before any prompt", got)
+	}
+
+	for i := 0; i < 37; i++ {
+		clock.now = clock.now.Add(time.Second)
+		result := BatchResult{Usage: &Usage{TotalTokens: 33}}
+		if i < 2 {
+			result = BatchResult{Error: "boom"}
+		}
+		progress.add(result)
+	}
+	// 37s for 37 prompts leaves 163s for the other 163
+	want := "37/200 done, 2 failed, 1.2k tokens, ETA 02:43"
+	if got := progress.line(); got != want {
+		t.Errorf("line() = %q, want %q", got, want)
+	}
+	if !strings.HasSuffix(out.String(), "\r\033[K"+want) || strings.Contains(out.String(), "\n") {
+		t.Errorf("terminal output = %q, want the line redrawn in place", out.String())
+	}
+
+	progress.finish()
+	if !strings.HasSuffix(out.String(), want+"\r\033[K"+want+"\n") {
+		t.Errorf("output after finish = %q, want the last line in place of the redrawn one", out.String())
+	}
+}
+
+// notifyWriter collects what is written, sending each write on lines too
+type notifyWriter struct {
+	strings.Builder
+	lines chan string
+}
+
+func (w *notifyWriter) Write(p []byte) (int, error) {
+	w.lines <- string(p)
+	return w.Builder.Write(p)
+}
+
+// TestBatchProgressPlain tests the lines written on each tick when stderr is
+// not a terminal, even while no prompt completes, and the last line
+func TestBatchProgressPlain(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	out := &notifyWriter{lines: make(chan string, 10)}
+	ticks := make(chan time.Time)
+	progress := &batchProgress{total: 4, start: clock.now, now: clock.Now, out: &ui{w: out}, ticks: ticks}
+	progress.begin()
+
+	// A stalled batch still reports
+	ticks <- clock.now
+	if line := <-out.lines; line != "0/4 done, 0 failed, 0 tokens, ETA --:--\n" {
+		t.Errorf("line before any result = %q", line)
+	}
+	for _, step := range []time.Duration{10 * time.Second, 2 * time.Second, 9 * time.Second} {
+		clock.now = clock.now.Add(step)
+		progress.add(BatchResult{Usage: &Usage{TotalTokens: 5}})
+	}
+	if len(out.lines) != 0 {
+		t.Errorf("%q written by the results, want only ticks to write", <-out.lines)
+	}
+	ticks <- clock.now
+	<-out.lines
+	clock.now = clock.now.Add(time.Second)
+	progress.add(BatchResult{Usage: &Usage{TotalTokens: 5}})
+	progress.finish()
+
+	want := "0/4 done, 0 failed, 0 tokens, ETA --:--\n" +
+		"3/4 done, 0 failed, 15 tokens, ETA 00:07\n" +
+		"4/4 done, 0 failed, 20 tokens, ETA 00:00\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+// TestBatchProgressInterrupted tests that a batch ending before all prompts
+// are done still writes its last line
+func TestBatchProgressInterrupted(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	var out strings.Builder
+	progress := &batchProgress{total: 10, start: clock.now, now: clock.Now, out: &ui{w: &out}, ticks: make(chan time.Time)}
+	progress.begin()
+
+	clock.now = clock.now.Add(2 * time.Second)
+	progress.add(BatchResult{Error: "boom"})
+	progress.finish()
+
+	if want := "1/10 done, 1 failed, 0 tokens, ETA 00:18\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+// TestFormatClock tests the ETA format
+func TestFormatClock(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                                       "00:00",
+		130 * time.Second:                       "02:10",
+		59*time.Minute + 59500*time.Millisecond: "1:00:00",
+		2*time.Hour + 5*time.Second:             "2:00:05",
+	}
+	for d, want := range tests {
+		if got := formatClock(d); got != want {
+			t.Errorf("formatClock(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
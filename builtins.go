@@ -0,0 +1,236 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Lengths of summarize --length
+const (
+	summaryShort = "short"
+	summaryLong  = "long"
+)
+
+// Values accepted by summarize --length
+var summaryLengths = []string{summaryShort, summaryLong}
+
+// Languages named by their ISO 639-1 code in translate --to
+var languageNames = map[string]string{
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"zh": "Chinese",
+}
+
+// builtinCommand is a prompt with instructions of its own, run as a command.
+// The text comes from the arguments or stdin and is sent after the system
+// prompt that Instructions builds from the command's flags.
+type builtinCommand struct {
+	Name        string
+	Description string
+	Usage       string
+	// Instructions defines the command's flags on fs and returns the
+	// function building the system prompt once they are parsed
+	Instructions func(fs *flag.FlagSet) func() (string, error)
+}
+
+// builtinCommands are the commands registered alongside the others
+var builtinCommands = []builtinCommand{
+	{
+		Name:        "summarize",
+		Description: "Summarize a text",
+		Usage:       "chatgpt-cli summarize [--length short|long] <text> (or: < file)",
+		Instructions: func(fs *flag.FlagSet) func() (string, error) {
+			length := fs.String("length", summaryShort, "summary length: short or long")
+			return func() (string, error) {
+				switch *length {
+				case summaryShort:
+					return "Summarize the text you are given in three to five sentences. Keep the key facts, names and numbers, and add nothing that is not in the text. Reply with the summary only.", nil
+				case summaryLong:
+					return "Summarize the text you are given in a few paragraphs, followed by a bulleted list of its key points. Keep the facts, names and numbers that matter, and add nothing that is not in the text. Reply with the summary only.", nil
+				}
+				return "", usageErrorf("--length must be one of: %s", strings.Join(summaryLengths, ", "))
+			}
+		},
+	},
+	{
+		Name:        "translate",
+		Description: "Translate a text into another language",
+		Usage:       "chatgpt-cli translate --to <language> <text> (or: < file)",
+		Instructions: func(fs *flag.FlagSet) func() (string, error) {
+			to := fs.String("to", "", "language to translate into, by name or code such as it")
+			return func() (string, error) {
+				language := strings.TrimSpace(*to)
+				if language == "" {
+					return "", usageErrorf("--to is required, e.g. --to it or --to Italian")
+				}
+				if name, ok := languageNames[strings.ToLower(language)]; ok {
+					language = name
+				}
+				return "Translate the text you are given into " + language + ". Keep its meaning, tone and formatting; leave code, commands and markdown syntax untranslated. Reply with the translation only.", nil
+			}
+		},
+	},
+	{
+		Name:        "commitmsg",
+		Description: "Write a commit message for staged changes",
+		Usage:       "git diff --cached | chatgpt-cli commitmsg [--no-body]",
+		Instructions: func(fs *flag.FlagSet) func() (string, error) {
+			noBody := fs.Bool("no-body", false, "write the subject line only")
+			return func() (string, error) {
+				instructions := "You write git commit messages following the Conventional Commits specification. You are given the output of git diff --cached. Reply with the commit message only, not in a code block. Its first line is type(scope): summary, where type is one of feat, fix, docs, style, refactor, perf, test, build, ci or chore, the scope is optional, and the summary is in the imperative mood, lower case and under 72 characters."
+				if *noBody {
+					return instructions + " Write that line only.", nil
+				}
+				return instructions + " If the change needs explaining, add a blank line and a body wrapped at 72 characters that says what changed and why.", nil
+			}
+		},
+	},
+}
+
+// run sends the text with the command's instructions and prints the reply
+// as prompt does, logging it under the command's name
+func (b builtinCommand) run(config *Config, args []string) error {
+	fs := flag.NewFlagSet(b.Name, flag.ContinueOnError)
+	noStream := fs.Bool("no-stream", false, "wait for the full response instead of streaming it")
+	showUsage := fs.Bool("usage", config.ShowUsage, "print token usage and estimated cost")
+	raw := fs.Bool("raw", false, "print the response as-is instead of rendering markdown")
+	dryRun := fs.Bool("dry-run", false, "print the request instead of sending it")
+	instructions := b.Instructions(fs)
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\nUsage: %s", err, b.Usage)
+	}
+	if config.SystemPrompt, err = instructions(); err != nil {
+		return err
+	}
+
+	// Without arguments, or with a lone "-", the text is read from stdin
+	text := strings.Join(args, " ")
+	if len(args) == 0 || (len(args) == 1 && args[0] == "-") {
+		if f, ok := stdin.(*os.File); ok && isTerminal(f) {
+			return usageErrorf("text is required as arguments or on stdin\nUsage: %s", b.Usage)
+		}
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		return usageErrorf("%s needs a text, but it is empty\nUsage: %s", b.Name, b.Usage)
+	}
+
+	stream := config.Stream && !*noStream && config.Output != outputJSON
+	if *dryRun {
+		req, err := newChatRequest(config.requestContext(), config, text, stream)
+		if err != nil {
+			return err
+		}
+		return printDryRun(config, req)
+	}
+
+	return sendAndPrint(config, chatPrompt{Command: b.Name, Text: text, Stream: stream, Raw: *raw, ShowUsage: *showUsage})
+}
+
+// chatPrompt is a single prompt sent and printed by sendAndPrint
+type chatPrompt struct {
+	// Command names the prompt's log entry
+	Command string
+	// Text is sent as the user message, and logged unless Logged is set
+	Text      string
+	Logged    string
+	Stream    bool
+	Raw       bool
+	ShowUsage bool
+	// JSON wraps the output of --output json, printed as it is when nil
+	JSON func(PromptOutput) any
+}
+
+// sendAndPrint sends a single prompt with the configured system prompt,
+// logs it, and prints the reply as prompt does: streamed or all at once,
+// rendered when stdout is a terminal, or as JSON
+func sendAndPrint(config *Config, p chatPrompt) error {
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+	logged := p.Logged
+	if logged == "" {
+		logged = p.Text
+	}
+
+	// Render markdown only for humans: never when piped, raw or JSON
+	render := !p.Raw && config.Output != outputJSON && isTerminal(os.Stdout)
+
+	client := newAPIClient(config)
+	var response *ChatResponse
+	var err error
+	start := time.Now()
+	if p.Stream {
+		var out io.Writer = os.Stdout
+		if render {
+			md := newMarkdownWriter(os.Stdout, colorEnabled(config))
+			defer md.Flush()
+			out = md
+		}
+		response, err = client.ChatStream(config.requestContext(), promptMessages(config, p.Text), out)
+	} else {
+		stopProgress := startProgress(config)
+		response, err = client.Chat(config.requestContext(), promptMessages(config, p.Text))
+		stopProgress()
+	}
+	if isCancelled(err) {
+		if p.Stream {
+			// End the partially streamed line before the cancellation notice
+			fmt.Println()
+		}
+		warnLogError(config, logEntry(config, p.Command, logged, "", cancelledLogMessage))
+		return errCancelled
+	}
+	if err != nil {
+		warnLogError(config, writeLogEntry(config, LogEntry{Timestamp: time.Now(), Command: p.Command, Prompt: logged, Error: err.Error(), RequestID: errorRequestID(err)}))
+		return fmt.Errorf("failed to get response: %w", err)
+	}
+
+	content := formatResponse(response)
+	warnLogError(config, writeLogEntry(config, LogEntry{
+		Timestamp: time.Now(),
+		Command:   p.Command,
+		Prompt:    logged,
+		Response:  content,
+		Usage:     usageOrNil(response.Usage),
+		Model:     responseModel(config, response),
+		LatencyMs: time.Since(start).Milliseconds(),
+		RequestID: response.RequestID,
+	}))
+
+	switch {
+	case config.Output == outputJSON:
+		output := newPromptOutput(response, content)
+		if p.JSON != nil {
+			return printJSON(p.JSON(output))
+		}
+		return printJSON(output)
+	case !p.Stream && render:
+		fmt.Println(renderMarkdown(content, colorEnabled(config)))
+	case !p.Stream:
+		fmt.Println(content)
+	}
+
+	if p.ShowUsage {
+		fmt.Fprintln(os.Stderr, formatResponseUsage(config, response))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBuiltinCommands tests the messages each built-in sends, and that it
+// is logged under its own name
+func TestBuiltinCommands(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	var request ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = ChatRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"done"}}]}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		command    string
+		args       []string
+		stdin      string
+		wantSystem []string
+		wantUser   string
+	}{
+		{"summarize short", "summarize", nil, "A long article.", []string{"three to five sentences"}, "A long article."},
+		{"summarize long", "summarize", []string{"--length", "long", "-"}, "A long article.", []string{"few paragraphs", "bulleted list"}, "A long article."},
+		{"translate by code", "translate", []string{"--to", "it", "Good", "morning"}, "", []string{"into Italian."}, "Good morning"},
+		{"translate by name", "translate", []string{"--to", "Klingon", "Hello"}, "", []string{"into Klingon."}, "Hello"},
+		{"commitmsg", "commitmsg", nil, "diff --git a/x b/x\n+fix\n", []string{"Conventional Commits", "git diff --cached", "body wrapped at 72"}, "diff --git a/x b/x\n+fix\n"},
+		{"commitmsg subject only", "commitmsg", []string{"--no-body"}, "diff --git a/x b/x\n", []string{"Write that line only."}, "diff --git a/x b/x\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				APIKey:    "sk-test",
+				APIURL:    server.URL,
+				Model:     "gpt-4o",
+				Timeout:   5 * time.Second,
+				ConfigDir: t.TempDir(),
+			}
+			stdin = strings.NewReader(tt.stdin)
+
+			out := captureOutput(t, &os.Stdout, func() {
+				if err := getCommands()[tt.command].Handler(config, tt.args); err != nil {
+					t.Fatalf("%s error = %v", tt.command, err)
+				}
+			})
+			if out != "done\n" {
+				t.Errorf("output = %q", out)
+			}
+
+			if len(request.Messages) != 2 || request.Messages[0].Role != "system" || request.Messages[1].Role != "user" {
+				t.Fatalf("messages = %+v, want a system and a user message", request.Messages)
+			}
+			for _, want := range tt.wantSystem {
+				if !strings.Contains(request.Messages[0].Content, want) {
+					t.Errorf("system prompt = %q, want it to contain %q", request.Messages[0].Content, want)
+				}
+			}
+			if request.Messages[1].Content != tt.wantUser {
+				t.Errorf("user message = %q, want %q", request.Messages[1].Content, tt.wantUser)
+			}
+
+			entries := readTestLogEntries(t, config.ConfigDir)
+			if len(entries) != 1 || entries[0].Command != tt.command || entries[0].Response != "done" {
+				t.Errorf("logged %+v, want one %s entry", entries, tt.command)
+			}
+		})
+	}
+}
+
+// TestSendAndPrint tests the output of --output json, which holds the usage
+// instead of printing it, and the prompt logged in place of the one sent
+func TestSendAndPrint(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"done"}}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`))
+	}))
+	defer server.Close()
+
+	config := &Config{APIKey: "sk-test", APIURL: server.URL, Model: "gpt-4o", Timeout: 5 * time.Second, ConfigDir: t.TempDir(), Output: outputJSON}
+	var stdout string
+	stderr := captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, func() {
+			err := sendAndPrint(config, chatPrompt{
+				Command:   "test",
+				Text:      "the whole prompt",
+				Logged:    "a summary",
+				ShowUsage: true,
+				JSON:      func(output PromptOutput) any { return map[string]any{"wrapped": output} },
+			})
+			if err != nil {
+				t.Fatalf("sendAndPrint() error = %v", err)
+			}
+		})
+	})
+
+	var got struct{ Wrapped PromptOutput }
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil || got.Wrapped.Content != "done" || got.Wrapped.Usage.TotalTokens != 4 {
+		t.Errorf("stdout = %q, want the wrapped JSON output", stdout)
+	}
+	if stderr != "" {
+		t.Errorf("stderr = %q, want the usage left to the JSON output", stderr)
+	}
+	entries := readTestLogEntries(t, config.ConfigDir)
+	if len(entries) != 1 || entries[0].Command != "test" || entries[0].Prompt != "a summary" {
+		t.Errorf("logged %+v, want the logged prompt", entries)
+	}
+}
+
+// TestBuiltinCommandUsage tests the arguments refused by the built-ins
+func TestBuiltinCommandUsage(t *testing.T) {
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	config := &Config{APIKey: "sk-test", Model: "gpt-4o", ConfigDir: t.TempDir()}
+	tests := []struct {
+		command string
+		args    []string
+	}{
+		{"summarize", []string{"--length", "medium", "text"}},
+		{"translate", []string{"Hello"}},
+		{"commitmsg", []string{"--subject"}},
+		{"commitmsg", nil},
+	}
+
+	for _, tt := range tests {
+		stdin = strings.NewReader(" \n")
+		if err := getCommands()[tt.command].Handler(config, tt.args); !errors.Is(err, ErrUsage) {
+			t.Errorf("%s %q error = %v, want a usage error", tt.command, tt.args, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// errCancelled is returned by commands interrupted by the user
+var errCancelled = errors.New("request cancelled")
+
+// Error recorded in the log for requests interrupted by the user
+const cancelledLogMessage = "cancelled by user"
+
+// Exit status for commands interrupted by the user, as for a shell's SIGINT
+const exitCancelled = 130
+
+// requestContext returns the context API requests should use. It is cancelled
+// when the user interrupts the command.
+func (c *Config) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// uncancelledContext keeps the values of its parent, such as the trace span,
+// but not its cancellation
+type uncancelledContext struct {
+	context.Context
+}
+
+func (uncancelledContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (uncancelledContext) Done() <-chan struct{}       { return nil }
+func (uncancelledContext) Err() error                  { return nil }
+
+// withoutCancel returns a context with the values of ctx that is never
+// cancelled, for requests that finish even when the user interrupts the
+// command, such as those of a batch already in flight
+func withoutCancel(ctx context.Context) context.Context {
+	return uncancelledContext{ctx}
+}
+
+// isCancelled reports whether err was caused by the user interrupting a request
+func isCancelled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, errCancelled)
+}
+
+// cancelOnInterrupt returns a context that is cancelled on the first SIGINT or
+// SIGTERM. A second signal exits immediately.
+func cancelOnInterrupt() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(signals)
+			return
+		}
+
+		<-signals
+		os.Exit(exitCancelled)
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSlowServer returns a test server that starts a streamed reply, or sends
+// nothing for a buffered one, then hangs until the client goes away
+func newSlowServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		if request.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"partial\"}}]}\n\n")
+			w.(http.Flusher).Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+			t.Errorf("request was not cancelled")
+		}
+	}))
+}
+
+// TestPromptCommandCancelled tests that cancelling the context aborts the
+// request, returns errCancelled and logs the cancellation
+func TestPromptCommandCancelled(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := newSlowServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		stream bool
+	}{
+		{name: "buffered", stream: false},
+		{name: "streamed", stream: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			tmpDir := t.TempDir()
+			config := &Config{
+				APIKey:    "test-key",
+				APIURL:    server.URL,
+				Model:     "gpt-4o",
+				Timeout:   10 * time.Second,
+				Stream:    tt.stream,
+				ConfigDir: tmpDir,
+				ctx:       ctx,
+			}
+
+			var err error
+			captureOutput(t, &os.Stdout, func() {
+				err = promptCommand(config, []string{"hello"})
+			})
+			if !errors.Is(err, errCancelled) {
+				t.Fatalf("promptCommand() error = %v, want %v", err, errCancelled)
+			}
+
+			data, err := os.ReadFile(filepath.Join(tmpDir, "logs.jsonl"))
+			if err != nil {
+				t.Fatalf("failed to read log file: %v", err)
+			}
+
+			var entry LogEntry
+			if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &entry); err != nil {
+				t.Fatalf("failed to parse log entry: %v", err)
+			}
+			if entry.Error != cancelledLogMessage {
+				t.Errorf("entry.Error = %q, want %q", entry.Error, cancelledLogMessage)
+			}
+			if entry.Prompt != "hello" {
+				t.Errorf("entry.Prompt = %q, want %q", entry.Prompt, "hello")
+			}
+		})
+	}
+}
+
+// TestBatchCommandCancelled tests that cancelling the context stops sending
+// prompts but lets those in flight finish, and that their results are
+// written
+func TestBatchCommandCancelled(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := newDelayedServer(t, 300*time.Millisecond)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	tmpDir := t.TempDir()
+	promptFile := filepath.Join(tmpDir, "prompts.txt")
+	if err := os.WriteFile(promptFile, []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatalf("failed to write prompt file: %v", err)
+	}
+
+	config := &Config{
+		APIKey:    "test-key",
+		APIURL:    server.URL,
+		Model:     "gpt-4o",
+		Timeout:   10 * time.Second,
+		ConfigDir: tmpDir,
+		ctx:       ctx,
+	}
+
+	var err error
+	var out string
+	stderr := captureOutput(t, &os.Stderr, func() {
+		out = captureOutput(t, &os.Stdout, func() {
+			err = batchCommand(config, []string{"--concurrency", "2", promptFile})
+		})
+	})
+	if !errors.Is(err, errCancelled) {
+		t.Fatalf("batchCommand() error = %v, want %v", err, errCancelled)
+	}
+	if !strings.Contains(stderr, "Interrupted after sending 2 of 3 prompts") {
+		t.Errorf("stderr = %q, want the prompts sent", stderr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected the 2 results of the prompts in flight, got %d", len(lines))
+	}
+	for i, line := range lines {
+		var result BatchResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("failed to parse result: %v", err)
+		}
+		if result.Error != "" || result.Response == "" {
+			t.Errorf("results[%d] = %+v, want the finished reply", i, result)
+		}
+	}
+}
+
+// TestIsCancelled tests recognition of cancellation errors
+func TestIsCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "context cancelled", err: fmt.Errorf("request failed: %w", context.Canceled), want: true},
+		{name: "errCancelled", err: errCancelled, want: true},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: false},
+		{name: "other error", err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCancelled(tt.err); got != tt.want {
+				t.Errorf("isCancelled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Most choices a single prompt may ask for with --n
+const maxChoices = 10
+
+// Rule printed between the choices of a response
+var choiceSeparator = strings.Repeat("━", 51)
+
+// formatChoices formats the choices of a response for display. With n of 1,
+// or a single choice, it is the reply alone; otherwise each choice is
+// labeled [1], [2], ... and separated by rules.
+func formatChoices(response *ChatResponse, n int) string {
+	if len(response.Choices) == 0 {
+		return "No response received from ChatGPT"
+	}
+	if n <= 1 || len(response.Choices) == 1 {
+		return strings.TrimSpace(response.Choices[0].Message.Content)
+	}
+
+	blocks := make([]string, len(response.Choices))
+	for i, choice := range response.Choices {
+		blocks[i] = fmt.Sprintf("[%d]\n%s", i+1, strings.TrimSpace(choice.Message.Content))
+	}
+	return strings.Join(blocks, "\n\n"+choiceSeparator+"\n\n")
+}
+
+// choiceContents returns the trimmed reply of each choice
+func choiceContents(response *ChatResponse) []string {
+	contents := make([]string, len(response.Choices))
+	for i, choice := range response.Choices {
+		contents[i] = strings.TrimSpace(choice.Message.Content)
+	}
+	return contents
+}
+
+// validateChoiceFlags checks --n and --pick before the request is sent
+func validateChoiceFlags(config *Config, n int, pick bool) error {
+	if n < 1 || n > maxChoices {
+		return usageErrorf("--n must be between 1 and %d", maxChoices)
+	}
+	if n > 1 && (config.Provider == providerAnthropic || config.Provider == providerOllama) {
+		return usageErrorf("--n is not supported by the %s provider", config.Provider)
+	}
+	if pick && n < 2 {
+		return usageErrorf("--pick needs --n 2 or more")
+	}
+	if pick && !stdinIsTerminal() {
+		return usageErrorf("--pick needs an interactive terminal")
+	}
+	return nil
+}
+
+// pickChoice shows the labeled choices on stderr and asks which one to keep,
+// returning its reply
+func pickChoice(response *ChatResponse) (string, error) {
+	contents := choiceContents(response)
+	if len(contents) < 2 {
+		return formatChoices(response, 1), nil
+	}
+
+	fmt.Fprintln(os.Stderr, formatChoices(response, len(contents)))
+	fmt.Fprintln(os.Stderr)
+	return pickChoiceOn(os.Stderr, stdin, contents)
+}
+
+// pickChoiceOn asks on w for a choice number until a valid one is read from r
+func pickChoiceOn(w io.Writer, r io.Reader, contents []string) (string, error) {
+	reader := bufio.NewReader(r)
+	for {
+		fmt.Fprintf(w, "Pick a choice [1-%d]: ", len(contents))
+
+		answer, err := reader.ReadString('\n')
+		if n, convErr := strconv.Atoi(strings.TrimSpace(answer)); convErr == nil && n >= 1 && n <= len(contents) {
+			return contents[n-1], nil
+		}
+		if err != nil {
+			fmt.Fprintln(w)
+			return "", fmt.Errorf("no choice picked")
+		}
+		fmt.Fprintf(w, "Enter a number from 1 to %d\n", len(contents))
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// threeChoices is a response with three candidate replies
+var threeChoices = &ChatResponse{
+	Choices: []Choice{
+		{Index: 0, Message: Message{Content: " First \n"}},
+		{Index: 1, Message: Message{Content: "Second"}},
+		{Index: 2, Message: Message{Content: "```go\nfmt.Println(3)\n```"}},
+	},
+}
+
+// TestFormatChoices tests labeling and separating several choices
+func TestFormatChoices(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *ChatResponse
+		n        int
+		expected string
+	}{
+		{
+			name:     "no choices",
+			response: &ChatResponse{},
+			n:        3,
+			expected: "No response received from ChatGPT",
+		},
+		{
+			name:     "one choice",
+			response: &ChatResponse{Choices: []Choice{{Message: Message{Content: "  Only\n"}}}},
+			n:        3,
+			expected: "Only",
+		},
+		{
+			name:     "many choices with n of 1",
+			response: threeChoices,
+			n:        1,
+			expected: "First",
+		},
+		{
+			name:     "many choices",
+			response: threeChoices,
+			n:        3,
+			expected: "[1]\nFirst\n\n" + choiceSeparator + "\n\n[2]\nSecond\n\n" + choiceSeparator + "\n\n[3]\n```go\nfmt.Println(3)\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatChoices(tt.response, tt.n); got != tt.expected {
+				t.Errorf("formatChoices() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPickChoiceOn tests reading the picked choice, asking again after an
+// invalid answer
+func TestPickChoiceOn(t *testing.T) {
+	contents := choiceContents(threeChoices)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid number", input: "2\n", want: "Second"},
+		{name: "invalid then valid", input: "7\nabc\n1\n", want: "First"},
+		{name: "no line break", input: "3", want: "```go\nfmt.Println(3)\n```"},
+		{name: "end of input", input: "9\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			got, err := pickChoiceOn(&out, strings.NewReader(tt.input), contents)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pickChoiceOn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pickChoiceOn() = %q, want %q", got, tt.want)
+			}
+			if !strings.Contains(out.String(), "Pick a choice [1-3]: ") {
+				t.Errorf("prompt = %q, want it to ask for 1-3", out.String())
+			}
+		})
+	}
+}
+
+// TestValidateChoiceFlags tests the checks made before asking for choices
+func TestValidateChoiceFlags(t *testing.T) {
+	originalIsTerminal := stdinIsTerminal
+	defer func() { stdinIsTerminal = originalIsTerminal }()
+	stdinIsTerminal = func() bool { return true }
+
+	tests := []struct {
+		name     string
+		provider string
+		n        int
+		pick     bool
+		wantErr  bool
+	}{
+		{name: "default", provider: providerOpenAI, n: 1},
+		{name: "several", provider: providerOpenAI, n: 3},
+		{name: "azure", provider: providerAzure, n: 3, pick: true},
+		{name: "zero", provider: providerOpenAI, n: 0, wantErr: true},
+		{name: "too many", provider: providerOpenAI, n: maxChoices + 1, wantErr: true},
+		{name: "pick one", provider: providerOpenAI, n: 1, pick: true, wantErr: true},
+		{name: "anthropic", provider: providerAnthropic, n: 2, wantErr: true},
+		{name: "ollama", provider: providerOllama, n: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateChoiceFlags(&Config{Provider: tt.provider}, tt.n, tt.pick)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateChoiceFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUsage) {
+				t.Errorf("validateChoiceFlags() error = %v, want a usage error", err)
+			}
+		})
+	}
+
+	stdinIsTerminal = func() bool { return false }
+	if err := validateChoiceFlags(&Config{Provider: providerOpenAI}, 2, true); !errors.Is(err, ErrUsage) {
+		t.Errorf("validateChoiceFlags() without a terminal error = %v, want a usage error", err)
+	}
+}
+
+// TestPromptCommandChoices tests sending --n and printing or picking choices
+func TestPromptCommandChoices(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var requestN int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		requestN = req.N
+		if req.Stream {
+			t.Error("request with several choices was streamed")
+		}
+		_, _ = fmt.Fprint(w, `{"model":"gpt-4o","choices":[{"index":0,"message":{"content":"One"}},{"index":1,"message":{"content":"Two"}}],"usage":{"prompt_tokens":5,"completion_tokens":4,"total_tokens":9}}`)
+	}))
+	defer server.Close()
+
+	setTestEnv(envConfigDir, t.TempDir())
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	setTestEnv(envModel, "gpt-4o")
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	var stdout string
+	stderr := captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, func() {
+			if err := promptCommand(config, []string{"--n", "2", "--usage", "hello"}); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+	if requestN != 2 {
+		t.Errorf("request n = %d, want 2", requestN)
+	}
+	if want := "[1]\nOne\n\n" + choiceSeparator + "\n\n[2]\nTwo\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+	if !strings.Contains(stderr, "5 prompt + 4 completion = 9 total") || !strings.Contains(stderr, "all 2 choices") {
+		t.Errorf("stderr = %q, want the combined usage of both choices", stderr)
+	}
+
+	originalStdin, originalIsTerminal := stdin, stdinIsTerminal
+	defer func() { stdin, stdinIsTerminal = originalStdin, originalIsTerminal }()
+	stdinIsTerminal = func() bool { return true }
+	stdin = strings.NewReader("2\n")
+
+	stderr = captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, func() {
+			if err := promptCommand(config, []string{"--n", "2", "--pick", "hello"}); err != nil {
+				t.Errorf("promptCommand() --pick error = %v", err)
+			}
+		})
+	})
+	if stdout != "Two\n" {
+		t.Errorf("--pick stdout = %q, want only the picked choice", stdout)
+	}
+	if !strings.Contains(stderr, "[1]\nOne") || !strings.Contains(stderr, "Pick a choice [1-2]: ") {
+		t.Errorf("--pick stderr = %q, want the choices and the question", stderr)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboard reads and writes the system clipboard
+type clipboard interface {
+	Read() (string, error)
+	Write(text string) error
+}
+
+// Returns the clipboard of the current system. Replaced in tests.
+var newClipboard = func() clipboard {
+	return systemClipboard(runtime.GOOS, os.Getenv("WAYLAND_DISPLAY") != "")
+}
+
+// commandClipboard uses a pair of command line tools to copy and paste
+type commandClipboard struct {
+	copy  []string
+	paste []string
+	// install names what to install when the tools are missing
+	install string
+}
+
+// systemClipboard returns the clipboard tools of the given OS. On Linux and
+// other Unix systems, Wayland sessions use wl-clipboard and X11 ones xclip.
+func systemClipboard(goos string, wayland bool) commandClipboard {
+	switch goos {
+	case "darwin":
+		return commandClipboard{copy: []string{"pbcopy"}, paste: []string{"pbpaste"}, install: "pbcopy and pbpaste, which ship with macOS"}
+	case "windows":
+		return commandClipboard{
+			copy:    []string{"clip.exe"},
+			paste:   []string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"},
+			install: "clip.exe and PowerShell, which ship with Windows",
+		}
+	}
+	if wayland {
+		return commandClipboard{copy: []string{"wl-copy"}, paste: []string{"wl-paste", "--no-newline"}, install: "wl-clipboard"}
+	}
+	return commandClipboard{
+		copy:    []string{"xclip", "-selection", "clipboard"},
+		paste:   []string{"xclip", "-selection", "clipboard", "-o"},
+		install: "xclip",
+	}
+}
+
+func (c commandClipboard) Read() (string, error) {
+	cmd, err := c.command(c.paste)
+	if err != nil {
+		return "", err
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", clipboardError("paste", err, stderr.String())
+	}
+
+	// PowerShell ends the text with a line break of its own
+	if c.paste[0] == "powershell.exe" {
+		out = bytes.TrimSuffix(out, []byte("\r\n"))
+	}
+	return string(out), nil
+}
+
+func (c commandClipboard) Write(text string) error {
+	cmd, err := c.command(c.copy)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return clipboardError("copy", err, stderr.String())
+	}
+	return nil
+}
+
+// command builds the command for args, failing with what to install if the
+// tool is missing
+func (c commandClipboard) command(args []string) (*exec.Cmd, error) {
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("clipboard tool %s not found; install %s", args[0], c.install)
+	}
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+// clipboardError adds the tool's own message to a failed copy or paste
+func clipboardError(action string, err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("failed to %s: %s", action, msg)
+	}
+	return fmt.Errorf("failed to %s: %w", action, err)
+}
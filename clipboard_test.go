@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeClipboard is an in-memory clipboard
+type fakeClipboard struct {
+	text string
+	err  error
+}
+
+func (c *fakeClipboard) Read() (string, error) {
+	return c.text, c.err
+}
+
+func (c *fakeClipboard) Write(text string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.text = text
+	return nil
+}
+
+// useFakeClipboard replaces the system clipboard for the rest of the test
+func useFakeClipboard(t *testing.T, fake *fakeClipboard) {
+	original := newClipboard
+	newClipboard = func() clipboard { return fake }
+	t.Cleanup(func() { newClipboard = original })
+}
+
+// TestSystemClipboard tests the tools picked for each OS
+func TestSystemClipboard(t *testing.T) {
+	tests := []struct {
+		goos      string
+		wayland   bool
+		wantCopy  string
+		wantPaste string
+	}{
+		{"darwin", false, "pbcopy", "pbpaste"},
+		{"windows", false, "clip.exe", "powershell.exe"},
+		{"linux", true, "wl-copy", "wl-paste"},
+		{"linux", false, "xclip", "xclip"},
+		{"freebsd", false, "xclip", "xclip"},
+	}
+
+	for _, tt := range tests {
+		c := systemClipboard(tt.goos, tt.wayland)
+		if c.copy[0] != tt.wantCopy || c.paste[0] != tt.wantPaste {
+			t.Errorf("systemClipboard(%q, %t) = %v / %v, want %s / %s", tt.goos, tt.wayland, c.copy, c.paste, tt.wantCopy, tt.wantPaste)
+		}
+	}
+}
+
+// TestClipboardMissingTool tests that a missing tool names what to install
+func TestClipboardMissingTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	c := systemClipboard("linux", false)
+	if err := c.Write("text"); err == nil || !strings.Contains(err.Error(), "install xclip") {
+		t.Errorf("Write() error = %v, want a hint to install xclip", err)
+	}
+	c = systemClipboard("linux", true)
+	if _, err := c.Read(); err == nil || !strings.Contains(err.Error(), "wl-paste not found; install wl-clipboard") {
+		t.Errorf("Read() error = %v, want a hint to install wl-clipboard", err)
+	}
+}
+
+// TestPromptCommandClipboard tests --clip-in and --clip-out
+func TestPromptCommandClipboard(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err == nil && len(request.Messages) > 0 {
+			received = request.Messages[0].Content
+		}
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Reply text"}}]}`)
+	}))
+	defer server.Close()
+
+	setTestEnv(envConfigDir, t.TempDir())
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	fake := &fakeClipboard{text: "Clipboard\nquestion"}
+	useFakeClipboard(t, fake)
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--clip-in", "--clip-out", "--no-stream"}); err != nil {
+			t.Errorf("promptCommand() error = %v", err)
+		}
+	})
+	if received != "Clipboard\nquestion" {
+		t.Errorf("sent prompt = %q, want the clipboard text", received)
+	}
+	if fake.text != "Reply text" {
+		t.Errorf("clipboard = %q, want the reply", fake.text)
+	}
+
+	// With JSON output only the content is copied
+	fake.text = ""
+	config.Output = outputJSON
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--clip-out", "hello"}); err != nil {
+			t.Errorf("promptCommand(--output json) error = %v", err)
+		}
+	})
+	if fake.text != "Reply text" || !strings.Contains(out, `"content"`) {
+		t.Errorf("clipboard = %q, output = %q, want the bare reply copied and JSON printed", fake.text, out)
+	}
+	config.Output = outputPlain
+
+	if err := promptCommand(config, []string{"--clip-in", "extra"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("promptCommand(--clip-in extra) error = %v, want a usage error", err)
+	}
+
+	fake.text = "  "
+	if err := promptCommand(config, []string{"--clip-in"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("promptCommand(--clip-in) with an empty clipboard error = %v, want a usage error", err)
+	}
+
+	fake.err = errors.New("clipboard tool xclip not found; install xclip")
+	if err := promptCommand(config, []string{"--clip-in"}); err == nil || !strings.Contains(err.Error(), "install xclip") {
+		t.Errorf("promptCommand(--clip-in) error = %v, want the clipboard error", err)
+	}
+}
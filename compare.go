@@ -0,0 +1,313 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Default number of models asked in parallel by the compare command
+const defaultCompareConcurrency = 4
+
+// Narrowest column a reply is shown in side by side; on a terminal too
+// narrow for every model to get one, the replies are stacked instead
+const minCompareColumnWidth = 30
+
+// Separator between side-by-side columns
+const compareColumnSeparator = " │ "
+
+// CompareResult is the reply of one model to a compared prompt
+type CompareResult struct {
+	Model     string `json:"model"`
+	Response  string `json:"response,omitempty"`
+	Usage     *Usage `json:"usage,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// compareCommand sends the same prompt to several models and prints their
+// replies next to each other. A model that fails is reported in its place;
+// the command only fails when all of them do.
+func compareCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli compare --models gpt-4o,gpt-3.5-turbo [--concurrency N] \"your prompt here\""
+
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	modelList := fs.String("models", "", "comma-separated models to send the prompt to")
+	concurrency := fs.Int("concurrency", defaultCompareConcurrency, "number of models asked in parallel")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	models, err := parseCompareModels(*modelList)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if *concurrency < 1 {
+		return usageErrorf("--concurrency must be a positive integer")
+	}
+	prompt := strings.TrimSpace(strings.Join(args, " "))
+	if prompt == "" {
+		return usageErrorf("prompt is required\n%s", usage)
+	}
+
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	stopProgress := startProgress(config)
+	results := runCompare(config, models, prompt, *concurrency)
+	stopProgress()
+	if config.requestContext().Err() != nil {
+		return errCancelled
+	}
+
+	if config.Output == outputJSON {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		out := newUI(config, os.Stdout)
+		if width, ok := compareTerminalWidth(); ok && width >= len(results)*minCompareColumnWidth+(len(results)-1)*utf8.RuneCountInString(compareColumnSeparator) {
+			printCompareColumns(out, results, width)
+		} else {
+			printCompareStacked(out, results)
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	if failed == len(results) {
+		return fmt.Errorf("all %d models failed", failed)
+	}
+	return nil
+}
+
+// parseCompareModels splits the --models list, which needs at least two
+// distinct models
+func parseCompareModels(value string) ([]string, error) {
+	var models []string
+	for _, model := range strings.Split(value, ",") {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			continue
+		}
+		if containsString(models, model) {
+			return nil, fmt.Errorf("model %s is listed twice in --models", model)
+		}
+		models = append(models, model)
+	}
+	if len(models) < 2 {
+		return nil, fmt.Errorf("--models needs at least two models, such as --models gpt-4o,gpt-3.5-turbo")
+	}
+	return models, nil
+}
+
+// runCompare sends the prompt to each model using a pool of workers and
+// returns the results in the order of models
+func runCompare(config *Config, models []string, prompt string, concurrency int) []CompareResult {
+	// The workers share one client, so requests reuse connections
+	client := newAPIClient(config)
+	results := make([]CompareResult, len(models))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(models); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runCompareModel(client, models[i], prompt)
+			}
+		}()
+	}
+	for i := range models {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// runCompareModel sends the prompt to one model, with the values set for it
+// in the config file, and logs the request
+func runCompareModel(client *APIClient, model, prompt string) CompareResult {
+	config := *client.config
+	useModel(&config, model)
+	result := CompareResult{Model: model}
+
+	start := time.Now()
+	response, err := client.withConfig(&config).Chat(config.requestContext(), promptMessages(&config, prompt))
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		if isCancelled(err) {
+			result.Error = cancelledLogMessage
+		}
+		warnLogError(&config, writeLogEntry(&config, LogEntry{
+			Timestamp: time.Now(),
+			Command:   "compare",
+			Prompt:    prompt,
+			Error:     result.Error,
+			Model:     model,
+			RequestID: errorRequestID(err),
+		}))
+		return result
+	}
+
+	result.Response = formatResponse(response)
+	result.Usage = usageOrNil(response.Usage)
+	warnLogError(&config, writeLogEntry(&config, LogEntry{
+		Timestamp: time.Now(),
+		Command:   "compare",
+		Prompt:    prompt,
+		Response:  result.Response,
+		Usage:     result.Usage,
+		Model:     responseModel(&config, response),
+		LatencyMs: result.LatencyMs,
+		RequestID: response.RequestID,
+	}))
+	return result
+}
+
+// compareTerminalWidth returns the width of stdout, and false when it is not
+// a terminal
+func compareTerminalWidth() (int, bool) {
+	if !isTerminal(os.Stdout) {
+		return 0, false
+	}
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		return 0, false
+	}
+	return width, true
+}
+
+// compareHeader returns the header of a result: the model, then its timing
+// or that it failed
+func compareHeader(result CompareResult) string {
+	latency := time.Duration(result.LatencyMs) * time.Millisecond
+	if result.Error != "" {
+		return fmt.Sprintf("%s (failed after %.1fs)", result.Model, latency.Seconds())
+	}
+	var usage Usage
+	if result.Usage != nil {
+		usage = *result.Usage
+	}
+	return result.Model + " " + formatTiming(latency, usage)
+}
+
+// compareBody returns the text shown for a result: the reply, or the error
+func compareBody(out *ui, result CompareResult) string {
+	if result.Error != "" {
+		return out.red("Error:") + " " + result.Error
+	}
+	return result.Response
+}
+
+// printCompareStacked prints each result under a header, one after the other
+func printCompareStacked(out *ui, results []CompareResult) {
+	for i, result := range results {
+		if i > 0 {
+			out.Println()
+		}
+		out.heading(compareHeader(result))
+		out.Println(compareBody(out, result))
+	}
+}
+
+// printCompareColumns prints the results side by side in columns sharing
+// width, each reply wrapped to its column
+func printCompareColumns(out *ui, results []CompareResult, width int) {
+	separator := utf8.RuneCountInString(compareColumnSeparator)
+	columnWidth := (width - (len(results)-1)*separator) / len(results)
+
+	// Headers are padded to the same height, so the rules under them line up
+	headers := make([][]string, len(results))
+	headerRows := 0
+	for i, result := range results {
+		headers[i] = wrapText(compareHeader(result), columnWidth)
+		if len(headers[i]) > headerRows {
+			headerRows = len(headers[i])
+		}
+	}
+
+	columns := make([][]string, len(results))
+	rows := 0
+	for i, result := range results {
+		lines := append(headers[i], make([]string, headerRows-len(headers[i]))...)
+		lines = append(lines, strings.Repeat("━", columnWidth))
+		if result.Error != "" {
+			lines = append(lines, wrapText("Error: "+result.Error, columnWidth)...)
+		} else {
+			lines = append(lines, wrapText(result.Response, columnWidth)...)
+		}
+		columns[i] = lines
+		if len(lines) > rows {
+			rows = len(lines)
+		}
+	}
+
+	for row := 0; row < rows; row++ {
+		var cells []string
+		for _, lines := range columns {
+			cell := ""
+			if row < len(lines) {
+				cell = lines[row]
+			}
+			cells = append(cells, cell)
+		}
+		// Columns that ended are left out at the end of the line, and the
+		// last cell isn't padded, so lines carry no trailing spaces
+		for len(cells) > 1 && cells[len(cells)-1] == "" {
+			cells = cells[:len(cells)-1]
+		}
+		for i := 0; i < len(cells)-1; i++ {
+			cells[i] += strings.Repeat(" ", columnWidth-utf8.RuneCountInString(cells[i]))
+		}
+		out.Println(strings.TrimRight(strings.Join(cells, compareColumnSeparator), " "))
+	}
+}
+
+// wrapText wraps text to lines of at most width characters, breaking at
+// spaces and cutting words longer than a line. Line breaks are kept, runs
+// of spaces are not.
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for utf8.RuneCountInString(word) > width {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				runes := []rune(word)
+				lines = append(lines, string(runes[:width]))
+				word = string(runes[width:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
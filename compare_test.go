@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCompareServer answers chat requests with the model's name, failing for
+// the models in failing
+func newCompareServer(t *testing.T, failing ...string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if containsString(failing, request.Model) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":{"message":"The model %s does not exist","type":"invalid_request_error"}}`, request.Model)
+			return
+		}
+		fmt.Fprintf(w, `{"model":%q,"choices":[{"message":{"role":"assistant","content":"Answer from %s"}}],"usage":{"prompt_tokens":5,"completion_tokens":7,"total_tokens":12}}`, request.Model, request.Model)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestCompareCommand tests sending a prompt to several models, reporting a
+// failing one inline and logging each request
+func TestCompareCommand(t *testing.T) {
+	server := newCompareServer(t, "gpt-missing")
+	tmpDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: tmpDir, NoColor: true}
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = compareCommand(config, []string{"--models", "gpt-4o, gpt-missing,gpt-3.5-turbo", "Explain", "channels"})
+	})
+	if err != nil {
+		t.Fatalf("compareCommand() error = %v", err)
+	}
+
+	// Output to a pipe is stacked, in the order of --models
+	for _, want := range []string{"gpt-4o (0.", "12 tokens)\n" + headingRule + "\nAnswer from gpt-4o\n", "gpt-missing (failed after", "Error: ", "The model gpt-missing does not exist", "Answer from gpt-3.5-turbo"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	}
+	if strings.Index(out, "gpt-4o") > strings.Index(out, "gpt-missing") || strings.Index(out, "gpt-missing") > strings.Index(out, "gpt-3.5-turbo") {
+		t.Errorf("output = %q, want the models in the order given", out)
+	}
+
+	entries := readTestLogEntries(t, tmpDir)
+	if len(entries) != 3 {
+		t.Fatalf("log entries = %+v, want one per model", entries)
+	}
+	models := map[string]LogEntry{}
+	for _, entry := range entries {
+		if entry.Command != "compare" || entry.Prompt != "Explain channels" {
+			t.Errorf("log entry = %+v", entry)
+		}
+		models[entry.Model] = entry
+	}
+	if entry := models["gpt-3.5-turbo"]; entry.Response != "Answer from gpt-3.5-turbo" || entry.Usage == nil || entry.Usage.TotalTokens != 12 {
+		t.Errorf("gpt-3.5-turbo log entry = %+v", entry)
+	}
+	if entry := models["gpt-missing"]; !strings.Contains(entry.Error, "does not exist") {
+		t.Errorf("gpt-missing log entry = %+v", entry)
+	}
+	if config.Model != "gpt-4o" {
+		t.Errorf("config.Model = %q, want it unchanged", config.Model)
+	}
+}
+
+// TestCompareCommandJSON tests the array written with --output json
+func TestCompareCommandJSON(t *testing.T) {
+	server := newCompareServer(t, "gpt-missing")
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: t.TempDir(), Output: outputJSON, LogDisabled: true}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := compareCommand(config, []string{"--models", "gpt-missing,gpt-4o", "--concurrency", "1", "hi"}); err != nil {
+			t.Errorf("compareCommand() error = %v", err)
+		}
+	})
+
+	var results []CompareResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("output = %q: %v", out, err)
+	}
+	if len(results) != 2 || results[0].Model != "gpt-missing" || results[0].Error == "" || results[1].Response != "Answer from gpt-4o" || results[1].Usage == nil {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+// TestCompareCommandAllFail tests that the command fails when no model answers
+func TestCompareCommandAllFail(t *testing.T) {
+	server := newCompareServer(t, "a", "b")
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: t.TempDir(), NoColor: true, LogDisabled: true}
+
+	var err error
+	captureOutput(t, &os.Stdout, func() {
+		err = compareCommand(config, []string{"--models", "a,b", "hi"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "all 2 models failed") {
+		t.Errorf("compareCommand() error = %v", err)
+	}
+}
+
+// TestCompareCommandUsage tests the flags and arguments rejected before any
+// request is sent
+func TestCompareCommandUsage(t *testing.T) {
+	config := &Config{APIKey: "test-key", APIURL: "http://127.0.0.1:1", Timeout: time.Second}
+	tests := [][]string{
+		{"hi"},
+		{"--models", "gpt-4o", "hi"},
+		{"--models", "gpt-4o,gpt-4o", "hi"},
+		{"--models", "gpt-4o,gpt-3.5-turbo"},
+		{"--models", "gpt-4o,gpt-3.5-turbo", "--concurrency", "0", "hi"},
+	}
+	for _, args := range tests {
+		if err := compareCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("compareCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
+
+// TestPrintCompareColumns tests the side-by-side layout
+func TestPrintCompareColumns(t *testing.T) {
+	results := []CompareResult{
+		{Model: "a", Response: "one two three four five six", LatencyMs: 1200},
+		{Model: "b", Error: "boom", LatencyMs: 300},
+	}
+	out := captureOutput(t, &os.Stdout, func() {
+		printCompareColumns(newUI(&Config{NoColor: true}, os.Stdout), results, 35)
+	})
+
+	want := "a (1.2s)         │ b (failed after\n" +
+		"                 │ 0.3s)\n" +
+		strings.Repeat("━", 16) + " │ " + strings.Repeat("━", 16) + "\n" +
+		"one two three    │ Error: boom\n" +
+		"four five six\n"
+	if out != want {
+		t.Errorf("output =\n%s\nwant\n%s", out, want)
+	}
+}
+
+// TestWrapText tests wrapping text to a width
+func TestWrapText(t *testing.T) {
+	got := wrapText("the quick brown fox\n\nabcdefghijkl end", 5)
+	want := []string{"the", "quick", "brown", "fox", "", "abcde", "fghij", "kl", "end"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("wrapText() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Shells supported by the completion command
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionCommand prints a shell completion script
+func completionCommand(config *Config, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("shell required\nUsage: chatgpt-cli completion <%s>", strings.Join(completionShells, "|"))
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion())
+	case "zsh":
+		fmt.Print(zshCompletion())
+	case "fish":
+		fmt.Print(fishCompletion())
+	default:
+		return usageErrorf("unsupported shell: %s\nValid shells: %s", args[0], strings.Join(completionShells, ", "))
+	}
+
+	return nil
+}
+
+// completeCommand is the hidden helper called by the completion scripts. Its
+// arguments are the words typed after the program name, the last one being the
+// word under the cursor. It prints one candidate per line. The scripts pass the
+// words after "--" so that global flags among them are left alone.
+func completeCommand(config *Config, args []string) error {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	for _, candidate := range completionCandidates(config, args) {
+		fmt.Println(candidate)
+	}
+	return nil
+}
+
+// visibleCommands returns the registered commands shown to users, sorted by name
+func visibleCommands() []Command {
+	var commands []Command
+	for _, command := range getCommands() {
+		if !command.Hidden {
+			commands = append(commands, command)
+		}
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// completionCandidates returns the completions for the last word in args
+func completionCandidates(config *Config, args []string) []string {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	current := args[len(args)-1]
+
+	// Values of global flags, wherever they appear
+	if len(args) > 1 {
+		switch args[len(args)-2] {
+		case "--profile", "-profile":
+			return filterPrefix(profileCandidates(config), current)
+		case "--output", "-output":
+			return filterPrefix([]string{outputPlain, outputJSON}, current)
+		}
+	}
+
+	words := positionalWords(args[:len(args)-1])
+
+	var candidates []string
+	if len(words) == 0 {
+		for _, command := range visibleCommands() {
+			candidates = append(candidates, command.Name)
+		}
+		aliases, _ := loadAliases(config.ConfigDir)
+		candidates = append(candidates, aliasNames(aliases)...)
+		sort.Strings(candidates)
+		return filterPrefix(candidates, current)
+	}
+
+	previous := args[len(args)-2]
+
+	switch words[0] {
+	case "config":
+		if len(words) == 1 {
+			candidates = configSubcommands
+		} else if len(words) == 2 {
+			switch words[1] {
+			case "get":
+				for _, v := range configValues(config) {
+					candidates = append(candidates, v.Key)
+				}
+			case "set", "unset":
+				candidates = configFileKeys
+			}
+		}
+	case "logs":
+		switch {
+		case len(words) == 1:
+			candidates = []string{"clear", "export", "repair", "scan", "scrub", "show"}
+		case words[1] == "export" && previous == "--format":
+			candidates = exportFormats
+		case words[1] == "export" && previous == "--md-style":
+			candidates = []string{markdownTable, markdownSections}
+		}
+	case "history":
+		if len(words) == 1 {
+			candidates = []string{"show", "rerun"}
+		}
+	case "search":
+		if previous == "--in" {
+			candidates = searchFields
+		}
+	case "summarize":
+		if previous == "--length" {
+			candidates = summaryLengths
+		}
+	case "alias":
+		if len(words) == 1 {
+			candidates = aliasSubcommands
+		} else if len(words) == 2 && words[1] == "remove" {
+			aliases, _ := loadAliases(config.ConfigDir)
+			candidates = aliasNames(aliases)
+		}
+	case "style":
+		if len(words) == 1 {
+			candidates = styleSubcommands
+		}
+	case "prompt":
+		if previous == "--style" {
+			styles, _ := loadStyles(config.ConfigDir)
+			candidates = styleNames(styles)
+		}
+	case "auth":
+		if len(words) == 1 {
+			candidates = []string{"login", "logout", "status"}
+		}
+	case "stats":
+		if previous == "--by" {
+			candidates = []string{statsByDay, statsByModel}
+		}
+	case "completion":
+		if len(words) == 1 {
+			candidates = completionShells
+		}
+	}
+
+	return filterPrefix(candidates, current)
+}
+
+// positionalWords drops global flags and their values from words
+func positionalWords(words []string) []string {
+	var positional []string
+	for i := 0; i < len(words); i++ {
+		switch words[i] {
+		case "--profile", "-profile", "--output", "-output":
+			i++
+			continue
+		}
+		if strings.HasPrefix(words[i], "-") {
+			continue
+		}
+		positional = append(positional, words[i])
+	}
+	return positional
+}
+
+// profileCandidates returns the profile names defined in the config file
+func profileCandidates(config *Config) []string {
+	// A broken config file just offers no profile names
+	sections, _ := loadConfigSections(config.ConfigDir)
+	names := sortedProfileNames(sections)
+	return append([]string{defaultProfileName}, names...)
+}
+
+// filterPrefix returns the candidates starting with prefix, ignoring case so
+// that lowercase config keys complete too
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(prefix)) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// bashCompletion returns the bash completion script
+func bashCompletion() string {
+	var names []string
+	for _, command := range visibleCommands() {
+		names = append(names, command.Name)
+	}
+
+	return fmt.Sprintf(`# bash completion for chatgpt-cli
+# Load with: source <(chatgpt-cli completion bash)
+
+_chatgpt_cli() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local candidates
+
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        candidates="%s"
+    else
+        candidates="$(chatgpt-cli __complete -- "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)"
+    fi
+
+    COMPREPLY=($(compgen -W "$candidates" -- "$cur"))
+}
+
+complete -o default -F _chatgpt_cli chatgpt-cli
+`, strings.Join(names, " "))
+}
+
+// zshCompletion returns the zsh completion script
+func zshCompletion() string {
+	var commands []string
+	for _, command := range visibleCommands() {
+		commands = append(commands, fmt.Sprintf("        '%s:%s'", command.Name, zshEscape(command.Description)))
+	}
+
+	return fmt.Sprintf(`#compdef chatgpt-cli
+# zsh completion for chatgpt-cli
+# Load with: source <(chatgpt-cli completion zsh)
+
+_chatgpt_cli() {
+    local -a commands candidates
+    commands=(
+%s
+    )
+
+    if (( CURRENT == 2 )); then
+        _describe 'command' commands
+        return
+    fi
+
+    candidates=(${(f)"$(chatgpt-cli __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    if (( ${#candidates} )); then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+
+compdef _chatgpt_cli chatgpt-cli
+`, strings.Join(commands, "\n"))
+}
+
+// fishCompletion returns the fish completion script
+func fishCompletion() string {
+	var lines []string
+	for _, command := range visibleCommands() {
+		lines = append(lines, fmt.Sprintf("complete -c chatgpt-cli -n '__fish_use_subcommand' -a %s -d %s",
+			command.Name, fishQuote(command.Description)))
+	}
+
+	return fmt.Sprintf(`# fish completion for chatgpt-cli
+# Load with: chatgpt-cli completion fish | source
+
+complete -c chatgpt-cli -f
+%s
+complete -c chatgpt-cli -n 'not __fish_use_subcommand' -a '(chatgpt-cli __complete -- (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+complete -c chatgpt-cli -n '__fish_seen_subcommand_from prompt batch explain-code' -F
+`, strings.Join(lines, "\n"))
+}
+
+// zshEscape escapes a description for a single-quoted _describe entry
+func zshEscape(s string) string {
+	s = strings.ReplaceAll(s, ":", `\:`)
+	return strings.ReplaceAll(s, "'", `'\''`)
+}
+
+// fishQuote quotes s as a fish single-quoted string
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
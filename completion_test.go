@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestCompletionCandidates tests completions for partially typed commands
+func TestCompletionCandidates(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, testProfilesConfig)
+	config := &Config{ConfigDir: tmpDir, APIURL: defaultAPIURL}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"no words", nil, []string{"alias", "ask-logs", "auth", "batch", "commitmsg", "compare", "completion", "config", "doctor", "embed", "explain-code", "export", "help", "history", "image", "import", "init", "logs", "models", "prompt", "refine", "review", "run", "search", "stats", "status", "style", "summarize", "tokens", "transcribe", "translate", "update"}},
+		{"command prefix", []string{"co"}, []string{"commitmsg", "compare", "completion", "config"}},
+		{"after global flag", []string{"--output", "json", "st"}, []string{"stats", "status", "style"}},
+		{"style names", []string{"prompt", "--style", "co"}, []string{"concise"}},
+		{"config subcommands", []string{"config", ""}, configSubcommands},
+		{"config set keys", []string{"config", "set", "OPENAI_M"}, []string{"OPENAI_MODEL", "OPENAI_MAX_TOKENS", "OPENAI_MODELS_URL", "OPENAI_MAX_RESPONSE_BYTES"}},
+		{"lowercase key", []string{"config", "unset", "azure"}, []string{"AZURE_API_VERSION"}},
+		{"config get includes read-only keys", []string{"config", "get", "CHATGPT_CLI_P"}, []string{"CHATGPT_CLI_PROFILE"}},
+		{"no completion after key", []string{"config", "set", "OPENAI_MODEL", ""}, nil},
+		{"profile names", []string{"--profile", ""}, []string{"default", "work"}},
+		{"output formats", []string{"logs", "--output", "j"}, []string{"json"}},
+		{"logs clear", []string{"logs", "c"}, []string{"clear"}},
+		{"logs subcommands", []string{"logs", ""}, []string{"clear", "export", "repair", "scan", "scrub", "show"}},
+		{"logs export format", []string{"logs", "export", "--format", ""}, []string{"csv", "md", "json"}},
+		{"history subcommands", []string{"history", ""}, []string{"show", "rerun"}},
+		{"search fields", []string{"search", "--in", "r"}, []string{"response"}},
+		{"summary lengths", []string{"summarize", "--length", ""}, []string{"short", "long"}},
+		{"auth subcommands", []string{"auth", "lo"}, []string{"login", "logout"}},
+		{"stats grouping", []string{"stats", "--by", ""}, []string{"day", "model"}},
+		{"shells", []string{"completion", ""}, []string{"bash", "zsh", "fish"}},
+		{"prompt falls back to files", []string{"prompt", "--file", ""}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := completionCandidates(config, tt.args)
+			if strings.Join(result, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("completionCandidates(%q) = %v, want %v", tt.args, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCompletionCommand tests that the scripts cover the command registry
+func TestCompletionCommand(t *testing.T) {
+	config := &Config{ConfigDir: t.TempDir()}
+
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			var err error
+			out := captureOutput(t, &os.Stdout, func() {
+				err = completionCommand(config, []string{shell})
+			})
+			if err != nil {
+				t.Fatalf("completionCommand(%s) error = %v", shell, err)
+			}
+
+			for name, command := range getCommands() {
+				if command.Hidden {
+					continue
+				}
+				if !strings.Contains(out, name) {
+					t.Errorf("%s script does not mention command %q", shell, name)
+				}
+			}
+			if !strings.Contains(out, "__complete --") {
+				t.Errorf("%s script does not call the __complete helper", shell)
+			}
+		})
+	}
+
+	if err := completionCommand(config, []string{"powershell"}); err == nil || !strings.Contains(err.Error(), "unsupported shell") {
+		t.Errorf("completionCommand(powershell) error = %v, want unsupported shell", err)
+	}
+	if err := completionCommand(config, []string{}); err == nil || !strings.Contains(err.Error(), "shell required") {
+		t.Errorf("completionCommand() error = %v, want shell required", err)
+	}
+}
+
+// TestCompleteCommand tests the hidden helper used by the scripts
+func TestCompleteCommand(t *testing.T) {
+	config := &Config{ConfigDir: t.TempDir()}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := completeCommand(config, []string{"--", "--profile", "d"}); err != nil {
+			t.Errorf("completeCommand() error = %v", err)
+		}
+	})
+
+	if out != "default\n" {
+		t.Errorf("completeCommand() output = %q, want %q", out, "default\n")
+	}
+}
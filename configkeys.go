@@ -0,0 +1,863 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of configuration values, as reported by config schema
+const (
+	configTypeString   = "string"
+	configTypeInt      = "int"
+	configTypeFloat    = "float"
+	configTypeDuration = "duration"
+	configTypeBool     = "bool"
+	configTypeEnum     = "enum"
+	// A byte count, or a size such as 5MB or 512KB
+	configTypeSize = "size"
+)
+
+// configKey describes a configuration key: how it is loaded, shown and
+// checked by config set, and what config schema and the help text say about
+// it. Each key is read from the environment variable of its name, which
+// overrides the config file.
+type configKey struct {
+	name string
+	typ  string
+	// def is the default, as written in the config file; "" means not set
+	def string
+	// helpDefault describes the default in the help text instead of def,
+	// as "not set" or "derived from OPENAI_API_URL"
+	helpDefault string
+	help        string
+	// values are the values of an enum, and min and max the bounds of a
+	// number, checked by config set unless validate is given
+	values   []string
+	min, max *float64
+	// constraint describes the accepted values beyond the type
+	constraint string
+	// label names the key in the errors of config set
+	label  string
+	secret bool
+	// file is set for keys persisted in the config file, internal for those
+	// written there by the CLI only, and modelScoped for those config set
+	// --model can set for a single model
+	file        bool
+	internal    bool
+	modelScoped bool
+	// field is the Config field the key sets, if any
+	field string
+
+	// load sets the field from r; keys read before the others, such as
+	// the provider, or elsewhere, such as the API key, have none
+	load func(c *Config, r *configReader)
+	// value returns the value shown by config list and config get; keys
+	// without one are not shown
+	value func(c *Config) string
+	// validate checks a value of config set and returns it in the form it
+	// is saved; keys without one are checked by their type and bounds
+	validate func(value string) (string, error)
+}
+
+// bound returns a pointer to a bound of a number
+func bound(n float64) *float64 {
+	return &n
+}
+
+// configKeys are all the configuration keys. The keys in the config file
+// come first, in the order they are written. Adding a key means adding it
+// here and to Config.
+var configKeys = []configKey{
+	{
+		name: envAPIKey, typ: configTypeString, secret: true, file: true, field: "APIKey",
+		help:     "Your OpenAI API key (required); overrides auth login",
+		value:    apiKeyWithSource,
+		validate: validateAPIKey,
+	},
+	{
+		name: encryptedAPIKeyKey, typ: configTypeString, secret: true, file: true, internal: true,
+		help: "OPENAI_API_KEY encrypted by config set --encrypt",
+		validate: func(string) (string, error) {
+			return "", fmt.Errorf("%s is not set directly; use: chatgpt-cli config set --encrypt %s <key>", encryptedAPIKeyKey, envAPIKey)
+		},
+	},
+	{
+		name: envAnthropicAPIKey, typ: configTypeString, secret: true, file: true, field: "APIKey",
+		help:     "Your Anthropic API key, used with the anthropic provider",
+		value:    apiKeyWithSource,
+		validate: validateAPIKey,
+	},
+	{
+		name: envAPIURL, typ: configTypeString, def: defaultAPIURL, file: true, field: "APIURL",
+		help:       "API endpoint URL",
+		constraint: "an http:// or https:// URL; the default depends on OPENAI_PROVIDER",
+		load: func(c *Config, r *configReader) {
+			c.APIURL = getEnvOrFileOrDefault(envAPIURL, r.file[envAPIURL], defaultAPIURLFor(c.Provider))
+		},
+		value:    func(c *Config) string { return c.APIURL },
+		validate: validateURL("API URL"),
+	},
+	{
+		// Read first by loadConfig, since the values set for the model apply
+		name: envModel, typ: configTypeString, def: defaultModel, file: true, field: "Model",
+		help:       "Model to use",
+		constraint: "the default depends on OPENAI_PROVIDER",
+		value:      func(c *Config) string { return c.Model },
+		validate:   validateNotEmpty("model cannot be empty"),
+	},
+	{
+		name: envTimeout, typ: configTypeDuration, def: defaultTimeout.String(), file: true, modelScoped: true, field: "Timeout",
+		help: "Overall request timeout, response included",
+		load: func(c *Config, r *configReader) {
+			c.Timeout = parseDurationOrDefault(r.checked(envTimeout, checkDuration), defaultTimeout)
+		},
+		value: func(c *Config) string { return c.Timeout.String() },
+		validate: func(value string) (string, error) {
+			if _, err := time.ParseDuration(value); err != nil {
+				return "", fmt.Errorf("invalid timeout format (use format like '60s', '1m', '90s'): %w", err)
+			}
+			return value, nil
+		},
+	},
+	{
+		name: envMaxTokens, typ: configTypeInt, def: strconv.Itoa(defaultMaxTokens), min: bound(1), label: "max tokens", file: true, modelScoped: true, field: "MaxTokens",
+		help: "Max tokens in response",
+		load: func(c *Config, r *configReader) {
+			c.MaxTokens = parseIntOrDefault(r.checked(envMaxTokens, checkInt), defaultMaxTokens)
+		},
+		value: func(c *Config) string { return strconv.Itoa(c.MaxTokens) },
+	},
+	{
+		name: envTemperature, typ: configTypeFloat, def: fmt.Sprintf("%.1f", defaultTemperature), min: bound(0), max: bound(2), label: "temperature", file: true, modelScoped: true, field: "Temperature",
+		help: "Response randomness 0.0-2.0",
+		load: func(c *Config, r *configReader) {
+			c.Temperature = parseFloatOrDefault(r.checked(envTemperature, checkFloat), defaultTemperature)
+		},
+		value: func(c *Config) string { return fmt.Sprintf("%.1f", c.Temperature) },
+	},
+	{
+		name: envTopP, typ: configTypeFloat, helpDefault: "not set", min: bound(0), max: bound(1), label: "top_p", file: true, modelScoped: true, field: "TopP",
+		help: "Nucleus sampling 0.0-1.0",
+		load: func(c *Config, r *configReader) {
+			c.TopP = parseFloatOrDefault(r.checked(envTopP, checkFloat), 0)
+		},
+		value: func(c *Config) string { return formatOptionalFloat(c.TopP) },
+	},
+	{
+		name: envPresencePenalty, typ: configTypeFloat, helpDefault: "not set", min: bound(-2), max: bound(2), label: "presence penalty", file: true, modelScoped: true, field: "PresencePenalty",
+		help: "Penalty -2.0-2.0 for repeating topics",
+		load: func(c *Config, r *configReader) {
+			c.PresencePenalty = parseFloatOrDefault(r.checked(envPresencePenalty, checkFloat), 0)
+		},
+		value: func(c *Config) string { return formatOptionalFloat(c.PresencePenalty) },
+	},
+	{
+		name: envFrequencyPenalty, typ: configTypeFloat, helpDefault: "not set", min: bound(-2), max: bound(2), label: "frequency penalty", file: true, modelScoped: true, field: "FrequencyPenalty",
+		help: "Penalty -2.0-2.0 for repeating tokens",
+		load: func(c *Config, r *configReader) {
+			c.FrequencyPenalty = parseFloatOrDefault(r.checked(envFrequencyPenalty, checkFloat), 0)
+		},
+		value: func(c *Config) string { return formatOptionalFloat(c.FrequencyPenalty) },
+	},
+	{
+		name: envStop, typ: configTypeString, helpDefault: "not set", file: true, modelScoped: true, field: "Stop",
+		help:       "Comma-separated stop sequences, up to 4",
+		constraint: "up to 4 comma-separated sequences",
+		load: func(c *Config, r *configReader) {
+			c.Stop = parseStopSequencesOrDefault(r.checked(envStop, checkStopSequences))
+		},
+		value:    func(c *Config) string { return formatStopSequences(c.Stop) },
+		validate: validateWith(checkStopSequences),
+	},
+	{
+		name: envSeed, typ: configTypeInt, helpDefault: "not set", label: "seed", file: true, field: "Seed",
+		help: "Seed for deterministic sampling, where supported",
+		load: func(c *Config, r *configReader) {
+			c.Seed = parseSeedOrDefault(r.checked(envSeed, checkInt))
+		},
+		value: func(c *Config) string { return formatSeed(c.Seed) },
+	},
+	{
+		name: envStream, typ: configTypeBool, def: strconv.FormatBool(defaultStream), label: "stream", file: true, field: "Stream",
+		help: "Stream responses as they arrive",
+		load: func(c *Config, r *configReader) {
+			c.Stream = parseBoolOrDefault(r.checked(envStream, checkBool), defaultStream)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.Stream) },
+	},
+	{
+		name: envShowUsage, typ: configTypeBool, def: strconv.FormatBool(defaultShowUsage), label: "show usage", file: true, field: "ShowUsage",
+		help: "Print token usage after each response",
+		load: func(c *Config, r *configReader) {
+			c.ShowUsage = parseBoolOrDefault(r.checked(envShowUsage, checkBool), defaultShowUsage)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.ShowUsage) },
+	},
+	{
+		name: envModelsURL, typ: configTypeString, helpDefault: "derived from OPENAI_API_URL", file: true, field: "ModelsURL",
+		help:       "Models endpoint URL",
+		constraint: "an http:// or https:// URL",
+		load: func(c *Config, r *configReader) {
+			c.ModelsURL = getEnvOrFileConfig(envModelsURL, r.file[envModelsURL])
+		},
+		value:    getModelsURL,
+		validate: validateURL("models URL"),
+	},
+	{
+		name: envOutput, typ: configTypeEnum, def: defaultOutput, values: []string{outputPlain, outputJSON}, label: "output", file: true, field: "Output",
+		help: "Output format, plain or json",
+		load: func(c *Config, r *configReader) {
+			c.Output = parseOutputOrDefault(r.checked(envOutput, checkOutput), defaultOutput)
+		},
+		value: func(c *Config) string { return c.Output },
+	},
+	{
+		name: envNoColor, typ: configTypeBool, def: strconv.FormatBool(defaultNoColor), label: "no color", file: true, field: "NoColor",
+		help: "Disable colors in markdown and other output; so does NO_COLOR",
+		load: func(c *Config, r *configReader) {
+			c.NoColor = parseBoolOrDefault(r.checked(envNoColor, checkBool), defaultNoColor)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.NoColor) },
+	},
+	{
+		// Read first by loadConfig, since it decides the defaults of the
+		// endpoint, model and API key
+		name: envProvider, typ: configTypeEnum, def: defaultProvider, values: validProviders, file: true, field: "Provider",
+		help:  "API provider, openai, azure, anthropic or ollama",
+		value: func(c *Config) string { return c.Provider },
+		validate: func(value string) (string, error) {
+			value = strings.ToLower(value)
+			if parseProviderOrDefault(value, "") == "" {
+				return "", fmt.Errorf("provider must be one of: %s", strings.Join(validProviders, ", "))
+			}
+			return value, nil
+		},
+	},
+	{
+		name: envAzureAPIVersion, typ: configTypeString, def: defaultAzureAPIVersion, file: true, field: "AzureAPIVersion",
+		help: "api-version used with the azure provider",
+		load: func(c *Config, r *configReader) {
+			c.AzureAPIVersion = getEnvOrFileOrDefault(envAzureAPIVersion, r.file[envAzureAPIVersion], defaultAzureAPIVersion)
+		},
+		value:    func(c *Config) string { return c.AzureAPIVersion },
+		validate: validateNotEmpty("Azure API version cannot be empty"),
+	},
+	{
+		name: envOrgID, typ: configTypeString, helpDefault: "not set", file: true, field: "OrgID",
+		help:       "Organization sent as OpenAI-Organization",
+		constraint: "no spaces",
+		load: func(c *Config, r *configReader) {
+			c.OrgID = getEnvOrFileConfig(envOrgID, r.file[envOrgID])
+		},
+		value:    func(c *Config) string { return c.OrgID },
+		validate: validateID(envOrgID),
+	},
+	{
+		name: envProjectID, typ: configTypeString, helpDefault: "not set", file: true, field: "ProjectID",
+		help:       "Project sent as OpenAI-Project",
+		constraint: "no spaces",
+		load: func(c *Config, r *configReader) {
+			c.ProjectID = getEnvOrFileConfig(envProjectID, r.file[envProjectID])
+		},
+		value:    func(c *Config) string { return c.ProjectID },
+		validate: validateID(envProjectID),
+	},
+	{
+		name: envLogMaxSize, typ: configTypeSize, def: formatSize(defaultLogMaxSize), min: bound(0), file: true, field: "LogMaxSize",
+		help:       "Rotate logs.jsonl above this size",
+		constraint: "0 disables rotation",
+		load: func(c *Config, r *configReader) {
+			c.LogMaxSize = parseSizeOrDefault(r.checked(envLogMaxSize, checkSize), defaultLogMaxSize)
+		},
+		value:    func(c *Config) string { return formatSize(c.LogMaxSize) },
+		validate: validateSize("log max size must be a size like 5MB, 512KB or 1048576 (0 disables rotation)"),
+	},
+	{
+		name: envLogMaxFiles, typ: configTypeInt, def: strconv.Itoa(defaultLogMaxFiles), min: bound(0), label: "log max files", file: true, field: "LogMaxFiles",
+		help: "Number of rotated log files kept",
+		load: func(c *Config, r *configReader) {
+			c.LogMaxFiles = parseIntOrDefault(r.checked(envLogMaxFiles, checkInt), defaultLogMaxFiles)
+		},
+		value: func(c *Config) string { return strconv.Itoa(c.LogMaxFiles) },
+	},
+	{
+		name: envMaxFileSize, typ: configTypeSize, def: formatSize(defaultMaxFileSize), min: bound(0), file: true, field: "MaxFileSize",
+		help:       "Largest file accepted by prompt --file",
+		constraint: "0 disables the limit",
+		load: func(c *Config, r *configReader) {
+			c.MaxFileSize = parseSizeOrDefault(r.checked(envMaxFileSize, checkSize), defaultMaxFileSize)
+		},
+		value:    func(c *Config) string { return formatSize(c.MaxFileSize) },
+		validate: validateSize("max file size must be a size like 1MB, 512KB or 1048576 (0 disables the limit)"),
+	},
+	{
+		name: envLogFullPrompt, typ: configTypeBool, def: strconv.FormatBool(defaultLogFullPrompt), label: "log full prompt", file: true, field: "LogFullPrompt",
+		help: "Log attached file contents, not just names",
+		load: func(c *Config, r *configReader) {
+			c.LogFullPrompt = parseBoolOrDefault(r.checked(envLogFullPrompt, checkBool), defaultLogFullPrompt)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.LogFullPrompt) },
+	},
+	{
+		name: envTiming, typ: configTypeBool, def: strconv.FormatBool(defaultTiming), label: "timing", file: true, field: "Timing",
+		help: "Print how long each prompt took, like prompt --timing",
+		load: func(c *Config, r *configReader) {
+			c.Timing = parseBoolOrDefault(r.checked(envTiming, checkBool), defaultTiming)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.Timing) },
+	},
+	{
+		name: envToolDomains, typ: configTypeString, helpDefault: "none", file: true, field: "ToolDomains",
+		help:       "Comma-separated domains the http_get tool may fetch",
+		constraint: "comma-separated domains",
+		load: func(c *Config, r *configReader) {
+			c.ToolDomains = parseDomainList(getEnvOrFileConfig(envToolDomains, r.file[envToolDomains]))
+		},
+		value:    func(c *Config) string { return formatDomainList(c.ToolDomains) },
+		validate: validateWith(validateDomainList),
+	},
+	{
+		name: envReasoningModels, typ: configTypeString, helpDefault: "none", file: true, field: "ReasoningModels",
+		help:       "More models to send as reasoning models, like o1",
+		constraint: "comma-separated model names",
+		load: func(c *Config, r *configReader) {
+			c.ReasoningModels = parseModelList(getEnvOrFileConfig(envReasoningModels, r.file[envReasoningModels]))
+		},
+		value:    func(c *Config) string { return formatModelList(c.ReasoningModels) },
+		validate: validateWith(validateModelList),
+	},
+	{
+		name: envLogDisabled, typ: configTypeBool, def: strconv.FormatBool(defaultLogDisabled), label: "log disabled", file: true, field: "LogDisabled",
+		help: "Do not write prompts and responses to the log",
+		load: func(c *Config, r *configReader) {
+			c.LogDisabled = parseBoolOrDefault(r.checked(envLogDisabled, checkBool), defaultLogDisabled)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.LogDisabled) },
+	},
+	{
+		name: envPromptPrefix, typ: configTypeString, helpDefault: "not set", file: true, field: "PromptPrefix",
+		help: "Text sent before every prompt, like prompt --prefix",
+		load: func(c *Config, r *configReader) {
+			c.PromptPrefix = getEnvOrFileConfig(envPromptPrefix, r.file[envPromptPrefix])
+		},
+		value:    func(c *Config) string { return c.PromptPrefix },
+		validate: validatePromptText(envPromptPrefix),
+	},
+	{
+		name: envPromptSuffix, typ: configTypeString, helpDefault: "not set", file: true, field: "PromptSuffix",
+		help: "Text sent after every prompt, like prompt --suffix",
+		load: func(c *Config, r *configReader) {
+			c.PromptSuffix = getEnvOrFileConfig(envPromptSuffix, r.file[envPromptSuffix])
+		},
+		value:    func(c *Config) string { return c.PromptSuffix },
+		validate: validatePromptText(envPromptSuffix),
+	},
+	{
+		name: envRequestsPerMinute, typ: configTypeInt, def: "0", helpDefault: "unlimited", min: bound(0), file: true, field: "RequestsPerMinute",
+		help:       "Requests sent per minute at most, by all invocations",
+		constraint: "0 means unlimited",
+		load: func(c *Config, r *configReader) {
+			c.RequestsPerMinute = parseIntOrDefault(r.checked(envRequestsPerMinute, checkInt), 0)
+		},
+		value: func(c *Config) string { return formatRequestsPerMinute(c.RequestsPerMinute) },
+		validate: func(value string) (string, error) {
+			perMinute, err := strconv.Atoi(value)
+			if err != nil || perMinute < 0 {
+				return "", fmt.Errorf("requests per minute must be a non-negative integer (0 means unlimited)")
+			}
+			return value, nil
+		},
+	},
+	{
+		name: envExtraHeaders, typ: configTypeString, helpDefault: "none", file: true, field: "ExtraHeaders",
+		help:       `Headers sent with every prompt, as "Key: Value; Key: Value"`,
+		constraint: `"Key: Value" pairs separated by semicolons`,
+		load: func(c *Config, r *configReader) {
+			c.ExtraHeaders = parseHeaderList(r.checked(envExtraHeaders, validateHeaderList))
+		},
+		value:    func(c *Config) string { return formatHeaderList(c.ExtraHeaders) },
+		validate: validateWith(validateHeaderList),
+	},
+	{
+		name: envEmbedModel, typ: configTypeString, def: defaultEmbedModel, helpDefault: defaultEmbedModel + ", or " + defaultOllamaEmbedModel + " with ollama", file: true, field: "EmbedModel",
+		help:       "Model used by embed",
+		constraint: "the default depends on OPENAI_PROVIDER",
+		load: func(c *Config, r *configReader) {
+			c.EmbedModel = getEnvOrFileOrDefault(envEmbedModel, r.file[envEmbedModel], defaultEmbedModelFor(c.Provider))
+		},
+		value:    func(c *Config) string { return c.EmbedModel },
+		validate: validateNotEmpty("embed model cannot be empty"),
+	},
+	{
+		name: envConnectTimeout, typ: configTypeDuration, def: defaultConnectTimeout.String(), constraint: "positive", file: true, field: "ConnectTimeout",
+		help: "Time allowed to connect, TLS handshake included",
+		load: func(c *Config, r *configReader) {
+			c.ConnectTimeout = parseDurationOrDefault(r.checked(envConnectTimeout, checkDuration), defaultConnectTimeout)
+		},
+		value: func(c *Config) string { return connectTimeout(c).String() },
+		validate: func(value string) (string, error) {
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid connect timeout format (use format like '10s', '500ms'): %w", err)
+			}
+			if timeout <= 0 {
+				return "", fmt.Errorf("connect timeout must be positive")
+			}
+			return value, nil
+		},
+	},
+	{
+		name: envImageModel, typ: configTypeString, def: defaultImageModel, file: true, field: "ImageModel",
+		help: "Model used by image",
+		load: func(c *Config, r *configReader) {
+			c.ImageModel = getEnvOrFileOrDefault(envImageModel, r.file[envImageModel], defaultImageModel)
+		},
+		value:    func(c *Config) string { return c.ImageModel },
+		validate: validateNotEmpty("image model cannot be empty"),
+	},
+	{
+		name: envTranscribeModel, typ: configTypeString, def: defaultTranscribeModel, file: true, field: "TranscribeModel",
+		help: "Model used by transcribe",
+		load: func(c *Config, r *configReader) {
+			c.TranscribeModel = getEnvOrFileOrDefault(envTranscribeModel, r.file[envTranscribeModel], defaultTranscribeModel)
+		},
+		value:    func(c *Config) string { return c.TranscribeModel },
+		validate: validateNotEmpty("transcribe model cannot be empty"),
+	},
+	{
+		name: envTranscribeTimeout, typ: configTypeDuration, def: defaultTranscribeTimeout.String(), constraint: "positive", file: true, field: "TranscribeTimeout",
+		help: "Overall timeout of transcribe, upload included",
+		load: func(c *Config, r *configReader) {
+			c.TranscribeTimeout = parseDurationOrDefault(r.checked(envTranscribeTimeout, checkDuration), defaultTranscribeTimeout)
+		},
+		value: func(c *Config) string { return c.TranscribeTimeout.String() },
+		validate: func(value string) (string, error) {
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid transcribe timeout format (use format like '10m', '90s'): %w", err)
+			}
+			if timeout <= 0 {
+				return "", fmt.Errorf("transcribe timeout must be positive")
+			}
+			return value, nil
+		},
+	},
+	{
+		name: envModerate, typ: configTypeBool, def: strconv.FormatBool(defaultModerate), label: "moderate", file: true, field: "Moderate",
+		help: "Check prompts with the moderations endpoint first, like prompt --moderate",
+		load: func(c *Config, r *configReader) {
+			c.Moderate = parseBoolOrDefault(r.checked(envModerate, checkBool), defaultModerate)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.Moderate) },
+	},
+	{
+		name: envRedact, typ: configTypeBool, def: strconv.FormatBool(defaultRedact), label: "redact", file: true, field: "Redact",
+		help: "Redact API keys, credentials and emails in logs, like --no-redact when false",
+		load: func(c *Config, r *configReader) {
+			c.Redact = parseBoolOrDefault(r.checked(envRedact, checkBool), defaultRedact)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.Redact) },
+	},
+	{
+		name: envNotify, typ: configTypeBool, def: strconv.FormatBool(defaultNotify), label: "notify", file: true, field: "Notify",
+		help: "Show a desktop notification when a slow prompt completes, like prompt --notify",
+		load: func(c *Config, r *configReader) {
+			c.Notify = parseBoolOrDefault(r.checked(envNotify, checkBool), defaultNotify)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.Notify) },
+	},
+	{
+		name: envNotifyAfter, typ: configTypeDuration, def: defaultNotifyAfter.String(), constraint: "not negative", file: true, field: "NotifyAfter",
+		help: "Shortest request time that triggers a notification",
+		load: func(c *Config, r *configReader) {
+			c.NotifyAfter = parseDurationOrDefault(r.checked(envNotifyAfter, checkDuration), defaultNotifyAfter)
+		},
+		value: func(c *Config) string { return c.NotifyAfter.String() },
+		validate: func(value string) (string, error) {
+			after, err := time.ParseDuration(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid notify after format (use format like '5s', '1m'): %w", err)
+			}
+			if after < 0 {
+				return "", fmt.Errorf("notify after cannot be negative")
+			}
+			return value, nil
+		},
+	},
+	{
+		name: envURLMaxChars, typ: configTypeInt, def: strconv.Itoa(defaultURLMaxChars), min: bound(0), label: "url max chars", constraint: "0 means no limit", file: true, field: "URLMaxChars",
+		help: "Characters of a page kept by prompt --url, 0 for no limit",
+		load: func(c *Config, r *configReader) {
+			c.URLMaxChars = parseIntOrDefault(r.checked(envURLMaxChars, checkInt), defaultURLMaxChars)
+		},
+		value: func(c *Config) string { return strconv.Itoa(c.URLMaxChars) },
+	},
+	{
+		name: envLogPreviewLen, typ: configTypeInt, def: strconv.Itoa(defaultLogPreviewLen), min: bound(0), label: "log preview len", constraint: "0 means no limit", file: true, field: "LogPreviewLen",
+		help: "Characters of prompts and responses shown by logs and history, 0 for no limit",
+		load: func(c *Config, r *configReader) {
+			c.LogPreviewLen = parseIntOrDefault(r.checked(envLogPreviewLen, checkInt), defaultLogPreviewLen)
+		},
+		value: func(c *Config) string { return strconv.Itoa(c.LogPreviewLen) },
+	},
+	{
+		name: envMaxResponseBytes, typ: configTypeSize, def: formatSize(defaultMaxResponseBytes), min: bound(0), constraint: "0 disables the limit", file: true, field: "MaxResponseBytes",
+		help: "Largest API response read, 0 for no limit",
+		load: func(c *Config, r *configReader) {
+			c.MaxResponseBytes = parseSizeOrDefault(r.checked(envMaxResponseBytes, checkSize), defaultMaxResponseBytes)
+		},
+		value:    func(c *Config) string { return formatSize(c.MaxResponseBytes) },
+		validate: validateSize("max response bytes must be a size like 10MB, 512KB or 10485760 (0 disables the limit)"),
+	},
+	{
+		name: envTimeFormat, typ: configTypeString, def: defaultTimeFormat, constraint: "a Go time layout", file: true, field: "TimeFormat",
+		help: "Go layout of the timestamps shown by logs",
+		load: func(c *Config, r *configReader) {
+			c.TimeFormat = parseTimeFormatOrDefault(r.checked(envTimeFormat, checkTimeFormat), defaultTimeFormat)
+		},
+		value: func(c *Config) string { return c.TimeFormat },
+		validate: func(value string) (string, error) {
+			if err := checkTimeFormat(value); err != nil {
+				return "", fmt.Errorf("time format must be a Go time layout such as \"2006-01-02 15:04:05 MST\" or \"Jan 2 15:04\"")
+			}
+			return value, nil
+		},
+	},
+	{
+		name: envAutoTrim, typ: configTypeBool, def: strconv.FormatBool(defaultAutoTrim), label: "auto trim", file: true, field: "AutoTrim",
+		help: "Trim a prompt too long for the model's context and send it again",
+		load: func(c *Config, r *configReader) {
+			c.AutoTrim = parseBoolOrDefault(r.checked(envAutoTrim, checkBool), defaultAutoTrim)
+		},
+		value: func(c *Config) string { return strconv.FormatBool(c.AutoTrim) },
+	},
+	{
+		name: envRequestTemplate, typ: configTypeString, helpDefault: "not set", constraint: "a Go text/template file rendering JSON", file: true, field: "RequestTemplate",
+		help: "Template file rendering the body of chat requests, for a gateway",
+		load: func(c *Config, r *configReader) {
+			c.RequestTemplate = getEnvOrFileConfig(envRequestTemplate, r.file[envRequestTemplate])
+		},
+		value: func(c *Config) string {
+			if c.RequestTemplate == "" {
+				return "(not set)"
+			}
+			return c.RequestTemplate
+		},
+		validate: validateRequestTemplate,
+	},
+	{
+		name: envResponseJSONPath, typ: configTypeString, helpDefault: "not set", file: true, field: "ResponsePaths",
+		help:       `Paths of a gateway's reply text and usage, as "text=a.b; prompt_tokens=c.d"`,
+		constraint: `"name=path" pairs separated by semicolons, names text, prompt_tokens, completion_tokens or total_tokens`,
+		load: func(c *Config, r *configReader) {
+			c.ResponsePaths = loadResponsePaths(r.checked(envResponseJSONPath, checkResponsePaths))
+		},
+		value:    func(c *Config) string { return c.ResponsePaths.format() },
+		validate: validateWith(checkResponsePaths),
+	},
+
+	// Keys read from the environment only
+	{
+		name: envProfile, typ: configTypeString, def: defaultProfileName, field: "Profile",
+		help:  "Profile to use",
+		value: func(c *Config) string { return profileDisplayName(c.Profile) },
+		validate: func(string) (string, error) {
+			return "", fmt.Errorf("CHATGPT_CLI_PROFILE cannot be set via config set command. Use the --profile flag or the environment variable instead.")
+		},
+	},
+	{
+		name: envPassphrase, typ: configTypeString, secret: true,
+		help: "Passphrase of an encrypted API key, instead of prompting",
+	},
+	{
+		name: envDebug, typ: configTypeInt, min: bound(0), max: bound(debugBodies), constraint: "true means 1, false 0", field: "Debug",
+		help: "Print HTTP requests and responses, like -v (2: like -vv)",
+		load: func(c *Config, r *configReader) {
+			c.Debug = parseDebugLevel(r.checked(envDebug, checkDebugLevel))
+		},
+	},
+	{
+		name: envStrictConfig, typ: configTypeBool, def: "false",
+		help: "Fail instead of warning about values that can't be parsed",
+	},
+	{
+		name: envTraceFile, typ: configTypeString,
+		help: "File each run appends OpenTelemetry-style spans to, as JSON lines",
+	},
+	{
+		name: envAutocorrect, typ: configTypeBool, def: "false",
+		help: "Run the command a mistyped one is closest to, instead of suggesting it",
+	},
+	{
+		name: envConfigDir, typ: configTypeString, def: "~/.chatgpt-cli", field: "ConfigDir",
+		help:  "Config directory",
+		value: func(c *Config) string { return c.ConfigDir },
+		validate: func(string) (string, error) {
+			return "", fmt.Errorf("CHATGPT_CLI_CONFIG_DIR cannot be set via config set command. Use the environment variable instead.")
+		},
+	},
+}
+
+// configFileKeys are the keys persisted in the config file, in the order
+// they are written
+var configFileKeys = configKeyNames(func(k configKey) bool { return k.file })
+
+// configKeyNames returns the names of the keys for which keep is true
+func configKeyNames(keep func(configKey) bool) []string {
+	var names []string
+	for _, key := range configKeys {
+		if keep(key) {
+			names = append(names, key.name)
+		}
+	}
+	return names
+}
+
+// lookupConfigKey returns the key named name
+func lookupConfigKey(name string) (configKey, bool) {
+	for _, key := range configKeys {
+		if key.name == name {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+// settableConfigKeys returns the keys config set takes
+func settableConfigKeys() []string {
+	return configKeyNames(func(k configKey) bool { return k.file && !k.internal })
+}
+
+// check validates a value of config set by the key's own check, or else by
+// its type, values and bounds
+func (k configKey) check(value string) (string, error) {
+	if k.validate != nil {
+		return k.validate(value)
+	}
+	switch k.typ {
+	case configTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "", fmt.Errorf("%s must be true or false", k.label)
+		}
+	case configTypeEnum:
+		if !containsString(k.values, value) {
+			if len(k.values) == 2 {
+				return "", fmt.Errorf("%s must be %s or %s", k.label, k.values[0], k.values[1])
+			}
+			return "", fmt.Errorf("%s must be one of: %s", k.label, strings.Join(k.values, ", "))
+		}
+	case configTypeInt:
+		n, err := strconv.Atoi(value)
+		switch {
+		case k.min != nil && *k.min == 1 && (err != nil || n < 1):
+			return "", fmt.Errorf("%s must be a positive integer", k.label)
+		case k.min != nil && *k.min == 0 && (err != nil || n < 0):
+			return "", fmt.Errorf("%s must be a non-negative integer", k.label)
+		case err != nil:
+			return "", fmt.Errorf("%s must be an integer", k.label)
+		}
+	case configTypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || (k.min != nil && f < *k.min) || (k.max != nil && f > *k.max) {
+			return "", fmt.Errorf("%s must be a number between %.1f and %.1f", k.label, *k.min, *k.max)
+		}
+	}
+	return value, nil
+}
+
+// helpLine returns the line of the key in the help text
+func (k configKey) helpLine() string {
+	line := fmt.Sprintf("    %-20s - %s", k.name, k.help)
+	// Switches read from the environment only go without their default
+	switch {
+	case k.helpDefault != "":
+		line += " (default: " + k.helpDefault + ")"
+	case k.def != "" && k.value != nil:
+		line += " (default: " + k.def + ")"
+	}
+	return line
+}
+
+// configHelp returns the lines of the help text describing each key
+func configHelp() string {
+	var lines []string
+	for _, key := range configKeys {
+		if !key.internal {
+			lines = append(lines, key.helpLine())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateAPIKey checks an API key given to config set
+func validateAPIKey(value string) (string, error) {
+	value = normalizeAPIKey(value)
+	if value == "" {
+		return "", fmt.Errorf("API key cannot be empty")
+	}
+	return value, nil
+}
+
+// validateWith turns a check of a value into a validator of config set
+func validateWith(check func(string) error) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if err := check(value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+}
+
+// validateNotEmpty returns a validator rejecting an empty value with message
+func validateNotEmpty(message string) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if value == "" {
+			return "", fmt.Errorf("%s", message)
+		}
+		return value, nil
+	}
+}
+
+// validateURL returns a validator of an http or https URL named label
+func validateURL(label string) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+			return "", fmt.Errorf("%s must start with http:// or https://", label)
+		}
+		return value, nil
+	}
+}
+
+// validateID returns a validator of an organization or project ID
+func validateID(key string) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if value == "" || strings.ContainsAny(value, " \t\r\n") {
+			return "", fmt.Errorf("%s cannot be empty or contain spaces", key)
+		}
+		return value, nil
+	}
+}
+
+// validateSize returns a validator of a size, rejecting others with message
+func validateSize(message string) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if size, err := parseSize(value); err != nil || size < 0 {
+			return "", fmt.Errorf("%s", message)
+		}
+		return value, nil
+	}
+}
+
+// validatePromptText returns a validator of the prompt prefix or suffix
+func validatePromptText(key string) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("%s cannot be empty; remove it with config unset", key)
+		}
+		return value, nil
+	}
+}
+
+// ConfigKeySchema is a key of the JSON output of config schema
+type ConfigKeySchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Default is null for keys that are not set by default
+	Default     *string  `json:"default"`
+	Values      []string `json:"values,omitempty"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	Constraint  string   `json:"constraint,omitempty"`
+	Secret      bool     `json:"secret"`
+	Env         string   `json:"env"`
+	ConfigFile  bool     `json:"config_file"`
+	ModelScoped bool     `json:"model_scoped"`
+	Description string   `json:"description"`
+}
+
+// configSchema describes every key for config schema
+func configSchema() []ConfigKeySchema {
+	schema := make([]ConfigKeySchema, 0, len(configKeys))
+	for _, key := range configKeys {
+		entry := ConfigKeySchema{
+			Name:        key.name,
+			Type:        key.typ,
+			Values:      key.values,
+			Min:         key.min,
+			Max:         key.max,
+			Constraint:  key.constraint,
+			Secret:      key.secret,
+			Env:         key.name,
+			ConfigFile:  key.file,
+			ModelScoped: key.modelScoped,
+			Description: key.help,
+		}
+		if key.def != "" {
+			def := key.def
+			entry.Default = &def
+		}
+		schema = append(schema, entry)
+	}
+	return schema
+}
+
+// configSchemaCommand describes every configuration key: its type, default
+// and accepted values
+func configSchemaCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("config schema takes no arguments\nUsage: chatgpt-cli config schema [--output json]")
+	}
+	schema := configSchema()
+	if config.Output == outputJSON {
+		return printJSON(schema)
+	}
+
+	out := newUI(config, os.Stdout)
+	t := newTable("KEY", "TYPE", "DEFAULT", "ACCEPTS")
+	for _, key := range schema {
+		def := "-"
+		if key.Default != nil {
+			def = *key.Default
+		}
+		t.addRow(key.Name, key.Type, def, schemaAccepts(key))
+	}
+	t.render(out)
+	return nil
+}
+
+// schemaAccepts describes the values a key accepts on one line
+func schemaAccepts(key ConfigKeySchema) string {
+	var parts []string
+	if len(key.Values) > 0 {
+		parts = append(parts, strings.Join(key.Values, ", "))
+	}
+	switch {
+	case key.Min != nil && key.Max != nil:
+		parts = append(parts, fmt.Sprintf("%g to %g", *key.Min, *key.Max))
+	case key.Min != nil:
+		parts = append(parts, fmt.Sprintf("at least %g", *key.Min))
+	}
+	if key.Constraint != "" {
+		parts = append(parts, key.Constraint)
+	}
+	var notes []string
+	if key.Secret {
+		notes = append(notes, "secret")
+	}
+	if !key.ConfigFile {
+		notes = append(notes, "environment only")
+	}
+	if key.ModelScoped {
+		notes = append(notes, "per model")
+	}
+	sort.Strings(notes)
+	accepts := strings.Join(parts, "; ")
+	if len(notes) > 0 {
+		accepts = strings.TrimSpace(accepts + " (" + strings.Join(notes, ", ") + ")")
+	}
+	return accepts
+}
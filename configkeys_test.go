@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Fields of Config set by commands and their flags rather than configuration
+var runtimeConfigFields = []string{
+	"JSONResponse",
+	"Choices",
+	"Tools",
+	"ReasoningEffort",
+	"NoWait",
+	"AllowHeaderOverride",
+	"SystemPrompt",
+	"History",
+	"Messages",
+}
+
+// TestConfigKeysCoverConfig tests that every configurable field of Config is
+// set by a key of the registry, and that every field a key names exists
+func TestConfigKeysCoverConfig(t *testing.T) {
+	fields := make(map[string]bool)
+	for _, key := range configKeys {
+		if key.field != "" {
+			fields[key.field] = true
+		}
+	}
+
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() || containsString(runtimeConfigFields, field.Name) {
+			continue
+		}
+		if !fields[field.Name] {
+			t.Errorf("Config.%s is not set by any key of configKeys", field.Name)
+		}
+	}
+	for field := range fields {
+		if _, ok := typ.FieldByName(field); !ok {
+			t.Errorf("configKeys names Config.%s, which does not exist", field)
+		}
+	}
+}
+
+// TestConfigKeysConsistent tests the registry against itself: names are
+// unique, defaults pass config set, and keys shown have a value
+func TestConfigKeysConsistent(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, key := range configKeys {
+		if seen[key.name] {
+			t.Errorf("%s is registered twice", key.name)
+		}
+		seen[key.name] = true
+
+		if key.help == "" {
+			t.Errorf("%s has no help", key.name)
+		}
+		if key.modelScoped && (key.load == nil || !key.file) {
+			t.Errorf("%s is model scoped but not loaded from the config file", key.name)
+		}
+		if key.typ == configTypeEnum && len(key.values) == 0 {
+			t.Errorf("%s is an enum without values", key.name)
+		}
+		if key.file && !key.internal && key.def != "" {
+			if _, err := key.check(key.def); err != nil {
+				t.Errorf("default %q of %s is rejected by config set: %v", key.def, key.name, err)
+			}
+		}
+	}
+
+	help := configHelp()
+	for _, key := range configKeys {
+		if !key.internal && !strings.Contains(help, key.name+" ") {
+			t.Errorf("help does not describe %s", key.name)
+		}
+	}
+}
+
+// TestValidateConfigValueFromRegistry tests the messages of the checks
+// derived from a key's type and bounds
+func TestValidateConfigValueFromRegistry(t *testing.T) {
+	tests := []struct {
+		key, value, want string
+	}{
+		{envStream, "maybe", "stream must be true or false"},
+		{envMaxTokens, "0", "max tokens must be a positive integer"},
+		{envLogMaxFiles, "-1", "log max files must be a non-negative integer"},
+		{envSeed, "x", "seed must be an integer"},
+		{envTemperature, "2.5", "temperature must be a number between 0.0 and 2.0"},
+		{envPresencePenalty, "-3", "presence penalty must be a number between -2.0 and 2.0"},
+		{envOutput, "yaml", "output must be plain or json"},
+		{envDebug, "1", "unknown configuration key"},
+		{"NOT_A_KEY", "1", "Valid keys: OPENAI_API_KEY, ANTHROPIC_API_KEY, OPENAI_API_URL"},
+	}
+	for _, tt := range tests {
+		if _, err := validateConfigValue(tt.key, tt.value); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("validateConfigValue(%s, %q) error = %v, want %q", tt.key, tt.value, err, tt.want)
+		}
+	}
+	if value, err := validateConfigValue(envProvider, "Azure"); err != nil || value != providerAzure {
+		t.Errorf("validateConfigValue(provider, Azure) = %q, %v", value, err)
+	}
+}
+
+// TestConfigSchemaCommand tests the JSON description of the keys
+func TestConfigSchemaCommand(t *testing.T) {
+	config := &Config{Output: outputJSON}
+	var err error
+	output := captureOutput(t, &os.Stdout, func() {
+		err = configCommand(config, []string{"schema"})
+	})
+	if err != nil {
+		t.Fatalf("config schema error = %v", err)
+	}
+
+	var schema []ConfigKeySchema
+	if err := json.Unmarshal([]byte(output), &schema); err != nil {
+		t.Fatalf("config schema output is not JSON: %v\n%s", err, output)
+	}
+	if len(schema) != len(configKeys) {
+		t.Fatalf("config schema described %d keys, want %d", len(schema), len(configKeys))
+	}
+	keys := make(map[string]ConfigKeySchema)
+	for _, key := range schema {
+		keys[key.Name] = key
+	}
+
+	temperature := keys[envTemperature]
+	if temperature.Type != configTypeFloat || temperature.Default == nil || *temperature.Default != "0.7" ||
+		temperature.Min == nil || *temperature.Min != 0 || temperature.Max == nil || *temperature.Max != 2 || !temperature.ModelScoped {
+		t.Errorf("OPENAI_TEMPERATURE = %+v", temperature)
+	}
+	if key := keys[envAPIKey]; !key.Secret || key.Default != nil || !key.ConfigFile {
+		t.Errorf("OPENAI_API_KEY = %+v", key)
+	}
+	if key := keys[envProvider]; key.Type != configTypeEnum || strings.Join(key.Values, ",") != "openai,azure,anthropic,ollama" {
+		t.Errorf("OPENAI_PROVIDER = %+v", key)
+	}
+	if key := keys[envTraceFile]; key.ConfigFile || key.Env != envTraceFile {
+		t.Errorf("CHATGPT_CLI_TRACE_FILE = %+v", key)
+	}
+
+	if err := configCommand(config, []string{"schema", "extra"}); err == nil {
+		t.Error("config schema with an argument succeeded")
+	}
+}
+
+// TestConfigSchemaPlain tests the tab-separated listing of the keys
+func TestConfigSchemaPlain(t *testing.T) {
+	output := captureOutput(t, &os.Stdout, func() {
+		if err := configSchemaCommand(&Config{}, nil); err != nil {
+			t.Error(err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if lines[0] != "KEY\tTYPE\tDEFAULT\tACCEPTS" {
+		t.Errorf("header = %q", lines[0])
+	}
+	for _, want := range []string{
+		"OPENAI_TEMPERATURE\tfloat\t0.7\t0 to 2 (per model)",
+		"OPENAI_API_KEY\tstring\t-\t(secret)",
+		"CHATGPT_CLI_TRACE_FILE\tstring\t-\t(environment only)",
+	} {
+		if !strings.Contains(output, want+"\n") {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "os"
+
+// Where a value shown by config list --show-origin comes from, besides the
+// sources of configuration problems
+const (
+	configSourceProfile = "profile"
+	configSourceFlag    = "flag"
+)
+
+// ConfigValueOrigin is a value of config list --show-origin --output json
+type ConfigValueOrigin struct {
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+}
+
+// origin returns where the value of key comes from, leaving out the values
+// set for a model, which depend on the model in use: the environment, the
+// profile's section of the config file, its default section, or the
+// built-in default
+func (r *configReader) origin(key string) string {
+	switch {
+	case os.Getenv(key) != "":
+		return configSourceEnv
+	case r.file[key] == "":
+		return configSourceDefault
+	case r.profileKeys[key]:
+		return configSourceProfile + " " + r.profile
+	}
+	return configSourceFile
+}
+
+// apiKeyOrigin returns where the API key comes from, as found by
+// resolveAPIKey: the environment, a credential store named as such, or the
+// config file
+func (r *configReader) apiKeyOrigin(provider, source string) string {
+	switch source {
+	case "":
+		return configSourceDefault
+	case apiKeySourceEnv:
+		return configSourceEnv
+	case apiKeySourceFile:
+		if r.profileKeys[apiKeyName(provider)] || r.profileKeys[encryptedAPIKeyKey] {
+			return configSourceProfile + " " + r.profile
+		}
+		return configSourceFile
+	}
+	return source
+}
+
+// configOrigins returns where the values shown by config list come from,
+// with those set for the model in use marked as such
+func configOrigins(config *Config) map[string]string {
+	origins := make(map[string]string, len(configKeys))
+	for _, key := range configKeys {
+		origins[key.name] = configSourceDefault
+		if origin, ok := config.origins[key.name]; ok {
+			origins[key.name] = origin
+		}
+	}
+	for key := range modelScopedValues(config) {
+		origins[key] = configSourceModel + " " + config.Model
+	}
+	return origins
+}
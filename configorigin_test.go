@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testOriginsConfig sets max_tokens in the default section, a profile and
+// for a model, and timeout in the default section only
+const testOriginsConfig = `api_key = "sk-file-1234567890"
+max_tokens = 500
+timeout = "30s"
+
+[profile.work]
+api_key = "sk-work-1234567890"
+max_tokens = 800
+
+[models.gpt-4]
+max_tokens = 2000
+`
+
+// TestConfigOrigins tests where loadConfig says each value comes from, for
+// each combination of the environment, the profile, the model's values, the
+// default section and the built-in default
+func TestConfigOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		env     map[string]string
+		key     string
+		want    string
+	}{
+		{name: "built-in default", key: envTemperature, want: configSourceDefault},
+		{name: "default section", key: envMaxTokens, want: configSourceFile},
+		{name: "profile over default section", profile: "work", key: envMaxTokens, want: "profile work"},
+		{name: "default section under a profile", profile: "work", key: envTimeout, want: configSourceFile},
+		{name: "env over default section", env: map[string]string{envTimeout: "5s"}, key: envTimeout, want: configSourceEnv},
+		{name: "env over profile", profile: "work", env: map[string]string{envMaxTokens: "100"}, key: envMaxTokens, want: configSourceEnv},
+		{name: "model over default section", env: map[string]string{envModel: "gpt-4"}, key: envMaxTokens, want: "model gpt-4"},
+		{name: "model over profile", profile: "work", env: map[string]string{envModel: "gpt-4"}, key: envMaxTokens, want: "model gpt-4"},
+		{name: "env over model", env: map[string]string{envModel: "gpt-4", envMaxTokens: "100"}, key: envMaxTokens, want: configSourceEnv},
+		{name: "env only", env: map[string]string{envModel: "gpt-4"}, key: envModel, want: configSourceEnv},
+		{name: "invalid env value", env: map[string]string{envMaxTokens: "lots"}, key: envMaxTokens, want: configSourceDefault},
+		{name: "profile flag", profile: "work", key: envProfile, want: configSourceFlag},
+		{name: "profile env", env: map[string]string{envProfile: "work"}, key: envProfile, want: configSourceEnv},
+		{name: "api key in default section", key: envAPIKey, want: configSourceFile},
+		{name: "api key in profile", profile: "work", key: envAPIKey, want: "profile work"},
+		{name: "api key env", env: map[string]string{envAPIKey: "sk-env-1234567890"}, key: envAPIKey, want: configSourceEnv},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanup := setupTestEnv(t)
+			defer cleanup()
+
+			dir := t.TempDir()
+			writeTestConfigFile(t, dir, testOriginsConfig)
+			setTestEnv(envConfigDir, dir)
+			for key, value := range tt.env {
+				setTestEnv(key, value)
+			}
+
+			config, err := loadConfig(tt.profile)
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			if got := configOrigins(config)[tt.key]; got != tt.want {
+				t.Errorf("origin of %s = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestConfigOriginStoredAPIKey tests that a key saved by auth login is said
+// to come from its credential store
+func TestConfigOriginStoredAPIKey(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	writeTestConfigFile(t, dir, testOriginsConfig)
+	setTestEnv(envConfigDir, dir)
+	if err := newCredentialStore(dir).Set(defaultProfileName, "sk-stored-1234567890"); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if got := configOrigins(config)[envAPIKey]; got != "credentials file" {
+		t.Errorf("origin of a stored key = %q, want the credentials file", got)
+	}
+}
+
+// TestConfigListShowOrigin tests the origin column of config list and the
+// origin printed by config get --origin
+func TestConfigListShowOrigin(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	writeTestConfigFile(t, dir, testOriginsConfig)
+	setTestEnv(envConfigDir, dir)
+	setTestEnv(envTimeout, "5s")
+	config, err := loadConfig("work")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	output := captureOutput(t, &os.Stdout, func() {
+		if err := configListCommand(config, []string{"--show-origin"}); err != nil {
+			t.Errorf("config list --show-origin error = %v", err)
+		}
+	})
+	for _, want := range []string{
+		"env\tOPENAI_TIMEOUT\t5s\n",
+		"profile work\tOPENAI_MAX_TOKENS\t800\n",
+		"default\tOPENAI_TEMPERATURE\t0.7\n",
+		"flag\tCHATGPT_CLI_PROFILE\twork\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("config list --show-origin output does not contain %q:\n%s", want, output)
+		}
+	}
+
+	// --model marks the values set for it, and the model itself as a flag
+	output = captureOutput(t, &os.Stdout, func() {
+		if err := configListCommand(config, []string{"--show-origin", "--model", "gpt-4"}); err != nil {
+			t.Errorf("config list --show-origin --model error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "model gpt-4\tOPENAI_MAX_TOKENS\t2000\n") || !strings.Contains(output, "flag\tOPENAI_MODEL\tgpt-4\n") {
+		t.Errorf("config list --show-origin --model output:\n%s", output)
+	}
+
+	output = captureOutput(t, &os.Stdout, func() {
+		if err := configGetCommand(config, []string{"openai_timeout", "--origin"}); err != nil {
+			t.Errorf("config get --origin error = %v", err)
+		}
+	})
+	if output != "env\n" {
+		t.Errorf("config get --origin = %q, want %q", output, "env\n")
+	}
+
+	config.Output = outputJSON
+	output = captureOutput(t, &os.Stdout, func() {
+		if err := configListCommand(config, []string{"--show-origin"}); err != nil {
+			t.Errorf("config list --show-origin error = %v", err)
+		}
+	})
+	var values map[string]ConfigValueOrigin
+	if err := json.Unmarshal([]byte(output), &values); err != nil {
+		t.Fatalf("config list --show-origin JSON = %q: %v", output, err)
+	}
+	if values[envTimeout] != (ConfigValueOrigin{Value: "5s", Origin: configSourceEnv}) {
+		t.Errorf("OPENAI_TIMEOUT = %+v", values[envTimeout])
+	}
+}
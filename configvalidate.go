@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Environment variable turning configuration values that can't be parsed
+// into an error instead of a warning
+const envStrictConfig = "CHATGPT_CLI_STRICT_CONFIG"
+
+// Where a configuration value comes from
+const (
+	configSourceEnv     = "env"
+	configSourceFile    = "file"
+	configSourceModel   = "model"
+	configSourceDefault = "default"
+)
+
+// configProblem is a configuration value that could not be parsed, so the
+// default is used instead
+type configProblem struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Error  string `json:"error"`
+}
+
+// String describes the problem on one line
+func (p configProblem) String() string {
+	from := "the config file"
+	switch p.Source {
+	case configSourceEnv:
+		from = "the environment"
+	case configSourceModel:
+		from = "the config file's model values"
+	}
+	return fmt.Sprintf("%s=%q from %s: %s", p.Key, p.Value, from, p.Error)
+}
+
+// configReader reads configuration values from the environment, then the
+// values set for the model in use, then the config file, recording the
+// values that fail their check
+type configReader struct {
+	file map[string]string
+	// profile is the selected profile, and profileKeys the keys of file set
+	// by its section; see origin
+	profile     string
+	profileKeys map[string]bool
+	model       string
+	models      map[string]map[string]string
+	problems    []configProblem
+}
+
+// lookup returns the value of key and where it comes from
+func (r *configReader) lookup(key string) (string, string) {
+	if value := os.Getenv(key); value != "" {
+		return value, configSourceEnv
+	}
+	if value := r.models[r.model][key]; value != "" {
+		return value, configSourceModel
+	}
+	if value := r.file[key]; value != "" {
+		return value, configSourceFile
+	}
+	return "", configSourceDefault
+}
+
+// checked returns the value of key, recording it as a problem if check
+// rejects it. The caller's parser then falls back to the default.
+func (r *configReader) checked(key string, check func(string) error) string {
+	value, source := r.lookup(key)
+	if value == "" {
+		return ""
+	}
+	if err := check(value); err != nil {
+		name := key
+		if source == configSourceModel {
+			name = modelScopedName(r.model, key)
+		}
+		r.problems = append(r.problems, configProblem{Key: name, Value: value, Source: source, Error: err.Error()})
+	}
+	return value
+}
+
+func checkInt(value string) error {
+	if _, err := strconv.Atoi(value); err != nil {
+		return errors.New("not an integer")
+	}
+	return nil
+}
+
+func checkFloat(value string) error {
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return errors.New("not a number")
+	}
+	return nil
+}
+
+func checkBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return errors.New("not true or false")
+	}
+	return nil
+}
+
+func checkDuration(value string) error {
+	if _, err := time.ParseDuration(value); err != nil {
+		return errors.New("not a duration such as 60s or 2m")
+	}
+	return nil
+}
+
+func checkSize(value string) error {
+	if size, err := parseSize(value); err != nil || size < 0 {
+		return errors.New("not a size such as 1MB, 512KB or a byte count")
+	}
+	return nil
+}
+
+func checkTimeFormat(value string) error {
+	if layoutProbe.Format(value) == value {
+		return errors.New("not a Go time layout such as 2006-01-02 15:04:05")
+	}
+	return nil
+}
+
+func checkOutput(value string) error {
+	if parseOutputOrDefault(value, "") == "" {
+		return fmt.Errorf("not %s or %s", outputPlain, outputJSON)
+	}
+	return nil
+}
+
+func checkProvider(value string) error {
+	if parseProviderOrDefault(value, "") == "" {
+		return fmt.Errorf("not one of: %s", strings.Join(validProviders, ", "))
+	}
+	return nil
+}
+
+func checkStopSequences(value string) error {
+	_, err := parseStopSequences(value)
+	return err
+}
+
+func checkDebugLevel(value string) error {
+	if checkBool(value) != nil && parseDebugLevel(value) == 0 {
+		return errors.New("not true, false or a positive level")
+	}
+	return nil
+}
+
+// strictConfig reports whether CHATGPT_CLI_STRICT_CONFIG is enabled
+func strictConfig() bool {
+	return parseBoolOrDefault(os.Getenv(envStrictConfig), false)
+}
+
+// reportConfigProblems warns on stderr about every value loadConfig could not
+// parse, or fails with all of them if strict
+func reportConfigProblems(config *Config, strict bool) error {
+	if len(config.problems) == 0 {
+		return nil
+	}
+
+	if strict {
+		lines := make([]string, len(config.problems))
+		for i, problem := range config.problems {
+			lines[i] = problem.String()
+		}
+		return fmt.Errorf("invalid configuration (%s is enabled):\n  %s", envStrictConfig, strings.Join(lines, "\n  "))
+	}
+
+	out := newUI(config, os.Stderr)
+	for _, problem := range config.problems {
+		out.Printf("%s ignoring %s\n", out.yellow("Warning:"), problem)
+	}
+	return nil
+}
+
+// validatesConfig reports whether the command is config validate, which
+// reports the problems itself
+func validatesConfig(commandName string, args []string) bool {
+	return commandName == "config" && len(args) > 0 && args[0] == "validate"
+}
+
+// editsConfig reports whether the command can fix an invalid value in the
+// config file, so strict mode only warns about it
+func editsConfig(commandName string, args []string) bool {
+	if commandName != "config" || len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "set", "unset", "reset", "set-default", "unset-default":
+		return true
+	}
+	return false
+}
+
+// ConfigValidation is the JSON output of config validate
+type ConfigValidation struct {
+	Valid    bool            `json:"valid"`
+	Problems []configProblem `json:"problems"`
+}
+
+// configValidateCommand reports every configuration value that could not be
+// parsed, with where it comes from, and fails if there is any
+func configValidateCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("config validate takes no arguments\nUsage: chatgpt-cli config validate")
+	}
+
+	problems := config.problems
+	if problems == nil {
+		problems = []configProblem{}
+	}
+
+	if config.Output == outputJSON {
+		if err := printJSON(ConfigValidation{Valid: len(problems) == 0, Problems: problems}); err != nil {
+			return err
+		}
+	} else {
+		out := newUI(config, os.Stdout)
+		if len(problems) == 0 {
+			out.Println("Configuration is valid.")
+			return nil
+		}
+		out.heading("Invalid configuration values:")
+		for _, problem := range problems {
+			out.Printf("  %s %s\n", out.red("✗"), problem)
+		}
+		out.Println(out.dim("The defaults are used instead of these values."))
+	}
+
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("1 invalid configuration value")
+	}
+	return fmt.Errorf("%d invalid configuration values", len(problems))
+}
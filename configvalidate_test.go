@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestLoadConfigProblems tests that values which fail to parse fall back to
+// their default and are recorded with their source
+func TestLoadConfigProblems(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	writeTestConfigFile(t, tmpDir, "temperature = \"warm\"\nstream = \"yes please\"\nlog_max_size = \"5MB\"\n")
+	setTestEnv(envMaxTokens, "10O0")
+	setTestEnv(envTimeout, "30")
+	setTestEnv(envStream, "false")
+
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	if config.MaxTokens != defaultMaxTokens || config.Temperature != defaultTemperature || config.Timeout != defaultTimeout {
+		t.Errorf("MaxTokens = %d, Temperature = %v, Timeout = %v, want the defaults", config.MaxTokens, config.Temperature, config.Timeout)
+	}
+
+	// The stream value in the file is invalid, but the environment overrides it
+	got := make(map[string]configProblem)
+	for _, problem := range config.problems {
+		got[problem.Key] = problem
+	}
+	want := map[string]configProblem{
+		envTimeout:     {Key: envTimeout, Value: "30", Source: configSourceEnv, Error: "not a duration such as 60s or 2m"},
+		envMaxTokens:   {Key: envMaxTokens, Value: "10O0", Source: configSourceEnv, Error: "not an integer"},
+		envTemperature: {Key: envTemperature, Value: "warm", Source: configSourceFile, Error: "not a number"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("problems = %+v, want %d", config.problems, len(want))
+	}
+	for key, problem := range want {
+		if got[key] != problem {
+			t.Errorf("problem %s = %+v, want %+v", key, got[key], problem)
+		}
+	}
+}
+
+// TestConfigChecks tests the checks of the values that are parsed
+func TestConfigChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func(string) error
+		value string
+		valid bool
+	}{
+		{"int", checkInt, "1000", true},
+		{"int typo", checkInt, "10O0", false},
+		{"float", checkFloat, "0.7", true},
+		{"float word", checkFloat, "warm", false},
+		{"bool", checkBool, "TRUE", true},
+		{"bool word", checkBool, "yes", false},
+		{"duration", checkDuration, "90s", true},
+		{"duration without unit", checkDuration, "90", false},
+		{"size", checkSize, "512KB", true},
+		{"negative size", checkSize, "-1", false},
+		{"output", checkOutput, "json", true},
+		{"unknown output", checkOutput, "yaml", false},
+		{"provider", checkProvider, "ollama", true},
+		{"unknown provider", checkProvider, "openia", false},
+		{"stop", checkStopSequences, `END, \n\n`, true},
+		{"stop unfinished escape", checkStopSequences, `END\`, false},
+		{"debug bool", checkDebugLevel, "false", true},
+		{"debug level", checkDebugLevel, "2", true},
+		{"debug word", checkDebugLevel, "loud", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check(tt.value); (err == nil) != tt.valid {
+				t.Errorf("check(%q) error = %v, want valid %v", tt.value, err, tt.valid)
+			}
+		})
+	}
+}
+
+// TestReportConfigProblems tests the warnings and the strict mode error
+func TestReportConfigProblems(t *testing.T) {
+	config := &Config{NoColor: true, problems: []configProblem{
+		{Key: envMaxTokens, Value: "10O0", Source: configSourceEnv, Error: "not an integer"},
+		{Key: envTemperature, Value: "warm", Source: configSourceFile, Error: "not a number"},
+	}}
+
+	var err error
+	out := captureOutput(t, &os.Stderr, func() {
+		err = reportConfigProblems(config, false)
+	})
+	if err != nil {
+		t.Fatalf("reportConfigProblems() error = %v", err)
+	}
+	wantOut := "Warning: ignoring OPENAI_MAX_TOKENS=\"10O0\" from the environment: not an integer\n" +
+		"Warning: ignoring OPENAI_TEMPERATURE=\"warm\" from the config file: not a number\n"
+	if out != wantOut {
+		t.Errorf("warnings = %q, want %q", out, wantOut)
+	}
+
+	out = captureOutput(t, &os.Stderr, func() {
+		err = reportConfigProblems(config, true)
+	})
+	if err == nil || !strings.Contains(err.Error(), envStrictConfig) || !strings.Contains(err.Error(), `OPENAI_TEMPERATURE="warm"`) {
+		t.Errorf("strict error = %v, want both problems", err)
+	}
+	if out != "" {
+		t.Errorf("strict mode printed %q, want no warnings", out)
+	}
+
+	if err := reportConfigProblems(&Config{}, true); err != nil {
+		t.Errorf("reportConfigProblems() without problems error = %v", err)
+	}
+}
+
+// TestEditsConfig tests which commands strict mode lets through
+func TestEditsConfig(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		want    bool
+	}{
+		{"config", []string{"set", "OPENAI_MAX_TOKENS", "1000"}, true},
+		{"config", []string{"unset", "OPENAI_MAX_TOKENS"}, true},
+		{"config", []string{"list"}, false},
+		{"config", nil, false},
+		{"prompt", []string{"set"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := editsConfig(tt.command, tt.args); got != tt.want {
+			t.Errorf("editsConfig(%s, %q) = %v, want %v", tt.command, tt.args, got, tt.want)
+		}
+	}
+}
+
+// TestConfigValidateCommand tests the report and result of config validate
+func TestConfigValidateCommand(t *testing.T) {
+	problem := configProblem{Key: envMaxTokens, Value: "10O0", Source: configSourceEnv, Error: "not an integer"}
+
+	t.Run("valid", func(t *testing.T) {
+		var err error
+		out := captureOutput(t, &os.Stdout, func() {
+			err = configValidateCommand(&Config{NoColor: true}, nil)
+		})
+		if err != nil || out != "Configuration is valid.\n" {
+			t.Errorf("output = %q, error = %v", out, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var err error
+		out := captureOutput(t, &os.Stdout, func() {
+			err = configValidateCommand(&Config{NoColor: true, problems: []configProblem{problem}}, nil)
+		})
+		if err == nil || err.Error() != "1 invalid configuration value" {
+			t.Errorf("error = %v, want 1 invalid configuration value", err)
+		}
+		if !strings.Contains(out, `OPENAI_MAX_TOKENS="10O0" from the environment: not an integer`) {
+			t.Errorf("output = %q, want the problem", out)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var err error
+		out := captureOutput(t, &os.Stdout, func() {
+			err = configValidateCommand(&Config{Output: outputJSON, problems: []configProblem{problem, problem}}, nil)
+		})
+		if err == nil || err.Error() != "2 invalid configuration values" {
+			t.Errorf("error = %v, want 2 invalid configuration values", err)
+		}
+		var result ConfigValidation
+		if err := json.Unmarshal([]byte(out), &result); err != nil {
+			t.Fatalf("output is not JSON: %v\n%s", err, out)
+		}
+		if result.Valid || len(result.Problems) != 2 || result.Problems[0] != problem {
+			t.Errorf("result = %+v", result)
+		}
+	})
+
+	t.Run("arguments", func(t *testing.T) {
+		if err := configValidateCommand(&Config{}, []string{"extra"}); err == nil || exitCode(err) != exitUsage {
+			t.Errorf("error = %v, want a usage error", err)
+		}
+	})
+}
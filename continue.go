@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// continueFlag is prompt --continue: alone it continues from the last
+// exchange, and --continue=N from the last N
+type continueFlag int
+
+func (c *continueFlag) String() string {
+	return strconv.Itoa(int(*c))
+}
+
+func (c *continueFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (c *continueFlag) Set(value string) error {
+	if b, err := strconv.ParseBool(value); err == nil {
+		*c = 0
+		if b {
+			*c = 1
+		}
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return errors.New("must be a positive number of exchanges, as in --continue=3")
+	}
+	*c = continueFlag(n)
+	return nil
+}
+
+// continuesFrom reports whether a log entry is an exchange prompt --continue
+// can send again: a prompt answered without error
+func continuesFrom(entry LogEntry) bool {
+	return entry.Command == "prompt" && entry.Error == "" && entry.Prompt != "" && entry.Response != ""
+}
+
+// readConversation returns the last n exchanges logged by prompt, oldest
+// first, as the user and assistant messages of a conversation
+func readConversation(configDir string, n int) ([]Message, error) {
+	logFiles, err := listLogFiles(configDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The logs are read oldest first, keeping the last n exchanges
+	var last []LogEntry
+	_, err = forEachLogEntry(logFiles, func(entry LogEntry) error {
+		if continuesFrom(entry) {
+			last = append(last, entry)
+			if len(last) > n {
+				last = last[1:]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, 2*len(last))
+	for _, entry := range last {
+		messages = append(messages,
+			Message{Role: "user", Content: entry.Prompt},
+			Message{Role: "assistant", Content: entry.Response})
+	}
+	return messages, nil
+}
+
+// loadConversation sets config.History to the last n exchanges for
+// prompt --continue. Fewer are sent if the logs don't have n.
+func loadConversation(config *Config, n int) error {
+	if config.LogDisabled {
+		return usageErrorf("--continue reads earlier prompts from the logs, but CHATGPT_CLI_LOG_DISABLED is set")
+	}
+	messages, err := readConversation(config.ConfigDir, n)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	if len(messages) == 0 {
+		return usageErrorf("--continue found no earlier prompt answered without error in the logs")
+	}
+	config.History = messages
+	return nil
+}
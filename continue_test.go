@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestContinueFlag tests the values of prompt --continue
+func TestContinueFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    continueFlag
+		wantErr bool
+	}{
+		{"true", 1, false},
+		{"false", 0, false},
+		{"3", 3, false},
+		{"0", 0, false},
+		{"-2", 0, true},
+		{"some", 0, true},
+	}
+
+	for _, tt := range tests {
+		var c continueFlag
+		err := c.Set(tt.value)
+		if (err != nil) != tt.wantErr || c != tt.want {
+			t.Errorf("Set(%q) = %d, %v; want %d, error %v", tt.value, c, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestReadConversation tests that only prompts answered without error are
+// continued from, oldest first
+func TestReadConversation(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestLogs(t, filepath.Join(tmpDir, "logs.jsonl"), []LogEntry{
+		{Command: "prompt", Prompt: "first", Response: "one"},
+		{Command: "prompt", Prompt: "second", Response: "two"},
+		{Command: "summarize", Prompt: "a text", Response: "a summary"},
+		{Command: "prompt", Prompt: "failed", Error: "rate limited"},
+		{Command: "prompt", Prompt: "third", Response: "three"},
+	})
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{1, "user:third assistant:three"},
+		{2, "user:second assistant:two user:third assistant:three"},
+		{5, "user:first assistant:one user:second assistant:two user:third assistant:three"},
+	}
+
+	for _, tt := range tests {
+		messages, err := readConversation(tmpDir, tt.n)
+		if err != nil {
+			t.Fatalf("readConversation(%d) error = %v", tt.n, err)
+		}
+		var got []string
+		for _, m := range messages {
+			got = append(got, m.Role+":"+m.Content)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("readConversation(%d) = %v, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+// TestPromptContinue tests that --continue sends the earlier exchanges and
+// logs the new one, so that the next --continue follows on from it
+func TestPromptContinue(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var request ChatRequest
+	replies := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = ChatRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		replies++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"reply ` + string(rune('0'+replies)) + `"}}]}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:    "sk-test",
+		APIURL:    server.URL,
+		Model:     "gpt-4o",
+		Timeout:   5 * time.Second,
+		ConfigDir: t.TempDir(),
+	}
+
+	run := func(args ...string) {
+		t.Helper()
+		captureOutput(t, &os.Stdout, func() {
+			if err := promptCommand(config, args); err != nil {
+				t.Fatalf("promptCommand(%q) error = %v", args, err)
+			}
+		})
+	}
+	sent := func() string {
+		var roles []string
+		for _, m := range request.Messages {
+			roles = append(roles, m.Role+":"+m.Content)
+		}
+		return strings.Join(roles, " ")
+	}
+
+	run("--no-stream", "what are interfaces?")
+	run("--no-stream", "--continue", "and generics?")
+	if got, want := sent(), "user:what are interfaces? assistant:reply 1 user:and generics?"; got != want {
+		t.Errorf("--continue sent %q, want %q", got, want)
+	}
+
+	run("--no-stream", "--continue=2", "which is older?")
+	if got, want := sent(), "user:what are interfaces? assistant:reply 1 user:and generics? assistant:reply 2 user:which is older?"; got != want {
+		t.Errorf("--continue=2 sent %q, want %q", got, want)
+	}
+
+	// The dry run shows the conversation that would be sent
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--dry-run", "--continue", "and now?"}); err != nil {
+			t.Fatalf("dry run error = %v", err)
+		}
+	})
+	for _, want := range []string{`"content": "which is older?"`, `"content": "reply 3"`, `"content": "and now?"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dry run output = %s, want it to contain %s", out, want)
+		}
+	}
+}
+
+// TestPromptContinueWithoutLogs tests that --continue fails when there is
+// nothing to continue
+func TestPromptContinueWithoutLogs(t *testing.T) {
+	config := &Config{APIKey: "sk-test", Model: "gpt-4o", ConfigDir: t.TempDir()}
+	if err := promptCommand(config, []string{"--continue", "hello"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("error = %v, want a usage error", err)
+	}
+
+	config.LogDisabled = true
+	if err := promptCommand(config, []string{"--continue", "hello"}); err == nil || !strings.Contains(err.Error(), "CHATGPT_CLI_LOG_DISABLED") {
+		t.Errorf("error = %v, want it to mention CHATGPT_CLI_LOG_DISABLED", err)
+	}
+}
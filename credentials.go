@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// File in the config directory holding the secret values of config.toml,
+// readable only by the owner
+const secretsFileName = "credentials"
+
+// Keys kept in the credentials file rather than config.toml, so that the
+// config file can be shared without leaking them
+var secretConfigKeys = []string{envAPIKey, envAnthropicAPIKey, encryptedAPIKeyKey}
+
+// Modes the config file and the credentials file are written with
+const (
+	configFileMode  os.FileMode = 0644
+	secretsFileMode os.FileMode = 0600
+)
+
+// secretsFilePath returns the path of the credentials file in configDir
+func secretsFilePath(configDir string) string {
+	return filepath.Join(configDir, secretsFileName)
+}
+
+// isSecretConfigKey reports whether a config.toml key belongs in the
+// credentials file
+func isSecretConfigKey(name string) bool {
+	for _, key := range secretConfigKeys {
+		if tomlKey(key) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// loadSecrets reads the credentials file into doc, as if its values were
+// written in config.toml. A value in both files is taken from the
+// credentials file.
+func loadSecrets(configDir string, doc *configDocument) error {
+	path := secretsFilePath(configDir)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	secrets, err := parseConfigDocument(path, string(data))
+	if err != nil {
+		return err
+	}
+	for _, line := range secrets.lines {
+		if key, ok := configKeyForTOML(line.key); ok && !line.header && isSecretConfigKey(line.key) {
+			doc.setIn(line.table, key, line.value)
+		}
+	}
+	return nil
+}
+
+// splitSecrets returns the lines of doc without its secret values, and the
+// document of the credentials file holding them, in the same tables
+func splitSecrets(doc *configDocument) (*configDocument, *configDocument) {
+	public := &configDocument{legacy: doc.legacy}
+	secrets := newSecretsDocument()
+	for _, line := range doc.lines {
+		key, known := configKeyForTOML(line.key)
+		if known && !line.header && isSecretConfigKey(line.key) {
+			secrets.setIn(line.table, key, line.value)
+			continue
+		}
+		public.lines = append(public.lines, line)
+	}
+	return public, secrets
+}
+
+// newSecretsDocument returns the document written for a new credentials file
+func newSecretsDocument() *configDocument {
+	return &configDocument{lines: []tomlLine{
+		{text: "# ChatGPT CLI credentials: API keys split from config.toml. Keep this file private."},
+		{text: ""},
+	}}
+}
+
+// hasValues reports whether the document sets any key
+func (d *configDocument) hasValues() bool {
+	for _, line := range d.lines {
+		if line.key != "" && !line.header {
+			return true
+		}
+	}
+	return false
+}
+
+// saveSecrets writes the credentials file with only the owner allowed to
+// read it, or removes it once it holds nothing
+func saveSecrets(configDir string, secrets *configDocument) error {
+	path := secretsFilePath(configDir)
+	if !secrets.hasValues() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove credentials file: %w", err)
+		}
+		return nil
+	}
+	return writeFileAtomic(path, []byte(secrets.String()), secretsFileMode)
+}
+
+// openFile is a file others than its owner may read or write, and its mode
+type openFile struct {
+	path string
+	mode os.FileMode
+}
+
+// filesTooOpen returns those of the files named in configDir whose mode
+// allows more than allowed. Windows doesn't use these modes, so there is
+// nothing to check there.
+func filesTooOpen(configDir string, allowed os.FileMode, names ...string) []openFile {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	var open []openFile
+	for _, name := range names {
+		path := filepath.Join(configDir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm()&^allowed != 0 {
+			open = append(open, openFile{path: path, mode: info.Mode().Perm()})
+		}
+	}
+	return open
+}
+
+// secretFilesTooOpen returns the files holding API keys that others than
+// the owner may read: the credentials file, and the one auth login falls
+// back to without a keychain
+func secretFilesTooOpen(configDir string) []openFile {
+	return filesTooOpen(configDir, secretsFileMode, secretsFileName, credentialsFileName)
+}
+
+// warnSecretFilePermissions warns on stderr, as ssh does about keys, when a
+// file holding API keys can be read by others
+func warnSecretFilePermissions(config *Config) {
+	out := newUI(config, os.Stderr)
+	for _, file := range secretFilesTooOpen(config.ConfigDir) {
+		out.Printf("%s permissions %04o for %s are too open; it holds API keys. Restrict them with: chatgpt-cli config fix-permissions\n",
+			out.yellow("Warning:"), file.mode, file.path)
+	}
+}
+
+// fixesPermissions reports whether the command is config fix-permissions,
+// which needs no warning about what it fixes
+func fixesPermissions(commandName string, args []string) bool {
+	return commandName == "config" && len(args) > 0 && args[0] == "fix-permissions"
+}
+
+// configFixPermissionsCommand makes the files holding API keys readable by
+// their owner only, and config.toml writable by its owner only
+func configFixPermissionsCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected arguments\nUsage: chatgpt-cli config fix-permissions")
+	}
+
+	fix := func(files []openFile, mode os.FileMode) error {
+		for _, file := range files {
+			if err := os.Chmod(file.path, mode); err != nil {
+				return fmt.Errorf("failed to fix permissions: %w", err)
+			}
+			fmt.Printf("Changed %s from %04o to %04o\n", file.path, file.mode, mode)
+		}
+		return nil
+	}
+	secrets := secretFilesTooOpen(config.ConfigDir)
+	configs := filesTooOpen(config.ConfigDir, configFileMode, configFileName)
+	if err := fix(secrets, secretsFileMode); err != nil {
+		return err
+	}
+	if err := fix(configs, configFileMode); err != nil {
+		return err
+	}
+
+	if len(secrets)+len(configs) == 0 {
+		fmt.Println("Permissions are already restricted")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// readTestFile returns the contents of a file and its mode, failing the test
+// if it can't be read
+func readTestFile(t *testing.T, path string) (string, os.FileMode) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat %s: %v", path, err)
+	}
+	return string(data), info.Mode().Perm()
+}
+
+// TestSaveConfigSplitsSecrets tests that API keys are written to the
+// credentials file and the other values to config.toml, and read from both
+func TestSaveConfigSplitsSecrets(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+
+	if err := saveConfigFile(tmpDir, map[string]string{envAPIKey: "sk-default", envModel: "gpt-4o"}); err != nil {
+		t.Fatalf("saveConfigFile() error = %v", err)
+	}
+	if err := saveProfileConfig(tmpDir, "work", map[string]string{envAnthropicAPIKey: "sk-ant-work"}); err != nil {
+		t.Fatalf("saveProfileConfig() error = %v", err)
+	}
+
+	config, mode := readTestFile(t, configFilePath(tmpDir))
+	if strings.Contains(config, "sk-") || !strings.Contains(config, `model = "gpt-4o"`) || !strings.Contains(config, "[profile.work]") {
+		t.Errorf("config.toml =\n%s\nwant the model and no keys", config)
+	}
+	secrets, secretsMode := readTestFile(t, secretsFilePath(tmpDir))
+	want := "\napi_key = \"sk-default\"\n\n[profile.work]\nanthropic_api_key = \"sk-ant-work\"\n"
+	if !strings.HasSuffix(secrets, want) || strings.Contains(secrets, "model") {
+		t.Errorf("credentials =\n%s\nwant only the keys", secrets)
+	}
+	if runtime.GOOS != "windows" && (mode != configFileMode || secretsMode != secretsFileMode) {
+		t.Errorf("modes = %04o, %04o; want %04o, %04o", mode, secretsMode, configFileMode, secretsFileMode)
+	}
+
+	loaded, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if loaded.APIKey != "sk-default" || loaded.Model != "gpt-4o" {
+		t.Errorf("loadConfig() = key %q, model %q", loaded.APIKey, loaded.Model)
+	}
+
+	// The credentials file goes once it holds no key
+	if err := saveConfigFile(tmpDir, map[string]string{envAPIKey: ""}); err != nil {
+		t.Fatalf("saveConfigFile() error = %v", err)
+	}
+	if err := saveProfileConfig(tmpDir, "work", map[string]string{envAnthropicAPIKey: ""}); err != nil {
+		t.Fatalf("saveProfileConfig() error = %v", err)
+	}
+	if _, err := os.Stat(secretsFilePath(tmpDir)); !os.IsNotExist(err) {
+		t.Errorf("credentials file should be removed once empty, stat error = %v", err)
+	}
+}
+
+// TestConfigSecretsMigration tests that keys written in config.toml before
+// the split are read as before and moved on the first write
+func TestConfigSecretsMigration(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	writeTestConfigFile(t, tmpDir, "# mine\napi_key = \"sk-old\" # personal\nmodel = \"gpt-4\"\n")
+
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.APIKey != "sk-old" {
+		t.Errorf("APIKey = %q, want the key of config.toml", config.APIKey)
+	}
+	if _, err := os.Stat(secretsFilePath(tmpDir)); !os.IsNotExist(err) {
+		t.Errorf("credentials file should not be written by a read")
+	}
+
+	if err := saveConfigFile(tmpDir, map[string]string{envTimeout: "30"}); err != nil {
+		t.Fatalf("saveConfigFile() error = %v", err)
+	}
+	if data, _ := readTestFile(t, configFilePath(tmpDir)); data != "# mine\nmodel = \"gpt-4\"\ntimeout = 30\n" {
+		t.Errorf("config.toml = %q, want the key moved out", data)
+	}
+	if data, _ := readTestFile(t, secretsFilePath(tmpDir)); !strings.HasSuffix(data, "\napi_key = \"sk-old\"\n") {
+		t.Errorf("credentials = %q, want the key", data)
+	}
+
+	// A key left in config.toml by hand is taken from the credentials file
+	writeTestConfigFile(t, tmpDir, "api_key = \"sk-stale\"\nmodel = \"gpt-4\"\n")
+	if sections := loadTestConfigSections(t, tmpDir); sections[""][envAPIKey] != "sk-old" {
+		t.Errorf("api key = %q, want the one of the credentials file", sections[""][envAPIKey])
+	}
+}
+
+// TestSecretFilePermissions tests the warning about credentials others can
+// read, and config fix-permissions
+func TestSecretFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't use file modes")
+	}
+	tmpDir := t.TempDir()
+	config := &Config{ConfigDir: tmpDir, NoColor: true}
+	if err := saveConfigFile(tmpDir, map[string]string{envAPIKey: "sk-test"}); err != nil {
+		t.Fatalf("saveConfigFile() error = %v", err)
+	}
+
+	errOut := captureOutput(t, &os.Stderr, func() { warnSecretFilePermissions(config) })
+	if errOut != "" {
+		t.Errorf("warning = %q, want none for mode 0600", errOut)
+	}
+
+	if err := os.Chmod(secretsFilePath(tmpDir), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(configFilePath(tmpDir), 0666); err != nil {
+		t.Fatal(err)
+	}
+	errOut = captureOutput(t, &os.Stderr, func() { warnSecretFilePermissions(config) })
+	if !strings.Contains(errOut, "Warning: permissions 0644 for "+secretsFilePath(tmpDir)+" are too open") || !strings.Contains(errOut, "chatgpt-cli config fix-permissions") {
+		t.Errorf("warning = %q", errOut)
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := configCommand(config, []string{"fix-permissions"}); err != nil {
+			t.Errorf("config fix-permissions error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "from 0644 to 0600") || !strings.Contains(out, "from 0666 to 0644") {
+		t.Errorf("output = %q", out)
+	}
+	if _, mode := readTestFile(t, secretsFilePath(tmpDir)); mode != secretsFileMode {
+		t.Errorf("credentials mode = %04o, want %04o", mode, secretsFileMode)
+	}
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := configFixPermissionsCommand(config, nil); err != nil {
+			t.Errorf("config fix-permissions error = %v", err)
+		}
+	})
+	if out != "Permissions are already restricted\n" {
+		t.Errorf("second run output = %q", out)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variable turning on debug output, like --verbose
+const envDebug = "CHATGPT_CLI_DEBUG"
+
+// Debug levels: requests and response headers, then raw response bodies too
+const (
+	debugHeaders = 1
+	debugBodies  = 2
+)
+
+// Headers whose values are printed as [redacted] in debug output, besides the
+// API key headers that are masked
+var redactedHeaders = []string{"Cookie", "Set-Cookie"}
+
+// parseDebugLevel reads CHATGPT_CLI_DEBUG: true or 1 print requests and
+// response headers, 2 adds response bodies
+func parseDebugLevel(value string) int {
+	if b, err := strconv.ParseBool(value); err == nil {
+		if b {
+			return debugHeaders
+		}
+		return 0
+	}
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		if n > debugBodies {
+			return debugBodies
+		}
+		return n
+	}
+	return 0
+}
+
+// newHTTPClient returns the client requests to the API are sent with, limited
+// by the overall and connect timeouts and OPENAI_MAX_RESPONSE_BYTES, and
+// printing them to stderr when debugging is on
+func newHTTPClient(config *Config) *http.Client {
+	transport := &responseTransport{base: transportFor(config), maxBytes: config.MaxResponseBytes}
+	client := &http.Client{Timeout: config.Timeout, Transport: transport}
+	if config.Debug > 0 {
+		client.Transport = &debugTransport{base: client.Transport, level: config.Debug, w: os.Stderr}
+	}
+	return client
+}
+
+// debugTransport is a RoundTripper printing each request and its response.
+// API keys are masked; nothing is written to the log file.
+type debugTransport struct {
+	base  http.RoundTripper
+	level int
+	w     io.Writer
+
+	// Keeps the lines of requests sent at the same time apart
+	mu sync.Mutex
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "> %s %s\n", req.Method, req.URL.Redacted())
+	writeDebugHeaders(&out, "> ", req.Header)
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			if len(data) > 0 {
+				fmt.Fprintf(&out, ">\n%s\n", bytes.TrimRight(data, "\n"))
+			}
+		}
+	}
+	t.print(out.Bytes())
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	out.Reset()
+	if err != nil {
+		fmt.Fprintf(&out, "< request failed after %s: %v\n", elapsed, err)
+		t.print(out.Bytes())
+		return nil, err
+	}
+
+	timing := elapsed.String()
+	if id := responseRequestID(resp.Header); id != "" {
+		timing += ", request id " + id
+	}
+	fmt.Fprintf(&out, "< %s %s (%s)\n", resp.Proto, resp.Status, timing)
+	writeDebugHeaders(&out, "< ", resp.Header)
+	t.print(out.Bytes())
+
+	// The body is printed as it is read, so streamed replies still stream
+	if t.level >= debugBodies {
+		w := &debugBodyWriter{t: t}
+		resp.Body = &debugBody{ReadCloser: resp.Body, tee: io.TeeReader(resp.Body, w), w: w}
+	}
+	return resp, nil
+}
+
+func (t *debugTransport) print(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, _ = t.w.Write(data)
+}
+
+// writeDebugHeaders writes the headers sorted by name with secrets hidden
+func writeDebugHeaders(w io.Writer, prefix string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, name, debugHeaderValue(name, value))
+		}
+	}
+}
+
+// debugHeaderValue masks API keys and redacts cookies
+func debugHeaderValue(name, value string) string {
+	for _, redacted := range redactedHeaders {
+		if http.CanonicalHeaderKey(name) == redacted {
+			return "[redacted]"
+		}
+	}
+	return maskHeader(name, value)
+}
+
+// debugBody is a response body printing what is read from it
+type debugBody struct {
+	io.ReadCloser
+	tee io.Reader
+	w   *debugBodyWriter
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	return b.tee.Read(p)
+}
+
+// Close ends a body that doesn't end with a line break with one, so later
+// output starts on a line of its own
+func (b *debugBody) Close() error {
+	if b.w.midLine {
+		b.w.t.print([]byte("\n"))
+		b.w.midLine = false
+	}
+	return b.ReadCloser.Close()
+}
+
+// debugBodyWriter prints response body data after a "<" line, with the "< "
+// prefix of debug output at the start of each line
+type debugBodyWriter struct {
+	t       *debugTransport
+	started bool
+	midLine bool
+}
+
+func (w *debugBodyWriter) Write(p []byte) (int, error) {
+	var out strings.Builder
+	if !w.started && len(p) > 0 {
+		out.WriteString("<\n")
+		w.started = true
+	}
+	for _, line := range strings.SplitAfter(string(p), "\n") {
+		if line == "" {
+			continue
+		}
+		if !w.midLine {
+			out.WriteString("< ")
+		}
+		out.WriteString(line)
+		w.midLine = !strings.HasSuffix(line, "\n")
+	}
+	w.t.print([]byte(out.String()))
+	return len(p), nil
+}
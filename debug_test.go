@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseDebugLevel tests the accepted CHATGPT_CLI_DEBUG values
+func TestParseDebugLevel(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{"", 0},
+		{"false", 0},
+		{"true", debugHeaders},
+		{"1", debugHeaders},
+		{"2", debugBodies},
+		{"9", debugBodies},
+		{"-1", 0},
+		{"verbose", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseDebugLevel(tt.value); got != tt.expected {
+			t.Errorf("parseDebugLevel(%q) = %d, want %d", tt.value, got, tt.expected)
+		}
+	}
+}
+
+// TestDebugTransport tests what is printed for a request and its response
+func TestDebugTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret-cookie")
+		w.Header().Set("X-Request-Id", "req-123")
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:   "sk-1234567890abcdef",
+		APIURL:   server.URL,
+		Model:    "gpt-4o",
+		Timeout:  10 * time.Second,
+		Provider: providerOpenAI,
+	}
+
+	tests := []struct {
+		level    int
+		wantBody bool
+	}{
+		{debugHeaders, false},
+		{debugBodies, true},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		client := &http.Client{Transport: &debugTransport{base: http.DefaultTransport, level: tt.level, w: &out}}
+
+		req, err := newChatRequest(context.Background(), config, "test prompt", false)
+		if err != nil {
+			t.Fatalf("newChatRequest() error = %v", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		response, err := chatProviderFor(config).ReadResponse(resp)
+		resp.Body.Close()
+		if err != nil || formatResponse(response) != "Hi" {
+			t.Fatalf("ReadResponse() = %v, %v, want the reply unchanged", response, err)
+		}
+
+		debug := out.String()
+		for _, want := range []string{
+			"> POST " + server.URL + "\n",
+			"> Authorization: Bearer sk-1...cdef\n",
+			`"content":"test prompt"`,
+			"< HTTP/1.1 200 OK (",
+			"< X-Request-Id: req-123\n",
+			"< Set-Cookie: [redacted]\n",
+		} {
+			if !strings.Contains(debug, want) {
+				t.Errorf("level %d: debug output is missing %q:\n%s", tt.level, want, debug)
+			}
+		}
+		if strings.Contains(debug, "sk-1234567890abcdef") || strings.Contains(debug, "secret-cookie") {
+			t.Errorf("level %d: debug output leaks a secret:\n%s", tt.level, debug)
+		}
+		if gotBody := strings.Contains(debug, "<\n< {\"choices\""); gotBody != tt.wantBody {
+			t.Errorf("level %d: response body printed = %t, want %t:\n%s", tt.level, gotBody, tt.wantBody, debug)
+		}
+	}
+}
+
+// TestVerbosePrompt tests that CHATGPT_CLI_DEBUG prints requests to stderr
+// while the log file stays free of headers
+func TestVerbosePrompt(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	setTestEnv(envAPIKey, "sk-1234567890abcdef")
+	setTestEnv(envAPIURL, server.URL)
+	setTestEnv(envDebug, "true")
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.Debug != debugHeaders {
+		t.Fatalf("config.Debug = %d, want %d", config.Debug, debugHeaders)
+	}
+
+	stderr := captureOutput(t, &os.Stderr, func() {
+		captureOutput(t, &os.Stdout, func() {
+			if err := promptCommand(config, []string{"--no-stream", "hello"}); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+	if !strings.Contains(stderr, "> POST "+server.URL) || !strings.Contains(stderr, "< HTTP/1.1 200 OK") {
+		t.Errorf("stderr = %q, want the request and response", stderr)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, logFileName))
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if strings.Contains(string(data), "sk-") || strings.Contains(string(data), "Authorization") {
+		t.Errorf("log file contains request details: %s", data)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Table of config.toml holding the default flags of commands, keyed by the
+// name of the command, as in prompt = "--usage --timing". Subcommands with
+// flags of their own are keyed by both words, as in "logs export".
+const defaultsTable = "defaults"
+
+// Default flags of each command, parsed by parseFlags before those on the
+// command line. Set by main from the config file unless --no-defaults is
+// given, so it is empty in tests.
+var flagDefaults map[string]string
+
+// DefaultFlags is one command's default flags in the JSON output of config
+// get-default
+type DefaultFlags struct {
+	Command string `json:"command"`
+	Flags   string `json:"flags"`
+}
+
+// loadFlagDefaults returns the default flags of each command from the
+// config file
+func loadFlagDefaults(configDir string) (map[string]string, error) {
+	doc, err := loadConfigDocument(configDir)
+	if err != nil {
+		return nil, err
+	}
+	return doc.defaultFlags(), nil
+}
+
+// defaultFlags returns the values of the defaults table, keyed by command
+func (d *configDocument) defaultFlags() map[string]string {
+	defaults := make(map[string]string)
+	for _, line := range d.lines {
+		if line.table == defaultsTable && !line.header && line.key != "" {
+			defaults[line.key] = line.value
+		}
+	}
+	return defaults
+}
+
+// parseDefaultFlags parses the default flags of the command of fs into it,
+// so that the flags on the command line, parsed next, override them. Flags
+// that no longer parse, such as one removed by an upgrade, are an error
+// telling how to fix or skip them.
+func parseDefaultFlags(fs *flag.FlagSet) error {
+	value := flagDefaults[fs.Name()]
+	if value == "" {
+		return nil
+	}
+
+	words, err := splitFlagWords(value)
+	if err == nil {
+		err = fs.Parse(words)
+	}
+	if err == nil && fs.NArg() > 0 {
+		err = fmt.Errorf("%s is not a flag", fs.Arg(0))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid default flags for %s (%s): %v\nChange them with 'chatgpt-cli config set-default %s ...', remove them with 'chatgpt-cli config unset-default %s' or skip them with --no-defaults",
+			fs.Name(), value, err, quoteFlagWord(fs.Name()), quoteFlagWord(fs.Name()))
+	}
+	return nil
+}
+
+// configSetDefaultCommand saves the default flags of a command
+func configSetDefaultCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli config set-default <command> \"--flag value ...\""
+
+	if len(args) < 2 {
+		return usageErrorf("command and flags required\n%s", usage)
+	}
+	command, err := defaultsCommandName(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Flags given as separate arguments are stored quoted as needed
+	value := args[1]
+	if len(args) > 2 {
+		quoted := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			quoted[i] = quoteFlagWord(arg)
+		}
+		value = strings.Join(quoted, " ")
+	}
+	words, err := splitFlagWords(value)
+	if err != nil {
+		return usageErrorf("invalid flags %q: %v", value, err)
+	}
+	if len(words) == 0 || !strings.HasPrefix(words[0], "-") {
+		return usageErrorf("default flags must start with a flag, such as --usage\n%s", usage)
+	}
+
+	err = updateConfigDocument(config.ConfigDir, func(doc *configDocument) {
+		doc.setIn(defaultsTable, command, value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	out := newUI(config, os.Stdout)
+	out.Printf("Default flags of %s set to: %s\n", command, value)
+	out.Printf("%s skip them once with --no-defaults\n", out.dim("Note:"))
+	return nil
+}
+
+// configGetDefaultCommand prints the default flags of a command, or of every
+// command when none is given
+func configGetDefaultCommand(config *Config, args []string) error {
+	if len(args) > 1 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli config get-default [command]", args[1])
+	}
+
+	defaults, err := loadFlagDefaults(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		command, err := defaultsCommandName(args[0])
+		if err != nil {
+			return err
+		}
+		if config.Output == outputJSON {
+			return printJSON(DefaultFlags{Command: command, Flags: defaults[command]})
+		}
+		fmt.Println(defaults[command])
+		return nil
+	}
+
+	commands := make([]string, 0, len(defaults))
+	for command := range defaults {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	if config.Output == outputJSON {
+		entries := make([]DefaultFlags, len(commands))
+		for i, command := range commands {
+			entries[i] = DefaultFlags{Command: command, Flags: defaults[command]}
+		}
+		return printJSON(entries)
+	}
+	if len(commands) == 0 {
+		fmt.Println("No default flags set. Add some with: chatgpt-cli config set-default <command> \"--flag ...\"")
+		return nil
+	}
+	out := newUI(config, os.Stdout)
+	for _, command := range commands {
+		out.Printf("%s %s\n", out.bold(command+":"), defaults[command])
+	}
+	return nil
+}
+
+// configUnsetDefaultCommand removes the default flags of a command
+func configUnsetDefaultCommand(config *Config, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("command required\nUsage: chatgpt-cli config unset-default <command>")
+	}
+	command, err := defaultsCommandName(args[0])
+	if err != nil {
+		return err
+	}
+
+	out := newUI(config, os.Stdout)
+	defaults, err := loadFlagDefaults(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	if _, exists := defaults[command]; !exists {
+		out.Printf("%s has no default flags in the config file, nothing to do\n", command)
+		return nil
+	}
+
+	err = updateConfigDocument(config.ConfigDir, func(doc *configDocument) {
+		doc.unsetIn(defaultsTable, command)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	out.Printf("Removed the default flags of %s\n", command)
+	return nil
+}
+
+// defaultsCommandName checks the command given to the default subcommands,
+// which is a command name optionally followed by a subcommand, as in
+// "logs export", and returns it with single spaces
+func defaultsCommandName(name string) (string, error) {
+	words := strings.Fields(name)
+	if len(words) == 0 || len(words) > 2 {
+		return "", usageErrorf("invalid command %q: use a command name, such as prompt, or a command and subcommand, such as \"logs export\"", name)
+	}
+	if command, exists := getCommands()[words[0]]; !exists || command.Hidden {
+		return "", usageErrorf("unknown command: %s", words[0])
+	}
+	return strings.Join(words, " "), nil
+}
+
+// splitFlagWords splits stored flags into words the way a shell would,
+// honoring single and double quotes and backslash escapes
+func splitFlagWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\' && quote == 0 || c == '\\' && quote == '"' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("trailing backslash")
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// quoteFlagWord quotes a word for splitFlagWords when it holds spaces,
+// quotes or backslashes, or is empty
+func quoteFlagWord(word string) string {
+	if word != "" && !strings.ContainsAny(word, " \t\n'\"\\") {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useFlagDefaults sets the default flags of commands for the rest of the test
+func useFlagDefaults(t *testing.T, defaults map[string]string) {
+	original := flagDefaults
+	flagDefaults = defaults
+	t.Cleanup(func() { flagDefaults = original })
+}
+
+// TestSplitFlagWords tests splitting stored flags like a shell
+func TestSplitFlagWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"--usage --timing", []string{"--usage", "--timing"}},
+		{"  --style   concise\t--n 2 ", []string{"--style", "concise", "--n", "2"}},
+		{`--prefix "be brief: " --suffix 'it''s' --stop a\ b`, []string{"--prefix", "be brief: ", "--suffix", "its", "--stop", "a b"}},
+		{`--prefix "say \"hi\" \n"`, []string{"--prefix", `say "hi" \n`}},
+		{`--prefix ''`, []string{"--prefix", ""}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := splitFlagWords(tt.in)
+		if err != nil || strings.Join(got, "\x00") != strings.Join(tt.want, "\x00") || len(got) != len(tt.want) {
+			t.Errorf("splitFlagWords(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{`--prefix "open`, `--prefix 'open`, `--prefix a\`} {
+		if _, err := splitFlagWords(in); err == nil {
+			t.Errorf("splitFlagWords(%q) succeeded, want an error", in)
+		}
+	}
+
+	// Quoted words split back into themselves
+	for _, word := range []string{"plain", "two words", `it's "quoted" \ ok`, ""} {
+		if got, err := splitFlagWords(quoteFlagWord(word)); err != nil || len(got) != 1 || got[0] != word {
+			t.Errorf("splitFlagWords(quoteFlagWord(%q)) = %q, %v", word, got, err)
+		}
+	}
+}
+
+// TestParseFlagsDefaults tests that default flags are parsed first, so the
+// command line overrides them, and that stale ones are reported
+func TestParseFlagsDefaults(t *testing.T) {
+	useFlagDefaults(t, map[string]string{
+		"prompt":      "--usage --style concise",
+		"logs export": "--format md",
+		"stale":       "--removed-flag",
+		"positional":  "--usage extra",
+	})
+
+	newPromptFlags := func(name string) (*flag.FlagSet, *bool, *string) {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		return fs, fs.Bool("usage", false, ""), fs.String("style", "", "")
+	}
+
+	fs, usage, style := newPromptFlags("prompt")
+	args, err := parseFlags(fs, []string{"--style", "formal", "hello"})
+	if err != nil || !*usage || *style != "formal" || len(args) != 1 || args[0] != "hello" {
+		t.Errorf("parseFlags() = %q, %v; usage = %t, style = %q", args, err, *usage, *style)
+	}
+
+	fs, usage, _ = newPromptFlags("prompt")
+	if _, err := parseFlags(fs, []string{"--usage=false", "hello"}); err != nil || *usage {
+		t.Errorf("parseFlags(--usage=false) usage = %t, %v; want the default overridden", *usage, err)
+	}
+
+	fs = flag.NewFlagSet("logs export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "")
+	if _, err := parseFlags(fs, nil); err != nil || *format != "md" {
+		t.Errorf("logs export format = %q, %v; want md", *format, err)
+	}
+
+	// Commands without defaults are untouched
+	fs, usage, _ = newPromptFlags("refine")
+	if _, err := parseFlags(fs, nil); err != nil || *usage {
+		t.Errorf("refine usage = %t, %v; want no defaults", *usage, err)
+	}
+
+	fs, _, _ = newPromptFlags("stale")
+	_, err = parseFlags(fs, []string{"hello"})
+	for _, want := range []string{"invalid default flags for stale (--removed-flag)", "flag provided but not defined: -removed-flag", "config unset-default stale", "--no-defaults"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseFlags(stale defaults) error = %v, want %q", err, want)
+		}
+	}
+
+	fs, _, _ = newPromptFlags("positional")
+	if _, err := parseFlags(fs, nil); err == nil || !strings.Contains(err.Error(), "extra is not a flag") {
+		t.Errorf("parseFlags(positional defaults) error = %v", err)
+	}
+}
+
+// TestConfigDefaultCommands tests saving, showing and removing the default
+// flags of commands in the defaults table of config.toml
+func TestConfigDefaultCommands(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, "model = \"gpt-4o\"\n")
+	config := &Config{ConfigDir: tmpDir, NoColor: true}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := configCommand(config, []string{"set-default", "prompt", "--usage --timing"}); err != nil {
+			t.Fatalf("set-default prompt error = %v", err)
+		}
+		// Flags given as separate arguments are quoted as needed
+		if err := configCommand(config, []string{"set-default", "logs  export", "--format", "md", "--out", "my logs.md"}); err != nil {
+			t.Fatalf("set-default logs export error = %v", err)
+		}
+	})
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, configFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "model = \"gpt-4o\"\n\n[defaults]\nprompt = \"--usage --timing\"\n\"logs export\" = \"--format md --out 'my logs.md'\"\n"
+	if string(data) != want {
+		t.Errorf("config.toml =\n%s\nwant\n%s", data, want)
+	}
+	if sections := loadTestConfigSections(t, tmpDir); sections[""]["OPENAI_MODEL"] != "gpt-4o" || len(sections) != 1 {
+		t.Errorf("sections = %v, want the defaults table ignored", sections)
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := configCommand(config, []string{"get-default", "prompt"}); err != nil {
+			t.Errorf("get-default prompt error = %v", err)
+		}
+	})
+	if out != "--usage --timing\n" {
+		t.Errorf("get-default prompt = %q", out)
+	}
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := configCommand(config, []string{"get-default"}); err != nil {
+			t.Errorf("get-default error = %v", err)
+		}
+	})
+	if out != "logs export: --format md --out 'my logs.md'\nprompt: --usage --timing\n" {
+		t.Errorf("get-default = %q", out)
+	}
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := configCommand(config, []string{"unset-default", "prompt"}); err != nil {
+			t.Errorf("unset-default prompt error = %v", err)
+		}
+		if err := configCommand(config, []string{"unset-default", "prompt"}); err != nil {
+			t.Errorf("second unset-default prompt error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Removed the default flags of prompt") || !strings.Contains(out, "nothing to do") {
+		t.Errorf("unset-default output = %q", out)
+	}
+	if defaults, err := loadFlagDefaults(tmpDir); err != nil || len(defaults) != 1 || defaults["logs export"] == "" {
+		t.Errorf("defaults = %v, %v; want only logs export left", defaults, err)
+	}
+
+	for _, args := range [][]string{
+		{"set-default", "prompt"},
+		{"set-default", "nosuchcommand", "--usage"},
+		{"set-default", "prompt", "usage"},
+		{"set-default", "prompt", "--prefix 'open"},
+		{"set-default", "logs export now", "--format md"},
+		{"get-default", "__complete"},
+		{"unset-default"},
+	} {
+		if err := configCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("config %q error = %v, want a usage error", args, err)
+		}
+	}
+}
+
+// TestParseGlobalFlagsNoDefaults tests that --no-defaults is taken out of
+// the command's arguments
+func TestParseGlobalFlagsNoDefaults(t *testing.T) {
+	rest, flags, err := parseGlobalFlags([]string{"chatgpt-cli", "prompt", "--no-defaults", "hello", "--", "--no-defaults"})
+	if err != nil || !flags.NoDefaults || strings.Join(rest, " ") != "chatgpt-cli prompt hello -- --no-defaults" {
+		t.Errorf("parseGlobalFlags() = %q, %+v, %v", rest, flags, err)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DoctorCheck is the outcome of one diagnostic step of the doctor command
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// doctorCommand checks connectivity to the configured endpoint step by step
+func doctorCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli doctor", args[0])
+	}
+
+	checks := runDoctorChecks(config.requestContext(), config)
+
+	if config.Output == outputJSON {
+		if err := printJSON(checks); err != nil {
+			return err
+		}
+	} else {
+		printDoctorChecks(config, checks)
+	}
+
+	failed := 0
+	for _, check := range checks {
+		if !check.OK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// runDoctorChecks runs the diagnostic steps in order. The network steps stop
+// at the first failure, since every later step depends on it.
+func runDoctorChecks(ctx context.Context, config *Config) []DoctorCheck {
+	var checks []DoctorCheck
+
+	if err := unlockAPIKey(config); err != nil {
+		checks = append(checks, DoctorCheck{Name: "API key", Detail: err.Error(), Hint: "set " + envPassphrase + " or enter the passphrase used with config set --encrypt"})
+	} else if config.APIKey == "" && !providerRequiresAPIKey(config.Provider) {
+		checks = append(checks, DoctorCheck{Name: "API key", OK: true, Detail: "not required by " + config.Provider})
+	} else if config.APIKey == "" {
+		name := apiKeyName(config.Provider)
+		checks = append(checks, DoctorCheck{
+			Name:   "API key",
+			Detail: "not set",
+			Hint:   "set " + name + " or run: chatgpt-cli config set " + name + " <key>",
+		})
+	} else {
+		checks = append(checks, DoctorCheck{Name: "API key", OK: true, Detail: maskAPIKey(config.APIKey)})
+	}
+
+	target, check := checkAPIURL(config)
+	checks = append(checks, check)
+	if !check.OK {
+		return checks
+	}
+
+	// Behind a proxy the connection is made to the proxy instead
+	proxy, check := checkProxy(target)
+	checks = append(checks, check)
+	if !check.OK {
+		return checks
+	}
+	dialURL := target
+	if proxy != nil {
+		dialURL = proxy
+	}
+
+	host := dialURL.Hostname()
+	address := net.JoinHostPort(host, urlPort(dialURL))
+
+	steps := []func() DoctorCheck{
+		func() DoctorCheck { return checkDNS(ctx, host, config.Timeout) },
+		func() DoctorCheck {
+			check := checkTCP(ctx, address, config.Timeout)
+			// A local Ollama that can't be reached is most likely not started
+			if !check.OK && proxy == nil && config.Provider == providerOllama {
+				check.Hint = ollamaStartHint
+			}
+			return check
+		},
+	}
+	if dialURL.Scheme == "https" {
+		steps = append(steps, func() DoctorCheck { return checkTLS(ctx, address, host, config.Timeout) })
+	}
+	steps = append(steps, func() DoctorCheck { return checkHTTP(ctx, config) })
+
+	for _, step := range steps {
+		check := step()
+		checks = append(checks, check)
+		if !check.OK {
+			break
+		}
+	}
+
+	return checks
+}
+
+// checkAPIURL validates the chat completions URL and returns it parsed
+func checkAPIURL(config *Config) (*url.URL, DoctorCheck) {
+	check := DoctorCheck{Name: "API URL"}
+
+	raw, err := chatCompletionsURL(config)
+	if err != nil {
+		check.Detail = err.Error()
+		check.Hint = "check " + envAPIURL
+		return nil, check
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		check.Detail = "invalid URL: " + raw
+		check.Hint = envAPIURL + " must be an absolute http:// or https:// URL"
+		return nil, check
+	}
+
+	check.OK = true
+	check.Detail = raw
+	return u, check
+}
+
+// checkProxy reports the proxy used for target, if any
+func checkProxy(target *url.URL) (*url.URL, DoctorCheck) {
+	check := DoctorCheck{Name: "Proxy"}
+
+	proxy, err := http.ProxyFromEnvironment(&http.Request{URL: target})
+	if err != nil {
+		check.Detail = err.Error()
+		check.Hint = "check the HTTPS_PROXY and HTTP_PROXY environment variables"
+		return nil, check
+	}
+
+	check.OK = true
+	if proxy == nil {
+		check.Detail = "none"
+	} else {
+		check.Detail = proxy.Redacted()
+	}
+	return proxy, check
+}
+
+// checkDNS resolves host
+func checkDNS(ctx context.Context, host string, timeout time.Duration) DoctorCheck {
+	check := DoctorCheck{Name: "DNS lookup"}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return failedCheck(check, err)
+	}
+
+	check.OK = true
+	check.Detail = fmt.Sprintf("%s -> %s (%s)", host, strings.Join(addrs, ", "), formatElapsed(start))
+	return check
+}
+
+// checkTCP opens a TCP connection to address
+func checkTCP(ctx context.Context, address string, timeout time.Duration) DoctorCheck {
+	check := DoctorCheck{Name: "TCP connect"}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return failedCheck(check, err)
+	}
+	conn.Close()
+
+	check.OK = true
+	check.Detail = fmt.Sprintf("%s (%s)", address, formatElapsed(start))
+	return check
+}
+
+// checkTLS performs a TLS handshake with address, verifying the certificate
+// for host
+func checkTLS(ctx context.Context, address, host string, timeout time.Duration) DoctorCheck {
+	check := DoctorCheck{Name: "TLS handshake"}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    &tls.Config{ServerName: host},
+	}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return failedCheck(check, err)
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	check.OK = true
+	check.Detail = fmt.Sprintf("%s (%s)", tls.CipherSuiteName(state.CipherSuite), formatElapsed(start))
+	return check
+}
+
+// checkHTTP lists the models to check that the API answers and accepts the key
+func checkHTTP(ctx context.Context, config *Config) DoctorCheck {
+	check := DoctorCheck{Name: "HTTP request"}
+
+	req, err := newModelsRequest(ctx, config)
+	if err != nil {
+		return failedCheck(check, err)
+	}
+
+	client := newHTTPClient(config)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return failedCheck(check, err)
+	}
+	resp.Body.Close()
+
+	check.Detail = fmt.Sprintf("GET %s: %s (%s)", req.URL.Redacted(), resp.Status, formatElapsed(start))
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		check.Hint = "the API rejected the key; check " + apiKeyName(config.Provider)
+	case resp.StatusCode >= 500:
+		check.Hint = "the API is reachable but failing; try again later"
+	default:
+		// Any other answer, even a 404 from a provider without a models
+		// endpoint, shows the API is reachable
+		check.OK = true
+	}
+	return check
+}
+
+// failedCheck marks check as failed with err and the matching hint
+func failedCheck(check DoctorCheck, err error) DoctorCheck {
+	check.OK = false
+	check.Detail = err.Error()
+	check.Hint = networkHint(err)
+	return check
+}
+
+// urlPort returns the port of u, defaulting to the scheme's port
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "http" {
+		return "80"
+	}
+	return "443"
+}
+
+// formatElapsed formats the time since start in milliseconds
+func formatElapsed(start time.Time) string {
+	return fmt.Sprintf("%dms", time.Since(start).Milliseconds())
+}
+
+// printDoctorChecks prints one line per check, with hints under failures
+func printDoctorChecks(config *Config, checks []DoctorCheck) {
+	fmt.Printf("Checking connectivity for profile %s\n\n", profileDisplayName(config.Profile))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, check := range checks {
+		status := "ok"
+		if !check.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", status, check.Name, check.Detail)
+		if check.Hint != "" {
+			fmt.Fprintf(w, "\t\tHint: %s\n", check.Hint)
+		}
+	}
+	w.Flush()
+}
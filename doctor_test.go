@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkNames returns the names of checks, marking failures with a "!"
+func checkNames(checks []DoctorCheck) string {
+	var names []string
+	for _, check := range checks {
+		name := check.Name
+		if !check.OK {
+			name += "!"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// TestRunDoctorChecks tests the diagnostic steps against a local server
+func TestRunDoctorChecks(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedURL := "http://" + listener.Addr().String() + "/v1/chat/completions"
+	listener.Close()
+
+	tests := []struct {
+		name   string
+		apiKey string
+		apiURL string
+		want   string
+		hint   string
+	}{
+		{
+			name:   "healthy",
+			apiKey: "good-key",
+			apiURL: server.URL + "/v1/chat/completions",
+			want:   "API key, API URL, Proxy, DNS lookup, TCP connect, HTTP request",
+		},
+		{
+			name:   "rejected key",
+			apiKey: "bad-key",
+			apiURL: server.URL + "/v1/chat/completions",
+			want:   "API key, API URL, Proxy, DNS lookup, TCP connect, HTTP request!",
+			hint:   envAPIKey,
+		},
+		{
+			name:   "missing key",
+			apiURL: server.URL + "/v1/chat/completions",
+			want:   "API key!, API URL, Proxy, DNS lookup, TCP connect, HTTP request!",
+			hint:   envAPIKey,
+		},
+		{
+			name:   "connection refused stops the checks",
+			apiKey: "good-key",
+			apiURL: closedURL,
+			want:   "API key, API URL, Proxy, DNS lookup, TCP connect!",
+			hint:   envAPIURL,
+		},
+		{
+			name:   "invalid URL",
+			apiKey: "good-key",
+			apiURL: "api.openai.com",
+			want:   "API key, API URL!",
+			hint:   envAPIURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				APIKey:  tt.apiKey,
+				APIURL:  tt.apiURL,
+				Timeout: 5 * time.Second,
+			}
+
+			checks := runDoctorChecks(context.Background(), config)
+			if got := checkNames(checks); got != tt.want {
+				t.Errorf("checks = %s, want %s", got, tt.want)
+			}
+
+			last := checks[len(checks)-1]
+			if tt.hint != "" && !strings.Contains(last.Hint, tt.hint) {
+				t.Errorf("last hint = %q, want it to mention %q", last.Hint, tt.hint)
+			}
+		})
+	}
+}
+
+// TestDoctorCommand tests the printed report and the error on failure
+func TestDoctorCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIURL:  server.URL + "/v1/chat/completions",
+		Timeout: 5 * time.Second,
+	}
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = doctorCommand(config, nil)
+	})
+	if err == nil || !strings.Contains(err.Error(), "1 of 6 checks failed") {
+		t.Errorf("doctorCommand() error = %v, want 1 of 6 checks failed", err)
+	}
+	for _, s := range []string{"FAIL  API key", "Hint: set " + envAPIKey, "ok    HTTP request"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output = %q, want it to contain %q", out, s)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DryRunOutput describes a request that would have been sent
+type DryRunOutput struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Headers whose values are API keys and must never be printed in full
+var secretHeaders = []string{"Authorization", "Api-Key", "X-Api-Key"}
+
+// newDryRunOutput describes req with its API key masked
+func newDryRunOutput(req *http.Request) (DryRunOutput, error) {
+	output := DryRunOutput{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: make(map[string]string, len(req.Header)),
+	}
+
+	for name := range req.Header {
+		output.Headers[name] = maskHeader(name, req.Header.Get(name))
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return DryRunOutput{}, fmt.Errorf("failed to read request body: %w", err)
+		}
+		defer body.Close()
+
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return DryRunOutput{}, fmt.Errorf("failed to read request body: %w", err)
+		}
+		output.Body = data
+	}
+
+	return output, nil
+}
+
+// maskHeader masks the API key in the value of a secret header, and the
+// value of any header whose name says it is a key, token or secret
+func maskHeader(name, value string) string {
+	for _, secret := range secretHeaders {
+		if http.CanonicalHeaderKey(name) != secret {
+			continue
+		}
+		if scheme, key, ok := strings.Cut(value, " "); ok {
+			return scheme + " " + maskAPIKey(key)
+		}
+		return maskAPIKey(value)
+	}
+	if sensitiveHeader(name) {
+		return maskAPIKey(value)
+	}
+	return value
+}
+
+// printDryRun prints the request that would be sent, as JSON in JSON output
+// mode and otherwise as the request line, headers and pretty-printed body
+func printDryRun(config *Config, req *http.Request) error {
+	output, err := newDryRunOutput(req)
+	if err != nil {
+		return err
+	}
+
+	if config.Output == outputJSON {
+		return printJSON(output)
+	}
+
+	fmt.Printf("%s %s\n", output.Method, output.URL)
+
+	names := make([]string, 0, len(output.Headers))
+	for name := range output.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %s\n", name, output.Headers[name])
+	}
+
+	var body bytes.Buffer
+	if err := json.Indent(&body, output.Body, "", "  "); err != nil {
+		return fmt.Errorf("failed to format request body: %w", err)
+	}
+	fmt.Printf("\n%s\n", body.String())
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNewChatRequest tests building a request without sending it
+func TestNewChatRequest(t *testing.T) {
+	config := &Config{
+		APIKey:      "sk-test-1234567890",
+		APIURL:      defaultAPIURL,
+		Model:       "gpt-4o",
+		MaxTokens:   100,
+		Temperature: 0.5,
+	}
+
+	req, err := newChatRequest(context.Background(), config, "hello", true)
+	if err != nil {
+		t.Fatalf("newChatRequest() error = %v", err)
+	}
+
+	if req.Method != "POST" || req.URL.String() != defaultAPIURL {
+		t.Errorf("request = %s %s, want POST %s", req.Method, req.URL, defaultAPIURL)
+	}
+	if req.Header.Get("Authorization") != "Bearer sk-test-1234567890" {
+		t.Errorf("Authorization = %q", req.Header.Get("Authorization"))
+	}
+	if req.Header.Get("Accept") != "text/event-stream" {
+		t.Errorf("Accept = %q, want text/event-stream", req.Header.Get("Accept"))
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+
+	var body ChatRequest
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("failed to parse body: %v", err)
+	}
+	if body.Model != "gpt-4o" || len(body.Messages) != 1 || body.Messages[0].Content != "hello" || !body.Stream {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+// TestMaskHeader tests that API keys are masked in printed headers
+func TestMaskHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		expected string
+	}{
+		{"bearer token", "Authorization", "Bearer sk-test-1234567890", "Bearer sk-t...7890"},
+		{"azure key", "api-key", "abcdef123456", "abcd...3456"},
+		{"short key", "Api-Key", "abc", "***"},
+		{"anthropic key", "x-api-key", "sk-ant-1234567890", "sk-a...7890"},
+		{"other header", "Content-Type", "application/json", "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := maskHeader(tt.header, tt.value); result != tt.expected {
+				t.Errorf("maskHeader(%q, %q) = %q, want %q", tt.header, tt.value, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestPromptCommandDryRun tests that a dry run prints the request and sends nothing
+func TestPromptCommandDryRun(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		output   string
+		apiKey   string
+		contains []string
+		excludes string
+	}{
+		{
+			name:     "plain",
+			apiKey:   "sk-test-1234567890",
+			contains: []string{"POST " + server.URL, "Authorization: Bearer sk-t...7890", `"content": "hello"`},
+			excludes: "sk-test-1234567890",
+		},
+		{
+			name:     "json",
+			output:   outputJSON,
+			apiKey:   "sk-test-1234567890",
+			contains: []string{`"method": "POST"`, `"Authorization": "Bearer sk-t...7890"`, `"content": "hello"`},
+			excludes: "sk-test-1234567890",
+		},
+		{
+			name:     "without API key",
+			contains: []string{"Authorization: Bearer (not set)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			config := &Config{
+				APIKey:    tt.apiKey,
+				APIURL:    server.URL,
+				Model:     "gpt-4o",
+				Timeout:   10 * time.Second,
+				Output:    tt.output,
+				ConfigDir: tmpDir,
+			}
+
+			var err error
+			out := captureOutput(t, &os.Stdout, func() {
+				err = promptCommand(config, []string{"--dry-run", "hello"})
+			})
+			if err != nil {
+				t.Fatalf("promptCommand() error = %v", err)
+			}
+
+			for _, s := range tt.contains {
+				if !strings.Contains(out, s) {
+					t.Errorf("output = %q, want it to contain %q", out, s)
+				}
+			}
+			if tt.excludes != "" && strings.Contains(out, tt.excludes) {
+				t.Errorf("output contains the unmasked API key")
+			}
+
+			if _, err := os.Stat(filepath.Join(tmpDir, "logs.jsonl")); !os.IsNotExist(err) {
+				t.Errorf("a dry run should not write to the log")
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Embeddings models used when OPENAI_EMBED_MODEL is unset
+const (
+	defaultEmbedModel       = "text-embedding-3-small"
+	defaultOllamaEmbedModel = "nomic-embed-text"
+)
+
+// EmbeddingRequest is the body of an embeddings request, understood by the
+// OpenAI, Azure OpenAI and Ollama endpoints
+type EmbeddingRequest struct {
+	Model      string   `json:"model,omitempty"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+// EmbeddingResponse is the reply of the embeddings endpoint
+type EmbeddingResponse struct {
+	Data  []Embedding `json:"data"`
+	Model string      `json:"model,omitempty"`
+	Usage Usage       `json:"usage"`
+
+	// Embeddings is the list returned by Ollama's /api/embed instead of data
+	Embeddings [][]float64 `json:"embeddings,omitempty"`
+}
+
+// Embedding is the vector of one input
+type Embedding struct {
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// FileEmbedding is the vector of one file of embed --file-list, written as
+// one JSON line
+type FileEmbedding struct {
+	File      string    `json:"file"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// defaultEmbedModelFor returns the model used when OPENAI_EMBED_MODEL is unset
+func defaultEmbedModelFor(provider string) string {
+	if provider == providerOllama {
+		return defaultOllamaEmbedModel
+	}
+	return defaultEmbedModel
+}
+
+// embedCommand prints the embedding of a text, a file or a list of files
+func embedCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli embed [--dims N] [--out path] <text> | --file path | --file-list files.txt"
+
+	fs := flag.NewFlagSet("embed", flag.ContinueOnError)
+	file := fs.String("file", "", "embed the contents of a file; - reads stdin")
+	fileList := fs.String("file-list", "", "embed every file listed, one path per line, writing JSONL")
+	outFile := fs.String("out", "", "write the output to this file instead of stdout")
+	dims := fs.Int("dims", 0, "ask for embeddings with N dimensions")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+
+	sources := 0
+	for _, given := range []bool{len(args) > 0, *file != "", *fileList != ""} {
+		if given {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return usageErrorf("give exactly one of a text, --file or --file-list\n%s", usage)
+	}
+	if *dims < 0 {
+		return usageErrorf("--dims must be a positive integer")
+	}
+	if config.Provider == providerAnthropic {
+		return usageErrorf("the %s provider has no embeddings endpoint; use %s, %s or %s", providerAnthropic, providerOpenAI, providerAzure, providerOllama)
+	}
+
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	// Files are named in the log, like the files attached to a prompt
+	var inputs []string
+	var attachments []attachment
+	loggedInput := strings.Join(args, " ")
+	switch {
+	case *file != "":
+		a, err := readAttachment(*file, config.MaxFileSize)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		attachments = []attachment{a}
+	case *fileList != "":
+		paths, err := readPrompts(*fileList)
+		if err != nil {
+			return fmt.Errorf("failed to read file list: %w", err)
+		}
+		if len(paths) == 0 {
+			return fmt.Errorf("no files found in %s", *fileList)
+		}
+		if attachments, err = readAttachments(paths, config.MaxFileSize); err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+	default:
+		inputs = []string{loggedInput}
+	}
+	for _, a := range attachments {
+		inputs = append(inputs, a.Content)
+	}
+	if len(attachments) > 0 {
+		loggedInput = promptForLog("", attachments, config.LogFullPrompt)
+	}
+	for i, input := range inputs {
+		if strings.TrimSpace(input) == "" {
+			if len(attachments) > 0 {
+				return usageErrorf("%s is empty", attachments[i].Name)
+			}
+			return usageErrorf("text to embed cannot be empty")
+		}
+	}
+
+	start := time.Now()
+	response, err := fetchEmbeddings(config.requestContext(), config, inputs, *dims)
+	entry := LogEntry{
+		Timestamp: time.Now(),
+		Command:   "embed",
+		Prompt:    loggedInput,
+		Model:     config.EmbedModel,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		if isCancelled(err) {
+			entry.Error = cancelledLogMessage
+		}
+		warnLogError(config, writeLogEntry(config, entry))
+		return fmt.Errorf("failed to get embeddings: %w", err)
+	}
+	if response.Model != "" {
+		entry.Model = response.Model
+	}
+	noun := "embeddings"
+	if len(response.Data) == 1 {
+		noun = "embedding"
+	}
+	entry.Response = fmt.Sprintf("[%d %s of %d dimensions]", len(response.Data), noun, len(response.Data[0].Embedding))
+	entry.Usage = usageOrNil(response.Usage)
+	warnLogError(config, writeLogEntry(config, entry))
+
+	var out io.Writer = os.Stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := writeEmbeddings(out, response.Data, attachments, *fileList != ""); err != nil {
+		return fmt.Errorf("failed to write embeddings: %w", err)
+	}
+
+	if *outFile != "" {
+		fmt.Printf("Wrote %d %s to %s\n", len(response.Data), noun, *outFile)
+	}
+	return nil
+}
+
+// writeEmbeddings writes a single vector as a JSON array, or with perFile
+// one FileEmbedding line for each attachment
+func writeEmbeddings(w io.Writer, embeddings []Embedding, attachments []attachment, perFile bool) error {
+	enc := json.NewEncoder(w)
+	if !perFile {
+		return enc.Encode(embeddings[0].Embedding)
+	}
+	for i, embedding := range embeddings {
+		if err := enc.Encode(FileEmbedding{File: attachments[i].Name, Embedding: embedding.Embedding}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getEmbeddingsURL returns the embeddings endpoint, derived from the chat
+// completions URL: /v1/embeddings for OpenAI, the deployment's embeddings for
+// Azure and /api/embed for Ollama
+func getEmbeddingsURL(config *Config) (string, error) {
+	apiURL, err := chatCompletionsURL(config)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(defaultAPIURL, "/chat/completions") + "/embeddings", nil
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"):
+		u.Path = strings.TrimSuffix(path, "/chat/completions") + "/embeddings"
+	case strings.HasSuffix(path, "/api/chat"):
+		u.Path = strings.TrimSuffix(path, "/api/chat") + "/api/embed"
+	default:
+		u.Path = "/v1/embeddings"
+	}
+
+	return u.String(), nil
+}
+
+// newEmbeddingsRequest builds the request for the embeddings of inputs
+func newEmbeddingsRequest(ctx context.Context, config *Config, inputs []string, dims int) (*http.Request, error) {
+	requestBody := EmbeddingRequest{
+		Model:      config.EmbedModel,
+		Input:      inputs,
+		Dimensions: dims,
+	}
+	// Azure encodes the model in the deployment URL
+	if config.Provider == providerAzure {
+		requestBody.Model = ""
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	apiURL, err := getEmbeddingsURL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeader(req, config)
+	if err := setExtraHeaders(req, config); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// fetchEmbeddings sends all inputs in one request and returns their
+// embeddings in input order
+func fetchEmbeddings(ctx context.Context, config *Config, inputs []string, dims int) (*EmbeddingResponse, error) {
+	client := newAPIClient(config)
+	resp, err := client.doLimited(ctx, func() (*http.Request, error) {
+		return newEmbeddingsRequest(ctx, config, inputs, dims)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response EmbeddingResponse
+	if err := decodeResponse(resp, &response); err != nil {
+		return nil, err
+	}
+
+	for i, vector := range response.Embeddings {
+		response.Data = append(response.Data, Embedding{Index: i, Embedding: vector})
+	}
+	sort.Slice(response.Data, func(i, j int) bool {
+		return response.Data[i].Index < response.Data[j].Index
+	})
+	if len(response.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(response.Data))
+	}
+	return &response, nil
+}
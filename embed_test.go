@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGetEmbeddingsURL tests deriving the embeddings endpoint from the chat
+// completions URL of each provider
+func TestGetEmbeddingsURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+	}{
+		{"openai", Config{APIURL: defaultAPIURL}, "https://api.openai.com/v1/embeddings"},
+		{"proxy prefix", Config{APIURL: "https://gateway.example.com/openai/v1/chat/completions"}, "https://gateway.example.com/openai/v1/embeddings"},
+		{"other path", Config{APIURL: "https://llm.example.com/generate"}, "https://llm.example.com/v1/embeddings"},
+		{"azure", Config{Provider: providerAzure, APIURL: "https://res.openai.azure.com/openai/deployments/embed/chat/completions"},
+			"https://res.openai.azure.com/openai/deployments/embed/embeddings?api-version=" + defaultAzureAPIVersion},
+		{"ollama", Config{Provider: providerOllama, APIURL: defaultOllamaAPIURL}, "http://localhost:11434/api/embed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getEmbeddingsURL(&tt.config)
+			if err != nil {
+				t.Fatalf("getEmbeddingsURL() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("getEmbeddingsURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// newEmbeddingsServer returns a server answering embeddings requests with
+// one vector per input, in reverse order, and the last request it received
+func newEmbeddingsServer(t *testing.T) (*httptest.Server, *EmbeddingRequest, *http.Request) {
+	t.Helper()
+
+	var request EmbeddingRequest
+	var received http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = *r.Clone(r.Context())
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+
+		response := EmbeddingResponse{Model: request.Model, Usage: Usage{PromptTokens: 4, TotalTokens: 4}}
+		for i := len(request.Input) - 1; i >= 0; i-- {
+			response.Data = append(response.Data, Embedding{Index: i, Embedding: []float64{float64(i), 0.5}})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server, &request, &received
+}
+
+// TestEmbedCommand tests embedding a text and what is sent and logged
+func TestEmbedCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server, request, received := newEmbeddingsServer(t)
+	config := &Config{
+		APIKey:       "sk-test",
+		APIURL:       server.URL + "/v1/chat/completions",
+		EmbedModel:   defaultEmbedModel,
+		Timeout:      5 * time.Second,
+		ConfigDir:    t.TempDir(),
+		ExtraHeaders: []extraHeader{{"X-Team-Id", "42"}},
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := embedCommand(config, []string{"--dims", "2", "hello", "world"}); err != nil {
+			t.Fatalf("embedCommand() error = %v", err)
+		}
+	})
+	if out != "[0,0.5]\n" {
+		t.Errorf("output = %q, want the vector as JSON", out)
+	}
+
+	if received.URL.Path != "/v1/embeddings" || received.Header.Get("Authorization") != "Bearer sk-test" || received.Header.Get("X-Team-Id") != "42" {
+		t.Errorf("request = %s %v", received.URL.Path, received.Header)
+	}
+	want := EmbeddingRequest{Model: defaultEmbedModel, Input: []string{"hello world"}, Dimensions: 2}
+	if !reflect.DeepEqual(*request, want) {
+		t.Errorf("request body = %+v, want %+v", *request, want)
+	}
+
+	entries := readTestLogEntries(t, config.ConfigDir)
+	if len(entries) != 1 || entries[0].Command != "embed" || entries[0].Prompt != "hello world" || entries[0].Usage == nil {
+		t.Fatalf("logged %+v", entries)
+	}
+	if entries[0].Response != "[1 embedding of 2 dimensions]" {
+		t.Errorf("logged response = %q", entries[0].Response)
+	}
+	history, err := readHistory(config.ConfigDir)
+	if err != nil || len(history) != 0 {
+		t.Errorf("history = %+v, %v; embeddings are not prompts", history, err)
+	}
+}
+
+// TestEmbedCommandFileList tests that listed files are sent in one request
+// and written as JSONL in list order
+func TestEmbedCommandFileList(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server, request, _ := newEmbeddingsServer(t)
+	tmpDir := t.TempDir()
+	config := &Config{
+		APIKey:     "sk-test",
+		APIURL:     server.URL + "/v1/chat/completions",
+		EmbedModel: defaultEmbedModel,
+		Timeout:    5 * time.Second,
+		ConfigDir:  t.TempDir(),
+	}
+
+	a := filepath.Join(tmpDir, "a.txt")
+	b := filepath.Join(tmpDir, "b.txt")
+	os.WriteFile(a, []byte("first"), 0644)
+	os.WriteFile(b, []byte("second"), 0644)
+	list := filepath.Join(tmpDir, "files.txt")
+	os.WriteFile(list, []byte(a+"\n\n# skipped\n"+b+"\n"), 0644)
+	outFile := filepath.Join(tmpDir, "vectors.jsonl")
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := embedCommand(config, []string{"--file-list", list, "--out", outFile}); err != nil {
+			t.Fatalf("embedCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Wrote 2 embeddings to "+outFile) {
+		t.Errorf("output = %q", out)
+	}
+	if !reflect.DeepEqual(request.Input, []string{"first", "second"}) {
+		t.Errorf("inputs = %q, want both files in one request", request.Input)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %d lines, want 2", len(lines))
+	}
+	for i, line := range lines {
+		var got FileEmbedding
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		if got.File != []string{a, b}[i] || got.Embedding[0] != float64(i) {
+			t.Errorf("line %d = %+v", i, got)
+		}
+	}
+
+	entries := readTestLogEntries(t, config.ConfigDir)
+	if len(entries) != 1 || entries[0].Prompt != "[attached: "+a+", "+b+"]" {
+		t.Errorf("logged %+v, want the file names", entries)
+	}
+}
+
+// TestEmbedCommandOllama tests reading Ollama's /api/embed reply
+func TestEmbedCommandOllama(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"nomic-embed-text","embeddings":[[0.1,0.2,0.3]]}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Provider:   providerOllama,
+		APIURL:     server.URL + "/api/chat",
+		EmbedModel: defaultOllamaEmbedModel,
+		Timeout:    5 * time.Second,
+		ConfigDir:  t.TempDir(),
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := embedCommand(config, []string{"hello"}); err != nil {
+			t.Fatalf("embedCommand() error = %v", err)
+		}
+	})
+	if path != "/api/embed" || out != "[0.1,0.2,0.3]\n" {
+		t.Errorf("path = %q, output = %q", path, out)
+	}
+}
+
+// TestEmbedCommandAPIError tests that API errors are reported and logged
+func TestEmbedCommandAPIError(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"This model does not support specifying dimensions.","type":"invalid_request_error"}}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:     "sk-test",
+		APIURL:     server.URL + "/v1/chat/completions",
+		EmbedModel: "text-embedding-ada-002",
+		Timeout:    5 * time.Second,
+		ConfigDir:  t.TempDir(),
+	}
+
+	err := embedCommand(config, []string{"--dims", "8", "hello"})
+	if err == nil || !strings.Contains(err.Error(), "does not support specifying dimensions") {
+		t.Fatalf("embedCommand() error = %v, want the API error", err)
+	}
+	entries := readTestLogEntries(t, config.ConfigDir)
+	if len(entries) != 1 || entries[0].Error == "" {
+		t.Errorf("logged %+v, want the error", entries)
+	}
+}
+
+// TestEmbedCommandUsage tests the arguments refused by embed
+func TestEmbedCommandUsage(t *testing.T) {
+	config := &Config{APIKey: "sk-test", ConfigDir: t.TempDir()}
+	for _, args := range [][]string{
+		{},
+		{"--file", "doc.txt", "hello"},
+		{"--file", "a.txt", "--file-list", "files.txt"},
+		{"--dims", "-1", "hello"},
+		{"  "},
+	} {
+		if err := embedCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("embedCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+
+	config.Provider = providerAnthropic
+	if err := embedCommand(config, []string{"hello"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("embedCommand() with anthropic error = %v, want a usage error", err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Config file key holding the encrypted API key
+const encryptedAPIKeyKey = "OPENAI_API_KEY_ENC"
+
+// Passphrase used instead of prompting, for non-interactive use
+const envPassphrase = "CHATGPT_CLI_PASSPHRASE"
+
+// scrypt cost parameters and sizes of the stored salt and derived key
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	encryptSalt   = 16
+	encryptKeyLen = 32
+)
+
+var errWrongPassphrase = errors.New("wrong passphrase for the encrypted API key")
+
+// encryptAPIKey encrypts apiKey with AES-GCM under a key derived from the
+// passphrase with scrypt. The result is base64 of salt, nonce and ciphertext.
+func encryptAPIKey(apiKey, passphrase string) (string, error) {
+	salt := make([]byte, encryptSalt)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	gcm, err := newAPIKeyCipher(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(append(salt, nonce...), nonce, []byte(apiKey), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptAPIKey reverses encryptAPIKey. A passphrase that doesn't match
+// returns errWrongPassphrase.
+func decryptAPIKey(encoded, passphrase string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < encryptSalt {
+		return "", fmt.Errorf("invalid %s value in the config file", encryptedAPIKeyKey)
+	}
+
+	gcm, err := newAPIKeyCipher(passphrase, data[:encryptSalt])
+	if err != nil {
+		return "", err
+	}
+
+	data = data[encryptSalt:]
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("invalid %s value in the config file", encryptedAPIKeyKey)
+	}
+
+	apiKey, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errWrongPassphrase
+	}
+	return string(apiKey), nil
+}
+
+// newAPIKeyCipher derives the encryption key from the passphrase and salt
+func newAPIKeyCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, encryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readPassphrase returns CHATGPT_CLI_PASSPHRASE, or asks for the passphrase on
+// the terminal without echoing it. New passphrases are asked for twice.
+func readPassphrase(prompt string, repeat bool) (string, error) {
+	if passphrase := os.Getenv(envPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("passphrase required: set %s or run in a terminal", envPassphrase)
+	}
+
+	passphrase, err := readHidden(prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase cannot be empty")
+	}
+
+	if repeat {
+		again, err := readHidden("Repeat passphrase: ")
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %w", err)
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// readHidden asks for a secret on the terminal without echoing it
+func readHidden(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(secret), err
+}
+
+// unlockAPIKey decrypts an API key stored encrypted in the config file. The
+// passphrase is only asked for by commands that send requests, and once.
+func unlockAPIKey(config *Config) error {
+	if config.encryptedAPIKey == "" {
+		return nil
+	}
+
+	passphrase, err := readPassphrase("Passphrase for the API key: ", false)
+	if err != nil {
+		return withKind(ErrAuth, err)
+	}
+
+	apiKey, err := decryptAPIKey(config.encryptedAPIKey, passphrase)
+	if err != nil {
+		return withKind(ErrAuth, err)
+	}
+
+	config.APIKey = apiKey
+	config.encryptedAPIKey = ""
+	return nil
+}
+
+// requireAPIKey unlocks the API key if needed and fails when there is none
+func requireAPIKey(config *Config) error {
+	if err := unlockAPIKey(config); err != nil {
+		return err
+	}
+	if config.APIKey == "" && providerRequiresAPIKey(config.Provider) {
+		// A new user is pointed to the setup wizard
+		if !configFileExists(config.ConfigDir) {
+			return withKind(ErrAuth, fmt.Errorf("missing API key: run chatgpt-cli init to set one up, or set %s", apiKeyName(config.Provider)))
+		}
+		return withKind(ErrAuth, fmt.Errorf("missing API key: set %s or run: chatgpt-cli auth login", apiKeyName(config.Provider)))
+	}
+	return nil
+}
+
+// displayAPIKey returns the API key as shown by config list and get
+func displayAPIKey(config *Config) string {
+	if config.APIKey == "" && config.encryptedAPIKey != "" {
+		return "(encrypted)"
+	}
+	return maskAPIKey(config.APIKey)
+}
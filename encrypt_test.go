@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestEncryptAPIKey tests the encryption round trip
+func TestEncryptAPIKey(t *testing.T) {
+	encrypted, err := encryptAPIKey("sk-secret", "correct horse")
+	if err != nil {
+		t.Fatalf("encryptAPIKey() error = %v", err)
+	}
+	if strings.Contains(encrypted, "sk-secret") {
+		t.Errorf("encrypted value contains the key: %s", encrypted)
+	}
+
+	again, err := encryptAPIKey("sk-secret", "correct horse")
+	if err != nil || again == encrypted {
+		t.Errorf("encrypting twice should use a new salt and nonce")
+	}
+
+	apiKey, err := decryptAPIKey(encrypted, "correct horse")
+	if err != nil || apiKey != "sk-secret" {
+		t.Errorf("decryptAPIKey() = %q, %v, want %q", apiKey, err, "sk-secret")
+	}
+
+	if _, err := decryptAPIKey(encrypted, "wrong horse"); !errors.Is(err, errWrongPassphrase) {
+		t.Errorf("decryptAPIKey(wrong passphrase) error = %v, want %v", err, errWrongPassphrase)
+	}
+	for _, invalid := range []string{"not base64!", "c2hvcnQ="} {
+		if _, err := decryptAPIKey(invalid, "correct horse"); err == nil || !strings.Contains(err.Error(), "invalid OPENAI_API_KEY_ENC") {
+			t.Errorf("decryptAPIKey(%q) error = %v, want invalid value", invalid, err)
+		}
+	}
+}
+
+// TestConfigSetEncryptedAPIKey tests storing, listing and unlocking an
+// encrypted API key
+func TestConfigSetEncryptedAPIKey(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	setTestEnv(envPassphrase, "correct horse")
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := configSetCommand(&Config{ConfigDir: tmpDir}, []string{"--encrypt", "OPENAI_API_KEY", "sk-secret"}); err != nil {
+			t.Errorf("configSetCommand(--encrypt) error = %v", err)
+		}
+	})
+
+	data, err := os.ReadFile(secretsFilePath(tmpDir))
+	if err != nil {
+		t.Fatalf("failed to read credentials file: %v", err)
+	}
+	if strings.Contains(string(data), "sk-secret") || !strings.Contains(string(data), "api_key_enc = ") {
+		t.Errorf("credentials file should only hold the encrypted key:\n%s", data)
+	}
+
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.APIKey != "" {
+		t.Errorf("loadConfig() should not decrypt the key before it is needed")
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := configListCommand(config, nil); err != nil {
+			t.Errorf("configListCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "OPENAI_API_KEY\t(encrypted)") {
+		t.Errorf("config list should show the key as encrypted:\n%s", out)
+	}
+
+	if err := requireAPIKey(config); err != nil || config.APIKey != "sk-secret" {
+		t.Errorf("requireAPIKey() = %v, APIKey %q, want the decrypted key", err, config.APIKey)
+	}
+
+	// A wrong passphrase is an authentication error of its own
+	config, _ = loadConfig("")
+	setTestEnv(envPassphrase, "wrong horse")
+	err = requireAPIKey(config)
+	if !errors.Is(err, errWrongPassphrase) || exitCode(err) != exitAuth {
+		t.Errorf("requireAPIKey(wrong passphrase) error = %v, want %v with exit status %d", err, errWrongPassphrase, exitAuth)
+	}
+
+	// Without a passphrase and a terminal there is nothing to ask
+	os.Unsetenv(envPassphrase)
+	if err := requireAPIKey(config); err == nil || !strings.Contains(err.Error(), "passphrase required") {
+		t.Errorf("requireAPIKey(no passphrase) error = %v, want passphrase required", err)
+	}
+
+	// The environment still takes precedence over the config file
+	setTestEnv(envAPIKey, "sk-env")
+	config, _ = loadConfig("")
+	if err := requireAPIKey(config); err != nil || config.APIKey != "sk-env" {
+		t.Errorf("requireAPIKey() = %v, APIKey %q, want the environment key", err, config.APIKey)
+	}
+	os.Unsetenv(envAPIKey)
+
+	// A plain key replaces the encrypted one, and unset removes either
+	captureOutput(t, &os.Stdout, func() {
+		if err := configSetCommand(&Config{ConfigDir: tmpDir}, []string{"OPENAI_API_KEY", "sk-plain"}); err != nil {
+			t.Errorf("configSetCommand() error = %v", err)
+		}
+	})
+	sections := loadTestConfigSections(t, tmpDir)
+	if _, exists := sections[""][encryptedAPIKeyKey]; exists || sections[""][envAPIKey] != "sk-plain" {
+		t.Errorf("default section = %v, want only the plain key", sections[""])
+	}
+
+	setTestEnv(envPassphrase, "correct horse")
+	captureOutput(t, &os.Stdout, func() {
+		if err := configSetCommand(&Config{ConfigDir: tmpDir}, []string{"--encrypt", "OPENAI_API_KEY", "sk-secret"}); err != nil {
+			t.Errorf("configSetCommand(--encrypt) error = %v", err)
+		}
+		if err := configUnsetCommand(&Config{ConfigDir: tmpDir}, []string{"OPENAI_API_KEY"}); err != nil {
+			t.Errorf("configUnsetCommand() error = %v", err)
+		}
+	})
+	if sections := loadTestConfigSections(t, tmpDir); len(sections[""]) != 0 {
+		t.Errorf("default section = %v, want no API key left", sections[""])
+	}
+}
+
+// TestConfigSetEncryptErrors tests the misuses of --encrypt
+func TestConfigSetEncryptErrors(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	config := &Config{ConfigDir: t.TempDir()}
+
+	if err := configSetCommand(config, []string{"--encrypt", "OPENAI_MODEL", "gpt-4"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("configSetCommand(--encrypt OPENAI_MODEL) error = %v, want a usage error", err)
+	}
+	if err := configSetCommand(config, []string{"OPENAI_API_KEY_ENC", "abc"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("configSetCommand(OPENAI_API_KEY_ENC) error = %v, want a usage error", err)
+	}
+	if err := configSetCommand(config, []string{"--encrypt", "OPENAI_API_KEY", "sk-secret"}); err == nil || !strings.Contains(err.Error(), "passphrase required") {
+		t.Errorf("configSetCommand(--encrypt) without passphrase error = %v, want passphrase required", err)
+	}
+}
+
+// TestLoadProfileEncryptedAPIKey tests that a profile's key replaces the
+// default one in either form
+func TestLoadProfileEncryptedAPIKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, `api_key = "sk-personal"
+
+[profile.work]
+api_key_enc = "c2VjcmV0"
+`)
+
+	fileConfig, err := loadProfileConfig(tmpDir, "work")
+	if err != nil {
+		t.Fatalf("loadProfileConfig() error = %v", err)
+	}
+	if _, exists := fileConfig[envAPIKey]; exists || fileConfig[encryptedAPIKeyKey] != "c2VjcmV0" {
+		t.Errorf("work config = %v, want only the encrypted key", fileConfig)
+	}
+
+	fileConfig, err = loadProfileConfig(tmpDir, "")
+	if err != nil || fileConfig[envAPIKey] != "sk-personal" {
+		t.Errorf("default config = %v, %v, want the plain key", fileConfig, err)
+	}
+}
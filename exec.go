@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// dangerousCommand is a kind of shell command that can destroy data, which
+// prompt --exec only runs once the user types yes in full
+type dangerousCommand struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Commands that need yes typed in full. A match anywhere in the script
+// counts, so a pipeline or a chain of commands can't hide one.
+var dangerousCommands = []dangerousCommand{
+	{"recursive rm", regexp.MustCompile(`\brm\s+(?:[^;&|\n]*\s)?(?:-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)\b`)},
+	{"mkfs", regexp.MustCompile(`\bmkfs(?:\.[a-z0-9]+)?\b`)},
+	{"dd to a device", regexp.MustCompile(`\bdd\b[^;&|\n]*\bof=/dev/`)},
+	{"write to a disk device", regexp.MustCompile(`>\s*/dev/(?:sd|hd|vd|xvd|nvme|disk|mmcblk)`)},
+	{"wipefs or shred", regexp.MustCompile(`\b(?:wipefs|shred)\b`)},
+	{"fork bomb", regexp.MustCompile(`:\s*\(\s*\)\s*\{[^}]*:\s*\|\s*:`)},
+	{"recursive delete", regexp.MustCompile(`(?i)\b(?:(?:rd|rmdir|del|erase)\s+(?:[^;&|\n]*\s)?/s\b|remove-item\b[^;&|\n]*-recurse)`)},
+	{"format a drive", regexp.MustCompile(`(?i)\bformat(?:-volume)?\s+[a-z]:`)},
+}
+
+// exitStatusError is how a command run by prompt --exec failed. Its status
+// becomes the exit status of the CLI.
+type exitStatusError struct {
+	status int
+}
+
+func (e *exitStatusError) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.status)
+}
+
+// checkExecFlags checks --exec and --yes before the prompt is sent, so that
+// a command that could never be confirmed doesn't cost a request
+func checkExecFlags(config *Config, execute, yes, dryRun bool) error {
+	switch {
+	case yes && !execute:
+		return usageErrorf("--yes needs --exec")
+	case !execute:
+		return nil
+	case config.Output == outputJSON:
+		return usageErrorf("--exec cannot be combined with --output json")
+	case dryRun:
+		return usageErrorf("--exec cannot be combined with --dry-run")
+	case !yes && !stdinIsTerminal():
+		return usageErrorf("--exec needs a terminal to ask for confirmation; pass --yes to run the command without asking")
+	}
+	return nil
+}
+
+// execReply runs the first code block of reply with the user's shell once
+// confirmed, streaming its output, and records the command and its exit
+// status in entry. Commands matching dangerousCommands need yes typed in
+// full, even with --yes, which only skips the y/N question. A status other
+// than 0 is returned as an *exitStatusError.
+func execReply(config *Config, entry *LogEntry, reply string, yes bool) error {
+	blocks := findCodeBlocks(reply)
+	if len(blocks) == 0 || strings.TrimSpace(blocks[0].content) == "" {
+		return fmt.Errorf("--exec: the response has no code block to run")
+	}
+	script := strings.TrimSpace(blocks[0].content)
+
+	errOut := newUI(config, os.Stderr)
+	errOut.Println(errOut.bold("Command to run:"))
+	for _, line := range strings.Split(script, "\n") {
+		errOut.Printf("  %s\n", line)
+	}
+
+	switch danger := matchDangerousCommand(script); {
+	case danger != "":
+		errOut.Printf("%s this command looks destructive (%s)\n", errOut.red("Warning:"), danger)
+		if !stdinIsTerminal() {
+			return fmt.Errorf("refusing to run a destructive command without a terminal to confirm it, even with --yes")
+		}
+		if !confirmWordOn(os.Stderr, "Type yes to run it:", "yes") {
+			errOut.Println("Command not run")
+			return nil
+		}
+	case !yes:
+		if !confirmOn(os.Stderr, "Run it?") {
+			errOut.Println("Command not run")
+			return nil
+		}
+	}
+
+	shell := shellCommand(runtime.GOOS, os.Getenv("SHELL"), script)
+	cmd := exec.Command(shell[0], shell[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+
+	status := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		// Killed by a signal, the status is -1
+		if status = exitErr.ExitCode(); status < 0 {
+			status = exitFailure
+		}
+	case err != nil:
+		entry.Exec = script
+		return fmt.Errorf("failed to run %s: %w", shell[0], err)
+	}
+	entry.Exec, entry.ExitStatus = script, &status
+	if status != 0 {
+		return &exitStatusError{status: status}
+	}
+	return nil
+}
+
+// matchDangerousCommand returns the name of the first kind of dangerous
+// command found in script, or "" if there is none
+func matchDangerousCommand(script string) string {
+	for _, d := range dangerousCommands {
+		if d.pattern.MatchString(script) {
+			return d.name
+		}
+	}
+	return ""
+}
+
+// shellCommand returns the command line running script with the user's
+// shell: $SHELL, or sh when it is unset, and cmd.exe on Windows
+func shellCommand(goos, shell, script string) []string {
+	if goos == "windows" {
+		return []string{"cmd.exe", "/C", script}
+	}
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return []string{shell, "-c", script}
+}
+
+// confirmWordOn asks question on w and reports whether the answer read from
+// stdin is word, in any case
+func confirmWordOn(w io.Writer, question, word string) bool {
+	fmt.Fprintf(w, "%s ", question)
+
+	answer, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(w)
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(answer), word)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// useExecTerminal sets what --exec reads its confirmation from, and whether
+// that is a terminal, for the rest of the test
+func useExecTerminal(t *testing.T, input string, terminal bool) {
+	originalStdin, originalIsTerminal := stdin, stdinIsTerminal
+	stdin = strings.NewReader(input)
+	stdinIsTerminal = func() bool { return terminal }
+	t.Cleanup(func() { stdin, stdinIsTerminal = originalStdin, originalIsTerminal })
+}
+
+// TestMatchDangerousCommand tests which commands need yes typed in full
+func TestMatchDangerousCommand(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"rm -rf /", "recursive rm"},
+		{"sudo rm -fr ~/build", "recursive rm"},
+		{"rm --force -R dir", "recursive rm"},
+		{"ls && rm --recursive old", "recursive rm"},
+		{"mkfs.ext4 /dev/sdb1", "mkfs"},
+		{"dd if=image.iso of=/dev/sdb bs=4M", "dd to a device"},
+		{"cat zero > /dev/sda", "write to a disk device"},
+		{"shred -u secrets.txt", "wipefs or shred"},
+		{":(){ :|:& };:", "fork bomb"},
+		{"rd /s /q C:\\temp", "recursive delete"},
+		{"Remove-Item C:\\temp -Recurse -Force", "recursive delete"},
+		{"format D: /q", "format a drive"},
+		{"rm file.txt", ""},
+		{"ls -lR | sort -r", ""},
+		{"dd if=/dev/zero of=disk.img bs=1M count=10", ""},
+		{"echo hello > /dev/null", ""},
+		{"du -ah . | sort -rh | head -n 5", ""},
+	}
+	for _, tt := range tests {
+		if got := matchDangerousCommand(tt.script); got != tt.want {
+			t.Errorf("matchDangerousCommand(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+// TestShellCommand tests the shell commands are run with
+func TestShellCommand(t *testing.T) {
+	tests := []struct {
+		goos, shell string
+		want        string
+	}{
+		{"linux", "/bin/zsh", "/bin/zsh|-c|ls"},
+		{"darwin", "", "/bin/sh|-c|ls"},
+		{"windows", "/bin/bash", "cmd.exe|/C|ls"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(shellCommand(tt.goos, tt.shell, "ls"), "|"); got != tt.want {
+			t.Errorf("shellCommand(%q, %q) = %q, want %q", tt.goos, tt.shell, got, tt.want)
+		}
+	}
+}
+
+// TestCheckExecFlags tests the checks made before the prompt is sent
+func TestCheckExecFlags(t *testing.T) {
+	useExecTerminal(t, "", true)
+
+	tests := []struct {
+		name     string
+		config   Config
+		execute  bool
+		yes      bool
+		dryRun   bool
+		terminal bool
+		wantErr  string
+	}{
+		{name: "no exec", terminal: false},
+		{name: "exec", execute: true, terminal: true},
+		{name: "yes without a terminal", execute: true, yes: true, terminal: false},
+		{name: "no terminal", execute: true, terminal: false, wantErr: "pass --yes"},
+		{name: "yes alone", yes: true, terminal: true, wantErr: "--yes needs --exec"},
+		{name: "json", config: Config{Output: outputJSON}, execute: true, terminal: true, wantErr: "--output json"},
+		{name: "dry run", execute: true, dryRun: true, terminal: true, wantErr: "--dry-run"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdinIsTerminal = func() bool { return tt.terminal }
+			err := checkExecFlags(&tt.config, tt.execute, tt.yes, tt.dryRun)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkExecFlags() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkExecFlags() error = %v, want a usage error about %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestExecReply tests confirming, running and declining the command of a reply
+func TestExecReply(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands need a POSIX shell")
+	}
+	t.Setenv("SHELL", "/bin/sh")
+	config := &Config{NoColor: true}
+	marker := filepath.Join(t.TempDir(), "ran")
+	reply := fmt.Sprintf("Run this:\n\n```sh\ntouch %s\nexit 3\n```\n", marker)
+
+	// Declined: nothing runs or is logged
+	useExecTerminal(t, "n\n", true)
+	var entry LogEntry
+	errOut := captureOutput(t, &os.Stderr, func() {
+		if err := execReply(config, &entry, reply, false); err != nil {
+			t.Errorf("execReply() error = %v", err)
+		}
+	})
+	if !strings.Contains(errOut, "Command to run:\n  touch ") || !strings.Contains(errOut, "Command not run") {
+		t.Errorf("stderr = %q", errOut)
+	}
+	if _, err := os.Stat(marker); err == nil || entry.Exec != "" || entry.ExitStatus != nil {
+		t.Errorf("declined command ran: entry = %+v", entry)
+	}
+
+	// Confirmed: the exit status is returned and recorded
+	useExecTerminal(t, "y\n", true)
+	var err error
+	captureOutput(t, &os.Stderr, func() {
+		err = execReply(config, &entry, reply, false)
+	})
+	var statusErr *exitStatusError
+	if !errors.As(err, &statusErr) || statusErr.status != 3 || exitCode(err) != 3 {
+		t.Errorf("execReply() error = %v, want exit status 3", err)
+	}
+	if _, statErr := os.Stat(marker); statErr != nil {
+		t.Errorf("confirmed command didn't run: %v", statErr)
+	}
+	if entry.Exec != "touch "+marker+"\nexit 3" || entry.ExitStatus == nil || *entry.ExitStatus != 3 {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	// --yes runs without asking
+	useExecTerminal(t, "", false)
+	entry = LogEntry{}
+	captureOutput(t, &os.Stderr, func() {
+		err = execReply(config, &entry, "```\ntrue\n```", true)
+	})
+	if err != nil || entry.ExitStatus == nil || *entry.ExitStatus != 0 {
+		t.Errorf("execReply(--yes) = %v, entry = %+v", err, entry)
+	}
+
+	// No code block
+	if err := execReply(config, &LogEntry{}, "Just text", true); err == nil || !strings.Contains(err.Error(), "no code block") {
+		t.Errorf("execReply(no code block) error = %v", err)
+	}
+}
+
+// TestExecReplyDangerous tests that destructive commands need yes typed in
+// full, even with --yes, and a terminal to type it
+func TestExecReplyDangerous(t *testing.T) {
+	config := &Config{NoColor: true}
+	// The directory doesn't exist, so running the command changes nothing
+	reply := "```sh\nrm -rf ./no-such-dir-for-exec-test\n```"
+
+	for _, answer := range []string{"y\n", "ye\n", ""} {
+		useExecTerminal(t, answer, true)
+		var entry LogEntry
+		var err error
+		errOut := captureOutput(t, &os.Stderr, func() {
+			err = execReply(config, &entry, reply, true)
+		})
+		if err != nil || entry.Exec != "" || !strings.Contains(errOut, "Warning: this command looks destructive (recursive rm)") || !strings.Contains(errOut, "Type yes to run it:") {
+			t.Errorf("answer %q: error = %v, entry = %+v, stderr = %q", answer, err, entry, errOut)
+		}
+	}
+
+	useExecTerminal(t, "", false)
+	var err error
+	captureOutput(t, &os.Stderr, func() {
+		err = execReply(config, &LogEntry{}, reply, true)
+	})
+	if err == nil || !strings.Contains(err.Error(), "refusing to run a destructive command") {
+		t.Errorf("execReply() without a terminal error = %v", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	t.Setenv("SHELL", "/bin/sh")
+	useExecTerminal(t, "YES\n", true)
+	var entry LogEntry
+	captureOutput(t, &os.Stderr, func() {
+		err = execReply(config, &entry, reply, false)
+	})
+	if exitCode(err) != 0 || entry.ExitStatus == nil || *entry.ExitStatus != 0 {
+		t.Errorf("execReply(yes) error = %v, entry = %+v", err, entry)
+	}
+}
+
+// TestPromptCommandExec tests that prompt --exec runs the reply's command,
+// exits with its status and logs it
+func TestPromptCommandExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command needs a POSIX shell")
+	}
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+	t.Setenv("SHELL", "/bin/sh")
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Use:\n\n`+"```sh\\necho from-exec\\nexit 4\\n```"+`"}}]}`)
+	}))
+	defer server.Close()
+
+	configDir := t.TempDir()
+	setTestEnv(envConfigDir, configDir)
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	// Without a terminal, --exec needs --yes and nothing is sent
+	useExecTerminal(t, "", false)
+	if err := promptCommand(config, []string{"--no-stream", "--exec", "list files"}); !errors.Is(err, ErrUsage) || requests != 0 {
+		t.Errorf("promptCommand(--exec) error = %v after %d requests, want a usage error before sending", err, requests)
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		captureOutput(t, &os.Stderr, func() {
+			err = promptCommand(config, []string{"--no-stream", "--exec", "--yes", "list files"})
+		})
+	})
+	if exitCode(err) != 4 {
+		t.Errorf("promptCommand(--exec --yes) error = %v, want exit status 4", err)
+	}
+	if !strings.Contains(out, "from-exec\n") {
+		t.Errorf("stdout = %q, want the command's output", out)
+	}
+
+	entries := readTestLogEntries(t, configDir)
+	if len(entries) != 1 || entries[0].Exec != "echo from-exec\nexit 4" || entries[0].ExitStatus == nil || *entries[0].ExitStatus != 4 {
+		t.Fatalf("log entries = %+v", entries)
+	}
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(&Config{ConfigDir: configDir, NoColor: true}, nil); err != nil {
+			t.Errorf("logsCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Exec: echo from-exec⏎exit 4 -> exit status 4") {
+		t.Errorf("logs output = %q", out)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kinds of errors returned by commands. Each maps to its own exit status so
+// that scripts can tell failures apart; see exitCode.
+var (
+	ErrUsage     = errors.New("usage error")
+	ErrAuth      = errors.New("authentication error")
+	ErrRateLimit = errors.New("rate limited")
+	ErrServer    = errors.New("server error")
+	ErrNetwork   = errors.New("network error")
+	// ErrRequestBudget is returned with --no-wait when OPENAI_REQUESTS_PER_MINUTE
+	// allows no more requests for now
+	ErrRequestBudget = errors.New("request budget exhausted")
+)
+
+// Exit statuses
+const (
+	exitFailure   = 1
+	exitUsage     = 2
+	exitAuth      = 3
+	exitRateLimit = 4
+	exitServer    = 5
+	exitNetwork   = 6
+	exitBudget    = 7
+)
+
+// kindError tags an error with one of the error kinds without changing its
+// message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// withKind tags err with kind, so that errors.Is(err, kind) holds
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// usageErrorf formats an error about invalid arguments
+func usageErrorf(format string, args ...interface{}) error {
+	return withKind(ErrUsage, fmt.Errorf(format, args...))
+}
+
+// exitCode returns the exit status for an error returned by a command
+func exitCode(err error) int {
+	var statusErr *exitStatusError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &statusErr):
+		return statusErr.status
+	case errors.Is(err, errCancelled):
+		return exitCancelled
+	case errors.Is(err, ErrUsage):
+		return exitUsage
+	case errors.Is(err, ErrAuth):
+		return exitAuth
+	case errors.Is(err, ErrRateLimit):
+		return exitRateLimit
+	case errors.Is(err, ErrServer):
+		return exitServer
+	case errors.Is(err, ErrNetwork):
+		return exitNetwork
+	case errors.Is(err, ErrRequestBudget):
+		return exitBudget
+	}
+	return exitFailure
+}
+
+// apiErrorKind classifies an API failure from the HTTP status code, or from
+// the error type and code in the body when the status is not conclusive.
+// It returns nil for failures of no particular kind.
+func apiErrorKind(statusCode int, apiErr *APIError) error {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrAuth
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimit
+	case statusCode >= 500:
+		return ErrServer
+	case apiErr == nil:
+		return nil
+	}
+
+	switch {
+	case apiErr.Code == "invalid_api_key" || apiErr.Type == "authentication_error" || apiErr.Type == "permission_error":
+		return ErrAuth
+	case apiErr.Code == "rate_limit_exceeded" || apiErr.Type == "rate_limit_error" || apiErr.Type == "insufficient_quota":
+		return ErrRateLimit
+	case apiErr.Type == "server_error" || apiErr.Type == "api_error" || apiErr.Type == "overloaded_error":
+		return ErrServer
+	}
+	return nil
+}
+
+// newAPIError returns the error for an error object in an API response
+func newAPIError(statusCode int, apiErr *APIError) error {
+	return classifyAPIError(statusCode, apiErr, nil)
+}
+
+// newStatusError returns the error for a response with an unexpected status
+// code, classified using the error object in the body if there is one
+func newStatusError(statusCode int, body []byte) error {
+	var errorBody struct {
+		Error *APIError `json:"error"`
+	}
+	_ = json.Unmarshal(body, &errorBody)
+
+	// The raw body is reported, even an empty one
+	if body == nil {
+		body = []byte{}
+	}
+	return classifyAPIError(statusCode, errorBody.Error, body)
+}
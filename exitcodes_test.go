@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExitCode tests the exit status of each error kind
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: 0},
+		{name: "plain error", err: errors.New("boom"), want: exitFailure},
+		{name: "usage", err: usageErrorf("bad flag"), want: exitUsage},
+		{name: "wrapped usage", err: fmt.Errorf("context: %w", usageErrorf("bad flag")), want: exitUsage},
+		{name: "auth", err: withKind(ErrAuth, errors.New("no key")), want: exitAuth},
+		{name: "rate limit", err: withKind(ErrRateLimit, errors.New("slow down")), want: exitRateLimit},
+		{name: "server", err: withKind(ErrServer, errors.New("oops")), want: exitServer},
+		{name: "network", err: withKind(ErrNetwork, errors.New("refused")), want: exitNetwork},
+		{name: "request budget", err: withKind(ErrRequestBudget, errors.New("wait")), want: exitBudget},
+		{name: "cancelled", err: errCancelled, want: exitCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWithKindKeepsMessage tests that tagging an error leaves it unchanged
+func TestWithKindKeepsMessage(t *testing.T) {
+	base := errors.New("missing API key")
+	err := withKind(ErrAuth, base)
+
+	if err.Error() != base.Error() {
+		t.Errorf("Error() = %q, want %q", err.Error(), base.Error())
+	}
+	if !errors.Is(err, base) {
+		t.Error("errors.Is(err, base) = false, want true")
+	}
+	if errors.Is(err, ErrUsage) {
+		t.Error("errors.Is(err, ErrUsage) = true, want false")
+	}
+	if withKind(ErrAuth, nil) != nil {
+		t.Error("withKind(nil) != nil")
+	}
+}
+
+// TestAPIErrorKind tests classification by status code and error type
+func TestAPIErrorKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		apiErr *APIError
+		want   error
+	}{
+		{name: "unauthorized", status: 401, want: ErrAuth},
+		{name: "forbidden", status: 403, want: ErrAuth},
+		{name: "too many requests", status: 429, want: ErrRateLimit},
+		{name: "internal error", status: 500, want: ErrServer},
+		{name: "bad gateway", status: 502, want: ErrServer},
+		{name: "bad request", status: 400, apiErr: &APIError{Type: "invalid_request_error"}, want: nil},
+		{name: "invalid key code", apiErr: &APIError{Type: "invalid_request_error", Code: "invalid_api_key"}, want: ErrAuth},
+		{name: "rate limit code", apiErr: &APIError{Type: "requests", Code: "rate_limit_exceeded"}, want: ErrRateLimit},
+		{name: "insufficient quota", apiErr: &APIError{Type: "insufficient_quota"}, want: ErrRateLimit},
+		{name: "server error type", apiErr: &APIError{Type: "server_error"}, want: ErrServer},
+		{name: "status wins over type", status: 429, apiErr: &APIError{Type: "server_error"}, want: ErrRateLimit},
+		{name: "nothing known", status: 404, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiErrorKind(tt.status, tt.apiErr); got != tt.want {
+				t.Errorf("apiErrorKind() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMismatchedOrganizationHint tests that a mismatched organization error
+// points at the organization and project settings
+func TestMismatchedOrganizationHint(t *testing.T) {
+	body := []byte(`{"error":{"message":"OpenAI-Organization header should match organization for API key","type":"invalid_request_error","code":"mismatched_organization"}}`)
+
+	errs := map[string]error{
+		"status error": newStatusError(http.StatusUnauthorized, body),
+		"API error":    newAPIError(http.StatusUnauthorized, &APIError{Type: "invalid_request_error", Code: "mismatched_organization"}),
+	}
+	for name, err := range errs {
+		if !strings.Contains(err.Error(), "Hint: check OPENAI_ORG_ID and OPENAI_PROJECT_ID") {
+			t.Errorf("%s = %q, want a hint about OPENAI_ORG_ID and OPENAI_PROJECT_ID", name, err)
+		}
+		if !errors.Is(err, ErrAuth) {
+			t.Errorf("%s is not ErrAuth", name)
+		}
+	}
+
+}
+
+// TestPromptCommandErrorKinds tests the error kind returned per scenario
+func TestPromptCommandErrorKinds(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		apiURL string
+		apiKey string
+		args   []string
+		want   error
+	}{
+		{
+			name:   "invalid API key",
+			status: http.StatusUnauthorized,
+			body:   `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`,
+			want:   ErrAuth,
+		},
+		{
+			name:   "rate limited",
+			status: http.StatusTooManyRequests,
+			body:   `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`,
+			want:   ErrRateLimit,
+		},
+		{
+			name:   "server error",
+			status: http.StatusServiceUnavailable,
+			body:   `{"error":{"message":"The server is overloaded","type":"server_error"}}`,
+			want:   ErrServer,
+		},
+		{
+			name:   "error object in a 200 response",
+			status: http.StatusOK,
+			body:   `{"error":{"message":"You exceeded your current quota","type":"insufficient_quota"}}`,
+			want:   ErrRateLimit,
+		},
+		{
+			name:   "connection refused",
+			apiURL: closedURL,
+			want:   ErrNetwork,
+		},
+		{
+			name:   "missing API key",
+			apiKey: "-",
+			want:   ErrAuth,
+		},
+		{
+			name: "unknown flag",
+			args: []string{"--bogus", "hello"},
+			want: ErrUsage,
+		},
+		{
+			name: "empty prompt",
+			args: []string{"  "},
+			want: ErrUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			config := &Config{
+				APIKey:    "test-key",
+				APIURL:    server.URL,
+				Model:     "gpt-4o",
+				Timeout:   5 * time.Second,
+				ConfigDir: t.TempDir(),
+			}
+			if tt.apiURL != "" {
+				config.APIURL = tt.apiURL
+			}
+			if tt.apiKey == "-" {
+				config.APIKey = ""
+			}
+			args := tt.args
+			if args == nil {
+				args = []string{"--no-stream", "hello"}
+			}
+
+			var err error
+			captureOutput(t, &os.Stderr, func() {
+				err = promptCommand(config, args)
+			})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("promptCommand() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import "strings"
+
+// matchesExpected reports whether a reply is the answer prompt --expect asks
+// for. Surrounding whitespace is ignored on both, and case with ignoreCase.
+func matchesExpected(reply, expected string, ignoreCase bool) bool {
+	reply, expected = strings.TrimSpace(reply), strings.TrimSpace(expected)
+	if ignoreCase {
+		return strings.EqualFold(reply, expected)
+	}
+	return reply == expected
+}
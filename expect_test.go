@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestMatchesExpected tests comparing a response with --expect
+func TestMatchesExpected(t *testing.T) {
+	tests := []struct {
+		reply, expected string
+		ignoreCase      bool
+		want            bool
+	}{
+		{"YES", "YES", false, true},
+		{"  YES\n", "YES", false, true},
+		{"yes", "YES", false, false},
+		{"yes", "YES", true, true},
+		{"YES.", "YES", true, false},
+		{"NO", "YES", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesExpected(tt.reply, tt.expected, tt.ignoreCase); got != tt.want {
+			t.Errorf("matchesExpected(%q, %q, %v) = %v, want %v", tt.reply, tt.expected, tt.ignoreCase, got, tt.want)
+		}
+	}
+}
+
+// TestPromptCommandExpect tests the exit status and output of --expect
+// and --quiet, and what is logged
+func TestPromptCommandExpect(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var maxTokens int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		maxTokens = request.MaxTokens
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":" yes\n"}}]}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:    "sk-test",
+		APIURL:    server.URL,
+		Model:     "gpt-4o",
+		MaxTokens: 1000,
+		Timeout:   5 * time.Second,
+		ConfigDir: t.TempDir(),
+	}
+
+	var err error
+	stdout := captureOutput(t, &os.Stdout, func() {
+		err = promptCommand(config, []string{"--quiet", "--expect", "YES", "--ignore-case", "--max-tokens", "3", "Any secrets?"})
+	})
+	if err != nil {
+		t.Fatalf("matching prompt error = %v", err)
+	}
+	if stdout != "" {
+		t.Errorf("--quiet printed %q", stdout)
+	}
+	if maxTokens != 3 {
+		t.Errorf("max_tokens = %d, want the --max-tokens override", maxTokens)
+	}
+
+	var stderr string
+	stdout = captureOutput(t, &os.Stdout, func() {
+		stderr = captureOutput(t, &os.Stderr, func() {
+			err = promptCommand(config, []string{"--no-stream", "--expect", "YES", "Any secrets?"})
+		})
+	})
+	if err == nil || exitCode(err) != exitFailure {
+		t.Fatalf("mismatching prompt error = %v, want exit status 1", err)
+	}
+	if stdout != "yes\n" || stderr != "yes\n" {
+		t.Errorf("stdout = %q, stderr = %q, want the answer on both", stdout, stderr)
+	}
+
+	entries := readTestLogEntries(t, config.ConfigDir)
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	for i, want := range []bool{true, false} {
+		if entries[i].Expect != "YES" || entries[i].Matched == nil || *entries[i].Matched != want {
+			t.Errorf("entry %d expect = %q, matched = %v, want %v", i, entries[i].Expect, entries[i].Matched, want)
+		}
+	}
+}
+
+// TestPromptCommandExpectUsage tests the flag combinations refused with
+// --expect
+func TestPromptCommandExpectUsage(t *testing.T) {
+	config := &Config{APIKey: "sk-test", Model: "gpt-4o", MaxTokens: 1000}
+	for _, args := range [][]string{
+		{"--ignore-case", "hello"},
+		{"--expect", "YES", "--n", "2", "hello"},
+		{"--max-tokens", "0", "hello"},
+	} {
+		if err := promptCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("promptCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Default number of estimated tokens of code sent by explain-code
+const defaultExplainBudget = 6000
+
+// explainInstructions is the system prompt of explain-code
+const explainInstructions = "You explain source code to a developer reading it for the first time. " +
+	"You are given a file, or part of one, in a fenced code block labeled with its path and the numbers of its first and last lines. " +
+	"Start with two or three sentences on what the code is for, then walk through its main parts in order, naming the functions and types involved and referring to line numbers where it helps. " +
+	"Point out anything subtle, such as concurrency, error handling, resource cleanup or performance traps. Don't repeat the code back."
+
+// explainQuestionInstructions is added to the system prompt when a
+// question is asked about the code
+const explainQuestionInstructions = " The developer asks a question about the code after it: answer it first and directly, then explain only what else is needed to understand the answer."
+
+// codeLanguage is the language of a source file: the tag of its fenced code
+// block and the name the model is told
+type codeLanguage struct {
+	Tag  string
+	Name string
+}
+
+// codeLanguages maps file extensions, lower case, to their language
+var codeLanguages = map[string]codeLanguage{
+	".go":      {"go", "Go"},
+	".py":      {"python", "Python"},
+	".js":      {"javascript", "JavaScript"},
+	".mjs":     {"javascript", "JavaScript"},
+	".cjs":     {"javascript", "JavaScript"},
+	".jsx":     {"jsx", "JavaScript (JSX)"},
+	".ts":      {"typescript", "TypeScript"},
+	".tsx":     {"tsx", "TypeScript (TSX)"},
+	".java":    {"java", "Java"},
+	".kt":      {"kotlin", "Kotlin"},
+	".kts":     {"kotlin", "Kotlin"},
+	".scala":   {"scala", "Scala"},
+	".swift":   {"swift", "Swift"},
+	".c":       {"c", "C"},
+	".h":       {"c", "C"},
+	".cc":      {"cpp", "C++"},
+	".cpp":     {"cpp", "C++"},
+	".cxx":     {"cpp", "C++"},
+	".hpp":     {"cpp", "C++"},
+	".hh":      {"cpp", "C++"},
+	".cs":      {"csharp", "C#"},
+	".m":       {"objectivec", "Objective-C"},
+	".rs":      {"rust", "Rust"},
+	".rb":      {"ruby", "Ruby"},
+	".php":     {"php", "PHP"},
+	".pl":      {"perl", "Perl"},
+	".lua":     {"lua", "Lua"},
+	".r":       {"r", "R"},
+	".jl":      {"julia", "Julia"},
+	".dart":    {"dart", "Dart"},
+	".ex":      {"elixir", "Elixir"},
+	".exs":     {"elixir", "Elixir"},
+	".erl":     {"erlang", "Erlang"},
+	".hs":      {"haskell", "Haskell"},
+	".ml":      {"ocaml", "OCaml"},
+	".clj":     {"clojure", "Clojure"},
+	".zig":     {"zig", "Zig"},
+	".sh":      {"bash", "shell"},
+	".bash":    {"bash", "Bash"},
+	".zsh":     {"zsh", "Zsh"},
+	".fish":    {"fish", "fish"},
+	".ps1":     {"powershell", "PowerShell"},
+	".sql":     {"sql", "SQL"},
+	".html":    {"html", "HTML"},
+	".htm":     {"html", "HTML"},
+	".css":     {"css", "CSS"},
+	".scss":    {"scss", "SCSS"},
+	".vue":     {"vue", "Vue"},
+	".svelte":  {"svelte", "Svelte"},
+	".json":    {"json", "JSON"},
+	".yaml":    {"yaml", "YAML"},
+	".yml":     {"yaml", "YAML"},
+	".toml":    {"toml", "TOML"},
+	".xml":     {"xml", "XML"},
+	".md":      {"markdown", "Markdown"},
+	".proto":   {"protobuf", "Protocol Buffers"},
+	".tf":      {"hcl", "Terraform"},
+	".graphql": {"graphql", "GraphQL"},
+}
+
+// codeFileNames maps file names without a telling extension to their
+// language
+var codeFileNames = map[string]codeLanguage{
+	"dockerfile":  {"dockerfile", "Dockerfile"},
+	"makefile":    {"makefile", "Makefile"},
+	"gnumakefile": {"makefile", "Makefile"},
+	"jenkinsfile": {"groovy", "Jenkins pipeline (Groovy)"},
+}
+
+// detectLanguage returns the language of a file from its name, or false if
+// it is unknown
+func detectLanguage(path string) (codeLanguage, bool) {
+	base := strings.ToLower(filepath.Base(path))
+	if language, ok := codeFileNames[base]; ok {
+		return language, true
+	}
+	language, ok := codeLanguages[filepath.Ext(base)]
+	return language, ok
+}
+
+// lineRange is a range of lines of a file, numbered from 1, both included
+type lineRange struct {
+	Start int
+	End   int
+}
+
+func (r lineRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// parseLineRange parses --lines, as 40-80 or a single line 40
+func parseLineRange(value string) (lineRange, error) {
+	start, end, isRange := strings.Cut(value, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(start))
+	last := first
+	if err == nil && isRange {
+		last, err = strconv.Atoi(strings.TrimSpace(end))
+	}
+	if err != nil || first < 1 || last < first {
+		return lineRange{}, fmt.Errorf("--lines must be a line or a range of lines such as 40-80, got %q", value)
+	}
+	return lineRange{Start: first, End: last}, nil
+}
+
+// codeBlocks splits lines into top-level blocks, such as a function with
+// the comment above it. A block starts at an unindented line following a
+// blank line or a line closing a block, such as } or end, which is the
+// shape functions and types have in most languages.
+func codeBlocks(lines []string) []lineRange {
+	var blocks []lineRange
+	start := 1
+	for i := 1; i < len(lines); i++ {
+		line, previous := lines[i], strings.TrimSpace(lines[i-1])
+		startsBlock := line != "" && line[0] != ' ' && line[0] != '\t' && !strings.HasPrefix(line, "}") &&
+			(previous == "" || (lines[i-1] == previous && isBlockEnd(previous)))
+		if startsBlock {
+			blocks = append(blocks, lineRange{Start: start, End: i})
+			start = i + 1
+		}
+	}
+	return append(blocks, lineRange{Start: start, End: len(lines)})
+}
+
+// isBlockEnd reports whether an unindented line closes a block
+func isBlockEnd(line string) bool {
+	switch strings.TrimRight(line, ";,") {
+	case "}", ")", "]", "end", "fi", "done", "esac":
+		return true
+	}
+	return false
+}
+
+// chunkCode groups the blocks of lines into chunks of at most maxTokens
+// estimated tokens, in order. A block larger than that is cut between
+// lines.
+func chunkCode(lines []string, maxTokens int) []lineRange {
+	var chunks []lineRange
+	current := lineRange{}
+	tokens := 0
+	add := func(block lineRange, blockTokens int) {
+		if current.Start > 0 && tokens+blockTokens > maxTokens {
+			chunks = append(chunks, current)
+			current, tokens = lineRange{}, 0
+		}
+		if current.Start == 0 {
+			current.Start = block.Start
+		}
+		current.End = block.End
+		tokens += blockTokens
+	}
+
+	for _, block := range codeBlocks(lines) {
+		blockTokens := estimateTokens(joinLines(lines, block))
+		if blockTokens <= maxTokens {
+			add(block, blockTokens)
+			continue
+		}
+		for n := block.Start; n <= block.End; n++ {
+			add(lineRange{Start: n, End: n}, estimateTokens(lines[n-1]+"\n"))
+		}
+	}
+	if current.Start > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// joinLines returns the text of a range of lines
+func joinLines(lines []string, r lineRange) string {
+	return strings.Join(lines[r.Start-1:r.End], "\n") + "\n"
+}
+
+// selectCode picks the lines of a file sent to explain-code. Without a hint
+// the whole file is sent if it fits maxTokens. With one, the hinted lines
+// are sent; when the file doesn't fit, the chunks holding them are sent
+// instead if they fit, so that whole functions are explained.
+func selectCode(lines []string, hint *lineRange, maxTokens int) (lineRange, error) {
+	whole := lineRange{Start: 1, End: len(lines)}
+	if hint == nil {
+		if tokens := estimateTokens(joinLines(lines, whole)); tokens > maxTokens {
+			var parts []string
+			for _, chunk := range chunkCode(lines, maxTokens) {
+				parts = append(parts, chunk.String())
+			}
+			return lineRange{}, usageErrorf("the file is about %d tokens, more than --budget %d; pick a part with --lines, such as: %s",
+				tokens, maxTokens, strings.Join(parts, ", "))
+		}
+		return whole, nil
+	}
+
+	if hint.End > len(lines) {
+		return lineRange{}, usageErrorf("--lines %s is past the end of the file, which has %d lines", hint, len(lines))
+	}
+	if estimateTokens(joinLines(lines, whole)) <= maxTokens {
+		return *hint, nil
+	}
+	expanded := lineRange{}
+	for _, chunk := range chunkCode(lines, maxTokens) {
+		if chunk.End >= hint.Start && chunk.Start <= hint.End {
+			if expanded.Start == 0 {
+				expanded.Start = chunk.Start
+			}
+			expanded.End = chunk.End
+		}
+	}
+	if estimateTokens(joinLines(lines, expanded)) <= maxTokens {
+		return expanded, nil
+	}
+	if tokens := estimateTokens(joinLines(lines, *hint)); tokens > maxTokens {
+		return lineRange{}, usageErrorf("lines %s are about %d tokens, more than --budget %d; pick fewer lines or raise --budget", hint, tokens, maxTokens)
+	}
+	return *hint, nil
+}
+
+// ExplainOutput is the JSON output of explain-code
+type ExplainOutput struct {
+	File     string `json:"file"`
+	Language string `json:"language,omitempty"`
+	Lines    string `json:"lines"`
+	PromptOutput
+}
+
+// explainCodeCommand asks the model to explain a source file, or some of
+// its lines, sent in a code block tagged with the file's language
+func explainCodeCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli explain-code [--lines 40-80] [--question text] [--budget N] [--model name] [--no-stream] [--usage] [--raw] [--dry-run] <file>"
+
+	fs := flag.NewFlagSet("explain-code", flag.ContinueOnError)
+	lines := fs.String("lines", "", "explain only these lines, such as 40-80")
+	question := fs.String("question", "", "a question about the code, answered first")
+	budget := fs.Int("budget", defaultExplainBudget, "estimated tokens of code to send at most")
+	model := fs.String("model", "", "model to use instead of OPENAI_MODEL, with the values set for it in the config file")
+	noStream := fs.Bool("no-stream", false, "wait for the full response instead of streaming it")
+	showUsage := fs.Bool("usage", config.ShowUsage, "print token usage and estimated cost")
+	raw := fs.Bool("raw", false, "print the response as-is instead of rendering markdown")
+	dryRun := fs.Bool("dry-run", false, "print the request instead of sending it")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) != 1 {
+		return usageErrorf("one file is required\n%s", usage)
+	}
+	if *budget < 1 {
+		return usageErrorf("--budget must be a positive integer")
+	}
+	var hint *lineRange
+	if *lines != "" {
+		r, err := parseLineRange(*lines)
+		if err != nil {
+			return usageErrorf("%w", err)
+		}
+		hint = &r
+	}
+	if *model != "" {
+		useModel(config, *model)
+	}
+
+	path := args[0]
+	file, err := readAttachment(path, config.MaxFileSize)
+	if err != nil {
+		return fmt.Errorf("failed to read code: %w", err)
+	}
+	if strings.TrimSpace(file.Content) == "" {
+		return fmt.Errorf("%s is empty", path)
+	}
+	fileLines := strings.Split(strings.TrimSuffix(file.Content, "\n"), "\n")
+	selected, err := selectCode(fileLines, hint, *budget)
+	if err != nil {
+		return err
+	}
+
+	code := joinLines(fileLines, selected)
+	language, known := detectLanguage(path)
+	fence := codeFence(code)
+	var prompt strings.Builder
+	prompt.WriteString("Explain this code")
+	if known {
+		fmt.Fprintf(&prompt, ", written in %s", language.Name)
+	}
+	if hint != nil && selected != *hint {
+		fmt.Fprintf(&prompt, ", focusing on lines %s", hint)
+	}
+	fmt.Fprintf(&prompt, ".\n\n%s%s %s (lines %s)\n%s%s", fence, language.Tag, path, selected, code, fence)
+	if strings.TrimSpace(*question) != "" {
+		fmt.Fprintf(&prompt, "\n\nQuestion: %s", strings.TrimSpace(*question))
+	}
+
+	config.SystemPrompt = explainInstructions
+	if strings.TrimSpace(*question) != "" {
+		config.SystemPrompt += explainQuestionInstructions
+	}
+	loggedPrompt := fmt.Sprintf("%s lines %s", path, selected)
+	if *question != "" {
+		loggedPrompt += ": " + strings.TrimSpace(*question)
+	}
+	if config.LogFullPrompt {
+		loggedPrompt = prompt.String()
+	}
+
+	stream := config.Stream && !*noStream && config.Output != outputJSON
+	if *dryRun {
+		req, err := newChatRequest(config.requestContext(), config, prompt.String(), stream)
+		if err != nil {
+			return err
+		}
+		return printDryRun(config, req)
+	}
+
+	return sendAndPrint(config, chatPrompt{
+		Command:   "explain-code",
+		Text:      prompt.String(),
+		Logged:    loggedPrompt,
+		Stream:    stream,
+		Raw:       *raw,
+		ShowUsage: *showUsage,
+		JSON: func(output PromptOutput) any {
+			return ExplainOutput{File: path, Language: language.Tag, Lines: selected.String(), PromptOutput: output}
+		},
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDetectLanguage tests the language found from file names
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"main.go", "go"},
+		{"src/App.TSX", "tsx"},
+		{"lib/util.hpp", "cpp"},
+		{"deploy/Dockerfile", "dockerfile"},
+		{"scripts/build.sh", "bash"},
+		{"notes.txt", ""},
+		{"LICENSE", ""},
+	}
+	for _, tt := range tests {
+		language, ok := detectLanguage(tt.path)
+		if language.Tag != tt.want || ok != (tt.want != "") {
+			t.Errorf("detectLanguage(%q) = %q, %v, want %q", tt.path, language.Tag, ok, tt.want)
+		}
+	}
+	if len(codeLanguages) < 30 {
+		t.Errorf("codeLanguages has %d extensions, want at least 30", len(codeLanguages))
+	}
+}
+
+// TestParseLineRange tests the values of --lines
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    lineRange
+		wantErr bool
+	}{
+		{value: "40-80", want: lineRange{40, 80}},
+		{value: "12", want: lineRange{12, 12}},
+		{value: " 3 - 5 ", want: lineRange{3, 5}},
+		{value: "80-40", wantErr: true},
+		{value: "0-3", wantErr: true},
+		{value: "a-b", wantErr: true},
+		{value: "10-", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLineRange(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseLineRange(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+// TestCodeBlocks tests splitting code at function boundaries
+func TestCodeBlocks(t *testing.T) {
+	code := `package main
+
+// one does one thing
+func one() {
+	if true {
+	}
+}
+func two() {
+
+	return
+}
+
+func three() {}`
+	want := []lineRange{{1, 2}, {3, 7}, {8, 12}, {13, 13}}
+	got := codeBlocks(strings.Split(code, "\n"))
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("codeBlocks() = %v, want %v", got, want)
+	}
+}
+
+// TestSelectCode tests the lines sent for a file and a --lines hint within
+// the budget
+func TestSelectCode(t *testing.T) {
+	// Ten functions of five lines, each about 16 tokens
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprintf("func f%d() {", i), "\tdoSomething()", "\treturn", "}", "")
+	}
+	lines = lines[:len(lines)-1]
+
+	hint := func(start, end int) *lineRange { return &lineRange{start, end} }
+	tests := []struct {
+		name    string
+		hint    *lineRange
+		budget  int
+		want    lineRange
+		wantErr string
+	}{
+		{name: "whole file", budget: 1000, want: lineRange{1, 49}},
+		{name: "file too large", budget: 40, wantErr: "pick a part with --lines, such as: 1-10, 11-20, 21-30, 31-40, 41-49"},
+		{name: "lines of a small file", hint: hint(7, 8), budget: 1000, want: lineRange{7, 8}},
+		{name: "chunk holding the lines", hint: hint(14, 17), budget: 40, want: lineRange{11, 20}},
+		{name: "lines across chunks", hint: hint(9, 12), budget: 40, want: lineRange{9, 12}},
+		{name: "past the end", hint: hint(40, 60), budget: 40, wantErr: "past the end"},
+		{name: "lines too large", hint: hint(1, 49), budget: 40, wantErr: "more than --budget 40"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectCode(lines, tt.hint, tt.budget)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectCode() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("selectCode() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestExplainCodeCommand tests that the lines are sent in a block tagged
+// with the language, with the question, and that only their place is logged
+func TestExplainCodeCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var sent ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"The channel is never closed."}}]}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "worker.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc work(c chan int) {\n\tfor range c {\n\t}\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: dir, NoColor: true}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := explainCodeCommand(config, []string{"--no-stream", "--lines", "3-6", "--question", "why does it hang?", path}); err != nil {
+			t.Errorf("explainCodeCommand() error = %v", err)
+		}
+	})
+	if out != "The channel is never closed.\n" {
+		t.Errorf("output = %q", out)
+	}
+
+	if len(sent.Messages) != 2 || !strings.HasPrefix(sent.Messages[0].Content, explainInstructions) || !strings.HasSuffix(sent.Messages[0].Content, explainQuestionInstructions) {
+		t.Fatalf("messages = %+v, want the instructions for a question", sent.Messages)
+	}
+	want := "Explain this code, written in Go.\n\n```go " + path + " (lines 3-6)\nfunc work(c chan int) {\n\tfor range c {\n\t}\n}\n```\n\nQuestion: why does it hang?"
+	if sent.Messages[1].Content != want {
+		t.Errorf("prompt = %q, want %q", sent.Messages[1].Content, want)
+	}
+
+	entries := readTestLogEntries(t, dir)
+	if len(entries) != 1 || entries[0].Command != "explain-code" || entries[0].Prompt != path+" lines 3-6: why does it hang?" {
+		t.Errorf("log entries = %+v", entries)
+	}
+
+	config.Output = outputJSON
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := explainCodeCommand(config, []string{path}); err != nil {
+			t.Errorf("explainCodeCommand() error = %v", err)
+		}
+	})
+	var result ExplainOutput
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("JSON output = %q: %v", out, err)
+	}
+	if result.File != path || result.Language != "go" || result.Lines != "1-6" || result.Content != "The channel is never closed." {
+		t.Errorf("JSON output = %+v", result)
+	}
+}
+
+// TestExplainCodeCommandUsage tests the arguments rejected before anything
+// is sent
+func TestExplainCodeCommandUsage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.py")
+	if err := os.WriteFile(path, []byte("print('hi')\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{APIKey: "test-key", ConfigDir: dir}
+	for _, args := range [][]string{
+		nil,
+		{path, path},
+		{"--lines", "5-1", path},
+		{"--lines", "2-3", path},
+		{"--budget", "0", path},
+	} {
+		if err := explainCodeCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("explainCodeCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
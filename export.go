@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Formats supported by logs export
+const (
+	exportCSV      = "csv"
+	exportMarkdown = "md"
+	exportJSON     = "json"
+)
+
+// Layouts of markdown exports
+const (
+	markdownTable    = "table"
+	markdownSections = "sections"
+)
+
+var exportFormats = []string{exportCSV, exportMarkdown, exportJSON}
+
+// Columns of CSV and markdown table exports
+var exportColumns = []string{"timestamp", "command", "prompt", "response", "error"}
+
+// logExporter converts log entries one at a time, so that exports never hold
+// the whole log in memory
+type logExporter interface {
+	begin() error
+	write(entry LogEntry) error
+	end() error
+}
+
+// logsExportCommand writes the log entries matching the filters in another format
+func logsExportCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli logs export [--format csv|md|json] [--md-style table|sections] [--out file] [--since 24h] [--command name] [--errors-only]"
+
+	fs := flag.NewFlagSet("logs export", flag.ContinueOnError)
+	format := fs.String("format", exportCSV, "output format: csv, md or json")
+	mdStyle := fs.String("md-style", markdownTable, "markdown layout: table or sections")
+	outFile := fs.String("out", "", "write the export to this file instead of stdout")
+	filterFlags := addLogFilterFlags(fs)
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\n%s", args[0], usage)
+	}
+
+	filter, err := filterFlags.filter()
+	if err != nil {
+		return err
+	}
+
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	exporter, err := newLogExporter(w, *format, *mdStyle)
+	if err != nil {
+		return err
+	}
+
+	count, err := exportLogs(logFiles, filter, exporter)
+	if err != nil {
+		return fmt.Errorf("failed to export logs: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to export logs: %w", err)
+	}
+
+	if *outFile != "" {
+		fmt.Printf("Exported %d log entries to %s\n", count, *outFile)
+	}
+	return nil
+}
+
+// newLogExporter returns the exporter for a format and markdown layout
+func newLogExporter(w io.Writer, format, mdStyle string) (logExporter, error) {
+	switch format {
+	case exportCSV:
+		return &csvExporter{w: csv.NewWriter(w)}, nil
+	case exportJSON:
+		return &jsonExporter{w: w}, nil
+	case exportMarkdown:
+		switch mdStyle {
+		case markdownTable:
+			return &markdownTableExporter{w: w}, nil
+		case markdownSections:
+			return &markdownSectionsExporter{w: w}, nil
+		}
+		return nil, usageErrorf("invalid markdown style: %s\nValid styles: %s, %s", mdStyle, markdownTable, markdownSections)
+	}
+	return nil, usageErrorf("invalid export format: %s\nValid formats: %s", format, strings.Join(exportFormats, ", "))
+}
+
+// exportLogs passes the entries matching filter to the exporter and returns
+// how many were exported
+func exportLogs(logFiles []string, filter logFilter, exporter logExporter) (int, error) {
+	if err := exporter.begin(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	_, err := forEachLogEntry(logFiles, func(entry LogEntry) error {
+		if !filter.matches(entry) {
+			return nil
+		}
+		count++
+		return exporter.write(entry)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, exporter.end()
+}
+
+// exportRow returns the values of the export columns for an entry
+func exportRow(entry LogEntry) []string {
+	return []string{
+		entry.Timestamp.Format(time.RFC3339),
+		entry.Command,
+		entry.Prompt,
+		entry.Response,
+		entry.Error,
+	}
+}
+
+// csvExporter writes a header row followed by one row per entry
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) begin() error {
+	return e.w.Write(exportColumns)
+}
+
+func (e *csvExporter) write(entry LogEntry) error {
+	return e.w.Write(exportRow(entry))
+}
+
+func (e *csvExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// jsonExporter writes a pretty-printed array of entries
+type jsonExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonExporter) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonExporter) write(entry LogEntry) error {
+	data, err := json.MarshalIndent(entry, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	separator := "\n  "
+	if e.count > 0 {
+		separator = ",\n  "
+	}
+	e.count++
+
+	_, err = fmt.Fprintf(e.w, "%s%s", separator, data)
+	return err
+}
+
+func (e *jsonExporter) end() error {
+	if e.count > 0 {
+		_, err := io.WriteString(e.w, "\n]\n")
+		return err
+	}
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// markdownTableExporter writes a table with one row per entry
+type markdownTableExporter struct {
+	w io.Writer
+}
+
+func (e *markdownTableExporter) begin() error {
+	_, err := fmt.Fprintf(e.w, "| %s |\n|%s\n",
+		strings.Join(exportColumns, " | "), strings.Repeat("---|", len(exportColumns)))
+	return err
+}
+
+func (e *markdownTableExporter) write(entry LogEntry) error {
+	row := exportRow(entry)
+	for i, value := range row {
+		row[i] = markdownCell(value)
+	}
+	_, err := fmt.Fprintf(e.w, "| %s |\n", strings.Join(row, " | "))
+	return err
+}
+
+func (e *markdownTableExporter) end() error {
+	return nil
+}
+
+// markdownCell escapes a value for a table cell, which must fit on one line
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// markdownSectionsExporter writes a section per entry with the prompt quoted
+// and the response in a fenced code block
+type markdownSectionsExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *markdownSectionsExporter) begin() error {
+	return nil
+}
+
+func (e *markdownSectionsExporter) write(entry LogEntry) error {
+	e.count++
+
+	var b strings.Builder
+	if e.count > 1 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "## %d. %s - %s\n", e.count, entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.Command)
+
+	if entry.Prompt != "" {
+		b.WriteString("\n")
+		for _, line := range strings.Split(strings.TrimSuffix(entry.Prompt, "\n"), "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+	}
+	if entry.Response != "" {
+		fence := codeFence(entry.Response)
+		fmt.Fprintf(&b, "\n%s\n%s\n%s\n", fence, strings.TrimSuffix(entry.Response, "\n"), fence)
+	}
+	if entry.Error != "" {
+		fmt.Fprintf(&b, "\n**Error:** %s\n", entry.Error)
+	}
+
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+func (e *markdownSectionsExporter) end() error {
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testExportEntries returns log entries with values that need escaping
+func testExportEntries() []LogEntry {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return []LogEntry{
+		{Timestamp: now, Command: "prompt", Prompt: "hello, \"world\"", Response: "line one\nline | two"},
+		{Timestamp: now.Add(time.Minute), Command: "batch", Prompt: "fails", Error: "API error"},
+		{Timestamp: now.Add(2 * time.Minute), Command: "prompt", Prompt: "code", Response: "```go\nfmt.Println()\n```"},
+	}
+}
+
+// exportTestEntries runs entries through the exporter for a format and style
+func exportTestEntries(t *testing.T, format, mdStyle string, entries []LogEntry) string {
+	t.Helper()
+
+	logFile := filepath.Join(t.TempDir(), "logs.jsonl")
+	writeTestLogs(t, logFile, entries)
+
+	var buf bytes.Buffer
+	exporter, err := newLogExporter(&buf, format, mdStyle)
+	if err != nil {
+		t.Fatalf("newLogExporter() error = %v", err)
+	}
+	count, err := exportLogs([]string{logFile}, logFilter{}, exporter)
+	if err != nil {
+		t.Fatalf("exportLogs() error = %v", err)
+	}
+	if count != len(entries) {
+		t.Errorf("exportLogs() count = %d, want %d", count, len(entries))
+	}
+	return buf.String()
+}
+
+// TestExportCSV tests that CSV exports round-trip through a CSV reader
+func TestExportCSV(t *testing.T) {
+	entries := testExportEntries()
+	out := exportTestEntries(t, exportCSV, "", entries)
+
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != len(entries)+1 {
+		t.Fatalf("expected %d records, got %d", len(entries)+1, len(records))
+	}
+	if strings.Join(records[0], ",") != "timestamp,command,prompt,response,error" {
+		t.Errorf("header = %v", records[0])
+	}
+	for i, entry := range entries {
+		want := exportRow(entry)
+		if strings.Join(records[i+1], "\x00") != strings.Join(want, "\x00") {
+			t.Errorf("record %d = %q, want %q", i, records[i+1], want)
+		}
+	}
+}
+
+// TestExportJSON tests that JSON exports parse back into the same entries
+func TestExportJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []LogEntry
+	}{
+		{name: "entries", entries: testExportEntries()},
+		{name: "empty", entries: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := exportTestEntries(t, exportJSON, "", tt.entries)
+
+			var got []LogEntry
+			if err := json.Unmarshal([]byte(out), &got); err != nil {
+				t.Fatalf("failed to parse JSON: %v\n%s", err, out)
+			}
+			if len(got) != len(tt.entries) {
+				t.Fatalf("expected %d entries, got %d", len(tt.entries), len(got))
+			}
+			for i := range got {
+				if got[i].Prompt != tt.entries[i].Prompt || got[i].Response != tt.entries[i].Response {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.entries[i])
+				}
+			}
+		})
+	}
+}
+
+// TestExportMarkdown tests both markdown layouts
+func TestExportMarkdown(t *testing.T) {
+	entries := testExportEntries()
+
+	table := exportTestEntries(t, exportMarkdown, markdownTable, entries)
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	if len(lines) != len(entries)+2 {
+		t.Fatalf("expected %d table lines, got %d:\n%s", len(entries)+2, len(lines), table)
+	}
+	if !strings.Contains(lines[2], `line one<br>line \| two`) {
+		t.Errorf("row = %q, want escaped newline and pipe", lines[2])
+	}
+
+	sections := exportTestEntries(t, exportMarkdown, markdownSections, entries)
+	for _, s := range []string{
+		// Shown in the local time zone
+		"## 1. " + entries[0].Timestamp.Local().Format("2006-01-02 15:04:05") + " - prompt",
+		"> hello, \"world\"",
+		"```\nline one\nline | two\n```",
+		"**Error:** API error",
+		"````\n```go\nfmt.Println()\n```\n````",
+	} {
+		if !strings.Contains(sections, s) {
+			t.Errorf("sections output missing %q:\n%s", s, sections)
+		}
+	}
+}
+
+// TestNewLogExporterInvalid tests rejection of unknown formats and styles
+func TestNewLogExporterInvalid(t *testing.T) {
+	if _, err := newLogExporter(&bytes.Buffer{}, "xml", markdownTable); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := newLogExporter(&bytes.Buffer{}, exportMarkdown, "list"); err == nil {
+		t.Error("expected error for unknown markdown style")
+	}
+}
+
+// TestLogsExportCommand tests filtering and writing to a file
+func TestLogsExportCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestLogs(t, filepath.Join(tmpDir, "logs.jsonl"), testExportEntries())
+
+	config := &Config{ConfigDir: tmpDir}
+	outFile := filepath.Join(tmpDir, "history.csv")
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"export", "--command", "batch", "--out", outFile}); err != nil {
+			t.Fatalf("logs export error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Exported 1 log entries to "+outFile) {
+		t.Errorf("output = %q", out)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(records) != 2 || records[1][1] != "batch" {
+		t.Errorf("records = %v, want header and the batch entry", records)
+	}
+}
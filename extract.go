@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// postProcessor turns a reply into the part of it prompt --extract prints
+type postProcessor func(content string) (string, error)
+
+// postProcessorKinds builds the post-processors --extract can name, as
+// kind[:argument]. raw keeps the extracted text as the model wrote it.
+var postProcessorKinds = map[string]func(arg string, raw bool) (postProcessor, error){
+	"code": newCodeExtractor,
+	"json": newJSONExtractor,
+}
+
+// extractListFlag is a repeatable flag collecting the --extract
+// post-processors, applied in order
+type extractListFlag []string
+
+func (f *extractListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *extractListFlag) Set(value string) error {
+	if _, err := newPostProcessor(value, false); err != nil {
+		return err
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// postProcessors builds the flag's post-processors
+func (f extractListFlag) postProcessors(raw bool) []postProcessor {
+	processors := make([]postProcessor, 0, len(f))
+	for _, spec := range f {
+		// Set already checked every spec
+		processor, _ := newPostProcessor(spec, raw)
+		processors = append(processors, processor)
+	}
+	return processors
+}
+
+// newPostProcessor builds the post-processor named by an --extract value
+func newPostProcessor(spec string, raw bool) (postProcessor, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	build, ok := postProcessorKinds[strings.ToLower(kind)]
+	if !ok {
+		kinds := make([]string, 0, len(postProcessorKinds))
+		for name := range postProcessorKinds {
+			kinds = append(kinds, name)
+		}
+		sort.Strings(kinds)
+		return nil, fmt.Errorf("unknown extract %q: use %s", kind, strings.Join(kinds, " or "))
+	}
+	return build(arg, raw)
+}
+
+// runPostProcessors applies the post-processors to a reply in order
+func runPostProcessors(content string, processors []postProcessor) (string, error) {
+	for _, process := range processors {
+		var err error
+		if content, err = process(content); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
+}
+
+// newCodeExtractor keeps the contents of the fenced code blocks: all of them
+// one after another, the first with "first", or those tagged with a language
+func newCodeExtractor(arg string, raw bool) (postProcessor, error) {
+	if strings.Contains(arg, ":") || strings.ContainsAny(arg, " \t") {
+		return nil, fmt.Errorf("invalid extract code:%s: use code, code:first or code:<language>", arg)
+	}
+
+	return func(content string) (string, error) {
+		var contents []string
+		for _, block := range findCodeBlocks(content) {
+			if arg == "" || arg == "first" || strings.EqualFold(block.lang, arg) {
+				contents = append(contents, block.content)
+			}
+		}
+		if len(contents) == 0 {
+			if arg == "" || arg == "first" {
+				return "", fmt.Errorf("no code block in the response")
+			}
+			return "", fmt.Errorf("no %s code block in the response", arg)
+		}
+		if arg == "first" {
+			contents = contents[:1]
+		}
+		return strings.Join(contents, "\n"), nil
+	}, nil
+}
+
+// newJSONExtractor keeps the first JSON object or array in the reply,
+// pretty-printed unless raw
+func newJSONExtractor(arg string, raw bool) (postProcessor, error) {
+	if arg != "" {
+		return nil, fmt.Errorf("invalid extract json:%s: json takes no argument", arg)
+	}
+
+	return func(content string) (string, error) {
+		value, ok := findJSON(content)
+		if !ok {
+			return "", fmt.Errorf("no JSON object or array in the response")
+		}
+		if raw {
+			return value, nil
+		}
+		var b bytes.Buffer
+		if err := json.Indent(&b, []byte(value), "", "  "); err != nil {
+			return "", fmt.Errorf("failed to format JSON: %w", err)
+		}
+		return b.String(), nil
+	}, nil
+}
+
+// codeBlock is a fenced code block of a markdown reply
+type codeBlock struct {
+	// First word of the fence's info string, such as go in ```go
+	lang    string
+	content string
+}
+
+// findCodeBlocks returns the fenced code blocks of a markdown text. A block
+// is closed by a fence of the same character at least as long as the one
+// that opened it, so ```` can hold ``` lines; one left open runs to the end
+// of the text, as in a reply cut short by the token limit.
+func findCodeBlocks(text string) []codeBlock {
+	var blocks []codeBlock
+	var fence, indent, lang string
+	var lines []string
+
+	closeBlock := func() {
+		blocks = append(blocks, codeBlock{lang: lang, content: strings.TrimRight(strings.Join(lines, "\n"), "\n")})
+		fence, lines = "", nil
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			open := fencePrefix(trimmed)
+			info := strings.TrimSpace(strings.TrimPrefix(trimmed, open))
+			// ```code``` on one line is inline code, not a fence
+			if open == "" || open[0] == '`' && strings.Contains(info, "`") {
+				continue
+			}
+			fence, indent = open, line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			lang = ""
+			if fields := strings.Fields(info); len(fields) > 0 {
+				lang = fields[0]
+			}
+			continue
+		}
+
+		if len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			closeBlock()
+			continue
+		}
+		// Lines of a block indented in a list lose the fence's indentation
+		lines = append(lines, strings.TrimPrefix(line, indent))
+	}
+	if fence != "" {
+		closeBlock()
+	}
+	return blocks
+}
+
+// fencePrefix returns the run of three or more backticks or tildes a line
+// starts with, or "" if it doesn't start a fence
+func fencePrefix(line string) string {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// findJSON returns the first JSON object or array in a text, as written.
+// Brackets that don't start valid JSON, such as [note] in prose, are skipped.
+func findJSON(text string) (string, bool) {
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+		var value json.RawMessage
+		if err := json.NewDecoder(strings.NewReader(text[i:])).Decode(&value); err == nil {
+			return string(value), true
+		}
+	}
+	return "", false
+}
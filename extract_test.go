@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// testCodeReply has prose around a Go block, a bash block and a block whose
+// ```` fence holds a ``` line
+const testCodeReply = "Here is the function:\n\n```go\nfunc add(a, b int) int {\n\treturn a + b\n}\n```\n\nRun it with:\n\n```bash\ngo run .\n```\n\nA README example:\n\n````markdown\n```go\nadd(1, 2)\n```\n````"
+
+// TestFindCodeBlocks tests fence parsing
+func TestFindCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []codeBlock
+	}{
+		{
+			name: "blocks with languages",
+			text: testCodeReply,
+			want: []codeBlock{
+				{lang: "go", content: "func add(a, b int) int {\n\treturn a + b\n}"},
+				{lang: "bash", content: "go run ."},
+				{lang: "markdown", content: "```go\nadd(1, 2)\n```"},
+			},
+		},
+		{
+			name: "unterminated fence runs to the end",
+			text: "Start:\n```python\nprint(1)\nprint(2)\n",
+			want: []codeBlock{{lang: "python", content: "print(1)\nprint(2)"}},
+		},
+		{
+			name: "tildes and info string",
+			text: "~~~js title=\"a.js\"\nlet a = 1\n```\nstill code\n~~~\n",
+			want: []codeBlock{{lang: "js", content: "let a = 1\n```\nstill code"}},
+		},
+		{
+			name: "indented in a list",
+			text: "1. Install:\n   ```\n   make\n     indented\n   ```\n",
+			want: []codeBlock{{lang: "", content: "make\n  indented"}},
+		},
+		{
+			name: "inline triple backticks are not a fence",
+			text: "Use ```x``` for inline code.\r\n```\r\ncode\r\n```\r\n",
+			want: []codeBlock{{lang: "", content: "code"}},
+		},
+		{
+			name: "no blocks",
+			text: "Just prose with `inline` code.",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findCodeBlocks(tt.text)
+			if len(got) != len(tt.want) {
+				t.Fatalf("findCodeBlocks() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("block %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestPostProcessors tests each --extract value on replies with and without
+// a matching part
+func TestPostProcessors(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		raw     bool
+		reply   string
+		want    string
+		wantErr string
+	}{
+		{"all code", []string{"code"}, false, testCodeReply, "func add(a, b int) int {\n\treturn a + b\n}\ngo run .\n```go\nadd(1, 2)\n```", ""},
+		{"first code", []string{"code:first"}, false, testCodeReply, "func add(a, b int) int {\n\treturn a + b\n}", ""},
+		{"language", []string{"code:BASH"}, false, testCodeReply, "go run .", ""},
+		{"missing language", []string{"code:rust"}, false, testCodeReply, "", "no rust code block in the response"},
+		{"no code", []string{"code"}, false, "No code here.", "", "no code block in the response"},
+		{"json object", []string{"json"}, false, "Sure [as asked]: {\"a\": [1, 2]} done", "{\n  \"a\": [\n    1,\n    2\n  ]\n}", ""},
+		{"raw json array", []string{"json"}, true, "Result: [1,2] and {\"b\":1}", "[1,2]", ""},
+		{"invalid json", []string{"json"}, false, "Almost: {\"a\": 1", "", "no JSON object or array in the response"},
+		{"pipeline", []string{"code:json", "json"}, true, "```json\n{\"ok\":true}\n```\n```go\nx := 1\n```", "{\"ok\":true}", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var specs extractListFlag
+			for _, spec := range tt.specs {
+				if err := specs.Set(spec); err != nil {
+					t.Fatalf("Set(%q) error = %v", spec, err)
+				}
+			}
+			got, err := runPostProcessors(tt.reply, specs.postProcessors(tt.raw))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("runPostProcessors() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("runPostProcessors() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	for _, spec := range []string{"xml", "json:pretty", "code:go:first"} {
+		var specs extractListFlag
+		if err := specs.Set(spec); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// TestPromptCommandExtract tests that prompt prints and logs an extract, and
+// fails with the whole reply on stderr when nothing matches
+func TestPromptCommandExtract(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if request.Stream {
+			t.Errorf("request.Stream = true, want false")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ChatResponse{Choices: []Choice{{Message: Message{Content: testCodeReply}}}})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, Stream: true, ConfigDir: tmpDir}
+
+	var err error
+	stdout := captureOutput(t, &os.Stdout, func() {
+		err = promptCommand(config, []string{"--extract", "code:go", "write add"})
+	})
+	if err != nil || stdout != "func add(a, b int) int {\n\treturn a + b\n}\n" {
+		t.Errorf("promptCommand(--extract code:go) = %q, %v", stdout, err)
+	}
+
+	var stderr string
+	stdout = captureOutput(t, &os.Stdout, func() {
+		stderr = captureOutput(t, &os.Stderr, func() {
+			err = promptCommand(config, []string{"--extract", "json", "write add"})
+		})
+	})
+	if err == nil || err.Error() != "no JSON object or array in the response" {
+		t.Errorf("promptCommand(--extract json) error = %v", err)
+	}
+	if stdout != "" || stderr != testCodeReply+"\n" {
+		t.Errorf("stdout = %q, stderr = %q; want the reply on stderr only", stdout, stderr)
+	}
+
+	entries := readTestLogEntries(t, tmpDir)
+	if len(entries) != 2 || entries[0].Response != testCodeReply || entries[1].Error == "" {
+		t.Errorf("log entries = %+v, want the whole replies and the error", entries)
+	}
+
+	if err := promptCommand(config, []string{"--extract", "code", "--json-response", "hi"}); exitCode(err) != exitUsage {
+		t.Errorf("--extract with --json-response error = %v, want a usage error", err)
+	}
+	if err := promptCommand(config, []string{"--extract", "yaml", "hi"}); exitCode(err) != exitUsage {
+		t.Errorf("--extract yaml error = %v, want a usage error", err)
+	}
+}
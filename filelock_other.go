@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+
+package main
+
+import "os"
+
+// tryLockFile always succeeds where no file locking is supported, so that
+// only the goroutines of one process are kept from writing at the same time
+func tryLockFile(f *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlockFile has nothing to release where no file locking is supported
+func unlockFile(f *os.File) error {
+	return nil
+}
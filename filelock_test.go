@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLockFile tests that a held lock makes others wait, then time out
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	unlock, err := lockFile(path, time.Second)
+	if err != nil {
+		t.Fatalf("lockFile() error = %v", err)
+	}
+
+	_, err = lockFile(path, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 50ms waiting for "+path) {
+		t.Errorf("lockFile() of a held lock error = %v, want a timeout", err)
+	}
+
+	// The lock is taken as soon as it is released
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlock()
+	}()
+	unlockAgain, err := lockFile(path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("lockFile() after release error = %v", err)
+	}
+	unlockAgain()
+}
+
+// TestConfigLockTimeout tests that config set fails with a clear error while
+// another process holds the config lock
+func TestConfigLockTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	unlock, err := lockFile(filepath.Join(tmpDir, configLockFileName), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	timeout := configLockTimeout
+	configLockTimeout = 50 * time.Millisecond
+	defer func() { configLockTimeout = timeout }()
+
+	config := &Config{ConfigDir: tmpDir}
+	err = configSetCommand(config, []string{"OPENAI_MODEL", "gpt-4"})
+	if err == nil || !strings.Contains(err.Error(), "failed to write the configuration: timed out") {
+		t.Errorf("configSetCommand() error = %v, want a lock timeout", err)
+	}
+}
+
+// TestWriteFileAtomic tests that the file is replaced with its permissions
+// and no temporary file is left
+func TestWriteFileAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.toml")
+	for _, contents := range []string{"first\n", "second\n"} {
+		if err := writeFileAtomic(path, []byte(contents), 0600); err != nil {
+			t.Fatalf("writeFileAtomic() error = %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != contents {
+			t.Errorf("file = %q, %v; want %q", data, err, contents)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("permissions = %o, want 600", perm)
+	}
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("directory has %d entries, %v; want only the file", len(entries), err)
+	}
+}
+
+// TestConcurrentConfigSet fires many config sets at once, for different keys
+// and profiles, and checks that none of them is lost
+func TestConcurrentConfigSet(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	values := map[string]string{
+		"OPENAI_MODEL":             "gpt-4o",
+		"OPENAI_MAX_TOKENS":        "1234",
+		"OPENAI_TEMPERATURE":       "0.3",
+		"OPENAI_TOP_P":             "0.9",
+		"OPENAI_STREAM":            "false",
+		"OPENAI_ORG_ID":            "org-abc",
+		"CHATGPT_CLI_OUTPUT":       "json",
+		"CHATGPT_CLI_LOG_MAX_SIZE": "2MB",
+	}
+	profiles := []string{"", "work", "home", "ci", "staging", "prod"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(values)*len(profiles))
+	captureOutput(t, &os.Stdout, func() {
+		for _, profile := range profiles {
+			for key, value := range values {
+				wg.Add(1)
+				go func(profile, key, value string) {
+					defer wg.Done()
+					config := &Config{ConfigDir: tmpDir, Profile: profile}
+					if err := configSetCommand(config, []string{key, value}); err != nil {
+						errs <- fmt.Errorf("config set %s in profile %q: %w", key, profile, err)
+					}
+				}(profile, key, value)
+			}
+		}
+		wg.Wait()
+	})
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	sections, err := loadConfigSections(tmpDir)
+	if err != nil {
+		t.Fatalf("loadConfigSections() error = %v", err)
+	}
+	for _, profile := range profiles {
+		for key, value := range values {
+			if got := sections[profile][key]; got != value {
+				t.Errorf("profile %q %s = %q, want %q", profile, key, got, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Names of the values OPENAI_RESPONSE_JSONPATH can point to
+const (
+	responsePathText             = "text"
+	responsePathPromptTokens     = "prompt_tokens"
+	responsePathCompletionTokens = "completion_tokens"
+	responsePathTotalTokens      = "total_tokens"
+)
+
+// responsePaths are the dotted paths of the values read from a gateway's
+// reply, set by OPENAI_RESPONSE_JSONPATH. Those it does not set keep the
+// paths of a chat completion.
+type responsePaths struct {
+	Text             string
+	PromptTokens     string
+	CompletionTokens string
+	TotalTokens      string
+}
+
+// defaultResponsePaths are where a chat completion holds the values
+var defaultResponsePaths = responsePaths{
+	Text:             "choices.0.message.content",
+	PromptTokens:     "usage.prompt_tokens",
+	CompletionTokens: "usage.completion_tokens",
+	TotalTokens:      "usage.total_tokens",
+}
+
+// usesGateway reports whether requests go through a gateway that is not
+// OpenAI compatible, with a request template or response paths
+func usesGateway(config *Config) bool {
+	return config.RequestTemplate != "" || config.ResponsePaths != nil
+}
+
+// gatewayProvider speaks to a gateway in front of the OpenAI API that wants
+// its own request and reply shapes. The chat completion payload is rendered
+// by the OPENAI_REQUEST_TEMPLATE file, and the reply's text and token counts
+// are read at the paths of OPENAI_RESPONSE_JSONPATH. Replies are streamed
+// only with a template alone, as the shape of streamed chunks is not known
+// otherwise.
+type gatewayProvider struct {
+	openAIProvider
+	config *Config
+}
+
+func (p gatewayProvider) NewRequest(ctx context.Context, config *Config, messages []Message, stream bool) (*http.Request, error) {
+	stream = stream && config.ResponsePaths == nil
+	body := openAIRequestBody(config, messages, stream)
+
+	var jsonData []byte
+	var err error
+	if config.RequestTemplate != "" {
+		jsonData, err = renderRequestTemplate(config.RequestTemplate, body)
+	} else {
+		jsonData, err = json.Marshal(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newOpenAIRequest(ctx, config, jsonData, stream)
+}
+
+func (p gatewayProvider) ReadResponse(resp *http.Response) (*ChatResponse, error) {
+	if p.config.ResponsePaths == nil {
+		return readChatResponse(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp.StatusCode, body)
+	}
+	return gatewayChatResponse(body, *p.config.ResponsePaths, requestModel(p.config))
+}
+
+// parseRequestTemplate reads and parses the template file at path. Parse
+// errors name the file and the line, as in "template: gateway.tmpl:3: ...".
+func parseRequestTemplate(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request template: %w", err)
+	}
+	tmpl, err := template.New(path).Option("missingkey=error").Funcs(template.FuncMap{
+		"json": templateJSON,
+	}).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid request template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// templateJSON is the json function of request templates, which writes a
+// value as JSON, such as {{json .Messages}}
+func templateJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// renderRequestTemplate renders the request body from the template file at
+// path, which receives the fields of the chat completion payload, and
+// checks that it is JSON
+func renderRequestTemplate(path string, request ChatRequest) ([]byte, error) {
+	tmpl, err := parseRequestTemplate(path)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, request); err != nil {
+		return nil, fmt.Errorf("failed to render request template: %w", err)
+	}
+	if problems, _ := verifyJSON(nil, body.String()); problems != "" {
+		return nil, fmt.Errorf("request template %s did not render JSON: %s", path, problems)
+	}
+	return body.Bytes(), nil
+}
+
+// gatewayChatResponse reads the text and token counts of a gateway's reply
+// at paths. The text must be there; token counts a gateway does not give are
+// left at zero, and the total is their sum when not given.
+func gatewayChatResponse(body []byte, paths responsePaths, model string) (*ChatResponse, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var reply interface{}
+	if err := decoder.Decode(&reply); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	value, ok := lookupJSONPath(reply, paths.Text)
+	if !ok {
+		return nil, fmt.Errorf("response has no text at %s (set by %s)", paths.Text, envResponseJSONPath)
+	}
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("response text at %s is not a string (set by %s)", paths.Text, envResponseJSONPath)
+	}
+
+	var usage Usage
+	for _, count := range []struct {
+		path  string
+		value *int
+	}{
+		{paths.PromptTokens, &usage.PromptTokens},
+		{paths.CompletionTokens, &usage.CompletionTokens},
+		{paths.TotalTokens, &usage.TotalTokens},
+	} {
+		value, ok := lookupJSONPath(reply, count.path)
+		if !ok {
+			continue
+		}
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("response token count at %s is not a number (set by %s)", count.path, envResponseJSONPath)
+		}
+		n, err := number.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("response token count at %s is not an integer (set by %s)", count.path, envResponseJSONPath)
+		}
+		*count.value = int(n)
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+
+	return &ChatResponse{
+		Object:  "chat.completion",
+		Model:   model,
+		Choices: []Choice{{Message: Message{Role: "assistant", Content: text}}},
+		Usage:   usage,
+	}, nil
+}
+
+// lookupJSONPath returns the value at a dotted path such as
+// output.choices.0.text, where numbers index arrays
+func lookupJSONPath(value interface{}, path string) (interface{}, bool) {
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, value != nil
+}
+
+// parseResponsePaths parses OPENAI_RESPONSE_JSONPATH, semicolon-separated
+// "name=path" pairs naming text, prompt_tokens, completion_tokens or
+// total_tokens. A value without "=" is the path of the text.
+func parseResponsePaths(value string) (*responsePaths, error) {
+	paths := defaultResponsePaths
+	if !strings.Contains(value, "=") {
+		value = responsePathText + "=" + value
+	}
+	found := false
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, path, _ := strings.Cut(pair, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !validJSONPath(path) {
+			return nil, fmt.Errorf("response path of %s must be dotted keys such as output.text: %q", name, path)
+		}
+		switch name {
+		case responsePathText:
+			paths.Text = path
+		case responsePathPromptTokens:
+			paths.PromptTokens = path
+		case responsePathCompletionTokens:
+			paths.CompletionTokens = path
+		case responsePathTotalTokens:
+			paths.TotalTokens = path
+		default:
+			return nil, fmt.Errorf("unknown response path %q: use %s, %s, %s or %s", name,
+				responsePathText, responsePathPromptTokens, responsePathCompletionTokens, responsePathTotalTokens)
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("response paths cannot be empty; remove them with config unset")
+	}
+	return &paths, nil
+}
+
+// validJSONPath reports whether path is dotted keys without empty ones
+func validJSONPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" || strings.ContainsAny(segment, " \t") {
+			return false
+		}
+	}
+	return true
+}
+
+// loadResponsePaths parses OPENAI_RESPONSE_JSONPATH as loaded, nil when it
+// is unset or invalid; config set rejects invalid values
+func loadResponsePaths(value string) *responsePaths {
+	if value == "" {
+		return nil
+	}
+	paths, err := parseResponsePaths(value)
+	if err != nil {
+		return nil
+	}
+	return paths
+}
+
+// checkResponsePaths checks OPENAI_RESPONSE_JSONPATH as loaded
+func checkResponsePaths(value string) error {
+	_, err := parseResponsePaths(value)
+	return err
+}
+
+// format returns the paths as config list shows them
+func (p *responsePaths) format() string {
+	if p == nil {
+		return "(not set)"
+	}
+	return fmt.Sprintf("%s=%s; %s=%s; %s=%s; %s=%s",
+		responsePathText, p.Text, responsePathPromptTokens, p.PromptTokens,
+		responsePathCompletionTokens, p.CompletionTokens, responsePathTotalTokens, p.TotalTokens)
+}
+
+// validateRequestTemplate checks OPENAI_REQUEST_TEMPLATE for config set: the
+// file must exist and parse
+func validateRequestTemplate(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("request template cannot be empty; remove it with config unset")
+	}
+	if _, err := parseRequestTemplate(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gatewayTemplate wraps the chat completion payload the way the example
+// gateway of the docs wants it
+const gatewayTemplate = `{
+  "input": {
+    "model": {{json .Model}},
+    "messages": {{json .Messages}},
+    "max_tokens": {{.MaxTokens}}
+  },
+  "metadata": {"client": "chatgpt-cli", "stream": {{.Stream}}}
+}
+`
+
+// TestPromptThroughGateway tests a prompt sent to a mock gateway wanting
+// {"input": ..., "metadata": ...} and replying in its own shape
+func TestPromptThroughGateway(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var request struct {
+		Input struct {
+			Model     string    `json:"model"`
+			Messages  []Message `json:"messages"`
+			MaxTokens int       `json:"max_tokens"`
+		} `json:"input"`
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("gateway received invalid JSON: %v", err)
+		}
+		if r.Header.Get("Accept") == "text/event-stream" {
+			t.Error("gateway was asked to stream a reply whose shape is not known")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"output": {"text": "Hello from the gateway"}, "meta": {"usage": {"in": 12, "out": 4}}}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	templatePath := filepath.Join(dir, "gateway.tmpl")
+	if err := os.WriteFile(templatePath, []byte(gatewayTemplate), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := parseResponsePaths("text=output.text; prompt_tokens=meta.usage.in; completion_tokens=meta.usage.out")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		APIKey: "sk-test", APIURL: server.URL, Model: "gpt-4o", MaxTokens: 100, Stream: true,
+		Timeout: 5 * time.Second, ConfigDir: dir,
+		RequestTemplate: templatePath, ResponsePaths: paths,
+	}
+	output := captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"Hi"}); err != nil {
+			t.Errorf("promptCommand() error = %v", err)
+		}
+	})
+
+	if strings.TrimSpace(output) != "Hello from the gateway" {
+		t.Errorf("output = %q, want the gateway's text", output)
+	}
+	if request.Input.Model != "gpt-4o" || request.Input.MaxTokens != 100 || len(request.Input.Messages) != 1 || request.Input.Messages[0].Content != "Hi" {
+		t.Errorf("gateway input = %+v", request.Input)
+	}
+	if request.Metadata["client"] != "chatgpt-cli" || request.Metadata["stream"] != false {
+		t.Errorf("gateway metadata = %v", request.Metadata)
+	}
+
+	entries := readTestLogEntries(t, dir)
+	if len(entries) != 1 || entries[0].Usage == nil || *entries[0].Usage != (Usage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16}) {
+		t.Errorf("logged entries = %+v", entries)
+	}
+}
+
+// TestRequestTemplateErrors tests that a template that fails to parse is
+// reported with its file and line, and one rendering something else than
+// JSON with what is wrong
+func TestRequestTemplateErrors(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.tmpl")
+	os.WriteFile(broken, []byte("{\n  \"input\": {{json .Messages}},\n  \"model\": {{upper .Model}}\n}\n"), 0644)
+	notJSON := filepath.Join(dir, "notjson.tmpl")
+	os.WriteFile(notJSON, []byte(`{"input": {{.Messages}}}`), 0644)
+
+	request := ChatRequest{Model: "gpt-4o", Messages: []Message{{Role: "user", Content: "hi"}}}
+	if _, err := renderRequestTemplate(broken, request); err == nil || !strings.Contains(err.Error(), broken+":3:") {
+		t.Errorf("broken template error = %v, want its file and line 3", err)
+	}
+	if _, err := renderRequestTemplate(notJSON, request); err == nil || !strings.Contains(err.Error(), "did not render JSON") {
+		t.Errorf("template rendering text error = %v", err)
+	}
+	if _, err := validateConfigValue(envRequestTemplate, broken); err == nil || !strings.Contains(err.Error(), broken+":3:") {
+		t.Errorf("config set of a broken template error = %v", err)
+	}
+	if _, err := validateConfigValue(envRequestTemplate, filepath.Join(dir, "missing.tmpl")); err == nil {
+		t.Error("config set of a missing template succeeded")
+	}
+}
+
+// TestParseResponsePaths tests the forms OPENAI_RESPONSE_JSONPATH takes
+func TestParseResponsePaths(t *testing.T) {
+	paths, err := parseResponsePaths("output.text")
+	if err != nil || paths.Text != "output.text" || paths.PromptTokens != defaultResponsePaths.PromptTokens {
+		t.Errorf("parseResponsePaths(bare path) = %+v, %v", paths, err)
+	}
+	paths, err = parseResponsePaths("text=data.0.reply; total_tokens=cost.tokens;")
+	if err != nil || paths.Text != "data.0.reply" || paths.TotalTokens != "cost.tokens" {
+		t.Errorf("parseResponsePaths(pairs) = %+v, %v", paths, err)
+	}
+	for _, value := range []string{"text=output..text", "reply=output.text", ";", "text="} {
+		if _, err := parseResponsePaths(value); err == nil {
+			t.Errorf("parseResponsePaths(%q) succeeded", value)
+		}
+	}
+}
+
+// TestGatewayChatResponse tests reading replies at the configured paths
+func TestGatewayChatResponse(t *testing.T) {
+	paths := defaultResponsePaths
+	paths.Text = "data.1.reply"
+	response, err := gatewayChatResponse([]byte(`{"data": [{}, {"reply": "second"}], "usage": {"total_tokens": 9}}`), paths, "m")
+	if err != nil || formatResponse(response) != "second" || response.Usage.TotalTokens != 9 || response.Model != "m" {
+		t.Errorf("gatewayChatResponse() = %+v, %v", response, err)
+	}
+
+	for body, want := range map[string]string{
+		`{"data": []}`:                 "no text at data.1.reply",
+		`{"data": [{}, {"reply": 3}]}`: "is not a string",
+		`{"data": [{}, {"reply": "x"}], "usage": {"total_tokens": "9"}}`: "is not a number",
+	} {
+		if _, err := gatewayChatResponse([]byte(body), paths, "m"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("gatewayChatResponse(%s) error = %v, want %q", body, err, want)
+		}
+	}
+}
+
+// TestGatewayUnset tests that requests are those of the OpenAI API when
+// neither key is set
+func TestGatewayUnset(t *testing.T) {
+	if _, ok := chatProviderFor(&Config{Provider: providerOpenAI}).(openAIProvider); !ok {
+		t.Error("provider without a gateway is not the OpenAI one")
+	}
+	if _, ok := chatProviderFor(&Config{Provider: providerAzure, RequestTemplate: "t.tmpl"}).(gatewayProvider); !ok {
+		t.Error("provider with a request template is not the gateway one")
+	}
+
+	// Error statuses are still reported as the API's errors
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error": {"message": "bad key"}}`))
+	}))
+	defer server.Close()
+	config := &Config{APIKey: "sk-test", APIURL: server.URL, Model: "gpt-4o", Timeout: 5 * time.Second, ResponsePaths: &defaultResponsePaths}
+	if _, err := newAPIClient(config).Chat(config.requestContext(), []Message{{Role: "user", Content: "hi"}}); !errors.Is(err, ErrAuth) {
+		t.Errorf("gateway error status = %v, want an authentication error", err)
+	}
+}
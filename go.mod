@@ -1,3 +1,10 @@
 module chatgpt-cli
 
 go 1.19
+
+require (
+	golang.org/x/crypto v0.21.0
+	golang.org/x/term v0.18.0
+)
+
+require golang.org/x/sys v0.18.0 // indirect
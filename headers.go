@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Words in a header name that make its value a secret, masked when printed
+var sensitiveHeaderWords = []string{"key", "token", "secret"}
+
+// extraHeader is a header added to every chat request, from
+// OPENAI_EXTRA_HEADERS or prompt --header
+type extraHeader struct {
+	Name  string
+	Value string
+}
+
+// headerListFlag is a repeatable flag collecting "Key: Value" headers
+type headerListFlag []extraHeader
+
+func (f *headerListFlag) String() string {
+	return formatHeaderList(*f)
+}
+
+func (f *headerListFlag) Set(value string) error {
+	header, err := parseHeader(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, header)
+	return nil
+}
+
+// parseHeader parses a "Key: Value" header
+func parseHeader(s string) (extraHeader, error) {
+	name, value, found := strings.Cut(s, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !found || !validHeaderName(name) {
+		return extraHeader{}, fmt.Errorf("header must be \"Key: Value\": %q", s)
+	}
+	if value == "" || strings.ContainsAny(value, "\r\n") {
+		return extraHeader{}, fmt.Errorf("header %s needs a value on a single line", name)
+	}
+	return extraHeader{Name: http.CanonicalHeaderKey(name), Value: value}, nil
+}
+
+// validHeaderName reports whether name is a valid HTTP header name
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseHeaderList parses OPENAI_EXTRA_HEADERS, semicolon-separated
+// "Key: Value" pairs. Invalid pairs are skipped; config set rejects them.
+func parseHeaderList(value string) []extraHeader {
+	var headers []extraHeader
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		if header, err := parseHeader(pair); err == nil {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// validateHeaderList checks that every pair of a header list is "Key: Value"
+func validateHeaderList(value string) error {
+	found := false
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		if _, err := parseHeader(pair); err != nil {
+			return fmt.Errorf("extra headers must be \"Key: Value\" pairs separated by semicolons: %w", err)
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("extra headers cannot be empty; remove them with config unset")
+	}
+	return nil
+}
+
+// formatHeaderList formats headers as config list shows them, with the values
+// of secret headers masked
+func formatHeaderList(headers []extraHeader) string {
+	if len(headers) == 0 {
+		return "(not set)"
+	}
+	pairs := make([]string, len(headers))
+	for i, header := range headers {
+		pairs[i] = header.Name + ": " + maskHeader(header.Name, header.Value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// sensitiveHeader reports whether the value of the header named name is a
+// secret, judging by its name
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// setExtraHeaders adds the extra headers to a chat request. Replacing a
+// header chatgpt-cli sets itself, such as Authorization or Content-Type,
+// needs --allow-header-override.
+func setExtraHeaders(req *http.Request, config *Config) error {
+	added := make(map[string]bool)
+	for _, header := range config.ExtraHeaders {
+		if !added[header.Name] && req.Header.Get(header.Name) != "" && !config.AllowHeaderOverride {
+			return usageErrorf("extra header %s would replace the one chatgpt-cli sets; pass --allow-header-override to send it", header.Name)
+		}
+		req.Header.Set(header.Name, header.Value)
+		added[header.Name] = true
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseHeader tests parsing "Key: Value" headers
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    extraHeader
+		wantErr bool
+	}{
+		{input: "X-Team-Id: 42", want: extraHeader{"X-Team-Id", "42"}},
+		{input: "x-request-source:cli", want: extraHeader{"X-Request-Source", "cli"}},
+		{input: " X-Trace : a:b ", want: extraHeader{"X-Trace", "a:b"}},
+		{input: "X-Team-Id", wantErr: true},
+		{input: "X-Team-Id:", wantErr: true},
+		{input: ": 42", wantErr: true},
+		{input: "X Team: 42", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseHeader(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHeader(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseHeader(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestParseHeaderList tests OPENAI_EXTRA_HEADERS parsing and validation
+func TestParseHeaderList(t *testing.T) {
+	headers := parseHeaderList("X-Request-Source: cli; ; X-Team-Id: 42;")
+	if len(headers) != 2 || headers[0].Name != "X-Request-Source" || headers[1].Value != "42" {
+		t.Errorf("parseHeaderList() = %+v", headers)
+	}
+
+	if err := validateHeaderList("X-Request-Source: cli; X-Team-Id: 42"); err != nil {
+		t.Errorf("validateHeaderList() error = %v", err)
+	}
+	for _, value := range []string{"X-Team-Id", "X-A: 1; nope", " ; "} {
+		if err := validateHeaderList(value); err == nil {
+			t.Errorf("validateHeaderList(%q) = nil, want an error", value)
+		}
+	}
+}
+
+// TestFormatHeaderList tests that secret header values are masked
+func TestFormatHeaderList(t *testing.T) {
+	headers := []extraHeader{{"X-Team-Id", "42"}, {"X-Gateway-Token", "tok-1234567890"}, {"X-Client-Secret", "abc"}}
+	if got, want := formatHeaderList(headers), "X-Team-Id: 42; X-Gateway-Token: tok-...7890; X-Client-Secret: ***"; got != want {
+		t.Errorf("formatHeaderList() = %q, want %q", got, want)
+	}
+	if got := formatHeaderList(nil); got != "(not set)" {
+		t.Errorf("formatHeaderList(nil) = %q", got)
+	}
+}
+
+// TestMaskSensitiveHeader tests masking headers by name in verbose and
+// dry-run output
+func TestMaskSensitiveHeader(t *testing.T) {
+	var out strings.Builder
+	writeDebugHeaders(&out, "> ", http.Header{
+		"X-Team-Id":    {"42"},
+		"X-Access-Key": {"ak-1234567890"},
+	})
+	if got, want := out.String(), "> X-Access-Key: ak-1...7890\n> X-Team-Id: 42\n"; got != want {
+		t.Errorf("debug headers = %q, want %q", got, want)
+	}
+}
+
+// TestSetExtraHeaders tests that replacing a header chatgpt-cli sets needs
+// --allow-header-override
+func TestSetExtraHeaders(t *testing.T) {
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest("POST", "https://example.com", nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer sk-test")
+		return req
+	}
+
+	config := &Config{ExtraHeaders: []extraHeader{{"X-Team-Id", "1"}, {"X-Team-Id", "2"}}}
+	req := newRequest()
+	if err := setExtraHeaders(req, config); err != nil {
+		t.Fatalf("setExtraHeaders() error = %v", err)
+	}
+	if got := req.Header.Get("X-Team-Id"); got != "2" {
+		t.Errorf("X-Team-Id = %q, want the last value", got)
+	}
+
+	for _, name := range []string{"Authorization", "Content-Type"} {
+		config := &Config{ExtraHeaders: []extraHeader{{name, "x"}}}
+		if err := setExtraHeaders(newRequest(), config); !errors.Is(err, ErrUsage) {
+			t.Errorf("overriding %s error = %v, want a usage error", name, err)
+		}
+
+		config.AllowHeaderOverride = true
+		req := newRequest()
+		if err := setExtraHeaders(req, config); err != nil || req.Header.Get(name) != "x" {
+			t.Errorf("overriding %s with --allow-header-override: error = %v, value %q", name, err, req.Header.Get(name))
+		}
+	}
+}
+
+// TestPromptCommandHeaders tests that configured and --header headers are
+// sent with the prompt
+func TestPromptCommandHeaders(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:       "sk-test",
+		APIURL:       server.URL,
+		Model:        "gpt-4o",
+		Timeout:      5 * time.Second,
+		ConfigDir:    t.TempDir(),
+		ExtraHeaders: parseHeaderList("X-Request-Source: cli; X-Team-Id: 1"),
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "--header", "X-Team-Id: 42", "hello"}); err != nil {
+			t.Fatalf("promptCommand() error = %v", err)
+		}
+	})
+	if got.Get("X-Request-Source") != "cli" || got.Get("X-Team-Id") != "42" || got.Get("Authorization") != "Bearer sk-test" {
+		t.Errorf("request headers = %v", got)
+	}
+
+	err := promptCommand(config, []string{"--header", "Authorization: Bearer other", "hello"})
+	if !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), "--allow-header-override") {
+		t.Errorf("overriding Authorization error = %v, want a usage error", err)
+	}
+}
+
+// TestDryRunShowsExtraHeaders tests that --dry-run prints the extra headers
+// with secrets masked
+func TestDryRunShowsExtraHeaders(t *testing.T) {
+	config := &Config{
+		APIKey:       "sk-test-1234567890",
+		APIURL:       defaultAPIURL,
+		Model:        "gpt-4o",
+		ExtraHeaders: []extraHeader{{"X-Team-Id", "42"}, {"X-Gateway-Token", "gw-1234567890"}},
+	}
+	req, err := newChatRequest(context.Background(), config, "hi", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := newDryRunOutput(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.Headers["X-Team-Id"] != "42" || output.Headers["X-Gateway-Token"] != "gw-1...7890" {
+		t.Errorf("dry-run headers = %v", output.Headers)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Number of prompts listed by the history command unless --limit is given
+const defaultHistoryLimit = 20
+
+// HistoryEntry is a logged prompt with its history index, 1 being the most
+// recent prompt
+type HistoryEntry struct {
+	Index int `json:"index"`
+	LogEntry
+}
+
+// historyCommand lists recent prompts from the logs, most recent first
+func historyCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "show":
+			return historyShowCommand(config, args[1:])
+		case "rerun":
+			return historyRerunCommand(config, args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	search := fs.String("search", "", "show only prompts containing this text, ignoring case")
+	limit := fs.Int("limit", defaultHistoryLimit, "show at most N prompts, 0 for all")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli history [--search text] [--limit N]", err)
+	}
+	if *limit < 0 {
+		return usageErrorf("--limit must be a non-negative integer")
+	}
+
+	history, err := readHistory(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	// Indexes stay those of the full history so that show and rerun accept them
+	matches := []HistoryEntry{}
+	for _, entry := range history {
+		if *limit > 0 && len(matches) == *limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(entry.Prompt), strings.ToLower(*search)) {
+			matches = append(matches, entry)
+		}
+	}
+
+	if config.Output == outputJSON {
+		return printJSON(matches)
+	}
+
+	if len(matches) == 0 {
+		if *search != "" {
+			fmt.Printf("No prompts in history match %q.\n", *search)
+		} else {
+			fmt.Println("No prompts in history.")
+		}
+		return nil
+	}
+
+	for _, entry := range matches {
+		fmt.Printf("%4d  %s  %s\n", entry.Index, entry.Timestamp.Local().Format("2006-01-02 15:04"),
+			truncate(strings.Join(strings.Fields(entry.Prompt), " "), config.LogPreviewLen))
+	}
+	return nil
+}
+
+// historyShowCommand prints a past prompt and its response in full
+func historyShowCommand(config *Config, args []string) error {
+	if len(args) != 1 {
+		return usageErrorf("history index required\nUsage: chatgpt-cli history show <n>")
+	}
+
+	entry, err := historyEntry(config, args[0])
+	if err != nil {
+		return err
+	}
+
+	if config.Output == outputJSON {
+		return printJSON(entry)
+	}
+
+	fmt.Printf("[%d] %s - %s", entry.Index, entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.Command)
+	if entry.Model != "" {
+		fmt.Printf(" (%s)", entry.Model)
+	}
+	fmt.Printf("\n\nPrompt:\n%s\n", entry.Prompt)
+	if entry.Response != "" {
+		fmt.Printf("\nResponse:\n%s\n", entry.Response)
+	}
+	if entry.Error != "" {
+		fmt.Printf("\nError: %s\n", entry.Error)
+	}
+	if entry.Usage != nil {
+		fmt.Printf("\nTokens: %d (prompt: %d, completion: %d)\n",
+			entry.Usage.TotalTokens, entry.Usage.PromptTokens, entry.Usage.CompletionTokens)
+	}
+	return nil
+}
+
+// historyRerunCommand sends a past prompt again with the current
+// configuration. Arguments after the index are passed on as prompt flags.
+func historyRerunCommand(config *Config, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("history index required\nUsage: chatgpt-cli history rerun <n> [prompt flags]")
+	}
+
+	entry, err := historyEntry(config, args[0])
+	if err != nil {
+		return err
+	}
+
+	// The terminator keeps a prompt starting with "-" from being read as a flag
+	promptArgs := append(append([]string{}, args[1:]...), "--", entry.Prompt)
+	return promptCommand(config, promptArgs)
+}
+
+// historyEntry returns the history entry selected by a 1-based index given
+// on the command line
+func historyEntry(config *Config, arg string) (HistoryEntry, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return HistoryEntry{}, usageErrorf("invalid history index %q: expected a number from chatgpt-cli history", arg)
+	}
+
+	history, err := readHistory(config.ConfigDir)
+	if err != nil {
+		return HistoryEntry{}, fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	switch {
+	case len(history) == 0:
+		return HistoryEntry{}, usageErrorf("history is empty")
+	case n < 1 || n > len(history):
+		return HistoryEntry{}, usageErrorf("history index %d out of range: choose 1 to %d", n, len(history))
+	}
+	return history[n-1], nil
+}
+
+// readHistory returns every logged prompt, most recent first
+func readHistory(configDir string) ([]HistoryEntry, error) {
+	logFiles, err := listLogFiles(configDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var prompts []LogEntry
+	_, err = forEachLogEntry(logFiles, func(entry LogEntry) error {
+		if inHistory(entry) {
+			prompts = append(prompts, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]HistoryEntry, len(prompts))
+	for i, entry := range prompts {
+		history[len(prompts)-1-i] = HistoryEntry{Index: len(prompts) - i, LogEntry: entry}
+	}
+	return history, nil
+}
+
+// inHistory reports whether a log entry is listed in the history. The
+// refinements of a refine prompt repeat it, so only its first pass is listed,
+// and embedded texts, image prompts, transcribed files and reviewed diffs
+// are not prompts to rerun.
+func inHistory(entry LogEntry) bool {
+	switch entry.Command {
+	case "embed", "image", "transcribe", "review":
+		return false
+	}
+	return entry.Prompt != "" && entry.Pass <= 1
+}
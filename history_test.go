@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestHistory logs three prompts, the oldest first, plus an entry
+// without a prompt
+func writeTestHistory(t *testing.T, configDir string) {
+	t.Helper()
+
+	now := time.Now()
+	writeTestLogs(t, rotatedLogFile(configDir, 1), []LogEntry{
+		{Timestamp: now.Add(-3 * time.Hour), Command: "prompt", Prompt: "Explain Kubernetes pods", Response: strings.Repeat("Pods are ", 20)},
+	})
+	writeTestLogs(t, filepath.Join(configDir, logFileName), []LogEntry{
+		{Timestamp: now.Add(-2 * time.Hour), Command: "config"},
+		{Timestamp: now.Add(-time.Hour), Command: "batch", Prompt: "-starts with a dash", Error: "timeout"},
+		{Timestamp: now, Command: "prompt", Prompt: "What is a kubernetes service?", Response: "A service exposes pods", Model: "gpt-4o"},
+	})
+}
+
+// TestReadHistory tests that prompts are indexed from the most recent
+func TestReadHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestHistory(t, tmpDir)
+
+	history, err := readHistory(tmpDir)
+	if err != nil {
+		t.Fatalf("readHistory() error = %v", err)
+	}
+
+	expected := []string{"What is a kubernetes service?", "-starts with a dash", "Explain Kubernetes pods"}
+	if len(history) != len(expected) {
+		t.Fatalf("readHistory() returned %d entries, want %d", len(history), len(expected))
+	}
+	for i, prompt := range expected {
+		if history[i].Index != i+1 || history[i].Prompt != prompt {
+			t.Errorf("history[%d] = %d %q, want %d %q", i, history[i].Index, history[i].Prompt, i+1, prompt)
+		}
+	}
+}
+
+// TestHistoryCommand tests listing and searching prompts
+func TestHistoryCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	config := &Config{ConfigDir: tmpDir}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := historyCommand(config, []string{}); err != nil {
+			t.Errorf("historyCommand() error = %v", err)
+		}
+	})
+	if out != "No prompts in history.\n" {
+		t.Errorf("empty history output = %q", out)
+	}
+
+	writeTestHistory(t, tmpDir)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"all", []string{}, []string{"1", "2", "3"}},
+		{"limit", []string{"--limit", "2"}, []string{"1", "2"}},
+		{"search ignores case", []string{"--search", "KUBERNETES"}, []string{"1", "3"}},
+		{"search and limit", []string{"--search", "kubernetes", "--limit", "1"}, []string{"1"}},
+		{"no match", []string{"--search", "docker"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureOutput(t, &os.Stdout, func() {
+				if err := historyCommand(config, tt.args); err != nil {
+					t.Errorf("historyCommand() error = %v", err)
+				}
+			})
+
+			var indexes []string
+			for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+				if fields := strings.Fields(line); len(fields) > 0 && fields[0] != "No" {
+					indexes = append(indexes, fields[0])
+				}
+			}
+			if strings.Join(indexes, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("listed indexes = %v, want %v\n%s", indexes, tt.expected, out)
+			}
+		})
+	}
+
+	if err := historyCommand(config, []string{"--limit", "-1"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("historyCommand(--limit -1) error = %v, want a usage error", err)
+	}
+}
+
+// TestHistoryShowCommand tests printing a past entry in full
+func TestHistoryShowCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	writeTestHistory(t, tmpDir)
+	config := &Config{ConfigDir: tmpDir}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := historyCommand(config, []string{"show", "3"}); err != nil {
+			t.Errorf("history show error = %v", err)
+		}
+	})
+	if !strings.Contains(out, strings.Repeat("Pods are ", 20)) {
+		t.Errorf("history show output should contain the full response:\n%s", out)
+	}
+
+	config.Output = outputJSON
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := historyCommand(config, []string{"show", "2"}); err != nil {
+			t.Errorf("history show error = %v", err)
+		}
+	})
+	var entry HistoryEntry
+	if err := json.Unmarshal([]byte(out), &entry); err != nil {
+		t.Fatalf("history show output is not JSON: %v\n%s", err, out)
+	}
+	if entry.Index != 2 || entry.Error != "timeout" {
+		t.Errorf("history show = %+v, want entry 2 with its error", entry)
+	}
+
+	tests := []struct {
+		args        []string
+		errContains string
+	}{
+		{[]string{"show"}, "history index required"},
+		{[]string{"show", "0"}, "history index 0 out of range: choose 1 to 3"},
+		{[]string{"show", "4"}, "history index 4 out of range: choose 1 to 3"},
+		{[]string{"show", "last"}, `invalid history index "last"`},
+		{[]string{"rerun"}, "history index required"},
+	}
+	for _, tt := range tests {
+		err := historyCommand(config, tt.args)
+		if !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), tt.errContains) {
+			t.Errorf("historyCommand(%q) error = %v, want usage error containing %q", tt.args, err, tt.errContains)
+		}
+	}
+
+	if err := historyCommand(&Config{ConfigDir: t.TempDir()}, []string{"show", "1"}); err == nil || !strings.Contains(err.Error(), "history is empty") {
+		t.Errorf("history show on empty logs error = %v, want history is empty", err)
+	}
+}
+
+// TestHistoryRerunCommand tests resending a past prompt
+func TestHistoryRerunCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		received = request.Messages[0].Content
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			Choices: []Choice{{Message: Message{Content: "Again"}}},
+		})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestHistory(t, tmpDir)
+	config := &Config{
+		APIKey:    "test-key",
+		APIURL:    server.URL,
+		Model:     "gpt-4o",
+		Timeout:   10 * time.Second,
+		Stream:    true,
+		ConfigDir: tmpDir,
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := historyCommand(config, []string{"rerun", "2", "--no-stream", "--raw"}); err != nil {
+			t.Errorf("history rerun error = %v", err)
+		}
+	})
+
+	if received != "-starts with a dash" {
+		t.Errorf("rerun sent %q, want the logged prompt", received)
+	}
+	if strings.TrimSpace(out) != "Again" {
+		t.Errorf("rerun output = %q, want the response", out)
+	}
+
+	// The rerun is logged and becomes the most recent prompt
+	history, err := readHistory(tmpDir)
+	if err != nil || len(history) != 4 || history[0].Prompt != "-starts with a dash" {
+		t.Errorf("history after rerun = %+v, %v", history, err)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Image model used when OPENAI_IMAGE_MODEL is unset
+const defaultImageModel = "dall-e-3"
+
+// Most images asked for in one request
+const maxImagesPerRequest = 10
+
+// imageSizes are the sizes accepted by each known image model; the sizes of
+// other models are left to the API to check
+var imageSizes = map[string][]string{
+	"dall-e-2":    {"256x256", "512x512", "1024x1024"},
+	"dall-e-3":    {"1024x1024", "1792x1024", "1024x1792"},
+	"gpt-image-1": {"auto", "1024x1024", "1536x1024", "1024x1536"},
+}
+
+// singleImageModels make one image per request
+var singleImageModels = map[string]bool{"dall-e-3": true}
+
+// ImageRequest is the body of an images generations request
+type ImageRequest struct {
+	Model  string `json:"model,omitempty"`
+	Prompt string `json:"prompt"`
+	N      int    `json:"n,omitempty"`
+	Size   string `json:"size,omitempty"`
+}
+
+// ImageResponse is the reply of the images generations endpoint
+type ImageResponse struct {
+	Created int64       `json:"created"`
+	Data    []ImageData `json:"data"`
+}
+
+// ImageData is one generated image, as a URL to download or as base64
+type ImageData struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// SavedImage is one image written by the image command, as printed with
+// --output json
+type SavedImage struct {
+	Path          string `json:"path"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// imageCommand generates images from a prompt and saves them as PNG files
+func imageCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli image [--size WxH] [--n N] [--out dir] <prompt>"
+
+	fs := flag.NewFlagSet("image", flag.ContinueOnError)
+	size := fs.String("size", "", "size of the images, such as 1024x1024 (default: the model's)")
+	n := fs.Int("n", 1, "number of images to generate")
+	outDir := fs.String("out", ".", "directory the images are saved in")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+
+	prompt := strings.TrimSpace(strings.Join(args, " "))
+	if prompt == "" {
+		return usageErrorf("prompt cannot be empty\n%s", usage)
+	}
+	if config.Provider == providerAnthropic || config.Provider == providerOllama {
+		return usageErrorf("the %s provider has no images endpoint; use %s or %s", config.Provider, providerOpenAI, providerAzure)
+	}
+	if err := validateImageOptions(config.ImageModel, *size, *n); err != nil {
+		return err
+	}
+
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	ctx := config.requestContext()
+	start := time.Now()
+	stopProgress := startProgress(config)
+	saved, err := generateImages(ctx, config, ImageRequest{Prompt: prompt, N: *n, Size: *size}, *outDir)
+	stopProgress()
+
+	// The log records where the images went, not their bytes
+	entry := LogEntry{
+		Timestamp: time.Now(),
+		Command:   "image",
+		Prompt:    prompt,
+		Model:     config.ImageModel,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if len(saved) > 0 {
+		paths := make([]string, len(saved))
+		for i, image := range saved {
+			paths[i] = image.Path
+		}
+		noun := "images"
+		if len(saved) == 1 {
+			noun = "image"
+		}
+		entry.Response = fmt.Sprintf("[%d %s saved: %s]", len(saved), noun, strings.Join(paths, ", "))
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		if isCancelled(err) {
+			entry.Error = cancelledLogMessage
+		}
+	}
+	warnLogError(config, writeLogEntry(config, entry))
+
+	if config.Output == outputJSON && err == nil {
+		return printJSON(saved)
+	}
+	for _, image := range saved {
+		fmt.Println(image.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate images: %w", err)
+	}
+	return nil
+}
+
+// validateImageOptions checks --size and --n against what the model accepts
+func validateImageOptions(model, size string, n int) error {
+	if n < 1 || n > maxImagesPerRequest {
+		return usageErrorf("--n must be between 1 and %d", maxImagesPerRequest)
+	}
+	if n > 1 && singleImageModels[model] {
+		return usageErrorf("%s makes one image per request; use --n 1, or set %s to a model such as gpt-image-1", model, envImageModel)
+	}
+
+	sizes, known := imageSizes[model]
+	if size == "" || !known {
+		return nil
+	}
+	for _, s := range sizes {
+		if s == size {
+			return nil
+		}
+	}
+	return usageErrorf("%s can't make %s images; use one of: %s", model, size, strings.Join(sizes, ", "))
+}
+
+// getImagesURL returns the images generations endpoint, derived from the
+// chat completions URL: /v1/images/generations for OpenAI and the
+// deployment's images/generations for Azure
+func getImagesURL(config *Config) (string, error) {
+	return siblingEndpointURL(config, "/images/generations")
+}
+
+// newImagesRequest builds the request generating the images of request
+func newImagesRequest(ctx context.Context, config *Config, request ImageRequest) (*http.Request, error) {
+	request.Model = config.ImageModel
+	// Azure encodes the model in the deployment URL
+	if config.Provider == providerAzure {
+		request.Model = ""
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	apiURL, err := getImagesURL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeader(req, config)
+	if err := setExtraHeaders(req, config); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// fetchImages sends the request to the images endpoint and returns the
+// images of its reply
+func fetchImages(ctx context.Context, client *APIClient, config *Config, request ImageRequest) (*ImageResponse, error) {
+	resp, err := client.doLimited(ctx, func() (*http.Request, error) {
+		return newImagesRequest(ctx, config, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ImageResponse
+	if err := decodeResponse(resp, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Data) == 0 {
+		return nil, fmt.Errorf("the response contains no images")
+	}
+	return &response, nil
+}
+
+// generateImages asks for the images and saves each one in dir, returning
+// those saved before any error
+func generateImages(ctx context.Context, config *Config, request ImageRequest, dir string) ([]SavedImage, error) {
+	client := newAPIClient(config)
+
+	response, err := fetchImages(ctx, client, config, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []SavedImage
+	next := 1
+	for i, image := range response.Data {
+		data, err := imageBytes(ctx, client.http, image)
+		if err != nil {
+			return saved, fmt.Errorf("image %d: %w", i+1, err)
+		}
+		path, err := writeNumberedImage(dir, &next, data)
+		if err != nil {
+			return saved, fmt.Errorf("failed to save image: %w", err)
+		}
+		saved = append(saved, SavedImage{Path: path, RevisedPrompt: image.RevisedPrompt})
+	}
+	return saved, nil
+}
+
+// imageBytes returns the contents of an image, decoding its base64 or
+// downloading its URL
+func imageBytes(ctx context.Context, client *http.Client, image ImageData) ([]byte, error) {
+	if image.B64JSON != "" {
+		data, err := base64.StdEncoding.DecodeString(image.B64JSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		return data, nil
+	}
+	if image.URL == "" {
+		return nil, fmt.Errorf("the response has neither a URL nor data for the image")
+	}
+
+	// Image URLs are signed, so no API key is sent to download them
+	resp, err := sendRequest(ctx, client, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, "GET", image.URL, nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: unexpected status code: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	return data, nil
+}
+
+// writeNumberedImage saves data as the first free image-<n>.png in dir, from
+// *next on, and moves *next past it so existing images are never replaced
+func writeNumberedImage(dir string, next *int, data []byte) (string, error) {
+	for ; ; *next++ {
+		path := filepath.Join(dir, fmt.Sprintf("image-%d.png", *next))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		*next++
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
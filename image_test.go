@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Bytes standing in for a PNG image in the tests
+var testImageBytes = []byte("\x89PNG\r\n\x1a\nfake image")
+
+// TestValidateImageOptions tests checking --size and --n per model
+func TestValidateImageOptions(t *testing.T) {
+	tests := []struct {
+		model   string
+		size    string
+		n       int
+		wantErr string
+	}{
+		{"dall-e-3", "", 1, ""},
+		{"dall-e-3", "1792x1024", 1, ""},
+		{"dall-e-3", "512x512", 1, "dall-e-3 can't make 512x512 images; use one of: 1024x1024, 1792x1024, 1024x1792"},
+		{"dall-e-3", "1024x1024", 2, "dall-e-3 makes one image per request"},
+		{"dall-e-2", "256x256", 4, ""},
+		{"gpt-image-1", "auto", 10, ""},
+		{"gpt-image-1", "1792x1024", 1, "gpt-image-1 can't make 1792x1024 images"},
+		{"my-image-model", "640x480", 3, ""},
+		{"gpt-image-1", "", 0, "--n must be between 1 and 10"},
+		{"gpt-image-1", "", 11, "--n must be between 1 and 10"},
+	}
+
+	for _, tt := range tests {
+		err := validateImageOptions(tt.model, tt.size, tt.n)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validateImageOptions(%q, %q, %d) error = %v", tt.model, tt.size, tt.n, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) || exitCode(err) != exitUsage {
+			t.Errorf("validateImageOptions(%q, %q, %d) error = %v, want a usage error containing %q", tt.model, tt.size, tt.n, err, tt.wantErr)
+		}
+	}
+}
+
+// TestGetImagesURL tests deriving the images endpoint from the chat
+// completions URL
+func TestGetImagesURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected string
+	}{
+		{"openai", Config{APIURL: defaultAPIURL}, "https://api.openai.com/v1/images/generations"},
+		{"proxy prefix", Config{APIURL: "https://gateway.example.com/openai/v1/chat/completions"}, "https://gateway.example.com/openai/v1/images/generations"},
+		{"other path", Config{APIURL: "https://llm.example.com/generate"}, "https://llm.example.com/v1/images/generations"},
+		{"azure", Config{Provider: providerAzure, APIURL: "https://res.openai.azure.com/openai/deployments/dalle/chat/completions"},
+			"https://res.openai.azure.com/openai/deployments/dalle/images/generations?api-version=" + defaultAzureAPIVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getImagesURL(&tt.config)
+			if err != nil {
+				t.Fatalf("getImagesURL() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("getImagesURL() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestImageCommand tests saving base64 images as numbered files, without
+// replacing earlier ones, and logging the prompt without the image data
+func TestImageCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var request ImageRequest
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		response := ImageResponse{Created: 1700000000}
+		for i := 0; i < request.N; i++ {
+			response.Data = append(response.Data, ImageData{B64JSON: base64.StdEncoding.EncodeToString(testImageBytes)})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	outDir := filepath.Join(tmpDir, "out")
+	newConfig := func() *Config {
+		return &Config{APIKey: "test-key", APIURL: server.URL + "/v1/chat/completions", ImageModel: "gpt-image-1", Timeout: 10 * time.Second, ConfigDir: tmpDir}
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := imageCommand(newConfig(), []string{"--size", "1024x1536", "--n", "2", "--out", outDir, "a watercolor fox"}); err != nil {
+			t.Fatalf("image error = %v", err)
+		}
+	})
+	first, second := filepath.Join(outDir, "image-1.png"), filepath.Join(outDir, "image-2.png")
+	if out != first+"\n"+second+"\n" {
+		t.Errorf("output = %q, want the saved paths", out)
+	}
+	if path != "/v1/images/generations" || request.Model != "gpt-image-1" || request.Prompt != "a watercolor fox" || request.Size != "1024x1536" || request.N != 2 {
+		t.Errorf("request to %s = %+v", path, request)
+	}
+	for _, file := range []string{first, second} {
+		data, err := os.ReadFile(file)
+		if err != nil || string(data) != string(testImageBytes) {
+			t.Errorf("%s = %q, %v; want the decoded image", file, data, err)
+		}
+	}
+
+	config := newConfig()
+	config.Output = outputJSON
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := imageCommand(config, []string{"--out", outDir, "a watercolor owl"}); err != nil {
+			t.Fatalf("image error = %v", err)
+		}
+	})
+	var saved []SavedImage
+	if err := json.Unmarshal([]byte(out), &saved); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if len(saved) != 1 || saved[0].Path != filepath.Join(outDir, "image-3.png") {
+		t.Errorf("saved = %+v, want image-3.png after the existing images", saved)
+	}
+
+	entries := readTestLogEntries(t, tmpDir)
+	if len(entries) != 2 {
+		t.Fatalf("log entries = %+v, want 2", entries)
+	}
+	if entries[0].Command != "image" || entries[0].Prompt != "a watercolor fox" || entries[0].Model != "gpt-image-1" {
+		t.Errorf("log entry = %+v", entries[0])
+	}
+	if want := "[2 images saved: " + first + ", " + second + "]"; entries[0].Response != want {
+		t.Errorf("logged response = %q, want %q", entries[0].Response, want)
+	}
+	if inHistory(entries[0]) {
+		t.Errorf("image prompts are listed in the history")
+	}
+}
+
+// TestImageCommandDownload tests downloading images returned as URLs
+func TestImageCommandDownload(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/files/fox.png" {
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("the API key was sent with the download")
+			}
+			w.Write(testImageBytes)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ImageResponse{Data: []ImageData{{URL: server.URL + "/files/fox.png", RevisedPrompt: "A watercolor painting of a red fox"}}})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL + "/v1/chat/completions", ImageModel: defaultImageModel, Timeout: 10 * time.Second, ConfigDir: tmpDir}
+	captureOutput(t, &os.Stdout, func() {
+		if err := imageCommand(config, []string{"--out", tmpDir, "a watercolor fox"}); err != nil {
+			t.Fatalf("image error = %v", err)
+		}
+	})
+	data, err := os.ReadFile(filepath.Join(tmpDir, "image-1.png"))
+	if err != nil || string(data) != string(testImageBytes) {
+		t.Errorf("image-1.png = %q, %v; want the downloaded image", data, err)
+	}
+}
+
+// TestImageCommandErrors tests content policy rejections and the options
+// and providers the command refuses
+func TestImageCommandErrors(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"Your request was rejected as a result of our safety system.","type":"invalid_request_error","code":"content_policy_violation"}}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL, ImageModel: defaultImageModel, Timeout: 10 * time.Second, ConfigDir: tmpDir}
+	err := imageCommand(config, []string{"--out", tmpDir, "something forbidden"})
+	if err == nil || !strings.Contains(err.Error(), "safety system") || !strings.Contains(err.Error(), "Hint: the prompt was rejected by the content policy") {
+		t.Errorf("error = %v, want the rejection with a hint", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(tmpDir, "*.png")); len(files) != 0 {
+		t.Errorf("images saved after a rejection: %v", files)
+	}
+	if entries := readTestLogEntries(t, tmpDir); len(entries) != 1 || entries[0].Error == "" {
+		t.Errorf("log entries = %+v, want the failure logged", entries)
+	}
+
+	for _, args := range [][]string{{}, {"--size", "512x512", "a fox"}, {"--n", "2", "a fox"}} {
+		if err := imageCommand(config, args); exitCode(err) != exitUsage {
+			t.Errorf("image %q error = %v, want a usage error", args, err)
+		}
+	}
+
+	config.Provider = providerAnthropic
+	if err := imageCommand(config, []string{"a fox"}); exitCode(err) != exitUsage || !strings.Contains(err.Error(), "no images endpoint") {
+		t.Errorf("anthropic error = %v, want a usage error", err)
+	}
+}
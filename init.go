@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Models offered by init for each provider; any other name can be typed.
+// Azure uses the OpenAI list.
+var initModelChoices = map[string][]string{
+	providerOpenAI:    {"gpt-4o-mini", "gpt-4o", "gpt-4-turbo", "gpt-3.5-turbo"},
+	providerAnthropic: {"claude-3-5-sonnet-latest", "claude-3-5-haiku-latest", "claude-3-opus-latest"},
+	providerOllama:    {"llama3.2", "mistral", "qwen2.5"},
+}
+
+// Reads the API key without echoing it. Replaced in tests.
+var readSecret = readHidden
+
+// initCommand sets up the configuration of a new user, asking for the API
+// key, model and defaults, or taking them from flags with --non-interactive
+func initCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	nonInteractive := fs.Bool("non-interactive", false, "take the values from flags instead of asking")
+	apiKey := fs.String("api-key", "", "API key to save")
+	model := fs.String("model", "", "model to use")
+	temperature := fs.String("temperature", "", "default temperature between 0.0 and 2.0")
+	maxTokens := fs.String("max-tokens", "", "default max tokens in a response")
+	noVerify := fs.Bool("no-verify", false, "save without checking the API key against the models endpoint")
+
+	const usage = "Usage: chatgpt-cli init [--non-interactive] [--api-key key] [--model name] [--temperature N] [--max-tokens N] [--no-verify]"
+	rest, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(rest) > 0 {
+		return usageErrorf("unexpected argument: %s\n%s", rest[0], usage)
+	}
+
+	keyName := apiKeyName(config.Provider)
+	keys := []string{keyName, "OPENAI_MODEL", "OPENAI_TEMPERATURE", "OPENAI_MAX_TOKENS"}
+
+	// Values given as flags are not asked for
+	values := make(map[string]string)
+	for key, value := range map[string]string{keyName: *apiKey, "OPENAI_MODEL": *model, "OPENAI_TEMPERATURE": *temperature, "OPENAI_MAX_TOKENS": *maxTokens} {
+		if value == "" {
+			continue
+		}
+		if key == keyName {
+			values[key] = strings.TrimSpace(value)
+			continue
+		}
+		if values[key], err = validateConfigValue(key, value); err != nil {
+			return withKind(ErrUsage, err)
+		}
+	}
+
+	sections, err := loadConfigSections(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	exists := len(sections[config.Profile]) > 0
+
+	var wizard *initWizard
+	if *nonInteractive {
+		if values[keyName] == "" && config.APIKey == "" && providerRequiresAPIKey(config.Provider) {
+			return usageErrorf("--api-key is required with --non-interactive\n%s", usage)
+		}
+	} else {
+		if !stdinIsTerminal() {
+			return usageErrorf("init asks its questions in a terminal; in scripts, use --non-interactive with --api-key and the other flags")
+		}
+
+		wizard = &initWizard{w: os.Stdout, r: bufio.NewReader(stdin)}
+		if exists {
+			question := fmt.Sprintf("A configuration already exists in %s (profile: %s). Update it?", configFilePath(config.ConfigDir), profileDisplayName(config.Profile))
+			if !wizard.confirm(question) {
+				fmt.Println("Configuration unchanged")
+				return nil
+			}
+		}
+		if err := wizard.run(config, values); err != nil {
+			return err
+		}
+	}
+
+	if !*noVerify {
+		if err := verifyInitConfig(config, values); err != nil {
+			if wizard == nil {
+				return fmt.Errorf("%w\nFix the values or save them anyway with --no-verify", err)
+			}
+			fmt.Printf("%v\n", err)
+			if !wizard.confirm("Save anyway?") {
+				fmt.Println("Configuration unchanged")
+				return nil
+			}
+		}
+	}
+
+	// Only what was answered is written, so other values are kept
+	saved := make(map[string]string)
+	for _, key := range keys {
+		if values[key] != "" {
+			saved[key] = values[key]
+		}
+	}
+	if len(saved) == 0 {
+		fmt.Println("Nothing to change")
+		return nil
+	}
+	if saved[envAPIKey] != "" {
+		// The plain key replaces an encrypted one
+		saved[encryptedAPIKeyKey] = ""
+	}
+
+	if err := saveProfileConfig(config.ConfigDir, config.Profile, saved); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	verb := "saved"
+	if exists {
+		verb = "updated"
+	}
+	fmt.Printf("Configuration %s in %s (profile: %s)\n", verb, configFilePath(config.ConfigDir), profileDisplayName(config.Profile))
+	fmt.Println("Try it: chatgpt-cli prompt \"Hello\"")
+	return nil
+}
+
+// verifyInitConfig lists the models with the new values, a cheap request
+// that fails if the API key is wrong
+func verifyInitConfig(config *Config, values map[string]string) error {
+	check := *config
+	if key := values[apiKeyName(config.Provider)]; key != "" {
+		check.APIKey = key
+	}
+
+	fmt.Printf("Checking the API key against %s... ", getModelsURL(&check))
+	if _, err := fetchModels(config.requestContext(), &check); err != nil {
+		fmt.Println("failed")
+		return fmt.Errorf("API key check failed: %w", err)
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+// initWizard asks the questions of init on a terminal
+type initWizard struct {
+	w io.Writer
+	r *bufio.Reader
+}
+
+// run asks for every value not given as a flag. Pressing Enter keeps the
+// current value, which is then not written.
+func (wz *initWizard) run(config *Config, values map[string]string) error {
+	keyName := apiKeyName(config.Provider)
+	if values[keyName] == "" && providerRequiresAPIKey(config.Provider) {
+		question := fmt.Sprintf("%s: ", apiKeyLabel(config.Provider))
+		if config.APIKey != "" {
+			question = fmt.Sprintf("%s (Enter keeps %s): ", apiKeyLabel(config.Provider), maskAPIKey(config.APIKey))
+		}
+		secret, err := readSecret(question)
+		if err != nil {
+			return fmt.Errorf("failed to read the API key: %w", err)
+		}
+		values[keyName] = strings.TrimSpace(secret)
+		if values[keyName] == "" && config.APIKey == "" {
+			return usageErrorf("API key cannot be empty")
+		}
+	}
+
+	if values["OPENAI_MODEL"] == "" {
+		choices := initModelChoices[config.Provider]
+		if choices == nil {
+			choices = initModelChoices[providerOpenAI]
+		}
+		fmt.Fprintln(wz.w, "Models:")
+		for i, name := range choices {
+			fmt.Fprintf(wz.w, "  %d) %s\n", i+1, name)
+		}
+		answer, err := wz.ask("OPENAI_MODEL", "Model, a number above or any name", config.Model, func(answer string) string {
+			if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+				return choices[n-1]
+			}
+			return answer
+		})
+		if err != nil {
+			return err
+		}
+		values["OPENAI_MODEL"] = answer
+	}
+
+	if values["OPENAI_TEMPERATURE"] == "" {
+		answer, err := wz.ask("OPENAI_TEMPERATURE", "Temperature, 0.0-2.0", fmt.Sprintf("%.1f", config.Temperature), nil)
+		if err != nil {
+			return err
+		}
+		values["OPENAI_TEMPERATURE"] = answer
+	}
+
+	if values["OPENAI_MAX_TOKENS"] == "" {
+		answer, err := wz.ask("OPENAI_MAX_TOKENS", "Max tokens in a response", strconv.Itoa(config.MaxTokens), nil)
+		if err != nil {
+			return err
+		}
+		values["OPENAI_MAX_TOKENS"] = answer
+	}
+	return nil
+}
+
+// ask asks for the value of key until a valid one is given, showing the
+// current value in brackets. It returns "" when Enter keeps the current
+// value. convert, if set, turns the answer into the value.
+func (wz *initWizard) ask(key, question, current string, convert func(string) string) (string, error) {
+	for {
+		fmt.Fprintf(wz.w, "%s [%s]: ", question, current)
+		line, err := wz.r.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			if err != nil {
+				fmt.Fprintln(wz.w)
+				return "", fmt.Errorf("init cancelled")
+			}
+			return "", nil
+		}
+
+		if convert != nil {
+			answer = convert(answer)
+		}
+		value, validErr := validateConfigValue(key, answer)
+		if validErr == nil {
+			return value, nil
+		}
+		if err != nil {
+			return "", withKind(ErrUsage, validErr)
+		}
+		fmt.Fprintf(wz.w, "Invalid value: %v\n", validErr)
+	}
+}
+
+// confirm asks a yes or no question, reading the answer from the wizard's
+// input so that no typed-ahead answers are lost
+func (wz *initWizard) confirm(question string) bool {
+	fmt.Fprintf(wz.w, "%s [y/N]: ", question)
+	answer, _ := wz.r.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// apiKeyLabel names the provider's API key in questions
+func apiKeyLabel(provider string) string {
+	if provider == providerAnthropic {
+		return "Anthropic API key"
+	}
+	return "OpenAI API key"
+}
+
+// configFileExists reports whether a config file, current or legacy, exists
+func configFileExists(configDir string) bool {
+	for _, name := range []string{configFileName, legacyConfigFileName} {
+		if _, err := os.Stat(filepath.Join(configDir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newInitTestServer serves the models endpoint, accepting only the given key
+func newInitTestServer(t *testing.T, validKey string, requests *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("path = %q, want /v1/models", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer "+validKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"data":[{"id":"gpt-4o"}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// loadInitTestConfig loads the config of a test using configDir and server
+func loadInitTestConfig(t *testing.T, configDir string, server *httptest.Server) *Config {
+	t.Helper()
+	setTestEnv(envConfigDir, configDir)
+	setTestEnv(envAPIURL, server.URL+"/v1/chat/completions")
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	return config
+}
+
+// TestInitCommandNonInteractive tests provisioning the config from flags
+func TestInitCommandNonInteractive(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	requests := 0
+	server := newInitTestServer(t, "sk-good", &requests)
+	configDir := t.TempDir()
+	config := loadInitTestConfig(t, configDir, server)
+
+	// A key that fails the check is not saved
+	captureOutput(t, &os.Stdout, func() {
+		err := initCommand(config, []string{"--non-interactive", "--api-key", "sk-bad"})
+		if !errors.Is(err, ErrAuth) || !strings.Contains(err.Error(), "--no-verify") {
+			t.Errorf("initCommand() with a bad key error = %v, want an auth error mentioning --no-verify", err)
+		}
+	})
+	if configFileExists(configDir) {
+		t.Error("config file written although the key check failed")
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := initCommand(config, []string{"--non-interactive", "--api-key", "sk-good", "--model", "gpt-4o", "--temperature", "0.3"}); err != nil {
+			t.Errorf("initCommand() error = %v", err)
+		}
+	})
+	if requests != 2 {
+		t.Errorf("%d requests sent, want one check per run", requests)
+	}
+	if !strings.Contains(out, "Checking the API key") || !strings.Contains(out, "Configuration saved in") {
+		t.Errorf("output = %q, want the check and where the config was saved", out)
+	}
+
+	fileConfig, err := loadConfigFile(configDir)
+	if err != nil {
+		t.Fatalf("loadConfigFile() error = %v", err)
+	}
+	want := map[string]string{"OPENAI_API_KEY": "sk-good", "OPENAI_MODEL": "gpt-4o", "OPENAI_TEMPERATURE": "0.3"}
+	for key, value := range want {
+		if fileConfig[key] != value {
+			t.Errorf("%s = %q, want %q", key, fileConfig[key], value)
+		}
+	}
+	if _, exists := fileConfig["OPENAI_MAX_TOKENS"]; exists {
+		t.Error("OPENAI_MAX_TOKENS saved although it was not given")
+	}
+
+	// --no-verify saves without a request, updating only what is given
+	captureOutput(t, &os.Stdout, func() {
+		if err := initCommand(config, []string{"--non-interactive", "--api-key", "sk-offline", "--no-verify"}); err != nil {
+			t.Errorf("initCommand() --no-verify error = %v", err)
+		}
+	})
+	fileConfig, _ = loadConfigFile(configDir)
+	if requests != 2 || fileConfig["OPENAI_API_KEY"] != "sk-offline" || fileConfig["OPENAI_MODEL"] != "gpt-4o" {
+		t.Errorf("after --no-verify: %d requests, config %v", requests, fileConfig)
+	}
+}
+
+// TestInitCommandUsageErrors tests the arguments init refuses
+func TestInitCommandUsageErrors(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	requests := 0
+	config := loadInitTestConfig(t, t.TempDir(), newInitTestServer(t, "sk-good", &requests))
+
+	originalIsTerminal := stdinIsTerminal
+	defer func() { stdinIsTerminal = originalIsTerminal }()
+	stdinIsTerminal = func() bool { return false }
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"no key with --non-interactive", []string{"--non-interactive"}},
+		{"invalid temperature", []string{"--non-interactive", "--api-key", "sk-good", "--temperature", "3"}},
+		{"no terminal", nil},
+		{"unexpected argument", []string{"now"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := initCommand(config, tt.args); !errors.Is(err, ErrUsage) {
+				t.Errorf("initCommand() error = %v, want a usage error", err)
+			}
+		})
+	}
+	if requests != 0 {
+		t.Errorf("%d requests sent, want none", requests)
+	}
+}
+
+// TestInitCommandInteractive tests answering the questions, and declining to
+// update an existing configuration
+func TestInitCommandInteractive(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	requests := 0
+	server := newInitTestServer(t, "sk-typed", &requests)
+	configDir := t.TempDir()
+	config := loadInitTestConfig(t, configDir, server)
+
+	originalStdin, originalIsTerminal, originalReadSecret := stdin, stdinIsTerminal, readSecret
+	defer func() { stdin, stdinIsTerminal, readSecret = originalStdin, originalIsTerminal, originalReadSecret }()
+	stdinIsTerminal = func() bool { return true }
+	readSecret = func(prompt string) (string, error) {
+		if prompt != "OpenAI API key: " {
+			t.Errorf("API key question = %q", prompt)
+		}
+		return "sk-typed\n", nil
+	}
+
+	// Model 2 from the list, the default temperature, an invalid then a
+	// valid max tokens
+	stdin = strings.NewReader("2\n\nlots\n500\n")
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := initCommand(config, nil); err != nil {
+			t.Errorf("initCommand() error = %v", err)
+		}
+	})
+	for _, want := range []string{"  2) gpt-4o", "Model, a number above or any name [gpt-3.5-turbo]: ", "Temperature, 0.0-2.0 [0.7]: ", "Invalid value: max tokens must be a positive integer", "Configuration saved in"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output = %q, want %q", out, want)
+		}
+	}
+
+	fileConfig, err := loadConfigFile(configDir)
+	if err != nil {
+		t.Fatalf("loadConfigFile() error = %v", err)
+	}
+	if fileConfig["OPENAI_API_KEY"] != "sk-typed" || fileConfig["OPENAI_MODEL"] != "gpt-4o" || fileConfig["OPENAI_MAX_TOKENS"] != "500" {
+		t.Errorf("config = %v, want the typed key, gpt-4o and 500 max tokens", fileConfig)
+	}
+	if _, exists := fileConfig["OPENAI_TEMPERATURE"]; exists {
+		t.Error("OPENAI_TEMPERATURE saved although Enter kept the default")
+	}
+
+	// An existing configuration is only changed once confirmed
+	stdin = strings.NewReader("n\n")
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := initCommand(config, nil); err != nil {
+			t.Errorf("initCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "A configuration already exists") || !strings.Contains(out, "Configuration unchanged") {
+		t.Errorf("output = %q, want the update question and no change", out)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests sent, want 1", requests)
+	}
+}
+
+// TestRequireAPIKeySuggestsInit tests that a new user is pointed to init
+func TestRequireAPIKeySuggestsInit(t *testing.T) {
+	configDir := t.TempDir()
+	config := &Config{Provider: providerOpenAI, ConfigDir: configDir}
+
+	err := requireAPIKey(config)
+	if !errors.Is(err, ErrAuth) || !strings.Contains(err.Error(), "chatgpt-cli init") {
+		t.Errorf("requireAPIKey() without a config file error = %v, want a hint to run init", err)
+	}
+
+	writeTestConfigFile(t, configDir, "model = \"gpt-4o\"\n")
+	if err := requireAPIKey(config); err == nil || strings.Contains(err.Error(), "chatgpt-cli init") {
+		t.Errorf("requireAPIKey() with a config file error = %v, want no hint to run init", err)
+	}
+}
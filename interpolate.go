@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Names of the environment variables a config file value can reference
+var envReferenceName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// expandConfigFile expands the environment variable references in the
+// values of the config file and those set for single models. It returns the
+// values that had any as written, keyed like config set takes them, and the
+// references that could not be expanded.
+func expandConfigFile(fileConfig map[string]string, modelConfigs map[string]map[string]string) (map[string]string, []configProblem) {
+	rawValues, problems := expandConfigValues(fileConfig, configSourceFile, func(key string) string { return key })
+
+	models := make([]string, 0, len(modelConfigs))
+	for model := range modelConfigs {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		raw, modelProblems := expandConfigValues(modelConfigs[model], configSourceModel, func(key string) string { return modelScopedName(model, key) })
+		for key, value := range raw {
+			rawValues[modelScopedName(model, key)] = value
+		}
+		problems = append(problems, modelProblems...)
+	}
+	return rawValues, problems
+}
+
+// expandConfigValues expands the environment variable references in the
+// values of the config file, in place, and returns the values as written
+// for those that had any, so that config list can show both. source and
+// name say where the values come from in problems: an unset variable
+// without a default expands to nothing and is a problem, which
+// CHATGPT_CLI_STRICT_CONFIG turns into an error. Problems are not reported
+// for keys the environment overrides, since their value isn't used.
+func expandConfigValues(values map[string]string, source string, name func(key string) string) (map[string]string, []configProblem) {
+	raw := make(map[string]string)
+	var problems []configProblem
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := values[key]
+		if !strings.Contains(value, "$") {
+			continue
+		}
+
+		expanded, unset, err := expandEnvReferences(value)
+		switch {
+		case err != nil:
+			// The value is kept as written, since it can't be expanded
+			expanded = value
+		case len(unset) == 1:
+			err = fmt.Errorf("environment variable %s is not set; give it a default with ${%s:-default}", unset[0], unset[0])
+		case len(unset) > 1:
+			err = fmt.Errorf("environment variables %s are not set; give them defaults with ${VAR:-default}", strings.Join(unset, ", "))
+		}
+		if err != nil && os.Getenv(key) == "" {
+			problems = append(problems, configProblem{Key: name(key), Value: value, Source: source, Error: err.Error()})
+		}
+		if expanded != value {
+			raw[key] = value
+			values[key] = expanded
+		}
+	}
+	return raw, problems
+}
+
+// expandEnvReferences expands ${VAR} and ${VAR:-default} in value from the
+// environment. The default is used when VAR is unset or empty and may hold
+// references itself. $$ stands for a literal $, and a $ followed by
+// anything else is kept as is. Variables that are unset, with no default,
+// expand to nothing and are returned.
+func expandEnvReferences(value string) (string, []string, error) {
+	var b strings.Builder
+	var unset []string
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		switch value[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := closingBrace(value, i+1)
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated reference %s", value[i:])
+			}
+			expanded, missing, err := expandEnvReference(value[i+2 : end])
+			if err != nil {
+				return "", nil, err
+			}
+			b.WriteString(expanded)
+			unset = append(unset, missing...)
+			i = end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), unset, nil
+}
+
+// expandEnvReference expands the inside of one ${...} reference
+func expandEnvReference(reference string) (string, []string, error) {
+	name, fallback, hasDefault := strings.Cut(reference, ":-")
+	if !envReferenceName.MatchString(name) {
+		return "", nil, fmt.Errorf("invalid reference ${%s}: use ${VAR} or ${VAR:-default}", reference)
+	}
+
+	value, set := os.LookupEnv(name)
+	switch {
+	case set && (value != "" || !hasDefault):
+		return value, nil, nil
+	case hasDefault:
+		return expandEnvReferences(fallback)
+	}
+	return "", []string{name}, nil
+}
+
+// closingBrace returns the index of the } closing the { at open in value,
+// skipping nested references and $$ escapes, or -1 if there is none
+func closingBrace(value string, open int) int {
+	depth := 0
+	for i := open; i < len(value); i++ {
+		switch {
+		case value[i] == '$' && i+1 < len(value) && value[i+1] == '$':
+			i++
+		case value[i] == '{':
+			depth++
+		case value[i] == '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// rawConfigValue returns the value of key in effect as written in the config
+// file, when it held references expanded from the environment
+func rawConfigValue(config *Config, key string) (string, bool) {
+	r := &configReader{file: config.fileConfig, model: config.Model, models: config.modelConfigs}
+	name := key
+	switch _, source := r.lookup(key); source {
+	case configSourceFile:
+	case configSourceModel:
+		name = modelScopedName(config.Model, key)
+	default:
+		return "", false
+	}
+	raw, ok := config.rawValues[name]
+	return raw, ok
+}
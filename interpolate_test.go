@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestExpandEnvReferences tests expanding ${VAR} and ${VAR:-default}
+func TestExpandEnvReferences(t *testing.T) {
+	t.Setenv("CHATGPT_TEST_GATEWAY", "https://gw.example.com")
+	t.Setenv("CHATGPT_TEST_EMPTY", "")
+	os.Unsetenv("CHATGPT_TEST_UNSET")
+	os.Unsetenv("CHATGPT_TEST_OTHER")
+
+	tests := []struct {
+		name      string
+		value     string
+		want      string
+		wantUnset string
+		wantErr   bool
+	}{
+		{name: "plain", value: "gpt-4o", want: "gpt-4o"},
+		{name: "reference", value: "${CHATGPT_TEST_GATEWAY}/v1/chat/completions", want: "https://gw.example.com/v1/chat/completions"},
+		{name: "default unused", value: "${CHATGPT_TEST_GATEWAY:-http://localhost}", want: "https://gw.example.com"},
+		{name: "default", value: "${CHATGPT_TEST_UNSET:-http://localhost:8080}/v1", want: "http://localhost:8080/v1"},
+		{name: "default for empty", value: "${CHATGPT_TEST_EMPTY:-fallback}", want: "fallback"},
+		{name: "empty without default", value: "a${CHATGPT_TEST_EMPTY}b", want: "ab"},
+		{name: "empty default", value: "${CHATGPT_TEST_UNSET:-}", want: ""},
+		{name: "nested default", value: "${CHATGPT_TEST_UNSET:-${CHATGPT_TEST_GATEWAY}}/v1", want: "https://gw.example.com/v1"},
+		{name: "nested twice", value: "${CHATGPT_TEST_UNSET:-${CHATGPT_TEST_OTHER:-deep}}", want: "deep"},
+		{name: "nested unset", value: "${CHATGPT_TEST_UNSET:-${CHATGPT_TEST_OTHER}}", want: "", wantUnset: "CHATGPT_TEST_OTHER"},
+		{name: "escape", value: "costs $$5 at $${HOME}", want: "costs $5 at ${HOME}"},
+		{name: "escape in default", value: "${CHATGPT_TEST_UNSET:-$$}", want: "$"},
+		{name: "lone dollar", value: "costs $5 or $", want: "costs $5 or $"},
+		{name: "unset", value: "${CHATGPT_TEST_UNSET}/v1", want: "/v1", wantUnset: "CHATGPT_TEST_UNSET"},
+		{name: "two unset", value: "${CHATGPT_TEST_UNSET}${CHATGPT_TEST_OTHER}", want: "", wantUnset: "CHATGPT_TEST_UNSET,CHATGPT_TEST_OTHER"},
+		{name: "unterminated", value: "${CHATGPT_TEST_GATEWAY/v1", wantErr: true},
+		{name: "invalid name", value: "${1ST}", wantErr: true},
+		{name: "empty name", value: "${}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unset, err := expandEnvReferences(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandEnvReferences(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want || strings.Join(unset, ",") != tt.wantUnset {
+				t.Errorf("expandEnvReferences(%q) = %q, unset %q; want %q, unset %q", tt.value, got, unset, tt.want, tt.wantUnset)
+			}
+		})
+	}
+}
+
+// TestLoadConfigInterpolation tests that loadConfig expands references in
+// the config file and records the unset variables as problems
+func TestLoadConfigInterpolation(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	t.Setenv("CHATGPT_TEST_GATEWAY", "https://gw.example.com")
+	os.Unsetenv("CHATGPT_TEST_UNSET")
+	writeTestConfigFile(t, tmpDir, `api_url = "${CHATGPT_TEST_GATEWAY}/v1/chat/completions"
+prompt_prefix = "Budget: $$100"
+org_id = "${CHATGPT_TEST_UNSET}"
+
+[models.gpt-4o]
+max_tokens = "${CHATGPT_TEST_UNSET:-512}"
+`)
+
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.APIURL != "https://gw.example.com/v1/chat/completions" || config.PromptPrefix != "Budget: $100" || config.OrgID != "" {
+		t.Errorf("config = %q, %q, %q", config.APIURL, config.PromptPrefix, config.OrgID)
+	}
+	if len(config.problems) != 1 || config.problems[0].Key != envOrgID || !strings.Contains(config.problems[0].Error, "CHATGPT_TEST_UNSET is not set") {
+		t.Errorf("problems = %+v, want the unset variable", config.problems)
+	}
+	if err := reportConfigProblems(config, true); err == nil || !strings.Contains(err.Error(), `OPENAI_ORG_ID="${CHATGPT_TEST_UNSET}"`) {
+		t.Errorf("strict error = %v, want the raw value", err)
+	}
+
+	useModel(config, "gpt-4o")
+	if config.MaxTokens != 512 {
+		t.Errorf("MaxTokens = %d, want the model value's default", config.MaxTokens)
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := configListCommand(config, nil); err != nil {
+			t.Errorf("configListCommand() error = %v", err)
+		}
+	})
+	for _, want := range []string{
+		"https://gw.example.com/v1/chat/completions (expanded from ${CHATGPT_TEST_GATEWAY}/v1/chat/completions)",
+		"512 (model gpt-4o) (expanded from ${CHATGPT_TEST_UNSET:-512})",
+		"Budget: $100 (expanded from Budget: $$100)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("config list output = %q, want %q", out, want)
+		}
+	}
+
+	// Values the environment overrides are neither reported nor shown raw
+	setTestEnv(envOrgID, "org-env")
+	setTestEnv(envAPIURL, "https://api.example.com")
+	if config, err = loadConfig(""); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if len(config.problems) != 0 {
+		t.Errorf("problems = %+v, want none for overridden keys", config.problems)
+	}
+	if _, ok := rawConfigValue(config, envAPIURL); ok {
+		t.Errorf("rawConfigValue(%s) found a raw value for an overridden key", envAPIURL)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ResponseFormat asks the API for a specific kind of reply
+type ResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// Response format type that guarantees a JSON object reply
+const responseFormatJSONObject = "json_object"
+
+// Model families known to support the json_object response format. Dated
+// variants such as gpt-4o-2024-08-06 match their family.
+var jsonResponseModels = []string{
+	"gpt-3.5-turbo",
+	"gpt-4-turbo",
+	"gpt-4-1106-preview",
+	"gpt-4-0125-preview",
+	"gpt-4o",
+	"gpt-4.1",
+	"gpt-5",
+	"o1",
+	"o3",
+	"o4-mini",
+}
+
+// supportsJSONResponse reports whether the model is known to support the
+// json_object response format
+func supportsJSONResponse(model string) bool {
+	for _, name := range jsonResponseModels {
+		if model == name || strings.HasPrefix(model, name+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// formatJSONResponse checks that a reply is valid JSON and pretty-prints it,
+// or returns it unchanged if raw is true
+func formatJSONResponse(content string, raw bool) (string, error) {
+	content = strings.TrimSpace(content)
+	if !json.Valid([]byte(content)) {
+		return "", fmt.Errorf("response is not valid JSON")
+	}
+	if raw {
+		return content, nil
+	}
+
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(content), "", "  "); err != nil {
+		return "", fmt.Errorf("failed to format JSON response: %w", err)
+	}
+	return b.String(), nil
+}
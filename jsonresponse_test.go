@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSupportsJSONResponse tests model matching for the json_object format
+func TestSupportsJSONResponse(t *testing.T) {
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"gpt-4o", true},
+		{"gpt-4o-mini", true},
+		{"gpt-4o-2024-08-06", true},
+		{"gpt-3.5-turbo", true},
+		{"gpt-4-turbo-preview", true},
+		{"gpt-4", false},
+		{"gpt-4-0613", false},
+		{"gpt-4ox", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := supportsJSONResponse(tt.model); got != tt.want {
+				t.Errorf("supportsJSONResponse(%q) = %v, want %v", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFormatJSONResponse tests validation and pretty-printing of JSON replies
+func TestFormatJSONResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		raw     bool
+		want    string
+		wantErr bool
+	}{
+		{name: "pretty", content: `{"a":1,"b":[true]}`, want: "{\n  \"a\": 1,\n  \"b\": [\n    true\n  ]\n}"},
+		{name: "raw", content: ` {"a":1} `, raw: true, want: `{"a":1}`},
+		{name: "invalid", content: "Sure! Here is your JSON", wantErr: true},
+		{name: "truncated", content: `{"a":`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatJSONResponse(tt.content, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatJSONResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("formatJSONResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPromptCommandJSONResponse tests the request field and handling of valid
+// and invalid replies
+func TestPromptCommandJSONResponse(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tests := []struct {
+		name       string
+		reply      string
+		args       []string
+		wantErr    bool
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "valid reply is pretty-printed",
+			reply:      `{"answer":42}`,
+			args:       []string{"--json-response", "reply in JSON"},
+			wantStdout: "{\n  \"answer\": 42\n}\n",
+		},
+		{
+			name:       "raw keeps the reply as sent",
+			reply:      `{"answer":42}`,
+			args:       []string{"--json-response", "--raw", "reply in JSON"},
+			wantStdout: "{\"answer\":42}\n",
+		},
+		{
+			name:       "invalid reply fails with the body on stderr",
+			reply:      "not json",
+			args:       []string{"--json-response", "reply in JSON"},
+			wantErr:    true,
+			wantStderr: "not json\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request ChatRequest
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+				}
+				if request.ResponseFormat == nil || request.ResponseFormat.Type != "json_object" {
+					t.Errorf("request.ResponseFormat = %+v, want json_object", request.ResponseFormat)
+				}
+				if request.Stream {
+					t.Errorf("request.Stream = true, want false")
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(ChatResponse{
+					Choices: []Choice{{Message: Message{Content: tt.reply}}},
+				})
+			}))
+			defer server.Close()
+
+			tmpDir := t.TempDir()
+			config := &Config{
+				APIKey:    "test-key",
+				APIURL:    server.URL,
+				Model:     "gpt-4o",
+				Timeout:   10 * time.Second,
+				Stream:    true,
+				ConfigDir: tmpDir,
+			}
+
+			var err error
+			var stdout string
+			stderr := captureOutput(t, &os.Stderr, func() {
+				stdout = captureOutput(t, &os.Stdout, func() {
+					err = promptCommand(config, tt.args)
+				})
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("promptCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stdout != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout, tt.wantStdout)
+			}
+			if tt.wantStderr != "" && stderr != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantStderr)
+			}
+
+			// The reply is logged as received, with the error if it was invalid
+			data, err := os.ReadFile(filepath.Join(tmpDir, "logs.jsonl"))
+			if err != nil {
+				t.Fatalf("failed to read log file: %v", err)
+			}
+			var entry LogEntry
+			if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &entry); err != nil {
+				t.Fatalf("failed to parse log entry: %v", err)
+			}
+			if entry.Response != tt.reply {
+				t.Errorf("entry.Response = %q, want %q", entry.Response, tt.reply)
+			}
+			if (entry.Error != "") != tt.wantErr {
+				t.Errorf("entry.Error = %q, wantErr %v", entry.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPromptCommandJSONResponseWarning tests the warning for unsupported models
+func TestPromptCommandJSONResponseWarning(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	config := &Config{
+		Model:     "gpt-4",
+		Timeout:   10 * time.Second,
+		ConfigDir: t.TempDir(),
+	}
+
+	var err error
+	stderr := captureOutput(t, &os.Stderr, func() {
+		captureOutput(t, &os.Stdout, func() {
+			err = promptCommand(config, []string{"--json-response", "--dry-run", "reply in JSON"})
+		})
+	})
+	if err != nil {
+		t.Fatalf("promptCommand() error = %v", err)
+	}
+	if !strings.Contains(stderr, "model gpt-4 may not support --json-response") {
+		t.Errorf("stderr = %q, want a warning", stderr)
+	}
+}
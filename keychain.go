@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Service name the API key is stored under in the OS credential store
+const keychainService = "chatgpt-cli"
+
+// Name of the file used when the OS has no credential store available
+const credentialsFileName = "credentials.json"
+
+var errCredentialNotFound = errors.New("no API key stored")
+
+// credentialStore keeps API keys outside the config file, one per account.
+// The account is the profile name.
+type credentialStore interface {
+	// Name describes the store to users, e.g. "macOS Keychain"
+	Name() string
+	// Get returns errCredentialNotFound when no key is stored
+	Get(account string) (string, error)
+	Set(account, apiKey string) error
+	// Delete returns errCredentialNotFound when no key is stored
+	Delete(account string) error
+}
+
+// newCredentialStore returns the OS credential store, or a file in the config
+// directory when the platform has none available
+func newCredentialStore(configDir string) credentialStore {
+	if store, ok := platformCredentialStore(); ok {
+		return store
+	}
+	return fileCredentialStore{path: filepath.Join(configDir, credentialsFileName)}
+}
+
+// fileCredentialStore keeps API keys in a JSON file only the owner can read
+type fileCredentialStore struct {
+	path string
+}
+
+func (s fileCredentialStore) Name() string {
+	return "credentials file"
+}
+
+func (s fileCredentialStore) Get(account string) (string, error) {
+	keys, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	apiKey, exists := keys[account]
+	if !exists {
+		return "", errCredentialNotFound
+	}
+	return apiKey, nil
+}
+
+func (s fileCredentialStore) Set(account, apiKey string) error {
+	keys, err := s.load()
+	if err != nil {
+		return err
+	}
+	keys[account] = apiKey
+	return s.save(keys)
+}
+
+func (s fileCredentialStore) Delete(account string) error {
+	keys, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, exists := keys[account]; !exists {
+		return errCredentialNotFound
+	}
+	delete(keys, account)
+
+	if len(keys) == 0 {
+		return os.Remove(s.path)
+	}
+	return s.save(keys)
+}
+
+func (s fileCredentialStore) load() (map[string]string, error) {
+	keys := make(map[string]string)
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s fileCredentialStore) save(keys map[string]string) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(data, '\n'), 0600)
+}
@@ -0,0 +1,75 @@
+//go:build darwin
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// Exit status of the security tool when no matching item exists
+const securityItemNotFound = 44
+
+// platformCredentialStore returns the macOS Keychain, used through the
+// security command line tool
+var platformCredentialStore = func() (credentialStore, bool) {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil, false
+	}
+	return macKeychain{}, true
+}
+
+// macKeychain stores API keys as generic passwords in the login keychain
+type macKeychain struct{}
+
+func (macKeychain) Name() string {
+	return "macOS Keychain"
+}
+
+func (macKeychain) Get(account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	if err != nil {
+		return "", securityError(err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (macKeychain) Set(account, apiKey string) error {
+	return securityError(securitySetCommand(account, apiKey).Run())
+}
+
+// securitySetCommand builds the command that stores apiKey. The security
+// tool only takes the password as an argument, so the command is sent to
+// its interactive mode on stdin to keep the key out of the process list.
+// -U updates the item if it already exists.
+func securitySetCommand(account, apiKey string) *exec.Cmd {
+	line := strings.Join([]string{"add-generic-password", "-U",
+		"-s", securityQuote(keychainService), "-a", securityQuote(account), "-w", securityQuote(apiKey)}, " ")
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(line + "\n")
+	return cmd
+}
+
+// securityQuote quotes an argument for the security tool's interactive
+// mode, which splits lines on spaces and honours double quotes and
+// backslash escapes
+func securityQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func (macKeychain) Delete(account string) error {
+	return securityError(exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", account).Run())
+}
+
+// securityError maps the security tool's "not found" status to
+// errCredentialNotFound
+func securityError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityItemNotFound {
+		return errCredentialNotFound
+	}
+	return err
+}
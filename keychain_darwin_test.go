@@ -0,0 +1,34 @@
+//go:build darwin
+
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSecuritySetCommandKeepsKeyOutOfArgv(t *testing.T) {
+	const apiKey = "sk-test-secret"
+	cmd := securitySetCommand("default", apiKey)
+
+	for _, arg := range cmd.Args {
+		if strings.Contains(arg, apiKey) {
+			t.Fatalf("API key appears in argv: %q", cmd.Args)
+		}
+	}
+	stdin, err := io.ReadAll(cmd.Stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `add-generic-password -U -s "` + keychainService + `" -a "default" -w "sk-test-secret"` + "\n"
+	if string(stdin) != want {
+		t.Errorf("stdin = %q, want %q", stdin, want)
+	}
+}
+
+func TestSecurityQuote(t *testing.T) {
+	if got := securityQuote(`a "b" \c`); got != `"a \"b\" \\c"` {
+		t.Errorf("securityQuote = %s", got)
+	}
+}
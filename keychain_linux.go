@@ -0,0 +1,55 @@
+//go:build linux
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// platformCredentialStore returns the freedesktop secret service (GNOME
+// Keyring, KWallet), used through the secret-tool command line tool
+var platformCredentialStore = func() (credentialStore, bool) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil, false
+	}
+	return secretService{}, true
+}
+
+// secretService stores API keys as secrets labelled with the service and
+// account attributes
+type secretService struct{}
+
+func (secretService) Name() string {
+	return "secret service"
+}
+
+func (secretService) Get(account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", account).Output()
+	// secret-tool exits with status 1 and no output when nothing matches
+	if len(out) == 0 {
+		if _, ok := err.(*exec.ExitError); ok || err == nil {
+			return "", errCredentialNotFound
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (secretService) Set(account, apiKey string) error {
+	// The secret is read from stdin so it never shows up in the process list
+	cmd := exec.Command("secret-tool", "store", "--label", keychainService+" API key ("+account+")",
+		"service", keychainService, "account", account)
+	cmd.Stdin = strings.NewReader(apiKey)
+	return cmd.Run()
+}
+
+func (s secretService) Delete(account string) error {
+	// clear succeeds whether or not anything matched, so look first
+	if _, err := s.Get(account); err != nil {
+		return err
+	}
+	return exec.Command("secret-tool", "clear", "service", keychainService, "account", account).Run()
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !linux && !windows
+
+package main
+
+// platformCredentialStore reports that no OS credential store is supported,
+// so API keys go to the credentials file
+var platformCredentialStore = func() (credentialStore, bool) {
+	return nil, false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileCredentialStore tests the credentials file used without an OS
+// credential store
+func TestFileCredentialStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := fileCredentialStore{path: filepath.Join(tmpDir, credentialsFileName)}
+
+	if _, err := store.Get("default"); !errors.Is(err, errCredentialNotFound) {
+		t.Errorf("Get() on a missing file error = %v, want %v", err, errCredentialNotFound)
+	}
+
+	if err := store.Set("default", "sk-default"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := store.Set("work", "sk-work"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	info, err := os.Stat(store.path)
+	if err != nil {
+		t.Fatalf("credentials file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("credentials file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if apiKey, err := store.Get("work"); err != nil || apiKey != "sk-work" {
+		t.Errorf("Get(work) = %q, %v, want %q", apiKey, err, "sk-work")
+	}
+
+	if err := store.Delete("work"); err != nil {
+		t.Errorf("Delete(work) error = %v", err)
+	}
+	if err := store.Delete("work"); !errors.Is(err, errCredentialNotFound) {
+		t.Errorf("Delete(work) twice error = %v, want %v", err, errCredentialNotFound)
+	}
+	if apiKey, err := store.Get("default"); err != nil || apiKey != "sk-default" {
+		t.Errorf("Get(default) = %q, %v, want %q", apiKey, err, "sk-default")
+	}
+
+	// The file goes away with the last key
+	if err := store.Delete("default"); err != nil {
+		t.Errorf("Delete(default) error = %v", err)
+	}
+	if _, err := os.Stat(store.path); !os.IsNotExist(err) {
+		t.Errorf("credentials file should be removed once empty, stat error = %v", err)
+	}
+}
+
+// TestNewCredentialStore tests the fallback to the credentials file
+func TestNewCredentialStore(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	store, ok := newCredentialStore(tmpDir).(fileCredentialStore)
+	if !ok {
+		t.Fatalf("newCredentialStore() = %T, want fileCredentialStore", newCredentialStore(tmpDir))
+	}
+	if store.path != filepath.Join(tmpDir, credentialsFileName) {
+		t.Errorf("credentials file path = %s, want it in the config directory", store.path)
+	}
+}
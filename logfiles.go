@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Name of the active log file inside the config directory. Rotated files
+// get a numeric suffix, with logs.jsonl.1 being the most recent.
+const logFileName = "logs.jsonl"
+
+// Set once a log write has failed, so that a run warns about it only once
+var logWriteFailed atomic.Bool
+
+// warnLogError warns on stderr that the log could not be written. Only the
+// first failure is reported; the command itself carries on.
+func warnLogError(config *Config, err error) {
+	if err == nil || !logWriteFailed.CompareAndSwap(false, true) {
+		return
+	}
+	errOut := newUI(config, os.Stderr)
+	errOut.Printf("%s could not write log: %v\n", errOut.yellow("Warning:"), err)
+}
+
+// rotatedLogFile returns the path of the n-th rotated log file
+func rotatedLogFile(configDir string, n int) string {
+	return filepath.Join(configDir, fmt.Sprintf("%s.%d", logFileName, n))
+}
+
+// listLogFiles returns the existing log files, oldest first: rotated files in
+// descending order of their suffix followed by the active log file
+func listLogFiles(configDir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(configDir, logFileName+".*"))
+	if err != nil {
+		return nil, err
+	}
+
+	type rotated struct {
+		path string
+		n    int
+	}
+
+	var files []rotated
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(filepath.Ext(match), "."))
+		if err != nil || n < 1 {
+			continue
+		}
+		files = append(files, rotated{path: match, n: n})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].n > files[j].n
+	})
+
+	var logFiles []string
+	for _, f := range files {
+		logFiles = append(logFiles, f.path)
+	}
+
+	active := filepath.Join(configDir, logFileName)
+	if _, err := os.Stat(active); err == nil {
+		logFiles = append(logFiles, active)
+	}
+
+	return logFiles, nil
+}
+
+// rotateLogsIfNeeded rotates the active log file if appending incoming bytes
+// would exceed the configured maximum size. Rotated files are shifted up by
+// one and anything beyond the configured number of files is deleted.
+// A maximum size of zero disables rotation.
+func rotateLogsIfNeeded(config *Config, incoming int64) error {
+	if config.LogMaxSize <= 0 {
+		return nil
+	}
+
+	active := filepath.Join(config.ConfigDir, logFileName)
+
+	info, err := os.Stat(active)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Never rotate an empty file, even if a single entry exceeds the cap
+	if info.Size() == 0 || info.Size()+incoming <= config.LogMaxSize {
+		return nil
+	}
+
+	if config.LogMaxFiles <= 0 {
+		return os.Remove(active)
+	}
+
+	// Drop the oldest file, then shift the rest up by one
+	if err := os.Remove(rotatedLogFile(config.ConfigDir, config.LogMaxFiles)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for n := config.LogMaxFiles - 1; n >= 1; n-- {
+		err := os.Rename(rotatedLogFile(config.ConfigDir, n), rotatedLogFile(config.ConfigDir, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(active, rotatedLogFile(config.ConfigDir, 1))
+}
+
+// logsClearCommand deletes the rotated log files and truncates the active one
+func logsClearCommand(config *Config, args []string) error {
+	out := newUI(config, os.Stdout)
+	fs := flag.NewFlagSet("logs clear", flag.ContinueOnError)
+	force := fs.Bool("force", false, "skip the confirmation prompt")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli logs clear [--force]", err)
+	}
+
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to list logs: %w", err)
+	}
+	if len(logFiles) == 0 {
+		out.Println("No logs found.")
+		return nil
+	}
+
+	if !*force && !confirm(fmt.Sprintf("Delete all logs in %d file(s)?", len(logFiles))) {
+		out.Println("Clear cancelled")
+		return nil
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	active := filepath.Join(config.ConfigDir, logFileName)
+	for _, logFile := range logFiles {
+		if logFile == active {
+			err = os.Truncate(logFile, 0)
+		} else {
+			err = os.Remove(logFile)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to clear logs: %w", err)
+		}
+	}
+
+	out.Printf("Cleared %d log file(s)\n", len(logFiles))
+	return nil
+}
+
+// logsRepairCommand rewrites the log files without the lines that are not
+// valid log entries. Each rewritten file is first copied to <file>.bak.
+func logsRepairCommand(config *Config, args []string) error {
+	out := newUI(config, os.Stdout)
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli logs repair", args[0])
+	}
+
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to list logs: %w", err)
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	repaired := 0
+	for _, logFile := range logFiles {
+		removed, err := repairLogFile(logFile)
+		if err != nil {
+			return fmt.Errorf("failed to repair %s: %w", filepath.Base(logFile), err)
+		}
+		if removed > 0 {
+			out.Printf("Removed %d corrupt line(s) from %s (backup: %s)\n", removed, filepath.Base(logFile), filepath.Base(logFile)+".bak")
+			repaired++
+		}
+	}
+
+	if repaired == 0 {
+		out.Println("No corrupt log entries found.")
+	}
+	return nil
+}
+
+// repairLogFile keeps the valid lines of logFile and returns how many lines
+// were dropped. Lines too long for the logs command to read count as corrupt.
+// The file is left untouched when every line is valid.
+func repairLogFile(logFile string) (int, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var kept bytes.Buffer
+	removed := 0
+
+	// A bufio.Reader is used rather than a Scanner so lines of any length can
+	// be dropped
+	reader := bufio.NewReader(f)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return 0, readErr
+		}
+
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 {
+			var entry LogEntry
+			if len(trimmed) > maxLogLineSize || json.Unmarshal(trimmed, &entry) != nil {
+				removed++
+			} else {
+				kept.Write(trimmed)
+				kept.WriteByte('\n')
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// Windows cannot rename a file that is still open
+	f.Close()
+
+	// Write the repaired copy next to the original before swapping them, so
+	// an interrupted repair never loses the log
+	tmp := logFile + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(logFile, logFile+".bak"); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, logFile); err != nil {
+		return 0, err
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLogRotation tests that a tiny max size forces rotation
+func TestLogRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &Config{
+		ConfigDir:   tmpDir,
+		LogMaxSize:  10,
+		LogMaxFiles: 2,
+	}
+
+	for i := 0; i < 5; i++ {
+		logEntry(config, "prompt", string(rune('a'+i)), "response", "")
+	}
+
+	logFiles, err := listLogFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("listLogFiles() error = %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(tmpDir, "logs.jsonl.2"),
+		filepath.Join(tmpDir, "logs.jsonl.1"),
+		filepath.Join(tmpDir, "logs.jsonl"),
+	}
+	if strings.Join(logFiles, ",") != strings.Join(expected, ",") {
+		t.Fatalf("listLogFiles() = %v, want %v", logFiles, expected)
+	}
+
+	// Only the newest entries survive, in chronological order
+	entries, _, err := readLogEntries(logFiles, logFilter{}, 0)
+	if err != nil {
+		t.Fatalf("readLogEntries() error = %v", err)
+	}
+
+	var prompts []string
+	for _, entry := range entries {
+		prompts = append(prompts, entry.Prompt)
+	}
+	if strings.Join(prompts, "") != "cde" {
+		t.Errorf("prompts = %v, want [c d e]", prompts)
+	}
+}
+
+// TestLogRotationDisabled tests that a zero max size never rotates
+func TestLogRotationDisabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &Config{
+		ConfigDir:   tmpDir,
+		LogMaxSize:  0,
+		LogMaxFiles: 3,
+	}
+
+	for i := 0; i < 3; i++ {
+		logEntry(config, "prompt", "test", "response", "")
+	}
+
+	logFiles, err := listLogFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("listLogFiles() error = %v", err)
+	}
+	if len(logFiles) != 1 {
+		t.Errorf("listLogFiles() = %v, want only the active file", logFiles)
+	}
+}
+
+// TestLogRotationNoBackups tests that zero max files discards old entries
+func TestLogRotationNoBackups(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &Config{
+		ConfigDir:   tmpDir,
+		LogMaxSize:  10,
+		LogMaxFiles: 0,
+	}
+
+	logEntry(config, "prompt", "first", "response", "")
+	logEntry(config, "prompt", "second", "response", "")
+
+	entries, _, err := readLogEntries([]string{filepath.Join(tmpDir, "logs.jsonl")}, logFilter{}, 0)
+	if err != nil {
+		t.Fatalf("readLogEntries() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Prompt != "second" {
+		t.Errorf("entries = %+v, want only the second entry", entries)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "logs.jsonl.1")); !os.IsNotExist(err) {
+		t.Errorf("logs.jsonl.1 should not exist")
+	}
+}
+
+// TestLogsClearCommand tests clearing logs with and without confirmation
+func TestLogsClearCommand(t *testing.T) {
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	tests := []struct {
+		name        string
+		args        []string
+		input       string
+		wantCleared bool
+	}{
+		{"force", []string{"--force"}, "", true},
+		{"confirmed", []string{}, "y\n", true},
+		{"declined", []string{}, "n\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			config := &Config{ConfigDir: tmpDir}
+
+			entry := LogEntry{Timestamp: time.Now(), Command: "prompt", Prompt: "test"}
+			writeTestLogs(t, filepath.Join(tmpDir, "logs.jsonl"), []LogEntry{entry})
+			writeTestLogs(t, filepath.Join(tmpDir, "logs.jsonl.1"), []LogEntry{entry})
+
+			stdin = strings.NewReader(tt.input)
+
+			if err := logsCommand(config, append([]string{"clear"}, tt.args...)); err != nil {
+				t.Fatalf("logsCommand(clear) error = %v", err)
+			}
+
+			_, err := os.Stat(filepath.Join(tmpDir, "logs.jsonl.1"))
+			if rotatedGone := os.IsNotExist(err); rotatedGone != tt.wantCleared {
+				t.Errorf("rotated file removed = %t, want %t", rotatedGone, tt.wantCleared)
+			}
+
+			info, err := os.Stat(filepath.Join(tmpDir, "logs.jsonl"))
+			if err != nil {
+				t.Fatalf("active log file should still exist: %v", err)
+			}
+			if empty := info.Size() == 0; empty != tt.wantCleared {
+				t.Errorf("active file truncated = %t, want %t", empty, tt.wantCleared)
+			}
+		})
+	}
+}
+
+// TestLogsRepairCommand tests that repair drops corrupt lines, keeps long
+// valid ones and backs up the original file
+func TestLogsRepairCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &Config{ConfigDir: tmpDir}
+	logFile := filepath.Join(tmpDir, "logs.jsonl")
+
+	// A response longer than the default 64KB scanner buffer must survive
+	long := strings.Repeat("x", 100*1024)
+	writeTestLogs(t, logFile, []LogEntry{
+		{Timestamp: time.Now(), Command: "prompt", Prompt: "first", Response: long},
+		{Timestamp: time.Now(), Command: "prompt", Prompt: "second"},
+	})
+	original, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	lines := strings.SplitAfter(string(original), "\n")
+	corrupt := lines[0] + "{\"timestamp\": \"trunc\n" + "not json\n" + lines[1]
+	if err := os.WriteFile(logFile, []byte(corrupt), 0644); err != nil {
+		t.Fatalf("failed to write log file: %v", err)
+	}
+
+	entries, skipped, err := readLogEntries([]string{logFile}, logFilter{}, 0)
+	if err != nil || len(entries) != 2 || skipped != 2 {
+		t.Fatalf("readLogEntries() = %d entries, %d skipped, %v, want 2 and 2", len(entries), skipped, err)
+	}
+
+	stderr := captureOutput(t, &os.Stderr, func() {
+		captureOutput(t, &os.Stdout, func() {
+			if err := logsCommand(config, []string{}); err != nil {
+				t.Errorf("logsCommand() error = %v", err)
+			}
+		})
+	})
+	if !strings.Contains(stderr, "2 log entries could not be parsed, run 'chatgpt-cli logs repair'") {
+		t.Errorf("logs stderr = %q, want the corrupt line count", stderr)
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"repair"}); err != nil {
+			t.Errorf("logsCommand(repair) error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Removed 2 corrupt line(s) from logs.jsonl (backup: logs.jsonl.bak)") {
+		t.Errorf("logs repair output = %q", out)
+	}
+
+	repaired, err := os.ReadFile(logFile)
+	if err != nil || string(repaired) != string(original) {
+		t.Errorf("repaired log differs from the valid lines: %v", err)
+	}
+	backup, err := os.ReadFile(logFile + ".bak")
+	if err != nil || string(backup) != corrupt {
+		t.Errorf("backup differs from the original file: %v", err)
+	}
+
+	// The backup is not read as a rotated log file
+	logFiles, err := listLogFiles(tmpDir)
+	if err != nil || len(logFiles) != 1 {
+		t.Errorf("listLogFiles() = %v, %v, want only the active file", logFiles, err)
+	}
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"repair"}); err != nil {
+			t.Errorf("logsCommand(repair) error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "No corrupt log entries found.") {
+		t.Errorf("second logs repair output = %q", out)
+	}
+
+	if err := logsCommand(config, []string{"repair", "extra"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("logsCommand(repair extra) error = %v, want a usage error", err)
+	}
+}
+
+// TestRepairLogFileOverlongLine tests that a line too long for the logs
+// command to read is dropped by repair
+func TestRepairLogFileOverlongLine(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "logs.jsonl")
+	huge := `{"command":"prompt","response":"` + strings.Repeat("x", maxLogLineSize) + `"}`
+	if err := os.WriteFile(logFile, []byte(huge+"\n"+`{"command":"config"}`+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write log file: %v", err)
+	}
+
+	if _, _, err := readLogEntries([]string{logFile}, logFilter{}, 0); err == nil || !strings.Contains(err.Error(), "logs repair") {
+		t.Errorf("readLogEntries() error = %v, want a pointer to logs repair", err)
+	}
+
+	removed, err := repairLogFile(logFile)
+	if err != nil || removed != 1 {
+		t.Fatalf("repairLogFile() = %d, %v, want 1 line removed", removed, err)
+	}
+	entries, _, err := readLogEntries([]string{logFile}, logFilter{}, 0)
+	if err != nil || len(entries) != 1 || entries[0].Command != "config" {
+		t.Errorf("entries after repair = %+v, %v, want the config entry", entries, err)
+	}
+}
+
+// TestParseSize tests parsing byte sizes with optional units
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"1048576", 1048576, false},
+		{"5MB", 5 * 1024 * 1024, false},
+		{"512kb", 512 * 1024, false},
+		{"1G", 1024 * 1024 * 1024, false},
+		{"100B", 100, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"five", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize(%q) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if result != tt.expected {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFormatSize tests formatting byte sizes
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		input    int64
+		expected string
+	}{
+		{5 * 1024 * 1024, "5MB"},
+		{512 * 1024, "512KB"},
+		{2 * 1024 * 1024 * 1024, "2GB"},
+		{1500, "1500"},
+		{0, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := formatSize(tt.input); result != tt.expected {
+				t.Errorf("formatSize(%d) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestConcurrentLogWrites tests that entries logged at the same time each
+// end up on a line of their own
+func TestConcurrentLogWrites(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &Config{ConfigDir: tmpDir}
+
+	const writers, perWriter = 8, 25
+	long := strings.Repeat("x", 4096)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := logEntry(config, "prompt", fmt.Sprintf("%d-%d", w, i), long, ""); err != nil {
+					t.Errorf("logEntry() error = %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, logFileName))
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != writers*perWriter {
+		t.Fatalf("%d lines logged, want %d", len(lines), writers*perWriter)
+	}
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Response != long {
+			t.Fatalf("line %.40q... is not a whole entry: %v", line, err)
+		}
+		seen[entry.Prompt] = true
+	}
+	if len(seen) != writers*perWriter {
+		t.Errorf("%d distinct entries logged, want %d", len(seen), writers*perWriter)
+	}
+}
+
+// TestLogWriteFailureWarning tests that a failed write is reported once
+func TestLogWriteFailureWarning(t *testing.T) {
+	defer logWriteFailed.Store(false)
+
+	// A file in place of the config directory makes every write fail
+	notADir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notADir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{ConfigDir: notADir}
+
+	out := captureOutput(t, &os.Stderr, func() {
+		for i := 0; i < 3; i++ {
+			err := logEntry(config, "prompt", "lost", "", "")
+			if err == nil {
+				t.Fatal("logEntry() error = nil, want a failure")
+			}
+			warnLogError(config, err)
+		}
+		warnLogError(config, nil)
+	})
+	if strings.Count(out, "could not write log:") != 1 || !strings.HasPrefix(out, "Warning: could not write log: ") {
+		t.Errorf("stderr = %q, want a single warning", out)
+	}
+}
+
+// TestLogDisabled tests that nothing is logged with CHATGPT_CLI_LOG_DISABLED
+func TestLogDisabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &Config{ConfigDir: tmpDir, LogDisabled: true}
+
+	if err := logEntry(config, "prompt", "secret", "response", ""); err != nil {
+		t.Errorf("logEntry() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, logFileName)); !os.IsNotExist(err) {
+		t.Errorf("log file written although logging is disabled")
+	}
+}
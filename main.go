@@ -1,61 +1,281 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
+// version is the CLI version, recorded in exports and compared with the
+// latest release by update. Release builds set it with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
 // Environment variable names
 const (
-	envAPIKey      = "OPENAI_API_KEY"
-	envAPIURL      = "OPENAI_API_URL"
-	envModel       = "OPENAI_MODEL"
-	envTimeout     = "OPENAI_TIMEOUT"
-	envMaxTokens   = "OPENAI_MAX_TOKENS"
-	envTemperature = "OPENAI_TEMPERATURE"
-	envConfigDir   = "CHATGPT_CLI_CONFIG_DIR"
+	envAPIKey            = "OPENAI_API_KEY"
+	envAnthropicAPIKey   = "ANTHROPIC_API_KEY"
+	envAPIURL            = "OPENAI_API_URL"
+	envModel             = "OPENAI_MODEL"
+	envTimeout           = "OPENAI_TIMEOUT"
+	envMaxTokens         = "OPENAI_MAX_TOKENS"
+	envTemperature       = "OPENAI_TEMPERATURE"
+	envTopP              = "OPENAI_TOP_P"
+	envPresencePenalty   = "OPENAI_PRESENCE_PENALTY"
+	envFrequencyPenalty  = "OPENAI_FREQUENCY_PENALTY"
+	envStop              = "OPENAI_STOP"
+	envSeed              = "OPENAI_SEED"
+	envStream            = "OPENAI_STREAM"
+	envShowUsage         = "OPENAI_SHOW_USAGE"
+	envModelsURL         = "OPENAI_MODELS_URL"
+	envOutput            = "CHATGPT_CLI_OUTPUT"
+	envNoColor           = "CHATGPT_CLI_NO_COLOR"
+	envProvider          = "OPENAI_PROVIDER"
+	envAzureAPIVersion   = "AZURE_API_VERSION"
+	envOrgID             = "OPENAI_ORG_ID"
+	envProjectID         = "OPENAI_PROJECT_ID"
+	envLogMaxSize        = "CHATGPT_CLI_LOG_MAX_SIZE"
+	envLogMaxFiles       = "CHATGPT_CLI_LOG_MAX_FILES"
+	envMaxFileSize       = "CHATGPT_CLI_MAX_FILE_SIZE"
+	envLogFullPrompt     = "CHATGPT_CLI_LOG_FULL_PROMPT"
+	envTiming            = "CHATGPT_CLI_TIMING"
+	envToolDomains       = "CHATGPT_CLI_TOOL_DOMAINS"
+	envReasoningModels   = "OPENAI_REASONING_MODELS"
+	envLogDisabled       = "CHATGPT_CLI_LOG_DISABLED"
+	envPromptPrefix      = "OPENAI_PROMPT_PREFIX"
+	envPromptSuffix      = "OPENAI_PROMPT_SUFFIX"
+	envRequestsPerMinute = "OPENAI_REQUESTS_PER_MINUTE"
+	envExtraHeaders      = "OPENAI_EXTRA_HEADERS"
+	envEmbedModel        = "OPENAI_EMBED_MODEL"
+	envConnectTimeout    = "OPENAI_CONNECT_TIMEOUT"
+	envImageModel        = "OPENAI_IMAGE_MODEL"
+	envTranscribeModel   = "OPENAI_TRANSCRIBE_MODEL"
+	envTranscribeTimeout = "OPENAI_TRANSCRIBE_TIMEOUT"
+	envModerate          = "CHATGPT_CLI_MODERATE"
+	envRedact            = "CHATGPT_CLI_REDACT"
+	envNotify            = "CHATGPT_CLI_NOTIFY"
+	envNotifyAfter       = "CHATGPT_CLI_NOTIFY_AFTER"
+	envURLMaxChars       = "CHATGPT_CLI_URL_MAX_CHARS"
+	envLogPreviewLen     = "CHATGPT_CLI_LOG_PREVIEW_LEN"
+	envMaxResponseBytes  = "OPENAI_MAX_RESPONSE_BYTES"
+	envTimeFormat        = "CHATGPT_CLI_TIME_FORMAT"
+	envAutoTrim          = "CHATGPT_CLI_AUTO_TRIM"
+	envRequestTemplate   = "OPENAI_REQUEST_TEMPLATE"
+	envResponseJSONPath  = "OPENAI_RESPONSE_JSONPATH"
+	envProfile           = "CHATGPT_CLI_PROFILE"
+	envConfigDir         = "CHATGPT_CLI_CONFIG_DIR"
 )
 
 // Default configuration values
 const (
-	defaultAPIURL      = "https://api.openai.com/v1/chat/completions"
-	defaultModel       = "gpt-3.5-turbo"
-	defaultTimeout     = 60 * time.Second
-	defaultMaxTokens   = 1000
-	defaultTemperature = 0.7
+	defaultAPIURL         = "https://api.openai.com/v1/chat/completions"
+	defaultModel          = "gpt-3.5-turbo"
+	defaultTimeout        = 60 * time.Second
+	defaultConnectTimeout = 10 * time.Second
+	defaultMaxTokens      = 1000
+	defaultTemperature    = 0.7
+	defaultStream         = true
+	defaultShowUsage      = false
+	defaultOutput         = outputPlain
+	defaultNoColor        = false
+	defaultProvider       = providerOpenAI
+	defaultLogMaxSize     = 5 * 1024 * 1024
+	defaultLogMaxFiles    = 3
+	defaultMaxFileSize    = 1024 * 1024
+	defaultLogFullPrompt  = false
+	defaultTiming         = false
+	defaultLogDisabled    = false
+	defaultModerate       = false
+	defaultRedact         = true
+	defaultNotify         = false
+	defaultNotifyAfter    = 5 * time.Second
+	defaultURLMaxChars    = 20000
+	defaultLogPreviewLen  = 200
+	// Largest API response read, once decompressed
+	defaultMaxResponseBytes = 10 * 1024 * 1024
+	defaultAutoTrim         = false
 )
 
+// Input used for interactive confirmations
+var stdin io.Reader = os.Stdin
+
+// Serializes writes to the log file
+var logMu sync.Mutex
+
 // Application configuration
 type Config struct {
-	APIKey      string
-	APIURL      string
-	Model       string
-	Timeout     time.Duration
-	MaxTokens   int
-	Temperature float64
-	ConfigDir   string
+	APIKey           string
+	APIURL           string
+	Model            string
+	Timeout          time.Duration
+	MaxTokens        int
+	Temperature      float64
+	TopP             float64
+	PresencePenalty  float64
+	FrequencyPenalty float64
+	Stop             []string
+	Seed             *int // nil means no seed is sent
+	Stream           bool
+	ShowUsage        bool
+	ModelsURL        string
+	Output           string
+	NoColor          bool
+	Provider         string
+	AzureAPIVersion  string
+	OrgID            string
+	ProjectID        string
+	LogMaxSize       int64
+	LogMaxFiles      int
+	MaxFileSize      int64
+	LogFullPrompt    bool
+	Timing           bool
+	ToolDomains      []string
+	ReasoningModels  []string
+	LogDisabled      bool
+	PromptPrefix     string
+	PromptSuffix     string
+	// Requests allowed per minute across invocations; 0 means unlimited
+	RequestsPerMinute int
+	// Headers added to every chat request, then those of prompt --header
+	ExtraHeaders []extraHeader
+	// Model of the embed command
+	EmbedModel string
+	// Model of the image command
+	ImageModel string
+	// Model and overall timeout of the transcribe command
+	TranscribeModel   string
+	TranscribeTimeout time.Duration
+	// Check prompts with the moderations endpoint before sending them
+	Moderate bool
+	// Replace API keys, credentials and the like in log entries
+	Redact bool
+	// Show a desktop notification when a prompt took at least NotifyAfter
+	Notify      bool
+	NotifyAfter time.Duration
+	// Characters of a page kept by prompt --url; 0 means no limit
+	URLMaxChars int
+	// Characters of prompts and responses shown by logs and history; 0
+	// means no limit
+	LogPreviewLen int
+	// Largest API response body read, once decompressed; 0 means no limit
+	MaxResponseBytes int64
+	// Go layout of the timestamps shown by logs; "" means defaultTimeFormat
+	TimeFormat string
+	// Trim a prompt rejected for not fitting in the model's context and
+	// send it again, unless prompt --no-trim
+	AutoTrim bool
+	// Template file rendering the body of chat requests, and the paths of
+	// the reply's values, for a gateway that is not OpenAI compatible; see
+	// gatewayProvider. Both are unset for the OpenAI API.
+	RequestTemplate string
+	ResponsePaths   *responsePaths
+	// Time allowed to connect to the API, TLS handshake included, within
+	// the overall Timeout; 0 means defaultConnectTimeout
+	ConnectTimeout time.Duration
+	Profile        string
+	ConfigDir      string
+	// Debug output level of the HTTP client; see debugTransport
+	Debug int
+
+	// Set by prompt --json-response to request a JSON object reply
+	JSONResponse bool
+	// Set by prompt --n to request several choices
+	Choices int
+	// Set by prompt --tools to the local tools the model may call
+	Tools []string
+	// Set by prompt --reasoning-effort for reasoning models
+	ReasoningEffort string
+	// Set by prompt and batch --no-wait to fail instead of waiting for
+	// OPENAI_REQUESTS_PER_MINUTE
+	NoWait bool
+	// Set by prompt --allow-header-override to let extra headers replace
+	// Authorization, Content-Type and the other headers chatgpt-cli sets
+	AllowHeaderOverride bool
+	// Set by built-in commands such as summarize to their instructions,
+	// sent as a system message before the prompt
+	SystemPrompt string
+	// Set by prompt --continue to earlier exchanges from the logs, sent
+	// between the system prompt and the prompt
+	History []Message
+	// Set by prompt --messages to a prepared conversation, sent as it is
+	// instead of the system prompt and History
+	Messages []Message
+
+	// Cancelled when the user interrupts the command; see requestContext
+	ctx context.Context
+
+	// API key from the config file still to be decrypted; see unlockAPIKey
+	encryptedAPIKey string
+	// Where the API key was found; see resolveAPIKey
+	apiKeySource string
+	// problems are the configured values that could not be parsed
+	problems []configProblem
+	// The config file's values and those set for single models, kept to
+	// switch models; see useModel
+	fileConfig   map[string]string
+	modelConfigs map[string]map[string]string
+	// The config file's values holding ${VAR} references, as written,
+	// keyed like config set takes them; see expandConfigValues
+	rawValues map[string]string
+	// Where each value comes from, by key, before the values set for the
+	// model in use; see configOrigins
+	origins map[string]string
+	// The redactor of log entries, loaded with the first one; see
+	// logRedactor
+	redactor *loadedRedactor
 }
 
 // OpenAI API request/response structures
 type ChatRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	MaxTokens   int       `json:"max_tokens,omitempty"`
-	Temperature float64   `json:"temperature,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	Messages         []Message `json:"messages"`
+	MaxTokens        int       `json:"max_tokens,omitempty"`
+	Temperature      float64   `json:"temperature,omitempty"`
+	TopP             float64   `json:"top_p,omitempty"`
+	PresencePenalty  float64   `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64   `json:"frequency_penalty,omitempty"`
+	Stop             []string  `json:"stop,omitempty"`
+	Seed             *int      `json:"seed,omitempty"`
+	Stream           bool      `json:"stream,omitempty"`
+	// StreamOptions asks for token usage in the final streamed chunk
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	// ResponseFormat asks for a JSON object reply when set
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// N asks for several choices when above 1
+	N int `json:"n,omitempty"`
+	// Reasoning models take max_completion_tokens instead of max_tokens, and
+	// may take reasoning_effort; see reasoning.go
+	MaxCompletionTokens int    `json:"max_completion_tokens,omitempty"`
+	ReasoningEffort     string `json:"reasoning_effort,omitempty"`
+	// Tools the model may call, and whether it must; see tools.go
+	Tools      []Tool `json:"tools,omitempty"`
+	ToolChoice string `json:"tool_choice,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Tools the assistant asks to run, instead of replying
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// The call a tool role message answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
 type ChatResponse struct {
@@ -66,6 +286,11 @@ type ChatResponse struct {
 	Choices []Choice  `json:"choices"`
 	Usage   Usage     `json:"usage"`
 	Error   *APIError `json:"error,omitempty"`
+	// SystemFingerprint identifies the backend configuration that answered;
+	// a seed only gives the same reply while it stays the same
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	// RequestID is the ID the provider gave the request, from its headers
+	RequestID string `json:"-"`
 }
 
 type Choice struct {
@@ -93,6 +318,46 @@ type LogEntry struct {
 	Prompt    string    `json:"prompt,omitempty"`
 	Response  string    `json:"response,omitempty"`
 	Error     string    `json:"error,omitempty"`
+	Usage     *Usage    `json:"usage,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	LatencyMs int64     `json:"latency_ms,omitempty"`
+	ToolCalls []ToolRun `json:"tool_calls,omitempty"`
+	// Expect is the answer asked for with prompt --expect, and Matched
+	// whether the response was that answer
+	Expect  string `json:"expect,omitempty"`
+	Matched *bool  `json:"matched,omitempty"`
+	// Pass is the index of a refine request, 1 being the first answer
+	Pass int `json:"pass,omitempty"`
+	// Step is the name of the workflow step of a run request
+	Step string `json:"step,omitempty"`
+	// Style is the comma-separated --style presets of a prompt
+	Style string `json:"style,omitempty"`
+	// Messages summarizes the conversation of prompt --messages sent
+	// before the prompt, as "5 messages, 1.2k chars"
+	Messages string `json:"messages,omitempty"`
+	// Chunk is the part of the diff a review request was about, as 1/3
+	Chunk string `json:"chunk,omitempty"`
+	// Exec is the command of the reply run by prompt --exec, and
+	// ExitStatus how it exited
+	Exec       string `json:"exec,omitempty"`
+	ExitStatus *int   `json:"exit_status,omitempty"`
+	// RequestID is the ID the provider gave the request, and Invocation
+	// the ID of the run of the CLI that wrote the entry
+	RequestID  string `json:"request_id,omitempty"`
+	Invocation string `json:"invocation,omitempty"`
+	// Seed is the seed the request was sent with, and Run the index of a
+	// prompt --count repetition, 1 being the first
+	Seed *int `json:"seed,omitempty"`
+	Run  int  `json:"run,omitempty"`
+	// Verify is the language prompt --verify checked the code of the reply
+	// as, Verified whether it passed, unset when it could not be checked,
+	// and VerifyRetries the follow-up requests sent to fix it
+	Verify        string `json:"verify,omitempty"`
+	Verified      *bool  `json:"verified,omitempty"`
+	VerifyRetries int    `json:"verify_retries,omitempty"`
+	// Trimmed is what was cut from a prompt too long for the model's context
+	// before it was sent again, as "lowered max_tokens from 4000 to 3597"
+	Trimmed string `json:"trimmed,omitempty"`
 }
 
 // Command represents a CLI command
@@ -100,10 +365,13 @@ type Command struct {
 	Name        string
 	Description string
 	Handler     func(*Config, []string) error
+	Hidden      bool // Omitted from completions
 }
 
-// loadConfig loads configuration from config file and environment variables with defaults
-func loadConfig() (*Config, error) {
+// loadConfig loads configuration from config file and environment variables with defaults.
+// The given profile, or CHATGPT_CLI_PROFILE if empty, is merged over the default
+// section of the config file.
+func loadConfig(profile string) (*Config, error) {
 	configDir := getConfigDir()
 
 	// Ensure config directory exists
@@ -111,83 +379,125 @@ func loadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	profileFlag := profile != ""
+	if profile == "" {
+		profile = os.Getenv(envProfile)
+	}
+	profile, err := normalizeProfile(profile)
+	if err != nil {
+		return nil, err
+	}
+
 	// Load from config file first
-	fileConfig := loadConfigFile(configDir)
+	fileConfig, profileKeys, err := loadProfileValues(configDir, profile)
+	if err != nil {
+		return nil, err
+	}
 
-	// Environment variables override file config
-	config := &Config{
-		APIKey:      getEnvOrFileConfig(envAPIKey, fileConfig["OPENAI_API_KEY"]),
-		APIURL:      getEnvOrFileOrDefault(envAPIURL, fileConfig["OPENAI_API_URL"], defaultAPIURL),
-		Model:       getEnvOrFileOrDefault(envModel, fileConfig["OPENAI_MODEL"], defaultModel),
-		Timeout:     parseDurationOrDefault(getEnvOrFileConfig(envTimeout, fileConfig["OPENAI_TIMEOUT"]), defaultTimeout),
-		MaxTokens:   parseIntOrDefault(getEnvOrFileConfig(envMaxTokens, fileConfig["OPENAI_MAX_TOKENS"]), defaultMaxTokens),
-		Temperature: parseFloatOrDefault(getEnvOrFileConfig(envTemperature, fileConfig["OPENAI_TEMPERATURE"]), defaultTemperature),
-		ConfigDir:   configDir,
+	modelConfigs, err := loadModelConfigs(configDir)
+	if err != nil {
+		return nil, err
 	}
 
-	return config, nil
-}
+	// ${VAR} references in the file are expanded from the environment
+	rawValues, problems := expandConfigFile(fileConfig, modelConfigs)
 
-// loadConfigFile loads configuration from file
-func loadConfigFile(configDir string) map[string]string {
-	configFile := filepath.Join(configDir, "config")
-	config := make(map[string]string)
+	// Values that fail to parse fall back to their default and are recorded
+	r := &configReader{file: fileConfig, profile: profile, profileKeys: profileKeys, models: modelConfigs, problems: problems}
 
-	data, err := os.ReadFile(configFile)
-	if err != nil {
-		return config // File doesn't exist or can't be read, return empty config
-	}
+	// The provider decides the defaults of the endpoint, model and API key
+	provider := parseProviderOrDefault(r.checked(envProvider, checkProvider), defaultProvider)
 
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+	// Values set for the model in use replace the configured ones
+	r.model = getEnvOrFileOrDefault(envModel, fileConfig["OPENAI_MODEL"], defaultModelFor(provider))
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			config[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	// Environment variables override file config
+	config := &Config{
+		Model:        r.model,
+		Provider:     provider,
+		Profile:      profile,
+		ConfigDir:    configDir,
+		fileConfig:   fileConfig,
+		modelConfigs: modelConfigs,
+		rawValues:    rawValues,
+		origins:      make(map[string]string),
+	}
+	loaded := len(r.problems)
+	for _, key := range configKeys {
+		if key.load != nil && !key.modelScoped {
+			key.load(config, r)
 		}
+		config.origins[key.name] = r.origin(key.name)
 	}
+	readModelValues(config, r)
+	config.problems = r.problems
 
-	return config
-}
-
-// saveConfigFile saves configuration to file
-func saveConfigFile(configDir string, config map[string]string) error {
-	configFile := filepath.Join(configDir, "config")
+	// Values that could not be parsed are replaced by their default
+	for _, p := range r.problems[loaded:] {
+		if _, ok := config.origins[p.Key]; ok {
+			config.origins[p.Key] = configSourceDefault
+		}
+	}
+	if profileFlag {
+		config.origins[envProfile] = configSourceFlag
+	}
 
-	// Read existing config to preserve all values
-	existingConfig := loadConfigFile(configDir)
+	config.APIKey, config.apiKeySource = resolveAPIKey(configDir, profile, provider, fileConfig)
+	config.APIKey = strings.TrimSpace(config.APIKey)
+	config.origins[apiKeyName(provider)] = r.apiKeyOrigin(provider, config.apiKeySource)
 
-	// Merge with new values
-	for key, value := range config {
-		existingConfig[key] = value
+	// An encrypted key is decrypted once a command needs it
+	if config.apiKeySource == apiKeySourceFile && config.APIKey == "" && hasEncryptedAPIKey(provider, fileConfig) {
+		config.encryptedAPIKey = fileConfig[encryptedAPIKeyKey]
 	}
 
-	// Write config file
-	var lines []string
-	lines = append(lines, "# ChatGPT CLI Configuration")
-	lines = append(lines, "# Generated on "+time.Now().Format("2006-01-02 15:04:05"))
-	lines = append(lines, "")
+	return config, nil
+}
 
-	keys := []string{
-		"OPENAI_API_KEY",
-		"OPENAI_API_URL",
-		"OPENAI_MODEL",
-		"OPENAI_TIMEOUT",
-		"OPENAI_MAX_TOKENS",
-		"OPENAI_TEMPERATURE",
+// loadConfigFile loads the default section of the config file
+func loadConfigFile(configDir string) (map[string]string, error) {
+	sections, err := loadConfigSections(configDir)
+	if err != nil {
+		return nil, err
 	}
+	return sections[""], nil
+}
 
-	for _, key := range keys {
-		if value, exists := existingConfig[key]; exists && value != "" {
-			lines = append(lines, fmt.Sprintf("%s=%s", key, value))
-		}
+// loadConfigSections loads every section of the config file, keyed by profile
+// name. The default section is keyed by "" and always present. A config.toml
+// that can't be parsed is an error naming the offending line.
+func loadConfigSections(configDir string) (map[string]map[string]string, error) {
+	doc, err := loadConfigDocument(configDir)
+	if err != nil {
+		return nil, err
 	}
+	return doc.sections(), nil
+}
 
-	return os.WriteFile(configFile, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+// saveConfigFile saves values to the default section of the config file.
+// Keys with an empty value are removed from the file.
+func saveConfigFile(configDir string, config map[string]string) error {
+	return saveProfileConfig(configDir, "", config)
+}
+
+// saveProfileConfig saves values to a profile's section of config.toml,
+// creating the section if needed. Keys with an empty value are removed.
+// Comments and keys the CLI doesn't know are kept as written.
+func saveProfileConfig(configDir, profile string, config map[string]string) error {
+	// Values not given are kept as they are in the file
+	return updateConfigDocument(configDir, func(doc *configDocument) {
+		doc.ensureTable(profileTable(profile))
+		for _, key := range configFileKeys {
+			value, exists := config[key]
+			switch {
+			case !exists:
+			case value == "":
+				doc.unset(profile, key)
+			default:
+				doc.set(profile, key, value)
+			}
+		}
+	})
 }
 
 // getEnvOrFileConfig gets value from env var first, then file config
@@ -253,6 +563,72 @@ func parseFloatOrDefault(value string, defaultValue float64) float64 {
 	return parsed
 }
 
+func parseBoolOrDefault(value string, defaultValue bool) bool {
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+func parseSizeOrDefault(value string, defaultValue int64) int64 {
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := parseSize(value)
+	if err != nil || parsed < 0 {
+		return defaultValue
+	}
+	return parsed
+}
+
+// Size suffixes accepted by parseSize, longest first
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"GB", 1024 * 1024 * 1024},
+	{"MB", 1024 * 1024},
+	{"KB", 1024},
+	{"G", 1024 * 1024 * 1024},
+	{"M", 1024 * 1024},
+	{"K", 1024},
+	{"B", 1},
+}
+
+// parseSize parses a byte size such as "5MB", "512KB" or "1048576"
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %q", value)
+	}
+	return n * multiplier, nil
+}
+
+// formatSize formats a byte size using the largest exact unit
+func formatSize(size int64) string {
+	for _, unit := range sizeUnits[:3] {
+		if size >= unit.multiplier && size%unit.multiplier == 0 {
+			return fmt.Sprintf("%d%s", size/unit.multiplier, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(size, 10)
+}
+
 func parseDurationOrDefault(value string, defaultValue time.Duration) time.Duration {
 	if value == "" {
 		return defaultValue
@@ -264,6 +640,71 @@ func parseDurationOrDefault(value string, defaultValue time.Duration) time.Durat
 	return parsed
 }
 
+// promptFlagConflicts lists, for each prompt flag, the flags it can't be
+// combined with
+var promptFlagConflicts = []struct {
+	flag   string
+	others []string
+}{
+	// Follow-ups fix the single reply, as sent without tools
+	{"--verify", []string{"--n", "--tools", "--json-response"}},
+	// Each repetition is compared whole, so nothing may pick, check or act on
+	// a single reply
+	{"--count", []string{"--n", "--tools", "--json-response", "--extract", "--expect", "--exec", "--clip-out", "--verify"}},
+	// Each save is sent and printed as it is, with nothing to pick, check or
+	// act on
+	{"--watch", []string{"--count", "--pick", "--tools", "--json-response", "--extract", "--expect", "--exec",
+		"--clip-out", "--confirm-cost", "--dry-run", "--verify"}},
+}
+
+// checkFlagConflicts returns a usage error for the first pair of given flags
+// that promptFlagConflicts rules out
+func checkFlagConflicts(given map[string]bool) error {
+	for _, conflict := range promptFlagConflicts {
+		if !given[conflict.flag] {
+			continue
+		}
+		for _, other := range conflict.others {
+			if given[other] {
+				return usageErrorf("%s cannot be combined with %s", conflict.flag, other)
+			}
+		}
+	}
+	return nil
+}
+
+// parseFlags parses flags from args and returns the remaining positional
+// arguments. Unlike fs.Parse, flags may appear before, between or after
+// positional arguments; everything after a "--" terminator is positional.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+
+	// The command's default flags come first, so those given override them
+	if err := parseDefaultFlags(fs); err != nil {
+		return nil, err
+	}
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		// fs.Parse consumes a "--" terminator, so stop looking for flags
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
 // Command handlers
 
 // helpCommand displays usage information
@@ -271,383 +712,2037 @@ func helpCommand(config *Config, args []string) error {
 	help := `ChatGPT CLI - Command Line Interface for ChatGPT
 
 Usage:
-  chatgpt-cli <command> [arguments]
+  chatgpt-cli [--output plain|json] [--profile <name>] <command> [arguments]
 
 Available Commands:
   help                    Show this help message
-  prompt <text>           Send a prompt to ChatGPT
-  logs                    Display application logs
-  config list             List current configuration
-  config get <key>        Get a configuration value
-  config set <key> <val>  Set a configuration value
+  init [flags]            Set up the API key, model and defaults, asking for each
+  prompt [flags] <text>   Send a prompt to ChatGPT
+  logs [flags]            Display application logs
+  logs clear [--force]    Delete all application logs
+  logs export [flags]     Export logs as CSV, markdown or JSON
+  logs repair             Drop log lines that are not valid JSON, keeping a backup
+  logs scan               Count the log entries with API keys, credentials or emails in them
+  logs scrub              Redact the existing logs, keeping a backup
+  logs show <n> [--full]  Print one log entry, with --full its prompt and response whole
+  history [flags]         List recent prompts, most recent first
+  history show <n>        Print a past prompt and its response in full
+  history rerun <n>       Send a past prompt again with the current config
+  search [flags] <terms>  Find past prompts and responses containing all the terms
+  models [--filter <s>]   List models available from the API
+  batch [flags] <file>    Send one prompt per line of a file
+  refine [flags] <text>   Answer a prompt, then have the model critique and improve the answer
+  compare --models <list> Send a prompt to several models and show the replies side by side
+  run [--raw] <workflow>  Run the prompt steps of a YAML or JSON workflow file in order
+  summarize [flags]       Summarize the text given as arguments or on stdin
+  translate --to <lang>   Translate the text given as arguments or on stdin
+  commitmsg [--no-body]   Write a Conventional Commits message for the git diff on stdin
+  review [flags]          Review the changes of git diff, in chunks, and report the findings by file
+  explain-code <file>     Explain a source file, or the lines given with --lines
+  tokens <text>           Estimate the number of tokens in a text (- reads stdin)
+  embed [flags] <text>    Print the embedding vector of a text, a file or a list of files
+  image [flags] <prompt>  Generate images from a prompt and save them as PNG files
+  transcribe <file>       Print the transcript of an audio file, up to 25MB
+  stats [flags]           Show prompts, tokens, cost and latency from the logs
+  ask-logs [flags] <text> Ask the model a question about the logs, sending the entries that fit
+  doctor                  Check DNS, TCP, TLS and HTTP connectivity to the API
+  status [flags]          Check that the provider is reachable and accepts the API key, for CI
+  completion <shell>      Print a bash, zsh or fish completion script
+  auth login              Save the API key to the OS keychain, read without echo
+  auth logout             Remove the API key from the OS keychain
+  auth status             Show where the API key is found and which one is used
+  alias add <name> <text> Save a prompt to run as: chatgpt-cli <name> [text]
+  alias list              List aliases
+  alias remove <name>     Delete an alias
+  style list              List the built-in and your own styles of prompt --style
+  config list             List current configuration; --model shows the values in effect for a model,
+                          --show-origin where each value comes from
+  config get <key>        Get a configuration value; --origin prints where it comes from
+  config set <key> <val>  Set a configuration value; --encrypt stores OPENAI_API_KEY encrypted,
+                          --model sets it only for one model
+  config unset <key>      Remove a configuration value from the config file; --model for one model
+  config reset [--force]  Remove all values from the config file
+  config validate         Report configured values that can't be parsed
+  config set-default <command> <flags>
+                          Save flags parsed before those given to a command, such as --usage
+  config get-default [command]
+                          Show the default flags of a command, or of all commands
+  config unset-default <command>
+                          Remove the default flags of a command
+  config fix-permissions  Make the files holding API keys readable only by you
+  config schema           Describe each configuration key: type, default and accepted values
+  export --out <file>     Bundle the config file, aliases, styles and redaction rules into a tar.gz
+  import <file>           Restore an archive made by export, refusing to replace existing files
+  update [flags]          Replace this binary with the latest release, after checking its SHA-256
+
+Global Flags:
+  --output plain|json     Output format; json is meant for scripts (default: %s)
+  --profile <name>        Use a named profile from the config file
+  -v, --verbose           Print HTTP requests and responses to stderr; -vv adds response bodies
+  --no-redact             Log prompts and responses without redacting API keys, credentials and emails
+  --no-defaults           Ignore the default flags saved with config set-default
+
+Prompt Flags:
+  --model <name>          Use this model instead of OPENAI_MODEL, with the values set for it
+  --no-stream             Wait for the full response instead of streaming it
+  --usage                 Print token usage and estimated cost after the response
+  --timing                Print how long the request took and the tokens used, e.g. (2.3s, 512 tokens)
+  --raw                   Print the response as-is instead of rendering markdown
+  --file <path>           Append a file to the prompt; repeatable, - reads stdin
+  --url <url>             Append the text of a web page to the prompt; repeatable
+  --stdin                 Read the prompt from stdin until EOF, keeping newlines (or: prompt -)
+  --dry-run               Print the request that would be sent, without sending it
+  --json-response         Ask for a JSON object reply and pretty-print it (--raw keeps it as sent)
+  --style <list>          Add comma-separated styles to the system prompt: concise, detailed, eli5,
+                          formal, bullet-points or your own (see style list)
+  --extract <what>        Print only the code blocks (code, code:first, code:<language>) or the first JSON (json); repeatable
+  --clip-in               Read the prompt from the clipboard
+  --clip-out              Copy the response to the clipboard after printing it
+  --notify                Show a desktop notification with the start of the reply when the request
+                          took at least CHATGPT_CLI_NOTIFY_AFTER (default: 5s)
+  --exec                  Run the first code block of the reply with your shell after asking y/N;
+                          its exit status becomes the CLI's
+  --yes                   With --exec, run the command without asking, unless it looks destructive
+  --confirm-cost          Show the estimated tokens and cost, then ask before sending
+  --moderate              Check the prompt with the moderations endpoint first; refuse it if flagged
+  --n N                   Ask for N choices, printed labeled [1], [2], ... (default: 1)
+  --pick                  With --n, choose one of the choices and print only that one
+  --count N               Send the prompt N times in a row, printing each answer and how many were identical
+  --watch <file>          Send the prompt in file, then again each time it is saved, until Ctrl-C
+  --watch-interval <dur>  How often --watch checks the file for changes (default: 500ms)
+  --tools <list>          Let the model call local tools: get_time, read_file, http_get
+  --max-tool-rounds N     Rounds of tool calls answered before giving up (default: 5)
+  --quiet                 Do not print the response; the exit status and --usage still tell the outcome
+  --expect <text>         Exit with status 1 unless the trimmed response is text, printing it to stderr
+  --ignore-case           Ignore case when comparing the response with --expect
+  --verify <language>     Check the first code block of the response: go (parsed), python (py_compile), json or none;
+                          code that fails is sent back to be fixed, and exits with status 1 if it still fails
+  --verify-retries N      Follow-up requests asking to fix code that fails --verify (default: %d)
+  --out <path>            Write the response to a file instead of stdout (- for stdout)
+  --append                Append to the --out file, after --delimiter (default: a --- line)
+  --force                 Overwrite an existing --out file, or send a prompt flagged by --moderate
+  --max-tokens N          Override OPENAI_MAX_TOKENS for this prompt
+  --top-p N               Override OPENAI_TOP_P for this prompt
+  --presence-penalty N    Override OPENAI_PRESENCE_PENALTY for this prompt
+  --frequency-penalty N   Override OPENAI_FREQUENCY_PENALTY for this prompt
+  --stop <list>           Override OPENAI_STOP with comma-separated stop sequences
+  --seed N                Override OPENAI_SEED to ask for deterministic sampling
+  --reasoning-effort <e>  Reasoning effort of o1, o3 and similar models: low, medium or high
+  --prefix <text>         Send text before the prompt (overrides OPENAI_PROMPT_PREFIX)
+  --suffix <text>         Send text after the prompt and files (overrides OPENAI_PROMPT_SUFFIX)
+  --no-wait               Fail with exit status 7 instead of waiting for OPENAI_REQUESTS_PER_MINUTE
+  --no-trim               Fail on a prompt too long for the model's context, despite CHATGPT_CLI_AUTO_TRIM
+  --header "Key: Value"   Send an extra header, after those of OPENAI_EXTRA_HEADERS; repeatable
+  --allow-header-override Let extra headers replace Authorization, Content-Type and the like
+  --continue[=N]          Send the last logged prompt and response (or the last N) before the prompt
+  --messages <file>       Send a JSON array of {role, content} messages as they are, then the prompt if given
+
+Logs Flags:
+  --tail N                Show only the last N matching entries
+  --since <duration>      Show only entries newer than the duration (e.g. 24h, 7d)
+  --command <name>        Show only entries for the given command
+  --errors-only           Show only entries that recorded an error
+  --group                 Group entries by the run of the CLI that wrote them, as for a batch or compare
+  --full                  With logs show, print the prompt and response without truncation
+  --utc                   Show timestamps in UTC instead of the local time zone
+  --relative              Show how long ago each entry was logged, such as 2h ago
+
+Logs Export Flags:
+  --format csv|md|json    Output format (default: csv)
+  --md-style table|sections  Markdown layout (default: table)
+  --out <file>            Write to a file instead of stdout
+  --since, --command and --errors-only filter entries as for logs
+
+History Flags:
+  --search <text>         Show only prompts containing the text, ignoring case
+  --limit N               Show at most N prompts, 0 for all (default: %d)
+  history rerun accepts prompt flags after the index
+
+Search Flags:
+  --regex                 Match a single regular expression instead of terms, ignoring case
+  --in prompt|response|both  Fields searched (default: both)
+  --limit N               Show at most N matches, most recent first, 0 for all (default: %d)
+
+Refine Flags:
+  --passes N              Requests made, the first answering and the others improving (default: 2, at most 5)
+  --show-steps            Print every answer and critique, not just the final answer
+  --usage                 Print the token usage and estimated cost of all passes
+  --raw                   Print the answer as-is instead of rendering markdown
+
+Compare Flags:
+  --models <list>         Comma-separated models to send the prompt to, at least two
+  --concurrency N         Number of models asked in parallel (default: 4)
+
+Run Flags:
+  --raw                   Print the step outputs as-is instead of rendering markdown
+
+Summarize, Translate and Commitmsg Flags:
+  --length short|long     Length of the summary (default: short)
+  --to <language>         Language to translate into, by name or code such as it
+  --no-body               Write the commit subject line only
+  --no-stream, --usage, --raw and --dry-run work as for prompt
+
+Review Flags:
+  --staged                Review the staged changes instead of the unstaged ones
+  --range <range>         Review the changes of a revision range, such as main..HEAD
+  --focus <focus>         Focus the review on bugs, security or style
+  --model <name>          Model to use instead of OPENAI_MODEL
+  --chunk-tokens N        Largest part of the diff sent in one request (default: 12000)
+  --raw                   Print the report as-is instead of rendering markdown
+
+Explain-Code Flags:
+  --lines <range>         Explain only these lines, such as 40-80, with whole functions around them if the file is too large
+  --question <text>       A question about the code, answered first
+  --budget N              Estimated tokens of code to send at most (default: 6000)
+  --model <name>          Model to use instead of OPENAI_MODEL
+  --no-stream, --usage, --raw and --dry-run work as for prompt
+
+Batch Flags:
+  --concurrency N         Number of prompts sent in parallel (default: %d)
+  --out <file>            Write JSONL results to a file instead of stdout
+  --no-wait               Fail prompts over OPENAI_REQUESTS_PER_MINUTE instead of waiting
+  --fail-fast             Stop sending prompts after the first one fails
+
+Embed Flags:
+  --file <path>           Embed the contents of a file instead of a text (- reads stdin)
+  --file-list <path>      Embed every file listed, one path per line, in one request; prints JSONL
+  --dims N                Ask for embeddings with N dimensions, if the model supports it
+  --out <file>            Write the embedding to a file instead of stdout
+
+Image Flags:
+  --size WxH              Size of the images, such as 1024x1024 (default: the model's)
+  --n N                   Number of images to generate (default: 1)
+  --out <dir>             Directory the numbered PNG files are saved in (default: .)
+
+Transcribe Flags:
+  --language <code>       Language of the audio, such as it (default: detected)
+  --format <format>       Transcript format: text, srt, vtt or json (default: text)
+  --out <file>            Write the transcript to a file instead of stdout
+
+Stats Flags:
+  --since <duration>      Only include entries newer than the duration (e.g. 7d)
+  --by day|model          Group the table by day or by model (default: model)
+
+Ask-Logs Flags:
+  --since <duration>      Only send entries newer than the duration (e.g. 1d)
+  --budget N              Estimated tokens of log entries to send at most (default: 4000)
+  --raw                   Print the response as-is instead of rendering markdown
+  --dry-run               Print the request that would be sent, without sending it
+
+Init Flags:
+  --non-interactive       Take the values from the flags below instead of asking
+  --api-key <key>         API key to save
+  --model <name>          Model to use
+  --temperature N         Default temperature
+  --max-tokens N          Default max tokens in a response
+  --no-verify             Save without checking the API key against the models endpoint
+
+Status Flags:
+  --deep                  Also send a chat completion of one token to the model
+  --timeout <duration>    Time allowed to each check (default: 10s)
+
+Export and Import Flags:
+  --out <file>            Archive to write (export)
+  --include-secrets       Include the API keys and the credentials file (export)
+  --merge                 Keep existing files and values, adding only what they lack (import)
+  --overwrite             Replace existing files with those of the archive (import)
+
+Update Flags:
+  --check                 Only report whether a newer version is available
+  --version <vX.Y.Z>      Install this version instead of the latest
 
 Examples:
+  chatgpt-cli init
   chatgpt-cli prompt "Explain Go interfaces"
+  chatgpt-cli prompt --file main.go "Review this code"
+  chatgpt-cli prompt --url https://go.dev/blog/go1.22 "What changed in loops?"
+  chatgpt-cli prompt - < question.txt
+  chatgpt-cli prompt --continue "and what about generics?"
+  chatgpt-cli prompt --style concise,formal "Explain TCP slow start"
+  chatgpt-cli prompt --extract code:go "Write a function reversing a string" > reverse.go
+  chatgpt-cli prompt --verify go "Write a function reversing a string"
+  chatgpt-cli prompt --exec "Find the 5 largest files in this directory"
+  chatgpt-cli prompt --count 5 --seed 42 --usage "Name a random fruit"
+  chatgpt-cli prompt --watch prompt.txt --usage
   chatgpt-cli logs
+  chatgpt-cli logs --tail 20 --errors-only
+  chatgpt-cli logs --since 24h --utc
+  chatgpt-cli logs export --format md --since 7d --out history.md
+  chatgpt-cli logs scan
+  chatgpt-cli logs show 3 --full
+  chatgpt-cli history --search kubernetes
+  chatgpt-cli history rerun 3 --no-stream
+  chatgpt-cli search --in response interface embedding
+  chatgpt-cli models --filter gpt-4
+  chatgpt-cli batch prompts.txt --concurrency 8 --out results.jsonl
+  chatgpt-cli refine --show-steps "Write a haiku about Go"
+  chatgpt-cli compare --models gpt-4o,gpt-3.5-turbo "Explain Go channels"
+  chatgpt-cli run release-notes.yaml
+  chatgpt-cli summarize --length long < notes.md
+  chatgpt-cli translate --to it "Good morning"
+  git diff --cached | chatgpt-cli commitmsg
+  chatgpt-cli review --range main..HEAD --focus security
+  chatgpt-cli explain-code --lines 40-80 --question "why is this goroutine leaking?" worker.go
+  chatgpt-cli embed --file-list docs.txt --out embeddings.jsonl
+  chatgpt-cli image --size 1024x1792 --out ./out/ "a watercolor fox"
+  chatgpt-cli transcribe --language it --format srt --out talk.srt talk.mp3
+  chatgpt-cli stats --since 7d --by day
+  chatgpt-cli ask-logs --since 1d "Why did my requests fail yesterday?"
+  chatgpt-cli doctor
+  chatgpt-cli status --deep --timeout 5s
+  chatgpt-cli auth login
+  source <(chatgpt-cli completion bash)
   chatgpt-cli config list
   chatgpt-cli config set OPENAI_MODEL gpt-4
+  chatgpt-cli --profile work config set OPENAI_MODEL gpt-4
+  chatgpt-cli config set --model gpt-4 OPENAI_MAX_TOKENS 4000
+  chatgpt-cli config list --model gpt-4
+  chatgpt-cli config list --show-origin
+  chatgpt-cli config set-default prompt "--usage --timing --style concise"
+  chatgpt-cli export --out backup.tar.gz
+  chatgpt-cli import --merge backup.tar.gz
+  chatgpt-cli update --check
 
 Configuration:
   Configuration is managed via environment variables:
-    OPENAI_API_KEY       - Your OpenAI API key (required)
-    OPENAI_API_URL       - API endpoint URL (default: %s)
-    OPENAI_MODEL         - Model to use (default: %s)
-    OPENAI_TIMEOUT       - Request timeout (default: %s)
-    OPENAI_MAX_TOKENS    - Max tokens in response (default: %d)
-    OPENAI_TEMPERATURE   - Response randomness 0.0-2.0 (default: %.1f)
-    CHATGPT_CLI_CONFIG_DIR - Config directory (default: ~/.chatgpt-cli)
+%s
 
 For more information, visit: https://github.com/umbertocicciaa/chatgpt-cli
 `
-	fmt.Printf(help, defaultAPIURL, defaultModel, defaultTimeout, defaultMaxTokens, defaultTemperature)
+	out := newUI(config, os.Stdout)
+	out.Printf(out.helpText(help), defaultOutput, defaultVerifyRetries, defaultHistoryLimit, defaultHistoryLimit, defaultBatchConcurrency, configHelp())
 	return nil
 }
 
 // promptCommand sends a prompt to ChatGPT
 func promptCommand(config *Config, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("prompt text is required\nUsage: chatgpt-cli prompt \"your prompt here\"")
+	return runPrompt(config, "prompt", "", args)
+}
+
+// runPrompt implements the prompt command and aliases. The prefix, an alias's
+// prompt, goes before the prompt text, and the interaction is logged under
+// the command name.
+func runPrompt(config *Config, command, prefix string, args []string) error {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	noStream := fs.Bool("no-stream", false, "wait for the full response instead of streaming it")
+	showUsage := fs.Bool("usage", config.ShowUsage, "print token usage and estimated cost")
+	timing := fs.Bool("timing", config.Timing, "print how long the request took and the tokens used")
+	raw := fs.Bool("raw", false, "print the response as-is instead of rendering markdown")
+	dryRun := fs.Bool("dry-run", false, "print the request instead of sending it")
+	jsonResponse := fs.Bool("json-response", false, "ask for a JSON object reply and pretty-print it")
+	fromStdin := fs.Bool("stdin", false, "read the prompt from stdin until EOF, keeping all whitespace")
+	clipIn := fs.Bool("clip-in", false, "read the prompt from the clipboard")
+	clipOut := fs.Bool("clip-out", false, "copy the response to the clipboard after printing it")
+	notify := fs.Bool("notify", config.Notify, "show a desktop notification when a slow request completes")
+	execute := fs.Bool("exec", false, "run the first code block of the response with your shell, after asking")
+	yes := fs.Bool("yes", false, "run the --exec command without asking, unless it looks destructive")
+	confirmCostFlag := fs.Bool("confirm-cost", false, "show the estimated tokens and cost and ask before sending")
+	moderate := fs.Bool("moderate", config.Moderate, "check the prompt with the moderations endpoint and refuse to send it if flagged")
+	outPath := fs.String("out", "", "write the response to a file instead of stdout; - means stdout")
+	appendOut := fs.Bool("append", false, "append to the --out file instead of refusing to overwrite it")
+	force := fs.Bool("force", false, "overwrite an existing --out file, or send a prompt flagged by --moderate")
+	delimiter := fs.String("delimiter", defaultOutDelimiter, "written before a response appended to a non-empty file")
+	choices := fs.Int("n", 1, "ask for this many choices, printed one after another")
+	pick := fs.Bool("pick", false, "pick one of the --n choices interactively and print only that one")
+	count := fs.Int("count", 1, "send the prompt this many times in a row and report how many answers were identical")
+	watchPath := fs.String("watch", "", "send the prompt in this file, then again each time the file is saved, until Ctrl-C")
+	watchInterval := fs.Duration("watch-interval", defaultWatchInterval, "how often --watch checks the file for changes")
+	promptPrefix := fs.String("prefix", config.PromptPrefix, "text sent before the prompt; overrides OPENAI_PROMPT_PREFIX")
+	promptSuffix := fs.String("suffix", config.PromptSuffix, "text sent after the prompt and attached files; overrides OPENAI_PROMPT_SUFFIX")
+	quiet := fs.Bool("quiet", false, "do not print the response")
+	noTrim := fs.Bool("no-trim", false, "fail on a prompt too long for the model's context instead of trimming it, despite CHATGPT_CLI_AUTO_TRIM")
+	expect := fs.String("expect", "", "exit with status 1 unless the trimmed response is this text")
+	ignoreCase := fs.Bool("ignore-case", false, "ignore case when comparing the response with --expect")
+	allowHeaderOverride := fs.Bool("allow-header-override", false, "let extra headers replace the headers chatgpt-cli sets, such as Authorization")
+	noWait := fs.Bool("no-wait", false, "fail instead of waiting when OPENAI_REQUESTS_PER_MINUTE is reached")
+	toolList := fs.String("tools", "", "comma-separated local tools the model may call: "+strings.Join(localToolNames(), ", "))
+	maxToolRounds := fs.Int("max-tool-rounds", defaultMaxToolRounds, "rounds of tool calls answered before giving up")
+	sampling := addSamplingFlags(fs, config)
+	var files fileListFlag
+	fs.Var(&files, "file", "append a file to the prompt; repeatable, - reads stdin")
+	var urls urlListFlag
+	fs.Var(&urls, "url", "append the text of a web page to the prompt; repeatable")
+	var headers headerListFlag
+	fs.Var(&headers, "header", "send an extra \"Key: Value\" header; repeatable")
+	var continued continueFlag
+	fs.Var(&continued, "continue", "send the last logged exchange, or the last N with --continue=N, before the prompt")
+	messagesPath := fs.String("messages", "", "send the conversation of a JSON file of {role, content} messages, then the prompt if one is given")
+	model := fs.String("model", "", "model to use instead of OPENAI_MODEL, with the values set for it in the config file")
+	style := fs.String("style", "", "comma-separated styles added to the system prompt, such as concise,formal")
+	var extracts extractListFlag
+	fs.Var(&extracts, "extract", "print only part of the response: code, code:first, code:<language> or json; repeatable")
+	verify := fs.String("verify", "", "check the first code block of the response: "+strings.Join(verifyLanguages(), ", "))
+	verifyRetries := fs.Int("verify-retries", defaultVerifyRetries, "follow-up requests asking to fix code that fails --verify")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli prompt [--model name] [--no-stream] [--usage] [--timing] [--raw] [--dry-run] [--json-response] [--style list] [--extract code[:first|:lang]|json]... [--confirm-cost] [--moderate [--force]] [--quiet] [--expect text [--ignore-case]] [--verify go|python|json|none [--verify-retries N]] [--max-tokens N] [--n N [--pick]] [--count N] [--watch file [--watch-interval 500ms]] [--tools list [--max-tool-rounds N]] [--out path [--append | --force]] [--top-p N] [--presence-penalty N] [--frequency-penalty N] [--stop list] [--seed N] [--reasoning-effort low|medium|high] [--prefix text] [--suffix text] [--no-wait] [--header \"Key: Value\"]... [--allow-header-override] [--continue[=N] | --messages file] [--no-trim] [--file path]... [--url url]... [--stdin | - | --clip-in] [--clip-out] [--notify] [--exec [--yes]] \"your prompt here\"", err)
+	}
+	if *model != "" {
+		useModel(config, *model)
+	}
+	if err := sampling.apply(fs, config); err != nil {
+		return err
 	}
 
-	// Validate API key
-	if config.APIKey == "" {
-		return fmt.Errorf("missing API key: %s environment variable not set", envAPIKey)
+	// A lone "-" is shorthand for --stdin
+	if len(args) == 1 && args[0] == "-" {
+		*fromStdin = true
+		args = nil
+	}
+	if *fromStdin {
+		if len(args) > 0 {
+			return usageErrorf("--stdin reads the whole prompt; remove the prompt arguments")
+		}
+		for _, file := range files {
+			if file == "-" {
+				return usageErrorf("stdin cannot be both the prompt and an attached file")
+			}
+		}
 	}
 
-	// Combine all arguments as the prompt
-	prompt := strings.Join(args, " ")
-	if strings.TrimSpace(prompt) == "" {
-		return fmt.Errorf("prompt cannot be empty")
+	if *appendOut && *outPath == "" {
+		return usageErrorf("--append needs --out")
+	}
+	if *force && *outPath == "" && !*moderate {
+		return usageErrorf("--force needs --out or --moderate")
 	}
 
-	// Send request
-	response, err := sendChatRequest(config, prompt)
+	if err := validateChoiceFlags(config, *choices, *pick); err != nil {
+		return err
+	}
+	if *choices > 1 && *jsonResponse && !*pick {
+		return usageErrorf("--json-response with --n needs --pick")
+	}
+	if len(extracts) > 0 && *jsonResponse {
+		return usageErrorf("--extract cannot be combined with --json-response, whose reply is only JSON")
+	}
+	if *ignoreCase && *expect == "" {
+		return usageErrorf("--ignore-case needs --expect")
+	}
+	if *choices > 1 && *expect != "" && !*pick {
+		return usageErrorf("--expect with --n needs --pick")
+	}
+	if *choices > 1 && *execute && !*pick {
+		return usageErrorf("--exec with --n needs --pick")
+	}
+	if err := checkExecFlags(config, *execute, *yes, *dryRun); err != nil {
+		return err
+	}
+	retriesSet := false
+	fs.Visit(func(f *flag.Flag) { retriesSet = retriesSet || f.Name == "verify-retries" })
+	verifyLanguage, err := checkVerifyFlags(*verify, *verifyRetries, retriesSet)
 	if err != nil {
-		logEntry(config, "prompt", prompt, "", err.Error())
-		return fmt.Errorf("failed to get response: %w", err)
+		return err
 	}
-
-	// Format and display response
-	content := formatResponse(response)
-	fmt.Println(content)
-
-	// Log successful interaction
-	logEntry(config, "prompt", prompt, content, "")
-
-	return nil
-}
-
-// logsCommand displays application logs
-func logsCommand(config *Config, args []string) error {
-	logFile := filepath.Join(config.ConfigDir, "logs.jsonl")
-
-	// Check if log file exists
-	if _, err := os.Stat(logFile); os.IsNotExist(err) {
-		fmt.Println("No logs found.")
-		return nil
+	if *count < 1 || *count > maxRepeatCount {
+		return usageErrorf("--count must be between 1 and %d", maxRepeatCount)
 	}
-
-	// Read log file
-	data, err := os.ReadFile(logFile)
+	if *watchPath != "" {
+		if len(args) > 0 || *fromStdin || *clipIn {
+			return usageErrorf("--watch reads the prompt from the file; remove the prompt arguments, --stdin and --clip-in")
+		}
+		if *watchInterval <= 0 {
+			return usageErrorf("--watch-interval must be positive")
+		}
+	}
+	err = checkFlagConflicts(map[string]bool{
+		"--n": *choices > 1, "--pick": *pick, "--tools": *toolList != "", "--json-response": *jsonResponse,
+		"--extract": len(extracts) > 0, "--expect": *expect != "", "--exec": *execute, "--clip-out": *clipOut,
+		"--confirm-cost": *confirmCostFlag, "--dry-run": *dryRun, "--verify": verifyLanguage != "",
+		"--count": *count > 1, "--watch": *watchPath != "",
+	})
 	if err != nil {
-		return fmt.Errorf("failed to read logs: %w", err)
+		return err
 	}
-
-	if len(data) == 0 {
-		fmt.Println("No logs found.")
-		return nil
+	config.Choices = *choices
+	var styles string
+	if *style != "" {
+		names, text, err := resolveStyles(config.ConfigDir, *style)
+		if err != nil {
+			return err
+		}
+		config.SystemPrompt = appendSystemPrompt(config.SystemPrompt, text)
+		styles = strings.Join(names, ",")
 	}
+	config.NoWait = *noWait
+	config.ExtraHeaders = append(config.ExtraHeaders, headers...)
+	config.AllowHeaderOverride = *allowHeaderOverride
 
-	// Parse and display logs
-	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
-	fmt.Printf("Showing %d log entries:\n\n", len(lines))
-
-	for i, line := range lines {
-		var entry LogEntry
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			continue // Skip invalid entries
+	if *toolList != "" {
+		if config.Tools, err = parseToolList(*toolList); err != nil {
+			return err
 		}
-
-		fmt.Printf("[%d] %s - %s\n", i+1, entry.Timestamp.Format("2006-01-02 15:04:05"), entry.Command)
-		if entry.Prompt != "" {
-			fmt.Printf("    Prompt: %s\n", truncate(entry.Prompt, 80))
+		if config.Choices > 1 {
+			return usageErrorf("--tools cannot be combined with --n")
 		}
-		if entry.Response != "" {
-			fmt.Printf("    Response: %s\n", truncate(entry.Response, 80))
+		if config.Provider == providerAnthropic || config.Provider == providerOllama {
+			return usageErrorf("--tools is not supported by the %s provider", config.Provider)
 		}
-		if entry.Error != "" {
-			fmt.Printf("    Error: %s\n", entry.Error)
+		if *maxToolRounds < 1 {
+			return usageErrorf("--max-tool-rounds must be at least 1")
 		}
-		fmt.Println()
 	}
 
-	return nil
-}
-
-// configCommand manages configuration
-func configCommand(config *Config, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("config subcommand required\nUsage: chatgpt-cli config <list|get|set>")
+	if *moderate && (config.Provider == providerAzure || config.Provider == providerAnthropic || config.Provider == providerOllama) {
+		return usageErrorf("--moderate needs the %s provider; the %s provider has no moderations endpoint", providerOpenAI, config.Provider)
 	}
 
-	subcommand := args[0]
-
-	switch subcommand {
-	case "list":
-		return configListCommand(config, args[1:])
-	case "get":
-		return configGetCommand(config, args[1:])
-	case "set":
-		return configSetCommand(config, args[1:])
-	default:
-		return fmt.Errorf("unknown config subcommand: %s\nValid subcommands: list, get, set", subcommand)
+	if *clipIn && (len(args) > 0 || *fromStdin) {
+		return usageErrorf("--clip-in reads the whole prompt; remove the prompt arguments and --stdin")
 	}
-}
 
-// configListCommand lists all configuration values
-func maskAPIKey(apiKey string) string {
-	if apiKey != "" {
-		if len(apiKey) > 8 {
-			return apiKey[:4] + "..." + apiKey[len(apiKey)-4:]
+	// The conversation is sent as it is, so nothing may add messages before
+	// the prompt
+	var messagesSummary string
+	if *messagesPath != "" {
+		if continued > 0 {
+			return usageErrorf("--messages cannot be combined with --continue; add the earlier exchanges to the file")
 		}
-		return "***"
+		if *style != "" {
+			return usageErrorf("--messages cannot be combined with --style; add a system message to the file")
+		}
+		if config.Messages, err = readMessagesFile(*messagesPath); err != nil {
+			return err
+		}
+		if (config.Provider == providerAnthropic || config.Provider == providerOllama) && hasToolMessages(config.Messages) {
+			return usageErrorf("%s: tool calls are not supported by the %s provider", *messagesPath, config.Provider)
+		}
+		messagesSummary = summarizeMessages(config.Messages)
 	}
-	return "(not set)"
-}
-
-func configListCommand(config *Config, args []string) error {
-	fmt.Println("Current Configuration:")
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-
-	// Show API key masked
-	apiKey := maskAPIKey(config.APIKey)
-
-	fmt.Printf("%-25s %s\n", "OPENAI_API_KEY:", apiKey)
-	fmt.Printf("%-25s %s\n", "OPENAI_API_URL:", config.APIURL)
-	fmt.Printf("%-25s %s\n", "OPENAI_MODEL:", config.Model)
-	fmt.Printf("%-25s %s\n", "OPENAI_TIMEOUT:", config.Timeout)
-	fmt.Printf("%-25s %d\n", "OPENAI_MAX_TOKENS:", config.MaxTokens)
-	fmt.Printf("%-25s %.1f\n", "OPENAI_TEMPERATURE:", config.Temperature)
-	fmt.Printf("%-25s %s\n", "CHATGPT_CLI_CONFIG_DIR:", config.ConfigDir)
 
-	return nil
-}
-
-// configGetCommand gets a specific configuration value
-func configGetCommand(config *Config, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("configuration key required\nUsage: chatgpt-cli config get <key>")
+	if len(args) == 0 && len(files) == 0 && len(urls) == 0 && !*fromStdin && !*clipIn && prefix == "" && config.Messages == nil && *watchPath == "" {
+		return usageErrorf("prompt text is required\nUsage: chatgpt-cli prompt \"your prompt here\"")
 	}
 
-	key := strings.ToUpper(args[0])
-
-	switch key {
-	case "OPENAI_API_KEY":
-		apiKey := maskAPIKey(config.APIKey)
-		fmt.Println(apiKey)
-	case "OPENAI_API_URL":
-		fmt.Println(config.APIURL)
-	case "OPENAI_MODEL":
-		fmt.Println(config.Model)
-	case "OPENAI_TIMEOUT":
-		fmt.Println(config.Timeout)
-	case "OPENAI_MAX_TOKENS":
-		fmt.Println(config.MaxTokens)
-	case "OPENAI_TEMPERATURE":
-		fmt.Println(config.Temperature)
-	case "CHATGPT_CLI_CONFIG_DIR":
-		fmt.Println(config.ConfigDir)
-	default:
-		return fmt.Errorf("unknown configuration key: %s", key)
+	// Validate API key; a dry run never sends the request, so it doesn't need one
+	if !*dryRun {
+		if err := requireAPIKey(config); err != nil {
+			return err
+		}
+		warnAPIKeyFormat(config)
 	}
 
-	return nil
-}
+	if continued > 0 {
+		if err := loadConversation(config, int(continued)); err != nil {
+			return err
+		}
+	}
 
-// configSetCommand sets a configuration value
-func configSetCommand(config *Config, args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("both key and value required\nUsage: chatgpt-cli config set <key> <value>")
+	// Combine all arguments as the prompt; newlines within an argument are kept
+	text := strings.Join(args, " ")
+	if *fromStdin {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt from stdin: %w", err)
+		}
+		text = string(data)
+	}
+	if *clipIn {
+		clipped, err := newClipboard().Read()
+		if err != nil {
+			return fmt.Errorf("failed to read prompt from clipboard: %w", err)
+		}
+		text = clipped
+	}
+	if prefix != "" {
+		if strings.TrimSpace(text) == "" {
+			text = prefix
+		} else {
+			text = prefix + "\n\n" + text
+		}
+	}
+	if strings.TrimSpace(text) == "" && len(files) == 0 && len(urls) == 0 && config.Messages == nil && *watchPath == "" {
+		return usageErrorf("prompt cannot be empty")
 	}
 
-	key := strings.ToUpper(args[0])
-	value := args[1]
+	attachments, err := readAttachments(files, config.MaxFileSize)
+	if err != nil {
+		return fmt.Errorf("failed to attach file: %w", err)
+	}
+	attachments = append(attachments, fetchURLAttachments(config, urls)...)
+	if strings.TrimSpace(text) == "" && len(attachments) == 0 && config.Messages == nil && *watchPath == "" {
+		return fmt.Errorf("no --url page could be fetched and the prompt is empty")
+	}
 
-	// Validate the value
-	switch key {
-	case "OPENAI_API_KEY":
-		if value == "" {
-			return fmt.Errorf("API key cannot be empty")
+	// Attached files and pages are sent in full but only named in the log by default.
+	// The prefix and suffix go around everything else, as sent. A --messages
+	// conversation without a prompt gets neither.
+	parts := promptParts{text: text, attachments: attachments, prefix: *promptPrefix, suffix: *promptSuffix}
+	prompt := parts.build()
+	loggedPrompt := promptForLogParts(config, parts)
+
+	if *jsonResponse {
+		config.JSONResponse = true
+		if !supportsJSONResponse(config.Model) {
+			fmt.Fprintf(os.Stderr, "Warning: model %s may not support --json-response\n", config.Model)
 		}
+	}
 
-	case "OPENAI_API_URL":
-		if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
-			return fmt.Errorf("API URL must start with http:// or https://")
-		}
+	// Send request, streaming the response as it arrives unless disabled.
+	// JSON output, JSON replies, several choices, tool calls, extracts,
+	// checks of the response and repetitions need it complete, so they never
+	// stream.
+	stream := config.Stream && !*noStream && config.Output != outputJSON && !config.JSONResponse && config.Choices <= 1 && len(config.Tools) == 0 && !*quiet && *expect == "" && len(extracts) == 0 && *count == 1 && *watchPath == "" && verifyLanguage == ""
 
-	case "OPENAI_MODEL":
-		if value == "" {
-			return fmt.Errorf("model cannot be empty")
+	if *dryRun {
+		req, err := newChatRequest(config.requestContext(), config, prompt, stream)
+		if err != nil {
+			return err
 		}
+		return printDryRun(config, req)
+	}
 
-	case "OPENAI_TIMEOUT":
-		if _, err := time.ParseDuration(value); err != nil {
-			return fmt.Errorf("invalid timeout format (use format like '60s', '1m', '90s'): %w", err)
+	// Refuse to overwrite a file before spending a request on it
+	output, err := newResponseOutput(*outPath, *appendOut, *force, *delimiter)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	// Only checked when enabled, since it costs a request before the prompt.
+	// A watched file is checked on each run.
+	if *moderate && *watchPath == "" {
+		moderated := prompt
+		if config.Messages != nil {
+			moderated = messagesText(promptMessages(config, prompt))
 		}
-
-	case "OPENAI_MAX_TOKENS":
-		tokens, err := strconv.Atoi(value)
-		if err != nil || tokens <= 0 {
-			return fmt.Errorf("max tokens must be a positive integer")
+		if err := moderatePrompt(config, moderated, *force); err != nil {
+			entry := LogEntry{Timestamp: time.Now(), Command: command, Prompt: loggedPrompt, Messages: messagesSummary, Error: err.Error(), Style: styles}
+			if isCancelled(err) {
+				entry.Error = cancelledLogMessage
+			}
+			warnLogError(config, writeLogEntry(config, entry))
+			return err
 		}
+	}
 
-	case "OPENAI_TEMPERATURE":
-		temp, err := strconv.ParseFloat(value, 64)
-		if err != nil || temp < 0 || temp > 2 {
-			return fmt.Errorf("temperature must be a number between 0.0 and 2.0")
+	if *confirmCostFlag {
+		ok, err := confirmCost(config, prompt)
+		if err != nil {
+			return err
 		}
+		if !ok {
+			fmt.Fprintln(os.Stderr, "Prompt not sent")
+			return nil
+		}
+	}
 
-	case "CHATGPT_CLI_CONFIG_DIR":
-		return fmt.Errorf("CHATGPT_CLI_CONFIG_DIR cannot be set via config set command. Use the environment variable instead.")
+	// Render markdown only for humans: never when piped, raw or JSON
+	render := !*raw && config.Output != outputJSON && output.isTerminal()
 
-	default:
-		return fmt.Errorf("unknown configuration key: %s\nValid keys: OPENAI_API_KEY, OPENAI_API_URL, OPENAI_MODEL, OPENAI_TIMEOUT, OPENAI_MAX_TOKENS, OPENAI_TEMPERATURE", key)
+	// Recorded on the span of the run, when traced
+	span := spanFromContext(config.requestContext())
+	span.SetBool("chatgpt_cli.stream", stream)
+	if *count > 1 {
+		span.SetInt("chatgpt_cli.count", *count)
 	}
 
-	// Save to config file
-	if err := saveConfigFile(config.ConfigDir, map[string]string{key: value}); err != nil {
-		return fmt.Errorf("failed to save configuration: %w", err)
+	if *watchPath != "" {
+		base := LogEntry{Command: command, Messages: messagesSummary, Style: styles}
+		if err := watchPrompt(config, watchRun{path: *watchPath, interval: *watchInterval, parts: parts, entry: base, output: output, render: render, quiet: *quiet, showUsage: *showUsage, timing: *timing, moderate: *moderate, force: *force}); err != nil {
+			return err
+		}
+		return output.Close()
 	}
 
-	fmt.Printf("Set %s=%s\n", key, value)
-	fmt.Printf("Configuration saved to %s\n", filepath.Join(config.ConfigDir, "config"))
-	return nil
-}
-
-// sendChatRequest sends a request to the OpenAI API
-func sendChatRequest(config *Config, prompt string) (*ChatResponse, error) {
-	// Construct request payload
-	requestBody := ChatRequest{
-		Model: config.Model,
-		Messages: []Message{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		MaxTokens:   config.MaxTokens,
-		Temperature: config.Temperature,
+	if *count > 1 {
+		base := LogEntry{Command: command, Prompt: loggedPrompt, Messages: messagesSummary, Style: styles}
+		if err := repeatPrompt(config, repeatRun{count: *count, prompt: prompt, entry: base, output: output, render: render, quiet: *quiet, showUsage: *showUsage, timing: *timing}); err != nil {
+			return err
+		}
+		return output.Close()
 	}
 
-	// Marshal to JSON
-	jsonData, err := json.Marshal(requestBody)
+	client := newAPIClient(config)
+	var response *ChatResponse
+	var toolRuns []ToolRun
+	start := time.Now()
+	send := func() {
+		if stream {
+			var out io.Writer = output
+			if render {
+				md := newMarkdownWriter(output, colorEnabled(config))
+				defer md.Flush()
+				out = md
+			}
+			response, err = client.ChatStream(config.requestContext(), promptMessages(config, prompt), out)
+		} else if len(config.Tools) > 0 {
+			response, toolRuns, err = sendWithTools(config.requestContext(), client, prompt, *maxToolRounds)
+		} else {
+			stopProgress := startProgress(config)
+			response, err = client.Chat(config.requestContext(), promptMessages(config, prompt))
+			stopProgress()
+		}
+	}
+	send()
+	// The API refuses the request before replying, so nothing was streamed
+	// and it can be sent again once trimmed. The log records the request as
+	// sent the second time.
+	var trimmed string
+	if failure, ok := contextLengthExceeded(err); ok && config.AutoTrim && !*noTrim {
+		if trimmed = trimRequest(config, &parts, failure); trimmed != "" {
+			fmt.Fprintf(os.Stderr, "The prompt doesn't fit in the model's context; %s, then sending it again\n", trimmed)
+			prompt = parts.build()
+			loggedPrompt = promptForLogParts(config, parts)
+			if config.Messages != nil {
+				messagesSummary = summarizeMessages(config.Messages)
+			}
+			send()
+		}
+	}
+	if isCancelled(err) {
+		if stream {
+			// End the partially streamed line before the cancellation notice
+			fmt.Fprintln(output)
+		}
+		warnLogError(config, writeLogEntry(config, LogEntry{Timestamp: time.Now(), Command: command, Prompt: loggedPrompt, Messages: messagesSummary, Trimmed: trimmed, Error: cancelledLogMessage, ToolCalls: toolRuns, Style: styles}))
+		return errCancelled
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		warnLogError(config, writeLogEntry(config, LogEntry{Timestamp: time.Now(), Command: command, Prompt: loggedPrompt, Messages: messagesSummary, Trimmed: trimmed, Error: err.Error(), ToolCalls: toolRuns, Style: styles, RequestID: errorRequestID(err)}))
+		return fmt.Errorf("failed to get response: %w", err)
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", config.APIURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	// --verify checks the code of the reply, asking for fixes while it
+	// fails; what follows uses the last reply
+	var verified verifyResult
+	if verifyLanguage != "" {
+		response, verified, err = verifyReply(config, client, promptMessages(config, prompt), response, verifyLanguage, *verifyRetries)
+		if err != nil {
+			entry := LogEntry{Timestamp: time.Now(), Command: command, Prompt: loggedPrompt, Messages: messagesSummary, Trimmed: trimmed, Response: formatChoices(response, 1), Error: err.Error(), Style: styles,
+				RequestID: errorRequestID(err), Verify: verifyLanguage, VerifyRetries: verified.retries}
+			if isCancelled(err) {
+				entry.Error = cancelledLogMessage
+				warnLogError(config, writeLogEntry(config, entry))
+				return errCancelled
+			}
+			warnLogError(config, writeLogEntry(config, entry))
+			return fmt.Errorf("failed to get response: %w", err)
+		}
 	}
+	latency := time.Since(start)
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	content := formatChoices(response, config.Choices)
+	if *pick {
+		if content, err = pickChoice(response); err != nil {
+			warnLogError(config, writeLogEntry(config, LogEntry{Timestamp: time.Now(), Command: command, Prompt: loggedPrompt, Messages: messagesSummary, Trimmed: trimmed, Response: formatChoices(response, config.Choices), Error: err.Error(), Style: styles, RequestID: response.RequestID}))
+			return err
+		}
+	}
+	entry := LogEntry{
+		Timestamp: time.Now(),
+		Command:   command,
+		Prompt:    loggedPrompt,
+		Messages:  messagesSummary,
+		Trimmed:   trimmed,
+		Response:  content,
+		Usage:     usageOrNil(response.Usage),
+		Model:     responseModel(config, response),
+		LatencyMs: latency.Milliseconds(),
+		ToolCalls: toolRuns,
+		Style:     styles,
+		RequestID: response.RequestID,
+		Seed:      config.Seed,
+	}
+	if verifyLanguage != "" {
+		entry.Verify, entry.VerifyRetries = verifyLanguage, verified.retries
+		if !verified.skipped {
+			entry.Verified = &verified.passed
+		}
+	}
 
-	// Create client with timeout
-	client := &http.Client{
-		Timeout: config.Timeout,
+	// --extract keeps only part of the reply for everything that follows;
+	// the log still records all of it
+	reply := content
+	if len(extracts) > 0 {
+		if reply, err = runPostProcessors(content, extracts.postProcessors(*raw)); err != nil {
+			// Keep the reply available to pipelines that detect the failure
+			fmt.Fprintln(os.Stderr, content)
+			entry.Error = err.Error()
+			warnLogError(config, writeLogEntry(config, entry))
+			return err
+		}
+		render = false
 	}
 
-	// Send request
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	matched := true
+	if *expect != "" {
+		matched = matchesExpected(reply, *expect, *ignoreCase)
+		entry.Expect = *expect
+		entry.Matched = &matched
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	// A JSON reply is checked, then shown pretty-printed unless --raw
+	display := reply
+	if config.JSONResponse {
+		formatted, err := formatJSONResponse(content, *raw)
+		if err != nil {
+			// Keep the reply available to pipelines that detect the failure
+			fmt.Fprintln(os.Stderr, content)
+			entry.Error = err.Error()
+			warnLogError(config, writeLogEntry(config, entry))
+			return err
+		}
+		display = formatted
+		render = false
 	}
 
-	// Check status code first
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d, response: %s",
-			resp.StatusCode, string(body))
+	// Display response; with --quiet only the exit status and the usage
+	// lines tell the outcome
+	switch {
+	case *quiet:
+	case config.Output == outputJSON:
+		if err := writeJSON(output, newPromptOutput(response, reply)); err != nil {
+			return err
+		}
+	case !stream:
+		if render {
+			fmt.Fprintln(output, renderMarkdown(display, colorEnabled(config)))
+		} else {
+			fmt.Fprintln(output, display)
+		}
+	}
+	if err := output.Close(); err != nil {
+		return err
 	}
 
-	// Parse response
-	var chatResponse ChatResponse
-	if err := json.Unmarshal(body, &chatResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if *showUsage && config.Output != outputJSON {
+		fmt.Fprintln(os.Stderr, formatResponseUsage(config, response))
+	}
+	if *timing && config.Output != outputJSON {
+		fmt.Fprintln(os.Stderr, formatTiming(latency, response.Usage))
 	}
 
-	// Check for API errors
-	if chatResponse.Error != nil {
-		return nil, fmt.Errorf("API error: %s (type: %s)",
-			chatResponse.Error.Message, chatResponse.Error.Type)
+	// --exec runs the command of the reply once confirmed, so that the
+	// interaction is logged with it and its exit status
+	var execErr error
+	if *execute && matched {
+		execErr = execReply(config, &entry, reply, *yes)
 	}
 
-	return &chatResponse, nil
+	// Log successful interaction
+	warnLogError(config, writeLogEntry(config, entry))
+
+	// Only the reply itself is copied, never the JSON output around it
+	if *clipOut {
+		if err := newClipboard().Write(reply); err != nil {
+			return fmt.Errorf("failed to copy response to clipboard: %w", err)
+		}
+	}
+	if *notify {
+		notifyReply(config, reply, latency)
+	}
+
+	if !matched {
+		fmt.Fprintln(os.Stderr, strings.TrimSpace(reply))
+		return fmt.Errorf("response does not match --expect %q", *expect)
+	}
+	if verifyLanguage != "" && !verified.passed && !verified.skipped {
+		fmt.Fprintln(os.Stderr, verified.problems)
+		return fmt.Errorf("the code of the response fails --verify %s after %d %s", verifyLanguage, verified.retries, plural(verified.retries, "retry", "retries"))
+	}
+	return execErr
+}
+
+// Maximum size of a single line in the log file
+const maxLogLineSize = 10 * 1024 * 1024
+
+// durationFlag is a flag.Value holding a duration that may also be given as
+// a number of days, such as "7d"
+type durationFlag time.Duration
+
+func (d *durationFlag) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *durationFlag) Set(value string) error {
+	parsed, err := parseDurationWithDays(value)
+	if err != nil {
+		return err
+	}
+	*d = durationFlag(parsed)
+	return nil
+}
+
+// parseDurationWithDays parses a Go duration, or a whole number of days
+// followed by "d"
+func parseDurationWithDays(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// logFilter selects which log entries are displayed
+type logFilter struct {
+	Since      time.Time
+	Command    string
+	ErrorsOnly bool
+}
+
+// matches reports whether a log entry passes the filter
+func (f logFilter) matches(entry LogEntry) bool {
+	if !f.Since.IsZero() && entry.Timestamp.Before(f.Since) {
+		return false
+	}
+	if f.Command != "" && entry.Command != f.Command {
+		return false
+	}
+	if f.ErrorsOnly && entry.Error == "" {
+		return false
+	}
+	return true
+}
+
+// logFilterFlags holds the filter flags shared by the logs subcommands
+type logFilterFlags struct {
+	since      durationFlag
+	command    *string
+	errorsOnly *bool
+}
+
+// addLogFilterFlags defines the --since, --command and --errors-only flags on fs
+func addLogFilterFlags(fs *flag.FlagSet) *logFilterFlags {
+	f := &logFilterFlags{}
+	fs.Var(&f.since, "since", "show only entries newer than this duration (e.g. 24h or 7d)")
+	f.command = fs.String("command", "", "show only entries for this command")
+	f.errorsOnly = fs.Bool("errors-only", false, "show only entries with an error")
+	return f
+}
+
+// filter returns the log filter selected by the parsed flags
+func (f *logFilterFlags) filter() (logFilter, error) {
+	if f.since < 0 {
+		return logFilter{}, usageErrorf("--since must be a positive duration")
+	}
+
+	filter := logFilter{
+		Command:    *f.command,
+		ErrorsOnly: *f.errorsOnly,
+	}
+	if f.since > 0 {
+		filter.Since = time.Now().Add(-time.Duration(f.since))
+	}
+	return filter, nil
+}
+
+// logsCommand displays application logs
+func logsCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "clear":
+			return logsClearCommand(config, args[1:])
+		case "export":
+			return logsExportCommand(config, args[1:])
+		case "repair":
+			return logsRepairCommand(config, args[1:])
+		case "scan":
+			return logsScanCommand(config, args[1:])
+		case "scrub":
+			return logsScrubCommand(config, args[1:])
+		case "show":
+			return logsShowCommand(config, args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	tail := fs.Int("tail", 0, "show only the last N matching entries")
+	group := fs.Bool("group", false, "group entries by the run of the CLI that wrote them")
+	filterFlags := addLogFilterFlags(fs)
+	timeFlags := addTimestampFlags(fs)
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli logs [--tail N] [--since 24h] [--command name] [--errors-only] [--group] [--utc|--relative]", err)
+	}
+	if *tail < 0 {
+		return usageErrorf("--tail must be a non-negative integer")
+	}
+	times, err := timeFlags.format(config)
+	if err != nil {
+		return err
+	}
+
+	filter, err := filterFlags.filter()
+	if err != nil {
+		return err
+	}
+
+	// Read rotated files first so entries come out in chronological order
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	// Check if any log file exists
+	if len(logFiles) == 0 {
+		if config.Output == outputJSON {
+			return printJSON([]LogEntry{})
+		}
+		fmt.Println("No logs found.")
+		if config.LogDisabled {
+			fmt.Printf("Logging is disabled by %s.\n", envLogDisabled)
+		}
+		return nil
+	}
+
+	entries, skipped, err := readLogEntries(logFiles, filter, *tail)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	defer reportCorruptLogLines(newUI(config, os.Stderr), skipped)
+
+	if config.Output == outputJSON {
+		if *group {
+			groups := groupLogEntries(entries)
+			if groups == nil {
+				groups = []LogGroup{}
+			}
+			return printJSON(groups)
+		}
+		if entries == nil {
+			entries = []LogEntry{}
+		}
+		return printJSON(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No logs found.")
+		return nil
+	}
+
+	if *group {
+		printLogGroups(newUI(config, os.Stdout), groupLogEntries(entries), config.LogPreviewLen, times)
+		return nil
+	}
+	printLogEntries(newUI(config, os.Stdout), entries, config.LogPreviewLen, times)
+	return nil
+}
+
+// logsShowCommand prints the entry numbered n in the logs listing made with
+// the same flags, with --full printing its prompt and response whole
+func logsShowCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli logs show <n> [--full] [--utc|--relative] [--tail N] [--since 24h] [--command name] [--errors-only]"
+
+	fs := flag.NewFlagSet("logs show", flag.ContinueOnError)
+	full := fs.Bool("full", false, "print the prompt and response without truncation")
+	tail := fs.Int("tail", 0, "number the last N matching entries, as logs --tail does")
+	filterFlags := addLogFilterFlags(fs)
+	timeFlags := addTimestampFlags(fs)
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) != 1 {
+		return usageErrorf("log entry number required\n%s", usage)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return usageErrorf("invalid log entry number %q: expected a number from chatgpt-cli logs", args[0])
+	}
+	if *tail < 0 {
+		return usageErrorf("--tail must be a non-negative integer")
+	}
+	times, err := timeFlags.format(config)
+	if err != nil {
+		return err
+	}
+
+	filter, err := filterFlags.filter()
+	if err != nil {
+		return err
+	}
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	entries, skipped, err := readLogEntries(logFiles, filter, *tail)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	defer reportCorruptLogLines(newUI(config, os.Stderr), skipped)
+
+	switch {
+	case len(entries) == 0:
+		return usageErrorf("no log entries match")
+	case n < 1 || n > len(entries):
+		return usageErrorf("log entry %d out of range: choose 1 to %d", n, len(entries))
+	}
+	entry := entries[n-1]
+
+	if config.Output == outputJSON {
+		return printJSON(entry)
+	}
+	printLogEntry(newUI(config, os.Stdout), n, entry, config.LogPreviewLen, *full, times)
+	return nil
+}
+
+// printLogEntries prints log entries for the logs command, with prompts and
+// responses cut to previewLen characters, followed by their total token usage
+func printLogEntries(out *ui, entries []LogEntry, previewLen int, times timestampFormat) {
+	out.Printf("Showing %d log entries:\n\n", len(entries))
+
+	for i, entry := range entries {
+		printLogEntry(out, i+1, entry, previewLen, false, times)
+		out.Println()
+	}
+	printTotalTokens(out, entries)
+}
+
+// printTotalTokens prints the tokens used by entries, if they recorded any
+func printTotalTokens(out *ui, entries []LogEntry) {
+	var total Usage
+	for _, entry := range entries {
+		if entry.Usage != nil {
+			total.add(*entry.Usage)
+		}
+	}
+	if total.TotalTokens > 0 {
+		out.Printf("%s %d (prompt: %d, completion: %d)\n", out.bold("Total tokens:"),
+			total.TotalTokens, total.PromptTokens, total.CompletionTokens)
+	}
+}
+
+// printLogEntry prints the entry numbered n. The prompt, response and
+// command run are shown on one line each, cut to previewLen characters, or
+// with full, whole on the lines after their label.
+func printLogEntry(out *ui, n int, entry LogEntry, previewLen int, full bool, times timestampFormat) {
+	command := entry.Command
+	if entry.Pass > 0 {
+		command += fmt.Sprintf(" (pass %d)", entry.Pass)
+	}
+	if entry.Step != "" {
+		command += fmt.Sprintf(" (step %s)", entry.Step)
+	}
+	if entry.Style != "" {
+		command += fmt.Sprintf(" (style %s)", entry.Style)
+	}
+	if entry.Chunk != "" {
+		command += fmt.Sprintf(" (chunk %s)", entry.Chunk)
+	}
+	if entry.Run > 0 {
+		command += fmt.Sprintf(" (run %d)", entry.Run)
+	}
+	if entry.Seed != nil {
+		command += fmt.Sprintf(" (seed %d)", *entry.Seed)
+	}
+	if full && entry.Model != "" {
+		command += fmt.Sprintf(" (%s)", entry.Model)
+	}
+	out.Printf("%s %s - %s\n", out.bold(fmt.Sprintf("[%d]", n)), out.dim(times.format(entry.Timestamp)), command)
+
+	text := func(label, s string, previewLen int) {
+		if full {
+			out.Printf("    %s\n%s\n", out.dim(label), strings.TrimRight(s, "\n"))
+			return
+		}
+		out.Printf("    %s %s\n", out.dim(label), truncate(oneLine(s), previewLen))
+	}
+	if entry.Messages != "" {
+		out.Printf("    %s %s\n", out.dim("Messages:"), entry.Messages)
+	}
+	if entry.Trimmed != "" {
+		out.Printf("    %s %s\n", out.dim("Trimmed:"), entry.Trimmed)
+	}
+	if entry.Prompt != "" {
+		text("Prompt:", entry.Prompt, previewLen)
+	}
+	if entry.Response != "" {
+		text("Response:", entry.Response, previewLen)
+	}
+	if entry.Error != "" {
+		out.Printf("    %s\n", out.red("Error: "+entry.Error))
+	}
+	// The request ID is what support asks for about a failed request
+	if entry.RequestID != "" && (full || entry.Error != "") {
+		out.Printf("    %s %s\n", out.dim("Request ID:"), entry.RequestID)
+	}
+	if full && entry.Invocation != "" {
+		out.Printf("    %s %s\n", out.dim("Run:"), entry.Invocation)
+	}
+	if entry.Usage != nil {
+		out.Printf("    %s %d (prompt: %d, completion: %d)\n", out.dim("Tokens:"),
+			entry.Usage.TotalTokens, entry.Usage.PromptTokens, entry.Usage.CompletionTokens)
+	}
+	// Entries logged before durations were recorded have none
+	if entry.LatencyMs > 0 {
+		out.Printf("    %s %s\n", out.dim("Duration:"), formatLatency(entry.LatencyMs))
+	}
+	if entry.Matched != nil {
+		result := "matched"
+		if !*entry.Matched {
+			result = out.red("did not match")
+		}
+		out.Printf("    %s %q, %s\n", out.dim("Expect:"), entry.Expect, result)
+	}
+	if entry.Verify != "" {
+		result := "not checked"
+		switch {
+		case entry.Verified == nil:
+		case *entry.Verified:
+			result = "passed"
+		default:
+			result = out.red("failed")
+		}
+		if entry.VerifyRetries > 0 {
+			result += fmt.Sprintf(" after %d %s", entry.VerifyRetries, plural(entry.VerifyRetries, "retry", "retries"))
+		}
+		out.Printf("    %s %s, %s\n", out.dim("Verify:"), entry.Verify, result)
+	}
+	if entry.Exec != "" {
+		status := "not run"
+		if entry.ExitStatus != nil {
+			status = fmt.Sprintf("exit status %d", *entry.ExitStatus)
+		}
+		if entry.ExitStatus != nil && *entry.ExitStatus != 0 {
+			status = out.red(status)
+		}
+		if full {
+			text("Exec:", entry.Exec, 0)
+			out.Printf("    %s %s\n", out.dim("Exit:"), status)
+		} else {
+			out.Printf("    %s %s -> %s\n", out.dim("Exec:"), truncate(oneLine(entry.Exec), 60), status)
+		}
+	}
+	for _, run := range entry.ToolCalls {
+		result := fmt.Sprintf("%d bytes", run.ResultBytes)
+		if run.Error != "" {
+			result = out.red("error: " + run.Error)
+		}
+		out.Printf("    %s %s(%s) -> %s\n", out.dim("Tool:"), run.Name, run.Arguments, result)
+	}
+}
+
+// readLogEntries scans the log files line by line and returns the entries that
+// match the filter, along with the number of lines that could not be parsed.
+// If tail is positive, only the last tail matches are kept.
+func readLogEntries(logFiles []string, filter logFilter, tail int) ([]LogEntry, int, error) {
+	var entries []LogEntry
+
+	skipped, err := forEachLogEntry(logFiles, func(entry LogEntry) error {
+		if !filter.matches(entry) {
+			return nil
+		}
+
+		entries = append(entries, entry)
+		if tail > 0 && len(entries) > tail {
+			entries = entries[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, skipped, err
+	}
+
+	return entries, skipped, nil
+}
+
+// reportCorruptLogLines tells how many log lines were skipped
+func reportCorruptLogLines(out *ui, skipped int) {
+	if skipped == 0 {
+		return
+	}
+	noun := "entries"
+	if skipped == 1 {
+		noun = "entry"
+	}
+	out.Printf("%s log %s could not be parsed, run 'chatgpt-cli logs repair'\n", out.yellow(strconv.Itoa(skipped)), noun)
+}
+
+// forEachLogEntry calls fn for every valid entry in the given log files, in
+// order, without loading the files into memory. Invalid lines are skipped and
+// their number returned.
+func forEachLogEntry(logFiles []string, fn func(LogEntry) error) (int, error) {
+	skipped := 0
+	for _, logFile := range logFiles {
+		n, err := forEachLogEntryInFile(logFile, fn)
+		skipped += n
+		if err != nil {
+			return skipped, err
+		}
+	}
+	return skipped, nil
+}
+
+func forEachLogEntryInFile(logFile string, fn func(LogEntry) error) (int, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	skipped := 0
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			skipped++
+			continue
+		}
+
+		if err := fn(entry); err != nil {
+			return skipped, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return skipped, fmt.Errorf("%s has a line longer than %d MB; run 'chatgpt-cli logs repair'", filepath.Base(logFile), maxLogLineSize>>20)
+		}
+		return skipped, err
+	}
+	return skipped, nil
+}
+
+// Subcommands of the config command
+var configSubcommands = []string{"list", "get", "set", "unset", "reset", "validate", "set-default", "get-default", "unset-default", "fix-permissions", "schema"}
+
+// configCommand manages configuration
+func configCommand(config *Config, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("config subcommand required\nUsage: chatgpt-cli config <%s>", strings.Join(configSubcommands, "|"))
+	}
+
+	subcommand := args[0]
+
+	switch subcommand {
+	case "list":
+		return configListCommand(config, args[1:])
+	case "get":
+		return configGetCommand(config, args[1:])
+	case "set":
+		return configSetCommand(config, args[1:])
+	case "unset":
+		return configUnsetCommand(config, args[1:])
+	case "reset":
+		return configResetCommand(config, args[1:])
+	case "validate":
+		return configValidateCommand(config, args[1:])
+	case "set-default":
+		return configSetDefaultCommand(config, args[1:])
+	case "get-default":
+		return configGetDefaultCommand(config, args[1:])
+	case "unset-default":
+		return configUnsetDefaultCommand(config, args[1:])
+	case "fix-permissions":
+		return configFixPermissionsCommand(config, args[1:])
+	case "schema":
+		return configSchemaCommand(config, args[1:])
+	default:
+		return usageErrorf("unknown config subcommand: %s\nValid subcommands: %s", subcommand, strings.Join(configSubcommands, ", "))
+	}
+}
+
+// configListCommand lists all configuration values
+func maskAPIKey(apiKey string) string {
+	if apiKey != "" {
+		if len(apiKey) > 8 {
+			return apiKey[:4] + "..." + apiKey[len(apiKey)-4:]
+		}
+		return "***"
+	}
+	return "(not set)"
+}
+
+// configValue is a single configuration key and its display value
+type configValue struct {
+	Key   string
+	Value string
+}
+
+// configValues returns all configuration values in display order
+func configValues(config *Config) []configValue {
+	var values []configValue
+	for _, key := range configKeys {
+		// Only the configured provider's API key is loaded
+		if key.value == nil || (key.secret && key.name != apiKeyName(config.Provider)) {
+			continue
+		}
+		values = append(values, configValue{key.name, key.value(config)})
+	}
+	return values
+}
+
+func configListCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("config list", flag.ContinueOnError)
+	model := fs.String("model", "", "show the values in effect for this model")
+	showOrigin := fs.Bool("show-origin", false, "show where each value comes from")
+	args, err := parseFlags(fs, args)
+	if err != nil || len(args) > 0 {
+		if err == nil {
+			err = fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		return usageErrorf("%w\nUsage: chatgpt-cli config list [--model name] [--show-origin]", err)
+	}
+	if *model != "" {
+		useModel(config, *model)
+	}
+
+	values := configValues(config)
+	var origins map[string]string
+	if *showOrigin {
+		origins = configOrigins(config)
+		if *model != "" {
+			origins[envModel] = configSourceFlag
+		}
+	}
+
+	if config.Output == outputJSON {
+		if *showOrigin {
+			m := make(map[string]ConfigValueOrigin, len(values))
+			for _, v := range values {
+				m[v.Key] = ConfigValueOrigin{Value: v.Value, Origin: origins[v.Key]}
+			}
+			return printJSON(m)
+		}
+		m := make(map[string]string, len(values))
+		for _, v := range values {
+			m[v.Key] = v.Value
+		}
+		return printJSON(m)
+	}
+
+	// Values set for the model are marked as such, unless their origin says
+	// so, and those expanded from the environment shown as written too
+	out := newUI(config, os.Stdout)
+	scoped := modelScopedValues(config)
+	for i, v := range values {
+		if scoped[v.Key] && origins == nil {
+			values[i].Value += " " + out.dim("(model "+config.Model+")")
+		}
+		if raw, ok := rawConfigValue(config, v.Key); ok && v.Key != apiKeyName(config.Provider) {
+			values[i].Value += " " + out.dim("(expanded from "+raw+")")
+		}
+	}
+
+	printConfigValues(out, values, origins)
+	return nil
+}
+
+// printConfigValues prints the values of config list under a heading on a
+// terminal, or as tab-separated keys and values when piped. With origins,
+// each line starts with where the value comes from, as git config
+// --show-origin does.
+func printConfigValues(out *ui, values []configValue, origins map[string]string) {
+	if out.tty {
+		out.heading("Current Configuration:")
+	}
+	t := newTable()
+	for _, v := range values {
+		if origins != nil {
+			t.addRow(origins[v.Key], out.dim(v.Key), v.Value)
+		} else {
+			t.addRow(out.dim(v.Key), v.Value)
+		}
+	}
+	t.render(out)
+}
+
+// configGetCommand gets a specific configuration value
+func configGetCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("config get", flag.ContinueOnError)
+	origin := fs.Bool("origin", false, "print where the value comes from instead of the value")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli config get <key> [--origin]", err)
+	}
+	if len(args) == 0 {
+		return usageErrorf("configuration key required\nUsage: chatgpt-cli config get <key> [--origin]")
+	}
+
+	key := strings.ToUpper(args[0])
+	k, ok := lookupConfigKey(key)
+	switch {
+	case ok && k.value != nil && *origin:
+		fmt.Println(configOrigins(config)[key])
+	case ok && k.secret && k.value != nil:
+		// Only the configured provider's key is loaded
+		if key == apiKeyName(config.Provider) {
+			fmt.Println(displayAPIKey(config))
+		} else {
+			fmt.Println()
+		}
+	case ok && k.value != nil:
+		fmt.Println(k.value(config))
+	default:
+		return usageErrorf("unknown configuration key: %s", key)
+	}
+
+	return nil
+}
+
+// configSetCommand sets a configuration value
+func configSetCommand(config *Config, args []string) error {
+	out := newUI(config, os.Stdout)
+	fs := flag.NewFlagSet("config set", flag.ContinueOnError)
+	encrypt := fs.Bool("encrypt", false, "store OPENAI_API_KEY encrypted with a passphrase")
+	model := fs.String("model", "", "set the value only for this model")
+	fs.SetOutput(io.Discard)
+
+	// Flags only come before the key, since values such as -0.5 look like flags
+	if err := fs.Parse(args); err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli config set [--encrypt] [--model name] <key> <value>", err)
+	}
+	args = fs.Args()
+	if len(args) < 2 {
+		return usageErrorf("both key and value required\nUsage: chatgpt-cli config set <key> <value>")
+	}
+
+	key := strings.ToUpper(args[0])
+	if m, k, ok := parseModelScopedKey(args[0]); ok && *model == "" {
+		*model, key = m, k
+	}
+	value, err := validateConfigValue(key, args[1])
+	if err != nil {
+		return withKind(ErrUsage, err)
+	}
+	if warning := configValueWarning(key, value); warning != "" {
+		errOut := newUI(config, os.Stderr)
+		errOut.Printf("%s %s\n", errOut.yellow("Warning:"), warning)
+	}
+	if *model != "" {
+		return configSetModelValue(config, *model, key, value)
+	}
+
+	values := map[string]string{key: value}
+	if key == envAPIKey {
+		encryptSet := false
+		fs.Visit(func(f *flag.Flag) { encryptSet = encryptSet || f.Name == "encrypt" })
+		if !encryptSet && isTerminal(os.Stdin) {
+			*encrypt = confirm("Encrypt the API key with a passphrase?")
+		}
+
+		// The plain and encrypted forms replace each other
+		values[encryptedAPIKeyKey] = ""
+		if *encrypt {
+			passphrase, err := readPassphrase("New passphrase: ", true)
+			if err != nil {
+				return err
+			}
+			if values[encryptedAPIKeyKey], err = encryptAPIKey(value, passphrase); err != nil {
+				return fmt.Errorf("failed to encrypt API key: %w", err)
+			}
+			values[key] = ""
+		}
+	} else if *encrypt {
+		return usageErrorf("--encrypt only applies to %s", envAPIKey)
+	}
+
+	// Save to the active profile's section of the config file
+	if err := saveProfileConfig(config.ConfigDir, config.Profile, values); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	if *encrypt {
+		out.Printf("Set %s (encrypted)\n", key)
+	} else {
+		out.Printf("Set %s=%s\n", key, value)
+	}
+	out.Printf("Configuration saved to %s (profile: %s)\n", configFilePath(config.ConfigDir), profileDisplayName(config.Profile))
+	if key == apiKeyName(config.Provider) && config.apiKeySource != "" && config.apiKeySource != apiKeySourceEnv && config.apiKeySource != apiKeySourceFile {
+		out.Printf("%s the API key in the %s takes precedence; remove it with: chatgpt-cli auth logout\n", out.dim("Note:"), config.apiKeySource)
+	}
+	return nil
 }
 
-// formatResponse formats the ChatGPT response for display
-func formatResponse(response *ChatResponse) string {
-	if len(response.Choices) == 0 {
-		return "No response received from ChatGPT"
+// configSetModelValue saves a value of config set --model to the model's
+// table of the config file
+func configSetModelValue(config *Config, model, key, value string) error {
+	if !isModelScopedKey(key) {
+		return usageErrorf("%s cannot be set for a single model\nModel keys: %s", key, strings.Join(modelScopedKeys, ", "))
+	}
+	if err := saveModelConfig(config.ConfigDir, model, map[string]string{key: value}); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	out := newUI(config, os.Stdout)
+	out.Printf("Set %s=%s for model %s\n", key, value, model)
+	out.Printf("Configuration saved to %s\n", configFilePath(config.ConfigDir))
+	if os.Getenv(key) != "" {
+		out.Printf("%s %s is set in the environment and takes precedence\n", out.dim("Note:"), key)
+	}
+	return nil
+}
+
+// validateConfigValue checks a value given to config set and returns it in
+// the form it is saved
+func validateConfigValue(key, value string) (string, error) {
+	k, ok := lookupConfigKey(key)
+	if !ok || (!k.file && k.validate == nil) {
+		return "", fmt.Errorf("unknown configuration key: %s\nValid keys: %s", key, strings.Join(settableConfigKeys(), ", "))
+	}
+	return k.check(value)
+}
+
+// configValueWarning returns a warning about a value that is accepted but
+// looks wrong, or "" if there is nothing to say
+func configValueWarning(key, value string) string {
+	switch {
+	case key == "OPENAI_ORG_ID" && !strings.HasPrefix(value, "org-"):
+		return "organization IDs usually start with org-"
+	case key == "OPENAI_PROJECT_ID" && !strings.HasPrefix(value, "proj_"):
+		return "project IDs usually start with proj_"
+	}
+	return ""
+}
+
+// configUnsetCommand removes a configuration value from the config file
+func configUnsetCommand(config *Config, args []string) error {
+	out := newUI(config, os.Stdout)
+	fs := flag.NewFlagSet("config unset", flag.ContinueOnError)
+	model := fs.String("model", "", "remove the value set for this model")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli config unset [--model name] <key>", err)
+	}
+	if len(args) == 0 {
+		return usageErrorf("configuration key required\nUsage: chatgpt-cli config unset <key>")
+	}
+
+	key := strings.ToUpper(args[0])
+	if m, k, ok := parseModelScopedKey(args[0]); ok && *model == "" {
+		*model, key = m, k
+	}
+	if *model != "" {
+		return configUnsetModelValue(config, *model, key)
+	}
+
+	if key == envConfigDir {
+		return usageErrorf("CHATGPT_CLI_CONFIG_DIR cannot be unset via config unset command. Use the environment variable instead.")
+	}
+	if !isConfigFileKey(key) {
+		return usageErrorf("unknown configuration key: %s\nValid keys: %s", key, strings.Join(configFileKeys, ", "))
+	}
+
+	sections, err := loadConfigSections(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	// Unsetting the API key removes it in either form
+	values := map[string]string{key: ""}
+	if key == envAPIKey {
+		values[encryptedAPIKeyKey] = ""
+	}
+
+	exists := false
+	for k := range values {
+		_, found := sections[config.Profile][k]
+		exists = exists || found
+	}
+	if !exists {
+		out.Printf("%s is not set in profile %s of the config file, nothing to do\n", key, profileDisplayName(config.Profile))
+		return nil
+	}
+
+	// An empty value removes the key when the file is rewritten
+	if err := saveProfileConfig(config.ConfigDir, config.Profile, values); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	out.Printf("Unset %s\n", key)
+	if os.Getenv(key) != "" {
+		out.Printf("%s %s is still set in the environment and takes precedence\n", out.dim("Note:"), key)
+	}
+	return nil
+}
+
+// configUnsetModelValue removes a value of config unset --model from the
+// model's table of the config file
+func configUnsetModelValue(config *Config, model, key string) error {
+	out := newUI(config, os.Stdout)
+	if !isModelScopedKey(key) {
+		return usageErrorf("%s cannot be set for a single model\nModel keys: %s", key, strings.Join(modelScopedKeys, ", "))
+	}
+
+	modelConfigs, err := loadModelConfigs(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	if _, exists := modelConfigs[model][key]; !exists {
+		out.Printf("%s is not set for model %s in the config file, nothing to do\n", key, model)
+		return nil
+	}
+
+	if err := saveModelConfig(config.ConfigDir, model, map[string]string{key: ""}); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+	out.Printf("Unset %s for model %s\n", key, model)
+	return nil
+}
+
+// configResetCommand removes all values from the config file
+func configResetCommand(config *Config, args []string) error {
+	out := newUI(config, os.Stdout)
+	fs := flag.NewFlagSet("config reset", flag.ContinueOnError)
+	force := fs.Bool("force", false, "skip the confirmation prompt")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli config reset [--force]", err)
+	}
+
+	doc, err := loadConfigDocument(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	fileConfig := doc.sections()[config.Profile]
+
+	// A named profile is removed entirely, even when it has no values left
+	if len(fileConfig) == 0 && config.Profile == "" {
+		out.Println("Config file has no values to reset")
+		return nil
+	}
+
+	configFile := configFilePath(config.ConfigDir)
+	question := fmt.Sprintf("Remove all %d values from %s?", len(fileConfig), configFile)
+	if config.Profile != "" {
+		question = fmt.Sprintf("Remove profile %s and its %d values from %s?", config.Profile, len(fileConfig), configFile)
+	}
+	if !*force && !confirm(question) {
+		out.Println("Reset cancelled")
+		return nil
+	}
+
+	// Comments and unknown keys of the default section are kept. The file is
+	// read again, since it may have changed while the question was asked.
+	err = updateConfigDocument(config.ConfigDir, func(doc *configDocument) {
+		if config.Profile != "" {
+			doc.removeProfile(config.Profile)
+		} else {
+			doc.clearDefault()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	out.Printf("Configuration reset, removed %d values from %s (profile: %s)\n", len(fileConfig), configFile, profileDisplayName(config.Profile))
+	return nil
+}
+
+// isConfigFileKey reports whether key can be stored in the config file
+func isConfigFileKey(key string) bool {
+	for _, k := range configFileKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// confirm asks a yes/no question on stdout and reads the answer from stdin.
+// Anything other than "y" or "yes" counts as no.
+func confirm(question string) bool {
+	return confirmOn(os.Stdout, question)
+}
+
+// confirmOn asks question on w and reads the answer from stdin
+func confirmOn(w io.Writer, question string) bool {
+	fmt.Fprintf(w, "%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// newChatRequest builds the HTTP request for a chat completion without
+// sending it
+func newChatRequest(ctx context.Context, config *Config, prompt string, stream bool) (*http.Request, error) {
+	return newProviderRequest(ctx, config, promptMessages(config, prompt), stream)
+}
+
+// newProviderRequest builds the provider's request for a conversation and
+// adds the extra headers
+func newProviderRequest(ctx context.Context, config *Config, messages []Message, stream bool) (*http.Request, error) {
+	req, err := chatProviderFor(config).NewRequest(ctx, config, messages, stream)
+	if err != nil {
+		return nil, err
+	}
+	if err := setExtraHeaders(req, config); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// promptMessages returns the conversation of a single prompt, after the
+// system prompt of a built-in command and the exchanges of prompt --continue
+// if there are any. After the conversation of prompt --messages, the prompt
+// is only sent if there is one.
+func promptMessages(config *Config, prompt string) []Message {
+	if config.Messages != nil {
+		messages := append([]Message(nil), config.Messages...)
+		if prompt != "" {
+			messages = append(messages, Message{Role: "user", Content: prompt})
+		}
+		return messages
+	}
+
+	var messages []Message
+	if config.SystemPrompt != "" {
+		messages = append(messages, Message{Role: "system", Content: config.SystemPrompt})
+	}
+	messages = append(messages, config.History...)
+	return append(messages, Message{
+		Role:    "user",
+		Content: prompt,
+	})
+}
+
+// openAIProvider speaks the OpenAI chat completions API, which Azure OpenAI
+// serves too
+type openAIProvider struct{}
+
+func (openAIProvider) NewRequest(ctx context.Context, config *Config, messages []Message, stream bool) (*http.Request, error) {
+	// Marshal to JSON
+	jsonData, err := json.Marshal(openAIRequestBody(config, messages, stream))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return newOpenAIRequest(ctx, config, jsonData, stream)
+}
+
+// openAIRequestBody builds the chat completion payload of the messages
+func openAIRequestBody(config *Config, messages []Message, stream bool) ChatRequest {
+	requestBody := ChatRequest{
+		Model:            requestModel(config),
+		Messages:         messages,
+		MaxTokens:        config.MaxTokens,
+		Temperature:      config.Temperature,
+		TopP:             config.TopP,
+		PresencePenalty:  config.PresencePenalty,
+		FrequencyPenalty: config.FrequencyPenalty,
+		Stop:             config.Stop,
+		Seed:             config.Seed,
+		Stream:           stream,
+	}
+	if stream {
+		requestBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	if config.JSONResponse {
+		requestBody.ResponseFormat = &ResponseFormat{Type: responseFormatJSONObject}
+	}
+	if config.Choices > 1 {
+		requestBody.N = config.Choices
+	}
+	if len(config.Tools) > 0 {
+		requestBody.Tools = toolDefinitions(config.Tools)
+		requestBody.ToolChoice = "auto"
+	}
+	warnDroppedFields(config, shapeRequest(&requestBody, config, capabilitiesFor(config)))
+	return requestBody
+}
+
+// newOpenAIRequest creates the HTTP request posting a chat completion body
+func newOpenAIRequest(ctx context.Context, config *Config, jsonData []byte, stream bool) (*http.Request, error) {
+	apiURL, err := chatCompletionsURL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create HTTP request
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeader(req, config)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return req, nil
+}
+
+func (openAIProvider) ReadResponse(resp *http.Response) (*ChatResponse, error) {
+	return readChatResponse(resp)
+}
+
+func (openAIProvider) ReadStream(r io.Reader, w io.Writer) (*ChatResponse, error) {
+	return readChatStream(r, w)
+}
+
+// readChatResponse reads and validates a non-streaming chat completion response
+func readChatResponse(resp *http.Response) (*ChatResponse, error) {
+	// Read response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check status code first
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp.StatusCode, body)
 	}
 
-	content := response.Choices[0].Message.Content
-	return strings.TrimSpace(content)
+	// Parse response
+	var chatResponse ChatResponse
+	if err := json.Unmarshal(body, &chatResponse); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Check for API errors
+	if chatResponse.Error != nil {
+		return nil, newAPIError(resp.StatusCode, chatResponse.Error)
+	}
+
+	return &chatResponse, nil
+}
+
+// formatResponse formats the ChatGPT response for display
+func formatResponse(response *ChatResponse) string {
+	return formatChoices(response, 1)
 }
 
 // logEntry logs an application event
-func logEntry(config *Config, command, prompt, response, errorMsg string) {
-	entry := LogEntry{
+func logEntry(config *Config, command, prompt, response, errorMsg string) error {
+	return writeLogEntry(config, LogEntry{
 		Timestamp: time.Now(),
 		Command:   command,
 		Prompt:    prompt,
 		Response:  response,
 		Error:     errorMsg,
-	}
+	})
+}
 
-	logFile := filepath.Join(config.ConfigDir, "logs.jsonl")
+// writeLogEntry appends an entry to the log file, rotating it first if the
+// entry would push it past the size cap. Sensitive content is redacted first
+// unless redaction is off. It is safe for concurrent use, and does nothing
+// when logging is disabled.
+func writeLogEntry(config *Config, entry LogEntry) error {
+	if config.LogDisabled {
+		return nil
+	}
+	logFile := filepath.Join(config.ConfigDir, logFileName)
+	if entry.Invocation == "" {
+		entry.Invocation = invocationID
+	}
+	// Stored in UTC, and shown in the zone chosen when read
+	entry.Timestamp = entry.Timestamp.UTC()
+
+	// An invalid redact.rules stops the write rather than log what the user
+	// meant to keep out
+	if config.Redact {
+		r, err := config.logRedactor()
+		if err != nil {
+			return err
+		}
+		r.redactEntry(&entry)
+	}
 
 	// Marshal to JSON
 	data, err := json.Marshal(entry)
 	if err != nil {
-		return // Silent failure for logging
+		return err
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if err := rotateLogsIfNeeded(config, int64(len(data)+1)); err != nil {
+		return fmt.Errorf("failed to rotate logs: %w", err)
 	}
 
 	// Append to log file
 	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return // Silent failure
+		return err
 	}
-	defer f.Close()
 
-	if _, err := f.Write(data); err != nil {
-		return // Silent failure
-	}
-	if _, err := f.WriteString("\n"); err != nil {
-		return // Silent failure
+	// Write the entry and its newline in one call, so that lines of other
+	// goroutines or processes appending at the same time never interleave
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
 }
 
-// truncate truncates a string to a maximum length
+// truncate cuts a string to at most maxLen characters, ending it with ...
+// when it was cut. A maxLen of 0 or less means no limit. Characters are
+// counted rather than bytes, and the cut is moved back so that it never
+// splits a character from the marks, joiners and modifiers that combine
+// with it, such as the parts of an emoji sequence.
 func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+	runes := []rune(s)
+	if maxLen <= 0 || len(runes) <= maxLen {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+	if maxLen <= 3 {
+		return strings.Repeat(".", maxLen)
+	}
+	cut := maxLen - 3
+	for cut > 0 && !canCutBefore(runes, cut) {
+		cut--
+	}
+	return string(runes[:cut]) + "..."
+}
+
+// canCutBefore reports whether text can be cut between runes[i-1] and
+// runes[i] without breaking up what displays as one character
+func canCutBefore(runes []rune, i int) bool {
+	r, prev := runes[i], runes[i-1]
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return false
+	case r == zeroWidthJoiner || prev == zeroWidthJoiner:
+		return false
+	case r >= 0xFE00 && r <= 0xFE0F: // variation selectors
+		return false
+	case r >= 0x1F3FB && r <= 0x1F3FF: // skin tone modifiers
+		return false
+	case r >= 0xE0020 && r <= 0xE007F: // tags, as in subdivision flags
+		return false
+	case isRegionalIndicator(r) && isRegionalIndicator(prev):
+		// Flags are pairs of regional indicators: cut between pairs only
+		n := 0
+		for j := i - 1; j >= 0 && isRegionalIndicator(runes[j]); j-- {
+			n++
+		}
+		return n%2 == 0
+	}
+	return true
+}
+
+// Joins the emoji around it into one, as in family emoji
+const zeroWidthJoiner = 0x200D
+
+// isRegionalIndicator reports whether r is one of the letters flags are made of
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// oneLine marks line breaks with ⏎ so multi-line text fits a one-line preview
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", "⏎", "\n", "⏎").Replace(s)
 }
 
 // getCommands returns all available commands
 func getCommands() map[string]Command {
-	return map[string]Command{
+	commands := map[string]Command{
 		"help": {
 			Name:        "help",
 			Description: "Show help message",
@@ -663,12 +2758,152 @@ func getCommands() map[string]Command {
 			Description: "Display application logs",
 			Handler:     logsCommand,
 		},
+		"history": {
+			Name:        "history",
+			Description: "List, show or re-run past prompts",
+			Handler:     historyCommand,
+		},
+		"search": {
+			Name:        "search",
+			Description: "Search past prompts and responses",
+			Handler:     searchCommand,
+		},
+		"models": {
+			Name:        "models",
+			Description: "List available models",
+			Handler:     modelsCommand,
+		},
+		"batch": {
+			Name:        "batch",
+			Description: "Send prompts from a file",
+			Handler:     batchCommand,
+		},
+		"refine": {
+			Name:        "refine",
+			Description: "Answer a prompt, then critique and improve the answer",
+			Handler:     refineCommand,
+		},
+		"compare": {
+			Name:        "compare",
+			Description: "Send a prompt to several models and compare the replies",
+			Handler:     compareCommand,
+		},
+		"explain-code": {
+			Name:        "explain-code",
+			Description: "Explain a source file or some of its lines",
+			Handler:     explainCodeCommand,
+		},
+		"ask-logs": {
+			Name:        "ask-logs",
+			Description: "Ask the model a question about your logs",
+			Handler:     askLogsCommand,
+		},
+		"style": {
+			Name:        "style",
+			Description: "List the styles of prompt --style",
+			Handler:     styleCommand,
+		},
+		"run": {
+			Name:        "run",
+			Description: "Run the steps of a workflow file",
+			Handler:     runCommand,
+		},
+		"tokens": {
+			Name:        "tokens",
+			Description: "Estimate the tokens in a text",
+			Handler:     tokensCommand,
+		},
+		"embed": {
+			Name:        "embed",
+			Description: "Print the embedding of a text or files",
+			Handler:     embedCommand,
+		},
+		"image": {
+			Name:        "image",
+			Description: "Generate images from a prompt",
+			Handler:     imageCommand,
+		},
+		"review": {
+			Name:        "review",
+			Description: "Review the changes of a git diff",
+			Handler:     reviewCommand,
+		},
+		"transcribe": {
+			Name:        "transcribe",
+			Description: "Transcribe an audio file",
+			Handler:     transcribeCommand,
+		},
+		"stats": {
+			Name:        "stats",
+			Description: "Show usage statistics",
+			Handler:     statsCommand,
+		},
+		"init": {
+			Name:        "init",
+			Description: "Set up the API key, model and defaults",
+			Handler:     initCommand,
+		},
+		"doctor": {
+			Name:        "doctor",
+			Description: "Check connectivity to the API",
+			Handler:     doctorCommand,
+		},
+		"status": {
+			Name:        "status",
+			Description: "Check that the provider answers and accepts the API key",
+			Handler:     statusCommand,
+		},
+		"auth": {
+			Name:        "auth",
+			Description: "Store the API key in the OS keychain",
+			Handler:     authCommand,
+		},
+		"alias": {
+			Name:        "alias",
+			Description: "Manage canned prompts run as commands",
+			Handler:     aliasCommand,
+		},
 		"config": {
 			Name:        "config",
 			Description: "Manage configuration",
 			Handler:     configCommand,
 		},
+		"export": {
+			Name:        "export",
+			Description: "Bundle the config, aliases and styles into an archive",
+			Handler:     exportCommand,
+		},
+		"import": {
+			Name:        "import",
+			Description: "Restore an archive made by export",
+			Handler:     importCommand,
+		},
+		"update": {
+			Name:        "update",
+			Description: "Update chatgpt-cli to the latest release",
+			Handler:     updateCommand,
+		},
+		"completion": {
+			Name:        "completion",
+			Description: "Print a shell completion script",
+			Handler:     completionCommand,
+		},
+		"__complete": {
+			Name:        "__complete",
+			Description: "List completions for the completion scripts",
+			Handler:     completeCommand,
+			Hidden:      true,
+		},
+	}
+
+	for _, b := range builtinCommands {
+		commands[b.Name] = Command{
+			Name:        b.Name,
+			Description: b.Description,
+			Handler:     b.run,
+		}
 	}
+	return commands
 }
 
 // parseCommand parses command-line arguments and returns the command and its arguments
@@ -679,29 +2914,130 @@ func parseCommand(args []string) (string, []string) {
 	return args[1], args[2:]
 }
 
+// findCommand returns the command of the given name, falling back to the
+// user's aliases
+func findCommand(config *Config, commands map[string]Command, name string) (Command, bool) {
+	if command, exists := commands[name]; exists {
+		return command, true
+	}
+	return aliasCommandFor(config, name)
+}
+
 func main() {
+	// Global flags select the profile and override the configured output format
+	args, globals, err := parseGlobalFlags(os.Args)
+	if err != nil {
+		newUI(nil, os.Stderr).printError(err)
+		os.Exit(exitUsage)
+	}
+
 	// Load configuration
-	config, err := loadConfig()
+	config, err := loadConfig(globals.Profile)
 	if err != nil {
-		log.Fatalf("Configuration error: %v", err)
+		newUI(nil, os.Stderr).printError(fmt.Errorf("configuration error: %w", err))
+		os.Exit(1)
+	}
+	if globals.Output != "" {
+		config.Output = globals.Output
+		config.origins[envOutput] = configSourceFlag
+	}
+	if globals.NoRedact {
+		config.Redact = false
+		config.origins[envRedact] = configSourceFlag
+	}
+	// Commands parse their default flags first, unless skipped for this run
+	if !globals.NoDefaults {
+		if flagDefaults, err = loadFlagDefaults(config.ConfigDir); err != nil {
+			newUI(nil, os.Stderr).printError(fmt.Errorf("configuration error: %w", err))
+			os.Exit(1)
+		}
+	}
+	if globals.Verbose > config.Debug {
+		config.Debug = globals.Verbose
+		if config.Debug > debugBodies {
+			config.Debug = debugBodies
+		}
 	}
 
+	// Ctrl-C cancels the running request; a second one exits immediately
+	ctx, cancel := cancelOnInterrupt()
+	defer cancel()
+	config.ctx = ctx
+
 	// Parse command
-	commandName, commandArgs := parseCommand(os.Args)
+	commandName, commandArgs := parseCommand(args)
 
 	// Get available commands
 	commands := getCommands()
 
-	// Find and execute command
-	command, exists := commands[commandName]
+	// Find and execute command, falling back to the user's aliases. A likely
+	// typo gets the command it is closest to suggested, or run with
+	// CHATGPT_CLI_AUTOCORRECT, rather than the whole help.
+	command, exists := findCommand(config, commands, commandName)
+	if !exists {
+		if suggestion, ok := suggestCommand(commandName, commandSuggestions(config, commands)); ok {
+			if autocorrect() {
+				fmt.Fprintf(os.Stderr, "Unknown command %q, running %q\n", commandName, suggestion)
+				commandName = suggestion
+				command, exists = findCommand(config, commands, commandName)
+			} else if config.Output == outputJSON {
+				printJSONError(fmt.Errorf("unknown command %q; did you mean %q?", commandName, suggestion))
+				os.Exit(exitUsage)
+			} else {
+				errOut := newUI(config, os.Stderr)
+				errOut.Printf("%s %q. Did you mean %q?\n", errOut.red("Unknown command"), commandName, suggestion)
+				os.Exit(exitUsage)
+			}
+		}
+	}
 	if !exists {
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", commandName)
+		if config.Output == outputJSON {
+			printJSONError(fmt.Errorf("unknown command: %s", commandName))
+			os.Exit(exitUsage)
+		}
+		errOut := newUI(config, os.Stderr)
+		errOut.Printf("%s %s\n\n", errOut.red("Unknown command:"), commandName)
 		_ = helpCommand(config, []string{})
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	// Every command but config set needs the selected profile to exist
+	if !createsProfile(commandName, commandArgs) {
+		err = checkProfile(config)
+	}
+
+	// Values that could not be parsed are warned about, or fatal in strict
+	// mode, except by config validate, which reports them itself, and by the
+	// hidden completion helper, which must not write to the terminal
+	if err == nil && !command.Hidden && !validatesConfig(commandName, commandArgs) {
+		err = reportConfigProblems(config, strictConfig() && !editsConfig(commandName, commandArgs))
+	}
+	if err == nil && !command.Hidden && !fixesPermissions(commandName, commandArgs) {
+		warnSecretFilePermissions(config)
 	}
 
-	// Execute command
-	if err := command.Handler(config, commandArgs); err != nil {
-		log.Fatalf("Error: %v", err)
+	// Execute command, timed as the root span of the trace when one is
+	// written. The completion helper is never traced.
+	var span *traceSpan
+	if !command.Hidden {
+		config.ctx, span = startTrace(config.ctx, os.Getenv(envTraceFile), commandName)
+	}
+	if err == nil {
+		err = command.Handler(config, commandArgs)
+	}
+	span.SetString("gen_ai.system", config.Provider)
+	span.SetString("gen_ai.request.model", config.Model)
+	span.End(err)
+	if err != nil {
+		switch {
+		case config.Output == outputJSON:
+			printJSONError(err)
+		case errors.Is(err, errCancelled):
+			fmt.Fprintln(os.Stderr, err)
+		default:
+			newUI(config, os.Stderr).printError(err)
+		}
+		// The exit status tells scripts what kind of failure this was
+		os.Exit(exitCode(err))
 	}
 }
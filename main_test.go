@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -18,8 +21,8 @@ func setupTestEnv(t *testing.T) func() {
 	// Save original env vars
 	originalVars := make(map[string]string)
 	envVars := []string{
-		envAPIKey, envAPIURL, envModel, envTimeout,
-		envMaxTokens, envTemperature, envConfigDir,
+		envAPIKey, envAnthropicAPIKey, envAPIURL, envModel, envTimeout,
+		envMaxTokens, envTemperature, envTopP, envPresencePenalty, envFrequencyPenalty, envStop, envSeed, envStream, envShowUsage, envModelsURL, envOutput, envNoColor, envProvider, envAzureAPIVersion, envOrgID, envProjectID, envLogMaxSize, envLogMaxFiles, envMaxFileSize, envLogFullPrompt, envTiming, envToolDomains, envReasoningModels, envLogDisabled, envPromptPrefix, envPromptSuffix, envRequestsPerMinute, envExtraHeaders, envEmbedModel, envConnectTimeout, envImageModel, envTranscribeModel, envTranscribeTimeout, envModerate, envRedact, envNotify, envNotifyAfter, envURLMaxChars, envLogPreviewLen, envMaxResponseBytes, envTimeFormat, envAutoTrim, envRequestTemplate, envResponseJSONPath, envProfile, envConfigDir, envPassphrase, envDebug, envStrictConfig, envTraceFile, envAutocorrect,
 	}
 
 	for _, key := range envVars {
@@ -27,8 +30,13 @@ func setupTestEnv(t *testing.T) func() {
 		os.Unsetenv(key)
 	}
 
+	// Keep API keys out of the OS keychain; the credentials file is used instead
+	originalStore := platformCredentialStore
+	platformCredentialStore = func() (credentialStore, bool) { return nil, false }
+
 	// Return cleanup function
 	return func() {
+		platformCredentialStore = originalStore
 		for key, value := range originalVars {
 			if value != "" {
 				os.Setenv(key, value)
@@ -101,7 +109,7 @@ func TestLoadConfig(t *testing.T) {
 			}
 
 			// Load config
-			config, err := loadConfig()
+			config, err := loadConfig("")
 			if err != nil {
 				t.Fatalf("loadConfig() error = %v", err)
 			}
@@ -175,6 +183,74 @@ func TestParseFloatOrDefault(t *testing.T) {
 	}
 }
 
+// TestParseBoolOrDefault tests boolean parsing with defaults
+func TestParseBoolOrDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		defVal   bool
+		expected bool
+	}{
+		{"empty string", "", true, true},
+		{"true", "true", false, true},
+		{"false", "false", true, false},
+		{"numeric", "0", true, false},
+		{"invalid bool", "maybe", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseBoolOrDefault(tt.input, tt.defVal)
+			if result != tt.expected {
+				t.Errorf("parseBoolOrDefault(%q, %t) = %t, want %t",
+					tt.input, tt.defVal, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestParseFlags tests parsing of flags interleaved with positional arguments
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name               string
+		args               []string
+		wantErr            bool
+		expectedFlag       bool
+		expectedPositional []string
+	}{
+		{"no flags", []string{"hello", "world"}, false, false, []string{"hello", "world"}},
+		{"leading flag", []string{"--flag", "hello"}, false, true, []string{"hello"}},
+		{"trailing flag", []string{"hello", "world", "--flag"}, false, true, []string{"hello", "world"}},
+		{"terminator", []string{"hello", "--", "--flag"}, false, false, []string{"hello", "--flag"}},
+		{"unknown flag", []string{"--unknown", "hello"}, true, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			value := fs.Bool("flag", false, "test flag")
+
+			positional, err := parseFlags(fs, tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseFlags() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFlags() unexpected error: %v", err)
+			}
+
+			if *value != tt.expectedFlag {
+				t.Errorf("flag = %t, want %t", *value, tt.expectedFlag)
+			}
+			if strings.Join(positional, "|") != strings.Join(tt.expectedPositional, "|") {
+				t.Errorf("positional = %q, want %q", positional, tt.expectedPositional)
+			}
+		})
+	}
+}
+
 // TestParseDurationOrDefault tests duration parsing with defaults
 func TestParseDurationOrDefault(t *testing.T) {
 	tests := []struct {
@@ -266,7 +342,7 @@ func TestParseCommand(t *testing.T) {
 func TestGetCommands(t *testing.T) {
 	commands := getCommands()
 
-	expectedCommands := []string{"help", "prompt", "logs", "config"}
+	expectedCommands := []string{"help", "prompt", "logs", "history", "search", "models", "batch", "refine", "run", "tokens", "embed", "image", "transcribe", "review", "stats", "init", "doctor", "status", "auth", "alias", "style", "config", "completion", "__complete", "summarize", "translate", "commitmsg", "export", "import", "update", "compare", "ask-logs", "explain-code"}
 
 	for _, cmdName := range expectedCommands {
 		if _, exists := commands[cmdName]; !exists {
@@ -420,6 +496,242 @@ func TestLogsCommand(t *testing.T) {
 	}
 }
 
+// writeTestLogs writes entries to a synthetic log file, one JSON object per line
+func writeTestLogs(t *testing.T, logFile string, entries []LogEntry) {
+	t.Helper()
+
+	f, err := os.Create(logFile)
+	if err != nil {
+		t.Fatalf("failed to create log file: %v", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			t.Fatalf("failed to encode entry: %v", err)
+		}
+	}
+}
+
+// TestReadLogEntries tests filtering and tailing of log entries
+func TestReadLogEntries(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "logs.jsonl")
+
+	now := time.Now()
+	writeTestLogs(t, logFile, []LogEntry{
+		{Timestamp: now.Add(-72 * time.Hour), Command: "prompt", Prompt: "old prompt", Response: "old response"},
+		{Timestamp: now.Add(-48 * time.Hour), Command: "config", Error: "old error"},
+		{Timestamp: now.Add(-2 * time.Hour), Command: "prompt", Prompt: "failed prompt", Error: "timeout"},
+		{Timestamp: now.Add(-1 * time.Hour), Command: "prompt", Prompt: "recent prompt", Response: "recent response"},
+		{Timestamp: now, Command: "config"},
+	})
+
+	// Append an invalid line that must be skipped
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	fmt.Fprintln(f, "not json")
+	f.Close()
+
+	tests := []struct {
+		name            string
+		filter          logFilter
+		tail            int
+		expectedPrompts []string
+		expectedCount   int
+	}{
+		{
+			name:          "no filter",
+			expectedCount: 5,
+		},
+		{
+			name:            "tail",
+			tail:            2,
+			expectedCount:   2,
+			expectedPrompts: []string{"recent prompt", ""},
+		},
+		{
+			name:          "tail larger than entries",
+			tail:          20,
+			expectedCount: 5,
+		},
+		{
+			name:            "since",
+			filter:          logFilter{Since: now.Add(-24 * time.Hour)},
+			expectedCount:   3,
+			expectedPrompts: []string{"failed prompt", "recent prompt", ""},
+		},
+		{
+			name:            "command",
+			filter:          logFilter{Command: "prompt"},
+			expectedCount:   3,
+			expectedPrompts: []string{"old prompt", "failed prompt", "recent prompt"},
+		},
+		{
+			name:            "errors only",
+			filter:          logFilter{ErrorsOnly: true},
+			expectedCount:   2,
+			expectedPrompts: []string{"", "failed prompt"},
+		},
+		{
+			name:            "combined filters with tail",
+			filter:          logFilter{Command: "prompt", Since: now.Add(-24 * time.Hour)},
+			tail:            1,
+			expectedCount:   1,
+			expectedPrompts: []string{"recent prompt"},
+		},
+		{
+			name:          "no matches",
+			filter:        logFilter{Command: "unknown"},
+			expectedCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, skipped, err := readLogEntries([]string{logFile}, tt.filter, tt.tail)
+			if err != nil {
+				t.Fatalf("readLogEntries() error = %v", err)
+			}
+			if skipped != 1 {
+				t.Errorf("skipped = %d, want the invalid line counted", skipped)
+			}
+
+			if len(entries) != tt.expectedCount {
+				t.Fatalf("len(entries) = %d, want %d", len(entries), tt.expectedCount)
+			}
+
+			for i, prompt := range tt.expectedPrompts {
+				if entries[i].Prompt != prompt {
+					t.Errorf("entries[%d].Prompt = %q, want %q", i, entries[i].Prompt, prompt)
+				}
+			}
+		})
+	}
+}
+
+// TestLogsCommandFlags tests logs command flag handling
+func TestLogsCommandFlags(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	config := &Config{
+		ConfigDir: tmpDir,
+	}
+
+	writeTestLogs(t, filepath.Join(tmpDir, "logs.jsonl"), []LogEntry{
+		{Timestamp: time.Now(), Command: "prompt", Prompt: "test prompt", Error: "test error"},
+	})
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:    "all filters",
+			args:    []string{"--tail", "20", "--since", "24h", "--command", "prompt", "--errors-only"},
+			wantErr: false,
+		},
+		{
+			name:    "filter without matches",
+			args:    []string{"--command", "config"},
+			wantErr: false,
+		},
+		{
+			name:        "negative tail",
+			args:        []string{"--tail", "-1"},
+			wantErr:     true,
+			errContains: "--tail must be a non-negative integer",
+		},
+		{
+			name:        "invalid since",
+			args:        []string{"--since", "yesterday"},
+			wantErr:     true,
+			errContains: "invalid value",
+		},
+		{
+			name:        "unknown flag",
+			args:        []string{"--verbose"},
+			wantErr:     true,
+			errContains: "flag provided but not defined",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := logsCommand(config, tt.args)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("logsCommand() expected error, got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("logsCommand() unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// TestLogsShowCommand tests printing one entry of the listing, cut or in full
+func TestLogsShowCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	config := &Config{ConfigDir: tmpDir, NoColor: true, LogPreviewLen: 12}
+	response := "第一行：很长的回答 🎉\nsecond line"
+	writeTestLogs(t, filepath.Join(tmpDir, "logs.jsonl"), []LogEntry{
+		{Timestamp: time.Now(), Command: "prompt", Prompt: "first", Error: "timeout"},
+		{Timestamp: time.Now(), Command: "prompt", Model: "gpt-4o", Prompt: "second prompt", Response: response},
+	})
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"show", "2"}); err != nil {
+			t.Errorf("logs show error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "[2] ") || !strings.Contains(out, "Prompt: second pr...") || !strings.Contains(out, "Response: 第一行：很长的回答...") {
+		t.Errorf("logs show output = %q, want the entry cut", out)
+	}
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"show", "2", "--full"}); err != nil {
+			t.Errorf("logs show --full error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "prompt (gpt-4o)") || !strings.Contains(out, "Prompt:\nsecond prompt\n") || !strings.Contains(out, "Response:\n"+response+"\n") {
+		t.Errorf("logs show --full output = %q, want the entry whole", out)
+	}
+
+	// Entries are numbered as in the listing with the same filters
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"show", "--errors-only", "1"}); err != nil {
+			t.Errorf("logs show --errors-only error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Prompt: first") {
+		t.Errorf("logs show --errors-only output = %q", out)
+	}
+
+	for _, args := range [][]string{nil, {"x"}, {"0"}, {"3"}, {"1", "2"}} {
+		if err := logsShowCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("logsShowCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
+
 // TestConfigCommand tests the config command
 func TestConfigCommand(t *testing.T) {
 	cleanup := setupTestEnv(t)
@@ -610,6 +922,163 @@ func TestConfigSetCommand(t *testing.T) {
 	}
 }
 
+// TestConfigUnsetCommand tests removing configuration values
+func TestConfigUnsetCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	config := &Config{
+		ConfigDir: tmpDir,
+	}
+
+	if err := saveConfigFile(tmpDir, map[string]string{
+		"OPENAI_MODEL":      "gpt-4",
+		"OPENAI_MAX_TOKENS": "2000",
+	}); err != nil {
+		t.Fatalf("saveConfigFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:        "no key",
+			args:        []string{},
+			wantErr:     true,
+			errContains: "configuration key required",
+		},
+		{
+			name:    "unset existing key",
+			args:    []string{"openai_model"},
+			wantErr: false,
+		},
+		{
+			name:    "unset missing key",
+			args:    []string{"OPENAI_TIMEOUT"},
+			wantErr: false,
+		},
+		{
+			name:        "unset config dir",
+			args:        []string{"CHATGPT_CLI_CONFIG_DIR"},
+			wantErr:     true,
+			errContains: "cannot be unset via config unset command",
+		},
+		{
+			name:        "unset unknown key",
+			args:        []string{"UNKNOWN_KEY"},
+			wantErr:     true,
+			errContains: "unknown configuration key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := configCommand(config, append([]string{"unset"}, tt.args...))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("configUnsetCommand() expected error, got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("configUnsetCommand() unexpected error: %v", err)
+				}
+			}
+		})
+	}
+
+	fileConfig := loadTestConfigSections(t, tmpDir)[""]
+	if _, exists := fileConfig["OPENAI_MODEL"]; exists {
+		t.Errorf("OPENAI_MODEL still present in config file")
+	}
+	if fileConfig["OPENAI_MAX_TOKENS"] != "2000" {
+		t.Errorf("OPENAI_MAX_TOKENS = %q, want %q", fileConfig["OPENAI_MAX_TOKENS"], "2000")
+	}
+}
+
+// TestConfigResetCommand tests clearing the config file
+func TestConfigResetCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	tests := []struct {
+		name          string
+		args          []string
+		input         string
+		expectCleared bool
+	}{
+		{
+			name:          "confirmed",
+			input:         "y\n",
+			expectCleared: true,
+		},
+		{
+			name:          "declined",
+			input:         "n\n",
+			expectCleared: false,
+		},
+		{
+			name:          "no answer",
+			input:         "",
+			expectCleared: false,
+		},
+		{
+			name:          "forced",
+			args:          []string{"--force"},
+			expectCleared: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			config := &Config{
+				ConfigDir: tmpDir,
+			}
+
+			if err := saveConfigFile(tmpDir, map[string]string{
+				"OPENAI_MODEL":   "gpt-4",
+				"OPENAI_API_KEY": "sk-test",
+			}); err != nil {
+				t.Fatalf("saveConfigFile() error = %v", err)
+			}
+
+			stdin = strings.NewReader(tt.input)
+
+			if err := configCommand(config, append([]string{"reset"}, tt.args...)); err != nil {
+				t.Fatalf("configResetCommand() error = %v", err)
+			}
+
+			remaining := len(loadTestConfigSections(t, tmpDir)[""])
+			if tt.expectCleared && remaining != 0 {
+				t.Errorf("config file has %d values after reset, want 0", remaining)
+			}
+			if !tt.expectCleared && remaining != 2 {
+				t.Errorf("config file has %d values after cancelled reset, want 2", remaining)
+			}
+		})
+	}
+
+	// Resetting an empty config is a no-op
+	config := &Config{
+		ConfigDir: t.TempDir(),
+	}
+	if err := configResetCommand(config, []string{}); err != nil {
+		t.Errorf("configResetCommand() with empty config error = %v", err)
+	}
+}
+
 // TestTruncate tests string truncation
 func TestTruncate(t *testing.T) {
 	tests := []struct {
@@ -642,10 +1111,70 @@ func TestTruncate(t *testing.T) {
 			maxLen:   10,
 			expected: "",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
+		{
+			name:     "multi-byte characters",
+			input:    "one⏎two⏎three",
+			maxLen:   8,
+			expected: "one⏎t...",
+		},
+		{
+			name:     "no limit",
+			input:    "this is a very long string",
+			maxLen:   0,
+			expected: "this is a very long string",
+		},
+		{
+			name:     "shorter than the ellipsis",
+			input:    "hello",
+			maxLen:   2,
+			expected: "..",
+		},
+		{
+			name:     "CJK at the boundary",
+			input:    "日本語のテキストです",
+			maxLen:   7,
+			expected: "日本語の...",
+		},
+		{
+			name:     "emoji at the boundary",
+			input:    "ok 👍👍👍👍👍",
+			maxLen:   7,
+			expected: "ok 👍...",
+		},
+		{
+			name:     "skin tone modifier at the boundary",
+			input:    "ab👍🏽cdefgh",
+			maxLen:   6,
+			expected: "ab...",
+		},
+		{
+			name:     "ZWJ sequence at the boundary",
+			input:    "a👨‍👩‍👧 family",
+			maxLen:   7,
+			expected: "a...",
+		},
+		{
+			name:     "variation selector at the boundary",
+			input:    "ab❤️cdefgh",
+			maxLen:   6,
+			expected: "ab...",
+		},
+		{
+			name:     "flags at the boundary",
+			input:    "a🇯🇵🇫🇷🇮🇹🇩🇪",
+			maxLen:   7,
+			expected: "a🇯🇵...",
+		},
+		{
+			name:     "combining accent at the boundary",
+			input:    "cafe\u0301 au lait",
+			maxLen:   7,
+			expected: "caf...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
 			result := truncate(tt.input, tt.maxLen)
 			if result != tt.expected {
 				t.Errorf("truncate(%q, %d) = %q, want %q",
@@ -919,6 +1448,90 @@ func TestPromptCommandSuccess(t *testing.T) {
 	}
 }
 
+// TestPromptCommandStdin tests reading a multi-line prompt from stdin
+func TestPromptCommandStdin(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		received = request.Messages[0].Content
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			Choices: []Choice{{Message: Message{Content: "ok"}}},
+		})
+	}))
+	defer server.Close()
+
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	multiLine := "Summarize:\n  - first\n\n  - second\n"
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		expected string
+		errMsg   string
+	}{
+		{"stdin flag", []string{"--no-stream", "--stdin"}, multiLine, multiLine, ""},
+		{"dash", []string{"--no-stream", "-"}, multiLine, multiLine, ""},
+		{"newlines in an argument", []string{"--no-stream", "first line\nsecond line", "end"}, "", "first line\nsecond line end", ""},
+		{"empty stdin", []string{"--stdin"}, " \n", "", "prompt cannot be empty"},
+		{"stdin with arguments", []string{"--stdin", "extra"}, multiLine, "", "remove the prompt arguments"},
+		{"stdin twice", []string{"--stdin", "--file", "-"}, multiLine, "", "stdin cannot be both"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			config := &Config{
+				APIKey:    "test-key",
+				APIURL:    server.URL,
+				Model:     "gpt-4o",
+				Timeout:   10 * time.Second,
+				ConfigDir: tmpDir,
+			}
+			stdin = strings.NewReader(tt.stdin)
+			received = ""
+
+			err := promptCommand(config, tt.args)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("promptCommand() error = %v, want %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("promptCommand() error = %v", err)
+			}
+			if received != tt.expected {
+				t.Errorf("request content = %q, want %q", received, tt.expected)
+			}
+
+			// The log keeps the newlines; the logs listing marks them with ⏎
+			entries, _, err := readLogEntries([]string{filepath.Join(tmpDir, "logs.jsonl")}, logFilter{}, 0)
+			if err != nil || len(entries) != 1 || entries[0].Prompt != tt.expected {
+				t.Fatalf("logged entries = %+v, %v, want the prompt %q", entries, err, tt.expected)
+			}
+			out := captureOutput(t, &os.Stdout, func() {
+				if err := logsCommand(config, []string{}); err != nil {
+					t.Errorf("logsCommand() error = %v", err)
+				}
+			})
+			preview := "    Prompt: " + strings.ReplaceAll(tt.expected, "\n", "⏎") + "\n"
+			if !strings.Contains(out, preview) {
+				t.Errorf("logs output = %q, want the line %q", out, preview)
+			}
+		})
+	}
+}
+
 // TestLogsCommandEmptyFile tests logs command with empty file
 func TestLogsCommandEmptyFile(t *testing.T) {
 	cleanup := setupTestEnv(t)
@@ -1006,11 +1619,53 @@ func TestConfigGetCommand(t *testing.T) {
 
 	validKeys := []string{
 		"OPENAI_API_KEY",
+		"ANTHROPIC_API_KEY",
 		"OPENAI_API_URL",
 		"OPENAI_MODEL",
 		"OPENAI_TIMEOUT",
 		"OPENAI_MAX_TOKENS",
 		"OPENAI_TEMPERATURE",
+		"OPENAI_TOP_P",
+		"OPENAI_PRESENCE_PENALTY",
+		"OPENAI_FREQUENCY_PENALTY",
+		"OPENAI_STOP",
+		"OPENAI_SEED",
+		"OPENAI_STREAM",
+		"OPENAI_SHOW_USAGE",
+		"OPENAI_MODELS_URL",
+		"CHATGPT_CLI_OUTPUT",
+		"CHATGPT_CLI_NO_COLOR",
+		"OPENAI_PROVIDER",
+		"AZURE_API_VERSION",
+		"OPENAI_ORG_ID",
+		"OPENAI_PROJECT_ID",
+		"CHATGPT_CLI_LOG_MAX_SIZE",
+		"CHATGPT_CLI_LOG_MAX_FILES",
+		"CHATGPT_CLI_MAX_FILE_SIZE",
+		"CHATGPT_CLI_LOG_FULL_PROMPT",
+		"CHATGPT_CLI_TIMING",
+		"CHATGPT_CLI_TOOL_DOMAINS",
+		"OPENAI_REASONING_MODELS",
+		"CHATGPT_CLI_LOG_DISABLED",
+		"OPENAI_PROMPT_PREFIX",
+		"OPENAI_PROMPT_SUFFIX",
+		"OPENAI_REQUESTS_PER_MINUTE",
+		"OPENAI_EXTRA_HEADERS",
+		"OPENAI_EMBED_MODEL",
+		"OPENAI_CONNECT_TIMEOUT",
+		"OPENAI_IMAGE_MODEL",
+		"OPENAI_TRANSCRIBE_MODEL",
+		"OPENAI_TRANSCRIBE_TIMEOUT",
+		"CHATGPT_CLI_MODERATE",
+		"CHATGPT_CLI_REDACT",
+		"CHATGPT_CLI_NOTIFY",
+		"CHATGPT_CLI_NOTIFY_AFTER",
+		"CHATGPT_CLI_URL_MAX_CHARS",
+		"CHATGPT_CLI_LOG_PREVIEW_LEN",
+		"OPENAI_MAX_RESPONSE_BYTES",
+		"CHATGPT_CLI_TIME_FORMAT",
+		"CHATGPT_CLI_AUTO_TRIM",
+		"CHATGPT_CLI_PROFILE",
 		"CHATGPT_CLI_CONFIG_DIR",
 	}
 
@@ -1073,6 +1728,157 @@ func TestConfigSetCommandExtended(t *testing.T) {
 			args:    []string{"OPENAI_TEMPERATURE", "2.0"},
 			wantErr: false,
 		},
+		{
+			name:    "set top_p at upper bound",
+			args:    []string{"OPENAI_TOP_P", "1.0"},
+			wantErr: false,
+		},
+		{
+			name:    "set negative presence penalty",
+			args:    []string{"OPENAI_PRESENCE_PENALTY", "-2.0"},
+			wantErr: false,
+		},
+		{
+			name:    "set frequency penalty",
+			args:    []string{"OPENAI_FREQUENCY_PENALTY", "0.5"},
+			wantErr: false,
+		},
+		{
+			name:    "set stop sequences",
+			args:    []string{"OPENAI_STOP", `END,\n\n`},
+			wantErr: false,
+		},
+		{
+			name:    "set valid stream",
+			args:    []string{"OPENAI_STREAM", "false"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid stream",
+			args:        []string{"OPENAI_STREAM", "sometimes"},
+			wantErr:     true,
+			errContains: "stream must be true or false",
+		},
+		{
+			name:    "set valid show usage",
+			args:    []string{"OPENAI_SHOW_USAGE", "true"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid show usage",
+			args:        []string{"OPENAI_SHOW_USAGE", "yes please"},
+			wantErr:     true,
+			errContains: "show usage must be true or false",
+		},
+		{
+			name:    "set valid models URL",
+			args:    []string{"OPENAI_MODELS_URL", "https://api.example.com/v1/models"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid models URL",
+			args:        []string{"OPENAI_MODELS_URL", "api.example.com"},
+			wantErr:     true,
+			errContains: "models URL must start with http",
+		},
+		{
+			name:    "set valid output",
+			args:    []string{"CHATGPT_CLI_OUTPUT", "json"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid output",
+			args:        []string{"CHATGPT_CLI_OUTPUT", "xml"},
+			wantErr:     true,
+			errContains: "output must be plain or json",
+		},
+		{
+			name:    "set valid no color",
+			args:    []string{"CHATGPT_CLI_NO_COLOR", "true"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid no color",
+			args:        []string{"CHATGPT_CLI_NO_COLOR", "never"},
+			wantErr:     true,
+			errContains: "no color must be true or false",
+		},
+		{
+			name:    "set valid provider",
+			args:    []string{"OPENAI_PROVIDER", "Azure"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid provider",
+			args:        []string{"OPENAI_PROVIDER", "bedrock"},
+			wantErr:     true,
+			errContains: "provider must be one of",
+		},
+		{
+			name:    "set valid Azure API version",
+			args:    []string{"AZURE_API_VERSION", "2024-02-01"},
+			wantErr: false,
+		},
+		{
+			name:        "set empty Azure API version",
+			args:        []string{"AZURE_API_VERSION", ""},
+			wantErr:     true,
+			errContains: "Azure API version cannot be empty",
+		},
+		{
+			name:    "set valid organization ID",
+			args:    []string{"OPENAI_ORG_ID", "org-abc123"},
+			wantErr: false,
+		},
+		{
+			name:    "set organization ID without prefix",
+			args:    []string{"OPENAI_ORG_ID", "abc123"},
+			wantErr: false,
+		},
+		{
+			name:        "set organization ID with spaces",
+			args:        []string{"OPENAI_ORG_ID", "org abc"},
+			wantErr:     true,
+			errContains: "OPENAI_ORG_ID cannot be empty or contain spaces",
+		},
+		{
+			name:    "set valid project ID",
+			args:    []string{"OPENAI_PROJECT_ID", "proj_abc123"},
+			wantErr: false,
+		},
+		{
+			name:        "set empty project ID",
+			args:        []string{"OPENAI_PROJECT_ID", ""},
+			wantErr:     true,
+			errContains: "OPENAI_PROJECT_ID cannot be empty or contain spaces",
+		},
+		{
+			name:    "set valid log max size",
+			args:    []string{"CHATGPT_CLI_LOG_MAX_SIZE", "10MB"},
+			wantErr: false,
+		},
+		{
+			name:    "set log max size to zero",
+			args:    []string{"CHATGPT_CLI_LOG_MAX_SIZE", "0"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid log max size",
+			args:        []string{"CHATGPT_CLI_LOG_MAX_SIZE", "lots"},
+			wantErr:     true,
+			errContains: "log max size must be a size",
+		},
+		{
+			name:    "set valid log max files",
+			args:    []string{"CHATGPT_CLI_LOG_MAX_FILES", "5"},
+			wantErr: false,
+		},
+		{
+			name:        "set negative log max files",
+			args:        []string{"CHATGPT_CLI_LOG_MAX_FILES", "-1"},
+			wantErr:     true,
+			errContains: "log max files must be a non-negative integer",
+		},
 		{
 			name:        "set temperature above upper bound",
 			args:        []string{"OPENAI_TEMPERATURE", "2.1"},
@@ -1085,12 +1891,295 @@ func TestConfigSetCommandExtended(t *testing.T) {
 			wantErr:     true,
 			errContains: "between 0.0 and 2.0",
 		},
+		{
+			name:        "set top_p above upper bound",
+			args:        []string{"OPENAI_TOP_P", "1.1"},
+			wantErr:     true,
+			errContains: "between 0.0 and 1.0",
+		},
+		{
+			name:        "set presence penalty below lower bound",
+			args:        []string{"OPENAI_PRESENCE_PENALTY", "-2.5"},
+			wantErr:     true,
+			errContains: "between -2.0 and 2.0",
+		},
+		{
+			name:        "set invalid frequency penalty non-numeric",
+			args:        []string{"OPENAI_FREQUENCY_PENALTY", "high"},
+			wantErr:     true,
+			errContains: "between -2.0 and 2.0",
+		},
+		{
+			name:        "set too many stop sequences",
+			args:        []string{"OPENAI_STOP", "a,b,c,d,e"},
+			wantErr:     true,
+			errContains: "at most 4 stop sequences",
+		},
 		{
 			name:        "set invalid temperature non-numeric",
 			args:        []string{"OPENAI_TEMPERATURE", "abc"},
 			wantErr:     true,
 			errContains: "between 0.0 and 2.0",
 		},
+		{
+			name:    "set valid max file size",
+			args:    []string{"CHATGPT_CLI_MAX_FILE_SIZE", "256KB"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid max file size",
+			args:        []string{"CHATGPT_CLI_MAX_FILE_SIZE", "-1"},
+			wantErr:     true,
+			errContains: "max file size must be a size",
+		},
+		{
+			name:    "set valid log full prompt",
+			args:    []string{"CHATGPT_CLI_LOG_FULL_PROMPT", "true"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid log full prompt",
+			args:        []string{"CHATGPT_CLI_LOG_FULL_PROMPT", "sometimes"},
+			wantErr:     true,
+			errContains: "log full prompt must be true or false",
+		},
+		{
+			name:    "set valid timing",
+			args:    []string{"CHATGPT_CLI_TIMING", "true"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid timing",
+			args:        []string{"CHATGPT_CLI_TIMING", "always"},
+			wantErr:     true,
+			errContains: "timing must be true or false",
+		},
+		{
+			name:    "set valid tool domains",
+			args:    []string{"CHATGPT_CLI_TOOL_DOMAINS", "example.com, api.github.com"},
+			wantErr: false,
+		},
+		{
+			name:        "set tool domains with a URL",
+			args:        []string{"CHATGPT_CLI_TOOL_DOMAINS", "https://example.com"},
+			wantErr:     true,
+			errContains: "tool domains must be host names",
+		},
+		{
+			name:    "set valid reasoning models",
+			args:    []string{"OPENAI_REASONING_MODELS", "my-o3-proxy, ft:o1:acme"},
+			wantErr: false,
+		},
+		{
+			name:        "set reasoning models with a space",
+			args:        []string{"OPENAI_REASONING_MODELS", "my model"},
+			wantErr:     true,
+			errContains: "reasoning models must be model names",
+		},
+		{
+			name:    "set valid log disabled",
+			args:    []string{"CHATGPT_CLI_LOG_DISABLED", "true"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid log disabled",
+			args:        []string{"CHATGPT_CLI_LOG_DISABLED", "never"},
+			wantErr:     true,
+			errContains: "log disabled must be true or false",
+		},
+		{
+			name:    "set valid prompt prefix",
+			args:    []string{"OPENAI_PROMPT_PREFIX", "Answer in Italian."},
+			wantErr: false,
+		},
+		{
+			name:        "set empty prompt suffix",
+			args:        []string{"OPENAI_PROMPT_SUFFIX", "  "},
+			wantErr:     true,
+			errContains: "OPENAI_PROMPT_SUFFIX cannot be empty",
+		},
+		{
+			name:    "set valid requests per minute",
+			args:    []string{"OPENAI_REQUESTS_PER_MINUTE", "20"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid requests per minute",
+			args:        []string{"OPENAI_REQUESTS_PER_MINUTE", "-1"},
+			wantErr:     true,
+			errContains: "requests per minute must be a non-negative integer",
+		},
+		{
+			name:    "set valid extra headers",
+			args:    []string{"OPENAI_EXTRA_HEADERS", "X-Request-Source: cli; X-Team-Id: 42"},
+			wantErr: false,
+		},
+		{
+			name:        "set extra headers without value",
+			args:        []string{"OPENAI_EXTRA_HEADERS", "X-Request-Source: cli; X-Team-Id"},
+			wantErr:     true,
+			errContains: "extra headers must be \"Key: Value\" pairs",
+		},
+		{
+			name:    "set valid embed model",
+			args:    []string{"OPENAI_EMBED_MODEL", "text-embedding-3-large"},
+			wantErr: false,
+		},
+		{
+			name:        "set empty embed model",
+			args:        []string{"OPENAI_EMBED_MODEL", ""},
+			wantErr:     true,
+			errContains: "embed model cannot be empty",
+		},
+		{
+			name:    "set valid connect timeout",
+			args:    []string{"OPENAI_CONNECT_TIMEOUT", "5s"},
+			wantErr: false,
+		},
+		{
+			name:        "set zero connect timeout",
+			args:        []string{"OPENAI_CONNECT_TIMEOUT", "0s"},
+			wantErr:     true,
+			errContains: "connect timeout must be positive",
+		},
+		{
+			name:    "set valid image model",
+			args:    []string{"OPENAI_IMAGE_MODEL", "gpt-image-1"},
+			wantErr: false,
+		},
+		{
+			name:        "set empty image model",
+			args:        []string{"OPENAI_IMAGE_MODEL", ""},
+			wantErr:     true,
+			errContains: "image model cannot be empty",
+		},
+		{
+			name:    "set valid transcribe model",
+			args:    []string{"OPENAI_TRANSCRIBE_MODEL", "gpt-4o-transcribe"},
+			wantErr: false,
+		},
+		{
+			name:    "set valid transcribe timeout",
+			args:    []string{"OPENAI_TRANSCRIBE_TIMEOUT", "15m"},
+			wantErr: false,
+		},
+		{
+			name:        "set zero transcribe timeout",
+			args:        []string{"OPENAI_TRANSCRIBE_TIMEOUT", "0s"},
+			wantErr:     true,
+			errContains: "transcribe timeout must be positive",
+		},
+		{
+			name:    "set valid moderate",
+			args:    []string{"CHATGPT_CLI_MODERATE", "true"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid moderate",
+			args:        []string{"CHATGPT_CLI_MODERATE", "sometimes"},
+			wantErr:     true,
+			errContains: "moderate must be true or false",
+		},
+		{
+			name:    "set valid redact",
+			args:    []string{"CHATGPT_CLI_REDACT", "false"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid redact",
+			args:        []string{"CHATGPT_CLI_REDACT", "off"},
+			wantErr:     true,
+			errContains: "redact must be true or false",
+		},
+		{
+			name:    "set valid notify after",
+			args:    []string{"CHATGPT_CLI_NOTIFY_AFTER", "30s"},
+			wantErr: false,
+		},
+		{
+			name:        "set negative notify after",
+			args:        []string{"CHATGPT_CLI_NOTIFY_AFTER", "-1s"},
+			wantErr:     true,
+			errContains: "notify after cannot be negative",
+		},
+		{
+			name:        "set invalid notify",
+			args:        []string{"CHATGPT_CLI_NOTIFY", "loud"},
+			wantErr:     true,
+			errContains: "notify must be true or false",
+		},
+		{
+			name:    "set valid url max chars",
+			args:    []string{"CHATGPT_CLI_URL_MAX_CHARS", "50000"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid url max chars",
+			args:        []string{"CHATGPT_CLI_URL_MAX_CHARS", "-5"},
+			wantErr:     true,
+			errContains: "url max chars must be a non-negative integer",
+		},
+		{
+			name:    "set valid log preview len",
+			args:    []string{"CHATGPT_CLI_LOG_PREVIEW_LEN", "0"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid log preview len",
+			args:        []string{"CHATGPT_CLI_LOG_PREVIEW_LEN", "short"},
+			wantErr:     true,
+			errContains: "log preview len must be a non-negative integer",
+		},
+		{
+			name:    "set valid max response bytes",
+			args:    []string{"OPENAI_MAX_RESPONSE_BYTES", "20MB"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid max response bytes",
+			args:        []string{"OPENAI_MAX_RESPONSE_BYTES", "huge"},
+			wantErr:     true,
+			errContains: "max response bytes must be a size",
+		},
+		{
+			name:    "set valid time format",
+			args:    []string{"CHATGPT_CLI_TIME_FORMAT", "Jan 2 15:04 MST"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid time format",
+			args:        []string{"CHATGPT_CLI_TIME_FORMAT", "yyyy-mm-dd"},
+			wantErr:     true,
+			errContains: "time format must be a Go time layout",
+		},
+		{
+			name:    "set valid auto trim",
+			args:    []string{"CHATGPT_CLI_AUTO_TRIM", "true"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid auto trim",
+			args:        []string{"CHATGPT_CLI_AUTO_TRIM", "sometimes"},
+			wantErr:     true,
+			errContains: "auto trim must be true or false",
+		},
+		{
+			name:    "set valid seed",
+			args:    []string{"OPENAI_SEED", "42"},
+			wantErr: false,
+		},
+		{
+			name:        "set invalid seed",
+			args:        []string{"OPENAI_SEED", "4.2"},
+			wantErr:     true,
+			errContains: "seed must be an integer",
+		},
+		{
+			name:        "set profile",
+			args:        []string{"CHATGPT_CLI_PROFILE", "work"},
+			wantErr:     true,
+			errContains: "cannot be set via config set command",
+		},
 		{
 			name:        "set valid config dir",
 			args:        []string{"CHATGPT_CLI_CONFIG_DIR", "/tmp/test-config"},
@@ -1126,6 +2215,27 @@ func TestConfigSetCommandExtended(t *testing.T) {
 	}
 }
 
+// TestConfigValueWarning tests the warnings about IDs missing their prefix
+func TestConfigValueWarning(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		warn  bool
+	}{
+		{"OPENAI_ORG_ID", "org-abc123", false},
+		{"OPENAI_ORG_ID", "abc123", true},
+		{"OPENAI_PROJECT_ID", "proj_abc123", false},
+		{"OPENAI_PROJECT_ID", "org-abc123", true},
+		{"OPENAI_MODEL", "gpt-4", false},
+	}
+
+	for _, tt := range tests {
+		if got := configValueWarning(tt.key, tt.value); (got != "") != tt.warn {
+			t.Errorf("configValueWarning(%q, %q) = %q, want warning %t", tt.key, tt.value, got, tt.warn)
+		}
+	}
+}
+
 // TestSendChatRequest tests the HTTP request to OpenAI API
 func TestSendChatRequest(t *testing.T) {
 	tests := []struct {
@@ -1211,11 +2321,11 @@ func TestSendChatRequest(t *testing.T) {
 				Temperature: 0.7,
 			}
 
-			_, err := sendChatRequest(config, "test prompt")
+			_, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt"))
 
 			if tt.wantErr {
 				if err == nil {
-					t.Errorf("sendChatRequest() expected error, got nil")
+					t.Errorf("Chat() expected error, got nil")
 					return
 				}
 				if !strings.Contains(err.Error(), tt.errContains) {
@@ -1223,7 +2333,7 @@ func TestSendChatRequest(t *testing.T) {
 				}
 			} else {
 				if err != nil {
-					t.Errorf("sendChatRequest() unexpected error: %v", err)
+					t.Errorf("Chat() unexpected error: %v", err)
 				}
 			}
 		})
@@ -1245,9 +2355,9 @@ func TestSendChatRequestTimeout(t *testing.T) {
 		MaxTokens: 1000, Temperature: 0.7,
 	}
 
-	_, err := sendChatRequest(config, "test prompt")
+	_, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt"))
 	if err == nil {
-		t.Errorf("sendChatRequest() expected timeout error, got nil")
+		t.Errorf("Chat() expected timeout error, got nil")
 	}
 }
 
@@ -1397,3 +2507,30 @@ func TestLogEntryMultiple(t *testing.T) {
 		t.Errorf("expected 3 log entries, got %d", len(lines))
 	}
 }
+
+// TestCheckFlagConflicts tests that one table rules out the flag pairs of
+// --verify, --count and --watch
+func TestCheckFlagConflicts(t *testing.T) {
+	tests := []struct {
+		given map[string]bool
+		want  string
+	}{
+		{map[string]bool{"--verify": true, "--n": true}, "--verify cannot be combined with --n"},
+		{map[string]bool{"--count": true, "--verify": true}, "--count cannot be combined with --verify"},
+		{map[string]bool{"--watch": true, "--dry-run": true}, "--watch cannot be combined with --dry-run"},
+		{map[string]bool{"--count": true, "--pick": true}, ""},
+		{map[string]bool{"--n": true, "--tools": true}, ""},
+	}
+	for _, tt := range tests {
+		err := checkFlagConflicts(tt.given)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("checkFlagConflicts(%v) error = %v", tt.given, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("checkFlagConflicts(%v) error = %v, want %q", tt.given, err, tt.want)
+		}
+	}
+}
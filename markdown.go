@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences used by the markdown renderer and ui
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiBoldOff   = "\x1b[22m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiItalicOff = "\x1b[23m"
+	ansiCyan      = "\x1b[36m"
+	ansiYellow    = "\x1b[33m"
+	ansiRed       = "\x1b[31m"
+	ansiDefaultFg = "\x1b[39m"
+)
+
+// Indentation of code blocks and of each list nesting level
+const (
+	codeIndent     = "    "
+	listIndentUnit = "  "
+)
+
+var (
+	listItemPattern = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	boldPattern     = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicPattern   = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_\s][^_]*)_\b`)
+	inlineCode      = regexp.MustCompile("`([^`]+)`")
+)
+
+// Bullet glyphs by nesting level; deeper levels reuse the last one
+var bulletGlyphs = []string{"•", "◦", "▪"}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// markdownRenderer renders markdown line by line for terminal display. It
+// keeps track of fenced code blocks across lines, so it can be fed a response
+// incrementally.
+type markdownRenderer struct {
+	color  bool
+	inCode bool
+}
+
+// renderMarkdown renders a complete markdown document for the terminal.
+// ANSI escapes are only emitted when color is true.
+func renderMarkdown(text string, color bool) string {
+	r := &markdownRenderer{color: color}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = r.renderLine(line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderLine renders a single line, without its trailing newline
+func (r *markdownRenderer) renderLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+
+	// Code fences toggle code mode; the fence itself shows the language, if any
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		r.inCode = !r.inCode
+		lang := strings.TrimSpace(trimmed[3:])
+		if !r.inCode || lang == "" {
+			return ""
+		}
+		return r.style(ansiDim, ansiReset, codeIndent+lang)
+	}
+
+	if r.inCode {
+		return codeIndent + r.style(ansiYellow, ansiDefaultFg, line)
+	}
+
+	if m := headingPattern.FindStringSubmatch(trimmed); m != nil {
+		return r.style(ansiBold, ansiBoldOff, r.renderInline(m[2]))
+	}
+
+	if m := listItemPattern.FindStringSubmatch(line); m != nil {
+		level := indentWidth(m[1]) / 2
+		marker := m[2]
+		if !strings.ContainsAny(marker[:1], "0123456789") {
+			marker = bulletGlyphs[len(bulletGlyphs)-1]
+			if level < len(bulletGlyphs) {
+				marker = bulletGlyphs[level]
+			}
+		}
+		return strings.Repeat(listIndentUnit, level+1) + marker + " " + r.renderInline(m[3])
+	}
+
+	if strings.HasPrefix(trimmed, ">") {
+		quote := strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+		return r.style(ansiDim, ansiReset, "│ ") + r.style(ansiItalic, ansiItalicOff, r.renderInline(quote))
+	}
+
+	return r.renderInline(line)
+}
+
+// renderInline replaces inline code, bold and italic markers
+func (r *markdownRenderer) renderInline(text string) string {
+	text = inlineCode.ReplaceAllStringFunc(text, func(s string) string {
+		return r.style(ansiCyan, ansiDefaultFg, s[1:len(s)-1])
+	})
+	text = boldPattern.ReplaceAllStringFunc(text, func(s string) string {
+		return r.style(ansiBold, ansiBoldOff, s[2:len(s)-2])
+	})
+	text = italicPattern.ReplaceAllStringFunc(text, func(s string) string {
+		return r.style(ansiItalic, ansiItalicOff, s[1:len(s)-1])
+	})
+	return text
+}
+
+// style wraps text in the given escapes when color is enabled
+func (r *markdownRenderer) style(on, off, text string) string {
+	if !r.color || text == "" {
+		return text
+	}
+	return on + text + off
+}
+
+// indentWidth returns the visual width of leading whitespace, counting tabs as 4
+func indentWidth(s string) int {
+	width := 0
+	for _, c := range s {
+		if c == '\t' {
+			width += 4
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// Longest partial line a markdownWriter holds back to render. The rest of a
+// longer line, such as minified JSON, is written unrendered as it arrives,
+// so that it shows up before it ends.
+const maxMarkdownLineBuffer = 4096
+
+// markdownWriter renders markdown written to it one complete line at a time,
+// so streamed responses can be displayed as they arrive
+type markdownWriter struct {
+	w        io.Writer
+	renderer markdownRenderer
+	buf      []byte
+	// Whether the current line went past maxMarkdownLineBuffer and is
+	// written as is
+	raw bool
+}
+
+// newMarkdownWriter returns a writer that renders markdown to w
+func newMarkdownWriter(w io.Writer, color bool) *markdownWriter {
+	return &markdownWriter{w: w, renderer: markdownRenderer{color: color}}
+}
+
+// Write buffers p and renders every complete line
+func (m *markdownWriter) Write(p []byte) (int, error) {
+	m.buf = append(m.buf, p...)
+
+	for {
+		i := bytes.IndexByte(m.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := string(m.buf[:i])
+		m.buf = m.buf[i+1:]
+
+		if m.raw {
+			m.raw = false
+		} else {
+			line = m.renderer.renderLine(line)
+		}
+		if _, err := io.WriteString(m.w, line+"\n"); err != nil {
+			return len(p), err
+		}
+	}
+
+	if m.raw || len(m.buf) > maxMarkdownLineBuffer {
+		m.raw = true
+		_, err := m.w.Write(m.buf)
+		m.buf = nil
+		if err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush renders any buffered partial line
+func (m *markdownWriter) Flush() error {
+	if len(m.buf) == 0 {
+		return nil
+	}
+
+	line := string(m.buf)
+	m.buf = nil
+	if m.raw {
+		m.raw = false
+	} else {
+		line = m.renderer.renderLine(line)
+	}
+
+	_, err := io.WriteString(m.w, line)
+	return err
+}
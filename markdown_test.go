@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestRenderMarkdown tests rendering without colors
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain text",
+			input:    "Hello, world!",
+			expected: "Hello, world!",
+		},
+		{
+			name:     "bold and italic markers are removed",
+			input:    "This is **bold**, __strong__, *italic* and _emphasis_.",
+			expected: "This is bold, strong, italic and emphasis.",
+		},
+		{
+			name:     "snake case is left alone",
+			input:    "Call my_func_name with 2 * 3 * 4",
+			expected: "Call my_func_name with 2 * 3 * 4",
+		},
+		{
+			name:     "inline code",
+			input:    "Run `go test` now",
+			expected: "Run go test now",
+		},
+		{
+			name:     "heading",
+			input:    "## Installation",
+			expected: "Installation",
+		},
+		{
+			name:  "nested lists",
+			input: "- first\n  - nested\n    - deeper\n      - deepest\n- second\n1. one\n   2. two",
+			expected: "  • first\n" +
+				"    ◦ nested\n" +
+				"      ▪ deeper\n" +
+				"        ▪ deepest\n" +
+				"  • second\n" +
+				"  1. one\n" +
+				"    2. two",
+		},
+		{
+			name:     "fenced code block",
+			input:    "Example:\n```go\nfmt.Println(\"**not bold**\")\n```\nDone",
+			expected: "Example:\n    go\n    fmt.Println(\"**not bold**\")\n\nDone",
+		},
+		{
+			name:     "unterminated code fence",
+			input:    "Start\n```\n- not a list\n# not a heading",
+			expected: "Start\n\n    - not a list\n    # not a heading",
+		},
+		{
+			name:     "blockquote",
+			input:    "> quoted *text*",
+			expected: "│ quoted text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := renderMarkdown(tt.input, false)
+			if result != tt.expected {
+				t.Errorf("renderMarkdown() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRenderMarkdownColor tests ANSI escapes when colors are enabled
+func TestRenderMarkdownColor(t *testing.T) {
+	result := renderMarkdown("**bold** and *italic*\n```\ncode\n```", true)
+
+	for _, escape := range []string{ansiBold, ansiBoldOff, ansiItalic, ansiItalicOff, ansiYellow} {
+		if !strings.Contains(result, escape) {
+			t.Errorf("renderMarkdown() = %q, missing escape %q", result, escape)
+		}
+	}
+
+	if plain := renderMarkdown("**bold** and *italic*\n```\ncode\n```", false); strings.Contains(plain, "\x1b[") {
+		t.Errorf("renderMarkdown() without color contains escapes: %q", plain)
+	}
+}
+
+// TestMarkdownWriter tests that incremental rendering matches full rendering
+func TestMarkdownWriter(t *testing.T) {
+	input := "Intro with **bold**\n```python\nprint('hi')\n```\n- item\n  - nested\nlast line"
+
+	var out strings.Builder
+	md := newMarkdownWriter(&out, false)
+
+	// Feed the input in small chunks, as a stream would
+	for i := 0; i < len(input); i += 3 {
+		end := i + 3
+		if end > len(input) {
+			end = len(input)
+		}
+		if _, err := md.Write([]byte(input[i:end])); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := md.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	expected := renderMarkdown(input, false)
+	if out.String() != expected {
+		t.Errorf("markdownWriter output = %q, want %q", out.String(), expected)
+	}
+}
+
+// TestMarkdownWriterLongLine tests that a line too long to buffer is written
+// as it arrives instead of once it ends
+func TestMarkdownWriterLongLine(t *testing.T) {
+	var out strings.Builder
+	md := newMarkdownWriter(&out, false)
+
+	long := strings.Repeat("ü", maxMarkdownLineBuffer)
+	if _, err := md.Write([]byte("# Title\n" + long)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.HasSuffix(out.String(), long) {
+		t.Errorf("long line not written before it ended: got %d bytes", out.Len())
+	}
+	if _, err := md.Write([]byte("more\n**bold**\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := md.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := renderMarkdown("# Title", false) + "\n" + long + "more\n" + renderMarkdown("**bold**", false) + "\n"
+	if out.String() != want {
+		t.Errorf("markdownWriter output = %d bytes, want %d bytes as rendered line by line", out.Len(), len(want))
+	}
+}
+
+// TestIndentWidth tests leading whitespace measurement
+func TestIndentWidth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", 0},
+		{"  ", 2},
+		{"\t", 4},
+		{" \t", 5},
+	}
+
+	for _, tt := range tests {
+		if result := indentWidth(tt.input); result != tt.expected {
+			t.Errorf("indentWidth(%q) = %d, want %d", tt.input, result, tt.expected)
+		}
+	}
+}
+
+// TestIsTerminalDevNull tests that /dev/null, a character device, is not
+// treated as a terminal
+func TestIsTerminalDevNull(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+	if isTerminal(f) {
+		t.Error("isTerminal(/dev/null) = true, want false")
+	}
+}
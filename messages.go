@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Roles a message of prompt --messages may have
+var messageRoles = []string{"system", "user", "assistant", "tool"}
+
+// messageFields are the fields of a message in a --messages file. Pointers
+// tell a missing field from an empty one.
+type messageFields struct {
+	Role       *string    `json:"role"`
+	Content    *string    `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls"`
+	ToolCallID string     `json:"tool_call_id"`
+}
+
+// readMessagesFile reads the conversation of prompt --messages: a JSON array
+// of messages with a role and content. Errors name the message at fault by
+// its index in the array, from 0, and the field, as in [2].role.
+func readMessagesFile(path string) ([]Message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, usageErrorf("%s: expected a JSON array of {\"role\", \"content\"} objects: %v", path, err)
+	}
+	if len(raw) == 0 {
+		return nil, usageErrorf("%s: no messages", path)
+	}
+
+	messages := make([]Message, 0, len(raw))
+	for i, item := range raw {
+		message, field, err := parseMessage(item)
+		if err != nil {
+			if field != "" {
+				field = "." + field
+			}
+			return nil, usageErrorf("%s: [%d]%s: %v", path, i, field, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// parseMessage checks one message of a --messages file, returning the field
+// at fault with the error
+func parseMessage(data json.RawMessage) (Message, string, error) {
+	var fields messageFields
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&fields); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return Message{}, typeErr.Field, fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value)
+		}
+		return Message{}, "", fmt.Errorf("expected an object with role and content: %v", err)
+	}
+
+	if fields.Role == nil {
+		return Message{}, "role", errors.New("missing")
+	}
+	role := *fields.Role
+	if !containsString(messageRoles, role) {
+		return Message{}, "role", fmt.Errorf("%q is not one of %s", role, strings.Join(messageRoles, ", "))
+	}
+	// An assistant message asking for tools may have no content
+	if fields.Content == nil && !(role == "assistant" && len(fields.ToolCalls) > 0) {
+		return Message{}, "content", errors.New("missing")
+	}
+	if len(fields.ToolCalls) > 0 && role != "assistant" {
+		return Message{}, "tool_calls", errors.New("only assistant messages call tools")
+	}
+	if role == "tool" && fields.ToolCallID == "" {
+		return Message{}, "tool_call_id", errors.New("missing; a tool message answers a call of the message before")
+	}
+
+	message := Message{Role: role, ToolCalls: fields.ToolCalls, ToolCallID: fields.ToolCallID}
+	if fields.Content != nil {
+		message.Content = *fields.Content
+	}
+	return message, "", nil
+}
+
+// summarizeMessages describes a conversation for the log, as
+// "5 messages, 1.2k chars"
+func summarizeMessages(messages []Message) string {
+	chars := 0
+	for _, message := range messages {
+		chars += len([]rune(message.Content))
+	}
+	noun := "messages"
+	if len(messages) == 1 {
+		noun = "message"
+	}
+	return fmt.Sprintf("%d %s, %s chars", len(messages), noun, formatThousands(chars))
+}
+
+// formatThousands shortens counts of a thousand or more, as 1.2k
+func formatThousands(n int) string {
+	if n < 1000 {
+		return fmt.Sprintf("%d", n)
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1000), ".0") + "k"
+}
+
+// hasToolMessages reports whether a conversation has tool calls or results
+func hasToolMessages(messages []Message) bool {
+	for _, message := range messages {
+		if message.Role == "tool" || len(message.ToolCalls) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// messagesText returns the text of a conversation, for the moderation check
+func messagesText(messages []Message) string {
+	var parts []string
+	for _, message := range messages {
+		if message.Content != "" {
+			parts = append(parts, message.Content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
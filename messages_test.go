@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeMessagesFile writes a --messages file to a temporary directory
+func writeMessagesFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "messages.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestReadMessagesFile tests the messages accepted by --messages and the
+// index and field reported for those that are not
+func TestReadMessagesFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "conversation",
+			content: `[{"role":"system","content":"Be brief"},{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello"}]`,
+			want:    "system:Be brief user:Hi assistant:Hello",
+		},
+		{
+			name: "tool call and result",
+			content: `[{"role":"user","content":"What time is it?"},
+				{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_time","arguments":"{}"}}]},
+				{"role":"tool","tool_call_id":"call_1","content":"12:00"}]`,
+			want: "user:What time is it? assistant: tool:12:00",
+		},
+		{name: "not an array", content: `{"role":"user"}`, wantErr: "expected a JSON array"},
+		{name: "empty", content: `[]`, wantErr: "no messages"},
+		{name: "unknown role", content: `[{"role":"user","content":"Hi"},{"role":"bot","content":"Hi"}]`, wantErr: `[1].role: "bot" is not one of system, user, assistant, tool`},
+		{name: "missing role", content: `[{"content":"Hi"}]`, wantErr: "[0].role: missing"},
+		{name: "missing content", content: `[{"role":"user"}]`, wantErr: "[0].content: missing"},
+		{name: "content not a string", content: `[{"role":"user","content":"Hi"},{"role":"user","content":42}]`, wantErr: "[1].content: expected string"},
+		{name: "unknown field", content: `[{"role":"user","content":"Hi","name":"me"}]`, wantErr: `[0]: expected an object with role and content: json: unknown field "name"`},
+		{name: "tool without call", content: `[{"role":"tool","content":"12:00"}]`, wantErr: "[0].tool_call_id: missing"},
+		{name: "user calling tools", content: `[{"role":"user","content":"Hi","tool_calls":[{"id":"call_1"}]}]`, wantErr: "[0].tool_calls: only assistant messages call tools"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := readMessagesFile(writeMessagesFile(t, tt.content))
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want a usage error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			var got []string
+			for _, m := range messages {
+				got = append(got, m.Role+":"+m.Content)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("messages = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSummarizeMessages tests the summary of a conversation in the log
+func TestSummarizeMessages(t *testing.T) {
+	tests := []struct {
+		messages []Message
+		want     string
+	}{
+		{[]Message{{Role: "user", Content: "Hi"}}, "1 message, 2 chars"},
+		{[]Message{{Role: "system", Content: strings.Repeat("a", 200)}, {Role: "user", Content: strings.Repeat("é", 1000)}}, "2 messages, 1.2k chars"},
+		{[]Message{{Role: "user", Content: strings.Repeat("a", 3000)}}, "1 message, 3k chars"},
+	}
+	for _, tt := range tests {
+		if got := summarizeMessages(tt.messages); got != tt.want {
+			t.Errorf("summarizeMessages() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// TestPromptMessages tests that --messages sends the conversation as it is,
+// with the prompt text after it if given, and logs its summary
+func TestPromptMessages(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var request ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = ChatRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Paris"}}],"usage":{"prompt_tokens":20,"completion_tokens":1,"total_tokens":21}}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:       "sk-test",
+		APIURL:       server.URL,
+		Model:        "gpt-4o",
+		Timeout:      5 * time.Second,
+		ConfigDir:    t.TempDir(),
+		SystemPrompt: "not sent with --messages",
+	}
+	path := writeMessagesFile(t, `[{"role":"system","content":"Answer in one word"},{"role":"user","content":"Capital of Italy?"},{"role":"assistant","content":"Rome"}]`)
+
+	sent := func() string {
+		var roles []string
+		for _, m := range request.Messages {
+			roles = append(roles, m.Role+":"+m.Content)
+		}
+		return strings.Join(roles, " ")
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "--messages", path}); err != nil {
+			t.Fatalf("promptCommand() error = %v", err)
+		}
+	})
+	if got, want := sent(), "system:Answer in one word user:Capital of Italy? assistant:Rome"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "--messages", path, "And of France?"}); err != nil {
+			t.Fatalf("promptCommand() error = %v", err)
+		}
+	})
+	if got, want := sent(), "system:Answer in one word user:Capital of Italy? assistant:Rome user:And of France?"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	entries := readTestLogEntries(t, config.ConfigDir)
+	last := entries[len(entries)-1]
+	if last.Messages != "3 messages, 39 chars" || last.Prompt != "And of France?" || last.Response != "Paris" {
+		t.Errorf("logged entry = %+v", last)
+	}
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"show", "1"}); err != nil {
+			t.Fatalf("logs show error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Messages: 3 messages, 39 chars") {
+		t.Errorf("logs show output = %q", out)
+	}
+
+	// The dry run shows the conversation that would be sent
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--dry-run", "--messages", path}); err != nil {
+			t.Fatalf("dry run error = %v", err)
+		}
+	})
+	if !strings.Contains(out, `"content": "Rome"`) || strings.Contains(out, config.SystemPrompt) {
+		t.Errorf("dry run output = %s", out)
+	}
+
+	for _, args := range [][]string{
+		{"--messages", path, "--continue", "hi"},
+		{"--messages", path, "--style", "concise"},
+		{"--messages", writeMessagesFile(t, `[{"role":"bot","content":"hi"}]`)},
+	} {
+		if err := promptCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("promptCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
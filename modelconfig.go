@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+)
+
+// Prefix of the config.toml tables holding the values of one model, as in
+// [models."gpt-4"], which can't be model since that key holds OPENAI_MODEL.
+// The legacy config file wrote them MODEL.gpt-4.KEY=value.
+const (
+	modelTablePrefix     = "models."
+	legacyModelKeyPrefix = "MODEL."
+)
+
+// Keys that can be set for a single model. They replace the configured
+// value whenever that model is used.
+var modelScopedKeys = configKeyNames(func(k configKey) bool { return k.modelScoped })
+
+// isModelScopedKey reports whether key can be set for a single model
+func isModelScopedKey(key string) bool {
+	for _, k := range modelScopedKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// modelTable returns the table holding a model's values
+func modelTable(model string) string {
+	return modelTablePrefix + model
+}
+
+// tableModel returns the model whose values a table holds, if it holds one.
+// Model names may contain dots, so everything after the prefix is the name.
+func tableModel(table string) (string, bool) {
+	model := strings.TrimPrefix(table, modelTablePrefix)
+	return model, model != table && model != ""
+}
+
+// tableHeader returns the header line of a table, quoting a model name that
+// is not a bare key, e.g. [models."gpt-4.1"]
+func tableHeader(table string) string {
+	if model, ok := tableModel(table); ok && !isBareKey(model) {
+		return "[" + modelTablePrefix + quoteTOMLString(model) + "]"
+	}
+	return "[" + table + "]"
+}
+
+// isBareKey reports whether s can be written as a TOML key without quotes
+func isBareKey(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isBareKeyChar(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// parseModelScopedKey splits a key written as MODEL.<model>.<KEY>, as
+// accepted by config set and config unset
+func parseModelScopedKey(name string) (model, key string, ok bool) {
+	if len(name) <= len(legacyModelKeyPrefix) || !strings.EqualFold(name[:len(legacyModelKeyPrefix)], legacyModelKeyPrefix) {
+		return "", "", false
+	}
+	rest := name[len(legacyModelKeyPrefix):]
+	i := strings.LastIndex(rest, ".")
+	if i <= 0 || i == len(rest)-1 {
+		return "", "", false
+	}
+	return rest[:i], strings.ToUpper(rest[i+1:]), true
+}
+
+// loadModelConfigs returns the values set for single models in the config
+// file, keyed by model name
+func loadModelConfigs(configDir string) (map[string]map[string]string, error) {
+	doc, err := loadConfigDocument(configDir)
+	if err != nil {
+		return nil, err
+	}
+	return doc.modelSections(), nil
+}
+
+// saveModelConfig saves values to a model's table of config.toml, creating
+// the table if needed. Keys with an empty value are removed.
+func saveModelConfig(configDir, model string, values map[string]string) error {
+	return updateConfigDocument(configDir, func(doc *configDocument) {
+		table := modelTable(model)
+		for _, key := range modelScopedKeys {
+			value, exists := values[key]
+			switch {
+			case !exists:
+			case value == "":
+				doc.unsetIn(table, key)
+			default:
+				doc.setIn(table, key, value)
+			}
+		}
+	})
+}
+
+// readModelValues reads the values that can be set per model into config
+func readModelValues(config *Config, r *configReader) {
+	for _, key := range configKeys {
+		if key.modelScoped {
+			key.load(config, r)
+		}
+	}
+}
+
+// useModel switches config to another model, replacing the values set for
+// the previous model with those set for this one. The command's flags are
+// applied after, so they still win.
+func useModel(config *Config, model string) {
+	config.Model = model
+	// Without the config file's values, as in tests, only the model changes
+	if config.fileConfig == nil {
+		return
+	}
+	readModelValues(config, &configReader{file: config.fileConfig, model: model, models: config.modelConfigs})
+}
+
+// modelScopedValues returns the keys whose value is the one set for the
+// model in use, which the environment still overrides
+func modelScopedValues(config *Config) map[string]bool {
+	r := &configReader{model: config.Model, models: config.modelConfigs}
+	scoped := make(map[string]bool)
+	for _, key := range modelScopedKeys {
+		if _, source := r.lookup(key); source == configSourceModel {
+			scoped[key] = true
+		}
+	}
+	return scoped
+}
+
+// modelScopedName returns the name of a model's value in messages, in the
+// form config set accepts, e.g. MODEL.gpt-4.OPENAI_MAX_TOKENS
+func modelScopedName(model, key string) string {
+	return legacyModelKeyPrefix + model + "." + key
+}
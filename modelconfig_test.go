@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testModelConfigFile sets max tokens globally and for gpt-4, and the
+// temperature only for gpt-4
+const testModelConfigFile = `model = "gpt-3.5-turbo"
+max_tokens = 1000
+
+[models.gpt-4]
+max_tokens = 4000
+temperature = 0.2
+
+[models."gpt-4.1"]
+max_tokens = 8000
+`
+
+// TestModelScopedPrecedence tests that a flag wins over the value set for
+// the model, which wins over the rest of the configuration and the default
+func TestModelScopedPrecedence(t *testing.T) {
+	tests := []struct {
+		name            string
+		file            string
+		env             map[string]string
+		args            []string
+		wantModel       string
+		wantMaxTokens   int
+		wantTemperature float64
+	}{
+		{
+			name:            "default",
+			file:            "",
+			wantModel:       defaultModel,
+			wantMaxTokens:   defaultMaxTokens,
+			wantTemperature: defaultTemperature,
+		},
+		{
+			name:            "global",
+			file:            testModelConfigFile,
+			wantModel:       "gpt-3.5-turbo",
+			wantMaxTokens:   1000,
+			wantTemperature: defaultTemperature,
+		},
+		{
+			name:            "configured model",
+			file:            strings.Replace(testModelConfigFile, "gpt-3.5-turbo", "gpt-4", 1),
+			wantModel:       "gpt-4",
+			wantMaxTokens:   4000,
+			wantTemperature: 0.2,
+		},
+		{
+			name:            "model from the environment",
+			file:            testModelConfigFile,
+			env:             map[string]string{envModel: "gpt-4"},
+			wantModel:       "gpt-4",
+			wantMaxTokens:   4000,
+			wantTemperature: 0.2,
+		},
+		{
+			name:            "model flag",
+			file:            testModelConfigFile,
+			args:            []string{"--model", "gpt-4"},
+			wantModel:       "gpt-4",
+			wantMaxTokens:   4000,
+			wantTemperature: 0.2,
+		},
+		{
+			name:            "model flag with a dotted name",
+			file:            testModelConfigFile,
+			args:            []string{"--model", "gpt-4.1"},
+			wantModel:       "gpt-4.1",
+			wantMaxTokens:   8000,
+			wantTemperature: defaultTemperature,
+		},
+		{
+			name:            "model flag back from a configured model",
+			file:            strings.Replace(testModelConfigFile, "gpt-3.5-turbo", "gpt-4", 1),
+			args:            []string{"--model", "gpt-4o-mini"},
+			wantModel:       "gpt-4o-mini",
+			wantMaxTokens:   1000,
+			wantTemperature: defaultTemperature,
+		},
+		{
+			name:            "flag over model value",
+			file:            testModelConfigFile,
+			args:            []string{"--model", "gpt-4", "--max-tokens", "50"},
+			wantModel:       "gpt-4",
+			wantMaxTokens:   50,
+			wantTemperature: 0.2,
+		},
+		{
+			name:            "environment over model value",
+			file:            testModelConfigFile,
+			env:             map[string]string{envMaxTokens: "300"},
+			args:            []string{"--model", "gpt-4"},
+			wantModel:       "gpt-4",
+			wantMaxTokens:   300,
+			wantTemperature: 0.2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanup := setupTestEnv(t)
+			defer cleanup()
+
+			var request ChatRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&request)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+			}))
+			defer server.Close()
+
+			tmpDir := t.TempDir()
+			setTestEnv(envConfigDir, tmpDir)
+			setTestEnv(envAPIKey, "sk-test")
+			setTestEnv(envAPIURL, server.URL)
+			writeTestConfigFile(t, tmpDir, tt.file)
+			for key, value := range tt.env {
+				setTestEnv(key, value)
+			}
+
+			config, err := loadConfig("")
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			args := append(append([]string{"--no-stream"}, tt.args...), "hello")
+			captureOutput(t, &os.Stdout, func() {
+				if err := promptCommand(config, args); err != nil {
+					t.Fatalf("promptCommand() error = %v", err)
+				}
+			})
+
+			if request.Model != tt.wantModel || request.MaxTokens != tt.wantMaxTokens || request.Temperature != tt.wantTemperature {
+				t.Errorf("request model %s, max tokens %d, temperature %v; want %s, %d, %v",
+					request.Model, request.MaxTokens, request.Temperature, tt.wantModel, tt.wantMaxTokens, tt.wantTemperature)
+			}
+		})
+	}
+}
+
+// TestParseModelScopedKey tests the MODEL.<model>.<KEY> form of keys
+func TestParseModelScopedKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantModel string
+		wantKey   string
+		wantOK    bool
+	}{
+		{"MODEL.gpt-4.OPENAI_MAX_TOKENS", "gpt-4", "OPENAI_MAX_TOKENS", true},
+		{"model.gpt-4.1.openai_temperature", "gpt-4.1", "OPENAI_TEMPERATURE", true},
+		{"MODEL.gpt-4", "", "", false},
+		{"MODEL..OPENAI_MAX_TOKENS", "", "", false},
+		{"OPENAI_MAX_TOKENS", "", "", false},
+	}
+
+	for _, tt := range tests {
+		model, key, ok := parseModelScopedKey(tt.name)
+		if model != tt.wantModel || key != tt.wantKey || ok != tt.wantOK {
+			t.Errorf("parseModelScopedKey(%q) = %q, %q, %v; want %q, %q, %v", tt.name, model, key, ok, tt.wantModel, tt.wantKey, tt.wantOK)
+		}
+	}
+}
+
+// TestConfigSetModel tests that config set --model writes the model's table
+// and config list --model marks the values taken from it
+func TestConfigSetModel(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	setTestEnv(envNoColor, "true")
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		for _, args := range [][]string{
+			{"OPENAI_MAX_TOKENS", "1000"},
+			{"--model", "gpt-4", "OPENAI_MAX_TOKENS", "4000"},
+			{"MODEL.gpt-4.1.OPENAI_TEMPERATURE", "0.3"},
+		} {
+			if err := configSetCommand(config, args); err != nil {
+				t.Fatalf("config set %q error = %v", args, err)
+			}
+		}
+	})
+
+	data, err := os.ReadFile(configFilePath(tmpDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"max_tokens = 1000\n", "[models.gpt-4]\nmax_tokens = 4000\n", "[models.\"gpt-4.1\"]\ntemperature = 0.3\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config file = %s, want it to contain %q", data, want)
+		}
+	}
+
+	if err := configSetCommand(config, []string{"--model", "gpt-4", "OPENAI_API_URL", "http://localhost"}); err == nil || exitCode(err) != exitUsage {
+		t.Errorf("config set --model of OPENAI_API_URL error = %v, want a usage error", err)
+	}
+
+	config, err = loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := configListCommand(config, []string{"--model", "gpt-4"}); err != nil {
+			t.Fatalf("config list --model error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "OPENAI_MAX_TOKENS\t") || !strings.Contains(out, "4000 (model gpt-4)") {
+		t.Errorf("config list --model output = %s, want the marked model value", out)
+	}
+	if !strings.Contains(out, "OPENAI_MODEL\t") || strings.Contains(out, "0.7 (model") {
+		t.Errorf("config list --model output = %s, want only the model's values marked", out)
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := configUnsetCommand(config, []string{"--model", "gpt-4", "OPENAI_MAX_TOKENS"}); err != nil {
+			t.Fatalf("config unset --model error = %v", err)
+		}
+	})
+	modelConfigs, err := loadModelConfigs(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := modelConfigs["gpt-4"][envMaxTokens]; exists {
+		t.Errorf("model values = %v, want OPENAI_MAX_TOKENS unset for gpt-4", modelConfigs)
+	}
+}
+
+// TestLegacyModelValues tests that MODEL.<model>.<KEY> lines of the legacy
+// config file move to the model's table
+func TestLegacyModelValues(t *testing.T) {
+	tmpDir := t.TempDir()
+	legacy := "OPENAI_MAX_TOKENS=1000\nMODEL.gpt-4.OPENAI_MAX_TOKENS=4000\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, legacyConfigFileName), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	modelConfigs, err := loadModelConfigs(tmpDir)
+	if err != nil {
+		t.Fatalf("loadModelConfigs() error = %v", err)
+	}
+	if modelConfigs["gpt-4"][envMaxTokens] != "4000" {
+		t.Errorf("model values = %v, want gpt-4 max tokens 4000", modelConfigs)
+	}
+}
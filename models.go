@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// OpenAI models API response structures
+type ModelsResponse struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+
+	// Models is the list returned by Ollama's /api/tags instead of data
+	Models []OllamaModel `json:"models,omitempty"`
+}
+
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// modelsCommand lists the models available from the API
+func modelsCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("models", flag.ContinueOnError)
+	filter := fs.String("filter", "", "show only models whose ID contains this text")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli models [--filter <text>]", err)
+	}
+
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	models, err := fetchModels(config.requestContext(), config)
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+
+	ids := filterModelIDs(models, *filter)
+	if len(ids) == 0 {
+		fmt.Println("No models found.")
+		return nil
+	}
+
+	for _, id := range ids {
+		fmt.Println(id)
+	}
+
+	return nil
+}
+
+// getModelsURL returns the models endpoint, derived from the chat completions
+// URL unless OPENAI_MODELS_URL is set
+func getModelsURL(config *Config) string {
+	if config.ModelsURL != "" {
+		return config.ModelsURL
+	}
+
+	u, err := url.Parse(config.APIURL)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(defaultAPIURL, "/chat/completions") + "/models"
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"):
+		u.Path = strings.TrimSuffix(path, "/chat/completions") + "/models"
+	case strings.HasSuffix(path, "/api/chat"):
+		u.Path = strings.TrimSuffix(path, "/api/chat") + "/api/tags"
+	default:
+		u.Path = "/v1/models"
+	}
+	u.RawQuery = ""
+
+	return u.String()
+}
+
+// newModelsRequest builds the request listing the available models
+func newModelsRequest(ctx context.Context, config *Config) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", getModelsURL(config), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	setAuthHeader(req, config)
+	return req, nil
+}
+
+// fetchModels retrieves the list of models from the API
+func fetchModels(ctx context.Context, config *Config) ([]Model, error) {
+	client := newAPIClient(config)
+	resp, err := client.do(ctx, func() (*http.Request, error) {
+		return newModelsRequest(ctx, config)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var modelsResponse ModelsResponse
+	if err := decodeResponse(resp, &modelsResponse); err != nil {
+		return nil, err
+	}
+
+	for _, model := range modelsResponse.Models {
+		modelsResponse.Data = append(modelsResponse.Data, Model{ID: model.Name, Object: "model"})
+	}
+	return modelsResponse.Data, nil
+}
+
+// filterModelIDs returns the sorted IDs of models containing filter
+func filterModelIDs(models []Model, filter string) []string {
+	var ids []string
+	for _, model := range models {
+		if filter != "" && !strings.Contains(model.ID, filter) {
+			continue
+		}
+		ids = append(ids, model.ID)
+	}
+
+	sort.Strings(ids)
+	return ids
+}
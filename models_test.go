@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGetModelsURL tests deriving the models endpoint
+func TestGetModelsURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		apiURL    string
+		modelsURL string
+		expected  string
+	}{
+		{
+			name:     "default API URL",
+			apiURL:   defaultAPIURL,
+			expected: "https://api.openai.com/v1/models",
+		},
+		{
+			name:     "proxy with prefix",
+			apiURL:   "https://proxy.example.com/openai/v1/chat/completions",
+			expected: "https://proxy.example.com/openai/v1/models",
+		},
+		{
+			name:     "URL without completions path",
+			apiURL:   "http://localhost:8080",
+			expected: "http://localhost:8080/v1/models",
+		},
+		{
+			name:     "ollama chat endpoint",
+			apiURL:   defaultOllamaAPIURL,
+			expected: "http://localhost:11434/api/tags",
+		},
+		{
+			name:     "query string is dropped",
+			apiURL:   "https://api.example.com/v1/chat/completions?foo=bar",
+			expected: "https://api.example.com/v1/models",
+		},
+		{
+			name:      "explicit models URL",
+			apiURL:    defaultAPIURL,
+			modelsURL: "https://models.example.com/list",
+			expected:  "https://models.example.com/list",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				APIURL:    tt.apiURL,
+				ModelsURL: tt.modelsURL,
+			}
+
+			result := getModelsURL(config)
+			if result != tt.expected {
+				t.Errorf("getModelsURL() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFilterModelIDs tests sorting and substring filtering of model IDs
+func TestFilterModelIDs(t *testing.T) {
+	models := []Model{
+		{ID: "gpt-4o"},
+		{ID: "dall-e-3"},
+		{ID: "gpt-3.5-turbo"},
+		{ID: "gpt-4"},
+	}
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected []string
+	}{
+		{"no filter", "", []string{"dall-e-3", "gpt-3.5-turbo", "gpt-4", "gpt-4o"}},
+		{"gpt-4 filter", "gpt-4", []string{"gpt-4", "gpt-4o"}},
+		{"no matches", "whisper", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := filterModelIDs(models, tt.filter)
+			if strings.Join(result, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("filterModelIDs() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestModelsCommand tests listing models against a mock endpoint
+func TestModelsCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tests := []struct {
+		name          string
+		apiKey        string
+		args          []string
+		serverHandler http.HandlerFunc
+		wantErr       bool
+		errContains   string
+	}{
+		{
+			name:   "successful request",
+			apiKey: "test-key",
+			args:   []string{"--filter", "gpt"},
+			serverHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/models" {
+					t.Errorf("path = %q, want %q", r.URL.Path, "/v1/models")
+				}
+				if r.Header.Get("Authorization") != "Bearer test-key" {
+					t.Errorf("Authorization header = %q, want %q", r.Header.Get("Authorization"), "Bearer test-key")
+				}
+				fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-4o","object":"model"},{"id":"whisper-1","object":"model"}]}`)
+			}),
+			wantErr: false,
+		},
+		{
+			name:   "empty list",
+			apiKey: "test-key",
+			serverHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"object":"list","data":[]}`)
+			}),
+			wantErr: false,
+		},
+		{
+			name:   "API error",
+			apiKey: "bad-key",
+			serverHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`)
+			}),
+			wantErr:     true,
+			errContains: "API error: Incorrect API key provided",
+		},
+		{
+			name:   "non-JSON error",
+			apiKey: "test-key",
+			serverHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+				fmt.Fprint(w, "bad gateway")
+			}),
+			wantErr:     true,
+			errContains: "unexpected status code: 502",
+		},
+		{
+			name:   "invalid JSON",
+			apiKey: "test-key",
+			serverHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "not json")
+			}),
+			wantErr:     true,
+			errContains: "failed to parse response",
+		},
+		{
+			name:        "missing API key",
+			apiKey:      "",
+			wantErr:     true,
+			errContains: "missing API key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.serverHandler
+			if handler == nil {
+				handler = func(w http.ResponseWriter, r *http.Request) {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+			}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			config := &Config{
+				APIKey:  tt.apiKey,
+				APIURL:  server.URL + "/v1/chat/completions",
+				Timeout: 10 * time.Second,
+			}
+
+			err := modelsCommand(config, tt.args)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("modelsCommand() expected error, got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("modelsCommand() unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
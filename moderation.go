@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ModerationRequest is the body of a moderations request
+type ModerationRequest struct {
+	Input string `json:"input"`
+}
+
+// ModerationResponse is the reply of the moderations endpoint, with one
+// result per input
+type ModerationResponse struct {
+	Model   string             `json:"model,omitempty"`
+	Results []ModerationResult `json:"results"`
+}
+
+// ModerationResult tells whether an input was flagged, and for which
+// categories
+type ModerationResult struct {
+	Flagged    bool            `json:"flagged"`
+	Categories map[string]bool `json:"categories"`
+}
+
+// flaggedCategories returns the categories the input tripped, sorted
+func (r ModerationResult) flaggedCategories() []string {
+	var categories []string
+	for category, flagged := range r.Categories {
+		if flagged {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// moderatePrompt checks the prompt with the moderations endpoint. A flagged
+// prompt is refused with an error naming the categories it tripped, unless
+// force is set, in which case only a warning is printed.
+func moderatePrompt(config *Config, prompt string, force bool) error {
+	categories, err := fetchModeration(config.requestContext(), config, prompt)
+	if err != nil {
+		return fmt.Errorf("moderation check failed: %w", err)
+	}
+	if categories == nil {
+		return nil
+	}
+
+	flagged := strings.Join(categories, ", ")
+	if force {
+		fmt.Fprintf(os.Stderr, "Warning: moderation flagged the prompt (%s); sending it anyway because of --force\n", flagged)
+		return nil
+	}
+	return fmt.Errorf("moderation flagged the prompt (%s); it was not sent\nUse --force to send it anyway", flagged)
+}
+
+// getModerationsURL returns the moderations endpoint, derived from the chat
+// completions URL
+func getModerationsURL(config *Config) (string, error) {
+	return siblingEndpointURL(config, "/moderations")
+}
+
+// newModerationRequest builds the request moderating input
+func newModerationRequest(ctx context.Context, config *Config, input string) (*http.Request, error) {
+	jsonData, err := json.Marshal(ModerationRequest{Input: input})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	apiURL, err := getModerationsURL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeader(req, config)
+	if err := setExtraHeaders(req, config); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// fetchModeration returns the categories input was flagged for, or nil if
+// it wasn't flagged
+func fetchModeration(ctx context.Context, config *Config, input string) ([]string, error) {
+	client := newAPIClient(config)
+	resp, err := client.do(ctx, func() (*http.Request, error) {
+		return newModerationRequest(ctx, config, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ModerationResponse
+	if err := decodeResponse(resp, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Results) == 0 {
+		return nil, fmt.Errorf("the response contains no results")
+	}
+
+	result := response.Results[0]
+	if !result.Flagged {
+		return nil, nil
+	}
+	categories := result.flaggedCategories()
+	if len(categories) == 0 {
+		categories = []string{"unspecified"}
+	}
+	return categories, nil
+}
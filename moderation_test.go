@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newModerationServer returns a server flagging moderation inputs that
+// contain "attack" for violence and harassment, answering chat requests with
+// "ok", and counting the requests sent to each endpoint
+func newModerationServer(t *testing.T) (*httptest.Server, map[string]int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path != "/v1/moderations" {
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+			return
+		}
+		var request ModerationRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid moderation request: %v", err)
+		}
+		flagged := strings.Contains(request.Input, "attack")
+		result := ModerationResult{Flagged: flagged, Categories: map[string]bool{"violence": flagged, "harassment": flagged, "hate": false}}
+		json.NewEncoder(w).Encode(ModerationResponse{Model: "omni-moderation-latest", Results: []ModerationResult{result}})
+	}))
+	return server, requests
+}
+
+// TestPromptCommandModerate tests refusing flagged prompts, sending clean
+// ones and forcing flagged ones through
+func TestPromptCommandModerate(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server, requests := newModerationServer(t)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	newConfig := func() *Config {
+		return &Config{APIKey: "test-key", APIURL: server.URL + "/v1/chat/completions", Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: tmpDir}
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(newConfig(), []string{"--no-stream", "--moderate", "how do tides work?"}); err != nil {
+			t.Fatalf("clean prompt error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "ok") || requests["/v1/moderations"] != 1 || requests["/v1/chat/completions"] != 1 {
+		t.Errorf("output = %q, requests = %v; want the clean prompt checked and sent", out, requests)
+	}
+
+	err := promptCommand(newConfig(), []string{"--no-stream", "--moderate", "plan an attack"})
+	if err == nil || !strings.Contains(err.Error(), "moderation flagged the prompt (harassment, violence); it was not sent") {
+		t.Errorf("flagged prompt error = %v, want the categories named", err)
+	}
+	if requests["/v1/moderations"] != 2 || requests["/v1/chat/completions"] != 1 {
+		t.Errorf("requests = %v, want the flagged prompt not sent", requests)
+	}
+	entries := readTestLogEntries(t, tmpDir)
+	if len(entries) != 2 || entries[1].Prompt != "plan an attack" || !strings.Contains(entries[1].Error, "harassment, violence") {
+		t.Errorf("log entries = %+v, want the refusal logged with the categories", entries)
+	}
+
+	stderr := captureOutput(t, &os.Stderr, func() {
+		captureOutput(t, &os.Stdout, func() {
+			if err := promptCommand(newConfig(), []string{"--no-stream", "--moderate", "--force", "plan an attack"}); err != nil {
+				t.Fatalf("forced prompt error = %v", err)
+			}
+		})
+	})
+	if !strings.Contains(stderr, "Warning: moderation flagged the prompt (harassment, violence)") || requests["/v1/chat/completions"] != 2 {
+		t.Errorf("stderr = %q, requests = %v; want the flagged prompt sent with a warning", stderr, requests)
+	}
+
+	// CHATGPT_CLI_MODERATE turns the check on, and --moderate=false off
+	config := newConfig()
+	config.Moderate = true
+	if err := promptCommand(config, []string{"--no-stream", "plan an attack"}); err == nil {
+		t.Errorf("flagged prompt sent with moderation configured")
+	}
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "--moderate=false", "plan an attack"}); err != nil {
+			t.Fatalf("prompt error = %v", err)
+		}
+	})
+	if requests["/v1/moderations"] != 4 || requests["/v1/chat/completions"] != 3 {
+		t.Errorf("requests = %v, want the check skipped with --moderate=false", requests)
+	}
+}
+
+// TestPromptCommandModerateErrors tests the flags and providers --moderate
+// refuses
+func TestPromptCommandModerateErrors(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server, requests := newModerationServer(t)
+	defer server.Close()
+
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: t.TempDir()}
+	if err := promptCommand(config, []string{"--force", "hi"}); exitCode(err) != exitUsage || !strings.Contains(err.Error(), "--force needs --out or --moderate") {
+		t.Errorf("--force error = %v, want a usage error", err)
+	}
+
+	config.Provider = providerAnthropic
+	if err := promptCommand(config, []string{"--moderate", "hi"}); exitCode(err) != exitUsage || !strings.Contains(err.Error(), "no moderations endpoint") {
+		t.Errorf("anthropic error = %v, want a usage error", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("requests = %v, want none", requests)
+	}
+}
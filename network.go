@@ -0,0 +1,308 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Kinds of network failures, as reported by classifyNetworkError
+const (
+	netErrDNS     = "dns"
+	netErrRefused = "refused"
+	netErrReset   = "reset"
+	netErrTLS     = "tls"
+	netErrTimeout = "timeout"
+	// Connecting, TLS handshake included, took longer than
+	// OPENAI_CONNECT_TIMEOUT
+	netErrConnectTimeout = "connect-timeout"
+)
+
+// Pause before retrying a request that failed with a transient error
+var retryDelay = 500 * time.Millisecond
+
+// classifyNetworkError returns the kind of network failure behind err, or ""
+// if it is not a recognized network failure
+func classifyNetworkError(err error) string {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return netErrDNS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" && errors.Is(err, syscall.ECONNREFUSED) {
+		return netErrRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return netErrReset
+	}
+
+	if isConnectTimeout(err) {
+		return netErrConnectTimeout
+	}
+	if isTLSError(err) {
+		return netErrTLS
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Timeout() {
+		return netErrTimeout
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return netErrTimeout
+	}
+
+	return ""
+}
+
+// isConnectTimeout reports whether err comes from a dial or TLS handshake
+// that did not finish within the connect timeout
+func isConnectTimeout(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout() {
+		return true
+	}
+
+	// net/http doesn't export its TLS handshake timeout error
+	return strings.Contains(err.Error(), "TLS handshake timeout")
+}
+
+// isTLSError reports whether err comes from a failed TLS handshake
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certErr x509.CertificateInvalidError
+	if errors.As(err, &recordErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &certErr) {
+		return true
+	}
+
+	// Alerts sent by the server are only distinguishable by their message
+	return strings.Contains(err.Error(), "tls: ")
+}
+
+// isTransientNetworkError reports whether a failure is likely to go away when
+// the request is sent again: a dropped connection or a temporary DNS failure
+func isTransientNetworkError(err error) bool {
+	switch classifyNetworkError(err) {
+	case netErrReset:
+		return true
+	case netErrDNS:
+		var dnsErr *net.DNSError
+		return errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+	}
+	return false
+}
+
+// networkHint returns advice for fixing a network failure, or ""
+func networkHint(err error) string {
+	switch classifyNetworkError(err) {
+	case netErrDNS:
+		return "the API host could not be resolved; check " + envAPIURL + " and your DNS settings"
+	case netErrRefused:
+		return "nothing is listening at the API address; check " + envAPIURL + " and proxy settings (HTTPS_PROXY)"
+	case netErrReset:
+		return "the connection was closed by the server or a proxy; check proxy settings (HTTPS_PROXY)"
+	case netErrTLS:
+		return "the TLS handshake failed; check proxy settings (HTTPS_PROXY) and that " + envAPIURL + " uses the right scheme"
+	case netErrConnectTimeout:
+		return "connecting to the API timed out; check " + envAPIURL + " and proxy settings (HTTPS_PROXY), or increase " + envConnectTimeout
+	case netErrTimeout:
+		return "the API did not finish responding in time; increase " + envTimeout + " for long responses, or check your network"
+	}
+	return ""
+}
+
+// connectTimeout returns the time allowed to connect to the API
+func connectTimeout(config *Config) time.Duration {
+	if config.ConnectTimeout <= 0 {
+		return defaultConnectTimeout
+	}
+	return config.ConnectTimeout
+}
+
+// ResponseTooLargeError is returned while reading a response body past
+// OPENAI_MAX_RESPONSE_BYTES, instead of reading it all into memory
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response is larger than %s; raise %s if it is expected", formatSize(e.Limit), envMaxResponseBytes)
+}
+
+// responseTransport asks for gzip-compressed responses and decompresses
+// them, and stops reading a body past maxBytes once decompressed. Setting
+// Accept-Encoding turns off the decompression net/http does by itself, so
+// both happen here, and compressed bombs are caught too.
+type responseTransport struct {
+	base     http.RoundTripper
+	maxBytes int64
+}
+
+func (t *responseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		resp.Body = &gzipBody{body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+	if t.maxBytes > 0 {
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.maxBytes, limit: t.maxBytes}
+	}
+	return resp, nil
+}
+
+// gzipBody decompresses a response body, reading the gzip header on the
+// first read so that an empty body only fails if it is read
+type gzipBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		b.zr, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, fmt.Errorf("failed to decompress response: %w", b.err)
+	}
+	return b.zr.Read(p)
+}
+
+func (b *gzipBody) Close() error {
+	return b.body.Close()
+}
+
+// limitedBody reads a response body up to a limit, like io.LimitReader, but
+// fails with ResponseTooLargeError rather than ending early when the body
+// goes on past it
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// One more byte tells a body of exactly the limit from a longer one
+		n, err := io.LimitReader(b.ReadCloser, 1).Read(make([]byte, 1))
+		if n > 0 {
+			return 0, &ResponseTooLargeError{Limit: b.limit}
+		}
+		return 0, err
+	}
+	n, err := io.LimitReader(b.ReadCloser, b.remaining).Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// Transports by connect timeout, shared so that connections are reused
+var (
+	transportsMu sync.Mutex
+	transports   = map[time.Duration]*http.Transport{}
+)
+
+// transportFor returns the default transport with dialing and the TLS
+// handshake limited to the connect timeout. The overall timeout is the
+// client's.
+func transportFor(config *Config) *http.Transport {
+	timeout := connectTimeout(config)
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if transport, ok := transports[timeout]; ok {
+		return transport
+	}
+
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transports[timeout] = transport
+	return transport
+}
+
+// sendRequest sends the request built by newRequest, sending it once more
+// after a short pause if it fails with a transient network error. Failures
+// carry a hint on how to fix them. Each attempt is traced as a span of its
+// own.
+func sendRequest(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("failed to send request: %w", ctx.Err())
+			case <-time.After(retryDelay):
+			}
+		}
+
+		var req *http.Request
+		req, err = newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		var resp *http.Response
+		resp, err = tracedDo(ctx, client, req, attempt)
+		if err == nil {
+			return resp, nil
+		}
+		if !isTransientNetworkError(err) {
+			break
+		}
+	}
+
+	if isCancelled(err) {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if hint := networkHint(err); hint != "" {
+		return nil, withKind(ErrNetwork, fmt.Errorf("failed to send request: %w\nHint: %s", err, hint))
+	}
+	return nil, withKind(ErrNetwork, fmt.Errorf("failed to send request: %w", err))
+}
+
+// tracedDo sends req, tracing it as an HTTP client span. Attempts after the
+// first record how many times the request was sent before.
+func tracedDo(ctx context.Context, client *http.Client, req *http.Request, attempt int) (*http.Response, error) {
+	_, span := startSpan(ctx, req.Method, spanKindClient)
+	span.SetString("http.request.method", req.Method)
+	span.SetString("server.address", req.URL.Hostname())
+	if attempt > 0 {
+		span.SetInt("http.request.resend_count", attempt)
+	}
+	resp, err := client.Do(req)
+	if resp != nil {
+		span.SetInt("http.response.status_code", resp.StatusCode)
+	}
+	span.End(err)
+	return resp, err
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// timeoutError is a net.Error reporting a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// TestClassifyNetworkError tests recognition of network failures
+func TestClassifyNetworkError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		want      string
+		transient bool
+		hint      string
+	}{
+		{
+			name: "unknown host",
+			err:  &url.Error{Op: "Post", URL: "https://api.invalid", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "api.invalid", IsNotFound: true}}},
+			want: netErrDNS,
+			hint: envAPIURL,
+		},
+		{
+			name:      "temporary DNS failure",
+			err:       &net.DNSError{Err: "server misbehaving", Name: "api.openai.com", IsTemporary: true},
+			want:      netErrDNS,
+			transient: true,
+			hint:      envAPIURL,
+		},
+		{
+			name: "connection refused",
+			err:  &url.Error{Op: "Post", URL: "http://localhost:1", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}},
+			want: netErrRefused,
+			hint: "proxy settings",
+		},
+		{
+			name:      "connection reset",
+			err:       fmt.Errorf("read: %w", syscall.ECONNRESET),
+			want:      netErrReset,
+			transient: true,
+		},
+		{
+			name: "timeout",
+			err:  &url.Error{Op: "Post", URL: "https://api.openai.com", Err: timeoutError{}},
+			want: netErrTimeout,
+			hint: envTimeout,
+		},
+		{
+			name: "connect timeout",
+			err:  &url.Error{Op: "Post", URL: "https://api.openai.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}},
+			want: netErrConnectTimeout,
+			hint: envConnectTimeout,
+		},
+		{
+			name: "TLS handshake timeout",
+			err:  &url.Error{Op: "Post", URL: "https://api.openai.com", Err: errors.New("net/http: TLS handshake timeout")},
+			want: netErrConnectTimeout,
+			hint: envConnectTimeout,
+		},
+		{
+			name: "tls",
+			err:  errors.New("remote error: tls: handshake failure"),
+			want: netErrTLS,
+			hint: "proxy settings",
+		},
+		{
+			name: "cancelled",
+			err:  &url.Error{Op: "Post", URL: "https://api.openai.com", Err: context.Canceled},
+			want: "",
+		},
+		{
+			name: "other",
+			err:  errors.New("boom"),
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyNetworkError(tt.err); got != tt.want {
+				t.Errorf("classifyNetworkError() = %q, want %q", got, tt.want)
+			}
+			if got := isTransientNetworkError(tt.err); got != tt.transient {
+				t.Errorf("isTransientNetworkError() = %v, want %v", got, tt.transient)
+			}
+			if hint := networkHint(tt.err); !strings.Contains(hint, tt.hint) || (tt.want == "") != (hint == "") {
+				t.Errorf("networkHint() = %q, want it to mention %q", hint, tt.hint)
+			}
+		})
+	}
+}
+
+// TestSendRequestRetriesDroppedConnection tests that a request is sent again
+// after the server drops the connection once
+func TestSendRequestRetriesDroppedConnection(t *testing.T) {
+	originalDelay := retryDelay
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = originalDelay }()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("failed to hijack connection: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := sendRequest(context.Background(), server.Client(), func() (*http.Request, error) {
+		return http.NewRequest("POST", server.URL, strings.NewReader("{}"))
+	})
+	if err != nil {
+		t.Fatalf("sendRequest() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 2 {
+		t.Errorf("server received %d requests, want 2", calls)
+	}
+}
+
+// TestSendRequestRefused tests that a refused connection is not retried and
+// carries a hint
+func TestSendRequestRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	attempts := 0
+	_, err = sendRequest(context.Background(), &http.Client{}, func() (*http.Request, error) {
+		attempts++
+		return http.NewRequest("POST", "http://"+address, strings.NewReader("{}"))
+	})
+	if err == nil {
+		t.Fatal("sendRequest() error = nil, want connection refused")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+	if !strings.Contains(err.Error(), "Hint: ") {
+		t.Errorf("error = %q, want a hint", err)
+	}
+}
+
+// TestSendRequestTimeoutPhases tests that a server which never completes the
+// TLS handshake fails on the connect timeout, and one that never answers on
+// the overall timeout, each naming its setting
+func TestSendRequestTimeoutPhases(t *testing.T) {
+	// Accepts connections but never says a word, so the handshake hangs
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	tests := []struct {
+		name           string
+		url            string
+		timeout        time.Duration
+		connectTimeout time.Duration
+		want           string
+		hint           string
+	}{
+		{"connect", "https://" + listener.Addr().String(), 5 * time.Second, 100 * time.Millisecond, netErrConnectTimeout, envConnectTimeout},
+		{"overall", slow.URL, 100 * time.Millisecond, 5 * time.Second, netErrTimeout, envTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newHTTPClient(&Config{Timeout: tt.timeout, ConnectTimeout: tt.connectTimeout})
+
+			start := time.Now()
+			_, err := sendRequest(context.Background(), client, func() (*http.Request, error) {
+				return http.NewRequest("POST", tt.url, strings.NewReader("{}"))
+			})
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("request took %v, want it to time out quickly", elapsed)
+			}
+			if got := classifyNetworkError(err); got != tt.want {
+				t.Errorf("classifyNetworkError(%v) = %q, want %q", err, got, tt.want)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.hint) {
+				t.Errorf("error = %v, want a hint naming %s", err, tt.hint)
+			}
+		})
+	}
+}
+
+// TestResponseGzip tests that gzip is asked for and compressed replies are
+// decoded, streamed ones included
+func TestResponseGzip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		var body ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		if body.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(zw, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(zw, `{"choices":[{"message":{"role":"assistant","content":"`+strings.Repeat("a", 5000)+`"}}]}`)
+	}))
+	defer server.Close()
+
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, MaxResponseBytes: defaultMaxResponseBytes}
+	client := newAPIClient(config)
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	response, err := client.Chat(context.Background(), messages)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if got := formatResponse(response); got != strings.Repeat("a", 5000) {
+		t.Errorf("response = %.20q..., want the decompressed content", got)
+	}
+
+	var out strings.Builder
+	if _, err := client.ChatStream(context.Background(), messages, &out); err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if out.String() != "Hello\n" {
+		t.Errorf("streamed = %q, want Hello", out.String())
+	}
+}
+
+// TestResponseTooLarge tests that a body past OPENAI_MAX_RESPONSE_BYTES,
+// plain or compressed, fails with a distinct error instead of being read
+func TestResponseTooLarge(t *testing.T) {
+	reply := `{"choices":[{"message":{"role":"assistant","content":"` + strings.Repeat("a", 1000) + `"}}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("gzip") != "" {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			defer zw.Close()
+			fmt.Fprint(zw, reply)
+			return
+		}
+		fmt.Fprint(w, reply)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		maxBytes int64
+		wantErr  bool
+	}{
+		{"plain", server.URL, 500, true},
+		// Compressed, the reply is smaller than the limit
+		{"gzip", server.URL + "?gzip=1", 500, true},
+		{"exactly the limit", server.URL, int64(len(reply)), false},
+		{"no limit", server.URL, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{APIKey: "test-key", APIURL: tt.url, Model: "gpt-4o", Timeout: 10 * time.Second, MaxResponseBytes: tt.maxBytes}
+			_, err := newAPIClient(config).Chat(context.Background(), []Message{{Role: "user", Content: "hi"}})
+
+			var tooLarge *ResponseTooLargeError
+			if tt.wantErr {
+				if !errors.As(err, &tooLarge) || tooLarge.Limit != tt.maxBytes || !strings.Contains(err.Error(), envMaxResponseBytes) {
+					t.Errorf("Chat() error = %v, want a ResponseTooLargeError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Chat() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Characters of the reply shown in a notification
+const notifyReplyChars = 80
+
+// notifier shows desktop notifications
+type notifier interface {
+	Notify(title, message string) error
+}
+
+// Returns the notifier of the current system. Replaced in tests.
+var newNotifier = func() notifier {
+	return systemNotifier(runtime.GOOS)
+}
+
+// systemNotifier returns a notifier using the notification tool of the given
+// OS, or one that does nothing when the tool is missing, such as on a server
+// without notify-send
+func systemNotifier(goos string) notifier {
+	tool := notifyCommand(goos, "", "").Args[0]
+	if _, err := exec.LookPath(tool); err != nil {
+		return noopNotifier{}
+	}
+	return commandNotifier{goos: goos}
+}
+
+// noopNotifier is used where no notification tool is available
+type noopNotifier struct{}
+
+func (noopNotifier) Notify(title, message string) error {
+	return nil
+}
+
+// commandNotifier runs the notification tool of an OS
+type commandNotifier struct {
+	goos string
+}
+
+func (n commandNotifier) Notify(title, message string) error {
+	cmd := notifyCommand(n.goos, title, message)
+	tool := cmd.Args[0]
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", tool, msg)
+		}
+		return fmt.Errorf("%s: %w", tool, err)
+	}
+	return nil
+}
+
+// Environment variables the PowerShell toast reads its texts from
+const (
+	envNotifyTitle = "CHATGPT_NOTIFY_TITLE"
+	envNotifyBody  = "CHATGPT_NOTIFY_BODY"
+)
+
+// notifyCommand returns the command showing a notification on the given OS:
+// osascript on macOS, a PowerShell toast on Windows and notify-send on Linux
+// and other Unix systems. The texts are quoted for AppleScript on macOS;
+// PowerShell quoting has too many quote characters to trust with a reply, so
+// the toast reads them from its environment instead, and notify-send takes
+// them as plain arguments.
+func notifyCommand(goos, title, message string) *exec.Cmd {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptQuote(message), appleScriptQuote(title))
+		return exec.Command("osascript", "-e", script)
+	case "windows":
+		script := strings.Join([]string{
+			"[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null",
+			"$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)",
+			"$text = $template.GetElementsByTagName('text')",
+			"$text.Item(0).AppendChild($template.CreateTextNode($env:" + envNotifyTitle + ")) | Out-Null",
+			"$text.Item(1).AppendChild($template.CreateTextNode($env:" + envNotifyBody + ")) | Out-Null",
+			// Toasts need the ID of an installed app; PowerShell's is always there
+			"$app = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\\WindowsPowerShell\\v1.0\\powershell.exe'",
+			"[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($template))",
+		}, "; ")
+		cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", script)
+		cmd.Env = append(os.Environ(), envNotifyTitle+"="+title, envNotifyBody+"="+message)
+		return cmd
+	}
+	// -- keeps a reply starting with a dash from being read as an option
+	return exec.Command("notify-send", "--app-name=chatgpt-cli", "--", title, message)
+}
+
+// appleScriptQuote returns s as an AppleScript string literal
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// notifyReply shows a notification with the start of reply when the request
+// took at least CHATGPT_CLI_NOTIFY_AFTER. A notification that fails is only
+// warned about, since the reply was already printed.
+func notifyReply(config *Config, reply string, elapsed time.Duration) {
+	if elapsed < config.NotifyAfter {
+		return
+	}
+
+	title := fmt.Sprintf("chatgpt-cli answered in %s", elapsed.Round(100*time.Millisecond))
+	if err := newNotifier().Notify(title, notifySnippet(reply)); err != nil {
+		errOut := newUI(config, os.Stderr)
+		errOut.Printf("%s could not show notification: %v\n", errOut.yellow("Warning:"), err)
+	}
+}
+
+// notifySnippet returns the first notifyReplyChars characters of reply on
+// one line, with an ellipsis when it was cut
+func notifySnippet(reply string) string {
+	snippet := strings.Join(strings.Fields(reply), " ")
+	if utf8.RuneCountInString(snippet) <= notifyReplyChars {
+		return snippet
+	}
+	runes := []rune(snippet)
+	return strings.TrimSpace(string(runes[:notifyReplyChars-1])) + "…"
+}
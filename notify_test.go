@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNotifier records the notifications it is asked to show
+type fakeNotifier struct {
+	titles   []string
+	messages []string
+	err      error
+}
+
+func (n *fakeNotifier) Notify(title, message string) error {
+	n.titles = append(n.titles, title)
+	n.messages = append(n.messages, message)
+	return n.err
+}
+
+// useFakeNotifier replaces the system notifier for the rest of the test
+func useFakeNotifier(t *testing.T, fake *fakeNotifier) {
+	original := newNotifier
+	newNotifier = func() notifier { return fake }
+	t.Cleanup(func() { newNotifier = original })
+}
+
+// TestNotifyCommand tests the command built for each OS and that the texts
+// are quoted for AppleScript
+func TestNotifyCommand(t *testing.T) {
+	tests := []struct {
+		goos string
+		want []string
+	}{
+		{"darwin", []string{"osascript", "-e", `display notification "it's \"done\" \\ ok" with title "Done in 6s"`}},
+		{"linux", []string{"notify-send", "--app-name=chatgpt-cli", "--", "Done in 6s", `it's "done" \ ok`}},
+		{"freebsd", []string{"notify-send", "--app-name=chatgpt-cli", "--", "Done in 6s", `it's "done" \ ok`}},
+	}
+	for _, tt := range tests {
+		if got := notifyCommand(tt.goos, "Done in 6s", `it's "done" \ ok`).Args; strings.Join(got, "\x00") != strings.Join(tt.want, "\x00") {
+			t.Errorf("notifyCommand(%q) = %q, want %q", tt.goos, got, tt.want)
+		}
+	}
+}
+
+// TestNotifyCommandWindows tests that the toast script reads the texts from
+// the environment, so that no quote of a reply, curly ones included, can end
+// a string of the script and run the rest
+func TestNotifyCommandWindows(t *testing.T) {
+	const reply = "don’t stop ‘; Remove-Item -Recurse C:\\ ; ‘"
+	cmd := notifyCommand("windows", "Done in 6s", reply)
+	if len(cmd.Args) != 5 || cmd.Args[0] != "powershell.exe" || cmd.Args[3] != "-Command" {
+		t.Fatalf("notifyCommand(windows) = %q", cmd.Args)
+	}
+	script := cmd.Args[4]
+	for _, want := range []string{"ToastText02", "CreateTextNode($env:CHATGPT_NOTIFY_TITLE)", "CreateTextNode($env:CHATGPT_NOTIFY_BODY)", "CreateToastNotifier($app).Show("} {
+		if !strings.Contains(script, want) {
+			t.Errorf("windows script = %s, want %q", script, want)
+		}
+	}
+	for _, text := range []string{"Done in 6s", "don’t", "Remove-Item"} {
+		if strings.Contains(script, text) {
+			t.Errorf("windows script contains %q: %s", text, script)
+		}
+	}
+
+	want := []string{"CHATGPT_NOTIFY_TITLE=Done in 6s", "CHATGPT_NOTIFY_BODY=" + reply}
+	if len(cmd.Env) < 2 || strings.Join(cmd.Env[len(cmd.Env)-2:], "\x00") != strings.Join(want, "\x00") {
+		t.Errorf("windows environment = %q, want it to end with %q", cmd.Env, want)
+	}
+}
+
+// TestSystemNotifierWithoutTool tests that a missing notification tool turns
+// notifications off instead of failing
+func TestSystemNotifierWithoutTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	n := systemNotifier("linux")
+	if _, ok := n.(noopNotifier); !ok {
+		t.Fatalf("systemNotifier() = %T, want noopNotifier", n)
+	}
+	if err := n.Notify("title", "message"); err != nil {
+		t.Errorf("Notify() error = %v", err)
+	}
+}
+
+// TestNotifySnippet tests shortening a reply to one line of 80 characters
+func TestNotifySnippet(t *testing.T) {
+	if got := notifySnippet("  Short\nreply \t here "); got != "Short reply here" {
+		t.Errorf("notifySnippet() = %q", got)
+	}
+
+	long := strings.Repeat("é", 100)
+	got := notifySnippet(long)
+	if got != strings.Repeat("é", 79)+"…" {
+		t.Errorf("notifySnippet(100 runes) = %q", got)
+	}
+	if got := notifySnippet(strings.Repeat("a", 80)); got != strings.Repeat("a", 80) {
+		t.Errorf("notifySnippet(80 runes) = %q, want it whole", got)
+	}
+}
+
+// TestNotifyReply tests the threshold and that a failed notification is only
+// warned about
+func TestNotifyReply(t *testing.T) {
+	fake := &fakeNotifier{}
+	useFakeNotifier(t, fake)
+	config := &Config{NotifyAfter: 5 * time.Second, NoColor: true}
+
+	notifyReply(config, "fast", 4*time.Second)
+	if len(fake.titles) != 0 {
+		t.Fatalf("notified %q for a request under the threshold", fake.titles)
+	}
+
+	notifyReply(config, "The answer is 42.", 6230*time.Millisecond)
+	if len(fake.titles) != 1 || fake.titles[0] != "chatgpt-cli answered in 6.2s" || fake.messages[0] != "The answer is 42." {
+		t.Errorf("notifications = %q / %q", fake.titles, fake.messages)
+	}
+
+	fake.err = errors.New("notify-send: no session bus")
+	out := captureOutput(t, &os.Stderr, func() {
+		notifyReply(config, "slow", 10*time.Second)
+	})
+	if !strings.Contains(out, "Warning: could not show notification: notify-send: no session bus") {
+		t.Errorf("stderr = %q, want a warning", out)
+	}
+}
+
+// TestPromptCommandNotify tests that prompt --notify and CHATGPT_CLI_NOTIFY
+// notify once the reply is printed
+func TestPromptCommandNotify(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Reply text"}}]}`)
+	}))
+	defer server.Close()
+
+	setTestEnv(envConfigDir, t.TempDir())
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	setTestEnv(envNotifyAfter, "0s")
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	fake := &fakeNotifier{}
+	useFakeNotifier(t, fake)
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "hello"}); err != nil {
+			t.Errorf("promptCommand() error = %v", err)
+		}
+		if err := promptCommand(config, []string{"--no-stream", "--notify", "hello"}); err != nil {
+			t.Errorf("promptCommand(--notify) error = %v", err)
+		}
+	})
+	if len(fake.messages) != 1 || fake.messages[0] != "Reply text" {
+		t.Errorf("notifications = %q, want one for --notify only", fake.messages)
+	}
+
+	setTestEnv(envNotify, "true")
+	if config, err = loadConfig(""); err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "hello"}); err != nil {
+			t.Errorf("promptCommand() error = %v", err)
+		}
+		if err := promptCommand(config, []string{"--no-stream", "--notify=false", "hello"}); err != nil {
+			t.Errorf("promptCommand(--notify=false) error = %v", err)
+		}
+	})
+	if len(fake.messages) != 2 {
+		t.Errorf("notifications = %q, want one more with CHATGPT_CLI_NOTIFY", fake.messages)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Ollama API defaults
+const (
+	defaultOllamaAPIURL = "http://localhost:11434/api/chat"
+	defaultOllamaModel  = "llama3.2"
+)
+
+// Hint given when nothing listens at the Ollama address
+const ollamaStartHint = "Ollama does not seem to be running; start it with: ollama serve (or set " + envAPIURL + " to its address)"
+
+// OllamaRequest is the body of an /api/chat request. Sampling parameters go
+// in the options block.
+type OllamaRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Format   string         `json:"format,omitempty"`
+	Options  *OllamaOptions `json:"options,omitempty"`
+}
+
+type OllamaOptions struct {
+	Temperature      float64  `json:"temperature,omitempty"`
+	NumPredict       int      `json:"num_predict,omitempty"`
+	TopP             float64  `json:"top_p,omitempty"`
+	PresencePenalty  float64  `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64  `json:"frequency_penalty,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+}
+
+// OllamaResponse is an /api/chat reply, or one line of a streamed reply. The
+// token counts come with the final, done one.
+type OllamaResponse struct {
+	Model           string  `json:"model"`
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// OllamaModel is a locally installed model listed by /api/tags
+type OllamaModel struct {
+	Name       string `json:"name"`
+	ModifiedAt string `json:"modified_at"`
+	Size       int64  `json:"size"`
+}
+
+// ollamaProvider speaks the Ollama chat API of a local model server
+type ollamaProvider struct{}
+
+func (ollamaProvider) NewRequest(ctx context.Context, config *Config, messages []Message, stream bool) (*http.Request, error) {
+	// Ollama streams unless told otherwise, so stream is always sent
+	requestBody := OllamaRequest{
+		Model:    config.Model,
+		Messages: messages,
+		Stream:   stream,
+		Options: &OllamaOptions{
+			Temperature:      config.Temperature,
+			NumPredict:       config.MaxTokens,
+			TopP:             config.TopP,
+			PresencePenalty:  config.PresencePenalty,
+			FrequencyPenalty: config.FrequencyPenalty,
+			Stop:             config.Stop,
+			Seed:             config.Seed,
+		},
+	}
+	if config.JSONResponse {
+		requestBody.Format = "json"
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.APIURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	setAuthHeader(req, config)
+
+	return req, nil
+}
+
+func (ollamaProvider) ReadResponse(resp *http.Response) (*ChatResponse, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var reply OllamaResponse
+	parseErr := json.Unmarshal(body, &reply)
+
+	if resp.StatusCode != http.StatusOK {
+		if parseErr == nil && reply.Error != "" {
+			return nil, newOllamaError(resp.StatusCode, reply.Error)
+		}
+		return nil, newStatusError(resp.StatusCode, body)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", parseErr)
+	}
+	if reply.Error != "" {
+		return nil, newOllamaError(resp.StatusCode, reply.Error)
+	}
+
+	response := ollamaChatResponse(&reply)
+	response.Choices = []Choice{{
+		Message:      Message{Role: "assistant", Content: reply.Message.Content},
+		FinishReason: ollamaFinishReason(reply.DoneReason),
+	}}
+	return response, nil
+}
+
+// ReadStream parses a streamed reply, one JSON object per line, until the
+// done one or end of input
+func (ollamaProvider) ReadStream(r io.Reader, w io.Writer) (*ChatResponse, error) {
+	response := &ChatResponse{Object: "chat.completion"}
+	choice := Choice{Message: Message{Role: "assistant"}}
+
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk OllamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, newOllamaError(0, chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			fmt.Fprint(w, chunk.Message.Content)
+		}
+
+		if chunk.Done {
+			response = ollamaChatResponse(&chunk)
+			choice.FinishReason = ollamaFinishReason(chunk.DoneReason)
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	// Terminate the streamed output with a newline
+	fmt.Fprintln(w)
+
+	choice.Message.Content = content.String()
+	response.Choices = []Choice{choice}
+
+	return response, nil
+}
+
+// ollamaChatResponse copies the fields shared with chat completions
+func ollamaChatResponse(reply *OllamaResponse) *ChatResponse {
+	return &ChatResponse{
+		Object: "chat.completion",
+		Model:  reply.Model,
+		Usage: Usage{
+			PromptTokens:     reply.PromptEvalCount,
+			CompletionTokens: reply.EvalCount,
+			TotalTokens:      reply.PromptEvalCount + reply.EvalCount,
+		},
+	}
+}
+
+// ollamaFinishReason maps a done_reason to the finish_reason values of chat
+// completions
+func ollamaFinishReason(doneReason string) string {
+	if doneReason == "" {
+		return "stop"
+	}
+	return doneReason
+}
+
+// newOllamaError returns the error for an Ollama error message, which is a
+// plain string rather than an error object
+func newOllamaError(statusCode int, message string) error {
+	err := fmt.Errorf("API error: %s", message)
+	if statusCode == http.StatusNotFound && strings.Contains(message, "not found") {
+		err = fmt.Errorf("%w\nHint: download the model with: ollama pull <model>", err)
+	}
+	if kind := apiErrorKind(statusCode, nil); kind != nil {
+		return withKind(kind, err)
+	}
+	return err
+}
+
+// ollamaNotRunning replaces the error for a refused connection to Ollama with
+// one telling how to start it
+func ollamaNotRunning(config *Config, err error) error {
+	if config.Provider != providerOllama || classifyNetworkError(err) != netErrRefused {
+		return err
+	}
+
+	address := config.APIURL
+	if u, parseErr := url.Parse(config.APIURL); parseErr == nil && u.Host != "" {
+		address = u.Host
+	}
+	return withKind(ErrNetwork, fmt.Errorf("failed to send request: nothing is listening at %s\nHint: %s", address, ollamaStartHint))
+}
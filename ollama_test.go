@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newOllamaTestConfig returns a config sending to an Ollama test server
+func newOllamaTestConfig(serverURL string) *Config {
+	return &Config{
+		APIURL:      serverURL + "/api/chat",
+		Model:       "llama3.2",
+		Timeout:     10 * time.Second,
+		MaxTokens:   200,
+		Temperature: 0.2,
+		Stop:        []string{"END"},
+		Provider:    providerOllama,
+	}
+}
+
+// TestOllamaRequest tests the translation of a prompt to an /api/chat request
+func TestOllamaRequest(t *testing.T) {
+	var request OllamaRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %q, want /api/chat", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization header = %q, want none without a key", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		_, _ = fmt.Fprint(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Hello"},
+			"done":true,"done_reason":"length","prompt_eval_count":7,"eval_count":3}`)
+	}))
+	defer server.Close()
+
+	config := newOllamaTestConfig(server.URL)
+	response, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt"))
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if request.Model != "llama3.2" || request.Stream {
+		t.Errorf("request = %+v, want the model and stream false", request)
+	}
+	if len(request.Messages) != 1 || request.Messages[0].Role != "user" || request.Messages[0].Content != "test prompt" {
+		t.Errorf("request messages = %+v, want the prompt as a user message", request.Messages)
+	}
+	if request.Options == nil || request.Options.Temperature != 0.2 || request.Options.NumPredict != 200 || strings.Join(request.Options.Stop, ",") != "END" {
+		t.Errorf("request options = %+v, want temperature, num_predict and stop", request.Options)
+	}
+
+	if formatResponse(response) != "Hello" || response.Choices[0].FinishReason != "length" {
+		t.Errorf("response = %+v, want the reply and finish reason length", response)
+	}
+	if response.Usage != (Usage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}) {
+		t.Errorf("response usage = %+v, want the eval counts mapped", response.Usage)
+	}
+}
+
+// TestOllamaErrors tests error replies, including a model that isn't pulled
+func TestOllamaErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		kind     error
+		contains string
+	}{
+		{"model not pulled", http.StatusNotFound, `{"error":"model \"llama9\" not found, try pulling it first"}`, nil, "ollama pull"},
+		{"server error", http.StatusInternalServerError, `{"error":"out of memory"}`, ErrServer, "out of memory"},
+		{"plain text", http.StatusBadGateway, `bad gateway`, ErrServer, "502"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			config := newOllamaTestConfig(server.URL)
+			_, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt"))
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Fatalf("Chat() error = %v, want it to contain %q", err, tt.contains)
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Errorf("Chat() error = %v, want %v", err, tt.kind)
+			}
+		})
+	}
+}
+
+// TestOllamaStream tests parsing of a newline-delimited JSON reply
+func TestOllamaStream(t *testing.T) {
+	lines := []string{
+		`{"model":"llama3.2","message":{"role":"assistant","content":"Hello"},"done":false}`,
+		`{"model":"llama3.2","message":{"role":"assistant","content":", world"},"done":false}`,
+		`{"model":"llama3.2","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":5,"eval_count":4}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OllamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Stream {
+			t.Errorf("request stream = %t, %v, want a streaming request", request.Stream, err)
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	config := newOllamaTestConfig(server.URL)
+	response, err := newAPIClient(config).ChatStream(context.Background(), promptMessages(config, "test prompt"), &out)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	if out.String() != "Hello, world\n" {
+		t.Errorf("streamed output = %q, want %q", out.String(), "Hello, world\n")
+	}
+	if formatResponse(response) != "Hello, world" || response.Choices[0].FinishReason != "stop" {
+		t.Errorf("response = %+v, want the assembled text and finish reason stop", response)
+	}
+	if response.Model != "llama3.2" || response.Usage.TotalTokens != 9 {
+		t.Errorf("response model %q and usage %+v, want them from the done line", response.Model, response.Usage)
+	}
+
+	// An error line ends the stream with an error
+	var stream bytes.Buffer
+	_, err = ollamaProvider{}.ReadStream(strings.NewReader(lines[0]+"\n"+`{"error":"model unloaded"}`+"\n"), &stream)
+	if err == nil || !strings.Contains(err.Error(), "model unloaded") {
+		t.Errorf("ReadStream() error = %v, want the streamed error", err)
+	}
+}
+
+// TestOllamaModels tests that models are listed from /api/tags
+func TestOllamaModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("path = %q, want /api/tags", r.URL.Path)
+		}
+		_, _ = fmt.Fprint(w, `{"models":[{"name":"mistral:latest","size":1},{"name":"llama3.2:latest","size":2}]}`)
+	}))
+	defer server.Close()
+
+	models, err := fetchModels(context.Background(), newOllamaTestConfig(server.URL))
+	if err != nil {
+		t.Fatalf("fetchModels() error = %v", err)
+	}
+	ids := filterModelIDs(models, "")
+	if strings.Join(ids, ",") != "llama3.2:latest,mistral:latest" {
+		t.Errorf("model IDs = %v, want the installed models", ids)
+	}
+}
+
+// TestOllamaNotRunning tests the hint given when nothing listens at the
+// Ollama address
+func TestOllamaNotRunning(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	config := newOllamaTestConfig("http://" + address)
+	_, err = newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt"))
+	if !errors.Is(err, ErrNetwork) || !strings.Contains(err.Error(), "ollama serve") {
+		t.Errorf("Chat() error = %v, want a network error with a hint to start ollama", err)
+	}
+	if _, err := fetchModels(context.Background(), config); err == nil || !strings.Contains(err.Error(), "ollama serve") {
+		t.Errorf("fetchModels() error = %v, want a hint to start ollama", err)
+	}
+
+	// Other providers keep the generic hint
+	config.Provider = providerOpenAI
+	if _, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt")); err == nil || strings.Contains(err.Error(), "ollama") {
+		t.Errorf("Chat(openai) error = %v, want the generic hint", err)
+	}
+}
+
+// TestPromptCommandOllama tests that prompting works without an API key
+func TestPromptCommandOllama(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"model":"llama3.2","message":{"role":"assistant","content":"Ciao"},"done":true}`)
+	}))
+	defer server.Close()
+
+	setTestEnv(envConfigDir, t.TempDir())
+	setTestEnv(envProvider, providerOllama)
+
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if config.APIURL != defaultOllamaAPIURL || config.Model != defaultOllamaModel {
+		t.Errorf("loadConfig() APIURL %q, model %q, want the Ollama defaults", config.APIURL, config.Model)
+	}
+	config.APIURL = server.URL + "/api/chat"
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "Say hello in Italian"}); err != nil {
+			t.Errorf("promptCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Ciao") {
+		t.Errorf("prompt output = %q, want the reply", out)
+	}
+}
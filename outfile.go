@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Written between responses appended to the same --out file
+const defaultOutDelimiter = `\n---\n\n`
+
+// responseOutput is where the prompt command writes the response: stdout,
+// or the file given with --out. The file is only opened once the response
+// starts arriving, so a failed request leaves no empty file behind.
+type responseOutput struct {
+	path      string
+	append    bool
+	delimiter string
+
+	file    *os.File
+	written int64
+}
+
+// newResponseOutput selects where the response goes. An empty path or "-"
+// means stdout. An existing file is only written with appendMode, which adds
+// the delimiter before the response, or force, which replaces it.
+func newResponseOutput(path string, appendMode, force bool, delimiter string) (*responseOutput, error) {
+	if path == "" || path == "-" {
+		return &responseOutput{}, nil
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return nil, usageErrorf("--out %s is a directory", path)
+	case err == nil && !appendMode && !force:
+		return nil, usageErrorf("%s already exists; use --append to add to it or --force to overwrite it", path)
+	case err != nil && !os.IsNotExist(err):
+		return nil, fmt.Errorf("failed to check --out file: %w", err)
+	}
+
+	return &responseOutput{
+		path:      path,
+		append:    appendMode,
+		delimiter: unescapeDelimiter(delimiter),
+	}, nil
+}
+
+// isTerminal reports whether the response is shown in a terminal, where it
+// may be rendered
+func (o *responseOutput) isTerminal() bool {
+	return o.path == "" && isTerminal(os.Stdout)
+}
+
+func (o *responseOutput) Write(p []byte) (int, error) {
+	if o.path == "" {
+		return os.Stdout.Write(p)
+	}
+
+	if o.file == nil {
+		if err := o.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := o.file.Write(p)
+	o.written += int64(n)
+	return n, err
+}
+
+// open creates or truncates the file, or opens it for appending and writes
+// the delimiter if it already has content
+func (o *responseOutput) open() error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if o.append {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(o.path, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open --out file: %w", err)
+	}
+	o.file = f
+
+	if o.append {
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to open --out file: %w", err)
+		}
+		if info.Size() > 0 {
+			if _, err := io.WriteString(f, o.delimiter); err != nil {
+				return fmt.Errorf("failed to write --out file: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the file and confirms on stderr how much was written to it.
+// It does nothing for stdout and may be called more than once.
+func (o *responseOutput) Close() error {
+	if o.file == nil {
+		return nil
+	}
+
+	err := o.file.Close()
+	o.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to write --out file: %w", err)
+	}
+
+	verb := "Wrote"
+	if o.append {
+		verb = "Appended"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d bytes to %s\n", verb, o.written, o.path)
+	return nil
+}
+
+// unescapeDelimiter turns the \n and \t escapes typed on the command line
+// into line breaks and tabs
+func unescapeDelimiter(delimiter string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(delimiter)
+}
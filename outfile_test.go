@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestResponseOutput tests writing, appending and overwriting --out files
+func TestResponseOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "answer.md")
+
+	write := func(appendMode, force bool, delimiter, content string) error {
+		output, err := newResponseOutput(path, appendMode, force, delimiter)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(output, content); err != nil {
+			return err
+		}
+		return output.Close()
+	}
+
+	stderr := captureOutput(t, &os.Stderr, func() {
+		if err := write(false, false, defaultOutDelimiter, "first"); err != nil {
+			t.Errorf("write new file error = %v", err)
+		}
+	})
+	if stderr != "Wrote 6 bytes to "+path+"\n" {
+		t.Errorf("confirmation = %q", stderr)
+	}
+
+	// An existing file is left alone without --append or --force
+	if err := write(false, false, defaultOutDelimiter, "second"); !errors.Is(err, ErrUsage) {
+		t.Errorf("write existing file error = %v, want a usage error", err)
+	}
+
+	stderr = captureOutput(t, &os.Stderr, func() {
+		if err := write(true, false, defaultOutDelimiter, "second"); err != nil {
+			t.Errorf("append error = %v", err)
+		}
+		if err := write(true, false, `\n===\n`, "third"); err != nil {
+			t.Errorf("append with delimiter error = %v", err)
+		}
+	})
+	if !strings.Contains(stderr, "Appended 7 bytes to "+path) {
+		t.Errorf("append confirmation = %q", stderr)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "first\n\n---\n\nsecond\n\n===\nthird\n" {
+		t.Errorf("appended file = %q", data)
+	}
+
+	captureOutput(t, &os.Stderr, func() {
+		if err := write(false, true, defaultOutDelimiter, "replaced"); err != nil {
+			t.Errorf("force error = %v", err)
+		}
+	})
+	if data, _ := os.ReadFile(path); string(data) != "replaced\n" {
+		t.Errorf("overwritten file = %q", data)
+	}
+
+	// Appending to a new file writes no delimiter
+	newPath := filepath.Join(tmpDir, "new.md")
+	output, err := newResponseOutput(newPath, true, false, defaultOutDelimiter)
+	if err != nil {
+		t.Fatalf("newResponseOutput() error = %v", err)
+	}
+	fmt.Fprint(output, "only")
+	captureOutput(t, &os.Stderr, func() { output.Close() })
+	if data, _ := os.ReadFile(newPath); string(data) != "only" {
+		t.Errorf("new appended file = %q", data)
+	}
+
+	// Nothing is created until something is written
+	unused := filepath.Join(tmpDir, "unused.md")
+	output, err = newResponseOutput(unused, false, false, defaultOutDelimiter)
+	if err != nil {
+		t.Fatalf("newResponseOutput() error = %v", err)
+	}
+	if err := output.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(unused); !os.IsNotExist(err) {
+		t.Errorf("unused output file should not exist")
+	}
+
+	if _, err := newResponseOutput(tmpDir, false, true, defaultOutDelimiter); !errors.Is(err, ErrUsage) {
+		t.Errorf("newResponseOutput(directory) error = %v, want a usage error", err)
+	}
+	for _, p := range []string{"", "-"} {
+		if output, err := newResponseOutput(p, false, false, defaultOutDelimiter); err != nil || output.path != "" {
+			t.Errorf("newResponseOutput(%q) = %+v, %v, want stdout", p, output, err)
+		}
+	}
+}
+
+// TestPromptCommandOut tests that --out writes the response to a file and
+// still logs it
+func TestPromptCommandOut(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Saved reply"}}]}`)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	setTestEnv(envConfigDir, tmpDir)
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	path := filepath.Join(tmpDir, "reply.txt")
+	var stdout string
+	stderr := captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, func() {
+			if err := promptCommand(config, []string{"--out", path, "hello"}); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+	if data, _ := os.ReadFile(path); string(data) != "Saved reply\n" {
+		t.Errorf("--out file = %q", data)
+	}
+	if stdout != "" || !strings.Contains(stderr, "Wrote 12 bytes to "+path) {
+		t.Errorf("stdout = %q, stderr = %q, want only the confirmation on stderr", stdout, stderr)
+	}
+
+	entries, _, err := readLogEntries([]string{filepath.Join(tmpDir, logFileName)}, logFilter{}, 0)
+	if err != nil || len(entries) != 1 || entries[0].Response != "Saved reply" {
+		t.Errorf("logged entries = %+v, %v, want the reply logged", entries, err)
+	}
+
+	// The existing file is refused before anything is sent
+	if err := promptCommand(config, []string{"--out", path, "hello"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("promptCommand() over an existing file error = %v, want a usage error", err)
+	}
+	if err := promptCommand(config, []string{"--append", "hello"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("promptCommand(--append) without --out error = %v, want a usage error", err)
+	}
+
+	stdout = captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--out", "-", "hello"}); err != nil {
+			t.Errorf("promptCommand(--out -) error = %v", err)
+		}
+	})
+	if stdout != "Saved reply\n" {
+		t.Errorf("--out - output = %q, want the reply on stdout", stdout)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Output formats
+const (
+	outputPlain = "plain"
+	outputJSON  = "json"
+)
+
+// PromptOutput is the JSON representation of a prompt response
+type PromptOutput struct {
+	ID           string `json:"id"`
+	Model        string `json:"model"`
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason"`
+	Usage        Usage  `json:"usage"`
+	// Choices holds each reply when several were asked for with --n
+	Choices []string `json:"choices,omitempty"`
+	// SystemFingerprint identifies the backend configuration that answered
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+}
+
+// newPromptOutput builds the JSON output for a response
+func newPromptOutput(response *ChatResponse, content string) PromptOutput {
+	output := PromptOutput{
+		ID:                response.ID,
+		Model:             response.Model,
+		Content:           content,
+		Usage:             response.Usage,
+		SystemFingerprint: response.SystemFingerprint,
+	}
+	if len(response.Choices) > 0 {
+		output.FinishReason = response.Choices[0].FinishReason
+	}
+	if len(response.Choices) > 1 {
+		output.Choices = choiceContents(response)
+	}
+	return output
+}
+
+// parseOutputOrDefault validates an output format, falling back to the default
+func parseOutputOrDefault(value, defaultValue string) string {
+	switch value {
+	case outputPlain, outputJSON:
+		return value
+	default:
+		return defaultValue
+	}
+}
+
+// globalFlags holds the flags accepted before or after any command. Empty
+// fields mean the flag was not given.
+type globalFlags struct {
+	Output  string
+	Profile string
+	// Verbose counts -v flags; -vv and --verbose --verbose count twice
+	Verbose int
+	// NoRedact turns log redaction off for this run
+	NoRedact bool
+	// NoDefaults skips the default flags of the command for this run
+	NoDefaults bool
+}
+
+// parseGlobalFlags extracts the global --output, --profile, --verbose,
+// --no-redact and --no-defaults flags from the command line, wherever they appear before a "--"
+// terminator. It returns the remaining arguments and the flag values.
+func parseGlobalFlags(args []string) ([]string, globalFlags, error) {
+	var rest []string
+	var flags globalFlags
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		switch arg {
+		case "-v", "--verbose", "-verbose":
+			flags.Verbose++
+			continue
+		case "-vv":
+			flags.Verbose += 2
+			continue
+		case "--no-redact", "-no-redact":
+			flags.NoRedact = true
+			continue
+		case "--no-defaults", "-no-defaults":
+			flags.NoDefaults = true
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		flagName := strings.TrimPrefix(strings.TrimPrefix(name, "-"), "-")
+		if flagName == name || (flagName != "output" && flagName != "profile") {
+			rest = append(rest, arg)
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, globalFlags{}, fmt.Errorf("flag needs an argument: %s", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch flagName {
+		case "output":
+			if value != outputPlain && value != outputJSON {
+				return nil, globalFlags{}, fmt.Errorf("invalid output format %q: must be plain or json", value)
+			}
+			flags.Output = value
+		case "profile":
+			if value == "" {
+				return nil, globalFlags{}, fmt.Errorf("profile name cannot be empty")
+			}
+			flags.Profile = value
+		}
+	}
+
+	return rest, flags, nil
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	return writeJSON(os.Stdout, v)
+}
+
+// writeJSON writes v to w as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	return nil
+}
+
+// printJSONError writes err to stderr as a JSON object
+func printJSONError(err error) {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	fmt.Fprintln(os.Stderr, string(data))
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureOutput runs fn and returns what it wrote to the given stream,
+// which must be os.Stdout or os.Stderr
+func captureOutput(t *testing.T, stream **os.File, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+
+	original := *stream
+	*stream = w
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	defer func() {
+		*stream = original
+	}()
+
+	fn()
+	w.Close()
+
+	return <-done
+}
+
+// TestParseGlobalFlags tests extraction of the --output and --profile flags
+func TestParseGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name             string
+		args             []string
+		wantErr          bool
+		expectedArgs     []string
+		expectedOutput   string
+		expectedProfile  string
+		expectedVerbose  int
+		expectedNoRedact bool
+	}{
+		{
+			name:           "no global flags",
+			args:           []string{"chatgpt-cli", "prompt", "hello"},
+			expectedArgs:   []string{"chatgpt-cli", "prompt", "hello"},
+			expectedOutput: "",
+		},
+		{
+			name:           "before command",
+			args:           []string{"chatgpt-cli", "--output", "json", "logs"},
+			expectedArgs:   []string{"chatgpt-cli", "logs"},
+			expectedOutput: "json",
+		},
+		{
+			name:           "after command with equals",
+			args:           []string{"chatgpt-cli", "config", "list", "--output=json"},
+			expectedArgs:   []string{"chatgpt-cli", "config", "list"},
+			expectedOutput: "json",
+		},
+		{
+			name:           "after terminator",
+			args:           []string{"chatgpt-cli", "prompt", "--", "--output", "json"},
+			expectedArgs:   []string{"chatgpt-cli", "prompt", "--", "--output", "json"},
+			expectedOutput: "",
+		},
+		{
+			name:            "profile and output",
+			args:            []string{"chatgpt-cli", "--profile", "work", "config", "set", "--output=json", "OPENAI_MODEL", "gpt-4"},
+			expectedArgs:    []string{"chatgpt-cli", "config", "set", "OPENAI_MODEL", "gpt-4"},
+			expectedOutput:  "json",
+			expectedProfile: "work",
+		},
+		{
+			name:            "single dash profile with equals",
+			args:            []string{"chatgpt-cli", "logs", "-profile=work"},
+			expectedArgs:    []string{"chatgpt-cli", "logs"},
+			expectedProfile: "work",
+		},
+		{
+			name:         "unrelated flag is kept",
+			args:         []string{"chatgpt-cli", "logs", "---profile", "x"},
+			expectedArgs: []string{"chatgpt-cli", "logs", "---profile", "x"},
+		},
+		{
+			name:            "verbose",
+			args:            []string{"chatgpt-cli", "-v", "prompt", "hello"},
+			expectedArgs:    []string{"chatgpt-cli", "prompt", "hello"},
+			expectedVerbose: 1,
+		},
+		{
+			name:            "very verbose",
+			args:            []string{"chatgpt-cli", "models", "-vv"},
+			expectedArgs:    []string{"chatgpt-cli", "models"},
+			expectedVerbose: 2,
+		},
+		{
+			name:            "repeated verbose",
+			args:            []string{"chatgpt-cli", "--verbose", "doctor", "--verbose"},
+			expectedArgs:    []string{"chatgpt-cli", "doctor"},
+			expectedVerbose: 2,
+		},
+		{
+			name:             "no redact",
+			args:             []string{"chatgpt-cli", "prompt", "--no-redact", "hello"},
+			expectedArgs:     []string{"chatgpt-cli", "prompt", "hello"},
+			expectedNoRedact: true,
+		},
+		{
+			name:         "verbose after terminator",
+			args:         []string{"chatgpt-cli", "prompt", "--", "-v"},
+			expectedArgs: []string{"chatgpt-cli", "prompt", "--", "-v"},
+		},
+		{
+			name:    "empty profile",
+			args:    []string{"chatgpt-cli", "--profile=", "logs"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid format",
+			args:    []string{"chatgpt-cli", "--output", "xml", "logs"},
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			args:    []string{"chatgpt-cli", "logs", "--output"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, flags, err := parseGlobalFlags(tt.args)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseGlobalFlags() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGlobalFlags() unexpected error: %v", err)
+			}
+
+			if flags.Output != tt.expectedOutput {
+				t.Errorf("Output = %q, want %q", flags.Output, tt.expectedOutput)
+			}
+			if flags.Profile != tt.expectedProfile {
+				t.Errorf("Profile = %q, want %q", flags.Profile, tt.expectedProfile)
+			}
+			if flags.Verbose != tt.expectedVerbose {
+				t.Errorf("Verbose = %d, want %d", flags.Verbose, tt.expectedVerbose)
+			}
+			if flags.NoRedact != tt.expectedNoRedact {
+				t.Errorf("NoRedact = %t, want %t", flags.NoRedact, tt.expectedNoRedact)
+			}
+			if strings.Join(args, " ") != strings.Join(tt.expectedArgs, " ") {
+				t.Errorf("args = %q, want %q", args, tt.expectedArgs)
+			}
+		})
+	}
+}
+
+// TestParseOutputOrDefault tests output format parsing with defaults
+func TestParseOutputOrDefault(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", outputPlain},
+		{"json", outputJSON},
+		{"plain", outputPlain},
+		{"yaml", outputPlain},
+	}
+
+	for _, tt := range tests {
+		if result := parseOutputOrDefault(tt.input, outputPlain); result != tt.expected {
+			t.Errorf("parseOutputOrDefault(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+// TestPromptCommandJSON tests the JSON output of the prompt command
+func TestPromptCommandJSON(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if request.Stream {
+			t.Errorf("JSON output should not stream")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			ID:    "chatcmpl-123",
+			Model: "gpt-4o",
+			Choices: []Choice{
+				{Message: Message{Content: "  Test response  "}, FinishReason: "stop"},
+			},
+			Usage: Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3},
+		})
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:    "test-key",
+		APIURL:    server.URL,
+		Model:     "gpt-4o",
+		Timeout:   10 * time.Second,
+		Stream:    true,
+		Output:    outputJSON,
+		ConfigDir: t.TempDir(),
+	}
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = promptCommand(config, []string{"test prompt"})
+	})
+	if err != nil {
+		t.Fatalf("promptCommand() error = %v", err)
+	}
+
+	var output PromptOutput
+	if err := json.Unmarshal([]byte(out), &output); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+
+	if output.ID != "chatcmpl-123" {
+		t.Errorf("ID = %q, want %q", output.ID, "chatcmpl-123")
+	}
+	if output.Content != "Test response" {
+		t.Errorf("Content = %q, want %q", output.Content, "Test response")
+	}
+	if output.FinishReason != "stop" {
+		t.Errorf("FinishReason = %q, want %q", output.FinishReason, "stop")
+	}
+	if output.Usage.TotalTokens != 3 {
+		t.Errorf("Usage.TotalTokens = %d, want 3", output.Usage.TotalTokens)
+	}
+}
+
+// TestConfigListCommandJSON tests the JSON output of config list
+func TestConfigListCommandJSON(t *testing.T) {
+	config := &Config{
+		APIKey:    "sk-1234567890abcdef",
+		APIURL:    defaultAPIURL,
+		Model:     "gpt-4",
+		Timeout:   defaultTimeout,
+		MaxTokens: 2000,
+		Output:    outputJSON,
+		ConfigDir: "/tmp/chatgpt-cli",
+	}
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = configListCommand(config, []string{})
+	})
+	if err != nil {
+		t.Fatalf("configListCommand() error = %v", err)
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal([]byte(out), &values); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+
+	expected := map[string]string{
+		"OPENAI_API_KEY":         "sk-1...cdef",
+		"OPENAI_MODEL":           "gpt-4",
+		"OPENAI_MAX_TOKENS":      "2000",
+		"OPENAI_TIMEOUT":         "1m0s",
+		"CHATGPT_CLI_OUTPUT":     "json",
+		"CHATGPT_CLI_CONFIG_DIR": "/tmp/chatgpt-cli",
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%s = %q, want %q", key, values[key], value)
+		}
+	}
+}
+
+// TestLogsCommandJSON tests the JSON output of the logs command
+func TestLogsCommandJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := &Config{
+		Output:    outputJSON,
+		ConfigDir: tmpDir,
+	}
+
+	// No log file yields an empty array
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = logsCommand(config, []string{})
+	})
+	if err != nil {
+		t.Fatalf("logsCommand() error = %v", err)
+	}
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("output = %q, want %q", out, "[]")
+	}
+
+	writeTestLogs(t, filepath.Join(tmpDir, "logs.jsonl"), []LogEntry{
+		{Timestamp: time.Now(), Command: "prompt", Prompt: "first"},
+		{Timestamp: time.Now(), Command: "prompt", Prompt: "second", Error: "failed"},
+	})
+
+	out = captureOutput(t, &os.Stdout, func() {
+		err = logsCommand(config, []string{"--errors-only"})
+	})
+	if err != nil {
+		t.Fatalf("logsCommand() error = %v", err)
+	}
+
+	var entries []LogEntry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+	if len(entries) != 1 || entries[0].Prompt != "second" {
+		t.Errorf("entries = %+v, want only the failed entry", entries)
+	}
+}
+
+// TestPrintJSONError tests the JSON error format
+func TestPrintJSONError(t *testing.T) {
+	out := captureOutput(t, &os.Stderr, func() {
+		printJSONError(os.ErrNotExist)
+	})
+
+	var result map[string]string
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+	if result["error"] != os.ErrNotExist.Error() {
+		t.Errorf("error = %q, want %q", result["error"], os.ErrNotExist.Error())
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Name accepted for the unnamed profile made of the top of the config file
+const defaultProfileName = "default"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// normalizeProfile validates a profile name. The default profile, given as ""
+// or "default", is returned as "".
+func normalizeProfile(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == defaultProfileName {
+		return "", nil
+	}
+	if !profileNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name %q: use only letters, digits, '-' and '_'", name)
+	}
+	return name, nil
+}
+
+// profileDisplayName returns the name shown to users for a profile
+func profileDisplayName(profile string) string {
+	if profile == "" {
+		return defaultProfileName
+	}
+	return profile
+}
+
+// parseProfileHeader returns the profile name of a [profile.<name>] line
+func parseProfileHeader(line string) (string, bool) {
+	if !strings.HasPrefix(line, "[profile.") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	name := strings.TrimSpace(line[len("[profile.") : len(line)-1])
+	return name, name != ""
+}
+
+// sortedProfileNames returns the names of the named profiles in sections
+func sortedProfileNames(sections map[string]map[string]string) []string {
+	var names []string
+	for name := range sections {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadProfileConfig returns the default section of the config file with the
+// profile's section merged over it
+func loadProfileConfig(configDir, profile string) (map[string]string, error) {
+	config, _, err := loadProfileValues(configDir, profile)
+	return config, err
+}
+
+// loadProfileValues is loadProfileConfig, also returning the keys set by
+// the profile's own section rather than the defaults
+func loadProfileValues(configDir, profile string) (map[string]string, map[string]bool, error) {
+	sections, err := loadConfigSections(configDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := sections[""]
+
+	// A profile's API key replaces the default one, whichever form each is in
+	for _, key := range []string{envAPIKey, encryptedAPIKeyKey} {
+		if _, exists := sections[profile][key]; exists && profile != "" {
+			delete(config, envAPIKey)
+			delete(config, encryptedAPIKeyKey)
+		}
+	}
+
+	profileKeys := make(map[string]bool)
+	for key, value := range sections[profile] {
+		config[key] = value
+		if profile != "" {
+			profileKeys[key] = true
+		}
+	}
+	return config, profileKeys, nil
+}
+
+// checkProfile returns an error if the selected profile has no section in the
+// config file
+func checkProfile(config *Config) error {
+	if config.Profile == "" {
+		return nil
+	}
+
+	sections, err := loadConfigSections(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	if _, exists := sections[config.Profile]; exists {
+		return nil
+	}
+
+	available := append([]string{defaultProfileName}, sortedProfileNames(sections)...)
+	return usageErrorf("unknown profile %q (available: %s)\nCreate it with: chatgpt-cli --profile %s config set <key> <value>",
+		config.Profile, strings.Join(available, ", "), config.Profile)
+}
+
+// createsProfile reports whether a command may run with a profile that does
+// not exist yet: init and config set, since they create profiles.
+func createsProfile(commandName string, args []string) bool {
+	return commandName == "init" || commandName == "config" && len(args) > 0 && args[0] == "set"
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestConfigFile writes raw contents to config.toml in configDir
+func writeTestConfigFile(t *testing.T, configDir, contents string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(configDir, configFileName), []byte(contents), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+}
+
+// loadTestConfigSections loads the config file sections, failing the test on
+// a parse error
+func loadTestConfigSections(t *testing.T, configDir string) map[string]map[string]string {
+	t.Helper()
+
+	sections, err := loadConfigSections(configDir)
+	if err != nil {
+		t.Fatalf("loadConfigSections() error = %v", err)
+	}
+	return sections
+}
+
+const testProfilesConfig = `# ChatGPT CLI Configuration
+api_key = "personal-key"
+model = "gpt-4o-mini"
+max_tokens = 500
+
+[profile.work]
+api_key = "work-key"
+model = "gpt-4"
+`
+
+// TestLoadConfigSections tests parsing the default and profile sections
+func TestLoadConfigSections(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, testProfilesConfig+"\n[profile.empty]\n")
+
+	sections := loadTestConfigSections(t, tmpDir)
+
+	if sections[""]["OPENAI_MODEL"] != "gpt-4o-mini" {
+		t.Errorf("default OPENAI_MODEL = %q, want %q", sections[""]["OPENAI_MODEL"], "gpt-4o-mini")
+	}
+	if sections["work"]["OPENAI_MODEL"] != "gpt-4" {
+		t.Errorf("work OPENAI_MODEL = %q, want %q", sections["work"]["OPENAI_MODEL"], "gpt-4")
+	}
+	if _, exists := sections["empty"]; !exists {
+		t.Errorf("empty profile section should exist")
+	}
+
+	// loadConfigFile only returns the default section
+	if fileConfig, err := loadConfigFile(tmpDir); err != nil || fileConfig["OPENAI_API_KEY"] != "personal-key" {
+		t.Errorf("loadConfigFile() should return the default section")
+	}
+}
+
+// TestLoadConfigProfile tests merging a profile over the defaults
+func TestLoadConfigProfile(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, testProfilesConfig)
+	setTestEnv(envConfigDir, tmpDir)
+
+	tests := []struct {
+		name            string
+		profile         string
+		envProfile      string
+		envModel        string
+		expectedProfile string
+		expectedKey     string
+		expectedModel   string
+	}{
+		{"default profile", "", "", "", "", "personal-key", "gpt-4o-mini"},
+		{"explicit default", "default", "", "", "", "personal-key", "gpt-4o-mini"},
+		{"work profile", "work", "", "", "work", "work-key", "gpt-4"},
+		{"profile from environment", "", "work", "", "work", "work-key", "gpt-4"},
+		{"flag overrides environment", "default", "work", "", "", "personal-key", "gpt-4o-mini"},
+		{"environment overrides profile values", "work", "", "gpt-4-turbo", "work", "work-key", "gpt-4-turbo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv(envProfile)
+			os.Unsetenv(envModel)
+			if tt.envProfile != "" {
+				setTestEnv(envProfile, tt.envProfile)
+			}
+			if tt.envModel != "" {
+				setTestEnv(envModel, tt.envModel)
+			}
+
+			config, err := loadConfig(tt.profile)
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+
+			if config.Profile != tt.expectedProfile {
+				t.Errorf("Profile = %q, want %q", config.Profile, tt.expectedProfile)
+			}
+			if config.APIKey != tt.expectedKey {
+				t.Errorf("APIKey = %q, want %q", config.APIKey, tt.expectedKey)
+			}
+			if config.Model != tt.expectedModel {
+				t.Errorf("Model = %q, want %q", config.Model, tt.expectedModel)
+			}
+
+			// Values missing from the profile come from the default section
+			if config.MaxTokens != 500 {
+				t.Errorf("MaxTokens = %d, want 500", config.MaxTokens)
+			}
+		})
+	}
+}
+
+// TestNormalizeProfile tests profile name validation
+func TestNormalizeProfile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"", "", false},
+		{"default", "", false},
+		{"work", "work", false},
+		{"client_a-2", "client_a-2", false},
+		{"my profile", "", true},
+		{"work]", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := normalizeProfile(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeProfile(%q) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if result != tt.expected {
+				t.Errorf("normalizeProfile(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestCheckProfile tests the error for unknown profiles
+func TestCheckProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, testProfilesConfig)
+
+	if err := checkProfile(&Config{ConfigDir: tmpDir}); err != nil {
+		t.Errorf("checkProfile(default) error = %v", err)
+	}
+	if err := checkProfile(&Config{ConfigDir: tmpDir, Profile: "work"}); err != nil {
+		t.Errorf("checkProfile(work) error = %v", err)
+	}
+
+	err := checkProfile(&Config{ConfigDir: tmpDir, Profile: "wrok"})
+	if err == nil {
+		t.Fatal("checkProfile(wrok) expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), `unknown profile "wrok" (available: default, work)`) {
+		t.Errorf("error = %q, want it to list the available profiles", err.Error())
+	}
+}
+
+// TestCreatesProfile tests which commands may create a profile
+func TestCreatesProfile(t *testing.T) {
+	if !createsProfile("config", []string{"set", "OPENAI_MODEL", "gpt-4"}) {
+		t.Errorf("config set should create profiles")
+	}
+	if createsProfile("config", []string{"list"}) || createsProfile("prompt", []string{"set"}) {
+		t.Errorf("only config set should create profiles")
+	}
+}
+
+// TestConfigCommandsWithProfile tests set, unset and reset on a named profile
+func TestConfigCommandsWithProfile(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, testProfilesConfig)
+
+	// Setting a value in a new profile creates its section
+	personal := &Config{ConfigDir: tmpDir, Profile: "personal"}
+	if err := configSetCommand(personal, []string{"OPENAI_MODEL", "gpt-4o"}); err != nil {
+		t.Fatalf("configSetCommand() error = %v", err)
+	}
+
+	work := &Config{ConfigDir: tmpDir, Profile: "work"}
+	if err := configSetCommand(work, []string{"OPENAI_TEMPERATURE", "0.2"}); err != nil {
+		t.Fatalf("configSetCommand() error = %v", err)
+	}
+
+	sections := loadTestConfigSections(t, tmpDir)
+	if sections["personal"]["OPENAI_MODEL"] != "gpt-4o" {
+		t.Errorf("personal OPENAI_MODEL = %q, want %q", sections["personal"]["OPENAI_MODEL"], "gpt-4o")
+	}
+	if sections["work"]["OPENAI_TEMPERATURE"] != "0.2" || sections["work"]["OPENAI_MODEL"] != "gpt-4" {
+		t.Errorf("work section = %v, want the new temperature and the old model", sections["work"])
+	}
+	if _, exists := sections[""]["OPENAI_TEMPERATURE"]; exists {
+		t.Errorf("default section should not get the work temperature")
+	}
+
+	// Unset only touches the active profile
+	if err := configUnsetCommand(work, []string{"OPENAI_MODEL"}); err != nil {
+		t.Fatalf("configUnsetCommand() error = %v", err)
+	}
+	sections = loadTestConfigSections(t, tmpDir)
+	if _, exists := sections["work"]["OPENAI_MODEL"]; exists {
+		t.Errorf("work OPENAI_MODEL should be unset")
+	}
+	if sections[""]["OPENAI_MODEL"] != "gpt-4o-mini" {
+		t.Errorf("default OPENAI_MODEL should be kept")
+	}
+
+	// Resetting a profile removes its section and leaves the others alone
+	stdin = strings.NewReader("y\n")
+	if err := configResetCommand(work, []string{}); err != nil {
+		t.Fatalf("configResetCommand() error = %v", err)
+	}
+	sections = loadTestConfigSections(t, tmpDir)
+	if _, exists := sections["work"]; exists {
+		t.Errorf("work profile should be removed")
+	}
+	if len(sections[""]) != 3 || len(sections["personal"]) != 1 {
+		t.Errorf("sections = %v, want default and personal untouched", sections)
+	}
+
+	// Resetting the default profile keeps the named profiles
+	if err := configResetCommand(&Config{ConfigDir: tmpDir}, []string{"--force"}); err != nil {
+		t.Fatalf("configResetCommand() error = %v", err)
+	}
+	sections = loadTestConfigSections(t, tmpDir)
+	if len(sections[""]) != 0 || sections["personal"]["OPENAI_MODEL"] != "gpt-4o" {
+		t.Errorf("sections = %v, want an empty default and the personal profile", sections)
+	}
+}
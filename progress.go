@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Frames of the waiting indicator and how often it is redrawn
+var (
+	progressFrames   = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+	progressInterval = 100 * time.Millisecond
+)
+
+// startProgress shows a spinner with the seconds elapsed on stderr while
+// waiting for a response that is not streamed, and returns the function
+// clearing it. Nothing is shown unless stderr is a terminal, nor when debug
+// output is printed there.
+func startProgress(config *Config) func() {
+	if config.Debug > 0 || !isTerminal(os.Stderr) {
+		return func() {}
+	}
+	return runProgress(newUI(config, os.Stderr), progressInterval)
+}
+
+// runProgress redraws the indicator every interval until the returned
+// function is called, which erases it before returning
+func runProgress(out *ui, interval time.Duration) func() {
+	start := time.Now()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for frame := 0; ; frame++ {
+			elapsed := int(time.Since(start).Seconds())
+			out.Printf("\r%s", out.dim(fmt.Sprintf("%s %ds", progressFrames[frame%len(progressFrames)], elapsed)))
+			select {
+			case <-done:
+				out.Printf("\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
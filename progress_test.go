@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRunProgress tests that the indicator is redrawn until stopped, then
+// erased
+func TestRunProgress(t *testing.T) {
+	var b strings.Builder
+	stop := runProgress(&ui{w: &b}, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	stop()
+
+	out := b.String()
+	if !strings.HasPrefix(out, "\r⠋ 0s\r⠙ 0s") {
+		t.Errorf("output = %q, want successive frames", out)
+	}
+	if !strings.HasSuffix(out, "\r\033[K") || strings.Count(out, "\033[K") != 1 {
+		t.Errorf("output = %q, want it erased once at the end", out)
+	}
+
+	// Nothing is drawn when stderr is not a terminal
+	startProgress(&Config{})()
+}
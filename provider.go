@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Supported API providers
+const (
+	providerOpenAI    = "openai"
+	providerAzure     = "azure"
+	providerAnthropic = "anthropic"
+	providerOllama    = "ollama"
+)
+
+// Default api-version query parameter sent to Azure OpenAI
+const defaultAzureAPIVersion = "2024-06-01"
+
+// validProviders lists the accepted OPENAI_PROVIDER values
+var validProviders = []string{providerOpenAI, providerAzure, providerAnthropic, providerOllama}
+
+// chatProvider translates chat messages to and from an API's wire format.
+// Replies are returned as a ChatResponse, the shape the rest of the CLI uses
+// whatever the provider.
+type chatProvider interface {
+	// NewRequest builds the HTTP request for the messages without sending it
+	NewRequest(ctx context.Context, config *Config, messages []Message, stream bool) (*http.Request, error)
+	// ReadResponse parses a complete reply, including error statuses
+	ReadResponse(resp *http.Response) (*ChatResponse, error)
+	// ReadStream parses a streamed reply, writing the content to w as it arrives
+	ReadStream(r io.Reader, w io.Writer) (*ChatResponse, error)
+}
+
+// chatProviderFor returns the implementation of the configured provider
+func chatProviderFor(config *Config) chatProvider {
+	switch config.Provider {
+	case providerAnthropic:
+		return anthropicProvider{}
+	case providerOllama:
+		return ollamaProvider{}
+	default:
+		if usesGateway(config) {
+			return gatewayProvider{config: config}
+		}
+		return openAIProvider{}
+	}
+}
+
+// defaultAPIURLFor returns the chat endpoint used when OPENAI_API_URL is unset
+func defaultAPIURLFor(provider string) string {
+	switch provider {
+	case providerAnthropic:
+		return defaultAnthropicAPIURL
+	case providerOllama:
+		return defaultOllamaAPIURL
+	default:
+		return defaultAPIURL
+	}
+}
+
+// defaultModelFor returns the model used when OPENAI_MODEL is unset
+func defaultModelFor(provider string) string {
+	switch provider {
+	case providerAnthropic:
+		return defaultAnthropicModel
+	case providerOllama:
+		return defaultOllamaModel
+	default:
+		return defaultModel
+	}
+}
+
+// apiKeyName returns the variable holding the provider's API key
+func apiKeyName(provider string) string {
+	if provider == providerAnthropic {
+		return envAnthropicAPIKey
+	}
+	return envAPIKey
+}
+
+// providerRequiresAPIKey reports whether requests fail without an API key.
+// A local Ollama server accepts them unauthenticated.
+func providerRequiresAPIKey(provider string) bool {
+	return provider != providerOllama
+}
+
+// parseProviderOrDefault validates a provider name, falling back to the default
+func parseProviderOrDefault(value, defaultValue string) string {
+	value = strings.ToLower(value)
+	for _, p := range validProviders {
+		if value == p {
+			return value
+		}
+	}
+	return defaultValue
+}
+
+// setAuthHeader authenticates a request the way the configured provider expects
+func setAuthHeader(req *http.Request, config *Config) {
+	switch config.Provider {
+	case providerAzure:
+		req.Header.Set("api-key", config.APIKey)
+	case providerAnthropic:
+		req.Header.Set("x-api-key", config.APIKey)
+		req.Header.Set("anthropic-version", anthropicVersion)
+	case providerOllama:
+		// Only sent for an Ollama behind an authenticating proxy
+		if config.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+config.APIKey)
+		}
+	default:
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+		setOrganizationHeaders(req, config)
+	}
+}
+
+// setOrganizationHeaders selects the OpenAI organization and project billed
+// for a request, when configured. Without them the key's defaults are used.
+func setOrganizationHeaders(req *http.Request, config *Config) {
+	if config.OrgID != "" {
+		req.Header.Set("OpenAI-Organization", config.OrgID)
+	}
+	if config.ProjectID != "" {
+		req.Header.Set("OpenAI-Project", config.ProjectID)
+	}
+}
+
+// chatCompletionsURL returns the URL chat requests are sent to. Azure requires
+// an api-version query parameter, which is added unless the URL already has one.
+func chatCompletionsURL(config *Config) (string, error) {
+	if config.Provider != providerAzure {
+		return config.APIURL, nil
+	}
+
+	u, err := url.Parse(config.APIURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid API URL: %w", err)
+	}
+
+	query := u.Query()
+	if query.Get("api-version") == "" {
+		version := config.AzureAPIVersion
+		if version == "" {
+			version = defaultAzureAPIVersion
+		}
+		query.Set("api-version", version)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// siblingEndpointURL returns the URL of another OpenAI endpoint, such as
+// /images/generations, next to the chat completions URL: under the same
+// prefix, or the Azure deployment, when the URL ends in /chat/completions,
+// otherwise under /v1 of its host
+func siblingEndpointURL(config *Config, endpoint string) (string, error) {
+	apiURL, err := chatCompletionsURL(config)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return strings.TrimSuffix(defaultAPIURL, "/chat/completions") + endpoint, nil
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if strings.HasSuffix(path, "/chat/completions") {
+		u.Path = strings.TrimSuffix(path, "/chat/completions") + endpoint
+	} else {
+		u.Path = "/v1" + endpoint
+	}
+
+	return u.String(), nil
+}
+
+// requestModel returns the model sent in the request body. Azure encodes the
+// model in the deployment URL, so the field is left out.
+func requestModel(config *Config) string {
+	if config.Provider == providerAzure {
+		return ""
+	}
+	return config.Model
+}
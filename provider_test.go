@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestParseProviderOrDefault tests provider parsing with defaults
+func TestParseProviderOrDefault(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", providerOpenAI},
+		{"openai", providerOpenAI},
+		{"azure", providerAzure},
+		{"AZURE", providerAzure},
+		{"anthropic", providerAnthropic},
+		{"ollama", providerOllama},
+		{"unknown", providerOpenAI},
+	}
+
+	for _, tt := range tests {
+		if result := parseProviderOrDefault(tt.input, providerOpenAI); result != tt.expected {
+			t.Errorf("parseProviderOrDefault(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+// TestChatCompletionsURL tests the api-version query parameter for Azure
+func TestChatCompletionsURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *Config
+		expected string
+	}{
+		{
+			name:     "openai URL is unchanged",
+			config:   &Config{Provider: providerOpenAI, APIURL: defaultAPIURL},
+			expected: defaultAPIURL,
+		},
+		{
+			name: "azure adds api-version",
+			config: &Config{
+				Provider:        providerAzure,
+				APIURL:          "https://example.openai.azure.com/openai/deployments/gpt4/chat/completions",
+				AzureAPIVersion: "2024-02-01",
+			},
+			expected: "https://example.openai.azure.com/openai/deployments/gpt4/chat/completions?api-version=2024-02-01",
+		},
+		{
+			name: "azure keeps api-version from URL",
+			config: &Config{
+				Provider:        providerAzure,
+				APIURL:          "https://example.openai.azure.com/openai/deployments/gpt4/chat/completions?api-version=2023-05-15",
+				AzureAPIVersion: "2024-02-01",
+			},
+			expected: "https://example.openai.azure.com/openai/deployments/gpt4/chat/completions?api-version=2023-05-15",
+		},
+		{
+			name: "azure without configured version uses default",
+			config: &Config{
+				Provider: providerAzure,
+				APIURL:   "https://example.openai.azure.com/openai/deployments/gpt4/chat/completions",
+			},
+			expected: "https://example.openai.azure.com/openai/deployments/gpt4/chat/completions?api-version=" + defaultAzureAPIVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := chatCompletionsURL(tt.config)
+			if err != nil {
+				t.Fatalf("chatCompletionsURL() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("chatCompletionsURL() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSendChatRequestProviders asserts the exact request sent for each provider
+func TestSendChatRequestProviders(t *testing.T) {
+	tests := []struct {
+		name              string
+		provider          string
+		path              string
+		expectedQuery     string
+		expectedAuth      string
+		expectedAPIKey    string
+		expectModelInBody bool
+	}{
+		{
+			name:              "openai",
+			provider:          providerOpenAI,
+			path:              "/v1/chat/completions",
+			expectedQuery:     "",
+			expectedAuth:      "Bearer test-key",
+			expectedAPIKey:    "",
+			expectModelInBody: true,
+		},
+		{
+			name:              "azure",
+			provider:          providerAzure,
+			path:              "/openai/deployments/my-gpt4/chat/completions",
+			expectedQuery:     "api-version=2024-06-01",
+			expectedAuth:      "",
+			expectedAPIKey:    "test-key",
+			expectModelInBody: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("path = %q, want %q", r.URL.Path, tt.path)
+				}
+				if r.URL.RawQuery != tt.expectedQuery {
+					t.Errorf("query = %q, want %q", r.URL.RawQuery, tt.expectedQuery)
+				}
+				if got := r.Header.Get("Authorization"); got != tt.expectedAuth {
+					t.Errorf("Authorization header = %q, want %q", got, tt.expectedAuth)
+				}
+				if got := r.Header.Get("api-key"); got != tt.expectedAPIKey {
+					t.Errorf("api-key header = %q, want %q", got, tt.expectedAPIKey)
+				}
+
+				body, _ := io.ReadAll(r.Body)
+				var fields map[string]interface{}
+				if err := json.Unmarshal(body, &fields); err != nil {
+					t.Fatalf("failed to decode request: %v", err)
+				}
+				if _, hasModel := fields["model"]; hasModel != tt.expectModelInBody {
+					t.Errorf("model in body = %t, want %t", hasModel, tt.expectModelInBody)
+				}
+
+				_ = json.NewEncoder(w).Encode(ChatResponse{
+					Choices: []Choice{{Message: Message{Content: "ok"}}},
+				})
+			}))
+			defer server.Close()
+
+			config := &Config{
+				APIKey:          "test-key",
+				APIURL:          server.URL + tt.path,
+				Model:           "gpt-4",
+				Timeout:         10 * time.Second,
+				Provider:        tt.provider,
+				AzureAPIVersion: defaultAzureAPIVersion,
+			}
+
+			if _, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt")); err != nil {
+				t.Errorf("Chat() error = %v", err)
+			}
+		})
+	}
+}
+
+// TestOrganizationHeaders tests that the organization and project are sent
+// with chat and models requests only when configured
+func TestOrganizationHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    string
+		orgID       string
+		projectID   string
+		wantOrg     string
+		wantProject string
+	}{
+		{name: "not configured", provider: providerOpenAI},
+		{name: "organization only", provider: providerOpenAI, orgID: "org-abc", wantOrg: "org-abc"},
+		{name: "organization and project", provider: providerOpenAI, orgID: "org-abc", projectID: "proj_xyz", wantOrg: "org-abc", wantProject: "proj_xyz"},
+		{name: "azure ignores them", provider: providerAzure, orgID: "org-abc", projectID: "proj_xyz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if got := r.Header.Get("OpenAI-Organization"); got != tt.wantOrg {
+					t.Errorf("%s OpenAI-Organization header = %q, want %q", r.URL.Path, got, tt.wantOrg)
+				}
+				if got := r.Header.Get("OpenAI-Project"); got != tt.wantProject {
+					t.Errorf("%s OpenAI-Project header = %q, want %q", r.URL.Path, got, tt.wantProject)
+				}
+
+				if strings.HasSuffix(r.URL.Path, "/models") {
+					_ = json.NewEncoder(w).Encode(ModelsResponse{Data: []Model{{ID: "gpt-4"}}})
+					return
+				}
+				_ = json.NewEncoder(w).Encode(ChatResponse{
+					Choices: []Choice{{Message: Message{Content: "ok"}}},
+				})
+			}))
+			defer server.Close()
+
+			config := &Config{
+				APIKey:          "test-key",
+				APIURL:          server.URL + "/v1/chat/completions",
+				Model:           "gpt-4",
+				Timeout:         10 * time.Second,
+				Provider:        tt.provider,
+				AzureAPIVersion: defaultAzureAPIVersion,
+				OrgID:           tt.orgID,
+				ProjectID:       tt.projectID,
+			}
+
+			if _, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "test prompt")); err != nil {
+				t.Errorf("Chat() error = %v", err)
+			}
+			if _, err := fetchModels(context.Background(), config); err != nil {
+				t.Errorf("fetchModels() error = %v", err)
+			}
+			if requests != 2 {
+				t.Errorf("server received %d requests, want 2", requests)
+			}
+		})
+	}
+}
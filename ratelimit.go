@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of the file in the config directory holding the request budget shared
+// by every invocation of the CLI
+const rateLimitFileName = "ratelimit"
+
+// How long a request waits for another invocation to update the budget
+const rateLimitLockTimeout = 10 * time.Second
+
+// Serializes the goroutines of one process, so that only other processes
+// contend for the lock file
+var rateLimitMu sync.Mutex
+
+// rateLimiter enforces OPENAI_REQUESTS_PER_MINUTE with a token bucket holding
+// up to a minute's worth of requests and refilled one request at a time. The
+// bucket is stored as the single timestamp at which it would be empty, the
+// moment the last granted request would be sent if requests were spaced
+// evenly; a request is allowed while that moment is at most a minute ahead.
+type rateLimiter struct {
+	path      string
+	perMinute int
+	// now and sleep are replaced by tests with a fake clock
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+	// out receives the waiting messages
+	out io.Writer
+}
+
+// newRateLimiter returns the limiter of the configured budget
+func newRateLimiter(config *Config) *rateLimiter {
+	return &rateLimiter{
+		path:      filepath.Join(config.ConfigDir, rateLimitFileName),
+		perMinute: config.RequestsPerMinute,
+		now:       time.Now,
+		sleep:     sleepContext,
+		out:       os.Stderr,
+	}
+}
+
+// waitForRateLimit takes a request from the budget set by
+// OPENAI_REQUESTS_PER_MINUTE, sleeping until one is available unless
+// --no-wait was given
+func waitForRateLimit(ctx context.Context, config *Config) error {
+	if config.RequestsPerMinute <= 0 {
+		return nil
+	}
+	return newRateLimiter(config).acquire(ctx, !config.NoWait)
+}
+
+// acquire takes a request from the budget. When the budget is exhausted it
+// waits for the next request to be allowed, or fails with ErrRequestBudget
+// if wait is false.
+func (l *rateLimiter) acquire(ctx context.Context, wait bool) error {
+	for {
+		delay, err := l.reserve()
+		if err != nil {
+			return err
+		}
+		if delay <= 0 {
+			return nil
+		}
+		if !wait {
+			return withKind(ErrRequestBudget, fmt.Errorf("rate limit of %d requests per minute reached; next request allowed in %s (%s)", l.perMinute, formatWait(delay), envRequestsPerMinute))
+		}
+
+		fmt.Fprintf(l.out, "waiting %s to respect rate limit\n", formatWait(delay))
+		if err := l.sleep(ctx, delay); err != nil {
+			return err
+		}
+		// Another invocation may have taken the request in the meantime
+	}
+}
+
+// reserve takes a request from the budget if one is available, returning 0,
+// or otherwise how long until one is
+func (l *rateLimiter) reserve() (time.Duration, error) {
+	unlock, err := l.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	now := l.now()
+	empty := l.readEmptyAt()
+	if empty.Before(now) {
+		empty = now
+	}
+	empty = empty.Add(time.Minute / time.Duration(l.perMinute))
+	if delay := empty.Sub(now) - time.Minute; delay > 0 {
+		return delay, nil
+	}
+
+	if err := os.WriteFile(l.path, []byte(strconv.FormatInt(empty.UnixNano(), 10)+"\n"), 0600); err != nil {
+		return 0, fmt.Errorf("failed to update rate limit state: %w", err)
+	}
+	return 0, nil
+}
+
+// readEmptyAt reads the time at which the bucket is empty. A missing or
+// unreadable file means a full bucket.
+func (l *rateLimiter) readEmptyAt() time.Time {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return time.Time{}
+	}
+	nanos, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// lock takes the lock file next to the budget, so that invocations running at
+// the same time do not grant the same request, and returns its release
+func (l *rateLimiter) lock() (func(), error) {
+	rateLimitMu.Lock()
+
+	unlock, err := lockFile(l.path+".lock", rateLimitLockTimeout)
+	if err != nil {
+		rateLimitMu.Unlock()
+		return nil, fmt.Errorf("failed to lock rate limit state: %w", err)
+	}
+	return func() {
+		unlock()
+		rateLimitMu.Unlock()
+	}, nil
+}
+
+// sleepContext sleeps for d, returning early if ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send request: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// formatWait formats a wait in seconds, rounded up to a tenth so that it is
+// never shown as 0.0s
+func formatWait(d time.Duration) string {
+	tenths := (d + 100*time.Millisecond - 1) / (100 * time.Millisecond)
+	return fmt.Sprintf("%d.%ds", tenths/10, tenths%10)
+}
+
+// formatRequestsPerMinute formats OPENAI_REQUESTS_PER_MINUTE as config list
+// shows it
+func formatRequestsPerMinute(perMinute int) string {
+	if perMinute <= 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(perMinute)
+}
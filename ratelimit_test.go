@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that moves only when slept on
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.slept = append(c.slept, d)
+	c.now = c.now.Add(d)
+	return nil
+}
+
+// newTestRateLimiter returns a limiter of perMinute requests on a fake clock,
+// keeping its state in dir
+func newTestRateLimiter(dir string, perMinute int, clock *fakeClock, out *strings.Builder) *rateLimiter {
+	return &rateLimiter{
+		path:      filepath.Join(dir, rateLimitFileName),
+		perMinute: perMinute,
+		now:       clock.Now,
+		sleep:     clock.Sleep,
+		out:       out,
+	}
+}
+
+// TestRateLimiterBurstThenWait tests that a minute's budget is sent at once,
+// then requests are spaced evenly
+func TestRateLimiterBurstThenWait(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	var out strings.Builder
+	limiter := newTestRateLimiter(t.TempDir(), 3, clock, &out)
+
+	for i := 0; i < 3; i++ {
+		if err := limiter.acquire(context.Background(), true); err != nil {
+			t.Fatalf("acquire() %d error = %v", i, err)
+		}
+	}
+	if len(clock.slept) != 0 {
+		t.Fatalf("slept %v within the budget", clock.slept)
+	}
+
+	if err := limiter.acquire(context.Background(), true); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != 20*time.Second {
+		t.Errorf("slept %v, want 20s", clock.slept)
+	}
+	if got, want := out.String(), "waiting 20.0s to respect rate limit\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+
+	// A request is refilled every 20 seconds
+	clock.now = clock.now.Add(20 * time.Second)
+	if err := limiter.acquire(context.Background(), false); err != nil {
+		t.Errorf("acquire() after a refill error = %v", err)
+	}
+}
+
+// TestRateLimiterNoWait tests that --no-wait fails with its own error kind
+func TestRateLimiterNoWait(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	var out strings.Builder
+	limiter := newTestRateLimiter(t.TempDir(), 1, clock, &out)
+
+	if err := limiter.acquire(context.Background(), false); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	clock.now = clock.now.Add(56800 * time.Millisecond)
+
+	err := limiter.acquire(context.Background(), false)
+	if !errors.Is(err, ErrRequestBudget) || exitCode(err) != exitBudget {
+		t.Fatalf("acquire() error = %v, want ErrRequestBudget", err)
+	}
+	if !strings.Contains(err.Error(), "next request allowed in 3.2s") {
+		t.Errorf("error = %q, want the wait", err)
+	}
+	if len(clock.slept) != 0 || out.Len() != 0 {
+		t.Errorf("--no-wait slept %v and printed %q", clock.slept, out.String())
+	}
+}
+
+// TestRateLimiterSharedState tests that limiters on the same config
+// directory, like separate invocations, share the budget
+func TestRateLimiterSharedState(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	var out strings.Builder
+
+	for i := 0; i < 2; i++ {
+		if err := newTestRateLimiter(dir, 2, clock, &out).acquire(context.Background(), false); err != nil {
+			t.Fatalf("acquire() %d error = %v", i, err)
+		}
+	}
+	if err := newTestRateLimiter(dir, 2, clock, &out).acquire(context.Background(), false); !errors.Is(err, ErrRequestBudget) {
+		t.Errorf("third acquire() error = %v, want ErrRequestBudget", err)
+	}
+
+	// An idle minute refills the whole budget, but no more
+	clock.now = clock.now.Add(10 * time.Minute)
+	for i := 0; i < 2; i++ {
+		if err := newTestRateLimiter(dir, 2, clock, &out).acquire(context.Background(), false); err != nil {
+			t.Fatalf("acquire() %d after idling error = %v", i, err)
+		}
+	}
+	if err := newTestRateLimiter(dir, 2, clock, &out).acquire(context.Background(), false); !errors.Is(err, ErrRequestBudget) {
+		t.Errorf("acquire() over the budget after idling error = %v, want ErrRequestBudget", err)
+	}
+}
+
+// TestRateLimiterCorruptState tests that an unreadable state file counts as
+// a full budget
+func TestRateLimiterCorruptState(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, rateLimitFileName), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	var out strings.Builder
+	if err := newTestRateLimiter(dir, 1, clock, &out).acquire(context.Background(), false); err != nil {
+		t.Errorf("acquire() error = %v", err)
+	}
+}
+
+// TestRateLimiterLeftoverLock tests that the lock file of a killed process
+// doesn't block the budget, since its lock went away with the process, and
+// that a held lock makes others wait
+func TestRateLimiterLeftoverLock(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, rateLimitFileName+".lock")
+	if err := os.WriteFile(lockPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)}
+	var out strings.Builder
+	limiter := newTestRateLimiter(dir, 2, clock, &out)
+	if err := limiter.acquire(context.Background(), false); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	unlock, err := lockFile(lockPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan error)
+	go func() { acquired <- limiter.acquire(context.Background(), false) }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("acquire() = %v while another process holds the lock", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	if err := <-acquired; err != nil {
+		t.Errorf("acquire() after release error = %v", err)
+	}
+}
+
+// TestSleepContextCancelled tests that waiting for the budget stops on Ctrl-C
+func TestSleepContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Hour); !isCancelled(err) {
+		t.Errorf("sleepContext() error = %v, want a cancellation", err)
+	}
+}
+
+// TestPromptCommandNoWait tests that prompt --no-wait exits with the budget
+// status once OPENAI_REQUESTS_PER_MINUTE is used up
+func TestPromptCommandNoWait(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+	tmpDir := t.TempDir()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:            "sk-test",
+		APIURL:            server.URL,
+		Model:             "gpt-4o",
+		Timeout:           5 * time.Second,
+		ConfigDir:         tmpDir,
+		RequestsPerMinute: 1,
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "--no-wait", "first"}); err != nil {
+			t.Fatalf("first prompt error = %v", err)
+		}
+	})
+
+	var err error
+	captureOutput(t, &os.Stdout, func() {
+		err = promptCommand(config, []string{"--no-stream", "--no-wait", "second"})
+	})
+	if exitCode(err) != exitBudget {
+		t.Errorf("second prompt error = %v, want exit status %d", err, exitBudget)
+	}
+	if requests != 1 {
+		t.Errorf("server got %d requests, want 1", requests)
+	}
+}
+
+// TestFormatWait tests that waits are rounded up to a tenth of a second
+func TestFormatWait(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		3200 * time.Millisecond: "3.2s",
+		3210 * time.Millisecond: "3.3s",
+		time.Millisecond:        "0.1s",
+		20 * time.Second:        "20.0s",
+	} {
+		if got := formatWait(d); got != want {
+			t.Errorf("formatWait(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Accepted --reasoning-effort values
+var validReasoningEfforts = []string{"low", "medium", "high"}
+
+// modelCapabilities tells which request fields a model accepts
+type modelCapabilities struct {
+	// MaxCompletionTokens sends the token limit as max_completion_tokens,
+	// which reasoning models require instead of max_tokens
+	MaxCompletionTokens bool
+	// Sampling allows temperature, top_p and the penalties
+	Sampling bool
+	// Stop allows stop sequences
+	Stop bool
+	// ReasoningEffort allows reasoning_effort
+	ReasoningEffort bool
+}
+
+// Capabilities of the models not in modelCapabilityTable
+var defaultCapabilities = modelCapabilities{Sampling: true, Stop: true}
+
+// Capabilities of reasoning models, also used for OPENAI_REASONING_MODELS
+var reasoningCapabilities = modelCapabilities{MaxCompletionTokens: true, ReasoningEffort: true}
+
+// Capabilities of model families that differ from the default. Dated variants
+// such as o1-2024-12-17 match their family; the first match wins, so more
+// specific names come first.
+var modelCapabilityTable = []struct {
+	Family       string
+	Capabilities modelCapabilities
+}{
+	{"o1-mini", modelCapabilities{MaxCompletionTokens: true}},
+	{"o1-preview", modelCapabilities{MaxCompletionTokens: true}},
+	{"o1", reasoningCapabilities},
+	{"o3", reasoningCapabilities},
+	{"o4-mini", reasoningCapabilities},
+	{"gpt-5", reasoningCapabilities},
+}
+
+// capabilitiesFor returns the request fields the configured model accepts.
+// Models listed in OPENAI_REASONING_MODELS are treated as reasoning models.
+func capabilitiesFor(config *Config) modelCapabilities {
+	model := config.Model
+	for _, family := range config.ReasoningModels {
+		if modelInFamily(model, family) {
+			return reasoningCapabilities
+		}
+	}
+	for _, entry := range modelCapabilityTable {
+		if modelInFamily(model, entry.Family) {
+			return entry.Capabilities
+		}
+	}
+	return defaultCapabilities
+}
+
+// modelInFamily reports whether model is family or one of its variants
+func modelInFamily(model, family string) bool {
+	return model == family || strings.HasPrefix(model, family+"-")
+}
+
+// shapeRequest fits the sampling fields of a request to what the model
+// accepts, returning the configured fields that had to be dropped. Fields
+// left at their defaults are dropped silently.
+func shapeRequest(body *ChatRequest, config *Config, caps modelCapabilities) []string {
+	var dropped []string
+
+	if caps.MaxCompletionTokens {
+		body.MaxCompletionTokens = body.MaxTokens
+		body.MaxTokens = 0
+	}
+	if !caps.Sampling {
+		if body.Temperature != defaultTemperature {
+			dropped = append(dropped, "temperature")
+		}
+		for _, field := range []struct {
+			name  string
+			value float64
+		}{{"top_p", body.TopP}, {"presence_penalty", body.PresencePenalty}, {"frequency_penalty", body.FrequencyPenalty}} {
+			if field.value != 0 {
+				dropped = append(dropped, field.name)
+			}
+		}
+		body.Temperature, body.TopP, body.PresencePenalty, body.FrequencyPenalty = 0, 0, 0, 0
+	}
+	if !caps.Stop && len(body.Stop) > 0 {
+		dropped = append(dropped, "stop")
+		body.Stop = nil
+	}
+
+	if config.ReasoningEffort != "" {
+		if caps.ReasoningEffort {
+			body.ReasoningEffort = config.ReasoningEffort
+		} else {
+			dropped = append(dropped, "reasoning_effort")
+		}
+	}
+	return dropped
+}
+
+// Dropped fields already warned about, so that retries, tool rounds and
+// batch prompts warn once
+var droppedFieldWarnings sync.Map
+
+// warnDroppedFields tells on stderr which configured fields the model does
+// not accept and were left out of the request
+func warnDroppedFields(config *Config, dropped []string) {
+	if len(dropped) == 0 {
+		return
+	}
+	key := config.Model + ":" + strings.Join(dropped, ",")
+	if _, warned := droppedFieldWarnings.LoadOrStore(key, true); warned {
+		return
+	}
+	errOut := newUI(config, os.Stderr)
+	errOut.Printf("%s %s does not support %s; not sent\n", errOut.yellow("Warning:"), config.Model, strings.Join(dropped, ", "))
+}
+
+// validateReasoningEffort checks a --reasoning-effort value
+func validateReasoningEffort(value string) error {
+	for _, effort := range validReasoningEfforts {
+		if value == effort {
+			return nil
+		}
+	}
+	return usageErrorf("--reasoning-effort must be low, medium or high")
+}
+
+// parseModelList parses a comma-separated list of model names
+func parseModelList(value string) []string {
+	var models []string
+	for _, model := range strings.Split(value, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// formatModelList formats models as config list shows them
+func formatModelList(models []string) string {
+	if len(models) == 0 {
+		return "(not set)"
+	}
+	return strings.Join(models, ",")
+}
+
+// validateModelList checks that every item of a model list is a model name
+func validateModelList(value string) error {
+	for _, model := range parseModelList(value) {
+		if strings.ContainsAny(model, " \t") {
+			return fmt.Errorf("reasoning models must be model names separated by commas: %q", model)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestRequestBodyPerModelFamily tests the exact fields sent to each kind of model
+func TestRequestBodyPerModelFamily(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		config   func(*Config)
+		expected string
+	}{
+		{
+			name:     "chat model",
+			model:    "gpt-4o",
+			expected: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"max_tokens":500,"temperature":0.7,"top_p":0.9,"stop":["END"]}`,
+		},
+		{
+			name:     "chat model ignores reasoning effort",
+			model:    "gpt-4o-mini",
+			config:   func(c *Config) { c.ReasoningEffort = "high" },
+			expected: `{"model":"gpt-4o-mini","messages":[{"role":"user","content":"hi"}],"max_tokens":500,"temperature":0.7,"top_p":0.9,"stop":["END"]}`,
+		},
+		{
+			name:     "o1",
+			model:    "o1",
+			config:   func(c *Config) { c.ReasoningEffort = "high" },
+			expected: `{"model":"o1","messages":[{"role":"user","content":"hi"}],"max_completion_tokens":500,"reasoning_effort":"high"}`,
+		},
+		{
+			name:     "dated o1",
+			model:    "o1-2024-12-17",
+			expected: `{"model":"o1-2024-12-17","messages":[{"role":"user","content":"hi"}],"max_completion_tokens":500}`,
+		},
+		{
+			name:     "o1-mini has no reasoning effort",
+			model:    "o1-mini",
+			config:   func(c *Config) { c.ReasoningEffort = "low" },
+			expected: `{"model":"o1-mini","messages":[{"role":"user","content":"hi"}],"max_completion_tokens":500}`,
+		},
+		{
+			name:     "o3-mini",
+			model:    "o3-mini",
+			config:   func(c *Config) { c.ReasoningEffort = "medium" },
+			expected: `{"model":"o3-mini","messages":[{"role":"user","content":"hi"}],"max_completion_tokens":500,"reasoning_effort":"medium"}`,
+		},
+		{
+			name:     "gpt-5",
+			model:    "gpt-5-mini",
+			expected: `{"model":"gpt-5-mini","messages":[{"role":"user","content":"hi"}],"max_completion_tokens":500}`,
+		},
+		{
+			name:     "configured reasoning model",
+			model:    "my-proxy-o3",
+			config:   func(c *Config) { c.ReasoningModels = []string{"my-proxy-o3"} },
+			expected: `{"model":"my-proxy-o3","messages":[{"role":"user","content":"hi"}],"max_completion_tokens":500}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Provider:    providerOpenAI,
+				APIURL:      defaultAPIURL,
+				Model:       tt.model,
+				MaxTokens:   500,
+				Temperature: defaultTemperature,
+				TopP:        0.9,
+				Stop:        []string{"END"},
+			}
+			if tt.config != nil {
+				tt.config(config)
+			}
+
+			var body []byte
+			captureOutput(t, &os.Stderr, func() {
+				req, err := newChatRequest(context.Background(), config, "hi", false)
+				if err != nil {
+					t.Fatalf("newChatRequest() error = %v", err)
+				}
+				body, _ = io.ReadAll(req.Body)
+			})
+			if string(body) != tt.expected {
+				t.Errorf("body = %s\nwant %s", body, tt.expected)
+			}
+		})
+	}
+}
+
+// TestShapeRequestDropped tests which dropped fields are warned about
+func TestShapeRequestDropped(t *testing.T) {
+	config := &Config{Model: "o3", ReasoningEffort: "low"}
+
+	body := ChatRequest{MaxTokens: 100, Temperature: defaultTemperature}
+	if dropped := shapeRequest(&body, config, capabilitiesFor(config)); len(dropped) != 0 {
+		t.Errorf("shapeRequest() dropped %v, want nothing for defaults", dropped)
+	}
+
+	body = ChatRequest{MaxTokens: 100, Temperature: 0.2, PresencePenalty: 1, Stop: []string{"x"}}
+	dropped := shapeRequest(&body, config, capabilitiesFor(config))
+	if strings.Join(dropped, ",") != "temperature,presence_penalty,stop" {
+		t.Errorf("shapeRequest() dropped %v, want temperature, presence_penalty and stop", dropped)
+	}
+
+	config.Model = "gpt-4o"
+	body = ChatRequest{Temperature: 0.2}
+	if dropped := shapeRequest(&body, config, capabilitiesFor(config)); strings.Join(dropped, ",") != "reasoning_effort" {
+		t.Errorf("shapeRequest() dropped %v, want reasoning_effort", dropped)
+	}
+}
+
+// TestWarnDroppedFields tests that a dropped field is warned about once
+func TestWarnDroppedFields(t *testing.T) {
+	config := &Config{Model: "o1-warn-test"}
+	out := captureOutput(t, &os.Stderr, func() {
+		warnDroppedFields(config, []string{"temperature", "stop"})
+		warnDroppedFields(config, []string{"temperature", "stop"})
+		warnDroppedFields(config, nil)
+	})
+	if want := "Warning: o1-warn-test does not support temperature, stop; not sent\n"; out != want {
+		t.Errorf("stderr = %q, want %q once", out, want)
+	}
+}
+
+// TestReasoningEffortFlag tests validating --reasoning-effort
+func TestReasoningEffortFlag(t *testing.T) {
+	for _, tt := range []struct {
+		value   string
+		wantErr bool
+	}{{"low", false}, {"high", false}, {"", false}, {"max", true}} {
+		config := &Config{}
+		fs := flag.NewFlagSet("prompt", flag.ContinueOnError)
+		sampling := addSamplingFlags(fs, config)
+		if err := fs.Parse([]string{"--reasoning-effort", tt.value}); err != nil {
+			t.Fatal(err)
+		}
+		err := sampling.apply(fs, config)
+		if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrUsage)) {
+			t.Errorf("--reasoning-effort %q error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if err == nil && config.ReasoningEffort != tt.value {
+			t.Errorf("ReasoningEffort = %q, want %q", config.ReasoningEffort, tt.value)
+		}
+	}
+}
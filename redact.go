@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Name of the file of the user's own redaction patterns, one regular
+// expression per line, inside the config directory
+const redactRulesFile = "redact.rules"
+
+// Type of the matches of the patterns in redact.rules
+const redactCustomType = "custom"
+
+// redactRule replaces the matches of a pattern with [REDACTED:<type>]
+type redactRule struct {
+	Type        string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// newRedactRule compiles a built-in rule. With keepLabel, the pattern's
+// first group is a label such as aws_secret_access_key= that is kept in
+// front of the replacement.
+func newRedactRule(ruleType, pattern string, keepLabel bool) redactRule {
+	replacement := "[REDACTED:" + ruleType + "]"
+	if keepLabel {
+		replacement = "${1}" + replacement
+	}
+	return redactRule{Type: ruleType, re: regexp.MustCompile(pattern), replacement: replacement}
+}
+
+// builtinRedactRules are applied in order, so JWTs are named as such before
+// the bearer token rule sees them. The patterns ask for the prefixes and
+// lengths the providers use, so git SHAs, UUIDs and other hex or base64
+// strings are left alone.
+var builtinRedactRules = []redactRule{
+	newRedactRule("jwt", `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`, false),
+	newRedactRule("api_key", `\b(?:sk-(?:proj-|ant-|svcacct-)?[A-Za-z0-9_-]{20,}|gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,}|AIza[0-9A-Za-z_-]{35}|xox[abprs]-[A-Za-z0-9-]{10,})`, false),
+	newRedactRule("aws_access_key", `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`, false),
+	// A secret access key is 40 base64 characters, which a SHA-1 in hex also
+	// is, so only those assigned to a key with the usual name are redacted
+	newRedactRule("aws_secret_key", `(?i)(\baws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+]{40}`, true),
+	newRedactRule("token", `(\b[Bb]earer\s+)[A-Za-z0-9._~+/-]{20,}=*`, true),
+	newRedactRule("email", `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`, false),
+}
+
+// redactor replaces sensitive content in log entries
+type redactor struct {
+	rules []redactRule
+}
+
+// loadedRedactor is the outcome of loading the redactor of a config
+type loadedRedactor struct {
+	r   *redactor
+	err error
+}
+
+// Guards the redactor of every config, which the workers of a batch may
+// load at the same time
+var logRedactorMu sync.Mutex
+
+// logRedactor returns the redactor of the log entries written with c. It is
+// loaded with the first entry and reused, so that the entries of a batch
+// don't each read and compile redact.rules again.
+func (c *Config) logRedactor() (*redactor, error) {
+	logRedactorMu.Lock()
+	defer logRedactorMu.Unlock()
+
+	if c.redactor == nil {
+		r, err := loadRedactor(c.ConfigDir)
+		c.redactor = &loadedRedactor{r: r, err: err}
+	}
+	return c.redactor.r, c.redactor.err
+}
+
+// loadRedactor returns the built-in rules followed by those of redact.rules
+// in configDir, if it exists. Blank lines and lines starting with # are
+// skipped.
+func loadRedactor(configDir string) (*redactor, error) {
+	r := &redactor{rules: builtinRedactRules}
+
+	path := filepath.Join(configDir, redactRulesFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", redactRulesFile, err)
+	}
+
+	// Copy the built-in rules so appending never touches the shared slice
+	r.rules = append([]redactRule(nil), builtinRedactRules...)
+	for i, line := range strings.Split(string(data), "\n") {
+		pattern := strings.TrimSpace(line)
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: invalid pattern: %w", path, i+1, err)
+		}
+		if re.MatchString("") {
+			return nil, fmt.Errorf("%s line %d: the pattern %q matches empty text", path, i+1, pattern)
+		}
+		r.rules = append(r.rules, redactRule{Type: redactCustomType, re: re, replacement: "[REDACTED:" + redactCustomType + "]"})
+	}
+	return r, nil
+}
+
+// redactString returns s with the matches of every rule replaced, adding
+// the number of matches of each type to found if it isn't nil
+func (r *redactor) redactString(s string, found map[string]int) string {
+	if s == "" {
+		return s
+	}
+	for _, rule := range r.rules {
+		matches := len(rule.re.FindAllStringIndex(s, -1))
+		if matches == 0 {
+			continue
+		}
+		if found != nil {
+			found[rule.Type] += matches
+		}
+		s = rule.re.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
+
+// redactEntry redacts the text fields of entry: the prompt, response and
+// error, the expected answer and the arguments and errors of tool calls.
+// It returns the number of matches of each type.
+func (r *redactor) redactEntry(entry *LogEntry) map[string]int {
+	found := map[string]int{}
+	entry.Prompt = r.redactString(entry.Prompt, found)
+	entry.Response = r.redactString(entry.Response, found)
+	entry.Error = r.redactString(entry.Error, found)
+	entry.Expect = r.redactString(entry.Expect, found)
+	entry.Exec = r.redactString(entry.Exec, found)
+
+	// The tool calls are copied, since the caller's slice may still be in use
+	if len(entry.ToolCalls) > 0 {
+		toolCalls := make([]ToolRun, len(entry.ToolCalls))
+		for i, run := range entry.ToolCalls {
+			run.Arguments = r.redactString(run.Arguments, found)
+			run.Error = r.redactString(run.Error, found)
+			toolCalls[i] = run
+		}
+		entry.ToolCalls = toolCalls
+	}
+	return found
+}
+
+// redactScan is what logs scan found: the number of entries read, how many
+// have content that redaction would replace, and of those how many have
+// each type
+type redactScan struct {
+	Entries  int            `json:"entries"`
+	Matching int            `json:"matching"`
+	Types    map[string]int `json:"types"`
+}
+
+// logsScanCommand reports how many log entries contain content redaction
+// would replace, without changing the logs
+func logsScanCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli logs scan", args[0])
+	}
+
+	r, err := loadRedactor(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to list logs: %w", err)
+	}
+
+	scan := redactScan{Types: map[string]int{}}
+	skipped, err := forEachLogEntry(logFiles, func(entry LogEntry) error {
+		scan.Entries++
+		found := r.redactEntry(&entry)
+		if len(found) > 0 {
+			scan.Matching++
+		}
+		for ruleType := range found {
+			scan.Types[ruleType]++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	defer reportCorruptLogLines(newUI(config, os.Stderr), skipped)
+
+	if config.Output == outputJSON {
+		return printJSON(scan)
+	}
+
+	out := newUI(config, os.Stdout)
+	if scan.Matching == 0 {
+		out.Printf("No sensitive content found in %d log %s.\n", scan.Entries, plural(scan.Entries, "entry", "entries"))
+		return nil
+	}
+
+	types := make([]string, 0, len(scan.Types))
+	for ruleType := range scan.Types {
+		types = append(types, ruleType)
+	}
+	sort.Strings(types)
+
+	out.Printf("%d of %d log %s contain sensitive content:\n", scan.Matching, scan.Entries, plural(scan.Entries, "entry", "entries"))
+	for _, ruleType := range types {
+		out.Printf("  %-16s %d\n", ruleType, scan.Types[ruleType])
+	}
+	out.Println("Run 'chatgpt-cli logs scrub' to redact them.")
+	return nil
+}
+
+// logsScrubCommand rewrites the log files with redaction applied. Each
+// rewritten file is first copied to <file>.bak.
+func logsScrubCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli logs scrub", args[0])
+	}
+
+	r, err := loadRedactor(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to list logs: %w", err)
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	out := newUI(config, os.Stdout)
+	scrubbed := 0
+	for _, logFile := range logFiles {
+		redacted, err := scrubLogFile(logFile, r)
+		if err != nil {
+			return fmt.Errorf("failed to scrub %s: %w", filepath.Base(logFile), err)
+		}
+		if redacted > 0 {
+			out.Printf("Redacted %d %s in %s (backup: %s)\n", redacted, plural(redacted, "entry", "entries"), filepath.Base(logFile), filepath.Base(logFile)+".bak")
+			scrubbed++
+		}
+	}
+
+	if scrubbed == 0 {
+		out.Println("No sensitive content found.")
+		return nil
+	}
+	out.Println("The backups still hold the original text; delete them once you have checked the logs.")
+	return nil
+}
+
+// scrubLogFile redacts the entries of logFile and returns how many changed.
+// Lines that are not valid entries are kept as they are, and the file is
+// left untouched when no entry changes.
+func scrubLogFile(logFile string, r *redactor) (int, error) {
+	f, err := os.Open(logFile)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var scrubbed bytes.Buffer
+	redacted := 0
+
+	reader := bufio.NewReader(f)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return 0, readErr
+		}
+
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) > 0 {
+			var entry LogEntry
+			if len(trimmed) <= maxLogLineSize && json.Unmarshal(trimmed, &entry) == nil && len(r.redactEntry(&entry)) > 0 {
+				data, err := json.Marshal(entry)
+				if err != nil {
+					return 0, err
+				}
+				trimmed = data
+				redacted++
+			}
+			scrubbed.Write(trimmed)
+			scrubbed.WriteByte('\n')
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if redacted == 0 {
+		return 0, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// Windows cannot rename a file that is still open
+	f.Close()
+
+	tmp := logFile + ".tmp"
+	if err := os.WriteFile(tmp, scrubbed.Bytes(), info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(logFile, logFile+".bak"); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, logFile); err != nil {
+		return 0, err
+	}
+	return redacted, nil
+}
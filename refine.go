@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Requests made by refine: the first answers the prompt, each other one
+// critiques and improves the previous answer
+const (
+	defaultRefinePasses = 2
+	maxRefinePasses     = 5
+)
+
+// Line separating the critique from the improved answer in a refinement
+const refineMarker = "=== IMPROVED ANSWER ==="
+
+// Request sent after each answer, in the same conversation, to have it
+// critiqued and improved
+const refineRequest = "Critique your previous answer: point out errors, omissions and anything unclear. " +
+	"Then write an improved answer that fixes them.\n\n" +
+	"Reply with the critique, then a line containing only " + refineMarker + ", then the improved answer alone."
+
+// refinePass is the outcome of one refine request
+type refinePass struct {
+	// Critique is empty for the first answer
+	Critique string
+	Answer   string
+	Response *ChatResponse
+}
+
+// RefinePassOutput is one pass of refine --show-steps in JSON output
+type RefinePassOutput struct {
+	Pass     int    `json:"pass"`
+	Critique string `json:"critique,omitempty"`
+	Answer   string `json:"answer"`
+	Usage    Usage  `json:"usage"`
+}
+
+// RefineOutput is the JSON output of refine: the final answer with the usage
+// of all passes, and each pass with --show-steps
+type RefineOutput struct {
+	PromptOutput
+	Passes []RefinePassOutput `json:"passes,omitempty"`
+}
+
+// refineCommand answers a prompt, then has the model critique and improve
+// its own answer
+func refineCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli refine [--passes N] [--show-steps] [--usage] [--raw] \"your prompt here\""
+
+	fs := flag.NewFlagSet("refine", flag.ContinueOnError)
+	passes := fs.Int("passes", defaultRefinePasses, fmt.Sprintf("requests made, the first answering and the others improving the answer (at most %d)", maxRefinePasses))
+	showSteps := fs.Bool("show-steps", false, "print every answer and critique, not just the final answer")
+	showUsage := fs.Bool("usage", config.ShowUsage, "print the token usage and estimated cost of all passes")
+	raw := fs.Bool("raw", false, "print the answer as-is instead of rendering markdown")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if *passes < 2 || *passes > maxRefinePasses {
+		return usageErrorf("--passes must be between 2 and %d", maxRefinePasses)
+	}
+
+	prompt := strings.Join(args, " ")
+	if strings.TrimSpace(prompt) == "" {
+		return usageErrorf("prompt text is required\n%s", usage)
+	}
+
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	steps, err := runRefine(newAPIClient(config), prompt, *passes)
+	if err != nil {
+		return err
+	}
+
+	// Usage is summed over the passes
+	var total Usage
+	for _, step := range steps {
+		total.add(step.Response.Usage)
+	}
+	final := steps[len(steps)-1]
+
+	if config.Output == outputJSON {
+		output := RefineOutput{PromptOutput: newPromptOutput(final.Response, final.Answer)}
+		output.Usage = total
+		if *showSteps {
+			for i, step := range steps {
+				output.Passes = append(output.Passes, RefinePassOutput{Pass: i + 1, Critique: step.Critique, Answer: step.Answer, Usage: step.Response.Usage})
+			}
+		}
+		return printJSON(output)
+	}
+
+	out := newUI(config, os.Stdout)
+	render := !*raw && isTerminal(os.Stdout)
+	display := func(text string) {
+		if render {
+			text = renderMarkdown(text, colorEnabled(config))
+		}
+		out.Println(text)
+	}
+
+	if *showSteps {
+		for i, step := range steps {
+			if i > 0 {
+				out.Println()
+				out.heading(fmt.Sprintf("Pass %d: critique", i+1))
+				display(step.Critique)
+				out.Println()
+			}
+			title := fmt.Sprintf("Pass %d: improved answer", i+1)
+			if i == 0 {
+				title = "Pass 1: answer"
+			}
+			out.heading(title)
+			display(step.Answer)
+		}
+	} else {
+		display(final.Answer)
+	}
+
+	if *showUsage {
+		fmt.Fprintf(os.Stderr, "%s, %d passes\n", formatUsage(responseModel(config, final.Response), total), len(steps))
+	}
+	return nil
+}
+
+// runRefine sends the prompt, then asks passes-1 times for the answer to be
+// improved, in one conversation so that each critique sees what came before.
+// Each pass is logged on its own, with its index.
+func runRefine(client *APIClient, prompt string, passes int) ([]refinePass, error) {
+	config := client.config
+	messages := promptMessages(config, prompt)
+	var steps []refinePass
+
+	for pass := 1; pass <= passes; pass++ {
+		if pass > 1 {
+			messages = append(messages, Message{Role: "user", Content: refineRequest})
+		}
+
+		start := time.Now()
+		response, err := client.Chat(config.requestContext(), messages)
+		if isCancelled(err) {
+			warnLogError(config, writeLogEntry(config, LogEntry{Timestamp: time.Now(), Command: "refine", Prompt: prompt, Error: cancelledLogMessage, Pass: pass}))
+			return nil, errCancelled
+		}
+		if err != nil {
+			warnLogError(config, writeLogEntry(config, LogEntry{Timestamp: time.Now(), Command: "refine", Prompt: prompt, Error: err.Error(), Pass: pass, RequestID: errorRequestID(err)}))
+			return nil, fmt.Errorf("failed to get response for pass %d: %w", pass, err)
+		}
+
+		reply := formatResponse(response)
+		messages = append(messages, Message{Role: "assistant", Content: reply})
+
+		step := refinePass{Answer: reply, Response: response}
+		if pass > 1 {
+			step.Critique, step.Answer = splitRefinement(reply)
+		}
+		steps = append(steps, step)
+
+		warnLogError(config, writeLogEntry(config, LogEntry{
+			Timestamp: time.Now(),
+			Command:   "refine",
+			Prompt:    prompt,
+			Response:  reply,
+			Usage:     usageOrNil(response.Usage),
+			Model:     responseModel(config, response),
+			LatencyMs: time.Since(start).Milliseconds(),
+			Pass:      pass,
+			RequestID: response.RequestID,
+		}))
+	}
+
+	return steps, nil
+}
+
+// splitRefinement splits a refinement into the critique and the improved
+// answer. A reply without the marker line is taken as the answer alone.
+func splitRefinement(reply string) (critique, answer string) {
+	before, after, found := strings.Cut(reply, refineMarker)
+	if !found {
+		return "", strings.TrimSpace(reply)
+	}
+	return strings.TrimSpace(before), strings.TrimSpace(after)
+}
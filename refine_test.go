@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRefineServer answers each request with the next reply and 10 prompt
+// and 5 completion tokens, recording the conversations it was sent
+func newRefineServer(t *testing.T, replies []string) (*httptest.Server, *[][]Message) {
+	t.Helper()
+	var conversations [][]Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		conversations = append(conversations, request.Messages)
+		if len(conversations) > len(replies) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		reply, _ := json.Marshal(replies[len(conversations)-1])
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"model":"gpt-4o","choices":[{"message":{"role":"assistant","content":%s}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`, reply)
+	}))
+	t.Cleanup(server.Close)
+	return server, &conversations
+}
+
+// newRefineConfig returns a config sending to server and logging to a
+// temporary directory
+func newRefineConfig(t *testing.T, server *httptest.Server) *Config {
+	return &Config{
+		APIKey:      "sk-test",
+		APIURL:      server.URL,
+		Model:       "gpt-4o",
+		MaxTokens:   100,
+		Temperature: defaultTemperature,
+		Timeout:     5 * time.Second,
+		ConfigDir:   t.TempDir(),
+		Output:      outputPlain,
+	}
+}
+
+// TestRefineCommand tests that the critique sees the first answer and only
+// the improved answer is printed
+func TestRefineCommand(t *testing.T) {
+	server, conversations := newRefineServer(t, []string{
+		"Go is a language.",
+		"Too short.\n" + refineMarker + "\nGo is a compiled, statically typed language.",
+	})
+	config := newRefineConfig(t, server)
+
+	var err error
+	stdout := captureOutput(t, &os.Stdout, func() {
+		err = refineCommand(config, []string{"What is Go?"})
+	})
+	if err != nil {
+		t.Fatalf("refineCommand() error = %v", err)
+	}
+	if stdout != "Go is a compiled, statically typed language.\n" {
+		t.Errorf("stdout = %q, want the improved answer only", stdout)
+	}
+
+	if len(*conversations) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(*conversations))
+	}
+	second := (*conversations)[1]
+	if len(second) != 3 || second[0].Content != "What is Go?" || second[1].Role != "assistant" || second[1].Content != "Go is a language." || second[2].Content != refineRequest {
+		t.Errorf("second request messages = %+v, want the prompt, the answer and the critique request", second)
+	}
+
+	entries := readTestLogEntries(t, config.ConfigDir)
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want one per pass", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Command != "refine" || entry.Pass != i+1 || entry.Prompt != "What is Go?" || entry.Usage == nil || entry.Usage.TotalTokens != 15 {
+			t.Errorf("entry %d = %+v, want pass %d of the prompt with its usage", i, entry, i+1)
+		}
+	}
+}
+
+// TestRefineCommandShowSteps tests --show-steps, --passes and the usage
+// summed over all passes
+func TestRefineCommandShowSteps(t *testing.T) {
+	server, conversations := newRefineServer(t, []string{
+		"First.",
+		"Vague.\n" + refineMarker + "\nSecond.",
+		"Still vague.\n" + refineMarker + "\nThird.",
+	})
+	config := newRefineConfig(t, server)
+
+	var stdout string
+	stderr := captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, func() {
+			if err := refineCommand(config, []string{"--passes", "3", "--show-steps", "--usage", "Explain"}); err != nil {
+				t.Fatalf("refineCommand() error = %v", err)
+			}
+		})
+	})
+
+	if len((*conversations)[2]) != 5 {
+		t.Errorf("third request has %d messages, want the whole conversation", len((*conversations)[2]))
+	}
+	for _, want := range []string{"Pass 1: answer\n" + headingRule + "\nFirst.", "Pass 2: critique\n" + headingRule + "\nVague.", "Pass 3: improved answer\n" + headingRule + "\nThird."} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout missing %q:\n%s", want, stdout)
+		}
+	}
+	if !strings.Contains(stderr, "Tokens: 30 prompt + 15 completion = 45 total") || !strings.HasSuffix(stderr, ", 3 passes\n") {
+		t.Errorf("stderr = %q, want the usage of the 3 passes", stderr)
+	}
+}
+
+// TestRefineCommandJSON tests the JSON output with --show-steps
+func TestRefineCommandJSON(t *testing.T) {
+	server, _ := newRefineServer(t, []string{"First.", "No marker, just a better answer."})
+	config := newRefineConfig(t, server)
+	config.Output = outputJSON
+
+	stdout := captureOutput(t, &os.Stdout, func() {
+		if err := refineCommand(config, []string{"--show-steps", "Explain"}); err != nil {
+			t.Fatalf("refineCommand() error = %v", err)
+		}
+	})
+
+	var output RefineOutput
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, stdout)
+	}
+	if output.Content != "No marker, just a better answer." || output.Usage.TotalTokens != 30 || len(output.Passes) != 2 || output.Passes[1].Critique != "" {
+		t.Errorf("output = %+v", output)
+	}
+}
+
+// TestRefineCommandFailedPass tests that a failed pass is logged with its
+// index after the passes that succeeded
+func TestRefineCommandFailedPass(t *testing.T) {
+	server, _ := newRefineServer(t, []string{"First."})
+	config := newRefineConfig(t, server)
+
+	var err error
+	captureOutput(t, &os.Stdout, func() {
+		err = refineCommand(config, []string{"Explain"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "pass 2") {
+		t.Fatalf("refineCommand() error = %v, want the failed pass", err)
+	}
+
+	entries := readTestLogEntries(t, config.ConfigDir)
+	if len(entries) != 2 || entries[1].Pass != 2 || entries[1].Error == "" {
+		t.Errorf("entries = %+v, want the failed pass 2 logged", entries)
+	}
+}
+
+// TestRefineCommandUsageErrors tests the validation of the arguments
+func TestRefineCommandUsageErrors(t *testing.T) {
+	config := &Config{APIKey: "sk-test"}
+	for _, args := range [][]string{{"--passes", "1", "x"}, {"--passes", "6", "x"}, {}, {"  "}} {
+		if err := refineCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("refineCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
+
+// TestSplitRefinement tests separating the critique from the answer
+func TestSplitRefinement(t *testing.T) {
+	critique, answer := splitRefinement("Wrong date.\n\n" + refineMarker + "\n\nIt was 2009.\n")
+	if critique != "Wrong date." || answer != "It was 2009." {
+		t.Errorf("splitRefinement() = %q, %q", critique, answer)
+	}
+
+	critique, answer = splitRefinement("  Just an answer.\n")
+	if critique != "" || answer != "Just an answer." {
+		t.Errorf("splitRefinement() without marker = %q, %q", critique, answer)
+	}
+}
+
+// TestHistorySkipsRefinements tests that history lists a refined prompt once
+func TestHistorySkipsRefinements(t *testing.T) {
+	config := &Config{ConfigDir: t.TempDir()}
+	for pass := 1; pass <= 3; pass++ {
+		if err := writeLogEntry(config, LogEntry{Timestamp: time.Now(), Command: "refine", Prompt: "Explain", Response: "r", Pass: pass}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := readHistory(config.ConfigDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Pass != 1 {
+		t.Errorf("history = %+v, want the first pass only", history)
+	}
+}
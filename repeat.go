@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Most times prompt --count may send a prompt
+const maxRepeatCount = 50
+
+// RepeatOutput is the JSON output of prompt --count
+type RepeatOutput struct {
+	Seed *int           `json:"seed,omitempty"`
+	Runs []PromptOutput `json:"runs"`
+	// Identical is the most answers that were byte-identical, and
+	// Distinct how many different answers there were
+	Identical int `json:"identical"`
+	Distinct  int `json:"distinct"`
+}
+
+// repeatRun is a prompt sent several times in a row by prompt --count
+type repeatRun struct {
+	count  int
+	prompt string
+	// entry is what each repetition is logged with, besides its reply
+	entry     LogEntry
+	output    io.Writer
+	render    bool
+	quiet     bool
+	showUsage bool
+	timing    bool
+}
+
+// repeatPrompt sends the prompt of run as many times as asked, printing each
+// answer under its index as it arrives, then how many were byte-identical.
+// Each repetition is logged with the seed and its index.
+func repeatPrompt(config *Config, run repeatRun) error {
+	client := newAPIClient(config)
+	messages := promptMessages(config, run.prompt)
+
+	var replies []string
+	var runs []PromptOutput
+	for i := 1; i <= run.count; i++ {
+		entry := run.entry
+		entry.Seed = config.Seed
+		entry.Run = i
+
+		stopProgress := startProgress(config)
+		start := time.Now()
+		response, err := client.Chat(config.requestContext(), messages)
+		stopProgress()
+		if isCancelled(err) {
+			entry.Timestamp, entry.Error = time.Now(), cancelledLogMessage
+			warnLogError(config, writeLogEntry(config, entry))
+			return errCancelled
+		}
+		if err != nil {
+			entry.Timestamp, entry.Error, entry.RequestID = time.Now(), err.Error(), errorRequestID(err)
+			warnLogError(config, writeLogEntry(config, entry))
+			return fmt.Errorf("failed to get response %d of %d: %w", i, run.count, err)
+		}
+		latency := time.Since(start)
+
+		reply := formatChoices(response, 1)
+		entry.Timestamp = time.Now()
+		entry.Response = reply
+		entry.Usage = usageOrNil(response.Usage)
+		entry.Model = responseModel(config, response)
+		entry.LatencyMs = latency.Milliseconds()
+		entry.RequestID = response.RequestID
+		warnLogError(config, writeLogEntry(config, entry))
+		replies = append(replies, reply)
+
+		if config.Output == outputJSON {
+			runs = append(runs, newPromptOutput(response, reply))
+			continue
+		}
+		if !run.quiet {
+			if i > 1 {
+				fmt.Fprintf(run.output, "\n%s\n\n", choiceSeparator)
+			}
+			fmt.Fprintf(run.output, "[%d]\n", i)
+			if run.render {
+				fmt.Fprintln(run.output, renderMarkdown(reply, colorEnabled(config)))
+			} else {
+				fmt.Fprintln(run.output, reply)
+			}
+		}
+		if run.showUsage {
+			fmt.Fprintln(os.Stderr, formatResponseUsage(config, response))
+		}
+		if run.timing {
+			fmt.Fprintln(os.Stderr, formatTiming(latency, response.Usage))
+		}
+	}
+
+	identical, distinct := countIdentical(replies)
+	if config.Output == outputJSON {
+		return writeJSON(run.output, RepeatOutput{Seed: config.Seed, Runs: runs, Identical: identical, Distinct: distinct})
+	}
+	if !run.quiet {
+		fmt.Fprintln(run.output)
+	}
+	fmt.Fprintln(os.Stderr, formatIdentical(identical, distinct, len(replies)))
+	return nil
+}
+
+// countIdentical returns the size of the largest group of byte-identical
+// replies, and how many different replies there are
+func countIdentical(replies []string) (identical, distinct int) {
+	counts := make(map[string]int)
+	for _, reply := range replies {
+		counts[reply]++
+		if counts[reply] > identical {
+			identical = counts[reply]
+		}
+	}
+	return identical, len(counts)
+}
+
+// formatIdentical formats the line telling how deterministic the repeated
+// answers were
+func formatIdentical(identical, distinct, total int) string {
+	switch {
+	case identical == total:
+		return fmt.Sprintf("All %d outputs are identical", total)
+	case identical == 1:
+		return fmt.Sprintf("All %d outputs differ", total)
+	default:
+		return fmt.Sprintf("%d of %d outputs are identical (%d distinct)", identical, total, distinct)
+	}
+}
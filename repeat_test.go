@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFormatIdentical tests the line reporting how many repeated answers
+// were byte-identical
+func TestFormatIdentical(t *testing.T) {
+	tests := []struct {
+		replies []string
+		want    string
+	}{
+		{[]string{"a", "a", "a"}, "All 3 outputs are identical"},
+		{[]string{"a", "b", "c"}, "All 3 outputs differ"},
+		{[]string{"a", "b", "a", "c", "a"}, "3 of 5 outputs are identical (3 distinct)"},
+		// Byte-identical: whitespace and case count
+		{[]string{"a", "A", "a "}, "All 3 outputs differ"},
+	}
+	for _, tt := range tests {
+		identical, distinct := countIdentical(tt.replies)
+		if got := formatIdentical(identical, distinct, len(tt.replies)); got != tt.want {
+			t.Errorf("formatIdentical(%q) = %q, want %q", tt.replies, got, tt.want)
+		}
+	}
+}
+
+// TestPromptCommandCount tests that --count sends the prompt with the seed
+// once per repetition, prints each answer and logs each with its index
+func TestPromptCommandCount(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var seeds []string
+	replies := []string{"4", "4", "four"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		seeds = append(seeds, string(body["seed"]))
+		reply := replies[len(seeds)-1]
+		fmt.Fprintf(w, `{"system_fingerprint":"fp_abc","choices":[{"message":{"role":"assistant","content":%q}}],"usage":{"prompt_tokens":5,"completion_tokens":1,"total_tokens":6}}`, reply)
+	}))
+	defer server.Close()
+
+	configDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: configDir, NoColor: true}
+
+	var errOut string
+	out := captureOutput(t, &os.Stdout, func() {
+		errOut = captureOutput(t, &os.Stderr, func() {
+			if err := promptCommand(config, []string{"--count", "3", "--seed", "42", "--usage", "2+2?"}); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+
+	if strings.Join(seeds, ",") != "42,42,42" {
+		t.Errorf("seeds sent = %q, want 42 each time", seeds)
+	}
+	want := "[1]\n4\n\n" + choiceSeparator + "\n\n[2]\n4\n\n" + choiceSeparator + "\n\n[3]\nfour\n\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+	if strings.Count(errOut, ", fingerprint fp_abc\n") != 3 || !strings.HasSuffix(errOut, "2 of 3 outputs are identical (2 distinct)\n") {
+		t.Errorf("stderr = %q, want the fingerprints and the identical count", errOut)
+	}
+
+	entries := readTestLogEntries(t, configDir)
+	if len(entries) != 3 {
+		t.Fatalf("got %d log entries, want 3", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Run != i+1 || entry.Seed == nil || *entry.Seed != 42 || entry.Response != replies[i] {
+			t.Errorf("log entry %d = %+v, want run %d with seed 42", i, entry, i+1)
+		}
+	}
+
+	config.Output, config.Seed = outputJSON, nil
+	seeds = nil
+	out = captureOutput(t, &os.Stdout, func() {
+		captureOutput(t, &os.Stderr, func() {
+			if err := promptCommand(config, []string{"--count", "2", "2+2?"}); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+	var result RepeatOutput
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("JSON output = %q: %v", out, err)
+	}
+	if len(result.Runs) != 2 || result.Identical != 2 || result.Distinct != 1 || result.Runs[0].SystemFingerprint != "fp_abc" {
+		t.Errorf("JSON output = %+v", result)
+	}
+	if seeds[0] != "" {
+		t.Errorf("seed sent = %s, want none without --seed", seeds[0])
+	}
+}
+
+// TestPromptCommandCountUsage tests the flags --count can't be combined with
+func TestPromptCommandCountUsage(t *testing.T) {
+	config := &Config{APIKey: "test-key", Model: "gpt-4o", ConfigDir: t.TempDir()}
+	for _, args := range [][]string{
+		{"--count", "0", "hi"},
+		{"--count", "51", "hi"},
+		{"--count", "2", "--n", "2", "hi"},
+		{"--count", "2", "--expect", "4", "hi"},
+		{"--count", "2", "--extract", "code", "hi"},
+	} {
+		if err := promptCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("promptCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+
+	config.Provider = providerAnthropic
+	if err := promptCommand(config, []string{"--seed", "1", "hi"}); !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), "--seed is not supported") {
+		t.Errorf("promptCommand(--seed) with Anthropic error = %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Response headers holding the ID the provider gave a request: OpenAI's,
+// then Anthropic's
+var requestIDHeaders = []string{"X-Request-Id", "Request-Id"}
+
+// invocationID identifies this run of the CLI in every log entry it writes,
+// so that the requests of one batch, compare or review can be grouped
+var invocationID = newInvocationID()
+
+// newInvocationID returns a random version 4 UUID
+func newInvocationID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// responseRequestID returns the ID the provider gave the request of resp,
+// or "" if it sent none
+func responseRequestID(header http.Header) string {
+	for _, name := range requestIDHeaders {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// withRequestID records the ID of the request resp answers on the response
+// read from it, or on the API error it failed with, so that it can be logged
+// and quoted to support
+func withRequestID(resp *http.Response, response *ChatResponse, err error) (*ChatResponse, error) {
+	id := responseRequestID(resp.Header)
+	var failure *APIFailure
+	if errors.As(err, &failure) && failure.RequestID == "" {
+		failure.RequestID = id
+	}
+	if response != nil {
+		response.RequestID = id
+	}
+	return response, err
+}
+
+// errorRequestID returns the ID of the request an API error answers, or ""
+func errorRequestID(err error) string {
+	var failure *APIFailure
+	if errors.As(err, &failure) {
+		return failure.RequestID
+	}
+	return ""
+}
+
+// LogGroup is the entries written by one run of the CLI, as listed by
+// logs --group
+type LogGroup struct {
+	Invocation string     `json:"invocation"`
+	Entries    []LogEntry `json:"entries"`
+	// The numbers of the entries in the logs listing
+	numbers []int
+}
+
+// groupLogEntries groups entries by the run of the CLI that wrote them,
+// ordered by their first entry. Entries logged before runs were recorded
+// each make a group of their own.
+func groupLogEntries(entries []LogEntry) []LogGroup {
+	var groups []LogGroup
+	index := make(map[string]int)
+	for i, entry := range entries {
+		g, ok := index[entry.Invocation]
+		if !ok || entry.Invocation == "" {
+			g = len(groups)
+			index[entry.Invocation] = g
+			groups = append(groups, LogGroup{Invocation: entry.Invocation})
+		}
+		groups[g].Entries = append(groups[g].Entries, entry)
+		groups[g].numbers = append(groups[g].numbers, i+1)
+	}
+	return groups
+}
+
+// printLogGroups prints a table summing up the runs, then the entries of each
+// group under a heading naming the run and its commands. Entries keep their
+// numbers in the logs listing, for logs show.
+func printLogGroups(out *ui, groups []LogGroup, previewLen int, times timestampFormat) {
+	var entries []LogEntry
+	for _, group := range groups {
+		entries = append(entries, group.Entries...)
+	}
+	out.Printf("Showing %d log entries from %d runs:\n\n", len(entries), len(groups))
+
+	summary := newTable("RUN", "COMMANDS", "ENTRIES", "TOKENS")
+	for _, group := range groups {
+		var tokens int
+		for _, entry := range group.Entries {
+			if entry.Usage != nil {
+				tokens += entry.Usage.TotalTokens
+			}
+		}
+		summary.addRow(logGroupID(group), strings.Join(logGroupCommands(group), ", "), strconv.Itoa(len(group.Entries)), strconv.Itoa(tokens))
+	}
+	summary.render(out)
+	out.Println()
+
+	for _, group := range groups {
+		noun := "entries"
+		if len(group.Entries) == 1 {
+			noun = "entry"
+		}
+		out.heading(fmt.Sprintf("Run %s: %s, %d %s", logGroupID(group), strings.Join(logGroupCommands(group), ", "), len(group.Entries), noun))
+		for i, entry := range group.Entries {
+			printLogEntry(out, group.numbers[i], entry, previewLen, false, times)
+			out.Println()
+		}
+	}
+	printTotalTokens(out, entries)
+}
+
+// logGroupID returns the short form of the invocation ID of a group
+func logGroupID(group LogGroup) string {
+	switch id := group.Invocation; {
+	case id == "":
+		return "not recorded"
+	case len(id) > 8:
+		return id[:8]
+	default:
+		return id
+	}
+}
+
+// logGroupCommands returns the commands of a group's entries, with runs of
+// the same command listed once
+func logGroupCommands(group LogGroup) []string {
+	var commands []string
+	for _, entry := range group.Entries {
+		if len(commands) == 0 || commands[len(commands)-1] != entry.Command {
+			commands = append(commands, entry.Command)
+		}
+	}
+	return commands
+}
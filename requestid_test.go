@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNewInvocationID tests that invocation IDs are random version 4 UUIDs
+func TestNewInvocationID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := newInvocationID(), newInvocationID()
+	if !uuid.MatchString(a) || a == b {
+		t.Errorf("newInvocationID() = %q, %q, want two different UUIDs", a, b)
+	}
+}
+
+// TestAPIClientRequestID tests that the request ID header is kept on the
+// response, streamed or not, and on API errors
+func TestAPIClientRequestID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req_"+r.URL.Query().Get("case"))
+		switch r.URL.Query().Get("case") {
+		case "error":
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`)
+		case "stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n")
+		default:
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	config := &Config{APIKey: "test-key", APIURL: server.URL + "?case=ok", Model: "gpt-4o", Timeout: 10 * time.Second}
+	response, err := newAPIClient(config).Chat(context.Background(), promptMessages(config, "hi"))
+	if err != nil || response.RequestID != "req_ok" {
+		t.Errorf("Chat() = %+v, %v, want request id req_ok", response, err)
+	}
+
+	config.APIURL = server.URL + "?case=stream"
+	var out strings.Builder
+	response, err = newAPIClient(config).ChatStream(context.Background(), promptMessages(config, "hi"), &out)
+	if err != nil || response.RequestID != "req_stream" {
+		t.Errorf("ChatStream() = %+v, %v, want request id req_stream", response, err)
+	}
+
+	config.APIURL = server.URL + "?case=error"
+	_, err = newAPIClient(config).Chat(context.Background(), promptMessages(config, "hi"))
+	if errorRequestID(err) != "req_error" || !strings.Contains(fmt.Sprint(err), "\nrequest id: req_error — include this when contacting support") {
+		t.Errorf("Chat() error = %v, want the request id", err)
+	}
+}
+
+// TestPromptCommandLogsRequestID tests that the request ID and the run of
+// the CLI are logged with failed and successful prompts
+func TestPromptCommandLogsRequestID(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.Header().Set("X-Request-Id", "req_abc123")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":{"message":"The server had an error","type":"server_error"}}`)
+			return
+		}
+		w.Header().Set("X-Request-Id", "req_def456")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	configDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: configDir, NoColor: true}
+
+	err := promptCommand(config, []string{"--no-stream", "hi"})
+	if err == nil || !strings.Contains(err.Error(), "request id: req_abc123") {
+		t.Errorf("promptCommand() error = %v, want the request id", err)
+	}
+	fail = false
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "hi"}); err != nil {
+			t.Errorf("promptCommand() error = %v", err)
+		}
+	})
+
+	entries := readTestLogEntries(t, configDir)
+	if len(entries) != 2 || entries[0].RequestID != "req_abc123" || entries[1].RequestID != "req_def456" {
+		t.Fatalf("log entries = %+v, want the request ids", entries)
+	}
+	if entries[0].Invocation != invocationID || entries[1].Invocation != invocationID {
+		t.Errorf("invocations = %q, %q, want %q", entries[0].Invocation, entries[1].Invocation, invocationID)
+	}
+
+	// The request ID of a failure is shown in the listing
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, nil); err != nil {
+			t.Errorf("logsCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Request ID: req_abc123") || strings.Contains(out, "req_def456") {
+		t.Errorf("logs output = %q, want only the failure's request id", out)
+	}
+}
+
+// TestLogsCommandGroup tests listing entries grouped by the run that wrote them
+func TestLogsCommandGroup(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	configDir := t.TempDir()
+	now := time.Now()
+	batch := "0f8e2a4c-1111-4222-8333-444455556666"
+	compare := "7b9d1e3f-1111-4222-8333-444455556666"
+	writeTestLogs(t, filepath.Join(configDir, "logs.jsonl"), []LogEntry{
+		{Timestamp: now, Command: "batch", Prompt: "one", Response: "1", Invocation: batch},
+		{Timestamp: now, Command: "compare", Prompt: "hi", Response: "a", Invocation: compare},
+		{Timestamp: now, Command: "batch", Prompt: "two", Response: "2", Invocation: batch},
+		{Timestamp: now, Command: "prompt", Prompt: "old", Response: "x"},
+	})
+	config := &Config{ConfigDir: configDir, NoColor: true}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"--group"}); err != nil {
+			t.Errorf("logsCommand(--group) error = %v", err)
+		}
+	})
+	for _, want := range []string{
+		"Showing 4 log entries from 3 runs:",
+		"RUN\tCOMMANDS\tENTRIES\tTOKENS\n0f8e2a4c\tbatch\t2\t0\n",
+		"Run 0f8e2a4c: batch, 2 entries\n",
+		"Run 7b9d1e3f: compare, 1 entry\n",
+		"Run not recorded: prompt, 1 entry\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logs --group output = %q, want %q", out, want)
+		}
+	}
+	// Entries keep their numbers in the listing, for logs show
+	if first, third, second := strings.Index(out, "[1]"), strings.Index(out, "[3]"), strings.Index(out, "[2]"); first < 0 || !(first < third && third < second) {
+		t.Errorf("logs --group output = %q, want [1] and [3] together before [2]", out)
+	}
+
+	config.Output = outputJSON
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"--group"}); err != nil {
+			t.Errorf("logsCommand(--group) error = %v", err)
+		}
+	})
+	var groups []LogGroup
+	if err := json.Unmarshal([]byte(out), &groups); err != nil {
+		t.Fatalf("logs --group JSON = %q: %v", out, err)
+	}
+	if len(groups) != 3 || groups[0].Invocation != batch || len(groups[0].Entries) != 2 {
+		t.Errorf("groups = %+v", groups)
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Largest chunk of a diff sent in one review request, in estimated tokens
+const defaultReviewChunkTokens = 12000
+
+// Instructions added to the review prompt by review --focus
+var reviewFocuses = map[string]string{
+	"bugs":     "Focus on bugs: logic errors, unhandled errors, missed edge cases, concurrency problems and resource leaks.",
+	"security": "Focus on security: injection, unsafe handling of input, secrets in the code, authentication and authorization mistakes and unsafe defaults.",
+	"style":    "Focus on style: naming, structure, duplication, comments and consistency with the surrounding code.",
+}
+
+// reviewInstructions is the system prompt of each chunk of a review
+const reviewInstructions = `You review code changes. You are given part of the output of git diff; other files of the change may be reviewed separately. Report problems in the added and changed lines only, each once, and nothing that is fine. Reply with a JSON object only, of the form {"findings": [{"file": "path/to/file", "line": 42, "severity": "high", "comment": "what is wrong and how to fix it"}]}, where file is the path in the diff, line is the line number in the new version of the file or 0 if none applies, and severity is high, medium or low. Reply {"findings": []} if there is nothing to report.`
+
+// ReviewFinding is one problem reported by a review
+type ReviewFinding struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Comment  string `json:"comment"`
+	// Chunk is the 1-based index of the chunk of the diff it came from
+	Chunk int `json:"chunk"`
+}
+
+// ReviewReport is the merged result of reviewing all chunks of a diff
+type ReviewReport struct {
+	Diff     string          `json:"diff"`
+	Files    []string        `json:"files"`
+	Chunks   int             `json:"chunks"`
+	Findings []ReviewFinding `json:"findings"`
+	Usage    *Usage          `json:"usage,omitempty"`
+}
+
+// diffFile is the part of a diff changing one file
+type diffFile struct {
+	Path string
+	Text string
+}
+
+// diffChunk is a part of a diff small enough to be reviewed in one request
+type diffChunk struct {
+	Files []string
+	Text  string
+}
+
+// reviewCommand reviews the changes of a git diff and prints the findings
+// grouped by file
+func reviewCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli review [--staged | --range main..HEAD] [--focus security|style|bugs] [--model name] [--chunk-tokens N] [--raw]"
+
+	fs := flag.NewFlagSet("review", flag.ContinueOnError)
+	staged := fs.Bool("staged", false, "review the staged changes instead of the unstaged ones")
+	revisions := fs.String("range", "", "review the changes of a revision range, such as main..HEAD")
+	focus := fs.String("focus", "", "focus the review on security, style or bugs")
+	model := fs.String("model", "", "model to use instead of OPENAI_MODEL, with the values set for it in the config file")
+	chunkTokens := fs.Int("chunk-tokens", defaultReviewChunkTokens, "largest part of the diff sent in one request, in estimated tokens")
+	raw := fs.Bool("raw", false, "print the report as-is instead of rendering markdown")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\n%s", args[0], usage)
+	}
+	if *staged && *revisions != "" {
+		return usageErrorf("--staged and --range cannot be combined")
+	}
+	if strings.HasPrefix(*revisions, "-") {
+		return usageErrorf("invalid --range: %s", *revisions)
+	}
+	if _, ok := reviewFocuses[*focus]; *focus != "" && !ok {
+		return usageErrorf("invalid focus: %s (valid: bugs, security, style)", *focus)
+	}
+	if *chunkTokens < 1000 {
+		return usageErrorf("--chunk-tokens must be at least 1000")
+	}
+	if *model != "" {
+		useModel(config, *model)
+	}
+
+	diffArgs := []string{"diff", "--no-color", "--no-ext-diff"}
+	switch {
+	case *staged:
+		diffArgs = append(diffArgs, "--cached")
+	case *revisions != "":
+		diffArgs = append(diffArgs, *revisions)
+	}
+	described := "git " + strings.Join(append([]string{"diff"}, diffArgs[3:]...), " ")
+
+	diff, err := gitDiff(diffArgs)
+	if err != nil {
+		return err
+	}
+	files := splitDiff(diff)
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "Nothing to review: %s is empty\n", described)
+		return nil
+	}
+
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	config.SystemPrompt = reviewInstructions
+	if *focus != "" {
+		config.SystemPrompt += " " + reviewFocuses[*focus]
+	}
+	config.JSONResponse = supportsJSONResponse(config.Model)
+
+	chunks := chunkDiff(files, *chunkTokens)
+	report := ReviewReport{Diff: described, Chunks: len(chunks), Findings: []ReviewFinding{}}
+	for _, file := range files {
+		report.Files = append(report.Files, file.Path)
+	}
+
+	client := newAPIClient(config)
+	var total Usage
+	for i, chunk := range chunks {
+		findings, usage, err := reviewChunk(client, chunk, i+1, len(chunks))
+		if err != nil {
+			return err
+		}
+		total.add(usage)
+		report.Findings = append(report.Findings, findings...)
+	}
+	report.Usage = usageOrNil(total)
+
+	text := formatReview(report)
+	warnLogError(config, writeLogEntry(config, LogEntry{
+		Timestamp: time.Now(),
+		Command:   "review",
+		Prompt:    fmt.Sprintf("%s (%d files in %d chunks)", described, len(files), len(chunks)),
+		Response:  text,
+		Usage:     report.Usage,
+		Model:     config.Model,
+	}))
+
+	switch {
+	case config.Output == outputJSON:
+		return printJSON(report)
+	case !*raw && isTerminal(os.Stdout):
+		fmt.Println(renderMarkdown(text, colorEnabled(config)))
+	default:
+		fmt.Println(text)
+	}
+	return nil
+}
+
+// gitDiff runs git diff with args in the current directory, refusing to run
+// outside a git repository
+func gitDiff(args []string) (string, error) {
+	inside, err := exec.Command("git", "rev-parse", "--is-inside-work-tree").Output()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) || (err == nil && strings.TrimSpace(string(inside)) != "true"):
+		return "", usageErrorf("not inside a git repository; run review from the working tree of the repository to review")
+	case err != nil:
+		return "", fmt.Errorf("failed to run git, which review needs: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// splitDiff splits a diff into the parts changing each file
+func splitDiff(diff string) []diffFile {
+	var files []diffFile
+	for strings.TrimSpace(diff) != "" {
+		end := strings.Index(diff, "\ndiff --git ") + 1
+		if end == 0 {
+			end = len(diff)
+		}
+		header := diff
+		if i := strings.IndexByte(diff, '\n'); i >= 0 {
+			header = diff[:i]
+		}
+		files = append(files, diffFile{Path: diffPath(header), Text: diff[:end]})
+		diff = diff[end:]
+	}
+	return files
+}
+
+// diffPath returns the path of the file changed after a diff --git header,
+// the new one when the file is renamed
+func diffPath(header string) string {
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[0] != "diff" {
+		return ""
+	}
+	return strings.TrimPrefix(fields[len(fields)-1], "b/")
+}
+
+// chunkDiff packs the files of a diff into chunks of at most maxTokens
+// estimated tokens. A file too large for a chunk is split between its hunks,
+// and a hunk too large between its lines; each piece repeats the header of
+// the file.
+func chunkDiff(files []diffFile, maxTokens int) []diffChunk {
+	var chunks []diffChunk
+	var current diffChunk
+	currentTokens := 0
+	flush := func() {
+		if current.Text != "" {
+			chunks = append(chunks, current)
+		}
+		current, currentTokens = diffChunk{}, 0
+	}
+
+	for _, file := range files {
+		tokens := estimateTokens(file.Text)
+		if tokens > maxTokens {
+			flush()
+			for _, piece := range splitDiffFile(file, maxTokens) {
+				chunks = append(chunks, diffChunk{Files: []string{file.Path}, Text: piece})
+			}
+			continue
+		}
+		if currentTokens+tokens > maxTokens {
+			flush()
+		}
+		current.Files = append(current.Files, file.Path)
+		current.Text += file.Text
+		currentTokens += tokens
+	}
+	flush()
+	return chunks
+}
+
+// splitDiffFile splits the diff of one file into pieces of at most
+// maxTokens, each starting with the file's header. Pieces end at a hunk once
+// they are half full, and anywhere when the next line doesn't fit.
+func splitDiffFile(file diffFile, maxTokens int) []string {
+	lines := strings.SplitAfter(file.Text, "\n")
+	i := 0
+	for i < len(lines) && !strings.HasPrefix(lines[i], "@@") {
+		i++
+	}
+	header := strings.Join(lines[:i], "")
+	headerTokens := estimateTokens(header)
+
+	var pieces []string
+	var piece strings.Builder
+	tokens := headerTokens
+	for _, line := range lines[i:] {
+		lineTokens := estimateTokens(line)
+		if piece.Len() > 0 && (tokens+lineTokens > maxTokens || (strings.HasPrefix(line, "@@") && tokens > maxTokens/2)) {
+			pieces = append(pieces, header+piece.String())
+			piece.Reset()
+			tokens = headerTokens
+		}
+		piece.WriteString(line)
+		tokens += lineTokens
+	}
+	if piece.Len() > 0 {
+		pieces = append(pieces, header+piece.String())
+	}
+	return pieces
+}
+
+// reviewChunk sends one chunk of the diff for review and returns its
+// findings, logging the request
+func reviewChunk(client *APIClient, chunk diffChunk, index, count int) ([]ReviewFinding, Usage, error) {
+	config := client.config
+	label := fmt.Sprintf("%d/%d", index, count)
+	loggedPrompt := "git diff of " + strings.Join(chunk.Files, ", ")
+	if config.LogFullPrompt {
+		loggedPrompt = chunk.Text
+	}
+
+	start := time.Now()
+	stopProgress := startProgress(config)
+	response, err := client.Chat(config.requestContext(), promptMessages(config, chunk.Text))
+	stopProgress()
+	if err != nil {
+		entry := LogEntry{Timestamp: time.Now(), Command: "review", Chunk: label, Prompt: loggedPrompt, Error: err.Error(), RequestID: errorRequestID(err)}
+		if isCancelled(err) {
+			entry.Error = cancelledLogMessage
+			warnLogError(config, writeLogEntry(config, entry))
+			return nil, Usage{}, errCancelled
+		}
+		warnLogError(config, writeLogEntry(config, entry))
+		return nil, Usage{}, fmt.Errorf("failed to review chunk %s: %w", label, err)
+	}
+
+	content := formatResponse(response)
+	warnLogError(config, writeLogEntry(config, LogEntry{
+		Timestamp: time.Now(),
+		Command:   "review",
+		Chunk:     label,
+		Prompt:    loggedPrompt,
+		Response:  content,
+		Usage:     usageOrNil(response.Usage),
+		Model:     responseModel(config, response),
+		LatencyMs: time.Since(start).Milliseconds(),
+		RequestID: response.RequestID,
+	}))
+
+	return parseFindings(content, index), response.Usage, nil
+}
+
+// parseFindings reads the findings of a review reply. A reply that isn't
+// the JSON asked for is kept whole as a finding of no particular file, so
+// that nothing the model said is lost.
+func parseFindings(content string, chunk int) []ReviewFinding {
+	var reply struct {
+		Findings []ReviewFinding `json:"findings"`
+	}
+	value, ok := findJSON(content)
+	if !ok || json.Unmarshal([]byte(value), &reply) != nil {
+		return []ReviewFinding{{Comment: strings.TrimSpace(content), Chunk: chunk}}
+	}
+
+	var findings []ReviewFinding
+	for _, finding := range reply.Findings {
+		if strings.TrimSpace(finding.Comment) == "" {
+			continue
+		}
+		finding.Severity = strings.ToLower(finding.Severity)
+		finding.Chunk = chunk
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// formatReview writes the report as markdown, with the findings grouped by
+// file in the order of the diff, each marked with its chunk
+func formatReview(report ReviewReport) string {
+	byFile := make(map[string][]ReviewFinding)
+	var others []string
+	for _, finding := range report.Findings {
+		if _, seen := byFile[finding.File]; !seen && !containsString(report.Files, finding.File) && finding.File != "" {
+			others = append(others, finding.File)
+		}
+		byFile[finding.File] = append(byFile[finding.File], finding)
+	}
+	sort.Strings(others)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Review of %s\n\n", report.Diff)
+	fmt.Fprintf(&b, "%d %s reviewed in %d %s, %d %s.\n", len(report.Files), plural(len(report.Files), "file", "files"),
+		report.Chunks, plural(report.Chunks, "chunk", "chunks"), len(report.Findings), plural(len(report.Findings), "finding", "findings"))
+
+	var clean []string
+	for _, file := range append(append(append([]string{}, report.Files...), others...), "") {
+		findings := byFile[file]
+		if len(findings) == 0 {
+			if file != "" {
+				clean = append(clean, file)
+			}
+			continue
+		}
+		sort.SliceStable(findings, func(i, j int) bool {
+			if findings[i].Chunk != findings[j].Chunk {
+				return findings[i].Chunk < findings[j].Chunk
+			}
+			return findings[i].Line < findings[j].Line
+		})
+
+		title := file
+		if file == "" {
+			title = "General"
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", title)
+		for _, finding := range findings {
+			b.WriteString("- ")
+			if finding.Severity != "" {
+				fmt.Fprintf(&b, "**%s** ", finding.Severity)
+			}
+			if finding.Line > 0 {
+				fmt.Fprintf(&b, "line %d ", finding.Line)
+			}
+			// Later lines of a comment continue its list item
+			comment := strings.ReplaceAll(strings.TrimSpace(finding.Comment), "\n", "\n  ")
+			fmt.Fprintf(&b, "(chunk %d/%d): %s\n", finding.Chunk, report.Chunks, comment)
+		}
+	}
+
+	if len(clean) > 0 {
+		fmt.Fprintf(&b, "\nNo findings in: %s\n", strings.Join(clean, ", "))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// plural returns singular when n is 1, otherwise pluralForm
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDiff changes two files, the second one in two hunks
+const testDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
++// added
+ func main() {}
+diff --git a/util/strings.go b/util/strings.go
+index 3333333..4444444 100644
+--- a/util/strings.go
++++ b/util/strings.go
+@@ -1,2 +1,3 @@
+ package util
++func a() {}
+@@ -10,2 +11,3 @@
+ func b() {}
++func c() {}
+`
+
+// TestSplitDiff tests splitting a diff by file
+func TestSplitDiff(t *testing.T) {
+	files := splitDiff(testDiff)
+	if len(files) != 2 || files[0].Path != "main.go" || files[1].Path != "util/strings.go" {
+		t.Fatalf("files = %+v", files)
+	}
+	if files[0].Text+files[1].Text != testDiff {
+		t.Errorf("files don't add up to the diff")
+	}
+	if len(splitDiff("")) != 0 {
+		t.Errorf("an empty diff has files")
+	}
+}
+
+// TestChunkDiff tests packing files into chunks and splitting files too
+// large for one, repeating their header in each piece
+func TestChunkDiff(t *testing.T) {
+	files := splitDiff(testDiff)
+
+	chunks := chunkDiff(files, 1000)
+	if len(chunks) != 1 || strings.Join(chunks[0].Files, ",") != "main.go,util/strings.go" || chunks[0].Text != testDiff {
+		t.Errorf("chunks = %+v, want both files in one chunk", chunks)
+	}
+
+	// Large enough for one hunk of the second file, but not both
+	header := "diff --git a/util/strings.go b/util/strings.go\nindex 3333333..4444444 100644\n--- a/util/strings.go\n+++ b/util/strings.go\n"
+	limit := estimateTokens(header+"@@ -10,2 +11,3 @@\n func b() {}\n+func c() {}\n") + 1
+	if first := estimateTokens(header+"@@ -1,2 +1,3 @@\n package util\n+func a() {}\n") + 1; first > limit {
+		limit = first
+	}
+	chunks = chunkDiff(files, limit)
+	if len(chunks) != 3 || chunks[0].Text != files[0].Text {
+		t.Fatalf("chunks = %+v, want main.go and the two hunks of util/strings.go", chunks)
+	}
+	for _, chunk := range chunks[1:] {
+		if !strings.HasPrefix(chunk.Text, header+"@@") || strings.Join(chunk.Files, ",") != "util/strings.go" {
+			t.Errorf("chunk = %+v, want a hunk of util/strings.go after its header", chunk)
+		}
+		if estimateTokens(chunk.Text) > limit {
+			t.Errorf("chunk of %d tokens, over the limit of %d", estimateTokens(chunk.Text), limit)
+		}
+	}
+	if !strings.Contains(chunks[1].Text, "+func a() {}") || !strings.Contains(chunks[2].Text, "+func c() {}") {
+		t.Errorf("hunks split wrongly: %q, %q", chunks[1].Text, chunks[2].Text)
+	}
+}
+
+// TestParseFindings tests reading the findings of review replies
+func TestParseFindings(t *testing.T) {
+	findings := parseFindings("```json\n{\"findings\": [{\"file\": \"a.go\", \"line\": 3, \"severity\": \"HIGH\", \"comment\": \"nil map write\"}, {\"file\": \"a.go\", \"comment\": \" \"}]}\n```", 2)
+	if len(findings) != 1 || findings[0] != (ReviewFinding{File: "a.go", Line: 3, Severity: "high", Comment: "nil map write", Chunk: 2}) {
+		t.Errorf("findings = %+v", findings)
+	}
+
+	if findings := parseFindings(`{"findings": []}`, 1); len(findings) != 0 {
+		t.Errorf("findings = %+v, want none", findings)
+	}
+
+	findings = parseFindings("Looks good overall,\nbut check the error handling.", 1)
+	if len(findings) != 1 || findings[0].File != "" || findings[0].Comment != "Looks good overall,\nbut check the error handling." {
+		t.Errorf("findings = %+v, want the reply kept whole", findings)
+	}
+}
+
+// TestFormatReview tests grouping findings by file in diff order, each
+// marked with its chunk
+func TestFormatReview(t *testing.T) {
+	report := ReviewReport{
+		Diff:   "git diff --cached",
+		Files:  []string{"main.go", "util/strings.go", "README.md"},
+		Chunks: 2,
+		Findings: []ReviewFinding{
+			{File: "util/strings.go", Line: 12, Severity: "low", Comment: "c is unused", Chunk: 2},
+			{File: "main.go", Line: 2, Severity: "medium", Comment: "comment says nothing", Chunk: 1},
+			{File: "util/strings.go", Line: 2, Severity: "high", Comment: "a is unused", Chunk: 1},
+			{Comment: "first line\nsecond line", Chunk: 2},
+		},
+	}
+
+	want := `# Review of git diff --cached
+
+3 files reviewed in 2 chunks, 4 findings.
+
+## main.go
+
+- **medium** line 2 (chunk 1/2): comment says nothing
+
+## util/strings.go
+
+- **high** line 2 (chunk 1/2): a is unused
+- **low** line 12 (chunk 2/2): c is unused
+
+## General
+
+- (chunk 2/2): first line
+  second line
+
+No findings in: README.md`
+	if got := formatReview(report); got != want {
+		t.Errorf("formatReview() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// initTestRepo creates a git repository with a committed file and makes it
+// the current directory
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	originalWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git("init", "-q")
+	if err := os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "main.go")
+	git("commit", "-q", "-m", "initial")
+	return dir
+}
+
+// TestReviewCommand tests reviewing the staged changes in chunks and
+// merging the findings, logging each chunk and the summary
+func TestReviewCommand(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Each reply reports one finding on every file of its chunk
+	fileHeader := regexp.MustCompile(`(?m)^diff --git a/\S+ b/(\S+)$`)
+	var requests []ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		requests = append(requests, request)
+
+		var findings []ReviewFinding
+		for _, match := range fileHeader.FindAllStringSubmatch(request.Messages[len(request.Messages)-1].Content, -1) {
+			findings = append(findings, ReviewFinding{File: match[1], Line: 1, Severity: "low", Comment: "check " + match[1]})
+		}
+		content, _ := json.Marshal(map[string][]ReviewFinding{"findings": findings})
+		reply, _ := json.Marshal(ChatResponse{Choices: []Choice{{Message: Message{Role: "assistant", Content: string(content)}}}, Usage: Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply)
+	}))
+	defer server.Close()
+
+	initTestRepo(t)
+	large := strings.Repeat("// a comment line long enough to take up some tokens\n", 60)
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := os.WriteFile(name, []byte("package main\n\n"+large), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out, err := exec.Command("git", "add", "a.go", "b.go").CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v\n%s", err, out)
+	}
+
+	configDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: configDir}
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := reviewCommand(config, []string{"--staged", "--focus", "security", "--model", "gpt-4o-mini", "--chunk-tokens", "1000"}); err != nil {
+			t.Fatalf("review error = %v", err)
+		}
+	})
+
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want one per chunk", len(requests))
+	}
+	system := requests[0].Messages[0]
+	if requests[0].Model != "gpt-4o-mini" || system.Role != "system" || !strings.Contains(system.Content, reviewFocuses["security"]) {
+		t.Errorf("request = %+v, want the model and the security focus", requests[0])
+	}
+	for i, name := range []string{"a.go", "b.go"} {
+		want := fmt.Sprintf("## %s\n\n- **low** line 1 (chunk %d/2): check %s", name, i+1, name)
+		if !strings.Contains(out, want) {
+			t.Errorf("output = %s\nwant it to contain %q", out, want)
+		}
+	}
+	if !strings.Contains(out, "# Review of git diff --cached") {
+		t.Errorf("output = %s\nwant the diff named", out)
+	}
+
+	entries := readTestLogEntries(t, configDir)
+	if len(entries) != 3 {
+		t.Fatalf("log entries = %+v, want 2 chunks and a summary", entries)
+	}
+	if entries[0].Chunk != "1/2" || entries[0].Prompt != "git diff of a.go" || entries[1].Chunk != "2/2" {
+		t.Errorf("chunk entries = %+v", entries[:2])
+	}
+	summary := entries[2]
+	if summary.Chunk != "" || summary.Prompt != "git diff --cached (2 files in 2 chunks)" || summary.Usage == nil || summary.Usage.TotalTokens != 240 {
+		t.Errorf("summary entry = %+v", summary)
+	}
+}
+
+// TestReviewCommandErrors tests refusing to run outside a repository, with
+// an empty diff or conflicting flags
+func TestReviewCommandErrors(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	config := &Config{APIKey: "test-key", APIURL: "http://127.0.0.1:1", Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: t.TempDir()}
+	for _, args := range [][]string{{"--staged", "--range", "main..HEAD"}, {"--focus", "speed"}, {"--range", "--output=x"}, {"extra"}} {
+		if err := reviewCommand(config, args); exitCode(err) != exitUsage {
+			t.Errorf("review %q error = %v, want a usage error", args, err)
+		}
+	}
+
+	dir := initTestRepo(t)
+	stderr := captureOutput(t, &os.Stderr, func() {
+		if err := reviewCommand(config, nil); err != nil {
+			t.Errorf("review of no changes error = %v", err)
+		}
+	})
+	if !strings.Contains(stderr, "Nothing to review: git diff is empty") {
+		t.Errorf("stderr = %q, want the empty diff reported", stderr)
+	}
+
+	outside := t.TempDir()
+	if err := os.Chdir(outside); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(outside))
+	err := reviewCommand(config, nil)
+	if exitCode(err) != exitUsage || !strings.Contains(err.Error(), "not inside a git repository") {
+		t.Errorf("review outside %s error = %v, want a usage error", dir, err)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Most stop sequences accepted by the API in one request
+const maxStopSequences = 4
+
+// samplingFlags holds the prompt flags overriding the sampling parameters
+type samplingFlags struct {
+	maxTokens        *int
+	topP             *float64
+	presencePenalty  *float64
+	frequencyPenalty *float64
+	stop             *string
+	seed             *int
+	reasoningEffort  *string
+}
+
+// addSamplingFlags defines the sampling flags on fs, defaulting to the
+// configured values
+func addSamplingFlags(fs *flag.FlagSet, config *Config) *samplingFlags {
+	return &samplingFlags{
+		maxTokens:        fs.Int("max-tokens", config.MaxTokens, "most tokens in the response"),
+		topP:             fs.Float64("top-p", config.TopP, "nucleus sampling between 0.0 and 1.0"),
+		presencePenalty:  fs.Float64("presence-penalty", config.PresencePenalty, "penalty between -2.0 and 2.0 for repeating topics"),
+		frequencyPenalty: fs.Float64("frequency-penalty", config.FrequencyPenalty, "penalty between -2.0 and 2.0 for repeating tokens"),
+		stop:             fs.String("stop", "", "comma-separated stop sequences"),
+		seed:             fs.Int("seed", 0, "seed asking for deterministic sampling; overrides OPENAI_SEED"),
+		reasoningEffort:  fs.String("reasoning-effort", "", "reasoning effort of reasoning models: low, medium or high"),
+	}
+}
+
+// apply validates the parsed flags and stores them in config
+func (f *samplingFlags) apply(fs *flag.FlagSet, config *Config) error {
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+
+	if set["max-tokens"] && *f.maxTokens < 1 {
+		return usageErrorf("--max-tokens must be a positive integer")
+	}
+	if *f.topP < 0 || *f.topP > 1 {
+		return usageErrorf("--top-p must be a number between 0.0 and 1.0")
+	}
+	if *f.presencePenalty < -2 || *f.presencePenalty > 2 {
+		return usageErrorf("--presence-penalty must be a number between -2.0 and 2.0")
+	}
+	if *f.frequencyPenalty < -2 || *f.frequencyPenalty > 2 {
+		return usageErrorf("--frequency-penalty must be a number between -2.0 and 2.0")
+	}
+
+	if *f.reasoningEffort != "" {
+		if err := validateReasoningEffort(*f.reasoningEffort); err != nil {
+			return err
+		}
+	}
+
+	// Flags left out keep the configured values, which --model may have
+	// changed since the flags were defined
+	if set["max-tokens"] {
+		config.MaxTokens = *f.maxTokens
+	}
+	if set["top-p"] {
+		config.TopP = *f.topP
+	}
+	if set["presence-penalty"] {
+		config.PresencePenalty = *f.presencePenalty
+	}
+	if set["frequency-penalty"] {
+		config.FrequencyPenalty = *f.frequencyPenalty
+	}
+	if set["seed"] {
+		if config.Provider == providerAnthropic {
+			return usageErrorf("--seed is not supported by the %s provider", config.Provider)
+		}
+		seed := *f.seed
+		config.Seed = &seed
+	}
+	config.ReasoningEffort = *f.reasoningEffort
+
+	// An explicit --stop replaces the configured sequences, even when empty
+	if set["stop"] {
+		stop, err := parseStopSequences(*f.stop)
+		if err != nil {
+			return usageErrorf("invalid --stop: %w", err)
+		}
+		config.Stop = stop
+	}
+
+	return nil
+}
+
+// parseStopSequences splits a comma-separated list of stop sequences. Spaces
+// around each item are ignored; \n, \t, \, and \\ are unescaped so that
+// sequences can contain newlines or commas. Empty items are dropped.
+func parseStopSequences(value string) ([]string, error) {
+	var sequences []string
+	var current strings.Builder
+	escaped := false
+
+	flush := func() {
+		if s := current.String(); strings.Trim(s, " ") != "" {
+			sequences = append(sequences, s)
+		}
+		current.Reset()
+	}
+
+	value = strings.TrimSpace(value)
+	for _, c := range value {
+		if escaped {
+			switch c {
+			case 'n':
+				current.WriteRune('\n')
+			case 't':
+				current.WriteRune('\t')
+			case ',', '\\':
+				current.WriteRune(c)
+			default:
+				return nil, fmt.Errorf("invalid escape \\%c in stop sequences", c)
+			}
+			escaped = false
+			continue
+		}
+
+		switch {
+		case c == '\\':
+			escaped = true
+		case c == ',':
+			flush()
+		case c == ' ' && current.Len() == 0:
+			// Skip spaces after a comma
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("stop sequences end with an unfinished escape")
+	}
+	flush()
+
+	for i, s := range sequences {
+		sequences[i] = strings.TrimRight(s, " ")
+	}
+
+	if len(sequences) > maxStopSequences {
+		return nil, fmt.Errorf("at most %d stop sequences are allowed, got %d", maxStopSequences, len(sequences))
+	}
+	return sequences, nil
+}
+
+// parseStopSequencesOrDefault parses stop sequences, ignoring invalid values
+func parseStopSequencesOrDefault(value string) []string {
+	sequences, err := parseStopSequences(value)
+	if err != nil {
+		return nil
+	}
+	return sequences
+}
+
+// formatStopSequences formats stop sequences the way they are configured
+func formatStopSequences(sequences []string) string {
+	if len(sequences) == 0 {
+		return "(not set)"
+	}
+
+	quoted := make([]string, len(sequences))
+	for i, s := range sequences {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, ",", `\,`)
+		s = strings.ReplaceAll(s, "\n", `\n`)
+		quoted[i] = strings.ReplaceAll(s, "\t", `\t`)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// parseSeedOrDefault parses a configured seed; an empty or invalid value
+// means no seed is sent
+func parseSeedOrDefault(value string) *int {
+	seed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil
+	}
+	return &seed
+}
+
+// formatSeed formats a seed the way it is configured
+func formatSeed(seed *int) string {
+	if seed == nil {
+		return "(not set)"
+	}
+	return strconv.Itoa(*seed)
+}
+
+// formatOptionalFloat formats a sampling parameter, where zero means unset
+func formatOptionalFloat(value float64) string {
+	if value == 0 {
+		return "(not set)"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
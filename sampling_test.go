@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseStopSequences tests splitting and unescaping of stop sequences
+func TestParseStopSequences(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: nil},
+		{name: "single", value: "END", want: []string{"END"}},
+		{name: "spaces around items", value: " END , STOP ", want: []string{"END", "STOP"}},
+		{name: "empty items dropped", value: "a,,b,", want: []string{"a", "b"}},
+		{name: "escaped newline", value: `\n\n,END`, want: []string{"\n\n", "END"}},
+		{name: "escaped comma and backslash", value: `a\,b,c\\d`, want: []string{"a,b", `c\d`}},
+		{name: "inner spaces kept", value: "Human: ,AI:", want: []string{"Human:", "AI:"}},
+		{name: "unknown escape", value: `\x`, wantErr: true},
+		{name: "unfinished escape", value: `END\`, wantErr: true},
+		{name: "too many", value: "a,b,c,d,e", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStopSequences(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStopSequences() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStopSequences() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFormatStopSequences tests that formatted sequences parse back unchanged
+func TestFormatStopSequences(t *testing.T) {
+	if got := formatStopSequences(nil); got != "(not set)" {
+		t.Errorf("formatStopSequences(nil) = %q, want (not set)", got)
+	}
+
+	sequences := []string{"\n\n", "a,b", `c\d`, "\tEND"}
+	formatted := formatStopSequences(sequences)
+	parsed, err := parseStopSequences(formatted)
+	if err != nil {
+		t.Fatalf("parseStopSequences(%q) error = %v", formatted, err)
+	}
+	if !reflect.DeepEqual(parsed, sequences) {
+		t.Errorf("round trip = %q, want %q", parsed, sequences)
+	}
+}
+
+// TestSamplingParametersInRequest tests that only set parameters are sent
+func TestSamplingParametersInRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		present []string
+		absent  []string
+	}{
+		{
+			name:   "unset",
+			config: Config{Model: "gpt-4o"},
+			absent: []string{"top_p", "presence_penalty", "frequency_penalty", "stop", "seed"},
+		},
+		{
+			name: "set",
+			config: Config{
+				Model:            "gpt-4o",
+				TopP:             0.9,
+				PresencePenalty:  -1,
+				FrequencyPenalty: 0.5,
+				Stop:             []string{"END"},
+			},
+			present: []string{`"top_p":0.9`, `"presence_penalty":-1`, `"frequency_penalty":0.5`, `"stop":["END"]`},
+		},
+		{
+			name:    "zero seed",
+			config:  Config{Model: "gpt-4o", Seed: parseSeedOrDefault("0")},
+			present: []string{`"seed":0`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.APIURL = "https://api.example.com/v1/chat/completions"
+			req, err := newChatRequest(context.Background(), &tt.config, "hello", false)
+			if err != nil {
+				t.Fatalf("newChatRequest() error = %v", err)
+			}
+			data, err := io.ReadAll(req.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Fatalf("failed to parse body: %v", err)
+			}
+			for _, s := range tt.present {
+				if !strings.Contains(string(data), s) {
+					t.Errorf("body = %s, want it to contain %s", data, s)
+				}
+			}
+			for _, key := range tt.absent {
+				if _, exists := body[key]; exists {
+					t.Errorf("body = %s, want no %q field", data, key)
+				}
+			}
+		})
+	}
+}
+
+// TestSamplingFlags tests prompt flag overrides of the configured values
+func TestSamplingFlags(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		wantErr       string
+		wantTopP      float64
+		wantStop      []string
+		wantMaxTokens int
+		wantSeed      string
+	}{
+		{name: "defaults from config", wantTopP: 0.5, wantStop: []string{"END"}, wantMaxTokens: 1000},
+		{name: "overrides", args: []string{"--top-p", "0.9", "--stop", "A,B", "--max-tokens", "5"}, wantTopP: 0.9, wantStop: []string{"A", "B"}, wantMaxTokens: 5},
+		{name: "empty stop clears", args: []string{"--stop", ""}, wantTopP: 0.5, wantStop: nil, wantMaxTokens: 1000},
+		{name: "seed", args: []string{"--seed", "42"}, wantTopP: 0.5, wantStop: []string{"END"}, wantMaxTokens: 1000, wantSeed: "42"},
+		{name: "top-p out of range", args: []string{"--top-p", "2"}, wantErr: "--top-p"},
+		{name: "penalty out of range", args: []string{"--presence-penalty", "-3"}, wantErr: "--presence-penalty"},
+		{name: "invalid stop", args: []string{"--stop", `\q`}, wantErr: "invalid --stop"},
+		{name: "max tokens not positive", args: []string{"--max-tokens", "0"}, wantErr: "--max-tokens"},
+		{name: "invalid seed", args: []string{"--seed", "x"}, wantErr: "invalid value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{TopP: 0.5, Stop: []string{"END"}, MaxTokens: 1000}
+
+			fs := flag.NewFlagSet("prompt", flag.ContinueOnError)
+			sampling := addSamplingFlags(fs, config)
+			_, err := parseFlags(fs, tt.args)
+			if err == nil {
+				err = sampling.apply(fs, config)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("apply() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+			if config.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("MaxTokens = %d, want %d", config.MaxTokens, tt.wantMaxTokens)
+			}
+			if config.TopP != tt.wantTopP {
+				t.Errorf("TopP = %v, want %v", config.TopP, tt.wantTopP)
+			}
+			if !reflect.DeepEqual(config.Stop, tt.wantStop) {
+				t.Errorf("Stop = %q, want %q", config.Stop, tt.wantStop)
+			}
+			wantSeed := tt.wantSeed
+			if wantSeed == "" {
+				wantSeed = "(not set)"
+			}
+			if got := formatSeed(config.Seed); got != wantSeed {
+				t.Errorf("Seed = %s, want %s", got, wantSeed)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Fields searched by search --in
+const (
+	searchInPrompt   = "prompt"
+	searchInResponse = "response"
+	searchInBoth     = "both"
+)
+
+// Values accepted by search --in
+var searchFields = []string{searchInPrompt, searchInResponse, searchInBoth}
+
+// Characters of a search excerpt, and those kept before the first match
+const (
+	searchSnippetWidth   = 80
+	searchSnippetContext = 20
+)
+
+// searchMatcher finds the search terms in a text. Every pattern has to match
+// for the text to match.
+type searchMatcher struct {
+	patterns []*regexp.Regexp
+}
+
+// newTermMatcher matches texts containing every term, ignoring case
+func newTermMatcher(terms []string) searchMatcher {
+	var m searchMatcher
+	for _, term := range terms {
+		m.patterns = append(m.patterns, regexp.MustCompile("(?i)"+regexp.QuoteMeta(term)))
+	}
+	return m
+}
+
+// newRegexMatcher matches texts matching expr, ignoring case
+func newRegexMatcher(expr string) (searchMatcher, error) {
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		return searchMatcher{}, usageErrorf("invalid --regex: %w", err)
+	}
+	return searchMatcher{patterns: []*regexp.Regexp{re}}, nil
+}
+
+// find returns the byte ranges of text matched, sorted and merged, or nil if
+// text does not match
+func (m searchMatcher) find(text string) [][]int {
+	var ranges [][]int
+	for _, re := range m.patterns {
+		found := re.FindAllStringIndex(text, -1)
+		if len(found) == 0 {
+			return nil
+		}
+		ranges = append(ranges, found...)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i][0] < ranges[j][0]
+	})
+	merged := [][]int{}
+	for _, r := range ranges {
+		if r[0] == r[1] {
+			continue
+		}
+		if last := len(merged) - 1; last >= 0 && r[0] <= merged[last][1] {
+			if r[1] > merged[last][1] {
+				merged[last][1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, []int{r[0], r[1]})
+	}
+	return merged
+}
+
+// searchMatch is a history entry found by search, with which of its fields
+// matched
+type searchMatch struct {
+	HistoryEntry
+	promptMatched   bool
+	responseMatched bool
+}
+
+// searchCommand finds the history entries whose prompt or response contains
+// all the given terms
+func searchCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli search [--regex] [--in prompt|response|both] [--limit N] <terms>"
+
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	regex := fs.Bool("regex", false, "search with a single regular expression instead of terms")
+	in := fs.String("in", searchInBoth, "fields searched: prompt, response or both")
+	limit := fs.Int("limit", defaultHistoryLimit, "show at most N matches, 0 for all")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if *in != searchInPrompt && *in != searchInResponse && *in != searchInBoth {
+		return usageErrorf("--in must be one of: %s", strings.Join(searchFields, ", "))
+	}
+	if *limit < 0 {
+		return usageErrorf("--limit must be a non-negative integer")
+	}
+
+	var matcher searchMatcher
+	if *regex {
+		if len(args) != 1 {
+			return usageErrorf("--regex takes a single regular expression\n%s", usage)
+		}
+		if matcher, err = newRegexMatcher(args[0]); err != nil {
+			return err
+		}
+	} else {
+		terms := strings.Fields(strings.Join(args, " "))
+		if len(terms) == 0 {
+			return usageErrorf("search terms are required\n%s", usage)
+		}
+		matcher = newTermMatcher(terms)
+	}
+
+	matches, skipped, err := searchHistory(config.ConfigDir, matcher, *in, *limit)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	defer reportCorruptLogLines(newUI(config, os.Stderr), skipped)
+
+	if config.Output == outputJSON {
+		entries := []HistoryEntry{}
+		for _, match := range matches {
+			entries = append(entries, match.HistoryEntry)
+		}
+		return printJSON(entries)
+	}
+
+	if len(matches) == 0 {
+		fmt.Printf("No prompts in history match %q.\n", strings.Join(args, " "))
+		return nil
+	}
+	printSearchMatches(newUI(config, os.Stdout), matches, matcher)
+	return nil
+}
+
+// searchHistory scans the log files one entry at a time for the history
+// entries matching in the given fields. It returns the last limit matches,
+// most recent first, with their history indexes, and the number of lines
+// that could not be parsed.
+func searchHistory(configDir string, matcher searchMatcher, in string, limit int) ([]searchMatch, int, error) {
+	logFiles, err := listLogFiles(configDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Indexes count from the most recent entry, so matches are numbered
+	// from the oldest until the total is known
+	var matches []searchMatch
+	total := 0
+	skipped, err := forEachLogEntry(logFiles, func(entry LogEntry) error {
+		if !inHistory(entry) {
+			return nil
+		}
+		total++
+
+		match := searchMatch{HistoryEntry: HistoryEntry{Index: total, LogEntry: entry}}
+		match.promptMatched = in != searchInResponse && matcher.find(entry.Prompt) != nil
+		match.responseMatched = in != searchInPrompt && matcher.find(entry.Response) != nil
+		if !match.promptMatched && !match.responseMatched {
+			return nil
+		}
+
+		matches = append(matches, match)
+		if limit > 0 && len(matches) > limit {
+			matches = matches[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, skipped, err
+	}
+
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	for i := range matches {
+		matches[i].Index = total - matches[i].Index + 1
+	}
+	return matches, skipped, nil
+}
+
+// printSearchMatches prints each match as history does, with an excerpt of
+// the response when it matched, and the terms highlighted
+func printSearchMatches(out *ui, matches []searchMatch, matcher searchMatcher) {
+	for _, match := range matches {
+		prompt := truncate(strings.Join(strings.Fields(match.Prompt), " "), searchSnippetWidth)
+		if match.promptMatched {
+			prompt = searchSnippet(out, match.Prompt, matcher)
+		}
+		out.Printf("%4d  %s  %s\n", match.Index, match.Timestamp.Local().Format("2006-01-02 15:04"), prompt)
+		if match.responseMatched {
+			out.Printf("      %s %s\n", out.dim("Response:"), searchSnippet(out, match.Response, matcher))
+		}
+	}
+}
+
+// searchSnippet returns a one-line excerpt of text starting a little before
+// its first match, with the matches highlighted
+func searchSnippet(out *ui, text string, matcher searchMatcher) string {
+	text = strings.Join(strings.Fields(text), " ")
+	ranges := matcher.find(text)
+
+	start := 0
+	if len(ranges) > 0 && ranges[0][0] > searchSnippetContext {
+		start = ranges[0][0] - searchSnippetContext
+		for start > 0 && !utf8.RuneStart(text[start]) {
+			start--
+		}
+	}
+	end := len(text)
+	runes := 0
+	for i := range text[start:] {
+		if runes == searchSnippetWidth {
+			end = start + i
+			break
+		}
+		runes++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	pos := start
+	for _, r := range ranges {
+		from, to := r[0], r[1]
+		if to <= start || from >= end {
+			continue
+		}
+		if from < start {
+			from = start
+		}
+		if to > end {
+			to = end
+		}
+		b.WriteString(text[pos:from])
+		b.WriteString(out.highlight(text[from:to]))
+		pos = to
+	}
+	b.WriteString(text[pos:end])
+	if end < len(text) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSearchMatcherFind tests that every term has to match, ignoring case,
+// and that overlapping matches are merged
+func TestSearchMatcherFind(t *testing.T) {
+	matcher := newTermMatcher([]string{"interface", "EMBED", "embedding"})
+
+	got := matcher.find("Embedding an Interface in a struct: embedding")
+	want := [][]int{{0, 9}, {13, 22}, {36, 45}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("find() = %v, want %v", got, want)
+	}
+	if got := matcher.find("an interface without the other term"); got != nil {
+		t.Errorf("find() with a missing term = %v, want nil", got)
+	}
+
+	regex, err := newRegexMatcher(`pods?\b`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := regex.find("Explain Kubernetes PODS"); !reflect.DeepEqual(got, [][]int{{19, 23}}) {
+		t.Errorf("regex find() = %v", got)
+	}
+	if _, err := newRegexMatcher("("); !errors.Is(err, ErrUsage) {
+		t.Errorf("newRegexMatcher(%q) error = %v, want a usage error", "(", err)
+	}
+}
+
+// TestSearchSnippet tests the excerpt printed around the first match
+func TestSearchSnippet(t *testing.T) {
+	matcher := newTermMatcher([]string{"needle"})
+
+	plain := &ui{}
+	text := strings.Repeat("hay ", 20) + "needle\nin the\thaystack " + strings.Repeat("straw ", 30)
+	got := searchSnippet(plain, text, matcher)
+	if !strings.HasPrefix(got, "...") || !strings.HasSuffix(got, "...") {
+		t.Errorf("snippet = %q, want both ends cut", got)
+	}
+	if !strings.Contains(got, "needle in the haystack") {
+		t.Errorf("snippet = %q, want the match on one line", got)
+	}
+
+	colored := &ui{color: true}
+	if got, want := searchSnippet(colored, "a Needle", matcher), "a "+ansiBold+ansiYellow+"Needle"+ansiDefaultFg+ansiBoldOff; got != want {
+		t.Errorf("colored snippet = %q, want %q", got, want)
+	}
+}
+
+// TestSearchCommand tests the matches, their history indexes and the flags
+func TestSearchCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	writeTestHistory(t, tmpDir)
+	config := &Config{ConfigDir: tmpDir}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []int
+	}{
+		{"terms in any order", []string{"pods", "kubernetes"}, []int{3}},
+		{"prompt or response", []string{"pods"}, []int{1, 3}},
+		{"in prompt", []string{"--in", "prompt", "pods"}, []int{3}},
+		{"in response", []string{"--in", "response", "kubernetes"}, nil},
+		{"regex", []string{"--regex", "^what|dash$"}, []int{1, 2}},
+		{"limit keeps the most recent", []string{"--limit", "1", "kubernetes"}, []int{1}},
+		{"no match", []string{"docker"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Output = outputJSON
+			out := captureOutput(t, &os.Stdout, func() {
+				if err := searchCommand(config, tt.args); err != nil {
+					t.Errorf("searchCommand() error = %v", err)
+				}
+			})
+
+			var matches []HistoryEntry
+			if err := json.Unmarshal([]byte(out), &matches); err != nil {
+				t.Fatalf("invalid JSON %q: %v", out, err)
+			}
+			var indexes []int
+			for _, match := range matches {
+				indexes = append(indexes, match.Index)
+			}
+			if !reflect.DeepEqual(indexes, tt.expected) {
+				t.Errorf("indexes = %v, want %v", indexes, tt.expected)
+			}
+		})
+	}
+
+	config.Output = outputPlain
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := searchCommand(config, []string{"exposes"}); err != nil {
+			t.Errorf("searchCommand() error = %v", err)
+		}
+	})
+	if !strings.HasPrefix(out, "   1  ") || !strings.Contains(out, "What is a kubernetes service?\n      Response: A service exposes pods\n") {
+		t.Errorf("plain output = %q", out)
+	}
+}
+
+// TestSearchCommandSkipsCorruptLines tests that malformed log lines are
+// counted at the end instead of failing the search
+func TestSearchCommandSkipsCorruptLines(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	writeTestHistory(t, tmpDir)
+	f, err := os.OpenFile(filepath.Join(tmpDir, logFileName), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{not json\n")
+	f.Close()
+
+	var stdout string
+	stderr := captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, func() {
+			if err := searchCommand(&Config{ConfigDir: tmpDir}, []string{"service"}); err != nil {
+				t.Errorf("searchCommand() error = %v", err)
+			}
+		})
+	})
+	if !strings.Contains(stdout, "kubernetes service") {
+		t.Errorf("stdout = %q, want the match", stdout)
+	}
+	if !strings.Contains(stderr, "1 log entry could not be parsed") {
+		t.Errorf("stderr = %q, want the skipped line count", stderr)
+	}
+}
+
+// TestSearchCommandUsage tests the arguments refused by search
+func TestSearchCommandUsage(t *testing.T) {
+	config := &Config{ConfigDir: t.TempDir()}
+	for _, args := range [][]string{
+		{},
+		{"--in", "title", "go"},
+		{"--limit", "-1", "go"},
+		{"--regex", "a", "b"},
+		{"--regex", "("},
+	} {
+		if err := searchCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("searchCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Groupings supported by the stats command
+const (
+	statsByModel = "model"
+	statsByDay   = "day"
+)
+
+// Group key used for entries that don't record a model
+const unknownModel = "unknown"
+
+// StatsGroup aggregates the log entries of one model or day
+type StatsGroup struct {
+	Key            string  `json:"key,omitempty"`
+	Prompts        int     `json:"prompts"`
+	Errors         int     `json:"errors"`
+	ErrorRate      float64 `json:"error_rate"`
+	Usage          Usage   `json:"usage"`
+	EstimatedCost  float64 `json:"estimated_cost"`
+	UnpricedTokens int     `json:"unpriced_tokens"`
+	AvgLatencyMs   int64   `json:"avg_latency_ms"`
+	P95LatencyMs   int64   `json:"p95_latency_ms"`
+
+	latencies []int64
+}
+
+// Stats is the result of the stats command
+type Stats struct {
+	Since  *time.Time   `json:"since,omitempty"`
+	By     string       `json:"by"`
+	Total  StatsGroup   `json:"total"`
+	Groups []StatsGroup `json:"groups"`
+}
+
+// add accumulates a log entry into the group. Entries written before usage
+// and latency were logged only count towards prompts and errors.
+func (g *StatsGroup) add(entry LogEntry) {
+	g.Prompts++
+	if entry.Error != "" {
+		g.Errors++
+	}
+
+	if entry.Usage != nil {
+		g.Usage.add(*entry.Usage)
+		if cost, ok := estimateCost(entry.Model, *entry.Usage); ok {
+			g.EstimatedCost += cost
+		} else {
+			g.UnpricedTokens += entry.Usage.TotalTokens
+		}
+	}
+
+	if entry.LatencyMs > 0 {
+		g.latencies = append(g.latencies, entry.LatencyMs)
+	}
+}
+
+// finish computes the derived averages once all entries are added
+func (g *StatsGroup) finish() {
+	if g.Prompts > 0 {
+		g.ErrorRate = float64(g.Errors) / float64(g.Prompts)
+	}
+	if len(g.latencies) > 0 {
+		var total int64
+		for _, ms := range g.latencies {
+			total += ms
+		}
+		g.AvgLatencyMs = total / int64(len(g.latencies))
+		g.P95LatencyMs = percentile(g.latencies, 95)
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of values, sorting them
+func percentile(values []int64, p int) int64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	rank := (len(values)*p + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1]
+}
+
+// statsCommand prints aggregate usage statistics from the logs
+func statsCommand(config *Config, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	var since durationFlag
+	fs.Var(&since, "since", "only include entries newer than this duration (e.g. 24h or 7d)")
+	by := fs.String("by", statsByModel, "group results by model or day")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return usageErrorf("%w\nUsage: chatgpt-cli stats [--since 7d] [--by day|model]", err)
+	}
+	if since < 0 {
+		return usageErrorf("--since must be a positive duration")
+	}
+	if *by != statsByModel && *by != statsByDay {
+		return usageErrorf("invalid --by value %q: must be day or model", *by)
+	}
+
+	var filter logFilter
+	if since > 0 {
+		filter.Since = time.Now().Add(-time.Duration(since))
+	}
+
+	logFiles, err := listLogFiles(config.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	stats, err := collectStats(logFiles, filter, *by)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	if config.Output == outputJSON {
+		return printJSON(stats)
+	}
+
+	if stats.Total.Prompts == 0 {
+		fmt.Println("No logs found.")
+		return nil
+	}
+
+	printStats(newUI(config, os.Stdout), stats)
+	return nil
+}
+
+// collectStats streams the log files and aggregates the matching entries
+func collectStats(logFiles []string, filter logFilter, by string) (*Stats, error) {
+	stats := &Stats{By: by, Groups: []StatsGroup{}}
+	if !filter.Since.IsZero() {
+		stats.Since = &filter.Since
+	}
+
+	groups := make(map[string]*StatsGroup)
+
+	_, err := forEachLogEntry(logFiles, func(entry LogEntry) error {
+		if !filter.matches(entry) {
+			return nil
+		}
+
+		key := entry.Model
+		if by == statsByDay {
+			key = entry.Timestamp.Local().Format("2006-01-02")
+		} else if key == "" {
+			key = unknownModel
+		}
+
+		group, ok := groups[key]
+		if !ok {
+			group = &StatsGroup{Key: key}
+			groups[key] = group
+		}
+
+		group.add(entry)
+		stats.Total.add(entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		group.finish()
+		stats.Groups = append(stats.Groups, *group)
+	}
+	stats.Total.finish()
+
+	sort.Slice(stats.Groups, func(i, j int) bool {
+		return stats.Groups[i].Key < stats.Groups[j].Key
+	})
+
+	return stats, nil
+}
+
+// printStats prints the summary and the per-group table
+func printStats(out *ui, stats *Stats) {
+	total := stats.Total
+
+	summary := newTable()
+	summary.addRow("Prompts:", strconv.Itoa(total.Prompts))
+	summary.addRow("Errors:", fmt.Sprintf("%d (%s)", total.Errors, formatPercent(total.ErrorRate)))
+	summary.addRow("Total tokens:", fmt.Sprintf("%d (prompt: %d, completion: %d)",
+		total.Usage.TotalTokens, total.Usage.PromptTokens, total.Usage.CompletionTokens))
+	summary.addRow("Estimated cost:", formatGroupCost(total))
+	summary.addRow("Avg latency:", formatLatency(total.AvgLatencyMs))
+	summary.addRow("P95 latency:", formatLatency(total.P95LatencyMs))
+	summary.render(out)
+
+	out.Println()
+
+	header := "MODEL"
+	if stats.By == statsByDay {
+		header = "DAY"
+	}
+
+	groups := newTable(header, "PROMPTS", "ERRORS", "TOKENS", "EST. COST", "AVG LATENCY", "P95 LATENCY")
+	for _, group := range stats.Groups {
+		groups.addRow(group.Key, strconv.Itoa(group.Prompts), formatPercent(group.ErrorRate),
+			strconv.Itoa(group.Usage.TotalTokens), formatGroupCost(group), formatLatency(group.AvgLatencyMs), formatLatency(group.P95LatencyMs))
+	}
+	groups.render(out)
+
+	if total.UnpricedTokens > 0 {
+		out.Printf("\n* cost excludes %d tokens from models without a known price\n", total.UnpricedTokens)
+	}
+}
+
+// formatGroupCost formats the estimated cost of a group, marking it with an
+// asterisk when some of its tokens could not be priced
+func formatGroupCost(group StatsGroup) string {
+	if group.UnpricedTokens == 0 {
+		return fmt.Sprintf("$%.6f", group.EstimatedCost)
+	}
+	if group.EstimatedCost == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("$%.6f*", group.EstimatedCost)
+}
+
+// formatPercent formats a ratio as a percentage
+func formatPercent(ratio float64) string {
+	return fmt.Sprintf("%.1f%%", ratio*100)
+}
+
+// formatLatency formats a latency, or "-" if none was recorded
+func formatLatency(ms int64) string {
+	if ms <= 0 {
+		return "-"
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(10 * time.Millisecond).String()
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeStatsTestLogs writes a mix of current and legacy log entries
+func writeStatsTestLogs(t *testing.T, configDir string) {
+	t.Helper()
+
+	now := time.Now()
+	writeTestLogs(t, filepath.Join(configDir, "logs.jsonl.1"), []LogEntry{
+		// Entry from before usage was logged
+		{Timestamp: now.Add(-10 * 24 * time.Hour), Command: "prompt", Prompt: "old", Response: "old response"},
+	})
+	writeTestLogs(t, filepath.Join(configDir, "logs.jsonl"), []LogEntry{
+		{
+			Timestamp: now.Add(-2 * time.Hour),
+			Command:   "prompt",
+			Model:     "gpt-4",
+			Usage:     &Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500},
+			LatencyMs: 1000,
+		},
+		{
+			Timestamp: now.Add(-time.Hour),
+			Command:   "batch",
+			Model:     "gpt-4",
+			Usage:     &Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500},
+			LatencyMs: 3000,
+		},
+		{
+			Timestamp: now.Add(-time.Hour),
+			Command:   "prompt",
+			Model:     "llama3",
+			Usage:     &Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30},
+			LatencyMs: 500,
+		},
+		{Timestamp: now, Command: "prompt", Error: "timeout"},
+	})
+}
+
+// TestCollectStats tests aggregating log entries by model
+func TestCollectStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeStatsTestLogs(t, tmpDir)
+
+	logFiles, err := listLogFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("listLogFiles() error = %v", err)
+	}
+
+	stats, err := collectStats(logFiles, logFilter{}, statsByModel)
+	if err != nil {
+		t.Fatalf("collectStats() error = %v", err)
+	}
+
+	total := stats.Total
+	if total.Prompts != 5 {
+		t.Errorf("Total.Prompts = %d, want 5", total.Prompts)
+	}
+	if total.Errors != 1 || total.ErrorRate != 0.2 {
+		t.Errorf("Total errors = %d (rate %f), want 1 (0.2)", total.Errors, total.ErrorRate)
+	}
+	if total.Usage.TotalTokens != 3030 {
+		t.Errorf("Total.Usage.TotalTokens = %d, want 3030", total.Usage.TotalTokens)
+	}
+	if total.UnpricedTokens != 30 {
+		t.Errorf("Total.UnpricedTokens = %d, want 30", total.UnpricedTokens)
+	}
+	if total.AvgLatencyMs != 1500 || total.P95LatencyMs != 3000 {
+		t.Errorf("Total latency avg %d p95 %d, want 1500 and 3000", total.AvgLatencyMs, total.P95LatencyMs)
+	}
+
+	var keys []string
+	for _, group := range stats.Groups {
+		keys = append(keys, group.Key)
+	}
+	if strings.Join(keys, ",") != "gpt-4,llama3,unknown" {
+		t.Fatalf("group keys = %v, want [gpt-4 llama3 unknown]", keys)
+	}
+
+	gpt4 := stats.Groups[0]
+	if gpt4.Prompts != 2 || gpt4.AvgLatencyMs != 2000 {
+		t.Errorf("gpt-4 group = %+v, want 2 prompts and 2000ms latency", gpt4)
+	}
+	if diff := gpt4.EstimatedCost - 0.12; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("gpt-4 EstimatedCost = %f, want 0.12", gpt4.EstimatedCost)
+	}
+}
+
+// TestPercentile tests the nearest-rank percentile
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		values []int64
+		p      int
+		want   int64
+	}{
+		{[]int64{700}, 95, 700},
+		{[]int64{300, 100, 200}, 95, 300},
+		{[]int64{300, 100, 200}, 50, 200},
+		{[]int64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 110, 120, 130, 140, 150, 160, 170, 180, 190, 2000}, 95, 190},
+	}
+
+	for _, tt := range tests {
+		if got := percentile(tt.values, tt.p); got != tt.want {
+			t.Errorf("percentile(%v, %d) = %d, want %d", tt.values, tt.p, got, tt.want)
+		}
+	}
+}
+
+// TestCollectStatsByDay tests grouping by day with a since window
+func TestCollectStatsByDay(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeStatsTestLogs(t, tmpDir)
+
+	logFiles, err := listLogFiles(tmpDir)
+	if err != nil {
+		t.Fatalf("listLogFiles() error = %v", err)
+	}
+
+	filter := logFilter{Since: time.Now().Add(-7 * 24 * time.Hour)}
+	stats, err := collectStats(logFiles, filter, statsByDay)
+	if err != nil {
+		t.Fatalf("collectStats() error = %v", err)
+	}
+
+	if stats.Total.Prompts != 4 {
+		t.Errorf("Total.Prompts = %d, want 4", stats.Total.Prompts)
+	}
+
+	prompts := 0
+	for _, group := range stats.Groups {
+		if _, err := time.Parse("2006-01-02", group.Key); err != nil {
+			t.Errorf("group key %q is not a day", group.Key)
+		}
+		prompts += group.Prompts
+	}
+	if prompts != 4 {
+		t.Errorf("prompts across groups = %d, want 4", prompts)
+	}
+}
+
+// TestStatsCommand tests flag handling and output of the stats command
+func TestStatsCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeStatsTestLogs(t, tmpDir)
+
+	tests := []struct {
+		name        string
+		args        []string
+		contains    string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:     "default",
+			args:     []string{},
+			contains: "MODEL",
+		},
+		{
+			name:     "by day since a week",
+			args:     []string{"--since", "7d", "--by", "day"},
+			contains: "DAY",
+		},
+		{
+			name:     "unpriced tokens are noted",
+			args:     []string{},
+			contains: "cost excludes 30 tokens",
+		},
+		{
+			name:        "invalid grouping",
+			args:        []string{"--by", "week"},
+			wantErr:     true,
+			errContains: "must be day or model",
+		},
+		{
+			name:        "invalid since",
+			args:        []string{"--since", "lastweek"},
+			wantErr:     true,
+			errContains: "invalid value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{ConfigDir: tmpDir}
+
+			var err error
+			out := captureOutput(t, &os.Stdout, func() {
+				err = statsCommand(config, tt.args)
+			})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("statsCommand() expected error, got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("statsCommand() unexpected error: %v", err)
+			}
+			if !strings.Contains(out, tt.contains) {
+				t.Errorf("output = %q, want it to contain %q", out, tt.contains)
+			}
+		})
+	}
+}
+
+// TestStatsCommandJSON tests the structured output of the stats command
+func TestStatsCommandJSON(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeStatsTestLogs(t, tmpDir)
+
+	config := &Config{ConfigDir: tmpDir, Output: outputJSON}
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = statsCommand(config, []string{"--by", "model"})
+	})
+	if err != nil {
+		t.Fatalf("statsCommand() error = %v", err)
+	}
+
+	var stats Stats
+	if err := json.Unmarshal([]byte(out), &stats); err != nil {
+		t.Fatalf("failed to parse output %q: %v", out, err)
+	}
+	if stats.By != statsByModel || stats.Total.Prompts != 5 || len(stats.Groups) != 3 {
+		t.Errorf("stats = %+v, want 5 prompts in 3 model groups", stats)
+	}
+
+	// An empty log directory still produces valid JSON
+	config.ConfigDir = t.TempDir()
+	out = captureOutput(t, &os.Stdout, func() {
+		err = statsCommand(config, []string{})
+	})
+	if err != nil {
+		t.Fatalf("statsCommand() error = %v", err)
+	}
+	if !strings.Contains(out, `"groups": []`) {
+		t.Errorf("output = %q, want an empty groups array", out)
+	}
+}
+
+// TestParseDurationWithDays tests durations given in days
+func TestParseDurationWithDays(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"24h", 24 * time.Hour, false},
+		{"30m", 30 * time.Minute, false},
+		{"d", 0, true},
+		{"1.5d", 0, true},
+		{"yesterday", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parseDurationWithDays(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDurationWithDays(%q) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if result != tt.expected {
+				t.Errorf("parseDurationWithDays(%q) = %v, want %v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Time allowed to each check of the status command
+const defaultStatusTimeout = 10 * time.Second
+
+// Results of a status check
+const (
+	statusOK      = "ok"
+	statusFail    = "fail"
+	statusSkipped = "skipped"
+)
+
+// StatusCheck is the result of one check of the status command
+type StatusCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+	Hint      string `json:"hint,omitempty"`
+	// The failure, which sets the exit status
+	err error
+}
+
+// StatusOutput is the JSON output of the status command
+type StatusOutput struct {
+	Provider string        `json:"provider"`
+	Model    string        `json:"model"`
+	URL      string        `json:"url"`
+	Checks   []StatusCheck `json:"checks"`
+}
+
+// statusCommand checks that the configured provider can be reached, accepts
+// the API key and, with --deep, answers a chat completion. It fails with the
+// exit status of the failed check, for preflight checks in CI.
+func statusCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli status [--deep] [--timeout 10s]"
+
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	deep := fs.Bool("deep", false, "also send a chat completion of one token")
+	timeout := fs.Duration("timeout", defaultStatusTimeout, "time allowed to each check")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\n%s", args[0], usage)
+	}
+	if *timeout <= 0 {
+		return usageErrorf("--timeout must be positive")
+	}
+
+	// Each check gets its own timeout, for the connection and the request
+	checkConfig := *config
+	checkConfig.Timeout = *timeout
+	checkConfig.ConnectTimeout = *timeout
+	checks := runStatusChecks(config.requestContext(), &checkConfig, *deep)
+
+	output := StatusOutput{Provider: config.Provider, Model: config.Model, URL: config.APIURL, Checks: checks}
+	if config.Output == outputJSON {
+		if err := printJSON(output); err != nil {
+			return err
+		}
+	} else {
+		printStatusChecks(config, output)
+	}
+
+	for _, check := range checks {
+		if check.Status == statusFail {
+			return statusError(check)
+		}
+	}
+	return nil
+}
+
+// runStatusChecks runs the checks in order. Each depends on the one before,
+// so the ones after a failure are skipped.
+func runStatusChecks(ctx context.Context, config *Config, deep bool) []StatusCheck {
+	steps := []struct {
+		name string
+		run  func(ctx context.Context) (string, error)
+	}{
+		{"reachability", func(ctx context.Context) (string, error) { return checkReachability(ctx, config) }},
+		{"api key", func(ctx context.Context) (string, error) { return checkAPIKeyAccepted(ctx, config) }},
+		{"chat", func(ctx context.Context) (string, error) { return checkChatCompletion(ctx, config) }},
+	}
+	if !deep {
+		steps = steps[:2]
+	}
+
+	var checks []StatusCheck
+	failed := false
+	for _, step := range steps {
+		if failed {
+			checks = append(checks, StatusCheck{Name: step.name, Status: statusSkipped})
+			continue
+		}
+
+		stepCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+		start := time.Now()
+		detail, err := step.run(stepCtx)
+		check := StatusCheck{Name: step.name, Status: statusOK, LatencyMs: time.Since(start).Milliseconds(), Detail: detail}
+		cancel()
+		if err != nil {
+			check.Status, check.err = statusFail, err
+			check.Detail, check.Hint = splitHint(err)
+			failed = true
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkReachability resolves the API host, or the proxy in front of it, and
+// opens a TCP connection to it
+func checkReachability(ctx context.Context, config *Config) (string, error) {
+	target, check := checkAPIURL(config)
+	if !check.OK {
+		return "", withKind(ErrUsage, fmt.Errorf("%s\nHint: %s", check.Detail, check.Hint))
+	}
+	proxy, check := checkProxy(target)
+	if !check.OK {
+		return "", fmt.Errorf("%s\nHint: %s", check.Detail, check.Hint)
+	}
+	dialURL, via := target, ""
+	if proxy != nil {
+		dialURL, via = proxy, " via proxy"
+	}
+
+	host := dialURL.Hostname()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return "", networkError(err)
+	}
+	address := net.JoinHostPort(host, urlPort(dialURL))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		if config.Provider == providerOllama && proxy == nil {
+			return "", withKind(ErrNetwork, fmt.Errorf("%w\nHint: %s", err, ollamaStartHint))
+		}
+		return "", networkError(err)
+	}
+	conn.Close()
+	return fmt.Sprintf("%s%s (%s)", address, via, strings.Join(addrs, ", ")), nil
+}
+
+// networkError tags a failure to connect with its hint
+func networkError(err error) error {
+	if hint := networkHint(err); hint != "" {
+		return withKind(ErrNetwork, fmt.Errorf("%w\nHint: %s", err, hint))
+	}
+	return withKind(ErrNetwork, err)
+}
+
+// checkAPIKeyAccepted lists the models, the lightest authenticated request.
+// Providers without a models endpoint answer 404, which leaves the key
+// unchecked rather than failing.
+func checkAPIKeyAccepted(ctx context.Context, config *Config) (string, error) {
+	if err := requireAPIKey(config); err != nil {
+		return "", err
+	}
+	models, err := fetchModels(ctx, config)
+	var failure *APIFailure
+	if errors.As(err, &failure) && failure.StatusCode == 404 {
+		return "no models endpoint, key not checked", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("accepted, %d models available", len(models)), nil
+}
+
+// checkChatCompletion asks the configured model for a single token
+func checkChatCompletion(ctx context.Context, config *Config) (string, error) {
+	chatConfig := *config
+	chatConfig.MaxTokens = 1
+	chatConfig.SystemPrompt = ""
+	chatConfig.Tools = nil
+	response, err := newAPIClient(&chatConfig).Chat(ctx, []Message{{Role: "user", Content: "Say OK"}})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s answered", responseModel(config, response)), nil
+}
+
+// splitHint separates the hint of an error from its message
+func splitHint(err error) (string, string) {
+	var failure *APIFailure
+	if errors.As(err, &failure) {
+		return failure.Message, failure.Hint
+	}
+	message, hint, _ := strings.Cut(err.Error(), "\nHint: ")
+	return message, hint
+}
+
+// statusError is the error of a failed check, of the same kind so that the
+// exit status tells what failed
+func statusError(check StatusCheck) error {
+	err := fmt.Errorf("%s check failed", check.Name)
+	for _, kind := range []error{ErrUsage, ErrAuth, ErrRateLimit, ErrServer, ErrNetwork, ErrRequestBudget} {
+		if errors.Is(check.err, kind) {
+			return withKind(kind, err)
+		}
+	}
+	if isCancelled(check.err) {
+		return errCancelled
+	}
+	return err
+}
+
+// printStatusChecks prints one line per check with its latency, and the
+// hint under a failure
+func printStatusChecks(config *Config, output StatusOutput) {
+	fmt.Printf("Checking %s with model %s for profile %s\n\n", output.Provider, output.Model, profileDisplayName(config.Profile))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, check := range output.Checks {
+		switch check.Status {
+		case statusOK:
+			fmt.Fprintf(w, "OK\t%s\t%dms\t%s\n", check.Name, check.LatencyMs, check.Detail)
+		case statusFail:
+			fmt.Fprintf(w, "FAIL\t%s\t%dms\t%s\n", check.Name, check.LatencyMs, check.Detail)
+			if check.Hint != "" {
+				fmt.Fprintf(w, "\t\t\tHint: %s\n", check.Hint)
+			}
+		default:
+			fmt.Fprintf(w, "SKIP\t%s\n", check.Name)
+		}
+	}
+	w.Flush()
+}
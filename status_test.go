@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statusNames returns the names of checks with their status, such as
+// "reachability ok"
+func statusNames(checks []StatusCheck) string {
+	var names []string
+	for _, check := range checks {
+		names = append(names, check.Name+" "+check.Status)
+	}
+	return strings.Join(names, ", ")
+}
+
+// TestRunStatusChecks tests each failure mode of the checks against a local
+// server
+func TestRunStatusChecks(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	originalDelay := retryDelay
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = originalDelay }()
+
+	healthy := func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/models") {
+			w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+			return
+		}
+		w.Write([]byte(`{"model":"gpt-4o-2024-08-06","choices":[{"message":{"role":"assistant","content":"OK"}}]}`))
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		apiURL  string
+		apiKey  string
+		deep    bool
+		want    string
+		hint    string
+		kind    error
+	}{
+		{
+			name:    "healthy",
+			handler: healthy,
+			deep:    true,
+			want:    "reachability ok, api key ok, chat ok",
+		},
+		{
+			name:    "chat not checked without deep",
+			handler: healthy,
+			want:    "reachability ok, api key ok",
+		},
+		{
+			name:   "unreachable",
+			apiURL: closedURL,
+			deep:   true,
+			want:   "reachability fail, api key skipped, chat skipped",
+			kind:   ErrNetwork,
+		},
+		{
+			name: "rejected key",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`))
+			},
+			want: "reachability ok, api key fail",
+			hint: envAPIKey,
+			kind: ErrAuth,
+		},
+		{
+			name:    "missing key",
+			handler: healthy,
+			apiKey:  "-",
+			want:    "reachability ok, api key fail",
+			hint:    envAPIKey,
+			kind:    ErrAuth,
+		},
+		{
+			name: "no models endpoint",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/models") {
+					http.NotFound(w, r)
+					return
+				}
+				healthy(w, r)
+			},
+			deep: true,
+			want: "reachability ok, api key ok, chat ok",
+		},
+		{
+			name: "chat fails",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/models") {
+					healthy(w, r)
+					return
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error":{"message":"The server had an error","type":"server_error"}}`))
+			},
+			deep: true,
+			want: "reachability ok, api key ok, chat fail",
+			kind: ErrServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiURL := tt.apiURL
+			if tt.handler != nil {
+				server := httptest.NewServer(tt.handler)
+				defer server.Close()
+				apiURL = server.URL
+			}
+			apiKey := "test-key"
+			if tt.apiKey == "-" {
+				apiKey = ""
+			}
+			config := &Config{
+				Provider:  providerOpenAI,
+				Model:     "gpt-4o",
+				APIKey:    apiKey,
+				APIURL:    apiURL + "/v1/chat/completions",
+				ModelsURL: apiURL + "/v1/models",
+				MaxTokens: 100,
+				Timeout:   5 * time.Second,
+			}
+
+			checks := runStatusChecks(context.Background(), config, tt.deep)
+			if got := statusNames(checks); got != tt.want {
+				t.Fatalf("checks = %s, want %s", got, tt.want)
+			}
+
+			var failed *StatusCheck
+			for i := range checks {
+				if checks[i].Status == statusFail {
+					failed = &checks[i]
+				}
+			}
+			if tt.kind == nil {
+				if failed != nil {
+					t.Errorf("%s failed: %v", failed.Name, failed.err)
+				}
+				return
+			}
+			if failed == nil {
+				t.Fatal("no check failed")
+			}
+			if err := statusError(*failed); !errors.Is(err, tt.kind) {
+				t.Errorf("statusError() = %v, want %v", err, tt.kind)
+			}
+			// A missing key is explained by the message itself, a rejected one
+			// by the hint
+			if !strings.Contains(failed.Detail+" "+failed.Hint, tt.hint) {
+				t.Errorf("detail = %q, hint = %q, want them to mention %q", failed.Detail, failed.Hint, tt.hint)
+			}
+		})
+	}
+}
+
+// TestStatusCommand tests the printed report, the JSON output and the exit
+// status of a failed check
+func TestStatusCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Provider:  providerOpenAI,
+		Model:     "gpt-4o",
+		APIKey:    "bad-key",
+		APIURL:    server.URL + "/v1/chat/completions",
+		ModelsURL: server.URL + "/v1/models",
+		Timeout:   5 * time.Second,
+	}
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = statusCommand(config, []string{"--deep"})
+	})
+	if exitCode(err) != exitAuth {
+		t.Errorf("statusCommand() error = %v, want exit status %d", err, exitAuth)
+	}
+	for _, s := range []string{"OK    reachability", "FAIL  api key", "Incorrect API key provided", "Hint: ", "SKIP  chat"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output = %q, want it to contain %q", out, s)
+		}
+	}
+
+	config.Output = outputJSON
+	out = captureOutput(t, &os.Stdout, func() {
+		err = statusCommand(config, []string{"--timeout", "2s"})
+	})
+	var output StatusOutput
+	if jsonErr := json.Unmarshal([]byte(out), &output); jsonErr != nil {
+		t.Fatalf("output is not JSON: %v\n%s", jsonErr, out)
+	}
+	if got := statusNames(output.Checks); got != "reachability ok, api key fail" {
+		t.Errorf("JSON checks = %s", got)
+	}
+
+	if err := statusCommand(config, []string{"--timeout", "0s"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("--timeout 0s error = %v, want a usage error", err)
+	}
+}
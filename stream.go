@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Maximum size of a single server-sent event line
+const maxStreamLineSize = 1024 * 1024
+
+// ChatStreamChunk is a single server-sent event of a streaming chat completion
+type ChatStreamChunk struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []StreamChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"`
+	Error   *APIError      `json:"error,omitempty"`
+	// SystemFingerprint is sent with every chunk
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+}
+
+type StreamChoice struct {
+	Index        int     `json:"index"`
+	Delta        Message `json:"delta"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// ChatStream sends a conversation as a streaming request and writes each
+// content delta to w as it arrives. The assembled response is returned so
+// callers can log it. If the server does not answer with an event stream, the
+// response is parsed as a regular completion and written to w in one go.
+func (c *APIClient) ChatStream(ctx context.Context, messages []Message, w io.Writer) (response *ChatResponse, err error) {
+	ctx, span := c.startRequestSpan(ctx)
+	span.SetBool("gen_ai.request.stream", true)
+	defer func() { endRequestSpan(span, response, err) }()
+
+	resp, err := c.send(ctx, messages, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	provider := chatProviderFor(c.config)
+
+	// Fall back to a regular response if the server doesn't stream
+	if !isStreamContentType(resp.Header.Get("Content-Type")) {
+		response, err := provider.ReadResponse(resp)
+		if err != nil {
+			return withRequestID(resp, nil, err)
+		}
+		fmt.Fprintln(w, formatResponse(response))
+		return withRequestID(resp, response, nil)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return withRequestID(resp, nil, newStatusError(resp.StatusCode, body))
+	}
+
+	response, err = provider.ReadStream(resp.Body, w)
+	return withRequestID(resp, response, err)
+}
+
+// isStreamContentType reports whether a response is streamed, either as
+// server-sent events or as Ollama's newline-delimited JSON
+func isStreamContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "text/event-stream") || strings.HasPrefix(contentType, "application/x-ndjson")
+}
+
+// readChatStream parses "data:" events from r until the [DONE] terminator or
+// end of input, writing content deltas of the first choice to w.
+func readChatStream(r io.Reader, w io.Writer) (*ChatResponse, error) {
+	response := &ChatResponse{Object: "chat.completion"}
+	choice := Choice{Message: Message{Role: "assistant"}}
+	hasChoice := false
+
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLineSize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Skip blank separators, comments and non-data fields
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		if chunk.Error != nil {
+			return nil, newAPIError(0, chunk.Error)
+		}
+
+		if response.ID == "" {
+			response.ID = chunk.ID
+			response.Created = chunk.Created
+			response.Model = chunk.Model
+		}
+		if chunk.SystemFingerprint != "" {
+			response.SystemFingerprint = chunk.SystemFingerprint
+		}
+
+		// Usage arrives in a final chunk without choices
+		if chunk.Usage != nil {
+			response.Usage = *chunk.Usage
+		}
+
+		for _, c := range chunk.Choices {
+			if c.Index != 0 {
+				continue
+			}
+			hasChoice = true
+
+			if c.Delta.Role != "" {
+				choice.Message.Role = c.Delta.Role
+			}
+			if c.Delta.Content != "" {
+				content.WriteString(c.Delta.Content)
+				fmt.Fprint(w, c.Delta.Content)
+			}
+			if c.FinishReason != "" {
+				choice.FinishReason = c.FinishReason
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if !hasChoice {
+		fmt.Fprintln(w, formatResponse(response))
+		return response, nil
+	}
+
+	// Terminate the streamed output with a newline
+	fmt.Fprintln(w)
+
+	choice.Message.Content = content.String()
+	response.Choices = []Choice{choice}
+
+	return response, nil
+}
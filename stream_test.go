@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStreamServer returns a test server that replies with the given SSE events
+func newStreamServer(t *testing.T, events []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if !request.Stream {
+			t.Errorf("request.Stream = false, want true")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "%s\n\n", event)
+		}
+	}))
+}
+
+// TestSendChatRequestStream tests parsing of streamed completions
+func TestSendChatRequestStream(t *testing.T) {
+	tests := []struct {
+		name           string
+		events         []string
+		wantErr        bool
+		errContains    string
+		expectedOutput string
+		expectedText   string
+		expectedReason string
+	}{
+		{
+			name: "deltas are printed and assembled",
+			events: []string{
+				`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[{"index":0,"delta":{"role":"assistant"}}]}`,
+				`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[{"index":0,"delta":{"content":"Hello"}}]}`,
+				`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[{"index":0,"delta":{"content":", world"}}]}`,
+				`data: {"id":"chatcmpl-1","model":"gpt-4","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+				`data: [DONE]`,
+			},
+			expectedOutput: "Hello, world\n",
+			expectedText:   "Hello, world",
+			expectedReason: "stop",
+		},
+		{
+			name: "comments and events after DONE are ignored",
+			events: []string{
+				`: keep-alive`,
+				`data: {"id":"chatcmpl-2","choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+				`data: [DONE]`,
+				`data: {"id":"chatcmpl-2","choices":[{"index":0,"delta":{"content":"ignored"}}]}`,
+			},
+			expectedOutput: "Hi\n",
+			expectedText:   "Hi",
+		},
+		{
+			name: "stream without choices",
+			events: []string{
+				`data: [DONE]`,
+			},
+			expectedOutput: "No response received from ChatGPT\n",
+			expectedText:   "No response received from ChatGPT",
+		},
+		{
+			name: "error event",
+			events: []string{
+				`data: {"error":{"message":"Rate limit reached","type":"rate_limit_error"}}`,
+			},
+			wantErr:     true,
+			errContains: "API error: Rate limit reached",
+		},
+		{
+			name: "malformed chunk",
+			events: []string{
+				`data: {not json`,
+			},
+			wantErr:     true,
+			errContains: "failed to parse stream chunk",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStreamServer(t, tt.events)
+			defer server.Close()
+
+			config := &Config{
+				APIKey:  "test-key",
+				APIURL:  server.URL,
+				Model:   "gpt-4",
+				Timeout: 10 * time.Second,
+			}
+
+			var out bytes.Buffer
+			response, err := newAPIClient(config).ChatStream(context.Background(), promptMessages(config, "test prompt"), &out)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ChatStream() expected error, got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ChatStream() unexpected error: %v", err)
+			}
+			if out.String() != tt.expectedOutput {
+				t.Errorf("output = %q, want %q", out.String(), tt.expectedOutput)
+			}
+			if got := formatResponse(response); got != tt.expectedText {
+				t.Errorf("formatResponse() = %q, want %q", got, tt.expectedText)
+			}
+			if tt.expectedReason != "" && response.Choices[0].FinishReason != tt.expectedReason {
+				t.Errorf("FinishReason = %q, want %q", response.Choices[0].FinishReason, tt.expectedReason)
+			}
+		})
+	}
+}
+
+// TestSendChatRequestStreamFallback tests servers that ignore the stream flag
+func TestSendChatRequestStreamFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverHandler http.HandlerFunc
+		wantErr       bool
+		errContains   string
+		expected      string
+	}{
+		{
+			name: "plain JSON response",
+			serverHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(ChatResponse{
+					ID: "test-id",
+					Choices: []Choice{
+						{Message: Message{Role: "assistant", Content: "  Full response  "}},
+					},
+				})
+			}),
+			expected: "Full response\n",
+		},
+		{
+			name: "non-200 status code",
+			serverHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error": "unauthorized"}`)
+			}),
+			wantErr:     true,
+			errContains: "unexpected status code: 401",
+		},
+		{
+			name: "non-200 status code with event stream",
+			serverHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.WriteHeader(http.StatusInternalServerError)
+			}),
+			wantErr:     true,
+			errContains: "unexpected status code: 500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.serverHandler)
+			defer server.Close()
+
+			config := &Config{
+				APIKey:  "test-key",
+				APIURL:  server.URL,
+				Model:   "gpt-3.5-turbo",
+				Timeout: 10 * time.Second,
+			}
+
+			var out bytes.Buffer
+			_, err := newAPIClient(config).ChatStream(context.Background(), promptMessages(config, "test prompt"), &out)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ChatStream() expected error, got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("error = %q, want error containing %q", err.Error(), tt.errContains)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ChatStream() unexpected error: %v", err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("output = %q, want %q", out.String(), tt.expected)
+			}
+		})
+	}
+}
+
+// TestPromptCommandStream tests that prompt streams by default and honors --no-stream
+func TestPromptCommandStream(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var streamed []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		streamed = append(streamed, request.Stream)
+
+		if request.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"streamed\"}}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			Choices: []Choice{{Message: Message{Content: "buffered"}}},
+		})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{
+		APIKey:    "test-key",
+		APIURL:    server.URL,
+		Model:     "gpt-3.5-turbo",
+		Timeout:   10 * time.Second,
+		Stream:    true,
+		ConfigDir: tmpDir,
+	}
+
+	if err := promptCommand(config, []string{"hello"}); err != nil {
+		t.Fatalf("promptCommand() error = %v", err)
+	}
+	if err := promptCommand(config, []string{"hello", "--no-stream"}); err != nil {
+		t.Fatalf("promptCommand() with --no-stream error = %v", err)
+	}
+
+	if len(streamed) != 2 || !streamed[0] || streamed[1] {
+		t.Errorf("stream flags sent = %v, want [true false]", streamed)
+	}
+
+	// Both interactions are logged with the full response text
+	data, err := os.ReadFile(filepath.Join(tmpDir, "logs.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log entries, got %d", len(lines))
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("failed to parse log entry: %v", err)
+	}
+	if entry.Response != "streamed" {
+		t.Errorf("entry.Response = %q, want %q", entry.Response, "streamed")
+	}
+}
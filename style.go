@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Directory in the config directory holding the user-defined styles, one
+// <name>.txt file each
+const stylesDirName = "styles"
+
+// Extension of the style files
+const styleFileExt = ".txt"
+
+// Subcommands of the style command
+var styleSubcommands = []string{"list"}
+
+// Sources of a style in style list
+const (
+	styleSourceBuiltin = "built-in"
+	styleSourceUser    = "user"
+)
+
+// builtinStyles are the --style presets, added to the system prompt
+var builtinStyles = map[string]string{
+	"concise":       "Be concise. Answer in as few words as the question allows, without preamble, caveats or repetition.",
+	"detailed":      "Be thorough. Explain your reasoning step by step, cover the edge cases and give examples.",
+	"eli5":          "Explain it like I'm five. Use simple words, short sentences and everyday analogies, and avoid jargon.",
+	"formal":        "Use a formal, professional tone, without slang, jokes or emoji.",
+	"bullet-points": "Format the answer as a bulleted list of short points rather than paragraphs.",
+}
+
+// StyleEntry is one style in the JSON output of style list
+type StyleEntry struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Text   string `json:"text"`
+}
+
+// styleCommand manages the --style presets
+func styleCommand(config *Config, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("style subcommand required\nUsage: chatgpt-cli style <%s>", strings.Join(styleSubcommands, "|"))
+	}
+
+	switch args[0] {
+	case "list":
+		return styleListCommand(config, args[1:])
+	default:
+		return usageErrorf("unknown style subcommand: %s\nValid subcommands: %s", args[0], strings.Join(styleSubcommands, ", "))
+	}
+}
+
+// styleListCommand prints the built-in and user-defined styles sorted by
+// name
+func styleListCommand(config *Config, args []string) error {
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\nUsage: chatgpt-cli style list", args[0])
+	}
+
+	styles, err := loadStyles(config.ConfigDir)
+	if err != nil {
+		return err
+	}
+
+	entries := []StyleEntry{}
+	for _, name := range styleNames(styles) {
+		entries = append(entries, styles[name])
+	}
+
+	if config.Output == outputJSON {
+		return printJSON(entries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSOURCE\tTEXT")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Name, entry.Source, truncate(oneLine(entry.Text), 60))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nAdd your own as %s\n", filepath.Join(config.ConfigDir, stylesDirName, "<name>"+styleFileExt))
+	return nil
+}
+
+// loadStyles returns the built-in styles and those of the styles directory,
+// keyed by name. A user-defined style replaces the built-in one of the same
+// name.
+func loadStyles(configDir string) (map[string]StyleEntry, error) {
+	styles := make(map[string]StyleEntry, len(builtinStyles))
+	for name, text := range builtinStyles {
+		styles[name] = StyleEntry{Name: name, Source: styleSourceBuiltin, Text: text}
+	}
+
+	dir := filepath.Join(configDir, stylesDirName)
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return styles, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read styles: %w", err)
+	}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != styleFileExt {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read style: %w", err)
+		}
+		text := strings.TrimSpace(string(data))
+		if text == "" {
+			continue
+		}
+		name := strings.TrimSuffix(file.Name(), styleFileExt)
+		styles[name] = StyleEntry{Name: name, Source: styleSourceUser, Text: text}
+	}
+	return styles, nil
+}
+
+// resolveStyles looks up the comma-separated styles of --style and returns
+// their names and their texts joined in order
+func resolveStyles(configDir, list string) ([]string, string, error) {
+	styles, err := loadStyles(configDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var names, texts []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		style, ok := styles[name]
+		if !ok {
+			return nil, "", usageErrorf("unknown style %q; available styles: %s", name, strings.Join(styleNames(styles), ", "))
+		}
+		names = append(names, name)
+		texts = append(texts, style.Text)
+	}
+	if len(names) == 0 {
+		return nil, "", usageErrorf("--style needs a style name; see: chatgpt-cli style list")
+	}
+	return names, strings.Join(texts, "\n\n"), nil
+}
+
+// appendSystemPrompt adds text after the system prompt, if there is one
+func appendSystemPrompt(systemPrompt, text string) string {
+	if systemPrompt == "" {
+		return text
+	}
+	return systemPrompt + "\n\n" + text
+}
+
+// styleNames returns the names of the styles, sorted
+func styleNames(styles map[string]StyleEntry) []string {
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
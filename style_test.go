@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestStyle saves a user-defined style in the config directory
+func writeTestStyle(t *testing.T, configDir, name, text string) {
+	t.Helper()
+
+	dir := filepath.Join(configDir, stylesDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+styleFileExt), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestResolveStyles tests looking up and combining styles
+func TestResolveStyles(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestStyle(t, tmpDir, "pirate", "Talk like a pirate.\n")
+	writeTestStyle(t, tmpDir, "formal", "Use a very formal tone.")
+
+	tests := []struct {
+		list      string
+		wantNames string
+		wantText  string
+		wantErr   string
+	}{
+		{"concise", "concise", builtinStyles["concise"], ""},
+		{"concise, eli5", "concise,eli5", builtinStyles["concise"] + "\n\n" + builtinStyles["eli5"], ""},
+		{"pirate", "pirate", "Talk like a pirate.", ""},
+		{"formal", "formal", "Use a very formal tone.", ""},
+		{"concise,shouty", "", "", `unknown style "shouty"; available styles: bullet-points, concise, detailed, eli5, formal, pirate`},
+		{",", "", "", "--style needs a style name"},
+	}
+
+	for _, tt := range tests {
+		names, text, err := resolveStyles(tmpDir, tt.list)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || exitCode(err) != exitUsage {
+				t.Errorf("resolveStyles(%q) error = %v, want a usage error containing %q", tt.list, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || strings.Join(names, ",") != tt.wantNames || text != tt.wantText {
+			t.Errorf("resolveStyles(%q) = %q, %q, %v; want %q, %q", tt.list, names, text, err, tt.wantNames, tt.wantText)
+		}
+	}
+}
+
+// TestStyleListCommand tests listing the built-in and user-defined styles
+func TestStyleListCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestStyle(t, tmpDir, "pirate", "Talk like a pirate.")
+	config := &Config{ConfigDir: tmpDir}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := styleListCommand(config, nil); err != nil {
+			t.Fatalf("style list error = %v", err)
+		}
+	})
+	for _, want := range []string{"NAME", "bullet-points", "eli5", "pirate", "user", "Talk like a pirate.", filepath.Join(tmpDir, "styles", "<name>.txt")} {
+		if !strings.Contains(out, want) {
+			t.Errorf("style list output = %s\nwant it to contain %q", out, want)
+		}
+	}
+
+	config.Output = outputJSON
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := styleListCommand(config, nil); err != nil {
+			t.Fatalf("style list error = %v", err)
+		}
+	})
+	var entries []StyleEntry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if len(entries) != len(builtinStyles)+1 || entries[0].Name != "bullet-points" || entries[0].Source != styleSourceBuiltin {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	if err := styleCommand(config, []string{"add"}); exitCode(err) != exitUsage {
+		t.Errorf("style add error = %v, want a usage error", err)
+	}
+}
+
+// TestPromptCommandStyle tests that styles go in the system message, shown
+// by --dry-run, and are logged with the prompt
+func TestPromptCommandStyle(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var request ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	newConfig := func() *Config {
+		return &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: tmpDir}
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(newConfig(), []string{"--dry-run", "--style", "eli5", "what is DNS?"}); err != nil {
+			t.Fatalf("prompt --dry-run error = %v", err)
+		}
+	})
+	if !strings.Contains(out, `"role": "system"`) || !strings.Contains(out, builtinStyles["eli5"]) {
+		t.Errorf("dry run output = %s\nwant the style in a system message", out)
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(newConfig(), []string{"--no-stream", "--style", "concise,formal", "what is DNS?"}); err != nil {
+			t.Fatalf("prompt --style error = %v", err)
+		}
+	})
+	want := builtinStyles["concise"] + "\n\n" + builtinStyles["formal"]
+	if len(request.Messages) != 2 || request.Messages[0].Role != "system" || request.Messages[0].Content != want {
+		t.Errorf("messages = %+v, want the styles in a system message", request.Messages)
+	}
+
+	entries := readTestLogEntries(t, tmpDir)
+	if len(entries) != 1 || entries[0].Style != "concise,formal" {
+		t.Errorf("log entries = %+v, want the styles logged", entries)
+	}
+	out = captureOutput(t, &os.Stdout, func() {
+		printLogEntries(newUI(&Config{NoColor: true}, os.Stdout), entries, defaultLogPreviewLen, timestampFormat{})
+	})
+	if !strings.Contains(out, "prompt (style concise,formal)") {
+		t.Errorf("logs output = %s\nwant the styles shown", out)
+	}
+
+	err := promptCommand(newConfig(), []string{"--style", "shouty", "hi"})
+	if exitCode(err) != exitUsage || !strings.Contains(err.Error(), "available styles: bullet-points, concise") {
+		t.Errorf("unknown style error = %v, want a usage error listing the styles", err)
+	}
+}
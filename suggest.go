@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"sort"
+)
+
+// Environment variable running the command an unknown one is closest to,
+// instead of only suggesting it
+const envAutocorrect = "CHATGPT_CLI_AUTOCORRECT"
+
+// Most edits between an unknown command and a command it could be a typo of
+const maxSuggestDistance = 2
+
+// autocorrect reports whether CHATGPT_CLI_AUTOCORRECT is enabled
+func autocorrect() bool {
+	return parseBoolOrDefault(os.Getenv(envAutocorrect), false)
+}
+
+// levenshtein returns the number of characters to insert, delete or replace
+// to turn a into b
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(t)]
+}
+
+// suggestCommand returns the candidate name is most likely a typo of: the
+// closest within maxSuggestDistance edits, and of those the one sharing the
+// longest prefix with name, then the first in alphabetical order. Names so
+// short that every character would be an edit get no suggestion.
+func suggestCommand(name string, candidates []string) (string, bool) {
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+
+	best, bestDistance, bestPrefix := "", maxSuggestDistance+1, -1
+	for _, candidate := range sorted {
+		distance := levenshtein(name, candidate)
+		if distance >= len([]rune(name)) {
+			continue
+		}
+		prefix := commonPrefixLen(name, candidate)
+		if distance < bestDistance || distance == bestDistance && prefix > bestPrefix {
+			best, bestDistance, bestPrefix = candidate, distance, prefix
+		}
+	}
+	return best, best != ""
+}
+
+// commonPrefixLen returns how many leading characters a and b share
+func commonPrefixLen(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	n := 0
+	for n < len(s) && n < len(t) && s[n] == t[n] {
+		n++
+	}
+	return n
+}
+
+// commandSuggestions returns the names an unknown command is matched
+// against: the visible commands and the user's aliases
+func commandSuggestions(config *Config, commands map[string]Command) []string {
+	var names []string
+	for name, command := range commands {
+		if !command.Hidden {
+			names = append(names, name)
+		}
+	}
+	if aliases, err := loadAliases(config.ConfigDir); err == nil {
+		names = append(names, aliasNames(aliases)...)
+	}
+	return names
+}
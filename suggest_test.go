@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestLevenshtein tests the edit distance between two names
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"prompt", "prompt", 0},
+		{"promt", "prompt", 1},
+		{"pormpt", "prompt", 2},
+		{"", "logs", 4},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+// TestSuggestCommand tests which command is suggested when several are close
+func TestSuggestCommand(t *testing.T) {
+	candidates := []string{"prompt", "profile", "logs", "log", "models", "model", "stats", "state", "init"}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"promt", "prompt"},
+		// The closest wins over one also within reach
+		{"modls", "models"},
+		{"lgo", "log"},
+		// At the same distance, the longest shared prefix wins...
+		{"stata", "state"},
+		// ...then alphabetical order
+		{"stat", "state"},
+		{"prmpt", "prompt"},
+		{"completely-different", ""},
+		{"xy", ""},
+	}
+	for _, tt := range tests {
+		got, ok := suggestCommand(tt.name, candidates)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("suggestCommand(%q) = %q, %v, want %q", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+// TestCommandSuggestions tests that aliases are suggested and hidden
+// commands are not
+func TestCommandSuggestions(t *testing.T) {
+	dir := t.TempDir()
+	if err := saveAliases(dir, map[string]string{"summarize": "Summarize this"}); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{ConfigDir: dir}
+
+	names := strings.Join(commandSuggestions(config, getCommands()), " ")
+	if !strings.Contains(names, "summarize") || !strings.Contains(names, "prompt") || strings.Contains(names, "__complete") {
+		t.Errorf("commandSuggestions() = %s", names)
+	}
+	if got, _ := suggestCommand("sumarize", commandSuggestions(config, getCommands())); got != "summarize" {
+		t.Errorf("suggestion for sumarize = %q, want the alias", got)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Spaces between the columns of a table without borders
+	tableGap = 2
+	// Columns are not narrowed below this width to fit the terminal
+	minTableColumnWidth = 4
+	// Marks a cell cut to fit its column
+	tableEllipsis = "…"
+)
+
+// Code points shown two columns wide: the wide and fullwidth ranges of
+// Unicode's East Asian Width property, which cover CJK text and most emoji
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},
+	{0x231A, 0x231B},
+	{0x2329, 0x232A},
+	{0x23E9, 0x23EC},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xA960, 0xA97F},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x2FFFD},
+	{0x30000, 0x3FFFD},
+}
+
+// table lays out rows in columns for the listings of config list, logs
+// --group and stats. On a terminal the columns are aligned by display width
+// and cut with an ellipsis to fit its width; otherwise rows are written
+// tab-separated, for cut and awk.
+type table struct {
+	headers []string
+	rows    [][]string
+	// borders draws a box around the table and between its columns
+	borders bool
+}
+
+// newTable returns a table with the given column headers, or without a
+// header row when there are none
+func newTable(headers ...string) *table {
+	return &table{headers: headers}
+}
+
+// addRow adds a row of cells, which may be styled with out's colors
+func (t *table) addRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// render writes the table to out, aligned when out is a terminal
+func (t *table) render(out *ui) {
+	if !out.tty {
+		t.renderPlain(out)
+		return
+	}
+
+	widths := t.columnWidths(out.width)
+	if t.borders {
+		out.Println(out.dim(t.rule(widths, "┌", "┬", "┐")))
+	}
+	if len(t.headers) > 0 {
+		headers := make([]string, len(t.headers))
+		for i, header := range t.headers {
+			headers[i] = out.bold(header)
+		}
+		t.renderRow(out, headers, widths)
+		if t.borders {
+			out.Println(out.dim(t.rule(widths, "├", "┼", "┤")))
+		}
+	}
+	for _, row := range t.rows {
+		t.renderRow(out, row, widths)
+	}
+	if t.borders {
+		out.Println(out.dim(t.rule(widths, "└", "┴", "┘")))
+	}
+}
+
+// renderPlain writes the header and rows tab-separated, without styles
+func (t *table) renderPlain(out *ui) {
+	if len(t.headers) > 0 {
+		out.Println(strings.Join(t.headers, "\t"))
+	}
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = flattenCell(stripANSI(cell))
+		}
+		out.Println(strings.Join(cells, "\t"))
+	}
+}
+
+// columnWidths returns the display width of each column, narrowing the
+// widest columns in turn while the table is wider than maxWidth, if set
+func (t *table) columnWidths(maxWidth int) []int {
+	var widths []int
+	measure := func(cells []string) {
+		for i, cell := range cells {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := displayWidth(flattenCell(cell)); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	measure(t.headers)
+	for _, row := range t.rows {
+		measure(row)
+	}
+	if maxWidth <= 0 || len(widths) == 0 {
+		return widths
+	}
+
+	total := (len(widths) - 1) * tableGap
+	if t.borders {
+		total = 3*len(widths) + 1
+	}
+	for _, w := range widths {
+		total += w
+	}
+	for total > maxWidth {
+		widest := 0
+		for i, w := range widths {
+			if w >= widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minTableColumnWidth {
+			break
+		}
+		widths[widest]--
+		total--
+	}
+	return widths
+}
+
+// renderRow writes one row, each cell cut and padded to its column. Without
+// borders the last cell is not padded, so lines have no trailing spaces.
+func (t *table) renderRow(out *ui, row []string, widths []int) {
+	var line strings.Builder
+	if t.borders {
+		line.WriteString(out.dim("│") + " ")
+	}
+	for i, width := range widths {
+		cell := ""
+		if i < len(row) {
+			cell = truncateWidth(flattenCell(row[i]), width)
+		}
+		line.WriteString(cell)
+		last := i == len(widths)-1
+		switch {
+		case t.borders:
+			line.WriteString(strings.Repeat(" ", width-displayWidth(cell)) + " " + out.dim("│"))
+			if !last {
+				line.WriteString(" ")
+			}
+		case !last:
+			line.WriteString(strings.Repeat(" ", width-displayWidth(cell)+tableGap))
+		}
+	}
+	out.Println(strings.TrimRight(line.String(), " "))
+}
+
+// rule returns a border line of the table
+func (t *table) rule(widths []int, left, middle, right string) string {
+	parts := make([]string, len(widths))
+	for i, width := range widths {
+		parts[i] = strings.Repeat("─", width+2)
+	}
+	return left + strings.Join(parts, middle) + right
+}
+
+// flattenCell puts a cell on one line
+func flattenCell(s string) string {
+	return strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ").Replace(s)
+}
+
+// displayWidth returns the number of terminal columns s takes, not counting
+// ANSI escape sequences
+func displayWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); {
+		if n := ansiSequenceLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		width += runeWidth(r)
+		i += size
+	}
+	return width
+}
+
+// truncateWidth cuts s to width columns, ending it with an ellipsis when it
+// is cut. Escape sequences after the cut are kept, so styles are still
+// turned off.
+func truncateWidth(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	limit := width - displayWidth(tableEllipsis)
+	var b strings.Builder
+	used, cut := 0, false
+	for i := 0; i < len(s); {
+		if n := ansiSequenceLen(s[i:]); n > 0 {
+			b.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if cut {
+			continue
+		}
+		if w := runeWidth(r); used+w <= limit {
+			b.WriteRune(r)
+			used += w
+			continue
+		}
+		b.WriteString(tableEllipsis)
+		cut = true
+	}
+	return b.String()
+}
+
+// runeWidth returns the number of terminal columns r takes: none for
+// combining marks and format characters, such as zero-width joiners, and two
+// for wide characters
+func runeWidth(r rune) int {
+	if r == 0 || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r >= wide.lo && r <= wide.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// ansiSequenceLen returns the length of the CSI escape sequence s starts
+// with, as written by ui's styles, or 0
+func ansiSequenceLen(s string) int {
+	if !strings.HasPrefix(s, "\x1b[") {
+		return 0
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// stripANSI removes the escape sequences of s
+func stripANSI(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if n := ansiSequenceLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(s[i : i+size])
+		i += size
+	}
+	return b.String()
+}
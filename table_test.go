@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testTable is the table of the table golden files, with wide and combining
+// characters
+func testTable() *table {
+	t := newTable("NAME", "LANGUAGE", "GREETING")
+	t.addRow("ascii", "English", "Hello, how are you doing today?")
+	t.addRow("cjk", "日本語", "こんにちは、お元気ですか")
+	t.addRow("hangul", "한국어", "안녕하세요")
+	t.addRow("accents", "Français", "Bonjour, ça va ?")
+	t.addRow("combining", "e\u0301 n\u0303", "cafe\u0301")
+	return t
+}
+
+// TestDisplayWidth tests the columns taken by narrow, wide, combining and
+// styled text
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"hello", 5},
+		{"日本語", 6},
+		{"안녕", 4},
+		{"ｆｕｌｌ", 8},
+		{"cafe\u0301", 4},
+		{"a\u200db", 2},
+		{ansiBold + "bold" + ansiBoldOff, 4},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := displayWidth(tt.s); got != tt.want {
+			t.Errorf("displayWidth(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestTruncateWidth tests cutting text to a number of columns
+func TestTruncateWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"hello", 5, "hello"},
+		{"hello world", 8, "hello w…"},
+		{"日本語テキスト", 6, "日本…"},
+		{"日本語テキスト", 7, "日本語…"},
+		{ansiDim + "hello world" + ansiBoldOff, 6, ansiDim + "hello…" + ansiBoldOff},
+	}
+	for _, tt := range tests {
+		got := truncateWidth(tt.s, tt.width)
+		if got != tt.want {
+			t.Errorf("truncateWidth(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+		if displayWidth(got) > tt.width {
+			t.Errorf("truncateWidth(%q, %d) is %d columns wide", tt.s, tt.width, displayWidth(got))
+		}
+	}
+}
+
+// TestTableRender tests the layout of a table on wide and narrow terminals,
+// with and without borders and colors
+func TestTableRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		width   int
+		borders bool
+		color   bool
+	}{
+		{name: "table_cjk", width: 100},
+		{name: "table_narrow", width: 40},
+		{name: "table_borders", width: 44, borders: true},
+		{name: "table_cjk", width: 100, color: true},
+	}
+	for _, tt := range tests {
+		t.Run(goldenName(tt.name, tt.color), func(t *testing.T) {
+			var b strings.Builder
+			table := testTable()
+			table.borders = tt.borders
+			table.render(&ui{w: &b, color: tt.color, tty: true, width: tt.width})
+			got := b.String()
+			checkGolden(t, goldenName(tt.name, tt.color), got)
+
+			for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+				if w := displayWidth(line); w > tt.width {
+					t.Errorf("line %q is %d columns wide, more than %d", line, w, tt.width)
+				}
+				if strings.HasSuffix(line, " ") {
+					t.Errorf("line %q has trailing spaces", line)
+				}
+			}
+		})
+	}
+}
+
+// TestTablePlain tests that a table that is not on a terminal is written
+// tab-separated, without styles
+func TestTablePlain(t *testing.T) {
+	var b strings.Builder
+	out := &ui{w: &b}
+	table := newTable("KEY", "VALUE")
+	table.addRow(ansiDim+"OPENAI_MODEL"+ansiBoldOff, "gpt-4o")
+	table.addRow("NOTE", "two\nlines")
+	table.render(out)
+	if got, want := b.String(), "KEY\tVALUE\nOPENAI_MODEL\tgpt-4o\nNOTE\ttwo lines\n"; got != want {
+		t.Errorf("plain table = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Layout of log timestamps unless CHATGPT_CLI_TIME_FORMAT sets another. The
+// zone is shown, since entries may be compared with dashboards in UTC.
+const defaultTimeFormat = "2006-01-02 15:04:05 MST"
+
+// A time whose every field differs, to tell a layout from plain text
+var layoutProbe = time.Date(2001, 2, 3, 16, 5, 6, 0, time.UTC)
+
+// timestampFormat renders the timestamps of log entries, which are stored in
+// UTC, in the local zone or in UTC, or relative to now
+type timestampFormat struct {
+	layout   string
+	utc      bool
+	relative bool
+	// now is replaced by tests with a fixed clock
+	now func() time.Time
+}
+
+// newTimestampFormat returns the format of logs and logs show, with the
+// layout of CHATGPT_CLI_TIME_FORMAT
+func newTimestampFormat(config *Config, utc, relative bool) timestampFormat {
+	return timestampFormat{layout: parseTimeFormatOrDefault(config.TimeFormat, defaultTimeFormat), utc: utc, relative: relative, now: time.Now}
+}
+
+// format renders t. Entries logged before timestamps were stored in UTC
+// carry the offset they were recorded with, so they are converted the same.
+func (f timestampFormat) format(t time.Time) string {
+	if f.relative {
+		return formatAgo(f.now().Sub(t))
+	}
+	if f.utc {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+	layout := f.layout
+	if layout == "" {
+		layout = defaultTimeFormat
+	}
+	return t.Format(layout)
+}
+
+// formatAgo renders how long ago something happened in its largest unit, such
+// as "2h ago" or "3d ago"
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return "just now"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d/time.Second))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	default:
+		return fmt.Sprintf("%dy ago", int(d/(365*24*time.Hour)))
+	}
+}
+
+// parseTimeFormatOrDefault returns value if it is a Go time layout, or
+// defaultValue
+func parseTimeFormatOrDefault(value, defaultValue string) string {
+	if value == "" || checkTimeFormat(value) != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// timestampFlags are the flags of logs and logs show choosing how
+// timestamps are shown
+type timestampFlags struct {
+	utc      *bool
+	relative *bool
+}
+
+func addTimestampFlags(fs *flag.FlagSet) timestampFlags {
+	return timestampFlags{
+		utc:      fs.Bool("utc", false, "show timestamps in UTC instead of the local time zone"),
+		relative: fs.Bool("relative", false, "show how long ago each entry was logged, such as 2h ago"),
+	}
+}
+
+// format returns the timestamp format the flags ask for
+func (f timestampFlags) format(config *Config) (timestampFormat, error) {
+	if *f.utc && *f.relative {
+		return timestampFormat{}, usageErrorf("--utc and --relative can't be combined")
+	}
+	return newTimestampFormat(config, *f.utc, *f.relative), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTimestampFormat tests log timestamps shown in UTC, in the local zone,
+// with a custom layout and relative to a fixed clock, for entries stored in
+// UTC and older ones stored with a local offset
+func TestTimestampFormat(t *testing.T) {
+	originalLocal := time.Local
+	time.Local = time.FixedZone("CEST", 2*60*60)
+	defer func() { time.Local = originalLocal }()
+
+	stored := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	// The same instant, as logged before timestamps were stored in UTC
+	legacy := stored.In(time.FixedZone("", -5*60*60))
+	clock := func() time.Time { return stored.Add(2*time.Hour + 10*time.Minute) }
+
+	tests := []struct {
+		name   string
+		format timestampFormat
+		want   string
+	}{
+		{"local", timestampFormat{layout: defaultTimeFormat, now: clock}, "2024-03-01 11:30:00 CEST"},
+		{"utc", timestampFormat{layout: defaultTimeFormat, utc: true, now: clock}, "2024-03-01 09:30:00 UTC"},
+		{"layout", timestampFormat{layout: "Jan 2 15:04", utc: true, now: clock}, "Mar 1 09:30"},
+		{"relative", timestampFormat{relative: true, now: clock}, "2h ago"},
+	}
+	for _, tt := range tests {
+		for _, ts := range []time.Time{stored, legacy} {
+			if got := tt.format.format(ts); got != tt.want {
+				t.Errorf("%s: format(%v) = %q, want %q", tt.name, ts, got, tt.want)
+			}
+		}
+	}
+}
+
+// TestFormatAgo tests the largest unit of a relative timestamp
+func TestFormatAgo(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{-time.Minute, "just now"},
+		{500 * time.Millisecond, "just now"},
+		{45 * time.Second, "45s ago"},
+		{59 * time.Minute, "59m ago"},
+		{2*time.Hour + 59*time.Minute, "2h ago"},
+		{3*24*time.Hour + time.Hour, "3d ago"},
+		{800 * 24 * time.Hour, "2y ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAgo(tt.d); got != tt.want {
+			t.Errorf("formatAgo(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+// TestLogTimestampsStoredInUTC tests that entries are written in UTC and
+// shown by logs with --utc and --relative
+func TestLogTimestampsStoredInUTC(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{ConfigDir: dir, NoColor: true, TimeFormat: defaultTimeFormat}
+	logged := time.Now().Add(-3 * time.Hour).Truncate(time.Second).In(time.FixedZone("", 3*60*60))
+	if err := writeLogEntry(config, LogEntry{Timestamp: logged, Command: "prompt", Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, logFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"timestamp":"` + logged.UTC().Format(time.RFC3339) + `"`; !strings.Contains(string(data), want) {
+		t.Errorf("log line = %s, want %s", data, want)
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"--utc"}); err != nil {
+			t.Errorf("logs --utc error = %v", err)
+		}
+	})
+	if want := "[1] " + logged.UTC().Format("2006-01-02 15:04:05") + " UTC - prompt"; !strings.Contains(out, want) {
+		t.Errorf("logs --utc output = %q, want %q", out, want)
+	}
+
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(config, []string{"show", "1", "--relative"}); err != nil {
+			t.Errorf("logs show --relative error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "[1] 3h ago - prompt") {
+		t.Errorf("logs show --relative output = %q", out)
+	}
+
+	if err := logsCommand(config, []string{"--utc", "--relative"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("logs --utc --relative error = %v, want a usage error", err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Tokens added by the chat format around each message and before the reply
+const (
+	tokensPerMessage = 4
+	tokensPerReply   = 3
+)
+
+// TokenEstimate is the output of the tokens command
+type TokenEstimate struct {
+	Model  string   `json:"model"`
+	Tokens int      `json:"tokens"`
+	Cost   *float64 `json:"estimated_cost,omitempty"`
+}
+
+// Reports whether the confirmation prompts can be answered interactively.
+// Replaced in tests.
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// tokensCommand prints the estimated number of tokens in a text
+func tokensCommand(config *Config, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("text is required\nUsage: chatgpt-cli tokens \"text\" (or - to read stdin)")
+	}
+
+	text := strings.Join(args, " ")
+	if len(args) == 1 && args[0] == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read text from stdin: %w", err)
+		}
+		text = string(data)
+	}
+
+	estimate := TokenEstimate{Model: config.Model, Tokens: estimateTokens(text)}
+	if cost, ok := estimateCost(config.Model, Usage{PromptTokens: estimate.Tokens}); ok {
+		estimate.Cost = &cost
+	}
+
+	if config.Output == outputJSON {
+		return printJSON(estimate)
+	}
+
+	fmt.Printf("Estimated tokens: %d\n", estimate.Tokens)
+	if estimate.Cost != nil {
+		fmt.Printf("Estimated cost as %s input: $%.6f\n", config.Model, *estimate.Cost)
+	}
+	return nil
+}
+
+// estimateTokens approximates the number of tokens a BPE tokenizer such as
+// OpenAI's splits text into. Words are usually one token, long words one per
+// six letters or so, numbers one per three digits, and each symbol and
+// character of a non-Latin script one on its own. Whitespace is folded into
+// the following word. The estimate is typically within 10-20% for English
+// prose and code.
+func estimateTokens(text string) int {
+	tokens := 0
+	letters, digits := 0, 0
+
+	flush := func() {
+		if letters > 0 {
+			tokens += 1 + (letters-1)/6
+		}
+		if digits > 0 {
+			tokens += (digits + 2) / 3
+		}
+		letters, digits = 0, 0
+	}
+
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+
+		switch {
+		case unicode.IsLetter(r) && (r < utf8.RuneSelf || unicode.Is(unicode.Latin, r)):
+			if digits > 0 {
+				flush()
+			}
+			letters++
+		case unicode.IsDigit(r):
+			if letters > 0 {
+				flush()
+			}
+			digits++
+		case r == ' ':
+			flush()
+		case unicode.IsSpace(r):
+			// Runs of newlines and indentation are merged into single tokens
+			flush()
+			tokens++
+			for len(text) > 0 {
+				next, nextSize := utf8.DecodeRuneInString(text)
+				if !unicode.IsSpace(next) {
+					break
+				}
+				text = text[nextSize:]
+			}
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// estimatePromptTokens approximates the prompt tokens of a chat request,
+// including the formatting around each message
+func estimatePromptTokens(messages []Message) int {
+	tokens := tokensPerReply
+	for _, m := range messages {
+		tokens += tokensPerMessage + estimateTokens(m.Content)
+	}
+	return tokens
+}
+
+// confirmCost shows the estimated tokens and worst-case cost of sending
+// prompt, with the conversation it goes after, and asks whether to go on. It fails rather than waiting for an
+// answer that can't come when stdin is not a terminal.
+func confirmCost(config *Config, prompt string) (bool, error) {
+	if !stdinIsTerminal() {
+		return false, usageErrorf("--confirm-cost needs a terminal to ask for confirmation")
+	}
+
+	usage := Usage{
+		PromptTokens:     estimatePromptTokens(promptMessages(config, prompt)),
+		CompletionTokens: config.MaxTokens,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	fmt.Fprintf(os.Stderr, "Estimated prompt tokens: %d\n", usage.PromptTokens)
+	fmt.Fprintf(os.Stderr, "Max completion tokens:   %d\n", usage.CompletionTokens)
+	if cost, ok := estimateCost(config.Model, usage); ok {
+		fmt.Fprintf(os.Stderr, "Estimated cost:          up to $%.6f (%s)\n", cost, config.Model)
+	} else {
+		fmt.Fprintf(os.Stderr, "Estimated cost:          unknown, no price for %s\n", config.Model)
+	}
+
+	return confirmOn(os.Stderr, "Send this prompt?"), nil
+}
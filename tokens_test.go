@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestEstimateTokens tests the token estimate of common kinds of text
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{"empty", "", 0},
+		{"single word", "hello", 1},
+		{"sentence", "The quick brown fox jumps over the lazy dog.", 10},
+		{"long word", "internationalization", 4},
+		{"number", "1234567", 3},
+		{"code", "x := f(a, b)\n\treturn x", 12},
+		{"accented word", "café", 1},
+		{"cjk", "你好世界", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimateTokens(tt.text); got != tt.expected {
+				t.Errorf("estimateTokens(%q) = %d, want %d", tt.text, got, tt.expected)
+			}
+		})
+	}
+
+	// The chat format adds a few tokens per message
+	messages := []Message{{Role: "user", Content: "hello"}}
+	if got := estimatePromptTokens(messages); got != 1+tokensPerMessage+tokensPerReply {
+		t.Errorf("estimatePromptTokens() = %d, want the content plus the message overhead", got)
+	}
+}
+
+// TestTokensCommand tests plain and JSON output of the tokens command
+func TestTokensCommand(t *testing.T) {
+	config := &Config{Model: "gpt-4o"}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := tokensCommand(config, []string{"hello", "world"}); err != nil {
+			t.Errorf("tokensCommand() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Estimated tokens: 2\n") || !strings.Contains(out, "Estimated cost as gpt-4o input: $0.000005") {
+		t.Errorf("tokens output = %q", out)
+	}
+
+	originalStdin := stdin
+	defer func() { stdin = originalStdin }()
+	stdin = strings.NewReader("one two three")
+
+	config.Output = outputJSON
+	config.Model = "local-model"
+	out = captureOutput(t, &os.Stdout, func() {
+		if err := tokensCommand(config, []string{"-"}); err != nil {
+			t.Errorf("tokensCommand(-) error = %v", err)
+		}
+	})
+	var estimate TokenEstimate
+	if err := json.Unmarshal([]byte(out), &estimate); err != nil {
+		t.Fatalf("tokens output is not valid JSON: %v\n%s", err, out)
+	}
+	if estimate.Tokens != 3 || estimate.Model != "local-model" || estimate.Cost != nil {
+		t.Errorf("estimate = %+v, want 3 tokens and no cost for an unpriced model", estimate)
+	}
+
+	if err := tokensCommand(config, nil); !errors.Is(err, ErrUsage) {
+		t.Errorf("tokensCommand() without text error = %v, want a usage error", err)
+	}
+}
+
+// TestPromptCommandConfirmCost tests that --confirm-cost asks before sending
+// and refuses to run without a terminal
+func TestPromptCommandConfirmCost(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = fmt.Fprint(w, `{"model":"gpt-4o","choices":[{"message":{"role":"assistant","content":"Sent"}}]}`)
+	}))
+	defer server.Close()
+
+	setTestEnv(envConfigDir, t.TempDir())
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	setTestEnv(envModel, "gpt-4o")
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	originalStdin, originalIsTerminal := stdin, stdinIsTerminal
+	defer func() { stdin, stdinIsTerminal = originalStdin, originalIsTerminal }()
+
+	// Without a terminal nothing is sent
+	stdinIsTerminal = func() bool { return false }
+	if err := promptCommand(config, []string{"--confirm-cost", "--no-stream", "hello"}); !errors.Is(err, ErrUsage) || requests != 0 {
+		t.Errorf("promptCommand() without a terminal error = %v after %d requests, want a usage error and none", err, requests)
+	}
+
+	stdinIsTerminal = func() bool { return true }
+	tests := []struct {
+		answer       string
+		wantRequests int
+		wantOutput   string
+	}{
+		{"n\n", 0, "Prompt not sent"},
+		{"y\n", 1, "Sent"},
+	}
+	for _, tt := range tests {
+		stdin = strings.NewReader(tt.answer)
+		var stdout string
+		stderr := captureOutput(t, &os.Stderr, func() {
+			stdout = captureOutput(t, &os.Stdout, func() {
+				if err := promptCommand(config, []string{"--confirm-cost", "--no-stream", "hello"}); err != nil {
+					t.Errorf("promptCommand() error = %v", err)
+				}
+			})
+		})
+		if requests != tt.wantRequests {
+			t.Errorf("answer %q: %d requests sent, want %d", tt.answer, requests, tt.wantRequests)
+		}
+		for _, want := range []string{"Estimated prompt tokens: 8", "Max completion tokens:   1000", "up to $0.010020 (gpt-4o)", "Send this prompt? [y/N]"} {
+			if !strings.Contains(stderr, want) {
+				t.Errorf("answer %q: stderr = %q, want %q", tt.answer, stderr, want)
+			}
+		}
+		if !strings.Contains(stdout+stderr, tt.wantOutput) {
+			t.Errorf("answer %q: output = %q, want %q", tt.answer, stdout+stderr, tt.wantOutput)
+		}
+	}
+}
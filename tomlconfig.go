@@ -0,0 +1,889 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config file names in the config directory
+const (
+	configFileName       = "config.toml"
+	legacyConfigFileName = "config"
+)
+
+// Lock file in the config directory held while config.toml is rewritten
+const configLockFileName = "config.toml.lock"
+
+// How long a write of the config file waits for another one to finish;
+// shortened by tests
+var configLockTimeout = 10 * time.Second
+
+// Serializes the config writes of one process, so that only other processes
+// contend for the lock file
+var configWriteMu sync.Mutex
+
+// Values written to config.toml without quotes
+var tomlBarePattern = regexp.MustCompile(`^(true|false|[+-]?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?)$`)
+
+// Dates and times are kept as written
+var tomlDatePattern = regexp.MustCompile(`^([0-9]{4}-[0-9]{2}-[0-9]{2}|[0-9]{2}:[0-9]{2})`)
+
+// configDocument is a parsed config.toml. It keeps every line as written so
+// that comments, unknown keys and other tables survive a save.
+type configDocument struct {
+	lines []tomlLine
+
+	// Set when the document was read from the legacy config file, which is
+	// backed up once config.toml is written
+	legacy bool
+}
+
+// tomlLine is one line of the document, or several for a multi-line array
+type tomlLine struct {
+	text    string // Raw text without the trailing newline
+	table   string // Table the line belongs to, or opens for headers
+	header  bool
+	key     string // Dotted key of a key/value line
+	value   string // Value in the form used by config set
+	comment string // Trailing comment of a header or key/value line
+}
+
+// configFilePath returns the path of config.toml in configDir
+func configFilePath(configDir string) string {
+	return filepath.Join(configDir, configFileName)
+}
+
+// tomlKey returns the config.toml key for a config set key, e.g. model for
+// OPENAI_MODEL or log_max_size for CHATGPT_CLI_LOG_MAX_SIZE
+func tomlKey(key string) string {
+	for _, prefix := range []string{"OPENAI_", "CHATGPT_CLI_"} {
+		key = strings.TrimPrefix(key, prefix)
+	}
+	return strings.ToLower(key)
+}
+
+// configKeyForTOML returns the config set key for a config.toml key
+func configKeyForTOML(name string) (string, bool) {
+	for _, key := range configFileKeys {
+		if tomlKey(key) == name {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// canonicalTOMLKey returns the config.toml spelling of a key of the default
+// section, a profile or a model table written as its variable, such as
+// OPENAI_MODEL or openai_model for model, so that it is not taken for an
+// unknown key. Other keys are returned as written.
+func canonicalTOMLKey(table, name string) string {
+	if _, known := configKeyForTOML(name); known {
+		return name
+	}
+	if _, ok := tableProfile(table); !ok {
+		if _, ok := tableModel(table); !ok {
+			return name
+		}
+	}
+	if key, known := configKeyForTOML(tomlKey(strings.ToUpper(name))); known {
+		return tomlKey(key)
+	}
+	return name
+}
+
+// profileTable returns the table holding a profile's values
+func profileTable(profile string) string {
+	if profile == "" {
+		return ""
+	}
+	return "profile." + profile
+}
+
+// tableProfile returns the profile stored in a table, if it holds one
+func tableProfile(table string) (string, bool) {
+	if table == "" {
+		return "", true
+	}
+	name := strings.TrimPrefix(table, "profile.")
+	if name == table || name == "" || strings.Contains(name, ".") {
+		return "", false
+	}
+	return name, true
+}
+
+// loadConfigDocument reads config.toml, falling back to the legacy config
+// file when config.toml doesn't exist yet, with the values of the
+// credentials file merged in
+func loadConfigDocument(configDir string) (*configDocument, error) {
+	doc, err := loadConfigFileDocument(configDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadSecrets(configDir, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// loadConfigFileDocument reads config.toml, or the legacy config file
+func loadConfigFileDocument(configDir string) (*configDocument, error) {
+	path := configFilePath(configDir)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return parseConfigDocument(path, string(data))
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(configDir, legacyConfigFileName))
+	if os.IsNotExist(err) {
+		return newConfigDocument(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	doc := newConfigDocument()
+	sections := parseLegacyConfig(string(data))
+	for _, profile := range append([]string{""}, sortedProfileNames(sections)...) {
+		doc.ensureTable(profileTable(profile))
+		for _, key := range configFileKeys {
+			if value := sections[profile][key]; value != "" {
+				doc.set(profile, key, value)
+			}
+		}
+	}
+
+	// Model values, written MODEL.<model>.<KEY>=value, get a table each
+	var modelKeys []string
+	for name := range sections[""] {
+		if _, key, ok := parseModelScopedKey(name); ok && isModelScopedKey(key) {
+			modelKeys = append(modelKeys, name)
+		}
+	}
+	sort.Strings(modelKeys)
+	for _, name := range modelKeys {
+		model, key, _ := parseModelScopedKey(name)
+		doc.setIn(modelTable(model), key, sections[""][name])
+	}
+	doc.legacy = true
+	return doc, nil
+}
+
+// updateConfigDocument reads config.toml, applies update and writes it back,
+// holding the config lock throughout so that invocations writing at the same
+// time don't lose each other's values
+func updateConfigDocument(configDir string, update func(doc *configDocument)) error {
+	configWriteMu.Lock()
+	defer configWriteMu.Unlock()
+
+	unlock, err := lockFile(filepath.Join(configDir, configLockFileName), configLockTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to write the configuration: %w", err)
+	}
+	defer unlock()
+
+	doc, err := loadConfigDocument(configDir)
+	if err != nil {
+		return err
+	}
+	update(doc)
+	return saveConfigDocument(configDir, doc)
+}
+
+// saveConfigDocument writes doc to config.toml, replacing it at once, with
+// the API keys split into the credentials file. A config.toml still holding
+// keys from before the split loses them on its first save. A document
+// migrated from the legacy config file renames that file to config.bak once
+// saved.
+func saveConfigDocument(configDir string, doc *configDocument) error {
+	public, secrets := splitSecrets(doc)
+	// The keys are written first, so that a failure loses none of them
+	if err := saveSecrets(configDir, secrets); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(configFilePath(configDir), []byte(public.String()), configFileMode); err != nil {
+		return err
+	}
+
+	if doc.legacy {
+		legacyFile := filepath.Join(configDir, legacyConfigFileName)
+		if err := os.Rename(legacyFile, legacyFile+".bak"); err != nil {
+			return fmt.Errorf("failed to back up legacy config file: %w", err)
+		}
+		doc.legacy = false
+	}
+	return nil
+}
+
+// parseLegacyConfig parses the KEY=VALUE config file used before config.toml.
+// Values before the first [profile.<name>] header belong to the default
+// section, which is keyed by "" and always present.
+func parseLegacyConfig(data string) map[string]map[string]string {
+	sections := map[string]map[string]string{"": {}}
+
+	section := sections[""]
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if name, ok := parseProfileHeader(line); ok {
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			section = sections[name]
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			section[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	return sections
+}
+
+// newConfigDocument returns the document written for a new config.toml
+func newConfigDocument() *configDocument {
+	return &configDocument{lines: []tomlLine{
+		{text: "# ChatGPT CLI Configuration"},
+		{text: ""},
+	}}
+}
+
+// sections returns the known values of the default section and of every
+// profile, keyed by profile name. A profile exists as soon as its table does.
+func (d *configDocument) sections() map[string]map[string]string {
+	sections := map[string]map[string]string{"": {}}
+
+	for _, line := range d.lines {
+		profile, ok := tableProfile(line.table)
+		if !ok {
+			continue
+		}
+		if sections[profile] == nil {
+			sections[profile] = make(map[string]string)
+		}
+		if key, ok := configKeyForTOML(line.key); ok && !line.header {
+			sections[profile][key] = line.value
+		}
+	}
+
+	return sections
+}
+
+// modelSections returns the values set for single models, keyed by model
+// name. Keys that can't be set per model are ignored like unknown keys.
+func (d *configDocument) modelSections() map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+
+	for _, line := range d.lines {
+		model, ok := tableModel(line.table)
+		if !ok {
+			continue
+		}
+		if sections[model] == nil {
+			sections[model] = make(map[string]string)
+		}
+		if key, ok := configKeyForTOML(line.key); ok && !line.header && isModelScopedKey(key) {
+			sections[model][key] = line.value
+		}
+	}
+
+	return sections
+}
+
+// set stores a value in a profile's table
+func (d *configDocument) set(profile, key, value string) {
+	d.setIn(profileTable(profile), key, value)
+}
+
+// setIn stores a value in a table, replacing the existing line in place or
+// adding one after the last key of the table
+func (d *configDocument) setIn(table, key, value string) {
+	line := tomlLine{table: table, key: tomlKey(key), value: value}
+	line.text = line.key + " = " + encodeTOMLValue(key, value)
+	if !isBareKey(line.key) {
+		line.text = quoteTOMLString(line.key) + " = " + encodeTOMLValue(key, value)
+	}
+
+	for i, existing := range d.lines {
+		if !existing.header && existing.table == table && existing.key == line.key {
+			if existing.comment != "" {
+				line.comment = existing.comment
+				line.text += " " + existing.comment
+			}
+			d.lines[i] = line
+			return
+		}
+	}
+
+	start := d.ensureTable(table)
+	if table != "" {
+		start++
+	}
+	pos := -1
+	for i := start; i < len(d.lines) && !d.lines[i].header; i++ {
+		if d.lines[i].key != "" {
+			pos = i + 1
+		}
+	}
+
+	switch {
+	case pos >= 0:
+	case table != "":
+		pos = start
+	default:
+		// Keep the comments at the top of the file above the first value
+		pos = 0
+		for pos < len(d.lines) && d.lines[pos].key == "" && !d.lines[pos].header {
+			pos++
+		}
+		if pos < len(d.lines) {
+			d.insert(pos, tomlLine{})
+		}
+	}
+	d.insert(pos, line)
+}
+
+// unset removes a value from a profile's table
+func (d *configDocument) unset(profile, key string) {
+	d.unsetIn(profileTable(profile), key)
+}
+
+// unsetIn removes a value from a table
+func (d *configDocument) unsetIn(table, key string) {
+	name := tomlKey(key)
+	for i, line := range d.lines {
+		if !line.header && line.table == table && line.key == name {
+			d.lines = append(d.lines[:i], d.lines[i+1:]...)
+			return
+		}
+	}
+}
+
+// removeProfile removes a profile's table with everything in it
+func (d *configDocument) removeProfile(profile string) {
+	table := profileTable(profile)
+	for i, line := range d.lines {
+		if line.header && line.table == table {
+			end := i + 1
+			for end < len(d.lines) && !d.lines[end].header {
+				end++
+			}
+			d.lines = append(d.lines[:i], d.lines[end:]...)
+			return
+		}
+	}
+}
+
+// clearDefault removes the known values of the default section, keeping
+// comments and unknown keys
+func (d *configDocument) clearDefault() {
+	var kept []tomlLine
+	for _, line := range d.lines {
+		if _, known := configKeyForTOML(line.key); known && line.table == "" && !line.header {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	d.lines = kept
+}
+
+// ensureTable returns the index of a table's header, appending the table if
+// needed. The root table starts at index 0.
+func (d *configDocument) ensureTable(table string) int {
+	if table == "" {
+		return 0
+	}
+	for i, line := range d.lines {
+		if line.header && line.table == table {
+			return i
+		}
+	}
+
+	if n := len(d.lines); n > 0 && strings.TrimSpace(d.lines[n-1].text) != "" {
+		d.lines = append(d.lines, tomlLine{table: d.lines[n-1].table})
+	}
+	d.lines = append(d.lines, tomlLine{text: tableHeader(table), table: table, header: true})
+	return len(d.lines) - 1
+}
+
+// insert adds a line at pos
+func (d *configDocument) insert(pos int, line tomlLine) {
+	d.lines = append(d.lines, tomlLine{})
+	copy(d.lines[pos+1:], d.lines[pos:])
+	d.lines[pos] = line
+}
+
+// String returns the document as written to config.toml
+func (d *configDocument) String() string {
+	texts := make([]string, len(d.lines))
+	for i, line := range d.lines {
+		texts[i] = line.text
+	}
+	return strings.TrimRight(strings.Join(texts, "\n"), "\n") + "\n"
+}
+
+// encodeTOMLValue formats a config set value for config.toml. Numbers and
+// booleans are written bare and stop sequences as an array of strings.
+func encodeTOMLValue(key, value string) string {
+	if key == envStop {
+		if sequences, err := parseStopSequences(value); err == nil {
+			quoted := make([]string, len(sequences))
+			for i, s := range sequences {
+				quoted[i] = quoteTOMLString(s)
+			}
+			return "[" + strings.Join(quoted, ", ") + "]"
+		}
+	}
+	if tomlBarePattern.MatchString(value) {
+		return value
+	}
+	return quoteTOMLString(value)
+}
+
+// quoteTOMLString returns s as a TOML basic string
+func quoteTOMLString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range s {
+		switch c {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlParser parses the subset of TOML used for configuration: tables,
+// dotted keys, single-line strings, numbers, booleans, dates, arrays and
+// inline tables. Errors name the file and line.
+type tomlParser struct {
+	name string
+	src  string
+	pos  int
+	line int
+}
+
+// parseConfigDocument parses the contents of config.toml
+func parseConfigDocument(name, src string) (*configDocument, error) {
+	p := &tomlParser{name: name, src: src, line: 1}
+	doc := &configDocument{}
+	table := ""
+	tables := map[string]bool{"": true}
+	keys := make(map[string]bool)
+
+	for !p.eof() {
+		start := p.pos
+		line := tomlLine{table: table}
+		var err error
+
+		p.skipSpaces()
+		switch p.peek() {
+		case '#', '\r', '\n', 0:
+			_, err = p.endOfLine()
+		case '[':
+			if strings.HasPrefix(p.src[p.pos:], "[[") {
+				return nil, p.errorf("arrays of tables are not supported")
+			}
+			p.pos++
+			var parts []string
+			if parts, err = p.parseKey(); err != nil {
+				return nil, err
+			}
+			if p.peek() != ']' {
+				return nil, p.errorf("expected ']' after table name")
+			}
+			p.pos++
+
+			table = strings.Join(parts, ".")
+			if tables[table] {
+				return nil, p.errorf("table [%s] is defined more than once", table)
+			}
+			tables[table] = true
+			line.table, line.header = table, true
+			line.comment, err = p.endOfLine()
+		default:
+			var parts []string
+			if parts, err = p.parseKey(); err != nil {
+				return nil, err
+			}
+			line.key = canonicalTOMLKey(table, strings.Join(parts, "."))
+			if p.peek() != '=' {
+				return nil, p.errorf("expected '=' after key %q", line.key)
+			}
+			p.pos++
+			p.skipSpaces()
+
+			if keys[table+"\x00"+line.key] {
+				return nil, p.errorf("key %q is defined more than once", line.key)
+			}
+			keys[table+"\x00"+line.key] = true
+
+			if line.value, err = p.parseValue(); err != nil {
+				return nil, err
+			}
+			line.comment, err = p.endOfLine()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line.text = strings.TrimRight(p.src[start:p.pos], "\r\n")
+		doc.lines = append(doc.lines, line)
+	}
+
+	return doc, nil
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// skipComment skips a comment up to the end of the line and returns it
+func (p *tomlParser) skipComment() string {
+	if p.peek() != '#' {
+		return ""
+	}
+	start := p.pos
+	for !p.eof() && p.peek() != '\n' && p.peek() != '\r' {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// endOfLine consumes an optional comment and the newline ending a line
+func (p *tomlParser) endOfLine() (string, error) {
+	p.skipSpaces()
+	comment := p.skipComment()
+	if p.eof() {
+		return comment, nil
+	}
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos++
+	}
+	if p.peek() != '\n' {
+		return "", p.errorf("unexpected %q at end of line", p.peek())
+	}
+	p.pos++
+	p.line++
+	return comment, nil
+}
+
+// skipBlank skips whitespace, newlines and comments inside an array
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpaces()
+		p.skipComment()
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "\r\n"):
+			p.pos += 2
+		case p.peek() == '\n':
+			p.pos++
+		default:
+			return
+		}
+		p.line++
+	}
+}
+
+// parseKey parses a bare, quoted or dotted key and the spaces after it
+func (p *tomlParser) parseKey() ([]string, error) {
+	var parts []string
+	for {
+		p.skipSpaces()
+
+		var part string
+		var err error
+		switch p.peek() {
+		case '"':
+			part, err = p.parseBasicString()
+		case '\'':
+			part, err = p.parseLiteralString()
+		default:
+			start := p.pos
+			for isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if part = p.src[start:p.pos]; part == "" {
+				return nil, p.errorf("expected a key, found %q", p.peek())
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return parts, nil
+		}
+		p.pos++
+	}
+}
+
+// parseValue parses a value and returns it in the form used by config set.
+// Arrays become a comma-separated list like OPENAI_STOP takes.
+func (p *tomlParser) parseValue() (string, error) {
+	rest := p.src[p.pos:]
+	switch {
+	case strings.HasPrefix(rest, `"""`), strings.HasPrefix(rest, "'''"):
+		return "", p.errorf("multi-line strings are not supported")
+	case p.peek() == '"':
+		return p.parseBasicString()
+	case p.peek() == '\'':
+		return p.parseLiteralString()
+	case p.peek() == '[':
+		items, err := p.parseArray()
+		if err != nil || len(items) == 0 {
+			return "", err
+		}
+		return formatStopSequences(items), nil
+	case p.peek() == '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	p.skipToken()
+	// A date may be separated from its time by a space
+	if p.pos-start == 10 && tomlDatePattern.MatchString(p.src[start:p.pos]) &&
+		p.peek() == ' ' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]) {
+		p.pos++
+		p.skipToken()
+	}
+
+	token := p.src[start:p.pos]
+	switch {
+	case token == "":
+		return "", p.errorf("expected a value, found %q", p.peek())
+	case token == "true" || token == "false":
+		return token, nil
+	case tomlDatePattern.MatchString(token):
+		return token, nil
+	}
+
+	number := strings.ReplaceAll(token, "_", "")
+	digits := strings.TrimLeft(number, "+-")
+	if digits == "inf" || digits == "nan" {
+		return number, nil
+	}
+	if digits != "" && isDigit(digits[0]) {
+		if _, err := strconv.ParseInt(number, 0, 64); err == nil {
+			return number, nil
+		}
+		if _, err := strconv.ParseFloat(number, 64); err == nil {
+			return number, nil
+		}
+	}
+	return "", p.errorf("invalid value %q", token)
+}
+
+// skipToken skips the characters of a number, boolean or date
+func (p *tomlParser) skipToken() {
+	for c := p.peek(); isBareKeyChar(c) || c == '+' || c == '.' || c == ':'; c = p.peek() {
+		p.pos++
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isBareKeyChar(c byte) bool {
+	return isDigit(c) || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_' || c == '-'
+}
+
+// parseBasicString parses a double-quoted string with escapes
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+
+		switch c := p.peek(); c {
+		case '"':
+			p.pos++
+			return b.String(), nil
+		case '\\':
+			p.pos++
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseEscape parses the escape sequence after a backslash
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"', '\\':
+		b.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return p.errorf("invalid unicode escape")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil {
+			return p.errorf("invalid unicode escape \\%c%s", c, p.src[p.pos:p.pos+size])
+		}
+		p.pos += size
+		b.WriteRune(rune(code))
+	default:
+		return p.errorf("invalid escape \\%c in string", c)
+	}
+	return nil
+}
+
+// parseLiteralString parses a single-quoted string, which has no escapes
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	start := p.pos
+	for p.peek() != '\'' {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		p.pos++
+	}
+	p.pos++
+	return p.src[start : p.pos-1], nil
+}
+
+// parseArray parses an array, which may span several lines
+func (p *tomlParser) parseArray() ([]string, error) {
+	startLine := p.line
+	p.pos++
+
+	var items []string
+	for {
+		p.skipBlank()
+		switch {
+		case p.peek() == ']':
+			p.pos++
+			return items, nil
+		case p.eof():
+			p.line = startLine
+			return nil, p.errorf("unterminated array")
+		}
+
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		p.skipBlank()
+		switch {
+		case p.peek() == ',':
+			p.pos++
+		case p.peek() == ']':
+			p.pos++
+			return items, nil
+		case p.eof():
+			p.line = startLine
+			return nil, p.errorf("unterminated array")
+		default:
+			return nil, p.errorf("expected ',' or ']' in array, found %q", p.peek())
+		}
+	}
+}
+
+// parseInlineTable parses an inline table and returns it as written
+func (p *tomlParser) parseInlineTable() (string, error) {
+	start := p.pos
+	p.pos++
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.pos++
+		return p.src[start:p.pos], nil
+	}
+
+	for {
+		if _, err := p.parseKey(); err != nil {
+			return "", err
+		}
+		if p.peek() != '=' {
+			return "", p.errorf("expected '=' in inline table")
+		}
+		p.pos++
+		p.skipSpaces()
+		if _, err := p.parseValue(); err != nil {
+			return "", err
+		}
+
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return p.src[start:p.pos], nil
+		default:
+			return "", p.errorf("expected ',' or '}' in inline table, found %q", p.peek())
+		}
+	}
+}
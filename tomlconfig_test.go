@@ -0,0 +1,345 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTOMLKeys tests that every config key maps to its own TOML key
+func TestTOMLKeys(t *testing.T) {
+	if got := tomlKey("CHATGPT_CLI_LOG_MAX_SIZE"); got != "log_max_size" {
+		t.Errorf("tomlKey(CHATGPT_CLI_LOG_MAX_SIZE) = %q, want %q", got, "log_max_size")
+	}
+	for _, key := range configFileKeys {
+		if got, ok := configKeyForTOML(tomlKey(key)); !ok || got != key {
+			t.Errorf("configKeyForTOML(%q) = %q, want %q", tomlKey(key), got, key)
+		}
+	}
+}
+
+// TestParseConfigDocument tests reading the supported TOML values
+func TestParseConfigDocument(t *testing.T) {
+	src := `# Comments are allowed anywhere
+api_key = "sk-\"quoted\"\tkey" # trailing comment
+api_url = 'C:\literal'
+max_tokens = 1_000
+temperature = 0.5
+stream = false
+stop = [
+  "END",  # first
+  "a,b",
+]
+created = 1979-05-27 07:32:00Z
+extra = { name = "kept", list = [1, 2] }
+
+[profile.work]
+"model" = "gpt-4"
+
+[plugins.other]
+model = "not a profile"
+`
+	doc, err := parseConfigDocument("config.toml", src)
+	if err != nil {
+		t.Fatalf("parseConfigDocument() error = %v", err)
+	}
+
+	sections := doc.sections()
+	expected := map[string]string{
+		"OPENAI_API_KEY":     "sk-\"quoted\"\tkey",
+		"OPENAI_API_URL":     `C:\literal`,
+		"OPENAI_MAX_TOKENS":  "1000",
+		"OPENAI_TEMPERATURE": "0.5",
+		"OPENAI_STREAM":      "false",
+		"OPENAI_STOP":        `END,a\,b`,
+	}
+	for key, want := range expected {
+		if got := sections[""][key]; got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if len(sections[""]) != len(expected) {
+		t.Errorf("default section = %v, want only the known keys", sections[""])
+	}
+	if sections["work"]["OPENAI_MODEL"] != "gpt-4" {
+		t.Errorf("work OPENAI_MODEL = %q, want %q", sections["work"]["OPENAI_MODEL"], "gpt-4")
+	}
+	if len(sections) != 2 {
+		t.Errorf("sections = %v, want the default and work profiles", sections)
+	}
+
+	if doc.String() != src {
+		t.Errorf("String() changed the document:\n%s", doc.String())
+	}
+}
+
+// TestParseConfigDocumentVariableNames tests that keys written as their
+// variable, in either case, are read as the config.toml key, in the default
+// section, profiles and model tables only
+func TestParseConfigDocumentVariableNames(t *testing.T) {
+	src := `OPENAI_MODEL = "gpt-4o"
+chatgpt_cli_log_max_size = 2048
+
+[profile.work]
+Openai_Temperature = 0.2
+
+[models.gpt-4o]
+OPENAI_MAX_TOKENS = 500
+
+[defaults]
+OPENAI_MODEL = "--usage"
+`
+	doc, err := parseConfigDocument("config.toml", src)
+	if err != nil {
+		t.Fatalf("parseConfigDocument() error = %v", err)
+	}
+	sections := doc.sections()
+	if sections[""]["OPENAI_MODEL"] != "gpt-4o" || sections[""]["CHATGPT_CLI_LOG_MAX_SIZE"] != "2048" || sections["work"]["OPENAI_TEMPERATURE"] != "0.2" {
+		t.Errorf("sections = %v, want the values of the variable names", sections)
+	}
+	if models := doc.modelSections(); models["gpt-4o"]["OPENAI_MAX_TOKENS"] != "500" {
+		t.Errorf("model sections = %v, want max_tokens for gpt-4o", models)
+	}
+	if defaults := doc.defaultFlags(); defaults["OPENAI_MODEL"] != "--usage" {
+		t.Errorf("default flags = %v, want the key kept as written", defaults)
+	}
+
+	// A value set again is written with the config.toml key
+	doc.set("", envModel, "gpt-4.1")
+	if got := doc.String(); !strings.HasPrefix(got, "model = \"gpt-4.1\"\n") {
+		t.Errorf("String() after set = %q", got)
+	}
+}
+
+// TestParseConfigDocumentErrors tests that malformed files report the line
+func TestParseConfigDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"missing equals", "model = \"gpt-4\"\napi_key \"sk\"\n", `config.toml:2: expected '=' after key "api_key"`},
+		{"unterminated string", "# comment\n\nmodel = \"gpt-4\n", "config.toml:3: unterminated string"},
+		{"invalid value", "model = gpt-4\n", `config.toml:1: invalid value "gpt-4"`},
+		{"trailing text", "max_tokens = 10 20\n", "config.toml:1: unexpected '2' at end of line"},
+		{"duplicate key", "[profile.work]\nmodel = \"a\"\nmodel = \"b\"\n", `config.toml:3: key "model" is defined more than once`},
+		{"duplicate table", "[profile.work]\n[profile.work]\n", "config.toml:2: table [profile.work] is defined more than once"},
+		{"unclosed table", "[profile.work\n", "config.toml:1: expected ']' after table name"},
+		{"unterminated array", "stop = [\n  \"a\",\n", "config.toml:1: unterminated array"},
+		{"bad escape", "model = \"a\\qb\"\n", `config.toml:1: invalid escape \q in string`},
+		{"multi-line string", "model = \"\"\"\ngpt-4\"\"\"\n", "config.toml:1: multi-line strings are not supported"},
+		{"legacy syntax", "OPENAI_MODEL=gpt-4\n", `config.toml:1: invalid value "gpt-4"`},
+		{"duplicate key spelled as variable", "model = \"a\"\nOPENAI_MODEL = \"b\"\n", `config.toml:2: key "model" is defined more than once`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfigDocument("config.toml", tt.src)
+			if err == nil {
+				t.Fatalf("parseConfigDocument() expected error, got nil")
+			}
+			if err.Error() != tt.expected {
+				t.Errorf("error = %q, want %q", err.Error(), tt.expected)
+			}
+		})
+	}
+}
+
+// TestSaveConfigPreservesComments tests that saving only touches the changed lines
+func TestSaveConfigPreservesComments(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, `# My settings
+
+# The model I use most
+model = "gpt-4" # keep this comment
+editor = "vim"
+
+[profile.work]
+api_key = "work-key"
+
+[plugins]
+enabled = true
+`)
+
+	if err := saveConfigFile(tmpDir, map[string]string{
+		"OPENAI_MODEL":      "gpt-4o",
+		"OPENAI_MAX_TOKENS": "2000",
+		"OPENAI_STOP":       `END,\n\n`,
+	}); err != nil {
+		t.Fatalf("saveConfigFile() error = %v", err)
+	}
+	if err := saveProfileConfig(tmpDir, "work", map[string]string{
+		"OPENAI_API_KEY":     "",
+		"OPENAI_API_URL":     "https://example.com/v1",
+		"CHATGPT_CLI_OUTPUT": "json",
+	}); err != nil {
+		t.Fatalf("saveProfileConfig() error = %v", err)
+	}
+	if err := saveProfileConfig(tmpDir, "new", map[string]string{"OPENAI_TIMEOUT": "30s"}); err != nil {
+		t.Fatalf("saveProfileConfig() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, configFileName))
+	if err != nil {
+		t.Fatalf("failed to read config file: %v", err)
+	}
+
+	expected := `# My settings
+
+# The model I use most
+model = "gpt-4o" # keep this comment
+editor = "vim"
+max_tokens = 2000
+stop = ["END", "\n\n"]
+
+[profile.work]
+api_url = "https://example.com/v1"
+output = "json"
+
+[plugins]
+enabled = true
+
+[profile.new]
+timeout = "30s"
+`
+	if string(data) != expected {
+		t.Errorf("config file =\n%s\nwant\n%s", data, expected)
+	}
+
+	sections := loadTestConfigSections(t, tmpDir)
+	if sections[""]["OPENAI_STOP"] != `END,\n\n` {
+		t.Errorf("OPENAI_STOP = %q, want %q", sections[""]["OPENAI_STOP"], `END,\n\n`)
+	}
+}
+
+// TestConfigResetKeepsUnknownKeys tests that reset only removes known values
+func TestConfigResetKeepsUnknownKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, "# Settings\nmodel = \"gpt-4\"\neditor = \"vim\"\n")
+
+	captureOutput(t, &os.Stdout, func() {
+		if err := configResetCommand(&Config{ConfigDir: tmpDir}, []string{"--force"}); err != nil {
+			t.Errorf("configResetCommand() error = %v", err)
+		}
+	})
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, configFileName))
+	if err != nil {
+		t.Fatalf("failed to read config file: %v", err)
+	}
+	if string(data) != "# Settings\neditor = \"vim\"\n" {
+		t.Errorf("config file = %q, want the comment and unknown key kept", data)
+	}
+}
+
+// TestLegacyConfigMigration tests reading the old config file and replacing
+// it on the first write
+func TestLegacyConfigMigration(t *testing.T) {
+	tmpDir := t.TempDir()
+	legacyFile := filepath.Join(tmpDir, legacyConfigFileName)
+	legacy := "# ChatGPT CLI Configuration\nOPENAI_MODEL=gpt-4\nOPENAI_STOP=END,\\n\n\n[profile.work]\nOPENAI_API_KEY=work-key\n\n[profile.empty]\n"
+	if err := os.WriteFile(legacyFile, []byte(legacy), 0600); err != nil {
+		t.Fatalf("failed to write legacy config: %v", err)
+	}
+
+	// Reading leaves the legacy file alone
+	sections := loadTestConfigSections(t, tmpDir)
+	if sections[""]["OPENAI_MODEL"] != "gpt-4" || sections["work"]["OPENAI_API_KEY"] != "work-key" {
+		t.Errorf("sections = %v, want the legacy values", sections)
+	}
+	if _, err := os.Stat(configFilePath(tmpDir)); !os.IsNotExist(err) {
+		t.Errorf("config.toml should not be written by a read")
+	}
+
+	if err := saveConfigFile(tmpDir, map[string]string{"OPENAI_MAX_TOKENS": "500"}); err != nil {
+		t.Fatalf("saveConfigFile() error = %v", err)
+	}
+
+	if _, err := os.Stat(legacyFile); !os.IsNotExist(err) {
+		t.Errorf("legacy config file should be moved away")
+	}
+	backup, err := os.ReadFile(legacyFile + ".bak")
+	if err != nil || string(backup) != legacy {
+		t.Errorf("config.bak = %q (error %v), want the legacy file", backup, err)
+	}
+
+	data, err := os.ReadFile(configFilePath(tmpDir))
+	if err != nil {
+		t.Fatalf("failed to read config.toml: %v", err)
+	}
+	expected := `# ChatGPT CLI Configuration
+
+model = "gpt-4"
+stop = ["END", "\n"]
+max_tokens = 500
+
+[profile.empty]
+
+[profile.work]
+`
+	if string(data) != expected {
+		t.Errorf("config.toml =\n%s\nwant\n%s", data, expected)
+	}
+
+	// The API key of the legacy file is moved to the credentials file
+	data, err = os.ReadFile(secretsFilePath(tmpDir))
+	if err != nil || !strings.HasSuffix(string(data), "\n[profile.work]\napi_key = \"work-key\"\n") {
+		t.Errorf("credentials = %q (error %v), want the work key", data, err)
+	}
+}
+
+// TestLoadConfigParseError tests that a broken config file is reported
+func TestLoadConfigParseError(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	tmpDir := t.TempDir()
+	writeTestConfigFile(t, tmpDir, "model = \"gpt-4\"\ntimeout = 30s\n")
+	setTestEnv(envConfigDir, tmpDir)
+
+	_, err := loadConfig("")
+	if err == nil || !strings.Contains(err.Error(), "config.toml:2: invalid value \"30s\"") {
+		t.Errorf("loadConfig() error = %v, want a parse error on line 2", err)
+	}
+
+	// Writing must not replace a file that couldn't be read
+	if err := saveConfigFile(tmpDir, map[string]string{"OPENAI_MODEL": "gpt-4o"}); err == nil {
+		t.Errorf("saveConfigFile() expected error, got nil")
+	}
+}
+
+// TestEncodeTOMLValue tests how config set values are written
+func TestEncodeTOMLValue(t *testing.T) {
+	tests := []struct {
+		key      string
+		value    string
+		expected string
+	}{
+		{"OPENAI_MAX_TOKENS", "2000", "2000"},
+		{"OPENAI_TEMPERATURE", "0.7", "0.7"},
+		{"OPENAI_STREAM", "true", "true"},
+		{"OPENAI_TIMEOUT", "30s", `"30s"`},
+		{"CHATGPT_CLI_LOG_MAX_SIZE", "5MB", `"5MB"`},
+		{"OPENAI_TOP_P", ".5", `".5"`},
+		{"OPENAI_API_KEY", "sk-\"a\\b\"\x01", `"sk-\"a\\b\"\u0001"`},
+		{"OPENAI_STOP", `a\,b, c`, `["a,b", "c"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			result := encodeTOMLValue(tt.key, tt.value)
+			if result != tt.expected {
+				t.Errorf("encodeTOMLValue(%q, %q) = %s, want %s", tt.key, tt.value, result, tt.expected)
+			}
+
+			doc, err := parseConfigDocument("config.toml", tomlKey(tt.key)+" = "+result+"\n")
+			if err != nil {
+				t.Fatalf("parseConfigDocument() error = %v", err)
+			}
+			if got := doc.sections()[""][tt.key]; got != tt.value && tt.key != "OPENAI_STOP" {
+				t.Errorf("round trip = %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
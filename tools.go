@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Rounds of tool calls answered before giving up on a final reply
+const defaultMaxToolRounds = 5
+
+// Largest tool result sent back to the model; longer ones are cut
+const maxToolResultSize = 64 * 1024
+
+// Tool describes a function the model may call
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the name, description and JSON schema of a tool's arguments
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is a request from the model to run a tool
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the tool to run and its JSON encoded arguments
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolRun records a tool executed for a prompt in its log entry. Only the
+// size of the result is kept, since it may hold a whole file.
+type ToolRun struct {
+	Name        string `json:"name"`
+	Arguments   string `json:"arguments"`
+	ResultBytes int    `json:"result_bytes"`
+	Error       string `json:"error,omitempty"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+// localTool is a tool the CLI runs on the user's machine
+type localTool struct {
+	Description string
+	Parameters  string
+	Run         func(config *Config, args json.RawMessage) (string, error)
+}
+
+// Tools that can be enabled with prompt --tools. They only read: the clock,
+// files below the current directory and pages of allowed domains.
+var localTools = map[string]localTool{
+	"get_time": {
+		Description: "Get the current date and time, in the user's time zone or the given IANA time zone",
+		Parameters:  `{"type":"object","properties":{"timezone":{"type":"string","description":"IANA time zone such as Europe/Rome"}}}`,
+		Run:         runGetTime,
+	},
+	"read_file": {
+		Description: "Read a text file below the current directory",
+		Parameters:  `{"type":"object","properties":{"path":{"type":"string","description":"Path relative to the current directory"}},"required":["path"]}`,
+		Run:         runReadFile,
+	},
+	"http_get": {
+		Description: "Fetch a web page or API response with an HTTP GET request. Only some domains are allowed.",
+		Parameters:  `{"type":"object","properties":{"url":{"type":"string","description":"http or https URL to fetch"}},"required":["url"]}`,
+		Run:         runHTTPGet,
+	},
+}
+
+// localToolNames returns the names of the local tools, sorted
+func localToolNames() []string {
+	names := make([]string, 0, len(localTools))
+	for name := range localTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseToolList parses the comma-separated --tools value
+func parseToolList(value string) ([]string, error) {
+	var tools []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := localTools[name]; !ok {
+			return nil, usageErrorf("unknown tool %q: available tools are %s", name, strings.Join(localToolNames(), ", "))
+		}
+		seen[name] = true
+		tools = append(tools, name)
+	}
+	return tools, nil
+}
+
+// toolDefinitions returns the request definitions of the named tools
+func toolDefinitions(names []string) []Tool {
+	tools := make([]Tool, 0, len(names))
+	for _, name := range names {
+		tool := localTools[name]
+		tools = append(tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        name,
+				Description: tool.Description,
+				Parameters:  json.RawMessage(tool.Parameters),
+			},
+		})
+	}
+	return tools
+}
+
+// sendWithTools sends the prompt and runs the tools the model asks for,
+// sending their results back, until it replies or maxRounds rounds of tool
+// calls have been answered. The usage of every request is added up. The tools
+// run so far are returned even on failure, to be logged.
+func sendWithTools(ctx context.Context, client *APIClient, prompt string, maxRounds int) (*ChatResponse, []ToolRun, error) {
+	config := client.config
+	messages := promptMessages(config, prompt)
+	var runs []ToolRun
+	var usage Usage
+
+	for round := 0; ; round++ {
+		response, err := client.Chat(ctx, messages)
+		if err != nil {
+			return nil, runs, err
+		}
+		usage.add(response.Usage)
+
+		if len(response.Choices) == 0 || len(response.Choices[0].Message.ToolCalls) == 0 {
+			response.Usage = usage
+			return response, runs, nil
+		}
+		if round >= maxRounds {
+			return nil, runs, fmt.Errorf("no reply after %d rounds of tool calls; raise --max-tool-rounds to allow more", maxRounds)
+		}
+
+		reply := response.Choices[0].Message
+		messages = append(messages, reply)
+		for _, call := range reply.ToolCalls {
+			result, run := runToolCall(config, call)
+			runs = append(runs, run)
+			messages = append(messages, Message{Role: "tool", ToolCallID: call.ID, Content: result})
+		}
+	}
+}
+
+// runToolCall runs one call, returning what is sent back to the model. A
+// failure is reported to the model as the result, so that it can recover.
+func runToolCall(config *Config, call ToolCall) (string, ToolRun) {
+	run := ToolRun{Name: call.Function.Name, Arguments: call.Function.Arguments}
+	start := time.Now()
+
+	result, err := executeTool(config, call.Function.Name, call.Function.Arguments)
+	run.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		run.Error = err.Error()
+		result = "Error: " + err.Error()
+	}
+	result = truncateToolResult(result)
+	run.ResultBytes = len(result)
+
+	if config.Debug > 0 {
+		status := fmt.Sprintf("%d bytes", run.ResultBytes)
+		if run.Error != "" {
+			status = "error: " + run.Error
+		}
+		fmt.Fprintf(os.Stderr, "* tool %s(%s) -> %s (%dms)\n", run.Name, run.Arguments, status, run.DurationMs)
+	}
+	return result, run
+}
+
+// truncateToolResult cuts a result longer than maxToolResultSize, backing
+// off to the start of a rune so that what is sent stays valid UTF-8
+func truncateToolResult(result string) string {
+	if len(result) <= maxToolResultSize {
+		return result
+	}
+	cut := maxToolResultSize
+	for cut > 0 && !utf8.RuneStart(result[cut]) {
+		cut--
+	}
+	return result[:cut] + "\n[truncated]"
+}
+
+// executeTool runs a tool enabled for this prompt
+func executeTool(config *Config, name, arguments string) (string, error) {
+	enabled := false
+	for _, tool := range config.Tools {
+		enabled = enabled || tool == name
+	}
+	if !enabled {
+		return "", fmt.Errorf("tool %s is not enabled", name)
+	}
+
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	return localTools[name].Run(config, json.RawMessage(arguments))
+}
+
+func runGetTime(config *Config, args json.RawMessage) (string, error) {
+	var params struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	now := time.Now()
+	if params.Timezone != "" {
+		location, err := time.LoadLocation(params.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown time zone %q", params.Timezone)
+		}
+		now = now.In(location)
+	}
+	return now.Format("Monday, 2006-01-02T15:04:05Z07:00 (MST)"), nil
+}
+
+func runReadFile(config *Config, args json.RawMessage) (string, error) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if params.Path == "" || params.Path == "-" {
+		return "", fmt.Errorf("a file path is required")
+	}
+
+	path, err := pathInWorkingDir(params.Path)
+	if err != nil {
+		return "", err
+	}
+	a, err := readAttachment(path, config.MaxFileSize)
+	if err != nil {
+		return "", err
+	}
+	return a.Content, nil
+}
+
+// pathInWorkingDir resolves path, following symlinks, and fails if it is
+// outside the current directory
+func pathInWorkingDir(path string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	wd, err = filepath.EvalSymlinks(wd)
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(wd, path)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+
+	rel, err := filepath.Rel(wd, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the current directory", path)
+	}
+	return resolved, nil
+}
+
+func runHTTPGet(config *Config, args json.RawMessage) (string, error) {
+	var params struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	u, err := url.Parse(params.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid URL %q: only http and https URLs can be fetched", params.URL)
+	}
+	if err := checkToolDomain(config, u); err != nil {
+		return "", err
+	}
+
+	// Redirects are followed only within the allowed domains
+	client := newHTTPClient(config)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return checkToolDomain(config, req.URL)
+	}
+
+	req, err := http.NewRequestWithContext(config.requestContext(), "GET", u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxToolResultSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	return fmt.Sprintf("HTTP %s\n\n%s", resp.Status, body), nil
+}
+
+// checkToolDomain fails unless the URL's host is one of the allowed domains
+// or a subdomain of one
+func checkToolDomain(config *Config, u *url.URL) error {
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range config.ToolDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	if len(config.ToolDomains) == 0 {
+		return fmt.Errorf("http_get is not allowed to fetch any domain; list the allowed ones in %s", envToolDomains)
+	}
+	return fmt.Errorf("%s is not an allowed domain (%s: %s)", host, envToolDomains, formatDomainList(config.ToolDomains))
+}
+
+// parseDomainList parses a comma-separated list of domains, lowercased
+func parseDomainList(value string) []string {
+	var domains []string
+	for _, domain := range strings.Split(value, ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// formatDomainList formats domains as config list shows them
+func formatDomainList(domains []string) string {
+	if len(domains) == 0 {
+		return "(not set)"
+	}
+	return strings.Join(domains, ",")
+}
+
+// validateDomainList checks that every item of a domain list is a host name
+func validateDomainList(value string) error {
+	for _, domain := range parseDomainList(value) {
+		if strings.ContainsAny(domain, ":/ \t*") {
+			return fmt.Errorf("tool domains must be host names such as example.com, separated by commas: %q", domain)
+		}
+	}
+	return nil
+}
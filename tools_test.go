@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// TestParseToolList tests reading --tools
+func TestParseToolList(t *testing.T) {
+	tools, err := parseToolList(" read_file, get_time,,read_file")
+	if err != nil {
+		t.Fatalf("parseToolList() error = %v", err)
+	}
+	if strings.Join(tools, ",") != "read_file,get_time" {
+		t.Errorf("parseToolList() = %v, want read_file and get_time once", tools)
+	}
+
+	if _, err := parseToolList("get_time,shell"); !errors.Is(err, ErrUsage) || !strings.Contains(err.Error(), "http_get, read_file") {
+		t.Errorf("parseToolList() with an unknown tool error = %v, want a usage error listing the tools", err)
+	}
+}
+
+// TestToolDefinitions tests the tools sent in a request
+func TestToolDefinitions(t *testing.T) {
+	data, err := json.Marshal(toolDefinitions([]string{"read_file"}))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	want := `[{"type":"function","function":{"name":"read_file","description":"Read a text file below the current directory","parameters":{"type":"object","properties":{"path":{"type":"string","description":"Path relative to the current directory"}},"required":["path"]}}}]`
+	if string(data) != want {
+		t.Errorf("toolDefinitions() = %s, want %s", data, want)
+	}
+
+	// Every schema must be valid JSON, or the request can't be encoded
+	for _, name := range localToolNames() {
+		if !json.Valid([]byte(localTools[name].Parameters)) {
+			t.Errorf("tool %s has invalid parameters JSON", name)
+		}
+	}
+}
+
+// TestReadFileTool tests that read_file stays in the current directory
+func TestReadFileTool(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "work")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "notes.txt"), []byte("inside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.txt"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	originalWd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(originalWd) }()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{Tools: []string{"read_file"}, MaxFileSize: 1024}
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{name: "relative", path: "sub/notes.txt", want: "inside"},
+		{name: "absolute inside", path: filepath.Join(dir, "sub", "notes.txt"), want: "inside"},
+		{name: "parent", path: "../secret.txt", wantErr: "outside the current directory"},
+		{name: "absolute outside", path: filepath.Join(root, "secret.txt"), wantErr: "outside the current directory"},
+		{name: "symlink outside", path: "link.txt", wantErr: "outside the current directory"},
+		{name: "missing", path: "nope.txt", wantErr: "failed to open file"},
+		{name: "stdin", path: "-", wantErr: "a file path is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, _ := json.Marshal(map[string]string{"path": tt.path})
+			got, err := executeTool(config, "read_file", string(args))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("read_file(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("read_file(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestTruncateToolResult tests that a long result is cut on a rune
+// boundary, so that the tool message stays valid UTF-8
+func TestTruncateToolResult(t *testing.T) {
+	short := strings.Repeat("é", 100)
+	if got := truncateToolResult(short); got != short {
+		t.Errorf("short result changed to %q", got)
+	}
+
+	// One ASCII byte shifts the two-byte runes so that the limit falls
+	// inside one of them
+	long := "x" + strings.Repeat("é", maxToolResultSize)
+	got := truncateToolResult(long)
+	if !utf8.ValidString(got) {
+		t.Fatal("truncated result is not valid UTF-8")
+	}
+	body := strings.TrimSuffix(got, "\n[truncated]")
+	if body == got || len(body) != maxToolResultSize-1 || !strings.HasPrefix(long, body) {
+		t.Errorf("truncated result has %d bytes before the marker, want %d", len(body), maxToolResultSize-1)
+	}
+}
+
+// TestHTTPGetTool tests the domain allowlist of http_get
+func TestHTTPGetTool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, "http://example.com/", http.StatusFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, "page body")
+	}))
+	defer server.Close()
+
+	config := &Config{Tools: []string{"http_get"}, Timeout: 5 * time.Second}
+	get := func(rawURL string) (string, error) {
+		args, _ := json.Marshal(map[string]string{"url": rawURL})
+		return executeTool(config, "http_get", string(args))
+	}
+
+	if _, err := get(server.URL); err == nil || !strings.Contains(err.Error(), envToolDomains) {
+		t.Errorf("http_get without domains error = %v, want a hint about %s", err, envToolDomains)
+	}
+
+	config.ToolDomains = []string{"127.0.0.1"}
+	got, err := get(server.URL + "/page")
+	if err != nil || got != "HTTP 200 OK\n\npage body" {
+		t.Errorf("http_get() = %q, %v, want the status and body", got, err)
+	}
+	if _, err := get(server.URL + "/away"); err == nil || !strings.Contains(err.Error(), "example.com is not an allowed domain") {
+		t.Errorf("http_get() redirected away error = %v, want the redirect refused", err)
+	}
+	if _, err := get("file:///etc/passwd"); err == nil || !strings.Contains(err.Error(), "only http and https") {
+		t.Errorf("http_get() of a file URL error = %v, want it refused", err)
+	}
+}
+
+// TestCheckToolDomain tests matching hosts against the allowed domains
+func TestCheckToolDomain(t *testing.T) {
+	config := &Config{ToolDomains: []string{"github.com"}}
+	tests := []struct {
+		rawURL  string
+		allowed bool
+	}{
+		{"https://github.com/x", true},
+		{"https://API.GitHub.com/repos", true},
+		{"https://github.com:8443/", true},
+		{"https://evilgithub.com/", false},
+		{"https://github.com.evil.org/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.rawURL)
+		if err := checkToolDomain(config, u); (err == nil) != tt.allowed {
+			t.Errorf("checkToolDomain(%q) error = %v, want allowed %v", tt.rawURL, err, tt.allowed)
+		}
+	}
+}
+
+// TestExecuteToolNotEnabled tests that only the tools of --tools run
+func TestExecuteToolNotEnabled(t *testing.T) {
+	config := &Config{Tools: []string{"get_time"}}
+	if _, err := executeTool(config, "read_file", `{"path":"main.go"}`); err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Errorf("executeTool() of a disabled tool error = %v, want it refused", err)
+	}
+	if _, err := executeTool(config, "get_time", `{"timezone":"Mars/Olympus"}`); err == nil || !strings.Contains(err.Error(), "unknown time zone") {
+		t.Errorf("get_time with a bad time zone error = %v", err)
+	}
+	if got, err := executeTool(config, "get_time", ""); err != nil || got == "" {
+		t.Errorf("get_time without arguments = %q, %v", got, err)
+	}
+}
+
+// newToolTestServer asks for a get_time call until it receives a tool
+// result, then replies. With loop set, it never stops asking.
+func newToolTestServer(t *testing.T, requests *[]ChatRequest, loop bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		*requests = append(*requests, req)
+
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role == "tool" && !loop {
+			_, _ = fmt.Fprint(w, `{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"It is late."}}],"usage":{"prompt_tokens":20,"completion_tokens":5,"total_tokens":25}}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}],"usage":{"prompt_tokens":10,"completion_tokens":3,"total_tokens":13}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestPromptCommandTools tests the tool call loop of prompt --tools
+func TestPromptCommandTools(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var requests []ChatRequest
+	server := newToolTestServer(t, &requests, false)
+	configDir := t.TempDir()
+	setTestEnv(envConfigDir, configDir)
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	config.Debug = 1
+
+	var stdout string
+	stderr := captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, func() {
+			if err := promptCommand(config, []string{"--tools", "get_time", "--usage", "what time is it?"}); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+
+	if stdout != "It is late.\n" {
+		t.Errorf("stdout = %q, want the final reply", stdout)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("%d requests sent, want 2", len(requests))
+	}
+	first, second := requests[0], requests[1]
+	if first.Stream || first.ToolChoice != "auto" || len(first.Tools) != 1 || first.Tools[0].Function.Name != "get_time" {
+		t.Errorf("first request = %+v, want get_time offered without streaming", first)
+	}
+	if len(second.Messages) != 3 || second.Messages[1].ToolCalls[0].ID != "call_1" || second.Messages[2].Role != "tool" || second.Messages[2].ToolCallID != "call_1" || second.Messages[2].Content == "" {
+		t.Errorf("second request messages = %+v, want the prompt, the tool call and its result", second.Messages)
+	}
+	if !strings.Contains(stderr, "* tool get_time({}) -> ") {
+		t.Errorf("stderr = %q, want the tool run printed in verbose mode", stderr)
+	}
+	if !strings.Contains(stderr, "30 prompt + 8 completion = 38 total") {
+		t.Errorf("stderr = %q, want the usage of both requests", stderr)
+	}
+
+	entries := readTestLogEntries(t, configDir)
+	if len(entries) != 1 || len(entries[0].ToolCalls) != 1 || entries[0].ToolCalls[0].Name != "get_time" || entries[0].ToolCalls[0].ResultBytes == 0 {
+		t.Errorf("log entries = %+v, want one entry with the get_time run", entries)
+	}
+}
+
+// TestPromptCommandToolRoundsLimit tests giving up on a model that keeps
+// asking for tools
+func TestPromptCommandToolRoundsLimit(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var requests []ChatRequest
+	server := newToolTestServer(t, &requests, true)
+	configDir := t.TempDir()
+	setTestEnv(envConfigDir, configDir)
+	setTestEnv(envAPIKey, "sk-test")
+	setTestEnv(envAPIURL, server.URL)
+	config, err := loadConfig("")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	captureOutput(t, &os.Stdout, func() {
+		err = promptCommand(config, []string{"--tools", "get_time", "--max-tool-rounds", "2", "hello"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "no reply after 2 rounds of tool calls") {
+		t.Errorf("promptCommand() error = %v, want the rounds limit", err)
+	}
+	if len(requests) != 3 {
+		t.Errorf("%d requests sent, want 3", len(requests))
+	}
+
+	entries := readTestLogEntries(t, configDir)
+	if len(entries) != 1 || entries[0].Error == "" || len(entries[0].ToolCalls) != 2 {
+		t.Errorf("log entries = %+v, want the failure and both tool runs", entries)
+	}
+}
+
+// TestPromptCommandToolsUsageErrors tests the --tools combinations refused
+func TestPromptCommandToolsUsageErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		args     []string
+	}{
+		{"unknown tool", providerOpenAI, []string{"--tools", "shell", "hi"}},
+		{"with --n", providerOpenAI, []string{"--tools", "get_time", "--n", "2", "hi"}},
+		{"no rounds", providerOpenAI, []string{"--tools", "get_time", "--max-tool-rounds", "0", "hi"}},
+		{"anthropic", providerAnthropic, []string{"--tools", "get_time", "hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Provider: tt.provider, APIKey: "sk-test"}
+			if err := promptCommand(config, tt.args); !errors.Is(err, ErrUsage) {
+				t.Errorf("promptCommand() error = %v, want a usage error", err)
+			}
+		})
+	}
+}
+
+// readTestLogEntries reads the entries of the log in configDir
+func readTestLogEntries(t *testing.T, configDir string) []LogEntry {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(configDir, logFileName))
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	var entries []LogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
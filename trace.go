@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variable naming the file spans are appended to
+const envTraceFile = "CHATGPT_CLI_TRACE_FILE"
+
+// Span kinds and status codes, as numbered by OTLP
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusOK    = 1
+	spanStatusError = 2
+)
+
+// Span is one timed operation, written as a line of the trace file: a run of
+// a command, a request to the API within it, and each attempt at sending
+// that request. Fields are named and encoded as in the OTLP JSON format, so
+// that the file can be converted for an OpenTelemetry collector.
+type Span struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId,omitempty"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	// Nanoseconds since the epoch, as strings like every 64-bit integer in
+	// OTLP JSON
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []SpanAttribute `json:"attributes,omitempty"`
+	Status            SpanStatus      `json:"status"`
+}
+
+// SpanAttribute is a key and its value, only one of whose fields is set
+type SpanAttribute struct {
+	Key   string         `json:"key"`
+	Value AttributeValue `json:"value"`
+}
+
+// AttributeValue holds a value in the field of its type
+type AttributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// SpanStatus tells whether the operation succeeded, with the error message
+// when it did not
+type SpanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// trace is the spans of one run of the CLI, all written to the same file
+type trace struct {
+	path string
+	id   string
+
+	mu sync.Mutex
+	// Tokens used by the requests of the run, added up for the root span
+	inputTokens, outputTokens int
+	warned                    bool
+}
+
+// traceSpan is a span being timed. A nil *traceSpan is valid and does
+// nothing, which is what every span is when tracing is off.
+type traceSpan struct {
+	trace *trace
+	span  Span
+	start time.Time
+}
+
+type traceSpanKey struct{}
+
+// startTrace starts the root span of a run of the CLI, named after the
+// command, if path names a trace file. Spans started from the returned
+// context nest under it.
+func startTrace(ctx context.Context, path, name string) (context.Context, *traceSpan) {
+	if path == "" {
+		return ctx, nil
+	}
+	t := &trace{path: path, id: strings.ReplaceAll(invocationID, "-", "")}
+	if len(t.id) != 32 {
+		t.id = newTraceID(16)
+	}
+	span := &traceSpan{trace: t, span: Span{TraceID: t.id, SpanID: newTraceID(8), Name: name, Kind: spanKindInternal}, start: time.Now()}
+	span.SetString("chatgpt_cli.command", name)
+	return context.WithValue(ctx, traceSpanKey{}, span), span
+}
+
+// startSpan starts a span nested under the one of ctx. It returns a nil span
+// when ctx carries none, so that tracing costs nothing when it is off.
+func startSpan(ctx context.Context, name string, kind int) (context.Context, *traceSpan) {
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &traceSpan{trace: parent.trace, start: time.Now(), span: Span{
+		TraceID:      parent.span.TraceID,
+		SpanID:       newTraceID(8),
+		ParentSpanID: parent.span.SpanID,
+		Name:         name,
+		Kind:         kind,
+	}}
+	return context.WithValue(ctx, traceSpanKey{}, span), span
+}
+
+// spanFromContext returns the span started last in ctx, or nil
+func spanFromContext(ctx context.Context) *traceSpan {
+	span, _ := ctx.Value(traceSpanKey{}).(*traceSpan)
+	return span
+}
+
+// SetString records a string attribute, unless value is empty
+func (s *traceSpan) SetString(key, value string) {
+	if s == nil || value == "" {
+		return
+	}
+	s.span.Attributes = append(s.span.Attributes, SpanAttribute{Key: key, Value: AttributeValue{StringValue: &value}})
+}
+
+// SetInt records an integer attribute
+func (s *traceSpan) SetInt(key string, value int) {
+	if s == nil {
+		return
+	}
+	v := strconv.Itoa(value)
+	s.span.Attributes = append(s.span.Attributes, SpanAttribute{Key: key, Value: AttributeValue{IntValue: &v}})
+}
+
+// SetBool records a boolean attribute
+func (s *traceSpan) SetBool(key string, value bool) {
+	if s == nil {
+		return
+	}
+	s.span.Attributes = append(s.span.Attributes, SpanAttribute{Key: key, Value: AttributeValue{BoolValue: &value}})
+}
+
+// SetUsage records the tokens used by a request and adds them to the totals
+// of the root span
+func (s *traceSpan) SetUsage(usage Usage) {
+	if s == nil || usage.TotalTokens == 0 {
+		return
+	}
+	s.SetInt("gen_ai.usage.input_tokens", usage.PromptTokens)
+	s.SetInt("gen_ai.usage.output_tokens", usage.CompletionTokens)
+	s.trace.mu.Lock()
+	s.trace.inputTokens += usage.PromptTokens
+	s.trace.outputTokens += usage.CompletionTokens
+	s.trace.mu.Unlock()
+}
+
+// End records the end of the span and whether err ended it, and appends it
+// to the trace file. The root span also gets the tokens used by the whole
+// run. A failed write is warned about once, without failing the command.
+func (s *traceSpan) End(err error) {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.span.StartTimeUnixNano = strconv.FormatInt(s.start.UnixNano(), 10)
+	s.span.EndTimeUnixNano = strconv.FormatInt(end.UnixNano(), 10)
+	s.span.Status = SpanStatus{Code: spanStatusOK}
+	if err != nil {
+		s.span.Status = SpanStatus{Code: spanStatusError, Message: err.Error()}
+		s.SetString("error.type", errorClass(err))
+	}
+
+	t := s.trace
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s.span.ParentSpanID == "" && t.inputTokens+t.outputTokens > 0 {
+		s.SetInt("gen_ai.usage.input_tokens", t.inputTokens)
+		s.SetInt("gen_ai.usage.output_tokens", t.outputTokens)
+	}
+	if err := appendSpan(t.path, s.span); err != nil && !t.warned {
+		t.warned = true
+		fmt.Fprintf(os.Stderr, "Warning: failed to write trace: %v\n", err)
+	}
+}
+
+// appendSpan writes span as one line at the end of the trace file, in a
+// single write so that the spans of processes tracing to the same file never
+// interleave
+func appendSpan(path string, span Span) error {
+	data, err := json.Marshal(span)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// newTraceID returns n random bytes in hex, as trace and span IDs are
+// written in OTLP JSON
+func newTraceID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return strings.Repeat("0", 2*n)
+	}
+	return hex.EncodeToString(b)
+}
+
+// errorClass names the kind of a failure for the error.type attribute, after
+// the error kinds that set the exit status
+func errorClass(err error) string {
+	switch {
+	case isCancelled(err):
+		return "cancelled"
+	case errors.Is(err, ErrUsage):
+		return "usage"
+	case errors.Is(err, ErrAuth):
+		return "auth"
+	case errors.Is(err, ErrRateLimit):
+		return "rate_limit"
+	case errors.Is(err, ErrServer):
+		return "server"
+	case errors.Is(err, ErrNetwork):
+		return "network"
+	case errors.Is(err, ErrRequestBudget):
+		return "request_budget"
+	}
+	return "_OTHER"
+}
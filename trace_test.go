@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// readTestSpans returns the spans of a trace file as generic JSON, so that
+// tests check the field names written rather than those of Span
+func readTestSpans(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read trace: %v", err)
+	}
+	var spans []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var span map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &span); err != nil {
+			t.Fatalf("trace line %q: %v", line, err)
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// spanAttribute returns the value of an attribute of a span as written, such
+// as {"intValue": "12"}, or nil
+func spanAttribute(span map[string]interface{}, key string) map[string]interface{} {
+	attributes, _ := span["attributes"].([]interface{})
+	for _, a := range attributes {
+		attribute := a.(map[string]interface{})
+		if attribute["key"] == key {
+			return attribute["value"].(map[string]interface{})
+		}
+	}
+	return nil
+}
+
+// TestTraceSchema tests the OTLP fields of the spans of a prompt whose
+// request is retried once: the run, the request and each attempt
+func TestTraceSchema(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	originalDelay := retryDelay
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = originalDelay }()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("failed to hijack connection: %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		fmt.Fprint(w, `{"id":"chatcmpl-1","model":"gpt-4o-2024","choices":[{"message":{"role":"assistant","content":"4"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "traces.jsonl")
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Provider: providerOpenAI, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: dir, NoColor: true}
+
+	var span *traceSpan
+	config.ctx, span = startTrace(context.Background(), path, "prompt")
+	captureOutput(t, &os.Stdout, func() {
+		if err := promptCommand(config, []string{"--no-stream", "2+2?"}); err != nil {
+			t.Errorf("promptCommand() error = %v", err)
+		}
+	})
+	span.End(nil)
+
+	spans := readTestSpans(t, path)
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, want 2 attempts, the request and the run", len(spans))
+	}
+	first, second, request, root := spans[0], spans[1], spans[2], spans[3]
+
+	hexID := regexp.MustCompile(`^[0-9a-f]+$`)
+	for i, s := range spans {
+		traceID, _ := s["traceId"].(string)
+		spanID, _ := s["spanId"].(string)
+		if len(traceID) != 32 || !hexID.MatchString(traceID) || traceID != root["traceId"] {
+			t.Errorf("span %d traceId = %q, want the 32 hex digits of the run", i, traceID)
+		}
+		if len(spanID) != 16 || !hexID.MatchString(spanID) {
+			t.Errorf("span %d spanId = %q, want 16 hex digits", i, spanID)
+		}
+		start, err1 := strconv.ParseInt(fmt.Sprint(s["startTimeUnixNano"]), 10, 64)
+		end, err2 := strconv.ParseInt(fmt.Sprint(s["endTimeUnixNano"]), 10, 64)
+		if _, ok := s["startTimeUnixNano"].(string); !ok || err1 != nil || err2 != nil || end < start {
+			t.Errorf("span %d times = %v, %v, want nanoseconds as strings", i, s["startTimeUnixNano"], s["endTimeUnixNano"])
+		}
+		if _, ok := s["status"].(map[string]interface{})["code"].(float64); !ok {
+			t.Errorf("span %d status = %v, want a code", i, s["status"])
+		}
+	}
+
+	if _, ok := root["parentSpanId"]; ok || root["name"] != "prompt" || root["kind"] != float64(spanKindInternal) {
+		t.Errorf("root span = %v", root)
+	}
+	if request["parentSpanId"] != root["spanId"] || request["name"] != "chat gpt-4o" || request["kind"] != float64(spanKindClient) {
+		t.Errorf("request span = %v", request)
+	}
+	if first["parentSpanId"] != request["spanId"] || second["parentSpanId"] != request["spanId"] || first["name"] != "POST" {
+		t.Errorf("attempt spans = %v, %v, want POST spans of the request", first, second)
+	}
+
+	tests := []struct {
+		span map[string]interface{}
+		key  string
+		want string
+	}{
+		{root, "chatgpt_cli.command", `{"stringValue":"prompt"}`},
+		{root, "chatgpt_cli.stream", `{"boolValue":false}`},
+		{root, "gen_ai.usage.input_tokens", `{"intValue":"12"}`},
+		{root, "gen_ai.usage.output_tokens", `{"intValue":"3"}`},
+		{request, "gen_ai.system", `{"stringValue":"openai"}`},
+		{request, "gen_ai.request.model", `{"stringValue":"gpt-4o"}`},
+		{request, "gen_ai.response.model", `{"stringValue":"gpt-4o-2024"}`},
+		{request, "gen_ai.usage.output_tokens", `{"intValue":"3"}`},
+		{first, "error.type", `{"stringValue":"_OTHER"}`},
+		{second, "http.request.resend_count", `{"intValue":"1"}`},
+		{second, "http.response.status_code", `{"intValue":"200"}`},
+	}
+	for _, tt := range tests {
+		got, _ := json.Marshal(spanAttribute(tt.span, tt.key))
+		if string(got) != tt.want {
+			t.Errorf("%s = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+	if status := first["status"].(map[string]interface{}); status["code"] != float64(spanStatusError) || status["message"] == "" {
+		t.Errorf("first attempt status = %v, want an error", status)
+	}
+	if status := root["status"].(map[string]interface{}); status["code"] != float64(spanStatusOK) {
+		t.Errorf("root status = %v, want OK", status)
+	}
+}
+
+// TestTraceDisabled tests that no span is started without a trace file
+func TestTraceDisabled(t *testing.T) {
+	ctx, root := startTrace(context.Background(), "", "prompt")
+	_, span := startSpan(ctx, "chat", spanKindClient)
+	if root != nil || span != nil {
+		t.Fatalf("spans = %v, %v, want none", root, span)
+	}
+	// Nil spans do nothing
+	span.SetString("key", "value")
+	span.SetUsage(Usage{PromptTokens: 1, TotalTokens: 1})
+	span.End(ErrServer)
+}
+
+// TestErrorClass tests the error.type of failures
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{usageErrorf("bad flag"), "usage"},
+		{withKind(ErrRateLimit, fmt.Errorf("slow down")), "rate_limit"},
+		{fmt.Errorf("request: %w", context.Canceled), "cancelled"},
+		{fmt.Errorf("something else"), "_OTHER"},
+	}
+	for _, tt := range tests {
+		if got := errorClass(tt.err); got != tt.want {
+			t.Errorf("errorClass(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Transcription model and timeout used when OPENAI_TRANSCRIBE_MODEL and
+// OPENAI_TRANSCRIBE_TIMEOUT are unset. Long recordings take minutes to
+// transcribe, so the timeout is well above the chat one.
+const (
+	defaultTranscribeModel   = "whisper-1"
+	defaultTranscribeTimeout = 10 * time.Minute
+)
+
+// Largest audio file the transcriptions endpoint accepts
+const maxTranscribeFileSize = 25 << 20
+
+// Transcript formats of transcribe --format, sent as response_format
+var transcriptFormats = []string{"text", "srt", "vtt", "json"}
+
+// transcribeCommand prints the transcript of an audio file
+func transcribeCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli transcribe [--language code] [--format text|srt|vtt|json] [--out path] <audio file>"
+
+	fs := flag.NewFlagSet("transcribe", flag.ContinueOnError)
+	language := fs.String("language", "", "language of the audio as an ISO-639-1 code such as it (default: detected)")
+	format := fs.String("format", "text", "transcript format: text, srt, vtt or json")
+	outFile := fs.String("out", "", "write the transcript to this file instead of stdout")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+
+	if len(args) != 1 {
+		return usageErrorf("give exactly one audio file\n%s", usage)
+	}
+	path := args[0]
+	if !isTranscriptFormat(*format) {
+		return usageErrorf("invalid format: %s (valid: %s)", *format, strings.Join(transcriptFormats, ", "))
+	}
+	if config.Provider == providerAnthropic || config.Provider == providerOllama {
+		return usageErrorf("the %s provider has no transcriptions endpoint; use %s or %s", config.Provider, providerOpenAI, providerAzure)
+	}
+
+	// The file is checked before the upload, which the API would refuse
+	// only after receiving it
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return usageErrorf("%s is not a file", path)
+	}
+	if info.Size() > maxTranscribeFileSize {
+		return usageErrorf("%s is %.1fMB, over the %s the transcriptions API accepts; split or compress it first", path, float64(info.Size())/(1<<20), formatSize(maxTranscribeFileSize))
+	}
+
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	stopProgress := startProgress(config)
+	transcript, err := fetchTranscript(config.requestContext(), config, path, *language, *format)
+	stopProgress()
+
+	// Transcripts are logged in full only like attached files
+	entry := LogEntry{
+		Timestamp: time.Now(),
+		Command:   "transcribe",
+		Prompt:    path,
+		Model:     config.TranscribeModel,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		if isCancelled(err) {
+			entry.Error = cancelledLogMessage
+		}
+		warnLogError(config, writeLogEntry(config, entry))
+		return fmt.Errorf("failed to transcribe audio: %w", err)
+	}
+	entry.Response = fmt.Sprintf("[%s transcript of %d characters]", *format, len(transcript))
+	if config.LogFullPrompt {
+		entry.Response = transcript
+	}
+	warnLogError(config, writeLogEntry(config, entry))
+
+	if !strings.HasSuffix(transcript, "\n") {
+		transcript += "\n"
+	}
+	if *outFile == "" {
+		fmt.Print(transcript)
+		return nil
+	}
+	if err := os.WriteFile(*outFile, []byte(transcript), 0644); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	fmt.Printf("Wrote the transcript of %s to %s\n", path, *outFile)
+	return nil
+}
+
+// isTranscriptFormat reports whether format is one of transcriptFormats
+func isTranscriptFormat(format string) bool {
+	for _, f := range transcriptFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// getTranscriptionsURL returns the audio transcriptions endpoint, derived
+// from the chat completions URL: /v1/audio/transcriptions for OpenAI and the
+// deployment's audio/transcriptions for Azure
+func getTranscriptionsURL(config *Config) (string, error) {
+	return siblingEndpointURL(config, "/audio/transcriptions")
+}
+
+// uploadBody is the multipart body of a transcription request, which closes
+// the audio file once sent
+type uploadBody struct {
+	io.Reader
+	file *os.File
+}
+
+func (b *uploadBody) Close() error {
+	return b.file.Close()
+}
+
+// newTranscriptionRequest builds the multipart request uploading the audio
+// file. The file is streamed from disk between the form fields rather than
+// read into memory, and the length of the body is known in advance.
+func newTranscriptionRequest(ctx context.Context, config *Config, path, language, format string) (*http.Request, error) {
+	var form bytes.Buffer
+	w := multipart.NewWriter(&form)
+	fields := [][2]string{{"response_format", format}}
+	// Azure encodes the model in the deployment URL
+	if config.Provider != providerAzure {
+		fields = append(fields, [2]string{"model", config.TranscribeModel})
+	}
+	if language != "" {
+		fields = append(fields, [2]string{"language", language})
+	}
+	for _, field := range fields {
+		if err := w.WriteField(field[0], field[1]); err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+	}
+	if _, err := w.CreateFormFile("file", filepath.Base(path)); err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	head := append([]byte(nil), form.Bytes()...)
+	form.Reset()
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	tail := form.Bytes()
+
+	apiURL, err := getTranscriptionsURL(config)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	body := &uploadBody{Reader: io.MultiReader(bytes.NewReader(head), file, bytes.NewReader(tail)), file: file}
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, body)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = int64(len(head)) + info.Size() + int64(len(tail))
+
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	setAuthHeader(req, config)
+	if err := setExtraHeaders(req, config); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return req, nil
+}
+
+// fetchTranscript uploads the audio file and returns its transcript in the
+// given format, JSON being indented
+func fetchTranscript(ctx context.Context, config *Config, path, language, format string) (string, error) {
+	// Transcriptions have a timeout of their own
+	client := newAPIClient(config)
+	client.http.Timeout = config.TranscribeTimeout
+
+	resp, err := client.doLimited(ctx, func() (*http.Request, error) {
+		return newTranscriptionRequest(ctx, config, path, language, format)
+	})
+	if err != nil {
+		if classifyNetworkError(err) == netErrTimeout {
+			return "", withKind(ErrNetwork, fmt.Errorf("the transcription did not finish within %s\nHint: increase %s for long recordings", client.http.Timeout, envTranscribeTimeout))
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", newStatusError(resp.StatusCode, body)
+	}
+
+	if format != "json" {
+		return string(body), nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, body, "", "  "); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return indented.String(), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Transcript returned by the test server in srt format
+const testSRT = "1\n00:00:00,000 --> 00:00:02,500\nCiao a tutti.\n"
+
+// TestTranscribeCommand tests uploading the audio file as multipart form
+// data and printing the transcript, logged only by file name
+func TestTranscribeCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var path string
+	var fields map[string]string
+	var fileName, fileContent string
+	var contentLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		contentLength = r.ContentLength
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("invalid multipart body: %v", err)
+			return
+		}
+		fields = map[string]string{}
+		for key, values := range r.MultipartForm.Value {
+			fields[key] = values[0]
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("no file in the request: %v", err)
+			return
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		fileName, fileContent = header.Filename, string(data)
+
+		switch fields["response_format"] {
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"text":"Ciao a tutti."}`))
+		default:
+			w.Write([]byte(testSRT))
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	audio := filepath.Join(tmpDir, "talk.mp3")
+	if err := os.WriteFile(audio, []byte("ID3 fake audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The chat timeout must not apply to transcriptions
+	newConfig := func() *Config {
+		return &Config{APIKey: "test-key", APIURL: server.URL + "/v1/chat/completions", TranscribeModel: defaultTranscribeModel,
+			Timeout: time.Nanosecond, TranscribeTimeout: 10 * time.Second, ConfigDir: tmpDir}
+	}
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := transcribeCommand(newConfig(), []string{"--language", "it", "--format", "srt", audio}); err != nil {
+			t.Fatalf("transcribe error = %v", err)
+		}
+	})
+	if out != testSRT {
+		t.Errorf("output = %q, want the transcript", out)
+	}
+	if path != "/v1/audio/transcriptions" || fields["model"] != "whisper-1" || fields["language"] != "it" || fields["response_format"] != "srt" {
+		t.Errorf("request to %s with fields %v", path, fields)
+	}
+	if fileName != "talk.mp3" || fileContent != "ID3 fake audio" || contentLength <= int64(len(fileContent)) {
+		t.Errorf("uploaded %q = %q with a length of %d", fileName, fileContent, contentLength)
+	}
+
+	outFile := filepath.Join(tmpDir, "talk.json")
+	config := newConfig()
+	config.LogFullPrompt = true
+	captureOutput(t, &os.Stdout, func() {
+		if err := transcribeCommand(config, []string{"--format", "json", "--out", outFile, audio}); err != nil {
+			t.Fatalf("transcribe error = %v", err)
+		}
+	})
+	if data, err := os.ReadFile(outFile); err != nil || string(data) != "{\n  \"text\": \"Ciao a tutti.\"\n}\n" {
+		t.Errorf("%s = %q, %v; want the indented JSON", outFile, data, err)
+	}
+	if _, ok := fields["language"]; ok {
+		t.Errorf("language sent without --language: %v", fields)
+	}
+
+	entries := readTestLogEntries(t, tmpDir)
+	if len(entries) != 2 {
+		t.Fatalf("log entries = %+v, want 2", entries)
+	}
+	if entries[0].Command != "transcribe" || entries[0].Prompt != audio || entries[0].Model != "whisper-1" || strings.Contains(entries[0].Response, "Ciao") {
+		t.Errorf("log entry = %+v, want the file logged without the transcript", entries[0])
+	}
+	if !strings.Contains(entries[1].Response, "Ciao a tutti.") {
+		t.Errorf("log entry = %+v, want the transcript logged with CHATGPT_CLI_LOG_FULL_PROMPT", entries[1])
+	}
+	if inHistory(entries[0]) {
+		t.Errorf("transcriptions are listed in the history")
+	}
+}
+
+// TestTranscribeCommandErrors tests the checks made before uploading and the
+// timeout of transcriptions
+func TestTranscribeCommandErrors(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("too late"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL, TranscribeModel: defaultTranscribeModel, Timeout: 10 * time.Second, TranscribeTimeout: 50 * time.Millisecond, ConfigDir: tmpDir}
+
+	large := filepath.Join(tmpDir, "long.wav")
+	f, err := os.Create(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Truncate(maxTranscribeFileSize + 1); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	audio := filepath.Join(tmpDir, "short.mp3")
+	if err := os.WriteFile(audio, []byte("ID3 fake audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	usageTests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{large}, "over the 25MB the transcriptions API accepts"},
+		{[]string{"--format", "docx", audio}, "invalid format: docx"},
+		{[]string{tmpDir}, "is not a file"},
+		{nil, "give exactly one audio file"},
+	}
+	for _, tt := range usageTests {
+		err := transcribeCommand(config, tt.args)
+		if exitCode(err) != exitUsage || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("transcribe %q error = %v, want a usage error containing %q", tt.args, err, tt.want)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("%d requests sent for invalid input", requests)
+	}
+
+	err = transcribeCommand(config, []string{audio})
+	if err == nil || !strings.Contains(err.Error(), "did not finish within 50ms") || !strings.Contains(err.Error(), envTranscribeTimeout) {
+		t.Errorf("timeout error = %v, want a hint naming %s", err, envTranscribeTimeout)
+	}
+}
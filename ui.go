@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Rule printed under headings
+var headingRule = strings.Repeat("━", 51)
+
+// ui writes the human output of commands. Labels, timestamps and errors are
+// styled only when the output is a terminal and colors are not disabled, so
+// piped output never contains escape codes.
+type ui struct {
+	w     io.Writer
+	color bool
+	// tty is set when w is a terminal, which tables are aligned for, and
+	// width to its width in columns, or 0 when unknown
+	tty   bool
+	width int
+}
+
+// newUI returns a ui writing to f. config may be nil when it failed to load.
+func newUI(config *Config, f *os.File) *ui {
+	tty := isTerminal(f)
+	out := &ui{w: f, color: colorEnabled(config) && tty, tty: tty}
+	if tty {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+			out.width = width
+		}
+	}
+	return out
+}
+
+// colorEnabled reports whether colors are allowed by CHATGPT_CLI_NO_COLOR and
+// the NO_COLOR convention (https://no-color.org), where any value disables them
+func colorEnabled(config *Config) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return config == nil || !config.NoColor
+}
+
+// style wraps s in an ANSI style when colors are on
+func (u *ui) style(on, off, s string) string {
+	if !u.color || s == "" {
+		return s
+	}
+	return on + s + off
+}
+
+func (u *ui) bold(s string) string   { return u.style(ansiBold, ansiBoldOff, s) }
+func (u *ui) dim(s string) string    { return u.style(ansiDim, ansiBoldOff, s) }
+func (u *ui) red(s string) string    { return u.style(ansiRed, ansiDefaultFg, s) }
+func (u *ui) yellow(s string) string { return u.style(ansiYellow, ansiDefaultFg, s) }
+
+// highlight marks search matches in bold yellow
+func (u *ui) highlight(s string) string {
+	return u.style(ansiBold+ansiYellow, ansiDefaultFg+ansiBoldOff, s)
+}
+
+// Printf writes formatted text as is
+func (u *ui) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(u.w, format, args...)
+}
+
+// Println writes args followed by a newline
+func (u *ui) Println(args ...interface{}) {
+	fmt.Fprintln(u.w, args...)
+}
+
+// field prints a dim "label:" padded to width columns, then the value. The
+// padding is computed on the plain label, so styled fields still line up.
+func (u *ui) field(indent, label string, width int, value string) {
+	padding := " "
+	if n := width - len(label) - 1; n > 0 {
+		padding += strings.Repeat(" ", n)
+	}
+	u.Printf("%s%s%s%s\n", indent, u.dim(label+":"), padding, value)
+}
+
+// heading prints a bold title underlined by a rule
+func (u *ui) heading(title string) {
+	u.Println(u.bold(title))
+	u.Println(u.dim(headingRule))
+}
+
+// printError prints a command's error, with a red label
+func (u *ui) printError(err error) {
+	u.Printf("%s %v\n", u.red("Error:"), err)
+}
+
+// helpText styles the section titles of the help text: the unindented
+// lines ending with a colon
+func (u *ui) helpText(text string) string {
+	if !u.color {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" && !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":") {
+			lines[i] = u.bold(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/ui/<name>.golden, rewriting the
+// file instead with -update
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "ui", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run go test -update to create it): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// TestColorEnabled tests the settings that turn colors off
+func TestColorEnabled(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if !colorEnabled(&Config{}) || !colorEnabled(nil) {
+		t.Error("colorEnabled() = false, want colors by default")
+	}
+	if colorEnabled(&Config{NoColor: true}) {
+		t.Error("colorEnabled() = true with CHATGPT_CLI_NO_COLOR")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled(&Config{}) || colorEnabled(nil) {
+		t.Error("colorEnabled() = true with NO_COLOR")
+	}
+}
+
+// TestNewUIPipe tests that output that is not a terminal is never styled
+func TestNewUIPipe(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	if out := newUI(&Config{}, w); out.color || out.red("x") != "x" {
+		t.Errorf("newUI() on a pipe has colors on")
+	}
+}
+
+// testConfigValues are the values of the config list golden files
+var testConfigValues = []configValue{
+	{"OPENAI_API_KEY", "sk-t...cdef (from config file)"},
+	{"OPENAI_MODEL", "gpt-4o"},
+	{"OPENAI_STOP", "(not set)"},
+	{"CHATGPT_CLI_LOG_FULL_PROMPT", "false"},
+}
+
+// TestPrintConfigValues tests config list output on a terminal, plain and
+// colored, and piped
+func TestPrintConfigValues(t *testing.T) {
+	for _, color := range []bool{false, true} {
+		var b strings.Builder
+		printConfigValues(&ui{w: &b, color: color, tty: true}, testConfigValues, nil)
+		checkGolden(t, goldenName("config_list", color), b.String())
+	}
+
+	var b strings.Builder
+	printConfigValues(&ui{w: &b}, testConfigValues, nil)
+	if got, want := b.String(), "OPENAI_API_KEY\tsk-t...cdef (from config file)\nOPENAI_MODEL\tgpt-4o\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("piped output = %q, want it to start with %q", got, want)
+	}
+}
+
+// TestPrintLogEntries tests logs output, plain and colored
+func TestPrintLogEntries(t *testing.T) {
+	timestamp := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	entries := []LogEntry{
+		{
+			Timestamp: timestamp,
+			Command:   "prompt",
+			Prompt:    "What is Go?",
+			Response:  "A programming language.",
+			Usage:     &Usage{PromptTokens: 4, CompletionTokens: 5, TotalTokens: 9},
+			LatencyMs: 1250,
+			ToolCalls: []ToolRun{{Name: "read_file", Arguments: `{"path":"x"}`, Error: "not found"}},
+		},
+		{
+			Timestamp: timestamp.Add(time.Minute),
+			Command:   "prompt",
+			Prompt:    "Hello",
+			Error:     "request timed out",
+		},
+	}
+
+	for _, color := range []bool{false, true} {
+		var b strings.Builder
+		printLogEntries(&ui{w: &b, color: color}, entries, defaultLogPreviewLen, timestampFormat{utc: true})
+		checkGolden(t, goldenName("logs", color), b.String())
+	}
+}
+
+// TestUIHelpersPlain tests that plain output has no escape codes
+func TestUIHelpersPlain(t *testing.T) {
+	var b strings.Builder
+	out := &ui{w: &b}
+	out.printError(errors.New("boom"))
+	out.Printf("%s", out.helpText("Usage:\n  cmd\n"))
+	if got := b.String(); got != "Error: boom\nUsage:\n  cmd\n" {
+		t.Errorf("plain output = %q", got)
+	}
+
+	b.Reset()
+	out.color = true
+	out.printError(errors.New("boom"))
+	out.Printf("%s", out.helpText("Usage:\n  cmd:\n"))
+	if got, want := b.String(), ansiRed+"Error:"+ansiDefaultFg+" boom\n"+ansiBold+"Usage:"+ansiBoldOff+"\n  cmd:\n"; got != want {
+		t.Errorf("colored output = %q, want %q", got, want)
+	}
+}
+
+// goldenName names the golden file of a plain or colored output
+func goldenName(name string, color bool) string {
+	if color {
+		return name + "_color"
+	}
+	return name
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// GitHub releases API of the CLI. Replaced in tests.
+var releasesURL = "https://api.github.com/repos/umbertocicciaa/chatgpt-cli/releases"
+
+// Returns the path of the running binary, with symlinks resolved so that
+// the binary itself is replaced rather than the link. Replaced in tests.
+var executablePath = func() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+// Name of the release asset listing the SHA-256 of the binaries, in the
+// format of sha256sum
+const checksumsAssetName = "checksums.txt"
+
+// Largest release asset update downloads
+const maxUpdateDownloadSize = 200 << 20
+
+// Release is a GitHub release of the CLI
+type Release struct {
+	TagName string         `json:"tag_name"`
+	HTMLURL string         `json:"html_url"`
+	Assets  []ReleaseAsset `json:"assets"`
+}
+
+// ReleaseAsset is a file attached to a release
+type ReleaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// asset returns the asset of the release with the given name
+func (r *Release) asset(name string) (ReleaseAsset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return ReleaseAsset{}, false
+}
+
+// updateCommand replaces the running binary with the latest release, or the
+// one given with --version, after checking its SHA-256 against the
+// checksums published with it
+func updateCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli update [--check] [--version vX.Y.Z]"
+
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	check := fs.Bool("check", false, "only report whether a newer version is available")
+	pin := fs.String("version", "", "install this version instead of the latest, such as v1.2.3")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) > 0 {
+		return usageErrorf("unexpected argument: %s\n%s", args[0], usage)
+	}
+	if *pin != "" && !strings.HasPrefix(*pin, "v") {
+		*pin = "v" + *pin
+	}
+
+	// Release assets are checked against their own, larger limit
+	unlimited := *config
+	unlimited.MaxResponseBytes = 0
+	client := newHTTPClient(&unlimited)
+	release, err := fetchRelease(config, client, *pin)
+	if err != nil {
+		return err
+	}
+
+	if *pin == "" && compareVersions(release.TagName, version) <= 0 {
+		fmt.Printf("chatgpt-cli %s is the latest version.\n", version)
+		return nil
+	}
+	if *pin == version {
+		fmt.Printf("chatgpt-cli %s is already installed.\n", version)
+		return nil
+	}
+	if *check {
+		fmt.Printf("chatgpt-cli %s is available (installed: %s): %s\n", release.TagName, version, release.HTMLURL)
+		fmt.Println("Run 'chatgpt-cli update' to install it.")
+		return nil
+	}
+
+	exe, err := executablePath()
+	if err != nil {
+		return fmt.Errorf("failed to find the running binary: %w", err)
+	}
+	// Refuse before downloading anything if the binary can't be replaced
+	if err := checkWritableDir(filepath.Dir(exe)); err != nil {
+		return updatePermissionError(exe, runtime.GOOS, err)
+	}
+
+	name := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	asset, ok := release.asset(name)
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s (%s)", release.TagName, runtime.GOOS, runtime.GOARCH, name)
+	}
+	checksums, ok := release.asset(checksumsAssetName)
+	if !ok {
+		return fmt.Errorf("release %s has no %s; refusing to install a binary that can't be verified", release.TagName, checksumsAssetName)
+	}
+
+	fmt.Printf("Downloading %s %s...\n", name, release.TagName)
+	sums, err := downloadAsset(config, client, checksums)
+	if err != nil {
+		return err
+	}
+	want, err := parseChecksum(sums, name)
+	if err != nil {
+		return err
+	}
+	binary, err := downloadAsset(config, client, asset)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(binary)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s; the binary was not replaced", name, got, want)
+	}
+
+	if err := replaceExecutable(exe, binary, runtime.GOOS); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+	fmt.Printf("Updated chatgpt-cli from %s to %s (%s)\n", version, release.TagName, exe)
+	return nil
+}
+
+// fetchRelease returns the latest release, or the one tagged tag
+func fetchRelease(config *Config, client *http.Client, tag string) (*Release, error) {
+	endpoint := releasesURL + "/latest"
+	if tag != "" {
+		endpoint = releasesURL + "/tags/" + tag
+	}
+
+	req, err := http.NewRequestWithContext(config.requestContext(), "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "chatgpt-cli/"+version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		if tag != "" {
+			return nil, fmt.Errorf("no release %s found", tag)
+		}
+		return nil, fmt.Errorf("no release found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for updates: HTTP %s", resp.Status)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %w", err)
+	}
+	if release.TagName == "" {
+		return nil, fmt.Errorf("failed to parse release: no tag name")
+	}
+	return &release, nil
+}
+
+// downloadAsset returns the contents of a release asset
+func downloadAsset(config *Config, client *http.Client, asset ReleaseAsset) ([]byte, error) {
+	req, err := http.NewRequestWithContext(config.requestContext(), "GET", asset.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "chatgpt-cli/"+version)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: HTTP %s", asset.Name, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxUpdateDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", asset.Name, err)
+	}
+	if len(data) > maxUpdateDownloadSize {
+		return nil, fmt.Errorf("%s is larger than %d MB", asset.Name, maxUpdateDownloadSize>>20)
+	}
+	return data, nil
+}
+
+// releaseAssetName returns the name of the binary built for an OS and
+// architecture by the release workflow
+func releaseAssetName(goos, goarch string) string {
+	name := "chatgpt-cli-" + goos + "-" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// parseChecksum returns the SHA-256 listed for name in a sha256sum file,
+// whose lines are "<hex>  <name>", with a * before binary-mode names
+func parseChecksum(sums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			sum := strings.ToLower(fields[0])
+			if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+				return "", fmt.Errorf("invalid checksum for %s in %s", name, checksumsAssetName)
+			}
+			return sum, nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsAssetName, name)
+}
+
+// compareVersions compares two vMAJOR.MINOR.PATCH versions, returning -1, 0
+// or 1. Pre-release suffixes are ignored. A version that can't be parsed,
+// such as dev for a build from source, is older than any other.
+func compareVersions(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return -1
+	case !okB:
+		return 1
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			if va[i] < vb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseVersion parses v1.2.3, 1.2 or v1.2.3-rc.1 into its numbers
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// checkWritableDir fails if a file can't be created in dir
+func checkWritableDir(dir string) error {
+	f, err := os.CreateTemp(dir, ".chatgpt-cli-update-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// updatePermissionError explains that the binary at exe can't be replaced by
+// the current user, with how to run the update with the rights needed
+func updatePermissionError(exe, goos string, err error) error {
+	if !errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("cannot write to %s: %w", filepath.Dir(exe), err)
+	}
+	hint := "run 'sudo chatgpt-cli update'"
+	if goos == "windows" {
+		hint = "run 'chatgpt-cli update' from a terminal opened as administrator"
+	}
+	return fmt.Errorf("%s is in %s, which you can't write to; %s", filepath.Base(exe), filepath.Dir(exe), hint)
+}
+
+// replaceExecutable atomically replaces the binary at exe with data, keeping
+// its permissions. The new binary is written next to it, then renamed over
+// it. Windows can't replace a running binary, but can rename it, so the old
+// one is first moved to <exe>.old, which the next update removes.
+func replaceExecutable(exe string, data []byte, goos string) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".chatgpt-cli-update-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := io.Copy(tmp, bytes.NewReader(data)); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, info.Mode().Perm()|0111); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if goos == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			os.Remove(tmpName)
+			return err
+		}
+		if err := os.Rename(tmpName, exe); err != nil {
+			// Put the old binary back so the CLI keeps working
+			os.Rename(old, exe)
+			os.Remove(tmpName)
+			return err
+		}
+		return nil
+	}
+
+	if err := os.Rename(tmpName, exe); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// releaseServer serves the releases API and the assets of the given
+// releases, with the binary for this platform holding binary
+type releaseServer struct {
+	*httptest.Server
+	latest   string
+	binaries map[string]string
+	// checksum replaces the published checksum of the binaries when set
+	checksum string
+}
+
+func newReleaseServer(t *testing.T, latest string, binaries map[string]string) *releaseServer {
+	t.Helper()
+
+	s := &releaseServer{latest: latest, binaries: binaries}
+	name := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		switch {
+		case r.URL.Path == "/releases/latest":
+			s.writeRelease(w, s.latest, name)
+		case len(parts) == 3 && parts[0] == "releases" && parts[1] == "tags":
+			if _, ok := s.binaries[parts[2]]; !ok {
+				http.NotFound(w, r)
+				return
+			}
+			s.writeRelease(w, parts[2], name)
+		case len(parts) == 3 && parts[0] == "download" && parts[2] == name:
+			fmt.Fprint(w, s.binaries[parts[1]])
+		case len(parts) == 3 && parts[0] == "download" && parts[2] == checksumsAssetName:
+			sum := sha256.Sum256([]byte(s.binaries[parts[1]]))
+			checksum := hex.EncodeToString(sum[:])
+			if s.checksum != "" {
+				checksum = s.checksum
+			}
+			fmt.Fprintf(w, "%s  chatgpt-cli-plan9-mips\n%s  %s\n", strings.Repeat("0", 64), checksum, name)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	originalURL, originalVersion := releasesURL, version
+	releasesURL = s.URL + "/releases"
+	t.Cleanup(func() { releasesURL, version = originalURL, originalVersion })
+	return s
+}
+
+func (s *releaseServer) writeRelease(w http.ResponseWriter, tag, name string) {
+	release := Release{TagName: tag, HTMLURL: "https://github.com/umbertocicciaa/chatgpt-cli/releases/tag/" + tag}
+	for _, asset := range []string{name, checksumsAssetName} {
+		release.Assets = append(release.Assets, ReleaseAsset{Name: asset, URL: s.URL + "/download/" + tag + "/" + asset})
+	}
+	json.NewEncoder(w).Encode(release)
+}
+
+// useTestExecutable makes update replace a file in a temporary directory
+// instead of the test binary, and returns its path
+func useTestExecutable(t *testing.T) string {
+	t.Helper()
+
+	exe := filepath.Join(t.TempDir(), "chatgpt-cli")
+	if err := os.WriteFile(exe, []byte("old binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	original := executablePath
+	executablePath = func() (string, error) { return exe, nil }
+	t.Cleanup(func() { executablePath = original })
+	return exe
+}
+
+// runUpdate runs the update command and returns its output
+func runUpdate(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	var err error
+	config := &Config{Timeout: 10 * time.Second}
+	out := captureOutput(t, &os.Stdout, func() {
+		err = updateCommand(config, args)
+	})
+	return out, err
+}
+
+// TestUpdateCommand tests checking for, downloading and installing a release
+func TestUpdateCommand(t *testing.T) {
+	newReleaseServer(t, "v1.2.0", map[string]string{"v1.2.0": "new binary", "v1.0.0": "pinned binary"})
+	exe := useTestExecutable(t)
+
+	version = "v1.2.0"
+	out, err := runUpdate(t)
+	if err != nil || !strings.Contains(out, "chatgpt-cli v1.2.0 is the latest version") {
+		t.Errorf("update on the latest version = %q, %v", out, err)
+	}
+
+	version = "v1.1.0"
+	out, err = runUpdate(t, "--check")
+	if err != nil || !strings.Contains(out, "chatgpt-cli v1.2.0 is available (installed: v1.1.0)") {
+		t.Errorf("update --check = %q, %v", out, err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old binary" {
+		t.Fatalf("update --check replaced the binary")
+	}
+
+	out, err = runUpdate(t)
+	if err != nil || !strings.Contains(out, "Updated chatgpt-cli from v1.1.0 to v1.2.0") {
+		t.Fatalf("update = %q, %v", out, err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "new binary" {
+		t.Errorf("binary = %q, want the new release", data)
+	}
+	if info, err := os.Stat(exe); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("binary mode = %v, %v; want 0755", info, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(exe)); len(entries) != 1 {
+		t.Errorf("files left next to the binary: %v", entries)
+	}
+
+	// --version installs an older release, or fails for a missing one
+	version = "v1.2.0"
+	if out, err = runUpdate(t, "--version", "1.0.0"); err != nil || !strings.Contains(out, "to v1.0.0") {
+		t.Fatalf("update --version 1.0.0 = %q, %v", out, err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "pinned binary" {
+		t.Errorf("binary = %q, want the pinned release", data)
+	}
+	if _, err = runUpdate(t, "--version", "v9.9.9"); err == nil || !strings.Contains(err.Error(), "no release v9.9.9 found") {
+		t.Errorf("update --version v9.9.9 error = %v", err)
+	}
+
+	if _, err := runUpdate(t, "now"); exitCode(err) != exitUsage {
+		t.Errorf("update now error = %v, want a usage error", err)
+	}
+}
+
+// TestUpdateChecksumMismatch tests that a binary whose SHA-256 differs from
+// the published one is never installed
+func TestUpdateChecksumMismatch(t *testing.T) {
+	server := newReleaseServer(t, "v1.2.0", map[string]string{"v1.2.0": "tampered binary"})
+	server.checksum = strings.Repeat("ab", 32)
+	exe := useTestExecutable(t)
+	version = "v1.0.0"
+
+	_, err := runUpdate(t)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("update error = %v, want a checksum mismatch", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old binary" {
+		t.Errorf("binary = %q, want it untouched", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(exe)); len(entries) != 1 {
+		t.Errorf("files left next to the binary: %v", entries)
+	}
+}
+
+// TestUpdateReadOnlyDir tests refusing to update a binary in a directory
+// the user can't write to
+func TestUpdateReadOnlyDir(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("needs a directory the user can't write to")
+	}
+	newReleaseServer(t, "v1.2.0", map[string]string{"v1.2.0": "new binary"})
+	exe := useTestExecutable(t)
+	version = "v1.0.0"
+
+	dir := filepath.Dir(exe)
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	if _, err := runUpdate(t); err == nil || !strings.Contains(err.Error(), "sudo chatgpt-cli update") {
+		t.Errorf("update error = %v, want the sudo hint", err)
+	}
+}
+
+// TestUpdatePermissionError tests the hint given for each OS
+func TestUpdatePermissionError(t *testing.T) {
+	err := updatePermissionError("/usr/local/bin/chatgpt-cli", "linux", os.ErrPermission)
+	if !strings.Contains(err.Error(), "chatgpt-cli is in /usr/local/bin, which you can't write to; run 'sudo chatgpt-cli update'") {
+		t.Errorf("linux error = %v", err)
+	}
+	err = updatePermissionError(`C:\Program Files\chatgpt-cli.exe`, "windows", os.ErrPermission)
+	if !strings.Contains(err.Error(), "as administrator") {
+		t.Errorf("windows error = %v", err)
+	}
+}
+
+// TestReplaceExecutableWindows tests moving the running binary aside first,
+// as Windows requires
+func TestReplaceExecutableWindows(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "chatgpt-cli.exe")
+	if err := os.WriteFile(exe, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(exe+".old", []byte("older"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceExecutable(exe, []byte("new"), "windows"); err != nil {
+		t.Fatalf("replaceExecutable() error = %v", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "new" {
+		t.Errorf("binary = %q, want new", data)
+	}
+	if data, _ := os.ReadFile(exe + ".old"); string(data) != "old" {
+		t.Errorf("old binary = %q, want the replaced one kept aside", data)
+	}
+}
+
+// TestCompareVersions tests ordering release tags
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.0", "v1.1.9", 1},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v1.2.0", "1.2.0", 0},
+		{"v1.2", "v1.2.0", 0},
+		{"v1.2.0-rc.1", "v1.2.0", 0},
+		{"v1.0.0", "v2.0.0", -1},
+		{"v1.0.0", "dev", 1},
+		{"dev", "v0.0.1", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestParseChecksum tests reading a sha256sum file
+func TestParseChecksum(t *testing.T) {
+	sum := strings.Repeat("a1", 32)
+	sums := []byte(strings.Repeat("0", 64) + "  chatgpt-cli-linux-arm64\n" + strings.ToUpper(sum) + " *chatgpt-cli-linux-amd64\n")
+
+	if got, err := parseChecksum(sums, "chatgpt-cli-linux-amd64"); err != nil || got != sum {
+		t.Errorf("parseChecksum() = %q, %v; want %q", got, err, sum)
+	}
+	if _, err := parseChecksum(sums, "chatgpt-cli-darwin-arm64"); err == nil {
+		t.Error("parseChecksum() of a missing name succeeded")
+	}
+	if _, err := parseChecksum([]byte("xyz  chatgpt-cli-linux-amd64\n"), "chatgpt-cli-linux-amd64"); err == nil {
+		t.Error("parseChecksum() of an invalid checksum succeeded")
+	}
+	if got := releaseAssetName("windows", "arm64"); got != "chatgpt-cli-windows-arm64.exe" {
+		t.Errorf("releaseAssetName(windows, arm64) = %q", got)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// modelPrice is the cost of a model in USD per million tokens
+type modelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// Built-in price table used to estimate the cost of a request. Dated model
+// snapshots (e.g. gpt-4o-2024-08-06) are priced as their base model.
+var modelPrices = map[string]modelPrice{
+	"gpt-3.5-turbo": {Input: 0.50, Output: 1.50},
+	"gpt-4":         {Input: 30.00, Output: 60.00},
+	"gpt-4-32k":     {Input: 60.00, Output: 120.00},
+	"gpt-4-turbo":   {Input: 10.00, Output: 30.00},
+	"gpt-4o":        {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.60},
+
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-opus":     {Input: 15.00, Output: 75.00},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+	"claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+}
+
+// add accumulates another usage into u
+func (u *Usage) add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// usageOrNil returns a pointer to usage, or nil if no tokens were reported
+func usageOrNil(usage Usage) *Usage {
+	if usage.TotalTokens == 0 && usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		return nil
+	}
+	return &usage
+}
+
+// responseModel returns the model that served a response, falling back to the
+// configured model when the API doesn't report one
+func responseModel(config *Config, response *ChatResponse) string {
+	if response.Model != "" {
+		return response.Model
+	}
+	return config.Model
+}
+
+// lookupModelPrice finds the price for a model by the longest matching name,
+// where a match is either exact or followed by a "-" suffix
+func lookupModelPrice(model string) (modelPrice, bool) {
+	var best string
+	for name := range modelPrices {
+		if model != name && !strings.HasPrefix(model, name+"-") {
+			continue
+		}
+		if len(name) > len(best) {
+			best = name
+		}
+	}
+
+	if best == "" {
+		return modelPrice{}, false
+	}
+	return modelPrices[best], true
+}
+
+// estimateCost returns the estimated cost in USD of a request, and false if
+// the model is not in the price table
+func estimateCost(model string, usage Usage) (float64, bool) {
+	price, ok := lookupModelPrice(model)
+	if !ok {
+		return 0, false
+	}
+
+	cost := float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output
+	return cost / 1_000_000, true
+}
+
+// formatCost formats an estimated cost for display
+func formatCost(model string, usage Usage) string {
+	cost, ok := estimateCost(model, usage)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("$%.6f", cost)
+}
+
+// formatTiming formats how long a request took and the tokens it used, such
+// as "(2.3s, 512 tokens)". Replies without usage show the time alone.
+func formatTiming(elapsed time.Duration, usage Usage) string {
+	seconds := fmt.Sprintf("%.1fs", elapsed.Seconds())
+	if usage.TotalTokens == 0 {
+		return "(" + seconds + ")"
+	}
+	return fmt.Sprintf("(%s, %d tokens)", seconds, usage.TotalTokens)
+}
+
+// formatUsage formats a one-line token usage summary
+func formatUsage(model string, usage Usage) string {
+	return fmt.Sprintf("Tokens: %d prompt + %d completion = %d total (estimated cost: %s)",
+		usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, formatCost(model, usage))
+}
+
+// formatResponseUsage formats the --usage line of a prompt's response, with
+// the fingerprint of the backend that answered when the API sent one
+func formatResponseUsage(config *Config, response *ChatResponse) string {
+	usage := formatUsage(responseModel(config, response), response.Usage)
+	if len(response.Choices) > 1 {
+		usage += fmt.Sprintf(", all %d choices", len(response.Choices))
+	}
+	if response.SystemFingerprint != "" {
+		usage += ", fingerprint " + response.SystemFingerprint
+	}
+	return usage
+}
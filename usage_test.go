@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLookupModelPrice tests model name matching against the price table
+func TestLookupModelPrice(t *testing.T) {
+	tests := []struct {
+		name          string
+		model         string
+		expectedFound bool
+		expectedInput float64
+	}{
+		{"exact gpt-3.5-turbo", "gpt-3.5-turbo", true, 0.50},
+		{"exact gpt-4", "gpt-4", true, 30.00},
+		{"exact gpt-4o", "gpt-4o", true, 2.50},
+		{"dated snapshot", "gpt-4o-2024-08-06", true, 2.50},
+		{"longest prefix wins", "gpt-4o-mini-2024-07-18", true, 0.15},
+		{"gpt-4 snapshot", "gpt-4-0613", true, 30.00},
+		{"unlisted model", "llama3", false, 0},
+		{"prefix without separator", "gpt-4.5-preview", false, 0},
+		{"empty model", "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, found := lookupModelPrice(tt.model)
+			if found != tt.expectedFound {
+				t.Fatalf("lookupModelPrice(%q) found = %t, want %t", tt.model, found, tt.expectedFound)
+			}
+			if price.Input != tt.expectedInput {
+				t.Errorf("lookupModelPrice(%q) input = %f, want %f", tt.model, price.Input, tt.expectedInput)
+			}
+		})
+	}
+}
+
+// TestFormatUsage tests the usage summary line
+func TestFormatUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		usage    Usage
+		expected string
+	}{
+		{
+			name:     "known model",
+			model:    "gpt-4",
+			usage:    Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500},
+			expected: "Tokens: 1000 prompt + 500 completion = 1500 total (estimated cost: $0.060000)",
+		},
+		{
+			name:     "unknown model",
+			model:    "my-custom-model",
+			usage:    Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30},
+			expected: "Tokens: 10 prompt + 20 completion = 30 total (estimated cost: unknown)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := formatUsage(tt.model, tt.usage)
+			if result != tt.expected {
+				t.Errorf("formatUsage() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestFormatTiming tests the --timing line with and without usage
+func TestFormatTiming(t *testing.T) {
+	if got := formatTiming(2345*time.Millisecond, Usage{TotalTokens: 512}); got != "(2.3s, 512 tokens)" {
+		t.Errorf("formatTiming() = %q, want (2.3s, 512 tokens)", got)
+	}
+	if got := formatTiming(80*time.Millisecond, Usage{}); got != "(0.1s)" {
+		t.Errorf("formatTiming() without usage = %q, want (0.1s)", got)
+	}
+}
+
+// TestUsageOrNil tests that empty usage is not recorded
+func TestUsageOrNil(t *testing.T) {
+	if usageOrNil(Usage{}) != nil {
+		t.Errorf("usageOrNil(Usage{}) should be nil")
+	}
+
+	usage := usageOrNil(Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3})
+	if usage == nil || usage.TotalTokens != 3 {
+		t.Errorf("usageOrNil() = %v, want TotalTokens 3", usage)
+	}
+}
+
+// TestUsageAdd tests accumulating usage
+func TestUsageAdd(t *testing.T) {
+	var total Usage
+	total.add(Usage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3})
+	total.add(Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30})
+
+	if total.PromptTokens != 11 || total.CompletionTokens != 22 || total.TotalTokens != 33 {
+		t.Errorf("total = %+v, want {11 22 33}", total)
+	}
+}
+
+// TestPromptCommandUsage tests that usage is shown and recorded in the log
+func TestPromptCommandUsage(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ChatResponse{
+			Model:   "gpt-4o-2024-08-06",
+			Choices: []Choice{{Message: Message{Content: "Test response"}}},
+			Usage:   Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30},
+		})
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{
+		APIKey:    "test-key",
+		APIURL:    server.URL,
+		Model:     "gpt-4o",
+		Timeout:   10 * time.Second,
+		ConfigDir: tmpDir,
+	}
+
+	if err := promptCommand(config, []string{"--usage", "test prompt"}); err != nil {
+		t.Fatalf("promptCommand() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "logs.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(data))), &entry); err != nil {
+		t.Fatalf("failed to parse log entry: %v", err)
+	}
+
+	if entry.Usage == nil {
+		t.Fatal("entry.Usage should not be nil")
+	}
+	if entry.Usage.TotalTokens != 30 {
+		t.Errorf("entry.Usage.TotalTokens = %d, want 30", entry.Usage.TotalTokens)
+	}
+
+	// The logs command shows the recorded usage
+	if err := logsCommand(config, []string{}); err != nil {
+		t.Errorf("logsCommand() error = %v", err)
+	}
+
+	// --timing prints the duration and tokens on stderr
+	stderr := captureOutput(t, &os.Stderr, func() {
+		if err := promptCommand(config, []string{"--timing", "test prompt"}); err != nil {
+			t.Errorf("promptCommand() --timing error = %v", err)
+		}
+	})
+	if !strings.HasPrefix(stderr, "(") || !strings.HasSuffix(stderr, "s, 30 tokens)\n") {
+		t.Errorf("--timing stderr = %q, want (N.Ns, 30 tokens)", stderr)
+	}
+}
+
+// TestLogsCommandDuration tests that logs shows recorded durations and still
+// renders entries logged without one
+func TestLogsCommandDuration(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestLogs(t, filepath.Join(tmpDir, "logs.jsonl"), []LogEntry{
+		{Timestamp: time.Now(), Command: "prompt", Prompt: "old", Response: "no duration"},
+		{Timestamp: time.Now(), Command: "prompt", Prompt: "new", Response: "timed", LatencyMs: 2345},
+	})
+
+	out := captureOutput(t, &os.Stdout, func() {
+		if err := logsCommand(&Config{ConfigDir: tmpDir}, []string{}); err != nil {
+			t.Errorf("logsCommand() error = %v", err)
+		}
+	})
+	if strings.Count(out, "Duration: ") != 1 || !strings.Contains(out, "Duration: 2.35s") {
+		t.Errorf("logs output = %q, want one Duration: 2.35s line", out)
+	}
+	if !strings.Contains(out, "Response: no duration") {
+		t.Errorf("logs output = %q, want the entry without a duration", out)
+	}
+}
+
+// TestSendChatRequestStreamUsage tests usage reported in the final stream chunk
+func TestSendChatRequestStreamUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if request.StreamOptions == nil || !request.StreamOptions.IncludeUsage {
+			t.Errorf("stream_options.include_usage not requested")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n"))
+		_, _ = w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":1,\"total_tokens\":6}}\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		APIKey:  "test-key",
+		APIURL:  server.URL,
+		Model:   "gpt-4o",
+		Timeout: 10 * time.Second,
+	}
+
+	var out strings.Builder
+	response, err := newAPIClient(config).ChatStream(context.Background(), promptMessages(config, "test prompt"), &out)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	if response.Usage.TotalTokens != 6 {
+		t.Errorf("Usage.TotalTokens = %d, want 6", response.Usage.TotalTokens)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Follow-up requests prompt --verify sends by default to fix code that fails
+const defaultVerifyRetries = 1
+
+// verifiers check the code of a reply for prompt --verify, by language. A
+// verifier returns the problems found, or errToolchainMissing when the tool
+// it runs is not installed.
+var verifiers = map[string]func(config *Config, code string) (string, error){
+	"go":     verifyGo,
+	"python": verifyPython,
+	"json":   verifyJSON,
+}
+
+// errToolchainMissing is returned by a verifier whose tool is not installed
+var errToolchainMissing = errors.New("toolchain not found")
+
+// verifyLanguages returns the values --verify takes
+func verifyLanguages() []string {
+	languages := make([]string, 0, len(verifiers)+1)
+	for language := range verifiers {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return append(languages, "none")
+}
+
+// checkVerifyFlags checks the values of --verify and --verify-retries and
+// returns the language to verify, "" for none
+func checkVerifyFlags(language string, retries int, retriesSet bool) (string, error) {
+	language = strings.ToLower(language)
+	if language == "" || language == "none" {
+		if retriesSet {
+			return "", usageErrorf("--verify-retries needs --verify")
+		}
+		return "", nil
+	}
+	if _, ok := verifiers[language]; !ok {
+		return "", usageErrorf("invalid --verify %q: use %s", language, strings.Join(verifyLanguages(), ", "))
+	}
+	if retries < 0 {
+		return "", usageErrorf("--verify-retries cannot be negative")
+	}
+	return language, nil
+}
+
+// verifyResult is the outcome of prompt --verify
+type verifyResult struct {
+	// passed is set when the last reply's code passed, skipped when it could
+	// not be checked for lack of a toolchain
+	passed  bool
+	skipped bool
+	// retries are the follow-up requests sent, and problems what the last
+	// check found
+	retries  int
+	problems string
+}
+
+// verifyReply checks the first code block of the reply to messages and,
+// while it fails and retries remain, sends the problems back asking for a
+// fix. It returns the last response, holding the usage of all requests.
+func verifyReply(config *Config, client *APIClient, messages []Message, response *ChatResponse, language string, retries int) (*ChatResponse, verifyResult, error) {
+	var result verifyResult
+	usage := response.Usage
+	for {
+		reply := formatChoices(response, 1)
+		problems, err := verifyCode(config, language, reply)
+		if errors.Is(err, errToolchainMissing) {
+			fmt.Fprintf(os.Stderr, "Warning: %v; the %s code was not verified\n", err, language)
+			result.skipped = true
+			break
+		}
+		if err != nil {
+			return response, result, err
+		}
+		result.problems = problems
+		if problems == "" {
+			result.passed = true
+			if result.retries > 0 {
+				fmt.Fprintf(os.Stderr, "The fixed code passes %s verification after %d %s\n", language, result.retries, plural(result.retries, "retry", "retries"))
+			}
+			break
+		}
+		if result.retries == retries {
+			break
+		}
+
+		result.retries++
+		fmt.Fprintf(os.Stderr, "The code fails %s verification; asking for a fix (%d/%d)\n", language, result.retries, retries)
+		messages = append(messages,
+			Message{Role: "assistant", Content: reply},
+			Message{Role: "user", Content: verifyFollowUp(language, problems)})
+		stopProgress := startProgress(config)
+		next, err := client.Chat(config.requestContext(), messages)
+		stopProgress()
+		if err != nil {
+			return response, result, err
+		}
+		response = next
+		usage.add(response.Usage)
+	}
+	response.Usage = usage
+	return response, result, nil
+}
+
+// verifyFollowUp is the message asking the model to fix code that failed
+func verifyFollowUp(language, problems string) string {
+	return fmt.Sprintf("Your %s code fails to %s:\n\n```\n%s\n```\n\nFix it and reply with the whole corrected code in a single code block.",
+		language, verifyAction(language), problems)
+}
+
+// verifyAction describes what the verification of a language checks
+func verifyAction(language string) string {
+	if language == "json" {
+		return "parse"
+	}
+	return "compile"
+}
+
+// verifyCode checks the first code block of reply, or the whole reply when
+// it has none and the language is JSON. It returns the problems found, ""
+// if there are none.
+func verifyCode(config *Config, language, reply string) (string, error) {
+	blocks := findCodeBlocks(reply)
+	var code string
+	switch {
+	case len(blocks) > 0:
+		code = blocks[0].content
+	case language == "json":
+		code = strings.TrimSpace(reply)
+	default:
+		return "no code block in the response", nil
+	}
+	return verifiers[language](config, code)
+}
+
+// verifyGo parses Go code, adding a package clause to a snippet without one
+// on its first line, so that line numbers match the code
+func verifyGo(config *Config, code string) (string, error) {
+	if _, err := parser.ParseFile(token.NewFileSet(), "reply.go", code, parser.PackageClauseOnly); err != nil {
+		code = "package main; " + code
+	}
+	_, err := parser.ParseFile(token.NewFileSet(), "reply.go", code, parser.AllErrors)
+	var list scanner.ErrorList
+	if errors.As(err, &list) {
+		lines := make([]string, len(list))
+		for i, e := range list {
+			lines[i] = e.Error()
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	if err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// verifyPython compiles Python code with python3 -m py_compile
+func verifyPython(config *Config, code string) (string, error) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		return "", fmt.Errorf("python3 not found on PATH: %w", errToolchainMissing)
+	}
+	dir, err := os.MkdirTemp("", "chatgpt-cli-verify-")
+	if err != nil {
+		return "", fmt.Errorf("failed to verify python code: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reply.py")
+	if err := os.WriteFile(path, []byte(code), 0600); err != nil {
+		return "", fmt.Errorf("failed to verify python code: %w", err)
+	}
+
+	output, err := exec.CommandContext(config.requestContext(), python, "-m", "py_compile", path).CombinedOutput()
+	if config.requestContext().Err() != nil {
+		return "", errCancelled
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return strings.TrimSpace(strings.ReplaceAll(string(output), path, "reply.py")), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to run python3: %w", err)
+	}
+	return "", nil
+}
+
+// verifyJSON parses JSON, reporting where the first error is
+func verifyJSON(config *Config, code string) (string, error) {
+	var value interface{}
+	err := json.Unmarshal([]byte(code), &value)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Sprintf("%v at offset %d", syntaxErr, syntaxErr.Offset), nil
+	}
+	if err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
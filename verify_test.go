@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestVerifyCode tests the checks of each language on the first code block
+func TestVerifyCode(t *testing.T) {
+	config := &Config{}
+	tests := []struct {
+		name     string
+		language string
+		reply    string
+		// problems holds part of the problems expected, "" for none
+		problems string
+	}{
+		{"go file", "go", "Here:\n```go\npackage main\n\nfunc main() {}\n```\n", ""},
+		{"go snippet", "go", "```go\nfunc add(a, b int) int {\n\treturn a + b\n}\n```", ""},
+		{"go error", "go", "```go\nfunc add(a, b int) int {\n\treturn a +\n}\n```", "reply.go:3:1: expected operand"},
+		{"first block only", "go", "```go\nfunc f() {}\n```\n\n```go\nfunc (\n```", ""},
+		{"no code block", "go", "I can't help with that.", "no code block in the response"},
+		{"json block", "json", "```json\n{\"a\": [1, 2]}\n```", ""},
+		{"bare json", "json", "{\"a\": 1}", ""},
+		{"json error", "json", "```json\n{\"a\": 1,}\n```", "at offset 9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, err := verifyCode(config, tt.language, tt.reply)
+			if err != nil {
+				t.Fatalf("verifyCode() error = %v", err)
+			}
+			if tt.problems == "" && problems != "" || !strings.Contains(problems, tt.problems) {
+				t.Errorf("verifyCode() = %q, want %q", problems, tt.problems)
+			}
+		})
+	}
+}
+
+// TestVerifyPython tests py_compile, and the warning of a missing python3
+func TestVerifyPython(t *testing.T) {
+	config := &Config{}
+	if _, err := exec.LookPath("python3"); err == nil {
+		if problems, err := verifyCode(config, "python", "```python\ndef f():\n    return 1\n```"); err != nil || problems != "" {
+			t.Errorf("valid python = %q, %v", problems, err)
+		}
+		problems, err := verifyCode(config, "python", "```python\ndef f(:\n    return 1\n```")
+		if err != nil || !strings.Contains(problems, "reply.py") {
+			t.Errorf("invalid python = %q, %v", problems, err)
+		}
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := verifyCode(config, "python", "```python\npass\n```"); !errors.Is(err, errToolchainMissing) {
+		t.Errorf("missing python3 error = %v", err)
+	}
+}
+
+// TestPromptVerify tests that code failing --verify is sent back with its
+// problems until fixed or out of retries, and that the outcome is logged
+func TestPromptVerify(t *testing.T) {
+	broken := "```go\nfunc add(a, b int) int {\n\treturn a +\n}\n```"
+	fixed := "```go\nfunc add(a, b int) int {\n\treturn a + b\n}\n```"
+
+	tests := []struct {
+		name    string
+		args    []string
+		replies []string
+		// wantErr is set when the code still fails in the end
+		wantErr  bool
+		verified bool
+		retries  int
+	}{
+		{name: "passes", args: []string{"--verify", "go"}, replies: []string{fixed}, verified: true},
+		{name: "fixed", args: []string{"--verify", "go"}, replies: []string{broken, fixed}, verified: true, retries: 1},
+		{name: "still broken", args: []string{"--verify", "go", "--verify-retries", "2"}, replies: []string{broken, broken, broken}, wantErr: true, retries: 2},
+		{name: "no retries", args: []string{"--verify", "go", "--verify-retries", "0"}, replies: []string{broken}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanup := setupTestEnv(t)
+			defer cleanup()
+
+			var requests []ChatRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request ChatRequest
+				_ = json.NewDecoder(r.Body).Decode(&request)
+				requests = append(requests, request)
+				reply, _ := json.Marshal(tt.replies[len(requests)-1])
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":` + string(reply) + `}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+			}))
+			defer server.Close()
+
+			config := &Config{APIKey: "sk-test", APIURL: server.URL, Model: "gpt-4o", Timeout: 5 * time.Second, ConfigDir: t.TempDir()}
+			var err error
+			var stdout string
+			stderr := captureOutput(t, &os.Stderr, func() {
+				stdout = captureOutput(t, &os.Stdout, func() {
+					err = promptCommand(config, append(tt.args, "Write add in Go"))
+				})
+			})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("promptCommand() error = %v, wantErr %v\nstderr: %s", err, tt.wantErr, stderr)
+			}
+			if len(requests) != len(tt.replies) {
+				t.Fatalf("%d requests sent, want %d", len(requests), len(tt.replies))
+			}
+			if want := tt.replies[len(tt.replies)-1]; strings.TrimSpace(stdout) != want {
+				t.Errorf("stdout = %q, want the last reply %q", stdout, want)
+			}
+			if len(requests) > 1 {
+				follow := requests[1].Messages
+				if n := len(follow); n != 3 || follow[1].Content != broken || !strings.Contains(follow[2].Content, "expected operand") {
+					t.Errorf("follow-up messages = %+v", follow)
+				}
+			}
+
+			entries := readTestLogEntries(t, config.ConfigDir)
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			entry := entries[0]
+			if entry.Verify != "go" || entry.Verified == nil || *entry.Verified != tt.verified || entry.VerifyRetries != tt.retries {
+				t.Errorf("logged verify = %q, %v, %d retries", entry.Verify, entry.Verified, entry.VerifyRetries)
+			}
+			if want := 15 * len(tt.replies); entry.Usage == nil || entry.Usage.TotalTokens != want {
+				t.Errorf("logged usage = %+v, want %d tokens", entry.Usage, want)
+			}
+		})
+	}
+}
+
+// TestPromptVerifyFlags tests the values and combinations --verify refuses
+func TestPromptVerifyFlags(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	config := &Config{APIKey: "sk-test", Model: "gpt-4o", ConfigDir: t.TempDir()}
+	for _, args := range [][]string{
+		{"--verify", "rust", "hi"},
+		{"--verify-retries", "2", "hi"},
+		{"--verify", "go", "--verify-retries", "-1", "hi"},
+		{"--verify", "go", "--n", "2", "hi"},
+		{"--verify", "json", "--json-response", "hi"},
+		{"--verify", "go", "--count", "2", "hi"},
+	} {
+		if err := promptCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("promptCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+}
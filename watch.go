@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// How often prompt --watch checks the prompt file for changes by default
+const defaultWatchInterval = 500 * time.Millisecond
+
+// watchRun is a prompt file sent again each time it is saved, by prompt
+// --watch
+type watchRun struct {
+	path     string
+	interval time.Duration
+	// parts are the attachments, prefix and suffix the text of the file is
+	// sent with, and the text of an alias it goes after
+	parts promptParts
+	// entry is what each run is logged with, besides its prompt and reply
+	entry     LogEntry
+	output    io.Writer
+	render    bool
+	quiet     bool
+	showUsage bool
+	timing    bool
+	// moderate checks each run with the moderations endpoint, and force
+	// sends it even if flagged
+	moderate bool
+	force    bool
+}
+
+// watchPrompt sends the prompt file of run, then polls its modification time
+// and sends it again after each save, once the file has not changed for one
+// interval, so that the bursts of writes of an editor make one run. Saves
+// that leave the text as it was are not sent. A failed run is reported and
+// the file watched on. Ctrl-C stops watching, with a summary of the runs.
+func watchPrompt(config *Config, run watchRun) error {
+	info, err := os.Stat(run.path)
+	if err != nil {
+		return fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	modified, size := info.ModTime(), info.Size()
+
+	client := newAPIClient(config)
+	name := filepath.Base(run.path)
+	runs, tokens := 0, 0
+	var sent string
+
+	// send runs the text of the file unless it is what was last sent, and
+	// reports whether the user stopped it
+	send := func() bool {
+		data, err := os.ReadFile(run.path)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error: failed to read prompt file: %v\n", err)
+			return false
+		case runs > 0 && string(data) == sent:
+			fmt.Fprintf(os.Stderr, "%s saved without changes; not sent\n", name)
+			return false
+		case strings.TrimSpace(string(data)) == "" && len(run.parts.attachments) == 0 && config.Messages == nil:
+			fmt.Fprintf(os.Stderr, "%s is empty; not sent\n", name)
+			return false
+		}
+		sent = string(data)
+		runs++
+		used, err := sendWatchRun(config, client, run, runs, sent)
+		tokens += used
+		if isCancelled(err) {
+			return true
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return false
+	}
+
+	stopped := send()
+	if !stopped {
+		fmt.Fprintf(os.Stderr, "Watching %s for changes; press Ctrl-C to stop\n", name)
+	}
+
+	ticker := time.NewTicker(run.interval)
+	defer ticker.Stop()
+	pending := false
+	for !stopped {
+		select {
+		case <-config.requestContext().Done():
+			stopped = true
+			continue
+		case <-ticker.C:
+		}
+
+		// Editors may replace the file, leaving it missing for a moment
+		info, err := os.Stat(run.path)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(modified) || info.Size() != size {
+			modified, size = info.ModTime(), info.Size()
+			pending = true
+			continue
+		}
+		if pending {
+			pending = false
+			stopped = send()
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Stopped watching %s after %d %s, %d tokens\n", name, runs, plural(runs, "run", "runs"), tokens)
+	return nil
+}
+
+// sendWatchRun sends the text of the prompt file as run n, printing the reply
+// under a divider with the time, and logs it. It returns the tokens used.
+func sendWatchRun(config *Config, client *APIClient, run watchRun, n int, text string) (int, error) {
+	parts := run.parts
+	if parts.text != "" {
+		text = parts.text + "\n\n" + text
+	}
+	parts.text = text
+	prompt := parts.build()
+
+	entry := run.entry
+	entry.Prompt = wrapPrompt(parts.prefix, promptForLog(text, parts.attachments, config.LogFullPrompt), parts.suffix)
+
+	if !run.quiet && config.Output != outputJSON {
+		if n > 1 {
+			fmt.Fprintln(run.output)
+		}
+		fmt.Fprintf(run.output, "── Run %d, %s ──\n\n", n, time.Now().Format("15:04:05"))
+	}
+
+	if run.moderate {
+		moderated := prompt
+		if config.Messages != nil {
+			moderated = messagesText(promptMessages(config, prompt))
+		}
+		if err := moderatePrompt(config, moderated, run.force); err != nil {
+			entry.Timestamp, entry.Error = time.Now(), err.Error()
+			if isCancelled(err) {
+				entry.Error = cancelledLogMessage
+			}
+			warnLogError(config, writeLogEntry(config, entry))
+			return 0, err
+		}
+	}
+
+	stopProgress := startProgress(config)
+	start := time.Now()
+	response, err := client.Chat(config.requestContext(), promptMessages(config, prompt))
+	stopProgress()
+	if isCancelled(err) {
+		entry.Timestamp, entry.Error = time.Now(), cancelledLogMessage
+		warnLogError(config, writeLogEntry(config, entry))
+		return 0, errCancelled
+	}
+	if err != nil {
+		entry.Timestamp, entry.Error, entry.RequestID = time.Now(), err.Error(), errorRequestID(err)
+		warnLogError(config, writeLogEntry(config, entry))
+		return 0, fmt.Errorf("failed to get response: %w", err)
+	}
+	latency := time.Since(start)
+
+	reply := formatChoices(response, config.Choices)
+	entry.Timestamp = time.Now()
+	entry.Response = reply
+	entry.Usage = usageOrNil(response.Usage)
+	entry.Model = responseModel(config, response)
+	entry.LatencyMs = latency.Milliseconds()
+	entry.RequestID = response.RequestID
+	warnLogError(config, writeLogEntry(config, entry))
+
+	switch {
+	case run.quiet:
+	case config.Output == outputJSON:
+		if err := writeJSON(run.output, newPromptOutput(response, reply)); err != nil {
+			return response.Usage.TotalTokens, err
+		}
+	case run.render:
+		fmt.Fprintln(run.output, renderMarkdown(reply, colorEnabled(config)))
+	default:
+		fmt.Fprintln(run.output, reply)
+	}
+	if run.showUsage && config.Output != outputJSON {
+		fmt.Fprintln(os.Stderr, formatResponseUsage(config, response))
+	}
+	if run.timing && config.Output != outputJSON {
+		fmt.Fprintln(os.Stderr, formatTiming(latency, response.Usage))
+	}
+	return response.Usage.TotalTokens, nil
+}
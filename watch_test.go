@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPromptWatch tests that a watched prompt file is sent at start and after
+// each save that changes it, once per burst of saves, until cancelled
+func TestPromptWatch(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		mu.Lock()
+		prompts = append(prompts, request.Messages[len(request.Messages)-1].Content)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"reply"}}],"usage":{"prompt_tokens":4,"completion_tokens":6,"total_tokens":10}}`))
+	}))
+	defer server.Close()
+
+	sent := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), prompts...)
+	}
+	// waitFor waits until n prompts were sent
+	waitFor := func(n int) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for len(sent()) < n {
+			if time.Now().After(deadline) {
+				return false
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return true
+	}
+
+	path := filepath.Join(t.TempDir(), "prompt.txt")
+	modified := time.Now().Add(-time.Hour)
+	// save writes the file with a modification time of its own, as the
+	// clock may not tick between writes
+	save := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Error(err)
+		}
+		modified = modified.Add(time.Second)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Error(err)
+		}
+	}
+	save("first version")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	config := &Config{
+		APIKey:    "sk-test",
+		APIURL:    server.URL,
+		Model:     "gpt-4o",
+		Timeout:   5 * time.Second,
+		ConfigDir: t.TempDir(),
+		ctx:       ctx,
+	}
+
+	go func() {
+		defer cancel()
+		if !waitFor(1) {
+			t.Error("the file was not sent at start")
+			return
+		}
+		save("second version")
+		if !waitFor(2) {
+			t.Error("the changed file was not sent again")
+			return
+		}
+		// A save without changes is not sent
+		save("second version")
+		time.Sleep(200 * time.Millisecond)
+		// Saves in a burst are sent once, as the last of them
+		save("third")
+		save("third version")
+		if !waitFor(3) {
+			t.Error("the burst of saves was not sent")
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}()
+
+	var err error
+	var stdout string
+	stderr := captureOutput(t, &os.Stderr, func() {
+		stdout = captureOutput(t, &os.Stdout, func() {
+			err = promptCommand(config, []string{"--watch", path, "--watch-interval", "20ms"})
+		})
+	})
+	if err != nil {
+		t.Fatalf("promptCommand(--watch) error = %v", err)
+	}
+
+	if got, want := strings.Join(sent(), ", "), "first version, second version, third version"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	for _, want := range []string{"── Run 1, ", "── Run 3, ", "reply"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("stdout = %q, want it to contain %q", stdout, want)
+		}
+	}
+	for _, want := range []string{"prompt.txt saved without changes; not sent", "Stopped watching prompt.txt after 3 runs, 30 tokens"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr = %q, want it to contain %q", stderr, want)
+		}
+	}
+	if entries := readTestLogEntries(t, config.ConfigDir); len(entries) != 3 || entries[2].Prompt != "third version" {
+		t.Errorf("logged %d entries: %+v", len(entries), entries)
+	}
+}
+
+// TestPromptWatchFlags tests the flags --watch cannot be combined with
+func TestPromptWatchFlags(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	config := &Config{APIKey: "sk-test", Model: "gpt-4o", ConfigDir: t.TempDir()}
+	path := filepath.Join(t.TempDir(), "prompt.txt")
+	for _, args := range [][]string{
+		{"--watch", path, "hello"},
+		{"--watch", path, "--stdin"},
+		{"--watch", path, "--count", "3"},
+		{"--watch", path, "--exec"},
+		{"--watch", path, "--watch-interval", "0s"},
+	} {
+		if err := promptCommand(config, args); !errors.Is(err, ErrUsage) {
+			t.Errorf("promptCommand(%q) error = %v, want a usage error", args, err)
+		}
+	}
+
+	if err := promptCommand(config, []string{"--watch", path}); err == nil || !strings.Contains(err.Error(), "failed to read prompt file") {
+		t.Errorf("missing file error = %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redirects followed when fetching a --url page
+const maxURLRedirects = 5
+
+// Largest page read for --url, before it is reduced to text
+const maxURLBodySize = 5 << 20
+
+// Marker ending a page cut at CHATGPT_CLI_URL_MAX_CHARS
+const urlTruncatedMarker = "[truncated]"
+
+// urlListFlag is a repeatable flag collecting http and https URLs
+type urlListFlag []string
+
+func (f *urlListFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *urlListFlag) Set(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: only http and https URLs can be fetched", value)
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// fetchURLAttachments fetches each page as an attachment named by its URL.
+// A page that can't be used is skipped with a warning, so the prompt is
+// still sent with the others.
+func fetchURLAttachments(config *Config, urls []string) []attachment {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	client := newHTTPClient(config)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxURLRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxURLRedirects)
+		}
+		return nil
+	}
+
+	errOut := newUI(config, os.Stderr)
+	var attachments []attachment
+	for _, pageURL := range urls {
+		text, err := fetchPageText(config, client, pageURL)
+		if err != nil {
+			errOut.Printf("%s skipping %s: %v\n", errOut.yellow("Warning:"), pageURL, err)
+			continue
+		}
+		attachments = append(attachments, attachment{Name: pageURL, Content: truncateRunes(text, config.URLMaxChars)})
+	}
+	return attachments
+}
+
+// fetchPageText fetches an HTML page and returns its readable text
+func fetchPageText(config *Config, client *http.Client, pageURL string) (string, error) {
+	req, err := http.NewRequestWithContext(config.requestContext(), "GET", pageURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		if mediaType == "" {
+			mediaType = "no content type"
+		}
+		return "", fmt.Errorf("not an HTML page (%s)", mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxURLBodySize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read page: %w", err)
+	}
+	text := htmlToText(string(body))
+	if text == "" {
+		return "", fmt.Errorf("the page has no text")
+	}
+	return text, nil
+}
+
+// Elements whose content is never readable text. The head goes last, so
+// the scripts and styles inside it are already gone.
+var htmlHiddenElements = func() []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, name := range []string{"script", "style", "noscript", "template", "svg", "head"} {
+		res = append(res, regexp.MustCompile(`(?is)<`+name+`\b.*?</`+name+`\s*>`))
+	}
+	return res
+}()
+
+var (
+	htmlComments = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTitle    = regexp.MustCompile(`(?is)<title\b[^>]*>(.*?)</title\s*>`)
+	// Tags that start a new line of text
+	htmlBlockTags = regexp.MustCompile(`(?i)</?(?:p|div|br|hr|h[1-6]|li|ul|ol|tr|table|section|article|header|footer|nav|main|aside|blockquote|pre|title|dt|dd|figcaption)\b[^>]*>`)
+	htmlTags      = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlSpaces    = regexp.MustCompile(`[ \t\r\f\v\x{00a0}]+`)
+)
+
+// htmlToText reduces an HTML page to its text: scripts, styles and the head
+// are dropped, block elements become line breaks, other tags are removed and
+// entities decoded. Runs of spaces and blank lines are collapsed.
+func htmlToText(page string) string {
+	page = htmlComments.ReplaceAllString(page, "")
+	// The title is the only part of the head worth keeping
+	title := ""
+	if m := htmlTitle.FindStringSubmatch(page); m != nil {
+		title = m[1]
+	}
+	for _, re := range htmlHiddenElements {
+		page = re.ReplaceAllString(page, "\n")
+	}
+	page = title + "\n" + htmlBlockTags.ReplaceAllString(page, "\n")
+	page = html.UnescapeString(htmlTags.ReplaceAllString(page, ""))
+
+	var lines []string
+	for _, line := range strings.Split(page, "\n") {
+		line = strings.TrimSpace(htmlSpaces.ReplaceAllString(line, " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateRunes cuts text to max characters, ending it with the truncated
+// marker. A max of zero means no limit.
+func truncateRunes(text string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return strings.TrimSpace(string([]rune(text)[:max])) + "\n" + urlTruncatedMarker
+}
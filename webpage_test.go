@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHTMLToText tests reducing pages to their readable text
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{
+			name: "article",
+			page: `<!DOCTYPE html><html><head><title>Go &amp; You</title><style>p { color: red }</style>
+<script>var x = "<p>not text</p>";</script></head>
+<body><nav><a href="/">Home</a></nav><!-- comment -->
+<h1>Intro</h1><p>First   paragraph,
+ with <b>bold</b> text.</p><p>Second&nbsp;one &lt;here&gt;</p>
+<ul><li>one</li><li>two</li></ul><br>End</body></html>`,
+			want: "Go & You\nHome\nIntro\nFirst paragraph,\nwith bold text.\nSecond one <here>\none\ntwo\nEnd",
+		},
+		{
+			name: "no head",
+			page: "<div>Just <i>this</i></div><noscript>Enable JS</noscript>",
+			want: "Just this",
+		},
+		{
+			name: "header is not head",
+			page: "<header>Site</header><main>Body</main>",
+			want: "Site\nBody",
+		},
+		{
+			name: "only scripts",
+			page: "<script>alert(1)</script>",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlToText(tt.page); got != tt.want {
+				t.Errorf("htmlToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTruncateRunes tests cutting page text at a number of characters
+func TestTruncateRunes(t *testing.T) {
+	if got := truncateRunes("àèìòù", 5); got != "àèìòù" {
+		t.Errorf("truncateRunes(5 runes, 5) = %q", got)
+	}
+	if got := truncateRunes("àèì òù", 4); got != "àèì\n[truncated]" {
+		t.Errorf("truncateRunes(6 runes, 4) = %q", got)
+	}
+	if got := truncateRunes("abc", 0); got != "abc" {
+		t.Errorf("truncateRunes(abc, 0) = %q, want no limit", got)
+	}
+}
+
+// TestURLListFlag tests that only http and https URLs are accepted
+func TestURLListFlag(t *testing.T) {
+	var urls urlListFlag
+	for _, value := range []string{"https://example.com/a", "http://localhost:8080/"} {
+		if err := urls.Set(value); err != nil {
+			t.Errorf("Set(%q) error = %v", value, err)
+		}
+	}
+	for _, value := range []string{"", "example.com", "file:///etc/passwd", "ftp://example.com/"} {
+		if err := urls.Set(value); err == nil {
+			t.Errorf("Set(%q) = nil, want an error", value)
+		}
+	}
+	if len(urls) != 2 {
+		t.Errorf("urls = %v", urls)
+	}
+}
+
+// TestPromptCommandURL tests sending fetched pages with the prompt, skipping
+// those that can't be used, and logging only their URLs
+func TestPromptCommandURL(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	pages := http.NewServeMux()
+	pages.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><head><title>Release notes</title></head><body><p>Version 2 adds generics.</p></body></html>")
+	})
+	pages.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusFound)
+	})
+	pages.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	pages.HandleFunc("/data.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"a": 1}`)
+	})
+	pageServer := httptest.NewServer(pages)
+	defer pageServer.Close()
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err == nil && len(request.Messages) > 0 {
+			received = request.Messages[len(request.Messages)-1].Content
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Summary"}}]}`)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	config := &Config{APIKey: "test-key", APIURL: server.URL, Model: "gpt-4o", Timeout: 10 * time.Second, ConfigDir: tmpDir, URLMaxChars: defaultURLMaxChars, NoColor: true}
+
+	article := pageServer.URL + "/article"
+	args := []string{"--no-stream", "--url", article, "--url", pageServer.URL + "/missing", "--url", pageServer.URL + "/data.json", "--url", pageServer.URL + "/loop", "--url", pageServer.URL + "/moved", "Summarize"}
+	var stderr string
+	captureOutput(t, &os.Stdout, func() {
+		stderr = captureOutput(t, &os.Stderr, func() {
+			if err := promptCommand(config, args); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+
+	if !strings.HasPrefix(received, "Summarize\n\n``` "+article+"\nRelease notes\nVersion 2 adds generics.\n```") {
+		t.Errorf("sent prompt = %q, want the text followed by the page labeled with its URL", received)
+	}
+	if !strings.Contains(received, "``` "+pageServer.URL+"/moved\nRelease notes") {
+		t.Errorf("sent prompt = %q, want the redirected page", received)
+	}
+	for _, want := range []string{"/missing: HTTP 404 Not Found", "/data.json: not an HTML page (application/json)", "/loop: request failed", "stopped after 5 redirects"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr = %q, want a warning with %q", stderr, want)
+		}
+	}
+
+	entries := readTestLogEntries(t, tmpDir)
+	if len(entries) != 1 {
+		t.Fatalf("log entries = %+v, want 1", entries)
+	}
+	if strings.Contains(entries[0].Prompt, "generics") || !strings.Contains(entries[0].Prompt, "[attached: "+article+", "+pageServer.URL+"/moved]") {
+		t.Errorf("logged prompt = %q, want only the URLs", entries[0].Prompt)
+	}
+
+	// Full prompt logging keeps the text, cut at the configured length
+	config.LogFullPrompt = true
+	config.URLMaxChars = 8
+	captureOutput(t, &os.Stdout, func() {
+		captureOutput(t, &os.Stderr, func() {
+			if err := promptCommand(config, []string{"--no-stream", "--url", article, "Summarize"}); err != nil {
+				t.Errorf("promptCommand() error = %v", err)
+			}
+		})
+	})
+	if !strings.Contains(received, "Release\n[truncated]\n```") {
+		t.Errorf("sent prompt = %q, want the page truncated", received)
+	}
+	if entries := readTestLogEntries(t, tmpDir); len(entries) != 2 || !strings.Contains(entries[1].Prompt, "Release\n[truncated]") {
+		t.Errorf("log entries = %+v, want the page text logged", entries)
+	}
+
+	// Nothing is sent when every page fails and there is no text
+	received = ""
+	captureOutput(t, &os.Stderr, func() {
+		if err := promptCommand(config, []string{"--no-stream", "--url", pageServer.URL + "/missing"}); err == nil || !strings.Contains(err.Error(), "no --url page could be fetched") {
+			t.Errorf("promptCommand() error = %v, want nothing to send", err)
+		}
+	})
+	if received != "" {
+		t.Errorf("sent prompt = %q, want nothing sent", received)
+	}
+
+	if err := promptCommand(config, []string{"--url", "file:///etc/passwd", "Summarize"}); !errors.Is(err, ErrUsage) {
+		t.Errorf("promptCommand(--url file://) error = %v, want a usage error", err)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
+
+// How a workflow step starts its conversation
+const (
+	// A new conversation with only the step's prompt
+	workflowContextFresh = "fresh"
+	// The conversation of the previous step, followed by the step's prompt
+	workflowContextInherit = "inherit"
+)
+
+// Statuses of a workflow step
+const (
+	stepStatusOK      = "ok"
+	stepStatusFailed  = "failed"
+	stepStatusSkipped = "skipped"
+)
+
+// Step names are used in templates as {{.steps.<name>.output}}, so they must
+// be valid template field names
+var stepNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Workflow is a file of prompts run one after another by the run command
+type Workflow struct {
+	Steps []WorkflowStep `json:"steps"`
+	// Default of the steps' continue_on_error
+	ContinueOnError bool `json:"continue_on_error,omitempty"`
+}
+
+// WorkflowStep is one prompt of a workflow
+type WorkflowStep struct {
+	Name string `json:"name"`
+	// Template of the prompt, which can use the outputs of earlier steps as
+	// {{.steps.<name>.output}}
+	Prompt      string   `json:"prompt"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	// File the step's output is written to
+	Save string `json:"save,omitempty"`
+	// fresh (the default) or inherit
+	Context         string `json:"context,omitempty"`
+	ContinueOnError *bool  `json:"continue_on_error,omitempty"`
+
+	template *template.Template
+}
+
+// WorkflowStepResult is the outcome of a workflow step
+type WorkflowStepResult struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Model     string `json:"model,omitempty"`
+	Output    string `json:"output,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Usage     *Usage `json:"usage,omitempty"`
+	Saved     string `json:"saved,omitempty"`
+	LatencyMs int64  `json:"latency_ms,omitempty"`
+}
+
+// WorkflowOutput is the JSON output of the run command
+type WorkflowOutput struct {
+	Steps []WorkflowStepResult `json:"steps"`
+}
+
+// runCommand runs the steps of a workflow file in order
+func runCommand(config *Config, args []string) error {
+	const usage = "Usage: chatgpt-cli run [--raw] <workflow.yaml|workflow.json>"
+
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	raw := fs.Bool("raw", false, "print the step outputs as-is instead of rendering markdown")
+
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return usageErrorf("%w\n%s", err, usage)
+	}
+	if len(args) != 1 {
+		return usageErrorf("workflow file is required\n%s", usage)
+	}
+
+	workflow, err := loadWorkflow(args[0])
+	if err != nil {
+		return withKind(ErrUsage, err)
+	}
+	if err := requireAPIKey(config); err != nil {
+		return err
+	}
+
+	out := newUI(config, os.Stdout)
+	render := !*raw && isTerminal(os.Stdout)
+
+	run := newWorkflowRun(config)
+	results := make([]WorkflowStepResult, 0, len(workflow.Steps))
+	stopped := false
+	for i, step := range workflow.Steps {
+		if stopped {
+			results = append(results, WorkflowStepResult{Name: step.Name, Status: stepStatusSkipped})
+			continue
+		}
+
+		result := run.runStep(step)
+		results = append(results, result)
+
+		if config.Output != outputJSON {
+			if i > 0 {
+				out.Println()
+			}
+			out.heading(fmt.Sprintf("Step %d/%d: %s", i+1, len(workflow.Steps), step.Name))
+			if result.Status == stepStatusOK {
+				output := result.Output
+				if render {
+					output = renderMarkdown(output, colorEnabled(config))
+				}
+				out.Println(output)
+			} else {
+				out.Println(out.red("Error: " + result.Error))
+			}
+		}
+
+		if config.requestContext().Err() != nil {
+			return errCancelled
+		}
+		continueOnError := workflow.ContinueOnError
+		if step.ContinueOnError != nil {
+			continueOnError = *step.ContinueOnError
+		}
+		if result.Status == stepStatusFailed && !continueOnError {
+			stopped = true
+		}
+	}
+
+	if config.Output == outputJSON {
+		if err := printJSON(WorkflowOutput{Steps: results}); err != nil {
+			return err
+		}
+	} else {
+		out.Println()
+		printWorkflowSummary(out, results)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Status == stepStatusFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", failed, len(results))
+	}
+	return nil
+}
+
+// loadWorkflow reads and checks a workflow file. Files ending in .json are
+// JSON and others YAML.
+func loadWorkflow(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow: %w", err)
+	}
+
+	// YAML is converted to JSON, so both are decoded the same way
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		doc, err := parseYAML(path, string(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var workflow Workflow
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&workflow); err != nil {
+		return nil, fmt.Errorf("%s: invalid workflow: %s", path, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	if err := workflow.check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &workflow, nil
+}
+
+// check validates the steps and parses their prompt templates. Steps
+// without a name are named step1, step2 and so on.
+func (w *Workflow) check() error {
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("workflow has no steps")
+	}
+
+	names := make(map[string]bool)
+	for i := range w.Steps {
+		step := &w.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step%d", i+1)
+		}
+		if !stepNamePattern.MatchString(step.Name) {
+			return fmt.Errorf("step %d: invalid name %q: use letters, digits and '_', not starting with a digit", i+1, step.Name)
+		}
+		if names[step.Name] {
+			return fmt.Errorf("step %d: name %q is used more than once", i+1, step.Name)
+		}
+		names[step.Name] = true
+
+		if strings.TrimSpace(step.Prompt) == "" {
+			return fmt.Errorf("step %s: prompt is required", step.Name)
+		}
+		tmpl, err := template.New(step.Name).Option("missingkey=error").Parse(step.Prompt)
+		if err != nil {
+			return fmt.Errorf("step %s: invalid prompt template: %w", step.Name, err)
+		}
+		step.template = tmpl
+
+		switch step.Context {
+		case "":
+			step.Context = workflowContextFresh
+		case workflowContextFresh, workflowContextInherit:
+		default:
+			return fmt.Errorf("step %s: context must be %s or %s, got %q", step.Name, workflowContextInherit, workflowContextFresh, step.Context)
+		}
+		if t := step.Temperature; t != nil && (*t < 0 || *t > 2) {
+			return fmt.Errorf("step %s: temperature must be a number between 0.0 and 2.0", step.Name)
+		}
+	}
+	return nil
+}
+
+// workflowRun holds what the steps of a workflow pass on to the next ones
+type workflowRun struct {
+	config *Config
+	// Shared by the steps, so they reuse connections
+	client *APIClient
+	// Template data: the status, output and error of each step run so far
+	steps map[string]map[string]string
+	// Conversation of the last step that succeeded, for context: inherit
+	messages []Message
+}
+
+func newWorkflowRun(config *Config) *workflowRun {
+	return &workflowRun{config: config, client: newAPIClient(config), steps: make(map[string]map[string]string)}
+}
+
+// runStep renders a step's prompt, sends it with the step's model and
+// temperature, logs it and saves its output
+func (r *workflowRun) runStep(step WorkflowStep) WorkflowStepResult {
+	config := *r.config
+	if step.Model != "" {
+		useModel(&config, step.Model)
+	}
+	if step.Temperature != nil {
+		config.Temperature = *step.Temperature
+	}
+
+	result := WorkflowStepResult{Name: step.Name, Model: config.Model}
+	fail := func(err error) WorkflowStepResult {
+		result.Status, result.Error = stepStatusFailed, err.Error()
+		r.steps[step.Name] = map[string]string{"status": result.Status, "output": "", "error": result.Error}
+		return result
+	}
+
+	var prompt bytes.Buffer
+	if err := step.template.Execute(&prompt, map[string]interface{}{"steps": r.steps}); err != nil {
+		return fail(fmt.Errorf("failed to fill in the prompt: %w", err))
+	}
+
+	messages := promptMessages(&config, prompt.String())
+	if step.Context == workflowContextInherit && len(r.messages) > 0 {
+		messages = append(append([]Message(nil), r.messages...), Message{Role: "user", Content: prompt.String()})
+	}
+
+	start := time.Now()
+	stopProgress := startProgress(&config)
+	response, err := r.client.withConfig(&config).Chat(config.requestContext(), messages)
+	stopProgress()
+	if err != nil {
+		logged := err.Error()
+		if isCancelled(err) {
+			logged = cancelledLogMessage
+		}
+		warnLogError(&config, writeLogEntry(&config, LogEntry{Timestamp: time.Now(), Command: "run", Step: step.Name, Prompt: prompt.String(), Error: logged, RequestID: errorRequestID(err)}))
+		return fail(err)
+	}
+
+	result.Output = formatResponse(response)
+	result.Usage = usageOrNil(response.Usage)
+	result.Model = responseModel(&config, response)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	r.messages = append(messages, Message{Role: "assistant", Content: result.Output})
+
+	warnLogError(&config, writeLogEntry(&config, LogEntry{
+		Timestamp: time.Now(),
+		Command:   "run",
+		Step:      step.Name,
+		Prompt:    prompt.String(),
+		Response:  result.Output,
+		Usage:     result.Usage,
+		Model:     result.Model,
+		LatencyMs: result.LatencyMs,
+		RequestID: response.RequestID,
+	}))
+
+	if step.Save != "" {
+		if err := os.WriteFile(step.Save, []byte(ensureTrailingNewline(result.Output)), 0644); err != nil {
+			output := result.Output
+			result = fail(fmt.Errorf("failed to save output: %w", err))
+			result.Output = output
+			return result
+		}
+		result.Saved = step.Save
+	}
+
+	result.Status = stepStatusOK
+	r.steps[step.Name] = map[string]string{"status": result.Status, "output": result.Output, "error": ""}
+	return result
+}
+
+// ensureTrailingNewline returns text ending with a newline
+func ensureTrailingNewline(text string) string {
+	if strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
+
+// printWorkflowSummary prints the table of step statuses after a run
+func printWorkflowSummary(out *ui, results []WorkflowStepResult) {
+	out.heading("Workflow Summary:")
+
+	w := tabwriter.NewWriter(out.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tSTATUS\tMODEL\tTOKENS\tTIME\tSAVED")
+	for _, result := range results {
+		tokens := "-"
+		if result.Usage != nil {
+			tokens = fmt.Sprintf("%d", result.Usage.TotalTokens)
+		}
+		model, saved := result.Model, result.Saved
+		if model == "" {
+			model = "-"
+		}
+		if saved == "" {
+			saved = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Name, result.Status, model, tokens, formatLatency(result.LatencyMs), saved)
+	}
+	w.Flush()
+}
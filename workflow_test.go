@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestWorkflow writes a workflow file and returns its path
+func writeTestWorkflow(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("failed to write workflow: %v", err)
+	}
+	return path
+}
+
+// TestLoadWorkflow tests reading YAML and JSON workflows and the checks of
+// their steps
+func TestLoadWorkflow(t *testing.T) {
+	yamlPath := writeTestWorkflow(t, "flow.yaml", `steps:
+  - name: extract
+    prompt: List the names in this text
+    model: gpt-4o-mini
+    temperature: 0
+  - prompt: "Sort these: {{.steps.extract.output}}"
+    context: inherit
+    save: names.txt
+`)
+	workflow, err := loadWorkflow(yamlPath)
+	if err != nil {
+		t.Fatalf("loadWorkflow() error = %v", err)
+	}
+	if len(workflow.Steps) != 2 || workflow.Steps[0].Model != "gpt-4o-mini" || workflow.Steps[0].Temperature == nil || *workflow.Steps[0].Temperature != 0 {
+		t.Errorf("steps = %+v", workflow.Steps)
+	}
+	if second := workflow.Steps[1]; second.Name != "step2" || second.Context != workflowContextInherit || second.Save != "names.txt" || workflow.Steps[0].Context != workflowContextFresh {
+		t.Errorf("second step = %+v", second)
+	}
+
+	jsonPath := writeTestWorkflow(t, "flow.json", `{"steps": [{"name": "only", "prompt": "hi"}], "continue_on_error": true}`)
+	if workflow, err = loadWorkflow(jsonPath); err != nil || !workflow.ContinueOnError || workflow.Steps[0].Name != "only" {
+		t.Errorf("loadWorkflow(json) = %+v, %v", workflow, err)
+	}
+
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"no steps", "steps:\n", "workflow has no steps"},
+		{"unknown field", "steps:\n  - prompt: hi\n    promt: typo\n", `unknown field "promt"`},
+		{"missing prompt", "steps:\n  - name: a\n", "step a: prompt is required"},
+		{"invalid name", "steps:\n  - name: my-step\n    prompt: hi\n", `invalid name "my-step"`},
+		{"duplicate name", "steps:\n  - name: a\n    prompt: hi\n  - name: a\n    prompt: again\n", `name "a" is used more than once`},
+		{"bad context", "steps:\n  - prompt: hi\n    context: shared\n", "context must be inherit or fresh"},
+		{"bad template", "steps:\n  - prompt: \"{{.steps.\"\n", "invalid prompt template"},
+		{"temperature", "steps:\n  - prompt: hi\n    temperature: 3\n", "temperature must be a number between 0.0 and 2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadWorkflow(writeTestWorkflow(t, "flow.yaml", tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadWorkflow() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunCommand tests that steps run in order with the outputs of earlier
+// steps, their own settings and context, and stop at the first failure
+func TestRunCommand(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var requests []ChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		last := request.Messages[len(request.Messages)-1].Content
+		if strings.Contains(last, "FAIL") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"bad request","type":"invalid_request_error"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		reply, _ := json.Marshal("reply to " + last)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":` + string(reply) + `}}],"usage":{"total_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	config := &Config{
+		APIKey:      "sk-test",
+		APIURL:      server.URL,
+		Model:       "gpt-4o",
+		Temperature: 0.7,
+		Timeout:     5 * time.Second,
+		ConfigDir:   dir,
+		NoColor:     true,
+	}
+	savePath := filepath.Join(dir, "out.txt")
+	path := writeTestWorkflow(t, "flow.yaml", `steps:
+  - name: extract
+    prompt: names
+    model: gpt-4o-mini
+    temperature: 0.1
+  - name: sort
+    prompt: "sort {{.steps.extract.output}}"
+    context: inherit
+    save: `+savePath+`
+  - name: broken
+    prompt: FAIL
+    continue_on_error: true
+  - name: fresh
+    prompt: "again {{.steps.broken.status}}"
+  - name: stop
+    prompt: FAIL again
+  - name: never
+    prompt: unreachable
+`)
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = runCommand(config, []string{path})
+	})
+	if err == nil || err.Error() != "2 of 6 steps failed" {
+		t.Errorf("runCommand() error = %v, want 2 of 6 steps failed", err)
+	}
+
+	if len(requests) != 5 {
+		t.Fatalf("sent %d requests, want 5", len(requests))
+	}
+	if r := requests[0]; r.Model != "gpt-4o-mini" || r.Temperature != 0.1 {
+		t.Errorf("extract sent model %s, temperature %v; want gpt-4o-mini, 0.1", r.Model, r.Temperature)
+	}
+	// The inherited conversation holds the first exchange
+	var sent []string
+	for _, m := range requests[1].Messages {
+		sent = append(sent, m.Role+":"+m.Content)
+	}
+	if got, want := strings.Join(sent, " | "), "user:names | assistant:reply to names | user:sort reply to names"; got != want {
+		t.Errorf("sort sent %q, want %q", got, want)
+	}
+	if r := requests[1]; r.Model != "gpt-4o" || r.Temperature != 0.7 {
+		t.Errorf("sort sent model %s, temperature %v; want the configured ones", r.Model, r.Temperature)
+	}
+	if len(requests[3].Messages) != 1 || requests[3].Messages[0].Content != "again failed" {
+		t.Errorf("fresh sent %+v", requests[3].Messages)
+	}
+
+	saved, err := os.ReadFile(savePath)
+	if err != nil || string(saved) != "reply to sort reply to names\n" {
+		t.Errorf("saved %q, %v", saved, err)
+	}
+
+	for _, want := range []string{
+		"Step 1/6: extract",
+		"reply to names",
+		"Error: unexpected status code: 400",
+		"Workflow Summary:",
+		savePath,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output = %s\nwant it to contain %q", out, want)
+		}
+	}
+
+	// The summary has a row per step, with its status
+	statuses := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 6 {
+			statuses[fields[0]] = fields[1]
+		}
+	}
+	for step, want := range map[string]string{"extract": "ok", "sort": "ok", "broken": "failed", "fresh": "ok", "stop": "failed", "never": "skipped"} {
+		if statuses[step] != want {
+			t.Errorf("summary status of %s = %q, want %q", step, statuses[step], want)
+		}
+	}
+
+	entries := readTestLogEntries(t, dir)
+	if len(entries) != 5 || entries[0].Command != "run" || entries[0].Step != "extract" || entries[2].Error == "" {
+		t.Errorf("log entries = %+v", entries)
+	}
+}
+
+// TestRunCommandJSON tests the JSON output of run
+func TestRunCommandJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"gpt-4o","choices":[{"message":{"role":"assistant","content":"done"}}]}`))
+	}))
+	defer server.Close()
+
+	config := &Config{APIKey: "sk-test", APIURL: server.URL, Model: "gpt-4o", Timeout: 5 * time.Second, ConfigDir: t.TempDir(), Output: outputJSON}
+	path := writeTestWorkflow(t, "flow.json", `{"steps": [{"name": "one", "prompt": "hi"}]}`)
+
+	var err error
+	out := captureOutput(t, &os.Stdout, func() {
+		err = runCommand(config, []string{path})
+	})
+	if err != nil {
+		t.Fatalf("runCommand() error = %v", err)
+	}
+
+	var output WorkflowOutput
+	if err := json.Unmarshal([]byte(out), &output); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if len(output.Steps) != 1 || output.Steps[0].Status != stepStatusOK || output.Steps[0].Output != "done" || output.Steps[0].Model != "gpt-4o" {
+		t.Errorf("output = %+v", output)
+	}
+}
+
+// TestRunCommandUsage tests the errors of run before any step is sent
+func TestRunCommandUsage(t *testing.T) {
+	config := &Config{APIKey: "sk-test", ConfigDir: t.TempDir()}
+	if err := runCommand(config, nil); exitCode(err) != exitUsage {
+		t.Errorf("run without a file error = %v, want a usage error", err)
+	}
+	path := writeTestWorkflow(t, "flow.yaml", "steps:\n")
+	if err := runCommand(config, []string{path}); exitCode(err) != exitUsage {
+		t.Errorf("run of an empty workflow error = %v, want a usage error", err)
+	}
+}
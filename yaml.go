@@ -0,0 +1,424 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Plain YAML scalars read as numbers rather than strings
+var (
+	yamlIntPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatPattern = regexp.MustCompile(`^[-+]?([0-9]+\.[0-9]*|\.[0-9]+|[0-9]+)([eE][-+]?[0-9]+)?$`)
+)
+
+// yamlParser parses the subset of YAML used for workflow files: block
+// mappings and sequences, plain, quoted and block (| and >) scalars, and
+// comments. Flow collections, anchors, tags and multiple documents are not
+// supported. Errors name the file and line.
+//
+// Values are returned as map[string]interface{}, []interface{}, string,
+// bool, int64, float64 or nil, ready to be encoded as JSON.
+type yamlParser struct {
+	name  string
+	lines []string
+	pos   int // Index of the line being parsed
+}
+
+// parseYAML parses a YAML document
+func parseYAML(name, src string) (interface{}, error) {
+	src = strings.TrimPrefix(src, "\ufeff")
+	p := &yamlParser{name: name, lines: strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")}
+
+	if !p.next() {
+		return nil, nil
+	}
+	if indent := p.indent(); indent != 0 {
+		return nil, p.errorf("unexpected indentation")
+	}
+	value, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.next() {
+		return nil, p.errorf("unexpected %q", strings.TrimSpace(p.lines[p.pos]))
+	}
+	return value, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// next skips blank and comment lines, reporting whether a line is left
+func (p *yamlParser) next() bool {
+	for ; p.pos < len(p.lines); p.pos++ {
+		text := strings.TrimSpace(p.lines[p.pos])
+		if text == "---" && p.pos == 0 {
+			continue
+		}
+		if text != "" && !strings.HasPrefix(text, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// indent returns the indentation of the current line
+func (p *yamlParser) indent() int {
+	line := p.lines[p.pos]
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// content returns the current line without its indentation
+func (p *yamlParser) content() string {
+	return strings.TrimLeft(p.lines[p.pos], " ")
+}
+
+// parseNode parses the mapping, sequence or scalar starting on the current
+// line, which is indented by indent
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	text := p.content()
+	if strings.HasPrefix(text, "\t") {
+		return nil, p.errorf("tabs are not allowed in indentation")
+	}
+	if isYAMLSequenceItem(text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok, err := splitYAMLKey(text); err != nil {
+		return nil, p.errorf("%v", err)
+	} else if ok {
+		return p.parseMapping(indent)
+	}
+
+	value, err := p.parseScalar(text)
+	if err != nil {
+		return nil, err
+	}
+	p.pos++
+	return value, nil
+}
+
+// parseMapping parses the keys indented by indent
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	mapping := make(map[string]interface{})
+
+	for p.next() && p.indent() == indent && !isYAMLSequenceItem(p.content()) {
+		key, rest, ok, err := splitYAMLKey(p.content())
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if !ok {
+			return nil, p.errorf("expected \"key: value\", found %q", p.content())
+		}
+		if _, exists := mapping[key]; exists {
+			return nil, p.errorf("key %q is defined more than once", key)
+		}
+
+		if mapping[key], err = p.parseValue(indent, rest, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.pos < len(p.lines) && p.next() && p.indent() > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return mapping, nil
+}
+
+// parseSequence parses the "- " items indented by indent
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	sequence := []interface{}{}
+
+	for p.next() && p.indent() == indent && isYAMLSequenceItem(p.content()) {
+		rest := strings.TrimPrefix(p.content(), "-")
+		trimmed := strings.TrimLeft(rest, " ")
+
+		// An item on the dash line is parsed as if the dash were a space,
+		// so that "- name: x" starts a mapping indented past the dash
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if _, _, ok, _ := splitYAMLKey(trimmed); ok || isYAMLSequenceItem(trimmed) {
+				column := indent + 1 + len(rest) - len(trimmed)
+				p.lines[p.pos] = strings.Repeat(" ", column) + trimmed
+				item, err := p.parseNode(column)
+				if err != nil {
+					return nil, err
+				}
+				sequence = append(sequence, item)
+				continue
+			}
+		}
+
+		item, err := p.parseValue(indent, trimmed, false)
+		if err != nil {
+			return nil, err
+		}
+		sequence = append(sequence, item)
+	}
+
+	if p.pos < len(p.lines) && p.next() && p.indent() > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return sequence, nil
+}
+
+// parseValue parses what follows a key or a dash on the current line: a
+// scalar, a block scalar, or a nested node on the following lines. Under a
+// key, a sequence may be indented as much as the key itself.
+func (p *yamlParser) parseValue(indent int, rest string, underKey bool) (interface{}, error) {
+	rest = stripYAMLComment(rest)
+	switch {
+	case strings.HasPrefix(rest, "|"), strings.HasPrefix(rest, ">"):
+		return p.parseBlockScalar(indent, rest)
+	case rest != "":
+		value, err := p.parseScalar(rest)
+		p.pos++
+		return value, err
+	}
+
+	p.pos++
+	if !p.next() {
+		return nil, nil
+	}
+	child := p.indent()
+	if child > indent || underKey && child == indent && isYAMLSequenceItem(p.content()) {
+		return p.parseNode(child)
+	}
+	return nil, nil
+}
+
+// parseBlockScalar parses a | or > scalar, whose lines are indented more
+// than the line holding the indicator
+func (p *yamlParser) parseBlockScalar(indent int, header string) (interface{}, error) {
+	folded := header[0] == '>'
+	chomping := strings.TrimSpace(header[1:])
+	if chomping != "" && chomping != "-" && chomping != "+" {
+		return nil, p.errorf("unsupported block scalar indicator %q", header)
+	}
+	p.pos++
+
+	// The first non-blank line sets the indentation of the block
+	var lines []string
+	blockIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		text := strings.TrimLeft(line, " ")
+		lineIndent := len(line) - len(text)
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if blockIndent < 0 {
+			if lineIndent <= indent {
+				break
+			}
+			blockIndent = lineIndent
+		}
+		if lineIndent < blockIndent {
+			break
+		}
+		lines = append(lines, line[blockIndent:])
+	}
+
+	// Trailing blank lines only matter to the chomping
+	trailing := 0
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var text string
+	if folded {
+		text = foldYAMLLines(lines)
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	switch {
+	case len(lines) == 0:
+		return "", nil
+	case chomping == "-":
+	case chomping == "+":
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		text += "\n"
+	}
+	return text, nil
+}
+
+// foldYAMLLines joins the lines of a > scalar: lines are joined by spaces,
+// blank lines become newlines, and more indented lines are kept as written
+func foldYAMLLines(lines []string) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case line == "":
+				b.WriteString("\n")
+				continue
+			case prev == "":
+				// The blank lines before wrote the line breaks
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(prev, " "):
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// parseScalar parses a plain or quoted scalar on a single line
+func (p *yamlParser) parseScalar(text string) (interface{}, error) {
+	text = stripYAMLComment(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	switch text[0] {
+	case '"', '\'':
+		value, n, err := parseYAMLQuoted(text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if rest := strings.TrimSpace(text[n:]); rest != "" {
+			return nil, p.errorf("unexpected %q after string", rest)
+		}
+		return value, nil
+	case '[', '{':
+		return nil, p.errorf("flow collections are not supported; quote values starting with %c, as in \"%s\"", text[0], text)
+	case '&', '*', '!':
+		return nil, p.errorf("anchors, aliases and tags are not supported")
+	}
+
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if yamlIntPattern.MatchString(text) {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	if yamlFloatPattern.MatchString(text) {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, nil
+		}
+	}
+	return text, nil
+}
+
+// parseYAMLQuoted parses a single- or double-quoted string at the start of
+// text, returning it and the number of bytes read
+func parseYAMLQuoted(text string) (string, int, error) {
+	quote := text[0]
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && quote == '"':
+			if i+1 >= len(text) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			switch e := text[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'u':
+				if i+4 >= len(text) {
+					return "", 0, fmt.Errorf("invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(text[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid unicode escape \\u%s", text[i+1:i+5])
+				}
+				b.WriteRune(rune(code))
+				i += 4
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c in string", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string; quoted strings must end on the same line")
+}
+
+// splitYAMLKey splits a "key: value" line, reporting whether it is one
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" {
+		return "", "", false, nil
+	}
+
+	if text[0] == '"' || text[0] == '\'' {
+		key, n, err := parseYAMLQuoted(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := strings.TrimLeft(text[n:], " ")
+		if !strings.HasPrefix(after, ":") {
+			return "", "", false, nil
+		}
+		return key, strings.TrimSpace(after[1:]), true, nil
+	}
+
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '#' && i > 0 && text[i-1] == ' ':
+			return "", "", false, nil
+		case text[i] == ':' && (i+1 == len(text) || text[i+1] == ' '):
+			key := strings.TrimSpace(text[:i])
+			if key == "" {
+				return "", "", false, nil
+			}
+			return key, strings.TrimSpace(text[i+1:]), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// stripYAMLComment removes a comment after a value, which starts with a #
+// after a space outside of quotes
+func stripYAMLComment(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "#") {
+		return ""
+	}
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		if _, n, err := parseYAMLQuoted(text); err == nil {
+			rest := strings.TrimSpace(text[n:])
+			if strings.HasPrefix(rest, "#") {
+				return text[:n]
+			}
+		}
+		return text
+	}
+	if i := strings.Index(text, " #"); i >= 0 {
+		return strings.TrimSpace(text[:i])
+	}
+	return text
+}
+
+// isYAMLSequenceItem reports whether a line starts a sequence item
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestParseYAML tests the supported subset of YAML by its JSON encoding
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "mapping",
+			src:  "name: extract\ntemperature: 0.2\ncount: 3\nenabled: true\nnothing: ~\n",
+			want: `{"count":3,"enabled":true,"name":"extract","nothing":null,"temperature":0.2}`,
+		},
+		{
+			name: "sequence of mappings",
+			src:  "steps:\n  - name: a\n    prompt: one\n  - name: b\n    prompt: two\n",
+			want: `{"steps":[{"name":"a","prompt":"one"},{"name":"b","prompt":"two"}]}`,
+		},
+		{
+			name: "sequence as indented as its key",
+			src:  "steps:\n- name: a\n- name: b\ndone: yes\n",
+			want: `{"done":"yes","steps":[{"name":"a"},{"name":"b"}]}`,
+		},
+		{
+			name: "comments",
+			src:  "# workflow\nname: a # the name\n\n# end\n",
+			want: `{"name":"a"}`,
+		},
+		{
+			name: "quoted strings",
+			src:  `a: "{{.steps.x.output}} # kept"` + "\nb: 'it''s'\nc: \"tab\\there\"\n\"d e\": 1\n",
+			want: `{"a":"{{.steps.x.output}} # kept","b":"it's","c":"tab\there","d e":1}`,
+		},
+		{
+			name: "literal block",
+			src:  "prompt: |\n  Line one\n    indented\n\n  Line three\nnext: x\n",
+			want: `{"next":"x","prompt":"Line one\n  indented\n\nLine three\n"}`,
+		},
+		{
+			name: "folded block without final newline",
+			src:  "prompt: >-\n  one\n  two\n\n  three\n",
+			want: `{"prompt":"one two\nthree"}`,
+		},
+		{
+			name: "scalar items",
+			src:  "- one\n- 2\n-\n  nested: x\n",
+			want: `["one",2,{"nested":"x"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := parseYAML("test.yaml", tt.src)
+			if err != nil {
+				t.Fatalf("parseYAML() error = %v", err)
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("parseYAML() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseYAMLErrors tests that unsupported or invalid YAML is reported
+// with its line
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"flow sequence", "a: [1, 2]\n", "test.yaml:1: flow collections are not supported"},
+		{"unquoted template", "prompt: {{.steps.a.output}}\n", "quote values starting with {"},
+		{"duplicate key", "a: 1\na: 2\n", "test.yaml:2: key \"a\" is defined more than once"},
+		{"bad indentation", "a: 1\n   b: 2\n", "test.yaml:2: unexpected indentation"},
+		{"unterminated string", "a: \"open\n", "test.yaml:1: unterminated string"},
+		{"anchor", "a: &x 1\n", "anchors, aliases and tags are not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML("test.yaml", tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseYAML() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}